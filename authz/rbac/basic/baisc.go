@@ -39,7 +39,8 @@ var modelData = []byte(`
 # sub: subject (user or role identifier)
 # obj: object (requested resource path, e.g., /api/users/123)
 # act: action (HTTP method, e.g., GET/POST/PUT/DELETE/PATCH)
-r = sub, obj, act
+# ctx: request context conditions are evaluated against (time, client ip, headers, resource owner)
+r = sub, obj, act, ctx
 
 [policy_definition]
 # p defines the stored policy tuple:
@@ -47,7 +48,8 @@ r = sub, obj, act
 # obj: policy object (resource template, e.g., /api/users/{id})
 # act: policy action (HTTP method)
 # eft: effect ("allow" or "deny")
-p = sub, obj, act, eft
+# cond: condition expression, "true" for unconditional policies, see rbac.Context
+p = sub, obj, act, eft, cond
 
 [role_definition]
 # g defines role membership:
@@ -69,13 +71,19 @@ e = some(where (p.eft == allow))
 #    - g(r.sub, p.sub): subject belongs to policy role
 #    - keyMatch3(r.obj, p.obj): REST path template matches (e.g., /api/users/{id})
 #    - r.act == p.act: HTTP method equals
-m = g(r.sub, "admin") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act)
+#    - cond(...): the policy condition is met, it is evaluated last since it may load the resource owner
+m = g(r.sub, "admin") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act && \
+    cond(p.cond, r.sub, r.obj, r.act, r.ctx))
 `)
 
 func Init() (err error) {
 	if !config.App.Auth.RBACEnable {
 		return nil
 	}
+	// The tenant aware model is initialized by package "authz/rbac/tenant" instead.
+	if config.App.Auth.RBACTenantEnable {
+		return nil
+	}
 
 	filename := filepath.Join(config.Tempdir(), "casbin_model.conf")
 	if err = os.WriteFile(filename, modelData, 0o600); err != nil {
@@ -85,10 +93,17 @@ func Init() (err error) {
 	if rbac.Adapter, err = gormadapter.NewAdapterByDBWithCustomTable(database.DB, new(modelauthz.CasbinRule)); err != nil {
 		return errors.Wrap(err, "failed to create casbin adapter")
 	}
+	// Policies created before conditions were supported have no "cond" field.
+	if err = database.DB.Model(new(modelauthz.CasbinRule)).
+		Where("ptype = ? AND (v4 = '' OR v4 IS NULL)", "p").
+		UpdateColumn("v4", consts.AUTHZ_CONDITION_NONE).Error; err != nil {
+		return errors.Wrap(err, "failed to migrate casbin policy conditions")
+	}
 	if rbac.Enforcer, err = casbin.NewEnforcer(filename, rbac.Adapter); err != nil {
 		return errors.Wrap(err, "failed to create casbin enforcer")
 	}
 
+	rbac.AddConditionFunctions(rbac.Enforcer)
 	rbac.Enforcer.SetLogger(logger.Casbin)
 	rbac.Enforcer.EnableAutoSave(true)
 	rbac.Enforcer.EnableAutoNotifyDispatcher(true)
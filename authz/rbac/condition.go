@@ -0,0 +1,289 @@
+package rbac
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	casbinmodel "github.com/casbin/casbin/v3/model"
+	casbinutil "github.com/casbin/casbin/v3/util"
+	"github.com/casbin/govaluate"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// Context is the request context conditional policies are evaluated against, it is "r.ctx" in the model.
+//
+// A policy condition is a govaluate expression like casbin matchers, it can reference
+// the request fields "r.sub", "r.obj", "r.act", the fields of Context and the casbin
+// builtin functions, eg:
+//
+//	r.ctx.Weekday >= 1 && r.ctx.Weekday <= 5 && timeBetween(r.ctx, '09:00', '18:00')
+//	ipIn(r.ctx, '10.0.0.0/8', '192.168.1.0/24')
+//	header(r.ctx, 'X-Env') == 'prod'
+//	owner(r.ctx) == r.sub
+//
+// The unconditional policies use the condition "true".
+type Context struct {
+	Hour     int    // 0-23
+	Minute   int    // 0-59
+	Weekday  int    // 0 is Sunday
+	Date     string // 2006-01-02
+	ClientIP string
+
+	// Route is the route template of the request, eg: /api/users/:id,
+	// ResourceID is the value of its last path parameter.
+	// They are used to load the owner of the resource, see RegisterOwner.
+	Route      string
+	ResourceID string
+
+	header http.Header
+	ctx    context.Context
+	owner  *string
+}
+
+// NewContext creates the condition context of a request received at the given time.
+func NewContext(ctx context.Context, now time.Time, clientIP string, header http.Header) *Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &Context{
+		Hour:     now.Hour(),
+		Minute:   now.Minute(),
+		Weekday:  int(now.Weekday()),
+		Date:     now.Format(time.DateOnly),
+		ClientIP: clientIP,
+		header:   header,
+		ctx:      ctx,
+	}
+}
+
+// SetOwner sets the owner of the requested resource, the owner loader is not used anymore.
+func (c *Context) SetOwner(owner string) { c.owner = &owner }
+
+// Owner returns the owner(created_by) of the requested resource, it is loaded on
+// first use by the loader registered for Route and empty if there is none.
+func (c *Context) Owner() (string, error) {
+	if c.owner != nil {
+		return *c.owner, nil
+	}
+	var owner string
+	ownerMu.RLock()
+	loader := ownerLoaders[c.Route]
+	ownerMu.RUnlock()
+	if loader != nil && len(c.ResourceID) > 0 {
+		var err error
+		if owner, err = loader(c.ctx, c.ResourceID); err != nil {
+			return "", err
+		}
+	}
+	c.owner = &owner
+	return owner, nil
+}
+
+// OwnerLoader loads the owner(created_by) of the resource with the given id.
+type OwnerLoader func(ctx context.Context, id string) (string, error)
+
+var (
+	ownerLoaders = make(map[string]OwnerLoader)
+	ownerMu      sync.RWMutex
+)
+
+// RegisterOwner registers the owner loader of the route template, eg: /api/users/:id.
+// The router registers the loaders of model routes automatically.
+func RegisterOwner(route string, loader OwnerLoader) {
+	ownerMu.Lock()
+	defer ownerMu.Unlock()
+	ownerLoaders[route] = loader
+}
+
+// conditionFunctions are the functions available in policy conditions besides the casbin builtin ones.
+var conditionFunctions = map[string]govaluate.ExpressionFunction{
+	// timeBetween(r.ctx, '09:00', '18:00') reports whether the request time is in [start, end),
+	// end before start means the range spans midnight.
+	"timeBetween": func(args ...any) (any, error) {
+		c, err := contextArg("timeBetween", 3, args)
+		if err != nil {
+			return nil, err
+		}
+		start, err := minuteOfDay(args[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := minuteOfDay(args[2])
+		if err != nil {
+			return nil, err
+		}
+		now := c.Hour*60 + c.Minute
+		if start <= end {
+			return now >= start && now < end, nil
+		}
+		return now >= start || now < end, nil
+	},
+	// ipIn(r.ctx, '10.0.0.0/8', ...) reports whether the client ip is in any of the CIDRs.
+	"ipIn": func(args ...any) (any, error) {
+		if len(args) < 2 {
+			return nil, errors.New("function ipIn(ctx, cidr...) expected at least 2 arguments")
+		}
+		c, err := contextArg("ipIn", len(args), args)
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(c.ClientIP)
+		if ip == nil {
+			return false, nil
+		}
+		for _, arg := range args[1:] {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, errors.New("cidr of function ipIn must be a string")
+			}
+			_, cidr, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid cidr %q of function ipIn", s)
+			}
+			if cidr.Contains(ip) {
+				return true, nil
+			}
+		}
+		return false, nil
+	},
+	// header(r.ctx, 'X-Env') returns the request header value.
+	"header": func(args ...any) (any, error) {
+		c, err := contextArg("header", 2, args)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := args[1].(string)
+		if !ok {
+			return nil, errors.New("name of function header must be a string")
+		}
+		return c.header.Get(name), nil
+	},
+	// owner(r.ctx) returns the owner of the requested resource.
+	"owner": func(args ...any) (any, error) {
+		c, err := contextArg("owner", 1, args)
+		if err != nil {
+			return nil, err
+		}
+		return c.Owner()
+	},
+}
+
+var (
+	// conditions caches the compiled policy conditions.
+	conditions sync.Map
+	functions  = sync.OnceValue(func() map[string]govaluate.ExpressionFunction {
+		fm := casbinmodel.LoadFunctionMap()
+		for name, fn := range conditionFunctions {
+			fm.AddFunction(name, fn)
+		}
+		return fm.GetFunctions()
+	})
+)
+
+// AddConditionFunctions adds the matcher function "cond" to the enforcer,
+// the models evaluate the policy condition with cond(p.cond, r.sub, r.obj, r.act, r.ctx).
+func AddConditionFunctions(e *casbin.Enforcer) {
+	e.AddFunction("cond", func(args ...any) (any, error) {
+		if len(args) != 5 {
+			return nil, errors.Newf("function cond expected 5 arguments, but got %d", len(args))
+		}
+		cond, _ := args[0].(string)
+		sub, _ := args[1].(string)
+		obj, _ := args[2].(string)
+		act, _ := args[3].(string)
+		c, _ := args[4].(*Context)
+		return EvalCondition(cond, sub, obj, act, c)
+	})
+}
+
+// ValidateCondition reports whether the condition is a valid expression,
+// empty condition means unconditional and is valid.
+func ValidateCondition(cond string) error {
+	if len(cond) == 0 {
+		return nil
+	}
+	_, err := compileCondition(cond)
+	return err
+}
+
+// EvalCondition evaluates the policy condition against the request,
+// empty condition and "true" are always met.
+// Nil Context is replaced with the context of a request received now without client ip and headers.
+func EvalCondition(cond, sub, obj, act string, c *Context) (bool, error) {
+	if len(cond) == 0 || cond == consts.AUTHZ_CONDITION_NONE {
+		return true, nil
+	}
+	expr, err := compileCondition(cond)
+	if err != nil {
+		return false, err
+	}
+	if c == nil {
+		c = NewContext(context.Background(), time.Now(), "", nil)
+	}
+	result, err := expr.Evaluate(map[string]any{
+		"r_sub": sub,
+		"r_obj": obj,
+		"r_act": act,
+		"r_ctx": c,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to evaluate condition %q", cond)
+	}
+	ok, isBool := result.(bool)
+	if !isBool {
+		return false, errors.Newf("condition %q is not a boolean expression", cond)
+	}
+	return ok, nil
+}
+
+func compileCondition(cond string) (*govaluate.EvaluableExpression, error) {
+	if v, ok := conditions.Load(cond); ok {
+		return v.(*govaluate.EvaluableExpression), nil
+	}
+	expr, err := govaluate.NewEvaluableExpressionWithFunctions(casbinutil.EscapeAssertion(cond), functions())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid condition %q", cond)
+	}
+	conditions.Store(cond, expr)
+	return expr, nil
+}
+
+// normalizeCondition returns the stored form of the condition.
+// Empty condition is stored as "true" because casbin adapters drop trailing empty fields.
+func normalizeCondition(cond string) string {
+	if len(cond) == 0 {
+		return consts.AUTHZ_CONDITION_NONE
+	}
+	return cond
+}
+
+func contextArg(fn string, n int, args []any) (*Context, error) {
+	if len(args) != n {
+		return nil, errors.Newf("function %s expected %d arguments, but got %d", fn, n, len(args))
+	}
+	c, ok := args[0].(*Context)
+	if !ok {
+		return nil, errors.Newf("first argument of function %s must be r.ctx", fn)
+	}
+	return c, nil
+}
+
+func minuteOfDay(arg any) (int, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return 0, errors.New("time must be a string formatted as 15:04")
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,86 @@
+package rbac_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	"github.com/casbin/casbin/v3/model"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalCondition(t *testing.T) {
+	// 2025-01-06 is a Monday.
+	monday := time.Date(2025, 1, 6, 10, 30, 0, 0, time.Local)
+	sunday := time.Date(2025, 1, 5, 23, 30, 0, 0, time.Local)
+	header := http.Header{}
+	header.Set("X-Env", "prod")
+
+	tests := []struct {
+		name string
+		cond string
+		ctx  *rbac.Context
+		want bool
+	}{
+		{"empty", "", nil, true},
+		{"none", "true", nil, true},
+		{"business hours", "r.ctx.Weekday >= 1 && r.ctx.Weekday <= 5 && timeBetween(r.ctx, '09:00', '18:00')", rbac.NewContext(context.Background(), monday, "", nil), true},
+		{"weekend", "r.ctx.Weekday >= 1 && r.ctx.Weekday <= 5", rbac.NewContext(context.Background(), sunday, "", nil), false},
+		{"over midnight", "timeBetween(r.ctx, '22:00', '06:00')", rbac.NewContext(context.Background(), sunday, "", nil), true},
+		{"office cidr", "ipIn(r.ctx, '10.0.0.0/8', '192.168.1.0/24')", rbac.NewContext(context.Background(), monday, "192.168.1.20", nil), true},
+		{"outside cidr", "ipIn(r.ctx, '10.0.0.0/8')", rbac.NewContext(context.Background(), monday, "172.16.0.1", nil), false},
+		{"invalid ip", "ipIn(r.ctx, '10.0.0.0/8')", rbac.NewContext(context.Background(), monday, "", nil), false},
+		{"header", "header(r.ctx, 'X-Env') == 'prod'", rbac.NewContext(context.Background(), monday, "", header), true},
+		{"subject", "r.sub == 'alice'", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rbac.EvalCondition(tt.cond, "alice", "/api/docs/1", "GET", tt.ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	require.Error(t, rbac.ValidateCondition("r.ctx.Hour >="))
+	require.NoError(t, rbac.ValidateCondition("keyMatch(r.obj, '/api/*') && owner(r.ctx) == r.sub"))
+}
+
+func TestConditionalPermission(t *testing.T) {
+	m, err := model.NewModelFromString(basicModel)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	rbac.AddConditionFunctions(e)
+
+	rbac.RegisterOwner("/api/docs/:id", func(_ context.Context, id string) (string, error) {
+		return map[string]string{"1": "alice", "2": "bob"}[id], nil
+	})
+
+	r := rbac.New(e)
+	require.NoError(t, r.AssignRole("alice", "editor"))
+	require.NoError(t, r.GrantPermission("editor", "/api/docs/{id}", "PUT"))
+	require.NoError(t, r.GrantConditionalPermission("editor", "/api/docs/{id}", "PUT", "owner(r.ctx) == r.sub"))
+	require.Error(t, r.GrantConditionalPermission("editor", "/api/docs/{id}", "PUT", "owner(r.ctx) =="))
+
+	// The conditional grant replaces the unconditional one.
+	policies, err := e.GetFilteredPolicy(0, "editor")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"editor", "/api/docs/{id}", "PUT", "allow", "owner(r.ctx) == r.sub"}}, policies)
+
+	enforce := func(id string) bool {
+		ctx := rbac.NewContext(context.Background(), time.Now(), "", nil)
+		ctx.Route = "/api/docs/:id"
+		ctx.ResourceID = id
+		ok, err := e.Enforce("alice", "/api/docs/"+id, "PUT", ctx)
+		require.NoError(t, err)
+		return ok
+	}
+	require.True(t, enforce("1"))
+	require.False(t, enforce("2"))
+
+	require.NoError(t, r.RevokePermission("editor", "/api/docs/{id}", "PUT"))
+	require.False(t, enforce("1"))
+}
@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/casbin/casbin/v3"
+)
+
+// Clone returns an in-memory copy of the enforcer with the same model and policies.
+// The copy has no adapter, watcher or dispatcher, so changes made to it are never
+// persisted or propagated, it is used to evaluate proposed policy changes.
+func Clone(e *casbin.Enforcer) (*casbin.Enforcer, error) {
+	c, err := casbin.NewEnforcer(e.GetModel().Copy())
+	if err != nil {
+		return nil, err
+	}
+	c.EnableAutoSave(false)
+	AddConditionFunctions(c)
+	if err = c.BuildRoleLinks(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RoleChains returns the role inheritance chains of the subject, every chain starts
+// with the subject and ends with a role that inherits no further role.
+// The domain is only used with the tenant aware model.
+func RoleChains(e *casbin.Enforcer, subject string, domain ...string) ([][]string, error) {
+	chains := make([][]string, 0)
+	var walk func(path []string) error
+	walk = func(path []string) error {
+		roles, err := e.GetRolesForUser(path[len(path)-1], domain...)
+		if err != nil {
+			return err
+		}
+		extended := false
+		for _, role := range roles {
+			if slices.Contains(path, role) {
+				// Skip cycles, casbin rejects most of them but policies may be edited directly.
+				continue
+			}
+			extended = true
+			next := append(append(make([]string, 0, len(path)+1), path...), role)
+			if err = walk(next); err != nil {
+				return err
+			}
+		}
+		if !extended && len(path) > 1 {
+			chains = append(chains, path)
+		}
+		return nil
+	}
+	if err := walk([]string{subject}); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
@@ -0,0 +1,63 @@
+package rbac_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	"github.com/casbin/casbin/v3/model"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/stretchr/testify/require"
+)
+
+const basicModel = `
+[request_definition]
+r = sub, obj, act, ctx
+[policy_definition]
+p = sub, obj, act, eft, cond
+[role_definition]
+g = _, _
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = g(r.sub, "admin") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act && cond(p.cond, r.sub, r.obj, r.act, r.ctx))
+`
+
+func TestCloneAndRoleChains(t *testing.T) {
+	m, err := model.NewModelFromString(basicModel)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	rbac.AddConditionFunctions(e)
+
+	r := rbac.New(e)
+	require.NoError(t, r.AssignRole("alice", "editor"))
+	require.NoError(t, r.AssignRole("editor", "viewer"))
+	require.NoError(t, r.AssignRole("alice", "auditor"))
+	require.NoError(t, r.GrantPermission("viewer", "/api/docs/{id}", "GET"))
+
+	chains, err := rbac.RoleChains(e, "alice")
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]string{{"alice", "editor", "viewer"}, {"alice", "auditor"}}, chains)
+
+	c, err := rbac.Clone(e)
+	require.NoError(t, err)
+	ctx := rbac.NewContext(context.Background(), time.Now(), "", nil)
+	ok, err := c.Enforce("alice", "/api/docs/1", "GET", ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Changes to the copy must not leak into the original enforcer.
+	require.NoError(t, rbac.New(c).UnassignRole("editor", "viewer"))
+	require.NoError(t, rbac.New(c).GrantPermission("auditor", "/api/logs", "GET"))
+	ok, err = c.Enforce("alice", "/api/docs/1", "GET", ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = e.Enforce("alice", "/api/docs/1", "GET", ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = e.Enforce("alice", "/api/logs", "GET", ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -1,6 +1,8 @@
 package rbac
 
 import (
+	"slices"
+
 	"github.com/casbin/casbin/v3"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/forbearing/gst/types"
@@ -22,12 +24,13 @@ type rbac struct {
 // has not been initialized yet to avoid nil pointer panics.
 type noop struct{}
 
-func (noop) AddRole(name string) error                                          { return nil }
-func (noop) RemoveRole(name string) error                                       { return nil }
-func (noop) GrantPermission(role string, resource string, action string) error  { return nil }
-func (noop) RevokePermission(role string, resource string, action string) error { return nil }
-func (noop) AssignRole(subject string, role string) error                       { return nil }
-func (noop) UnassignRole(subject string, role string) error                     { return nil }
+func (noop) AddRole(name string) error                                            { return nil }
+func (noop) RemoveRole(name string) error                                         { return nil }
+func (noop) GrantPermission(role string, resource string, action string) error    { return nil }
+func (noop) GrantConditionalPermission(role, resource, action, cond string) error { return nil }
+func (noop) RevokePermission(role string, resource string, action string) error   { return nil }
+func (noop) AssignRole(subject string, role string) error                         { return nil }
+func (noop) UnassignRole(subject string, role string) error                       { return nil }
 
 func RBAC() types.RBAC {
 	// When RBAC is disabled or Enforcer is not initialized,
@@ -41,6 +44,11 @@ func RBAC() types.RBAC {
 	}
 }
 
+// New returns the RBAC operating on the given enforcer, eg: the in-memory copy returned by Clone.
+func New(e *casbin.Enforcer) types.RBAC {
+	return &rbac{enforcer: e}
+}
+
 // AddRole is a no-op in Casbin, roles are created implicitly when used.
 func (r *rbac) AddRole(name string) error {
 	return nil
@@ -54,17 +62,21 @@ func (r *rbac) RemoveRole(name string) error {
 }
 
 func (r *rbac) GrantPermission(role string, resource string, action string) error {
-	if _, err := r.enforcer.AddPermissionForUser(role, resource, action, "allow"); err != nil {
+	return r.GrantConditionalPermission(role, resource, action, "")
+}
+
+func (r *rbac) GrantConditionalPermission(role string, resource string, action string, cond string) error {
+	if err := ValidateCondition(cond); err != nil {
 		return err
 	}
-	return nil
+	return grantPolicy(r.enforcer, []string{role, resource, action, string(consts.EffectAllow), normalizeCondition(cond)})
 }
 
 // RevokePermission removes policies for the given role with flexible behaviors:
 // - resource=="" && action=="" : remove all policies for the role
 // - resource=="" && action!="" : remove policies matching the role and action
 // - resource!="" && action=="" : remove policies matching the role and resource
-// - resource!="" && action!="" : remove the exact (role, resource, action, "allow") policy
+// - resource!="" && action!="" : remove the (role, resource, action) policies regardless of their condition
 func (r *rbac) RevokePermission(role string, resource string, action string) error {
 	if len(resource) == 0 && len(action) == 0 {
 		if _, err := r.enforcer.RemoveFilteredPolicy(0, role); err != nil {
@@ -84,12 +96,31 @@ func (r *rbac) RevokePermission(role string, resource string, action string) err
 		}
 		return nil
 	}
-	if _, err := r.enforcer.DeletePermissionForUser(role, resource, action, string(consts.EffectAllow)); err != nil {
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, role, resource, action); err != nil {
 		return err
 	}
 	return nil
 }
 
+// grantPolicy adds the policy whose last two fields are the effect and condition,
+// the policies only differing in them are removed, so a grant has exactly one condition.
+func grantPolicy(e *casbin.Enforcer, policy []string) error {
+	existing, err := e.GetFilteredPolicy(0, policy[:len(policy)-2]...)
+	if err != nil {
+		return err
+	}
+	for _, p := range existing {
+		if slices.Equal(p, policy) {
+			return nil
+		}
+		if _, err = e.RemovePolicy(p); err != nil {
+			return err
+		}
+	}
+	_, err = e.AddPolicy(policy)
+	return err
+}
+
 func (r *rbac) AssignRole(subject string, role string) error {
 	if _, err := r.enforcer.AddRoleForUser(subject, role); err != nil {
 		return err
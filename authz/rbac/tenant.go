@@ -0,0 +1,169 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/casbin/casbin/v3"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// TenantEnforcer is the tenant(domain) aware enforcer, it is only initialized
+// when both RBAC and tenant RBAC are enabled, see package "authz/rbac/tenant".
+var TenantEnforcer *casbin.Enforcer
+
+type tenantRBAC struct {
+	enforcer *casbin.Enforcer
+}
+
+// tenantNoop implements a no-op TenantRBAC, see noop.
+type tenantNoop struct{}
+
+func (tenantNoop) AddRole(tenant, name string) error                           { return nil }
+func (tenantNoop) RemoveRole(tenant, name string) error                        { return nil }
+func (tenantNoop) GrantPermission(tenant, role, resource, action string) error { return nil }
+func (tenantNoop) GrantConditionalPermission(tenant, role, resource, action, cond string) error {
+	return nil
+}
+func (tenantNoop) RevokePermission(tenant, role, resource, action string) error { return nil }
+func (tenantNoop) AssignRole(tenant, subject, role string) error                { return nil }
+func (tenantNoop) UnassignRole(tenant, subject, role string) error              { return nil }
+
+// TenantEnabled reports whether the tenant aware enforcer is in use.
+func TenantEnabled() bool { return TenantEnforcer != nil }
+
+// IsSuperAdmin reports whether the subject is granted "super_admin" in the wildcard tenant,
+// super admins act in every tenant. It's false if tenant RBAC is disabled.
+func IsSuperAdmin(sub string) bool {
+	if TenantEnforcer == nil || len(sub) == 0 {
+		return false
+	}
+	return slices.Contains(TenantEnforcer.GetRolesForUserInDomain(sub, consts.AUTHZ_TENANT_ALL), consts.AUTHZ_ROLE_SUPER_ADMIN)
+}
+
+// InTenant reports whether the subject may act in the tenant, that is it's a super admin
+// or holds a role in the tenant. The wildcard tenant is never a tenant to act in.
+// It's true if tenant RBAC is disabled.
+func InTenant(sub, tenant string) bool {
+	if TenantEnforcer == nil {
+		return true
+	}
+	if tenant == consts.AUTHZ_TENANT_ALL || len(tenant) == 0 {
+		return false
+	}
+	return IsSuperAdmin(sub) || len(TenantEnforcer.GetRolesForUserInDomain(sub, tenant)) > 0
+}
+
+func TenantRBAC() types.TenantRBAC {
+	if TenantEnforcer == nil {
+		return tenantNoop{}
+	}
+	return &tenantRBAC{enforcer: TenantEnforcer}
+}
+
+// NewTenant returns the TenantRBAC operating on the given tenant aware enforcer, see New.
+func NewTenant(e *casbin.Enforcer) types.TenantRBAC {
+	return &tenantRBAC{enforcer: e}
+}
+
+// Domain returns a RBAC bound to the tenant, so callers can manage roles
+// the same way regardless of whether tenant RBAC is enabled.
+// When tenant RBAC is disabled, the tenant is ignored and RBAC() is returned.
+// Empty tenant means the wildcard tenant "*".
+func Domain(tenant string) types.RBAC {
+	if TenantEnforcer == nil {
+		return RBAC()
+	}
+	return WithTenant(TenantRBAC(), tenant)
+}
+
+// WithTenant binds the TenantRBAC to the tenant, empty tenant means the wildcard tenant "*".
+func WithTenant(r types.TenantRBAC, tenant string) types.RBAC {
+	if len(tenant) == 0 {
+		tenant = consts.AUTHZ_TENANT_ALL
+	}
+	return &domain{tenant: tenant, rbac: r}
+}
+
+// AddRole is a no-op in Casbin, roles are created implicitly when used.
+func (r *tenantRBAC) AddRole(tenant, name string) error {
+	return nil
+}
+
+func (r *tenantRBAC) RemoveRole(tenant, name string) error {
+	if tenant == consts.AUTHZ_TENANT_ALL {
+		// The role is global, its grants spread over all tenants.
+		if _, err := r.enforcer.RemoveFilteredGroupingPolicy(1, name); err != nil {
+			return err
+		}
+	} else {
+		if _, err := r.enforcer.RemoveFilteredGroupingPolicy(1, name, tenant); err != nil {
+			return err
+		}
+	}
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, tenant, name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *tenantRBAC) GrantPermission(tenant, role, resource, action string) error {
+	return r.GrantConditionalPermission(tenant, role, resource, action, "")
+}
+
+func (r *tenantRBAC) GrantConditionalPermission(tenant, role, resource, action, cond string) error {
+	if err := ValidateCondition(cond); err != nil {
+		return err
+	}
+	return grantPolicy(r.enforcer, []string{tenant, role, resource, action, string(consts.EffectAllow), normalizeCondition(cond)})
+}
+
+func (r *tenantRBAC) RevokePermission(tenant, role, resource, action string) error {
+	// Empty field values in a filter match any value.
+	if _, err := r.enforcer.RemoveFilteredPolicy(0, tenant, role, resource, action); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *tenantRBAC) AssignRole(tenant, subject, role string) error {
+	if _, err := r.enforcer.AddRoleForUserInDomain(subject, role, tenant); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *tenantRBAC) UnassignRole(tenant, subject, role string) error {
+	if _, err := r.enforcer.DeleteRoleForUserInDomain(subject, role, tenant); err != nil {
+		return err
+	}
+	return nil
+}
+
+// domain adapts TenantRBAC with a fixed tenant to RBAC.
+type domain struct {
+	tenant string
+	rbac   types.TenantRBAC
+}
+
+func (d *domain) AddRole(name string) error    { return d.rbac.AddRole(d.tenant, name) }
+func (d *domain) RemoveRole(name string) error { return d.rbac.RemoveRole(d.tenant, name) }
+func (d *domain) GrantPermission(role, resource, action string) error {
+	return d.rbac.GrantPermission(d.tenant, role, resource, action)
+}
+
+func (d *domain) GrantConditionalPermission(role, resource, action, cond string) error {
+	return d.rbac.GrantConditionalPermission(d.tenant, role, resource, action, cond)
+}
+
+func (d *domain) RevokePermission(role, resource, action string) error {
+	return d.rbac.RevokePermission(d.tenant, role, resource, action)
+}
+
+func (d *domain) AssignRole(subject, role string) error {
+	return d.rbac.AssignRole(d.tenant, subject, role)
+}
+
+func (d *domain) UnassignRole(subject, role string) error {
+	return d.rbac.UnassignRole(d.tenant, subject, role)
+}
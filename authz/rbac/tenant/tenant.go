@@ -1,25 +1,106 @@
 package tenant
 
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/casbin/casbin/v3"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	modelauthz "github.com/forbearing/gst/internal/model/authz"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/types/consts"
+)
+
+var defaultSuperAdmins = []string{
+	consts.AUTHZ_USER_ROOT,
+	consts.AUTHZ_USER_ADMIN,
+}
+
 var modelData = []byte(`
 [request_definition]
-r = tenant, sub, obj, act
+# r defines the incoming request tuple:
+# tenant: the tenant(domain) the request acts in
+# sub: subject (user identifier)
+# obj: object (requested resource path, e.g., /api/users/123)
+# act: action (HTTP method, e.g., GET/POST/PUT/DELETE/PATCH)
+# ctx: request context conditions are evaluated against (time, client ip, headers, resource owner)
+r = tenant, sub, obj, act, ctx
 
 [policy_definition]
-p = tenant, sub, obj, act, eft
+# p defines the stored policy tuple:
+# tenant: the tenant the policy belongs to, "*" applies to all tenants
+# sub: policy subject (role code)
+# obj: policy object (resource template, e.g., /api/users/{id})
+# act: policy action (HTTP method)
+# eft: effect ("allow" or "deny")
+# cond: condition expression, "true" for unconditional policies, see rbac.Context
+p = tenant, sub, obj, act, eft, cond
 
 [role_definition]
+# g defines tenant scoped role membership:
+# g(user, role, tenant) means "user" belongs to "role" in "tenant"
 g = _, _, _
 
 [policy_effect]
 e = some(where (p.eft == allow))
 
 [matchers]
-m = (g(r.sub, "super_admin", "*") || r.tenant == p.tenant) &&
-    g(r.sub, p.sub, p.tenant) &&
-    keyMatch3(r.obj, p.obj) &&
-    r.act == p.act
+# Matcher logic:
+# 1) Super admin bypass: if subject belongs to "super_admin" in tenant "*", allow across all tenants
+# 2) Tenant admin bypass: if subject belongs to "admin" in the request tenant, allow
+# 3) Otherwise: require role membership in the request tenant AND the policy belongs to
+#    the request tenant or to all tenants AND path match AND method match AND the condition is met
+m = g(r.sub, "super_admin", "*") || \
+    g(r.sub, "admin", r.tenant) || \
+    (g(r.sub, p.sub, r.tenant) && (p.tenant == r.tenant || p.tenant == "*") && \
+    keyMatch3(r.obj, p.obj) && r.act == p.act && cond(p.cond, r.sub, r.obj, r.act, r.ctx))
 `)
 
-func Init() error {
-	return nil
+// Init initializes the tenant aware enforcer "rbac.TenantEnforcer" when both
+// RBAC and tenant RBAC are enabled. It shares the "casbin_rule" table with the
+// basic enforcer, the two models are exclusive and only one of them is initialized.
+//
+// The default admins "root" and "admin" are granted "super_admin" in tenant "*".
+func Init() (err error) {
+	if !config.App.Auth.RBACEnable || !config.App.Auth.RBACTenantEnable {
+		return nil
+	}
+
+	filename := filepath.Join(config.Tempdir(), "casbin_tenant_model.conf")
+	if err = os.WriteFile(filename, modelData, 0o600); err != nil {
+		return errors.Wrapf(err, "failed to write model file %s", filename)
+	}
+	if rbac.Adapter == nil {
+		if rbac.Adapter, err = gormadapter.NewAdapterByDBWithCustomTable(database.DB, new(modelauthz.CasbinRule)); err != nil {
+			return errors.Wrap(err, "failed to create casbin adapter")
+		}
+	}
+	// Policies created before conditions were supported have no "cond" field.
+	if err = database.DB.Model(new(modelauthz.CasbinRule)).
+		Where("ptype = ? AND (v5 = '' OR v5 IS NULL)", "p").
+		UpdateColumn("v5", consts.AUTHZ_CONDITION_NONE).Error; err != nil {
+		return errors.Wrap(err, "failed to migrate casbin policy conditions")
+	}
+	if rbac.TenantEnforcer, err = casbin.NewEnforcer(filename, rbac.Adapter); err != nil {
+		return errors.Wrap(err, "failed to create casbin tenant enforcer")
+	}
+
+	rbac.AddConditionFunctions(rbac.TenantEnforcer)
+	rbac.TenantEnforcer.SetLogger(logger.Casbin)
+	rbac.TenantEnforcer.EnableAutoSave(true)
+	rbac.TenantEnforcer.EnableAutoNotifyDispatcher(true)
+	rbac.TenantEnforcer.EnableAutoNotifyWatcher(true)
+	rbac.TenantEnforcer.EnableEnforce(true)
+
+	for _, user := range defaultSuperAdmins {
+		if _, err = rbac.TenantEnforcer.AddRoleForUserInDomain(user, consts.AUTHZ_ROLE_SUPER_ADMIN, consts.AUTHZ_TENANT_ALL); err != nil {
+			return errors.Wrapf(err, "failed to add default super admin grouping policy for %s", user)
+		}
+	}
+
+	return rbac.TenantEnforcer.LoadPolicy()
 }
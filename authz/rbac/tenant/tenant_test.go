@@ -0,0 +1,61 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	"github.com/casbin/casbin/v3/model"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModel(t *testing.T) {
+	m, err := model.NewModelFromString(string(modelData))
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	rbac.AddConditionFunctions(e)
+
+	_, err = e.AddRoleForUserInDomain("root", "super_admin", "*")
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("alice", "editor", "t1")
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("bob", "admin", "t2")
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("carol", "viewer", "t3")
+	require.NoError(t, err)
+	_, err = e.AddPolicy("t1", "editor", "/api/docs/{id}", "GET", "allow", "true")
+	require.NoError(t, err)
+	_, err = e.AddPolicy("*", "viewer", "/api/reports", "GET", "allow", "true")
+	require.NoError(t, err)
+	_, err = e.AddPolicy("t1", "editor", "/api/reports", "POST", "allow", "ipIn(r.ctx, '10.0.0.0/8')")
+	require.NoError(t, err)
+
+	tests := []struct {
+		tenant, sub, obj, act string
+		want                  bool
+	}{
+		{"t9", "root", "/api/anything", "DELETE", true},
+		{"t1", "alice", "/api/docs/1", "GET", true},
+		{"t1", "alice", "/api/docs/1", "DELETE", false},
+		{"t2", "alice", "/api/docs/1", "GET", false},
+		{"t2", "bob", "/api/anything", "POST", true},
+		{"t1", "bob", "/api/anything", "POST", false},
+		{"t3", "carol", "/api/reports", "GET", true},
+		{"t1", "carol", "/api/reports", "GET", false},
+		{"t1", "alice", "/api/reports", "POST", true},
+	}
+	ctx := rbac.NewContext(context.Background(), time.Now(), "10.1.2.3", nil)
+	for _, tt := range tests {
+		got, err := e.Enforce(tt.tenant, tt.sub, tt.obj, tt.act, ctx)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, "%s %s %s %s", tt.tenant, tt.sub, tt.obj, tt.act)
+	}
+
+	ctx = rbac.NewContext(context.Background(), time.Now(), "172.16.0.1", nil)
+	got, err := e.Enforce("t1", "alice", "/api/reports", "POST", ctx)
+	require.NoError(t, err)
+	require.False(t, got)
+}
@@ -10,9 +10,11 @@ import (
 	"github.com/forbearing/gst/authz/rbac/basic"
 	"github.com/forbearing/gst/authz/rbac/tenant"
 	"github.com/forbearing/gst/cache"
+	"github.com/forbearing/gst/cache/tiered"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/controller"
 	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/database/clickhouse"
 	"github.com/forbearing/gst/database/helper"
 	"github.com/forbearing/gst/database/mysql"
@@ -22,6 +24,7 @@ import (
 	"github.com/forbearing/gst/debug/gops"
 	debugpprof "github.com/forbearing/gst/debug/pprof"
 	"github.com/forbearing/gst/debug/statsviz"
+	"github.com/forbearing/gst/featureflag"
 	"github.com/forbearing/gst/grpc"
 	"github.com/forbearing/gst/logger/logrus"
 	pkgzap "github.com/forbearing/gst/logger/zap"
@@ -107,6 +110,11 @@ func Bootstrap() error {
 		feishu.Init,
 		ldap.Init,
 
+		// query cache backend and invalidations, it depends on redis and nats.
+		database.InitQueryCache,
+		// tiered cache invalidations of the peers, it depends on redis.
+		tiered.Init,
+
 		// Authorization and Authentication
 		basic.Init,
 		tenant.Init,
@@ -128,6 +136,8 @@ func Bootstrap() error {
 		module.Init,
 	)
 
+	RegisterCleanup(database.CloseQueryCache)
+	RegisterCleanup(tiered.Close)
 	RegisterCleanup(redis.Close)
 	RegisterCleanup(kafka.Close)
 	RegisterCleanup(etcd.Close)
@@ -139,6 +149,7 @@ func Bootstrap() error {
 	RegisterCleanup(rocketmq.Close)
 	RegisterCleanup(ldap.Close)
 	RegisterCleanup(controller.Clean)
+	RegisterCleanup(featureflag.Close)
 	RegisterCleanup(pkgzap.Clean)
 	RegisterCleanup(config.Clean)
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/forbearing/gst/election"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -37,6 +38,10 @@ func Cleanup() {
 }
 
 func runHandlers() {
+	// The handlers run concurrently, the leaders resign before the providers
+	// of the elections are closed so that another instance takes over immediately.
+	runSafe(election.Stop)
+
 	g, _ := errgroup.WithContext(context.Background())
 	for _, handler := range handlers {
 		g.Go(func() error { runSafe(handler); return nil })
@@ -0,0 +1,359 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/logger"
+	prommetrics "github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultNegativeTTL = 30 * time.Second
+	// loadingPruneEvery is the number of loads between two sweeps of the expired freshness/negative entries.
+	loadingPruneEvery = 1024
+)
+
+var _ types.Cache[any] = (*LoadingCache[any])(nil)
+
+// Loader loads the value of the key on a cache miss.
+// Return types.ErrEntryNotFound if the key does not exist, the "not found" is cached for the negative TTL.
+type Loader[T any] func(ctx context.Context, key string) (T, error)
+
+// LoadingCache is a read-through cache over any types.Cache[T] backend(lrue, ristretto, redis, dcache...).
+//
+//   - Get loads the missing key with the loader and stores it into the backend.
+//   - Concurrent misses of the same key share one loader call.
+//   - "not found" returned by the loader is cached for the negative TTL.
+//   - A hit within the refresh-ahead window before expiry returns the value and reloads it in background.
+//   - When the loader fails, the expired value is still served within the stale TTL.
+//
+// The freshness and the negative entries are kept in process, backends with global
+// expiration(lrue, bigcache) ignore the per-entry TTL and are only refreshed by the loader.
+type LoadingCache[T any] struct {
+	*loadingCache[T]
+	ctx context.Context
+}
+
+type loadingCache[T any] struct {
+	backend types.Cache[T]
+	loader  Loader[T]
+	opts    loadingOptions
+
+	flight     singleflight.Group
+	fresh      cmap.ConcurrentMap[string, time.Time] // key -> fresh until
+	negative   cmap.ConcurrentMap[string, time.Time] // key -> not found until
+	refreshing cmap.ConcurrentMap[string, struct{}]
+	loads      atomic.Int64
+
+	hits         atomic.Int64
+	misses       atomic.Int64
+	negativeHits atomic.Int64
+	loadSuccess  atomic.Int64
+	loadErrors   atomic.Int64
+	loadNanos    atomic.Int64
+	refreshes    atomic.Int64
+	staleServed  atomic.Int64
+}
+
+// LoadingStats is the statistics of a LoadingCache.
+type LoadingStats struct {
+	Hits         int64 // served from the backend
+	Misses       int64 // loaded from the loader
+	NegativeHits int64 // served from the negative cache
+	LoadSuccess  int64
+	LoadErrors   int64         // the "not found" is not counted as error
+	LoadTime     time.Duration // total time spent in the loader
+	Refreshes    int64         // refresh-ahead reloads
+	StaleServed  int64         // expired values served because the loader failed
+}
+
+// HitRatio returns the percentage of the requests served without calling the loader.
+func (s LoadingStats) HitRatio() float64 {
+	total := s.Hits + s.NegativeHits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.NegativeHits) * 100 / float64(total)
+}
+
+type loadingOptions struct {
+	name         string
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	refreshAhead time.Duration
+	staleTTL     time.Duration
+}
+
+// LoadingOption configures the LoadingCache.
+type LoadingOption func(*loadingOptions)
+
+// WithName sets the name that labels the cache hit/miss metrics, defaults to "loading".
+func WithName(name string) LoadingOption {
+	return func(o *loadingOptions) { o.name = name }
+}
+
+// WithTTL sets the TTL of the loaded values, defaults to config.App.Cache.Expiration.
+func WithTTL(ttl time.Duration) LoadingOption {
+	return func(o *loadingOptions) { o.ttl = ttl }
+}
+
+// WithNegativeTTL sets how long a "not found" is cached, defaults to 30s, negative value disables it.
+func WithNegativeTTL(ttl time.Duration) LoadingOption {
+	return func(o *loadingOptions) { o.negativeTTL = ttl }
+}
+
+// WithRefreshAhead reloads the value in background when it's hit within d before expiry.
+func WithRefreshAhead(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) { o.refreshAhead = d }
+}
+
+// WithStaleTTL keeps the value in the backend for d after expiry and serves it when the loader fails.
+func WithStaleTTL(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) { o.staleTTL = d }
+}
+
+// NewLoadingCache wraps the backend with the loader.
+//
+// Example:
+//
+//	users := cache.NewLoadingCache(cache.ExpirableCache[*model.User](), func(ctx context.Context, id string) (*model.User, error) {
+//		user := new(model.User)
+//		if err := database.Database[*model.User](ctx).Get(user, id); err != nil {
+//			return nil, err
+//		}
+//		if len(user.ID) == 0 {
+//			return nil, types.ErrEntryNotFound
+//		}
+//		return user, nil
+//	}, cache.WithTTL(5*time.Minute), cache.WithRefreshAhead(30*time.Second), cache.WithStaleTTL(time.Minute))
+//	user, err := users.WithContext(ctx).Get(id)
+func NewLoadingCache[T any](backend types.Cache[T], loader Loader[T], opts ...LoadingOption) *LoadingCache[T] {
+	o := loadingOptions{name: "loading", negativeTTL: defaultNegativeTTL}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if o.ttl <= 0 {
+		o.ttl = config.App.Cache.Expiration
+	}
+	if o.refreshAhead >= o.ttl {
+		o.refreshAhead = o.ttl / 2
+	}
+	return &LoadingCache[T]{
+		loadingCache: &loadingCache[T]{
+			backend:    backend,
+			loader:     loader,
+			opts:       o,
+			fresh:      cmap.New[time.Time](),
+			negative:   cmap.New[time.Time](),
+			refreshing: cmap.New[struct{}](),
+		},
+		ctx: context.Background(),
+	}
+}
+
+// WithContext returns a LoadingCache that passes ctx to the loader, the returned cache
+// shares the entries and stats with c. The backend is shared by all callers and is used
+// without context.
+func (c *LoadingCache[T]) WithContext(ctx context.Context) types.Cache[T] {
+	if ctx == nil {
+		return c
+	}
+	return &LoadingCache[T]{loadingCache: c.loadingCache, ctx: ctx}
+}
+
+// Get returns the cached value, loads it with the loader on a miss or after expiry.
+func (c *LoadingCache[T]) Get(key string) (T, error) {
+	var zero T
+	now := time.Now()
+
+	if until, ok := c.negative.Get(key); ok {
+		if now.Before(until) {
+			c.negativeHits.Add(1)
+			c.metric(true)
+			return zero, types.ErrEntryNotFound
+		}
+		c.negative.Remove(key)
+	}
+
+	val, err := c.backend.Get(key)
+	if err == nil {
+		until, known := c.fresh.Get(key)
+		// The value is set by others(e.g. the peers of a shared redis backend), trust the backend TTL.
+		if !known || now.Before(until) {
+			c.hits.Add(1)
+			c.metric(true)
+			if known && c.opts.refreshAhead > 0 && now.After(until.Add(-c.opts.refreshAhead)) {
+				c.refresh(key)
+			}
+			return val, nil
+		}
+
+		// expired, reload and fall back to the stale value.
+		c.misses.Add(1)
+		c.metric(false)
+		fresh, lerr := c.load(c.ctx, key)
+		if lerr == nil || errors.Is(lerr, types.ErrEntryNotFound) || c.opts.staleTTL <= 0 || now.After(until.Add(c.opts.staleTTL)) {
+			return fresh, lerr
+		}
+		c.staleServed.Add(1)
+		logger.Cache.Warnz("serve stale value", zap.String("cache", c.opts.name), zap.String("key", key), zap.Error(lerr))
+		return val, nil
+	}
+	if !errors.Is(err, types.ErrEntryNotFound) {
+		logger.Cache.Warnz("failed to get from backend", zap.String("cache", c.opts.name), zap.String("key", key), zap.Error(err))
+	}
+
+	c.misses.Add(1)
+	c.metric(false)
+	return c.load(c.ctx, key)
+}
+
+// Set stores the value for ttl, ttl <= 0 means the default TTL.
+func (c *LoadingCache[T]) Set(key string, value T, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.opts.ttl
+	}
+	return c.set(key, value, ttl)
+}
+
+// Peek returns the value in the backend without loading it.
+func (c *LoadingCache[T]) Peek(key string) (T, error) {
+	return c.backend.Peek(key)
+}
+
+// Delete removes the key from the backend, the negative cache and the freshness index.
+func (c *LoadingCache[T]) Delete(key string) error {
+	c.fresh.Remove(key)
+	c.negative.Remove(key)
+	return c.backend.Delete(key)
+}
+
+func (c *LoadingCache[T]) Exists(key string) bool {
+	return c.backend.Exists(key)
+}
+
+func (c *LoadingCache[T]) Len() int {
+	return c.backend.Len()
+}
+
+func (c *LoadingCache[T]) Clear() {
+	c.fresh.Clear()
+	c.negative.Clear()
+	c.backend.Clear()
+}
+
+// Stats returns the statistics of the cache.
+func (c *LoadingCache[T]) Stats() LoadingStats {
+	return LoadingStats{
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		LoadSuccess:  c.loadSuccess.Load(),
+		LoadErrors:   c.loadErrors.Load(),
+		LoadTime:     time.Duration(c.loadNanos.Load()),
+		Refreshes:    c.refreshes.Load(),
+		StaleServed:  c.staleServed.Load(),
+	}
+}
+
+// load calls the loader once for all concurrent callers of the key.
+// The shared load runs without the cancellation of ctx, otherwise a cancelled first
+// caller would fail all the callers waiting for the same key.
+func (c *loadingCache[T]) load(ctx context.Context, key string) (T, error) {
+	ctx = context.WithoutCancel(ctx)
+	val, err, _ := c.flight.Do(key, func() (any, error) {
+		begin := time.Now()
+		val, err := c.loader(ctx, key)
+		c.loadNanos.Add(int64(time.Since(begin)))
+		if c.loads.Add(1)%loadingPruneEvery == 0 {
+			c.prune(time.Now())
+		}
+
+		switch {
+		case err == nil:
+			c.loadSuccess.Add(1)
+			if e := c.set(key, val, c.opts.ttl); e != nil {
+				logger.Cache.Warnz("failed to set backend", zap.String("cache", c.opts.name), zap.String("key", key), zap.Error(e))
+			}
+			return val, nil
+		case errors.Is(err, types.ErrEntryNotFound):
+			c.loadSuccess.Add(1)
+			c.fresh.Remove(key)
+			if c.opts.negativeTTL > 0 {
+				c.negative.Set(key, time.Now().Add(c.opts.negativeTTL))
+			}
+			_ = c.backend.Delete(key)
+			return nil, types.ErrEntryNotFound
+		default:
+			c.loadErrors.Add(1)
+			return nil, err
+		}
+	})
+	var zero T
+	if err != nil {
+		return zero, err
+	}
+	// val is nil when T is an interface type and the loader returns a nil value.
+	if v, ok := val.(T); ok {
+		return v, nil
+	}
+	return zero, nil
+}
+
+func (c *loadingCache[T]) set(key string, val T, ttl time.Duration) error {
+	c.negative.Remove(key)
+	c.fresh.Set(key, time.Now().Add(ttl))
+	// keep the value in backend after expiry so that it can be served when the loader fails.
+	return c.backend.Set(key, val, ttl+c.opts.staleTTL)
+}
+
+// refresh reloads the key in background, at most one refresh per key is in flight.
+func (c *loadingCache[T]) refresh(key string) {
+	if !c.refreshing.SetIfAbsent(key, struct{}{}) {
+		return
+	}
+	c.refreshes.Add(1)
+	util.SafeGo(func() {
+		defer c.refreshing.Remove(key)
+		if _, err := c.load(context.Background(), key); err != nil && !errors.Is(err, types.ErrEntryNotFound) {
+			logger.Cache.Warnz("failed to refresh ahead", zap.String("cache", c.opts.name), zap.String("key", key), zap.Error(err))
+		}
+	}, "LoadingCache.refresh")
+}
+
+// prune removes the freshness entries whose stale window has passed and the expired negative entries.
+func (c *loadingCache[T]) prune(now time.Time) {
+	for item := range c.fresh.IterBuffered() {
+		if now.After(item.Val.Add(c.opts.staleTTL)) {
+			c.fresh.Remove(item.Key)
+		}
+	}
+	for item := range c.negative.IterBuffered() {
+		if now.After(item.Val) {
+			c.negative.Remove(item.Key)
+		}
+	}
+}
+
+func (c *loadingCache[T]) metric(hit bool) {
+	if hit {
+		if prommetrics.CacheHit != nil {
+			prommetrics.CacheHit.WithLabelValues("loading", c.opts.name).Inc()
+		}
+		return
+	}
+	if prommetrics.CacheMiss != nil {
+		prommetrics.CacheMiss.WithLabelValues("loading", c.opts.name).Inc()
+	}
+}
@@ -0,0 +1,148 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/cache"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingCacheCoalesce(t *testing.T) {
+	var calls atomic.Int64
+	backend := cache.ExpirableCache[string]()
+	backend.Clear()
+	lc := cache.NewLoadingCache(backend, func(_ context.Context, key string) (string, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return "value-" + key, nil
+	}, cache.WithTTL(time.Minute))
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			val, err := lc.Get("coalesce")
+			require.NoError(t, err)
+			require.Equal(t, "value-coalesce", val)
+		})
+	}
+	wg.Wait()
+	require.Equal(t, int64(1), calls.Load())
+
+	val, err := lc.Get("coalesce")
+	require.NoError(t, err)
+	require.Equal(t, "value-coalesce", val)
+	require.Equal(t, int64(1), calls.Load())
+	require.Equal(t, int64(1), lc.Stats().LoadSuccess)
+}
+
+func TestLoadingCacheNegative(t *testing.T) {
+	var calls atomic.Int64
+	backend := cache.ExpirableCache[string]()
+	backend.Clear()
+	lc := cache.NewLoadingCache(backend, func(context.Context, string) (string, error) {
+		calls.Add(1)
+		return "", types.ErrEntryNotFound
+	}, cache.WithNegativeTTL(100*time.Millisecond))
+
+	for range 3 {
+		_, err := lc.Get("negative")
+		require.ErrorIs(t, err, types.ErrEntryNotFound)
+	}
+	require.Equal(t, int64(1), calls.Load())
+	require.Equal(t, int64(2), lc.Stats().NegativeHits)
+
+	// The "not found" expires.
+	time.Sleep(150 * time.Millisecond)
+	_, err := lc.Get("negative")
+	require.ErrorIs(t, err, types.ErrEntryNotFound)
+	require.Equal(t, int64(2), calls.Load())
+
+	// Set overrides the "not found".
+	require.NoError(t, lc.Set("negative", "value", time.Minute))
+	val, err := lc.Get("negative")
+	require.NoError(t, err)
+	require.Equal(t, "value", val)
+}
+
+func TestLoadingCacheStaleIfError(t *testing.T) {
+	var fail atomic.Bool
+	backend := cache.ExpirableCache[string]()
+	backend.Clear()
+	lc := cache.NewLoadingCache(backend, func(context.Context, string) (string, error) {
+		if fail.Load() {
+			return "", errors.New("database is down")
+		}
+		return "value", nil
+	}, cache.WithTTL(50*time.Millisecond), cache.WithStaleTTL(time.Minute))
+
+	val, err := lc.Get("stale")
+	require.NoError(t, err)
+	require.Equal(t, "value", val)
+
+	time.Sleep(80 * time.Millisecond)
+	fail.Store(true)
+	val, err = lc.Get("stale")
+	require.NoError(t, err)
+	require.Equal(t, "value", val)
+
+	stats := lc.Stats()
+	require.Equal(t, int64(1), stats.StaleServed)
+	require.Equal(t, int64(1), stats.LoadErrors)
+}
+
+func TestLoadingCacheRefreshAhead(t *testing.T) {
+	var version atomic.Int64
+	backend := cache.ExpirableCache[int64]()
+	backend.Clear()
+	lc := cache.NewLoadingCache(backend, func(context.Context, string) (int64, error) {
+		return version.Add(1), nil
+	}, cache.WithTTL(200*time.Millisecond), cache.WithRefreshAhead(150*time.Millisecond))
+
+	val, err := lc.Get("refresh")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), val)
+
+	// Within the refresh-ahead window, the current value is returned and reloaded in background.
+	time.Sleep(80 * time.Millisecond)
+	val, err = lc.Get("refresh")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), val)
+	require.Eventually(t, func() bool {
+		val, err = lc.Peek("refresh")
+		return err == nil && val == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(1), lc.Stats().Refreshes)
+}
+
+func TestLoadingCacheDetachedLoad(t *testing.T) {
+	backend := cache.ExpirableCache[any]()
+	backend.Clear()
+	started := make(chan struct{})
+	lc := cache.NewLoadingCache(backend, func(ctx context.Context, key string) (any, error) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		// The loader returns a nil interface value.
+		return nil, ctx.Err()
+	}, cache.WithTTL(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		val, err := lc.WithContext(ctx).Get("detached")
+		require.NoError(t, err)
+		require.Nil(t, val)
+	})
+	<-started
+	// The waiter shares the load of the cancelled caller and is not failed by it.
+	cancel()
+	val, err := lc.Get("detached")
+	require.NoError(t, err)
+	require.Nil(t, val)
+	wg.Wait()
+}
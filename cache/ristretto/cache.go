@@ -55,6 +55,16 @@ func Cache[T any]() types.Cache[T] {
 	return val.(types.Cache[T])
 }
 
+// New creates a ristretto cache that is not shared with Cache[T] and the other callers,
+// Clear only removes its own entries.
+func New[T any]() (types.Cache[T], error) {
+	_ristretto, err := ristretto.NewCache(buildConf[T]())
+	if err != nil {
+		return nil, err
+	}
+	return &cache[T]{c: _ristretto, ctx: context.Background()}, nil
+}
+
 func (c *cache[T]) Set(key string, value T, ttl time.Duration) error {
 	if success := c.c.SetWithTTL(key, value, 1, ttl); !success {
 		return errors.New("cache rejected the set operation")
@@ -0,0 +1,373 @@
+// Package tiered is a two-tier cache, L1 is an in-process cache and L2 is usually redis.
+//
+// Get reads L1 then L2 and back-fills L1 with a shorter TTL. Set writes L2 and L1(write-through)
+// or L2 only(write-around). Every write and delete is broadcast to the peers through redis pub/sub
+// so that they drop the key from their L1.
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cache/ristretto"
+	"github.com/forbearing/gst/cache/tracing"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/logger"
+	prommetrics "github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	tierL1 = "l1"
+	tierL2 = "l2"
+)
+
+var (
+	// registry is the tiered caches by name, the peer invalidations are dispatched by it.
+	registry = cmap.New[invalidator]()
+	mu       sync.Mutex
+	node     = util.UUID()
+
+	subMu     sync.Mutex
+	subCancel context.CancelFunc
+)
+
+// invalidator is the part of the tiered cache the invalidation handler and Stats need.
+type invalidator interface {
+	invalidate(keys []string, all bool)
+	stats() TierStats
+}
+
+// invalidation is the message broadcast to the peers.
+type invalidation struct {
+	Node  string   `json:"node"`
+	Cache string   `json:"cache"`
+	Keys  []string `json:"keys,omitempty"`
+	Clear bool     `json:"clear,omitempty"`
+}
+
+// TierStats is the per-tier statistics of a tiered cache.
+type TierStats struct {
+	L1Hits     int64
+	L1Misses   int64
+	L1HitRatio float64
+	L2Hits     int64
+	L2Misses   int64
+	L2HitRatio float64
+}
+
+// Init subscribes the invalidations of the peers, it must be called after redis is initialized.
+// Without it the tiered caches still work but the L1 of the peers are only refreshed by expiry.
+func Init() error {
+	if !config.App.Redis.Enable {
+		return nil
+	}
+	subMu.Lock()
+	defer subMu.Unlock()
+	if subCancel != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := redis.Subscribe(ctx, config.App.Cache.TieredChannel, handleInvalidation); err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to subscribe tiered cache invalidations")
+	}
+	subCancel = cancel
+	return nil
+}
+
+// Close stops the subscription of the peer invalidations.
+func Close() {
+	subMu.Lock()
+	defer subMu.Unlock()
+	if subCancel != nil {
+		subCancel()
+		subCancel = nil
+	}
+}
+
+// Stats returns the per-tier statistics of all tiered caches by name.
+func Stats() map[string]TierStats {
+	stats := make(map[string]TierStats, registry.Count())
+	for item := range registry.IterBuffered() {
+		stats[item.Key] = item.Val.stats()
+	}
+	return stats
+}
+
+// Option configures the tiered cache.
+type Option func(*options)
+
+type options struct {
+	l1TTL     time.Duration
+	writeMode config.TieredWriteMode
+}
+
+// WithL1TTL sets the max TTL of the L1 entries, defaults to config.App.Cache.TieredL1TTL.
+func WithL1TTL(ttl time.Duration) Option {
+	return func(o *options) { o.l1TTL = ttl }
+}
+
+// WithWriteMode sets the write mode, defaults to config.App.Cache.TieredWriteMode.
+func WithWriteMode(mode config.TieredWriteMode) Option {
+	return func(o *options) { o.writeMode = mode }
+}
+
+// Cache returns the tiered cache of type T, L1 is a ristretto cache owned by the tiered cache
+// and L2 is the redis cache.
+func Cache[T any]() types.Cache[T] {
+	typ := reflect.TypeFor[T]()
+	name := typ.PkgPath() + "|" + typ.String()
+	if val, exists := registry.Get(name); exists {
+		return tracing.NewWrapper(val.(*cache[T]), "tiered") //nolint:errcheck
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if val, exists := registry.Get(name); exists {
+		return tracing.NewWrapper(val.(*cache[T]), "tiered") //nolint:errcheck
+	}
+	// L1 must not be the shared ristretto.Cache[T], the peer "clear" would wipe the entries
+	// of the other users and their keys would collide.
+	l1, err := ristretto.New[T]()
+	if err != nil {
+		logger.Cache.Errorz("failed to create tiered cache l1, fall back to redis only", zap.String("cache", name), zap.Error(err))
+		return redis.Cache[T]()
+	}
+	c := newCache(name, l1, redis.Cache[T]())
+	registry.Set(name, c)
+	return tracing.NewWrapper(c, "tiered")
+}
+
+// New creates a tiered cache over the given tiers, the name identifies the cache across
+// the peers and labels the metrics, it must be unique in the process.
+func New[T any](name string, l1, l2 types.Cache[T], opts ...Option) (types.Cache[T], error) {
+	if len(name) == 0 {
+		return nil, errors.New("tiered cache name is empty")
+	}
+	if l1 == nil || l2 == nil {
+		return nil, errors.New("tiered cache tier is nil")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if registry.Has(name) {
+		return nil, errors.Newf("tiered cache %q already exists", name)
+	}
+	c := newCache(name, l1, l2, opts...)
+	registry.Set(name, c)
+	return tracing.NewWrapper(c, "tiered"), nil
+}
+
+type cache[T any] struct {
+	*state[T]
+	ctx context.Context
+}
+
+type state[T any] struct {
+	name string
+	l1   types.Cache[T]
+	l2   types.Cache[T]
+	opts options
+
+	l1Hits   atomic.Int64
+	l1Misses atomic.Int64
+	l2Hits   atomic.Int64
+	l2Misses atomic.Int64
+}
+
+func newCache[T any](name string, l1, l2 types.Cache[T], opts ...Option) *cache[T] {
+	o := options{l1TTL: config.App.Cache.TieredL1TTL, writeMode: config.App.Cache.TieredWriteMode}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if o.l1TTL <= 0 {
+		o.l1TTL = time.Minute
+	}
+	if o.writeMode != config.TieredWriteAround {
+		o.writeMode = config.TieredWriteThrough
+	}
+	return &cache[T]{state: &state[T]{name: name, l1: l1, l2: l2, opts: o}, ctx: context.Background()}
+}
+
+func (c *cache[T]) Get(key string) (T, error) {
+	val, err := c.l1.Get(key)
+	if err == nil {
+		c.record(tierL1, true)
+		return val, nil
+	}
+	c.record(tierL1, false)
+
+	if val, err = c.l2.Get(key); err != nil {
+		c.record(tierL2, false)
+		var zero T
+		return zero, err
+	}
+	c.record(tierL2, true)
+	if e := c.l1.Set(key, val, c.opts.l1TTL); e != nil {
+		logger.Cache.Warnz("failed to back-fill l1", zap.String("cache", c.name), zap.String("key", key), zap.Error(e))
+	}
+	return val, nil
+}
+
+// Peek looks up L1 then L2 without back-filling L1.
+func (c *cache[T]) Peek(key string) (T, error) {
+	if val, err := c.l1.Peek(key); err == nil {
+		return val, nil
+	}
+	return c.l2.Peek(key)
+}
+
+// Set writes L2 first, then L1 in write-through mode or drops the key from L1 in write-around mode.
+// The peers drop the key from their L1 in both modes.
+func (c *cache[T]) Set(key string, value T, ttl time.Duration) error {
+	if err := c.l2.Set(key, value, ttl); err != nil {
+		return err
+	}
+	defer c.broadcast([]string{key}, false)
+
+	if c.opts.writeMode == config.TieredWriteAround {
+		return c.l1.Delete(key)
+	}
+	l1TTL := c.opts.l1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	return c.l1.Set(key, value, l1TTL)
+}
+
+func (c *cache[T]) Delete(key string) error {
+	defer c.broadcast([]string{key}, false)
+	if err := c.l1.Delete(key); err != nil && !errors.Is(err, types.ErrEntryNotFound) {
+		logger.Cache.Warnz("failed to delete from l1", zap.String("cache", c.name), zap.String("key", key), zap.Error(err))
+	}
+	return c.l2.Delete(key)
+}
+
+func (c *cache[T]) Exists(key string) bool {
+	return c.l1.Exists(key) || c.l2.Exists(key)
+}
+
+// Len returns the length of L2, L1 only holds a subset of it.
+func (c *cache[T]) Len() int {
+	return c.l2.Len()
+}
+
+func (c *cache[T]) Clear() {
+	c.l1.Clear()
+	c.l2.Clear()
+	c.broadcast(nil, true)
+}
+
+// WithContext returns a cache that annotates the spans of ctx with the tier hits,
+// the returned cache shares the tiers and stats with c.
+func (c *cache[T]) WithContext(ctx context.Context) types.Cache[T] {
+	if ctx == nil {
+		return c
+	}
+	return &cache[T]{state: c.state, ctx: ctx}
+}
+
+func (c *cache[T]) record(tier string, hit bool) {
+	var ratio float64
+	switch tier {
+	case tierL1:
+		if hit {
+			c.l1Hits.Add(1)
+		} else {
+			c.l1Misses.Add(1)
+		}
+		ratio = hitRatio(c.l1Hits.Load(), c.l1Misses.Load())
+	case tierL2:
+		if hit {
+			c.l2Hits.Add(1)
+		} else {
+			c.l2Misses.Add(1)
+		}
+		ratio = hitRatio(c.l2Hits.Load(), c.l2Misses.Load())
+	}
+
+	tracing.RecordTier(c.ctx, tier, hit, ratio)
+	if hit {
+		if prommetrics.CacheHit != nil {
+			prommetrics.CacheHit.WithLabelValues("tiered_"+tier, c.name).Inc()
+		}
+	} else if prommetrics.CacheMiss != nil {
+		prommetrics.CacheMiss.WithLabelValues("tiered_"+tier, c.name).Inc()
+	}
+	if prommetrics.CacheHitRatio != nil {
+		prommetrics.CacheHitRatio.WithLabelValues(c.name, tier).Set(ratio)
+	}
+}
+
+// broadcast publishes the invalidation to the peers, it's a no-op when redis is disabled.
+func (c *cache[T]) broadcast(keys []string, all bool) {
+	if !config.App.Redis.Enable {
+		return
+	}
+	payload, err := json.Marshal(invalidation{Node: node, Cache: c.name, Keys: keys, Clear: all})
+	if err != nil {
+		logger.Cache.Errorz("failed to marshal tiered cache invalidation", zap.String("cache", c.name), zap.Error(err))
+		return
+	}
+	if err = redis.Publish(config.App.Cache.TieredChannel, payload); err != nil {
+		logger.Cache.Errorz("failed to publish tiered cache invalidation", zap.String("cache", c.name), zap.Error(err))
+	}
+}
+
+// invalidate drops the keys or all entries from L1, L2 is shared and has been updated by the peer.
+func (s *state[T]) invalidate(keys []string, all bool) {
+	if all {
+		s.l1.Clear()
+		return
+	}
+	for _, key := range keys {
+		_ = s.l1.Delete(key)
+	}
+}
+
+func (s *state[T]) stats() TierStats {
+	l1Hits, l1Misses := s.l1Hits.Load(), s.l1Misses.Load()
+	l2Hits, l2Misses := s.l2Hits.Load(), s.l2Misses.Load()
+	return TierStats{
+		L1Hits:     l1Hits,
+		L1Misses:   l1Misses,
+		L1HitRatio: hitRatio(l1Hits, l1Misses),
+		L2Hits:     l2Hits,
+		L2Misses:   l2Misses,
+		L2HitRatio: hitRatio(l2Hits, l2Misses),
+	}
+}
+
+// handleInvalidation applies the invalidation of the peers, the own ones are ignored.
+func handleInvalidation(payload []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		logger.Cache.Warnz("invalid tiered cache invalidation", zap.ByteString("payload", payload), zap.Error(err))
+		return
+	}
+	if msg.Node == node {
+		return
+	}
+	if c, ok := registry.Get(msg.Cache); ok {
+		c.invalidate(msg.Keys, msg.Clear)
+	}
+}
+
+func hitRatio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) * 100 / float64(hits+misses)
+}
@@ -0,0 +1,183 @@
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/cache/ristretto"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	if err := config.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// mapCache is a minimal types.Cache that records the TTL of the entries.
+type mapCache struct {
+	mu   sync.Mutex
+	data map[string]string
+	ttls map[string]time.Duration
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{data: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (m *mapCache) Set(key string, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key], m.ttls[key] = value, ttl
+	return nil
+}
+
+func (m *mapCache) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	val, ok := m.data[key]
+	if !ok {
+		return "", types.ErrEntryNotFound
+	}
+	return val, nil
+}
+
+func (m *mapCache) Peek(key string) (string, error) { return m.Get(key) }
+
+func (m *mapCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	delete(m.ttls, key)
+	return nil
+}
+
+func (m *mapCache) Exists(key string) bool {
+	_, err := m.Get(key)
+	return err == nil
+}
+
+func (m *mapCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.data)
+}
+
+func (m *mapCache) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.data)
+	clear(m.ttls)
+}
+
+func (m *mapCache) WithContext(context.Context) types.Cache[string] { return m }
+
+func newTestCache(t *testing.T, name string, l1, l2 types.Cache[string], opts ...Option) types.Cache[string] {
+	c, err := New(name, l1, l2, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { registry.Remove(name) })
+	return c
+}
+
+func TestTieredReadThrough(t *testing.T) {
+	l1, l2 := newMapCache(), newMapCache()
+	c := newTestCache(t, "read-through", l1, l2, WithL1TTL(time.Second))
+	_, err := New[string]("read-through", l1, l2)
+	require.Error(t, err)
+
+	// L2 hit back-fills L1 with the L1 TTL.
+	require.NoError(t, l2.Set("k", "v", time.Hour))
+	val, err := c.Get("k")
+	require.NoError(t, err)
+	require.Equal(t, "v", val)
+	require.Equal(t, time.Second, l1.ttls["k"])
+
+	// L1 hit.
+	val, err = c.Get("k")
+	require.NoError(t, err)
+	require.Equal(t, "v", val)
+
+	_, err = c.Get("missing")
+	require.ErrorIs(t, err, types.ErrEntryNotFound)
+
+	stats := Stats()["read-through"]
+	require.Equal(t, TierStats{L1Hits: 1, L1Misses: 2, L1HitRatio: 100.0 / 3, L2Hits: 1, L2Misses: 1, L2HitRatio: 50}, stats)
+}
+
+func TestTieredWriteMode(t *testing.T) {
+	l1, l2 := newMapCache(), newMapCache()
+	through := newTestCache(t, "write-through", l1, l2, WithL1TTL(time.Minute))
+
+	// The L1 TTL never exceeds the TTL of the value.
+	require.NoError(t, through.Set("short", "v", time.Second))
+	require.Equal(t, time.Second, l1.ttls["short"])
+	require.Equal(t, time.Second, l2.ttls["short"])
+	require.NoError(t, through.Set("long", "v", time.Hour))
+	require.Equal(t, time.Minute, l1.ttls["long"])
+
+	l1, l2 = newMapCache(), newMapCache()
+	around := newTestCache(t, "write-around", l1, l2, WithWriteMode(config.TieredWriteAround))
+	require.NoError(t, l1.Set("k", "old", time.Minute))
+	require.NoError(t, around.Set("k", "new", time.Hour))
+	require.False(t, l1.Exists("k"))
+	val, err := around.Get("k")
+	require.NoError(t, err)
+	require.Equal(t, "new", val)
+
+	require.NoError(t, around.Delete("k"))
+	require.False(t, l1.Exists("k"))
+	require.False(t, l2.Exists("k"))
+}
+
+func TestTieredPeerInvalidation(t *testing.T) {
+	l1, l2 := newMapCache(), newMapCache()
+	newTestCache(t, "peer", l1, l2)
+	require.NoError(t, l1.Set("a", "1", time.Minute))
+	require.NoError(t, l1.Set("b", "2", time.Minute))
+
+	// The own invalidations are ignored.
+	payload, err := json.Marshal(invalidation{Node: node, Cache: "peer", Keys: []string{"a"}})
+	require.NoError(t, err)
+	handleInvalidation(payload)
+	require.True(t, l1.Exists("a"))
+
+	payload, err = json.Marshal(invalidation{Node: "other", Cache: "peer", Keys: []string{"a"}})
+	require.NoError(t, err)
+	handleInvalidation(payload)
+	require.False(t, l1.Exists("a"))
+	require.True(t, l1.Exists("b"))
+
+	payload, err = json.Marshal(invalidation{Node: "other", Cache: "peer", Clear: true})
+	require.NoError(t, err)
+	handleInvalidation(payload)
+	require.Zero(t, l1.Len())
+}
+
+type ownL1Item struct{ Value string }
+
+func TestTieredOwnL1(t *testing.T) {
+	shared := ristretto.Cache[ownL1Item]()
+	require.NoError(t, shared.Set("k", ownL1Item{Value: "shared"}, time.Minute))
+
+	Cache[ownL1Item]()
+	name := "github.com/forbearing/gst/cache/tiered|tiered.ownL1Item"
+	t.Cleanup(func() { registry.Remove(name) })
+	val, ok := registry.Get(name)
+	require.True(t, ok)
+	c := val.(*cache[ownL1Item]) //nolint:errcheck
+	_, err := c.l1.Get("k")
+	require.ErrorIs(t, err, types.ErrEntryNotFound)
+
+	// The peer "clear" only wipes the own L1.
+	require.NoError(t, c.l1.Set("own", ownL1Item{Value: "own"}, time.Minute))
+	payload, err := json.Marshal(invalidation{Node: "other", Cache: name, Clear: true})
+	require.NoError(t, err)
+	handleInvalidation(payload)
+	require.False(t, c.l1.Exists("own"))
+	require.True(t, shared.Exists("k"))
+}
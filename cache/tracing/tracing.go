@@ -290,3 +290,19 @@ func (tw *Wrapper[T]) startSpan(operationName string) (context.Context, trace.Sp
 	ctx, span := tracer.Start(tw.ctx, operationName)
 	return ctx, span
 }
+
+// RecordTier annotates the current span of ctx with the tier that was looked up,
+// whether it hit and the hit ratio of the tier, it's used by the multi-tier caches.
+func RecordTier(ctx context.Context, tier string, hit bool, hitRatio float64) {
+	if ctx == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.Bool("cache."+tier+".hit", hit),
+		attribute.Float64("cache."+tier+".hit_ratio", hitRatio),
+	)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database/mysql"
+	"github.com/forbearing/gst/database/postgres"
+	"github.com/forbearing/gst/database/sqlite"
+	"github.com/forbearing/gst/database/sqlserver"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditConfigFile string
+	auditChainKey   string
+	auditPublicKey  string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Operation log audit tools",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the tamper-evident operation log hash chains",
+	Long: `Verify the tamper-evident operation log hash chains against the database of the config file,
+and report the first broken link of every chain.
+
+The checkpoint signatures are verified with --public-key, the base64 encoded ed25519 public key
+of logmgmt.Config.SigningKey. The command exits with non-zero status if any chain is broken.`,
+	SilenceUsage: true,
+	RunE:         runAuditVerify,
+}
+
+func init() {
+	auditVerifyCmd.Flags().StringVarP(&auditConfigFile, "config", "c", "", "Config file (default: the config file of the application)")
+	auditVerifyCmd.Flags().StringVar(&auditChainKey, "chain", "", "Chain to verify (default: all chains)")
+	auditVerifyCmd.Flags().StringVar(&auditPublicKey, "public-key", "", "Base64 encoded ed25519 public key verifying the checkpoints")
+
+	auditCmd.AddCommand(auditVerifyCmd)
+}
+
+func runAuditVerify(cmd *cobra.Command, args []string) error {
+	var pub ed25519.PublicKey
+	if len(auditPublicKey) > 0 {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auditPublicKey))
+		if err != nil || len(data) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid ed25519 public key")
+		}
+		pub = ed25519.PublicKey(data)
+	} else {
+		fmt.Fprintln(os.Stderr, "Warning: --public-key is not set, checkpoint signatures are not verified")
+	}
+
+	if len(auditConfigFile) > 0 {
+		config.SetConfigFile(auditConfigFile)
+	}
+	if err := config.Init(); err != nil {
+		return fmt.Errorf("failed to init config: %w", err)
+	}
+	defer config.Clean()
+	for _, fn := range []func() error{pkgzap.Init, sqlite.Init, postgres.Init, mysql.Init, sqlserver.Init} {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	var results []*modellogmgmt.ChainResult
+	if len(auditChainKey) > 0 {
+		res, err := auditmanager.Verify(auditChainKey, pub)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	} else {
+		var err error
+		if results, err = auditmanager.VerifyAll(pub); err != nil {
+			return err
+		}
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No operation log chain found")
+		return nil
+	}
+	broken := 0
+	for _, res := range results {
+		if res.Valid {
+			fmt.Printf("%s %s: %d records, head seq %d, %d checkpoints\n", green("✔"), res.ChainKey, res.Records, res.HeadSeq, res.Checkpoints)
+			continue
+		}
+		broken++
+		fmt.Printf("%s %s: broken at seq %d", red("✘"), res.ChainKey, res.Broken.Seq)
+		if len(res.Broken.RecordID) > 0 {
+			fmt.Printf(" (record %s)", res.Broken.RecordID)
+		}
+		fmt.Printf(": %s\n", res.Broken.Reason)
+	}
+	if broken > 0 {
+		return fmt.Errorf("%d of %d operation log chains are broken", broken, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/database/mysql"
+	"github.com/forbearing/gst/database/postgres"
+	"github.com/forbearing/gst/database/sqlite"
+	"github.com/forbearing/gst/database/sqlserver"
+	"github.com/forbearing/gst/featureflag"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	featureflagFile       string
+	featureflagConfigFile string
+	featureflagOutput     string
+	featureflagPackage    string
+)
+
+var featureflagCmd = &cobra.Command{
+	Use:   "featureflag",
+	Short: "Generate typed constants of the feature flags",
+	Long: `Generate a Go file declaring a constant for every feature flag, so that the flags
+are referenced by constants instead of string literals:
+
+  if flags.NewCheckout.Enabled(ctx) { ... }
+
+The flags are read from --file, a YAML or JSON list of flags in the format of the
+/api/featureflags API, or from the database of the config file otherwise.`,
+	Example: `  gg featureflag -f featureflags.yaml -o internal/flags/flags.go
+  gg featureflag -c config.ini -o internal/flags/flags.go`,
+	SilenceUsage: true,
+	RunE:         runFeatureflag,
+}
+
+func init() {
+	featureflagCmd.Flags().StringVarP(&featureflagFile, "file", "f", "", "YAML or JSON file of the flags")
+	featureflagCmd.Flags().StringVarP(&featureflagConfigFile, "config", "c", "", "Config file (default: the config file of the application)")
+	featureflagCmd.Flags().StringVarP(&featureflagOutput, "output", "o", "flags/flags.go", "Output file")
+	featureflagCmd.Flags().StringVarP(&featureflagPackage, "package", "p", "", "Package name (default: the directory name of the output file)")
+}
+
+func runFeatureflag(cmd *cobra.Command, args []string) error {
+	var (
+		flags []*featureflag.Flag
+		err   error
+	)
+	if len(featureflagFile) > 0 {
+		flags, err = readFeatureflagFile(featureflagFile)
+	} else {
+		flags, err = readFeatureflagDatabase()
+	}
+	if err != nil {
+		return err
+	}
+
+	pkg := featureflagPackage
+	if len(pkg) == 0 {
+		abs, err := filepath.Abs(featureflagOutput)
+		if err != nil {
+			return err
+		}
+		pkg = strings.ReplaceAll(filepath.Base(filepath.Dir(abs)), "-", "_")
+	}
+	src, err := featureflag.Generate(pkg, flags...)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(featureflagOutput), 0o755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(featureflagOutput, src, 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("%s generated %d feature flags into %s\n", green("✔"), len(flags), featureflagOutput)
+	return nil
+}
+
+// readFeatureflagFile reads the flags from a YAML or JSON file, JSON is a subset of YAML.
+func readFeatureflagFile(filename string) ([]*featureflag.Flag, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The flags are decoded by their json tags.
+	var raw any
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	var flags []*featureflag.Flag
+	if err = json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("failed to parse %s, it must be a list of flags: %w", filename, err)
+	}
+	return flags, nil
+}
+
+func readFeatureflagDatabase() ([]*featureflag.Flag, error) {
+	if len(featureflagConfigFile) > 0 {
+		config.SetConfigFile(featureflagConfigFile)
+	}
+	if err := config.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init config: %w", err)
+	}
+	defer config.Clean()
+	for _, fn := range []func() error{pkgzap.Init, sqlite.Init, postgres.Init, mysql.Init, sqlserver.Init} {
+		if err := fn(); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := make([]*featureflag.Flag, 0)
+	if err := database.Database[*featureflag.Flag](nil).List(&flags); err != nil {
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+	return flags, nil
+}
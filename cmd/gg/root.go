@@ -38,5 +38,7 @@ func init() {
 		releaseCmd,
 		configCmd,
 		migrateCmd,
+		auditCmd,
+		featureflagCmd,
 	)
 }
@@ -11,6 +11,7 @@ const (
 	AUTH_ACCESS_TOKEN_EXPIRE_DURATION  = "AUTH_ACCESS_TOKEN_EXPIRE_DURATION"  //nolint:staticcheck,gosec
 	AUTH_REFRESH_TOKEN_EXPIRE_DURATION = "AUTH_REFRESH_TOKEN_EXPIRE_DURATION" //nolint:staticcheck,gosec
 	AUTH_RBAC_ENABLE                   = "AUTH_RBAC_ENABLE"                   //nolint:staticcheck
+	AUTH_RBAC_TENANT_ENABLE            = "AUTH_RBAC_TENANT_ENABLE"            //nolint:staticcheck
 )
 
 type Auth struct {
@@ -23,6 +24,8 @@ type Auth struct {
 	RefreshTokenExpireDuration time.Duration `json:"refresh_token_expire_duration" mapstructure:"refresh_token_expire_duration" ini:"refresh_token_expire_duration" yaml:"refresh_token_expire_duration"`
 
 	RBACEnable bool `json:"rbac_enable" mapstructure:"rbac_enable" ini:"rbac_enable" yaml:"rbac_enable"`
+	// RBACTenantEnable switches RBAC to the tenant(domain) aware model, it requires RBACEnable.
+	RBACTenantEnable bool `json:"rbac_tenant_enable" mapstructure:"rbac_tenant_enable" ini:"rbac_tenant_enable" yaml:"rbac_tenant_enable"`
 }
 
 func (*Auth) setDefault() {
@@ -35,4 +38,5 @@ func (*Auth) setDefault() {
 	cv.SetDefault("auth.refresh_token_expire_duration", "168h")
 
 	cv.SetDefault("auth.rbac_enable", false)
+	cv.SetDefault("auth.rbac_tenant_enable", false)
 }
@@ -11,6 +11,41 @@ const (
 	CacheGolangLRU CacheType = "golang-lru"
 )
 
+// QueryCacheBackend is where database[M] stores the cached query results.
+type QueryCacheBackend string
+
+const (
+	QueryCacheLocal QueryCacheBackend = "local" // process-local cache, default
+	QueryCacheRedis QueryCacheBackend = "redis" // shared by all replicas, requires redis enabled
+)
+
+// QueryCacheBroadcast is how the query cache invalidations are broadcast to the other replicas.
+type QueryCacheBroadcast string
+
+const (
+	QueryCacheBroadcastNone  QueryCacheBroadcast = "none" // default
+	QueryCacheBroadcastRedis QueryCacheBroadcast = "redis"
+	QueryCacheBroadcastNats  QueryCacheBroadcast = "nats"
+)
+
+// TieredWriteMode is how cache/tiered writes the value to the tiers.
+type TieredWriteMode string
+
+const (
+	TieredWriteThrough TieredWriteMode = "through" // write L2 and L1, default
+	TieredWriteAround  TieredWriteMode = "around"  // write L2 only, L1 is filled by the next read
+)
+
+// DistributedCacheBus is the event bus that dcache uses to propagate the set/delete events across nodes.
+type DistributedCacheBus string
+
+const (
+	DistributedCacheBusKafka    DistributedCacheBus = "kafka" // default, brokers from kafka.brokers
+	DistributedCacheBusRedis    DistributedCacheBus = "redis" // redis pub/sub
+	DistributedCacheBusNats     DistributedCacheBus = "nats"  // requires nats enabled
+	DistributedCacheBusLoopback DistributedCacheBus = "loopback"
+)
+
 const (
 	CACHE_TYPE         = "CACHE_TYPE"         //nolint:staticcheck
 	CACHE_SIZE_MB      = "CACHE_SIZE_MB"      //nolint:staticcheck
@@ -20,6 +55,16 @@ const (
 	CACHE_CLEAN_WINDOW = "CACHE_CLEAN_WINDOW" //nolint:staticcheck
 	CACHE_EXPIRATION   = "CACHE_EXPIRATION"   //nolint:staticcheck
 	CACHE_CAPACITY     = "CACHE_CAPACITY"     //nolint:staticcheck
+
+	CACHE_QUERY_BACKEND   = "CACHE_QUERY_BACKEND"   //nolint:staticcheck
+	CACHE_QUERY_BROADCAST = "CACHE_QUERY_BROADCAST" //nolint:staticcheck
+	CACHE_QUERY_CHANNEL   = "CACHE_QUERY_CHANNEL"   //nolint:staticcheck
+
+	CACHE_DISTRIBUTED_BUS = "CACHE_DISTRIBUTED_BUS" //nolint:staticcheck
+
+	CACHE_TIERED_L1_TTL     = "CACHE_TIERED_L1_TTL"     //nolint:staticcheck
+	CACHE_TIERED_WRITE_MODE = "CACHE_TIERED_WRITE_MODE" //nolint:staticcheck
+	CACHE_TIERED_CHANNEL    = "CACHE_TIERED_CHANNEL"    //nolint:staticcheck
 )
 
 type Cache struct {
@@ -31,6 +76,19 @@ type Cache struct {
 	CleanWindow time.Duration `json:"clean_window" mapstructure:"clean_window" ini:"clean_window" yaml:"clean_window"` // 清理过期数据的周期
 	Expiration  time.Duration `json:"expiration" mapstructure:"expiration" ini:"expiration" yaml:"expiration"`
 	Capacity    int           `json:"capacity" mapstructure:"capacity" ini:"capacity" yaml:"capacity"`
+
+	// Query cache of database[M].WithCache, the entries expire after Expiration.
+	QueryBackend   QueryCacheBackend   `json:"query_backend" mapstructure:"query_backend" ini:"query_backend" yaml:"query_backend"`
+	QueryBroadcast QueryCacheBroadcast `json:"query_broadcast" mapstructure:"query_broadcast" ini:"query_broadcast" yaml:"query_broadcast"`
+	QueryChannel   string              `json:"query_channel" mapstructure:"query_channel" ini:"query_channel" yaml:"query_channel"` // redis channel or nats subject of the invalidations
+
+	// DistributedBus is the event bus of dcache.NewDistributedCache and dcache.Init.
+	DistributedBus DistributedCacheBus `json:"distributed_bus" mapstructure:"distributed_bus" ini:"distributed_bus" yaml:"distributed_bus"`
+
+	// Two-tier cache of cache/tiered, L1 is in process and L2 is redis.
+	TieredL1TTL     time.Duration   `json:"tiered_l1_ttl" mapstructure:"tiered_l1_ttl" ini:"tiered_l1_ttl" yaml:"tiered_l1_ttl"` // max TTL of the L1 entries
+	TieredWriteMode TieredWriteMode `json:"tiered_write_mode" mapstructure:"tiered_write_mode" ini:"tiered_write_mode" yaml:"tiered_write_mode"`
+	TieredChannel   string          `json:"tiered_channel" mapstructure:"tiered_channel" ini:"tiered_channel" yaml:"tiered_channel"` // redis channel of the L1 invalidations
 }
 
 func (*Cache) setDefault() {
@@ -42,4 +100,11 @@ func (*Cache) setDefault() {
 	cv.SetDefault("cache.clean_window", 5*time.Minute)
 	cv.SetDefault("cache.expiration", 10*time.Minute)
 	cv.SetDefault("cache.capacity", 100000) // 100,000
+	cv.SetDefault("cache.query_backend", QueryCacheLocal)
+	cv.SetDefault("cache.query_broadcast", QueryCacheBroadcastNone)
+	cv.SetDefault("cache.query_channel", "gst.query_cache.invalidate")
+	cv.SetDefault("cache.distributed_bus", DistributedCacheBusKafka)
+	cv.SetDefault("cache.tiered_l1_ttl", time.Minute)
+	cv.SetDefault("cache.tiered_write_mode", TieredWriteThrough)
+	cv.SetDefault("cache.tiered_channel", "gst.cache.tiered.invalidate")
 }
@@ -33,6 +33,14 @@ const (
 	SERVER_CIRCUIT_BREAKER_MIN_REQUESTS = "SERVER_CIRCUIT_BREAKER_MIN_REQUESTS" //nolint:staticcheck
 	SERVER_CIRCUIT_BREAKER_ENABLE       = "SERVER_CIRCUIT_BREAKER_ENABLE"       //nolint:staticcheck
 
+	SERVER_LOAD_SHEDDING_ENABLE             = "SERVER_LOAD_SHEDDING_ENABLE"             //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_ALGORITHM          = "SERVER_LOAD_SHEDDING_ALGORITHM"          //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_INITIAL_LIMIT      = "SERVER_LOAD_SHEDDING_INITIAL_LIMIT"      //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_MIN_LIMIT          = "SERVER_LOAD_SHEDDING_MIN_LIMIT"          //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_MAX_LIMIT          = "SERVER_LOAD_SHEDDING_MAX_LIMIT"          //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_LATENCY            = "SERVER_LOAD_SHEDDING_LATENCY"            //nolint:staticcheck
+	SERVER_LOAD_SHEDDING_LOW_PRIORITY_RATIO = "SERVER_LOAD_SHEDDING_LOW_PRIORITY_RATIO" //nolint:staticcheck
+
 	SERVER_CIRCULAR_BUFFER_SIZE_OPERATION_LOG = "SERVER_CIRCULAR_BUFFER_SIZE_OPERATION_LOG" //nolint:staticcheck
 )
 
@@ -49,6 +57,9 @@ type Server struct {
 	// Circuit breaker
 	CircuitBreaker CircuitBreaker `json:"circuit_breaker" mapstructure:"circuit_breaker" ini:"circuit_breaker" yaml:"circuit_breaker"`
 
+	// Adaptive concurrency limit
+	LoadShedding LoadShedding `json:"load_shedding" mapstructure:"load_shedding" ini:"load_shedding" yaml:"load_shedding"`
+
 	// Circular buffer
 	CircularBuffer CircularBuffer `json:"circular_buffer" mapstructure:"circular_buffer" ini:"circular_buffer" yaml:"circular_buffer"`
 }
@@ -61,7 +72,51 @@ type CircuitBreaker struct {
 	FailureRate float64       `json:"failure_rate" mapstructure:"failure_rate" ini:"failure_rate" yaml:"failure_rate"`
 	MinRequests uint32        `json:"min_requests" mapstructure:"min_requests" ini:"min_requests" yaml:"min_requests"`
 	Enable      bool          `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+
+	// Routes overrides the settings above for some routes, every route has its own breaker.
+	Routes []CircuitBreakerRoute `json:"routes" mapstructure:"routes" ini:"routes" yaml:"routes"`
+}
+
+// CircuitBreakerRoute overrides the circuit breaker settings of a route, zero values inherit the global settings.
+type CircuitBreakerRoute struct {
+	// Route is the route template, eg: "/api/iam/users/:id", optionally prefixed by the method,
+	// eg: "POST /api/iam/users". A route matching both forms uses the one with method.
+	Route       string        `json:"route" mapstructure:"route" ini:"route" yaml:"route"`
+	MaxRequests uint32        `json:"max_requests" mapstructure:"max_requests" ini:"max_requests" yaml:"max_requests"`
+	Interval    time.Duration `json:"interval" mapstructure:"interval" ini:"interval" yaml:"interval"`
+	Timeout     time.Duration `json:"timeout" mapstructure:"timeout" ini:"timeout" yaml:"timeout"`
+	FailureRate float64       `json:"failure_rate" mapstructure:"failure_rate" ini:"failure_rate" yaml:"failure_rate"`
+	MinRequests uint32        `json:"min_requests" mapstructure:"min_requests" ini:"min_requests" yaml:"min_requests"`
+	Disable     bool          `json:"disable" mapstructure:"disable" ini:"disable" yaml:"disable"`
 }
+
+// LoadShedding limits the concurrent requests with a limit adapted to the latency,
+// requests above the limit are rejected with 503 before the latency explodes.
+type LoadShedding struct {
+	Enable bool `json:"enable" mapstructure:"enable" ini:"enable" yaml:"enable"`
+
+	// Algorithm is "gradient" or "aimd", default is "gradient".
+	// gradient compares the short-term latency with the long-term latency,
+	// aimd decreases the limit when a request is slower than Latency or fails.
+	Algorithm    string `json:"algorithm" mapstructure:"algorithm" ini:"algorithm" yaml:"algorithm"`
+	InitialLimit int    `json:"initial_limit" mapstructure:"initial_limit" ini:"initial_limit" yaml:"initial_limit"`
+	MinLimit     int    `json:"min_limit" mapstructure:"min_limit" ini:"min_limit" yaml:"min_limit"`
+	MaxLimit     int    `json:"max_limit" mapstructure:"max_limit" ini:"max_limit" yaml:"max_limit"`
+
+	// Latency is the latency threshold of aimd.
+	Latency time.Duration `json:"latency" mapstructure:"latency" ini:"latency" yaml:"latency"`
+
+	// LowPriorityRatio is the ratio of the limit above which low priority requests are shed.
+	LowPriorityRatio float64 `json:"low_priority_ratio" mapstructure:"low_priority_ratio" ini:"low_priority_ratio" yaml:"low_priority_ratio"`
+
+	// CriticalRoutes are never shed, eg: health checks and authentication.
+	// LowPriorityRoutes are shed first, eg: exports and reports.
+	// A route is a route template with optional method prefix like CircuitBreakerRoute.Route,
+	// a trailing "*" matches the routes with the prefix.
+	CriticalRoutes    []string `json:"critical_routes" mapstructure:"critical_routes" ini:"critical_routes" yaml:"critical_routes"`
+	LowPriorityRoutes []string `json:"low_priority_routes" mapstructure:"low_priority_routes" ini:"low_priority_routes" yaml:"low_priority_routes"`
+}
+
 type CircularBuffer struct {
 	SizeOperationLog int64 `json:"size_operation_log" mapstructure:"size_operation_log" ini:"size" yaml:"size_operation_log"`
 }
@@ -84,6 +139,16 @@ func (*Server) setDefault() {
 	cv.SetDefault("server.circuit_breaker.min_requests", uint32(10))
 	cv.SetDefault("server.circuit_breaker.enable", true)
 
+	// Load shedding defaults
+	cv.SetDefault("server.load_shedding.enable", false)
+	cv.SetDefault("server.load_shedding.algorithm", "gradient")
+	cv.SetDefault("server.load_shedding.initial_limit", 100)
+	cv.SetDefault("server.load_shedding.min_limit", 10)
+	cv.SetDefault("server.load_shedding.max_limit", 1000)
+	cv.SetDefault("server.load_shedding.latency", 500*time.Millisecond)
+	cv.SetDefault("server.load_shedding.low_priority_ratio", 0.8)
+	cv.SetDefault("server.load_shedding.critical_routes", []string{"/-/healthz", "/-/readyz", "/-/leader", "/api/login", "/api/logout", "/api/signup", "/api/iam/session/*", "/api/2fa/*"})
+
 	// Circular buffer defaults
 	cv.SetDefault("server.circular_buffer.size_operation_log", int64(10000))
 }
@@ -14,7 +14,6 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/forbearing/gst/config"
-	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/ds/queue/circularbuffer"
 	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	"github.com/forbearing/gst/logger"
@@ -99,16 +98,10 @@ func Init() (err error) {
 }
 
 func Clean() {
-	operationLogs := make([]*modellogmgmt.OperationLog, 0, config.App.Server.CircularBuffer.SizeOperationLog)
-	for !cb.IsEmpty() {
-		ol, _ := cb.Dequeue()
-		operationLogs = append(operationLogs, ol)
-	}
-	if len(operationLogs) > 0 {
-		if err := database.Database[*modellogmgmt.OperationLog](nil).WithLimit(-1).WithBatchSize(100).Create(operationLogs...); err != nil {
-			zap.S().Error(err)
-		}
+	if am != nil {
+		am.Flush()
 	}
+	auditmanager.CloseSinks()
 }
 
 // Create is a generic function to product gin handler to create one resource.
@@ -735,6 +728,7 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 		req.SetCreatedAt(data[0].GetCreatedAt())           // keep original "created_at"
 		req.SetCreatedBy(data[0].GetCreatedBy())           // keep original "created_by"
 		req.SetUpdatedBy(c.GetString(consts.CTX_USERNAME)) // set updated_by to current user”
+		oldRecord, _ := json.Marshal(data[0])
 
 		// 1.Perform business logic processing before update resource.
 		var serviceCtxBefore *types.ServiceContext
@@ -793,6 +787,8 @@ func UpdateFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...
 			Record:    util.BytesToString(record),
 			Request:   util.BytesToString(reqData),
 			Response:  util.BytesToString(respData),
+			OldRecord: util.BytesToString(oldRecord),
+			NewRecord: util.BytesToString(record),
 			IP:        c.ClientIP(),
 			User:      c.GetString(consts.CTX_USERNAME),
 			RequestID: c.GetString(consts.REQUEST_ID),
@@ -985,6 +981,7 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 		// req.SetUpdatedBy(c.GetString(CTX_USERNAME))
 		data[0].SetUpdatedBy(c.GetString(consts.CTX_USERNAME))
 
+		oldRecord, _ := json.Marshal(data[0])
 		newVal := reflect.ValueOf(req).Elem()
 		oldVal := reflect.ValueOf(data[0]).Elem()
 		patchValue(log, typ, oldVal, newVal)
@@ -1047,6 +1044,8 @@ func PatchFactory[M types.Model, REQ types.Request, RSP types.Response](cfg ...*
 			Record:    util.BytesToString(record),
 			Request:   util.BytesToString(reqData),
 			Response:  util.BytesToString(respData),
+			OldRecord: util.BytesToString(oldRecord),
+			NewRecord: util.BytesToString(respData),
 			IP:        c.ClientIP(),
 			User:      c.GetString(consts.CTX_USERNAME),
 			RequestID: c.GetString(consts.REQUEST_ID),
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 
+	"github.com/forbearing/gst/election"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,3 +20,8 @@ func (*probe) Readyz(c *gin.Context) {
 	// c.Writer.WriteHeader(http.StatusOK)
 	c.Status(http.StatusOK)
 }
+
+// Leader returns the leader election status of the instance, see election.Statuses.
+func (*probe) Leader(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"elections": election.Statuses(c.Request.Context())})
+}
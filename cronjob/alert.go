@@ -0,0 +1,157 @@
+package cronjob
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/provider/feishu"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"go.uber.org/zap"
+)
+
+// alertTimeout is the timeout of sending an alert.
+const alertTimeout = 10 * time.Second
+
+// Alert is sent when a cronjob fails Config.AlertAfter times in a row on an instance,
+// again every AlertAfter failures, and once resolved by the next successful run.
+type Alert struct {
+	Job      string           `json:"job"`
+	Failures int              `json:"failures"` // Failures is the number of consecutive failures, the failures before the success if resolved.
+	Resolved bool             `json:"resolved"`
+	Run      modelcronjob.Run `json:"run"` // Run is the last failed run, or the successful run if resolved.
+}
+
+// String returns the alert as a human readable text.
+func (a Alert) String() string {
+	if a.Resolved {
+		return fmt.Sprintf("[RESOLVED] cronjob %q succeeded on %s after %d consecutive failures", a.Job, a.Run.Node, a.Failures)
+	}
+	return fmt.Sprintf("[ALERT] cronjob %q failed %d times in a row on %s\nstatus: %s\nattempts: %d\nerror: %s",
+		a.Job, a.Failures, a.Run.Node, a.Run.Status, a.Run.Attempts, a.Run.Error)
+}
+
+// Alerter sends the alerts of the failing cronjobs.
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// AlerterFunc is an adapter to use a function as Alerter.
+type AlerterFunc func(ctx context.Context, alert Alert) error
+
+func (f AlerterFunc) Alert(ctx context.Context, alert Alert) error { return f(ctx, alert) }
+
+var (
+	alerters   []Alerter
+	alertersMu sync.RWMutex
+)
+
+// AddAlerter adds an alerter, every alert is sent to all alerters.
+func AddAlerter(a Alerter) {
+	if a == nil {
+		return
+	}
+	alertersMu.Lock()
+	defer alertersMu.Unlock()
+	alerters = append(alerters, a)
+}
+
+// sendAlert sends the alert to all alerters in background.
+func sendAlert(alert Alert) {
+	alertersMu.RLock()
+	as := make([]Alerter, len(alerters))
+	copy(as, alerters)
+	alertersMu.RUnlock()
+
+	log.Warnz("cronjob alert", zap.String("name", alert.Job), zap.Int("failures", alert.Failures), zap.Bool("resolved", alert.Resolved))
+	for _, a := range as {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+			defer cancel()
+			if err := a.Alert(ctx, alert); err != nil {
+				log.Errorz(fmt.Sprintf("failed to send cronjob alert: %s", err), zap.String("name", alert.Job))
+			}
+		}()
+	}
+}
+
+// NewWebhookAlerter creates an alerter posting the alert as JSON to the url.
+func NewWebhookAlerter(url string, headers ...map[string]string) Alerter {
+	var h map[string]string
+	if len(headers) > 0 {
+		h = headers[0]
+	}
+	return &webhookAlerter{url: url, headers: h}
+}
+
+type webhookAlerter struct {
+	url     string
+	headers map[string]string
+}
+
+func (w *webhookAlerter) Alert(ctx context.Context, alert Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.Newf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// NewFeishuAlerter creates an alerter sending the alert as a text message with the feishu client,
+// see provider/feishu. receiveIDType is one of "chat_id", "open_id", "user_id", "union_id" and "email".
+func NewFeishuAlerter(receiveIDType, receiveID string) Alerter {
+	return &feishuAlerter{receiveIDType: receiveIDType, receiveID: receiveID}
+}
+
+type feishuAlerter struct {
+	receiveIDType string
+	receiveID     string
+}
+
+func (f *feishuAlerter) Alert(ctx context.Context, alert Alert) error {
+	cli := feishu.Client()
+	if cli == nil {
+		return errors.New("feishu is not enabled")
+	}
+	content, err := json.Marshal(map[string]string{"text": alert.String()})
+	if err != nil {
+		return err
+	}
+	resp, err := cli.Im.Message.Create(ctx, larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(f.receiveIDType).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(f.receiveID).
+			MsgType(larkim.MsgTypeText).
+			Content(string(content)).
+			Build()).
+		Build())
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return errors.Newf("feishu responded code %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
@@ -1,10 +1,15 @@
 package cronjob
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cockroachdb/errors"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/lock"
 	pkgzap "github.com/forbearing/gst/logger/zap"
 	"github.com/forbearing/gst/util"
 	"github.com/robfig/cron/v3"
@@ -12,28 +17,95 @@ import (
 )
 
 var (
-	c        *cron.Cron
-	log      *pkgzap.Logger
-	cronjobs = make([]*cronjob, 0)
-	parser   cron.Parser
-	mu       sync.Mutex
+	c          *cron.Cron
+	log        *pkgzap.Logger
+	cronjobs   = make([]*cronjob, 0)
+	registered = make([]*cronjob, 0) // registered are the cronjobs added to the scheduler, see Jobs
+	parser     cron.Parser
+	mu         sync.Mutex
 
 	inited bool
+
+	locker   lock.Locker
+	lockerMu sync.RWMutex
+
+	// afterFunc schedules the release of a held lock, it's replaced in tests.
+	afterFunc = time.AfterFunc
+)
+
+const (
+	// lockTTL is the ttl of the lock of a singleton cronjob, the lock is renewed every lockTTL/3 while held.
+	lockTTL = 30 * time.Second
+	// lockPrefix is the prefix of the lock names of the singleton cronjobs.
+	lockPrefix = "cronjob:"
+	// maxLockHold is the max time the lock is held after a short run, see Config.Singleton.
+	maxLockHold = time.Minute
 )
 
 type cronjob struct {
 	name           string
 	spec           string
-	fn             func() error
+	fn             func(context.Context) error
 	sched          cron.Schedule
 	runImmediately bool
+	singleton      bool
+	overlap        OverlapPolicy
+	timeout        time.Duration
+	retry          RetryPolicy
+	alertAfter     int
+
+	running atomic.Bool // running is used by OverlapSkip
+	queue   sync.Mutex  // queue is used by OverlapQueue
+
+	// The following fields are guarded by mu.
+	entryID cron.EntryID
+	paused  bool
+
+	active   atomic.Int32 // active is the number of runs in progress
+	lastMu   sync.RWMutex
+	lastRun  *modelcronjob.Run
+	failures int // failures is the number of consecutive failed runs, guarded by lastMu
 }
 
+// OverlapPolicy decides what happens when a cronjob is due while its previous run
+// on the same instance is still running.
+type OverlapPolicy string
+
+const (
+	// OverlapAllow runs the cronjob concurrently with the previous run, it's the default.
+	OverlapAllow OverlapPolicy = "allow"
+	// OverlapSkip skips the run, it's recorded with status skipped.
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue waits for the previous run to finish.
+	OverlapQueue OverlapPolicy = "queue"
+)
+
 // Config defines the configuration for cronjob package
 type Config struct {
 	// RunImmediately indicates whether to run the cronjob immediately after registration
 	// in addition to the scheduled execution
 	RunImmediately bool `json:"run_immediately" yaml:"run_immediately" toml:"run_immediately"`
+
+	// Singleton runs the cronjob on only one instance when multiple replicas are deployed.
+	// Every run acquires the lock of the cronjob with the lock.Locker, instances that fail to
+	// acquire it skip the run. To tolerate clock skew between instances, the lock of a short
+	// run is held until half of the interval to the next run has elapsed, at most one minute.
+	Singleton bool `json:"singleton" yaml:"singleton" toml:"singleton"`
+
+	// Overlap decides what happens when the previous run on this instance is still running, default is "allow".
+	Overlap OverlapPolicy `json:"overlap" yaml:"overlap" toml:"overlap"`
+
+	// Timeout cancels the context passed to the cronjob after the duration, zero means no timeout.
+	// The run is recorded with status timeout, cronjobs not checking the context still run to completion.
+	// It applies to every attempt of Retry.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+
+	// Retry retries the failed attempts of a run, a run is failed only if its last attempt failed.
+	Retry RetryPolicy `json:"retry" yaml:"retry" toml:"retry"`
+
+	// AlertAfter sends an alert to the alerters after the number of consecutive failed runs
+	// on this instance, see AddAlerter. Zero disables the alerts.
+	AlertAfter int `json:"alert_after" yaml:"alert_after" toml:"alert_after"`
 }
 
 func init() {
@@ -47,20 +119,27 @@ func Init() (err error) {
 	if c == nil {
 		c = cron.New(cron.WithSeconds())
 	}
-
+	mu.Lock()
 	for _, cj := range cronjobs {
 		register(cj)
 	}
+	inited = true
+	mu.Unlock()
 
 	c.Start()
-
-	inited = true
+	subscribeControl()
 	return nil
 }
 
 // Register cronjob can be called at any point before or after Init().
 // The config parameter is optional and can be used to customize cronjob behavior.
 func Register(fn func() error, spec string, name string, config ...Config) {
+	RegisterContext(func(context.Context) error { return fn() }, spec, name, config...)
+}
+
+// RegisterContext works like Register, the context passed to fn is canceled
+// when Config.Timeout elapses or the lock of a singleton cronjob is lost.
+func RegisterContext(fn func(ctx context.Context) error, spec string, name string, config ...Config) {
 	var cfg Config
 	if len(config) > 0 {
 		cfg = config[0]
@@ -73,6 +152,11 @@ func Register(fn func() error, spec string, name string, config ...Config) {
 		spec:           spec,
 		fn:             fn,
 		runImmediately: cfg.RunImmediately,
+		singleton:      cfg.Singleton,
+		overlap:        cfg.Overlap,
+		timeout:        cfg.Timeout,
+		retry:          cfg.Retry,
+		alertAfter:     cfg.AlertAfter,
 	}
 
 	if inited {
@@ -95,38 +179,249 @@ func register(cj *cronjob) {
 		return
 	}
 
+	registered = append(registered, cj)
+
 	// Execute immediately if configured to do so
-	if cj.runImmediately {
-		go func() {
-			defer func() {
-				if err := recover(); err != nil {
-					log.Errorw(fmt.Sprintf("cronjob immediate execution panic: %s", err), "name", cj.name, "spec", cj.spec)
-				}
-			}()
-			begin := time.Now()
-			if err = cj.fn(); err != nil {
-				log.Errorz(fmt.Sprintf("finished immediate cronjob execution with error: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
+	if cj.runImmediately && !cj.paused {
+		go cj.run(modelcronjob.RunTriggerImmediate)
+	}
+	if cj.paused {
+		log.Infoz("cronjob is paused", zap.String("name", cj.name), zap.String("spec", cj.spec))
+		return
+	}
+
+	if err = cj.schedule(); err != nil {
+		log.Errorz(fmt.Sprintf("failed to add cronjob: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec))
+	} else {
+		log.Infoz("successfully add cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec),
+			zap.Bool("run_immediately", cj.runImmediately), zap.Bool("singleton", cj.singleton), zap.String("overlap", string(cj.overlap)))
+	}
+}
+
+// schedule adds the cronjob to the cron scheduler, the caller must hold mu.
+func (cj *cronjob) schedule() (err error) {
+	cj.entryID, err = c.AddFunc(cj.spec, func() { cj.run(modelcronjob.RunTriggerSchedule) })
+	return err
+}
+
+// run runs the cronjob once, applying the overlap policy, the singleton lock, the timeout and the retry policy.
+func (cj *cronjob) run(trigger modelcronjob.RunTrigger) {
+	cj.runWith(&modelcronjob.Run{Trigger: trigger})
+}
+
+// runWith runs the cronjob as r, r is completed with the result of the run.
+func (cj *cronjob) runWith(r *modelcronjob.Run) {
+	r.Job, r.Node, r.StartedAt = cj.name, node, time.Now()
+	trigger := r.Trigger
+	fields := []zap.Field{zap.String("name", cj.name), zap.String("spec", cj.spec), zap.String("trigger", string(trigger))}
+
+	switch cj.overlap {
+	case OverlapSkip:
+		if !cj.running.CompareAndSwap(false, true) {
+			log.Warnz("skip cronjob, the previous run is still running", fields...)
+			cj.finish(r, modelcronjob.RunStatusSkipped, nil)
+			return
+		}
+		defer cj.running.Store(false)
+	case OverlapQueue:
+		cj.queue.Lock()
+		defer cj.queue.Unlock()
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	if cj.singleton {
+		lease, err := currentLocker().TryLock(ctx, lockPrefix+cj.name, lock.WithTTL(lockTTL))
+		if errors.Is(err, lock.ErrNotAcquired) {
+			// A manual run is expected to run, report why it did not.
+			if isManual(trigger) {
+				log.Warnz("skip cronjob, it is running on another instance", fields...)
+				cj.finish(r, modelcronjob.RunStatusSkipped, ErrRunningElsewhere)
 			} else {
-				log.Infoz("finished immediate cronjob execution", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
+				log.Debugz("skip cronjob, it is running on another instance", fields...)
 			}
+			return
+		}
+		if err != nil {
+			log.Errorz(fmt.Sprintf("failed to acquire cronjob lock: %s", err), fields...)
+			cj.finish(r, modelcronjob.RunStatusFailure, errors.Wrap(err, "failed to acquire cronjob lock"))
+			return
+		}
+		stop := cj.watchLease(lease, cancel)
+		defer func() {
+			stop()
+			cj.releaseLease(lease, r.StartedAt, trigger)
 		}()
 	}
 
-	if _, err = c.AddFunc(cj.spec, func() {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Errorw(fmt.Sprintf("cronjob panic: %s", err), "name", cj.name, "spec", cj.spec)
-			}
-		}()
-		begin := time.Now()
-		if err = cj.fn(); err != nil {
-			log.Errorz(fmt.Sprintf("finished cronjob with error: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Time("next", cj.sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
-		} else {
-			log.Infoz("finished cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Time("next", cj.sched.Next(begin)), zap.String("cost", util.FormatDurationSmart(time.Since(begin))))
+	cj.active.Add(1)
+	defer cj.active.Add(-1)
+	publish(Event{Type: EventStarted, Run: *r})
+
+	var status modelcronjob.RunStatus
+	var err error
+	for {
+		r.Attempts++
+		if status, err = cj.attempt(ctx); err == nil ||
+			r.Attempts >= cj.retry.maxAttempts() || !cj.retry.retryable(err) || ctx.Err() != nil {
+			break
 		}
-	}); err != nil {
-		log.Errorz(fmt.Sprintf("failed to add cronjob: %s", err), zap.String("name", cj.name), zap.String("spec", cj.spec))
+		delay := cj.retry.backoff(r.Attempts)
+		log.Warnz(fmt.Sprintf("cronjob attempt failed, retry in %s: %s", delay, err), append(fields, zap.Int("attempt", r.Attempts))...)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	fields = append(fields, zap.Int("attempts", r.Attempts), zap.Time("next", cj.sched.Next(r.StartedAt)), zap.String("cost", util.FormatDurationSmart(time.Since(r.StartedAt))))
+	if err != nil {
+		log.Errorz(fmt.Sprintf("finished cronjob with error: %s", err), fields...)
 	} else {
-		log.Infoz("successfully add cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec), zap.Bool("run_immediately", cj.runImmediately))
+		log.Infoz("finished cronjob", fields...)
+	}
+	cj.finish(r, status, err)
+}
+
+// attempt calls the cronjob function once with the timeout.
+func (cj *cronjob) attempt(ctx context.Context) (modelcronjob.RunStatus, error) {
+	if cj.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cj.timeout)
+		defer cancel()
+	}
+
+	err := cj.call(ctx)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// The function may return nil or its own error, the timeout is reported as context.DeadlineExceeded.
+		if err == nil {
+			err = errors.Wrapf(context.DeadlineExceeded, "cronjob timed out after %s", cj.timeout)
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w (timed out after %s: %w)", err, cj.timeout, context.DeadlineExceeded)
+		}
+		return modelcronjob.RunStatusTimeout, err
+	case err != nil:
+		return modelcronjob.RunStatusFailure, err
+	default:
+		return modelcronjob.RunStatusSuccess, nil
+	}
+}
+
+// finish completes the run, publishes it, saves it into the run history and sends the alerts.
+func (cj *cronjob) finish(r *modelcronjob.Run, status modelcronjob.RunStatus, err error) {
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	last := *r
+	cj.lastMu.Lock()
+	cj.lastRun = &last
+	failures := cj.failures
+	switch status {
+	case modelcronjob.RunStatusFailure, modelcronjob.RunStatusTimeout:
+		cj.failures++
+	case modelcronjob.RunStatusSuccess:
+		cj.failures = 0
+	}
+	current := cj.failures
+	cj.lastMu.Unlock()
+
+	publish(Event{Type: EventFinished, Run: *r})
+	record(r)
+
+	if cj.alertAfter > 0 {
+		switch {
+		case current > failures && current%cj.alertAfter == 0:
+			sendAlert(Alert{Job: cj.name, Failures: current, Run: *r})
+		case current == 0 && failures >= cj.alertAfter:
+			sendAlert(Alert{Job: cj.name, Failures: failures, Resolved: true, Run: *r})
+		}
+	}
+}
+
+// call calls the cronjob function, a panic is returned as error.
+func (cj *cronjob) call(ctx context.Context) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Newf("cronjob panic: %v", e)
+		}
+	}()
+	return cj.fn(ctx)
+}
+
+// watchLease cancels the run if the lease of the lock is lost, the lease is renewed by the locker.
+func (cj *cronjob) watchLease(lease lock.Lease, cancel context.CancelCauseFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-lease.Done():
+			if err := lease.Err(); err != nil {
+				log.Errorz(fmt.Sprintf("lost cronjob lock, cancel the run: %s", err), zap.String("name", cj.name))
+				cancel(err)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// releaseLease releases the lock, or keeps it until begin + hold so that
+// instances whose clock is behind do not run the same schedule again.
+// The lock of a manual run or a replay is released immediately, it does not belong to a schedule.
+func (cj *cronjob) releaseLease(lease lock.Lease, begin time.Time, trigger modelcronjob.RunTrigger) {
+	unlock := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := lease.Unlock(ctx); err != nil && !errors.Is(err, lock.ErrLockLost) {
+			log.Warnz(fmt.Sprintf("failed to release cronjob lock: %s", err), zap.String("name", cj.name))
+		}
+	}
+	if remaining := time.Until(begin.Add(cj.lockHold(begin))); remaining > 0 && !isManual(trigger) {
+		afterFunc(remaining, unlock)
+		return
+	}
+	unlock()
+}
+
+// lockHold returns how long the lock is held after the run started at begin,
+// half of the period of the schedule and at most maxLockHold.
+// The period is measured from the next activation, the schedules round it to whole seconds
+// and the time from begin to the next activation may be close to zero.
+func (cj *cronjob) lockHold(begin time.Time) time.Duration {
+	if cj.sched == nil {
+		return 0
+	}
+	next := cj.sched.Next(begin)
+	return min(cj.sched.Next(next).Sub(next)/2, maxLockHold)
+}
+
+// isManual reports whether the run is requested by a user rather than the schedule.
+func isManual(trigger modelcronjob.RunTrigger) bool {
+	return trigger == modelcronjob.RunTriggerManual || trigger == modelcronjob.RunTriggerReplay
+}
+
+// SetLocker sets the locker of the singleton cronjobs, default is lock.Default.
+func SetLocker(l lock.Locker) {
+	lockerMu.Lock()
+	defer lockerMu.Unlock()
+	locker = l
+}
+
+func currentLocker() lock.Locker {
+	lockerMu.RLock()
+	defer lockerMu.RUnlock()
+	if locker != nil {
+		return locker
 	}
+	return lock.Default()
 }
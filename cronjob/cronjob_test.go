@@ -0,0 +1,363 @@
+package cronjob
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/lock"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	log = pkgzap.New("/dev/stdout")
+	os.Exit(m.Run())
+}
+
+func newCronjob(t *testing.T, spec string, cfg Config, fn func(context.Context) error) *cronjob {
+	t.Helper()
+	sched, err := parser.Parse(spec)
+	require.NoError(t, err)
+	return &cronjob{
+		name:       t.Name(),
+		spec:       spec,
+		fn:         fn,
+		sched:      sched,
+		singleton:  cfg.Singleton,
+		overlap:    cfg.Overlap,
+		timeout:    cfg.Timeout,
+		retry:      cfg.Retry,
+		alertAfter: cfg.AlertAfter,
+	}
+}
+
+// blockingJob returns a cronjob function that blocks until release is closed,
+// it counts the calls and the max number of concurrent calls.
+func blockingJob(release <-chan struct{}) (fn func(context.Context) error, calls, maxConcurrent *atomic.Int32) {
+	calls, maxConcurrent = new(atomic.Int32), new(atomic.Int32)
+	var concurrent atomic.Int32
+	fn = func(context.Context) error {
+		calls.Add(1)
+		n := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		for {
+			m := maxConcurrent.Load()
+			if n <= m || maxConcurrent.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		return nil
+	}
+	return fn, calls, maxConcurrent
+}
+
+func runConcurrently(cj *cronjob, n int) *sync.WaitGroup {
+	wg := new(sync.WaitGroup)
+	for range n {
+		wg.Go(func() { cj.run(modelcronjob.RunTriggerSchedule) })
+	}
+	return wg
+}
+
+func TestOverlap(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		release := make(chan struct{})
+		fn, calls, _ := blockingJob(release)
+		cj := newCronjob(t, "@every 1h", Config{Overlap: OverlapSkip}, fn)
+
+		wg := runConcurrently(cj, 3)
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		release := make(chan struct{})
+		fn, calls, maxConcurrent := blockingJob(release)
+		cj := newCronjob(t, "@every 1h", Config{Overlap: OverlapQueue}, fn)
+
+		wg := runConcurrently(cj, 3)
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(3), calls.Load())
+		require.Equal(t, int32(1), maxConcurrent.Load())
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		release := make(chan struct{})
+		fn, calls, maxConcurrent := blockingJob(release)
+		cj := newCronjob(t, "@every 1h", Config{}, fn)
+
+		wg := runConcurrently(cj, 3)
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(3), calls.Load())
+		require.Equal(t, int32(3), maxConcurrent.Load())
+	})
+}
+
+func TestSingleton(t *testing.T) {
+	SetLocker(lock.NewMemoryLocker())
+	defer SetLocker(nil)
+
+	// Capture the delayed release of the lock instead of waiting for it.
+	var hold time.Duration
+	var release func()
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		hold, release = d, f
+		return nil
+	}
+	defer func() { afterFunc = time.AfterFunc }()
+
+	started, unblock := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	fn := func(context.Context) error {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-unblock
+		}
+		return nil
+	}
+
+	// Two instances of the same cronjob, each with its own overlap state.
+	spec := "@every 1h"
+	node1 := newCronjob(t, spec, Config{Singleton: true}, fn)
+	node2 := newCronjob(t, spec, Config{Singleton: true}, fn)
+
+	done := make(chan struct{})
+	go func() {
+		node1.run(modelcronjob.RunTriggerSchedule)
+		close(done)
+	}()
+	<-started
+	node2.run(modelcronjob.RunTriggerSchedule)
+	close(unblock)
+	<-done
+	require.Equal(t, int32(1), calls.Load())
+
+	// The lock is held for half of the period after the run, at most maxLockHold.
+	require.NotNil(t, release)
+	require.InDelta(t, maxLockHold, hold, float64(time.Second))
+	node2.run(modelcronjob.RunTriggerSchedule)
+	require.Equal(t, int32(1), calls.Load())
+
+	release()
+	node2.run(modelcronjob.RunTriggerSchedule)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestLockHold(t *testing.T) {
+	cases := []struct {
+		spec string
+		hold time.Duration
+	}{
+		{"@every 1s", 500 * time.Millisecond},
+		{"*/10 * * * * *", 5 * time.Second},
+		{"0 0 * * * *", maxLockHold},
+	}
+	// Just before the next activation of all specs.
+	begin := time.Date(2026, 1, 1, 0, 59, 59, 999_000_000, time.Local)
+	for _, c := range cases {
+		cj := newCronjob(t, c.spec, Config{}, nil)
+		require.Equal(t, c.hold, cj.lockHold(begin), c.spec)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var ctxErr error
+	cj := newCronjob(t, "@every 1h", Config{Timeout: 50 * time.Millisecond}, func(ctx context.Context) error {
+		<-ctx.Done()
+		ctxErr = ctx.Err()
+		return ctxErr
+	})
+	cj.run(modelcronjob.RunTriggerSchedule)
+	require.ErrorIs(t, ctxErr, context.DeadlineExceeded)
+
+	// Retryable sees the timeout whatever the function returns.
+	for _, ret := range []error{nil, errors.New("interrupted")} {
+		cj = newCronjob(t, "@every 1h", Config{Timeout: time.Millisecond}, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ret
+		})
+		status, err := cj.attempt(context.Background())
+		require.Equal(t, modelcronjob.RunStatusTimeout, status)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	cj := newCronjob(t, "@every 1h", Config{}, func(context.Context) error { panic("boom") })
+	require.ErrorContains(t, cj.call(context.Background()), "boom")
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("succeed", func(t *testing.T) {
+		var calls atomic.Int32
+		cj := newCronjob(t, "@every 1h", Config{Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}}, func(context.Context) error {
+			if calls.Add(1) < 3 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		cj.run(modelcronjob.RunTriggerSchedule)
+		require.Equal(t, int32(3), calls.Load())
+		require.Equal(t, 3, cj.lastRun.Attempts)
+		require.Equal(t, modelcronjob.RunStatusSuccess, cj.lastRun.Status)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		var calls atomic.Int32
+		cj := newCronjob(t, "@every 1h", Config{Retry: RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}}, func(context.Context) error {
+			calls.Add(1)
+			return errors.New("failed")
+		})
+		cj.run(modelcronjob.RunTriggerSchedule)
+		require.Equal(t, int32(2), calls.Load())
+		require.Equal(t, modelcronjob.RunStatusFailure, cj.lastRun.Status)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		errPermanent := errors.New("permanent")
+		var calls atomic.Int32
+		cj := newCronjob(t, "@every 1h", Config{Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			Retryable:      func(err error) bool { return !errors.Is(err, errPermanent) },
+		}}, func(context.Context) error {
+			calls.Add(1)
+			return errPermanent
+		})
+		cj.run(modelcronjob.RunTriggerSchedule)
+		require.Equal(t, int32(1), calls.Load())
+		require.Equal(t, 1, cj.lastRun.Attempts)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}
+	require.Equal(t, 100*time.Millisecond, p.backoff(1))
+	require.Equal(t, 200*time.Millisecond, p.backoff(2))
+	require.Equal(t, 400*time.Millisecond, p.backoff(3))
+	require.Equal(t, time.Second, p.backoff(10))
+
+	p.Jitter = 0.5
+	for range 100 {
+		d := p.backoff(2)
+		require.GreaterOrEqual(t, d, 100*time.Millisecond)
+		require.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	alerts := make(chan Alert, 10)
+	AddAlerter(AlerterFunc(func(_ context.Context, a Alert) error {
+		alerts <- a
+		return nil
+	}))
+	defer func() {
+		alertersMu.Lock()
+		alerters = nil
+		alertersMu.Unlock()
+	}()
+
+	var fail atomic.Bool
+	fail.Store(true)
+	cj := newCronjob(t, "@every 1h", Config{AlertAfter: 2}, func(context.Context) error {
+		if fail.Load() {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	cj.run(modelcronjob.RunTriggerSchedule)
+	select {
+	case a := <-alerts:
+		t.Fatalf("unexpected alert after 1 failure: %v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cj.run(modelcronjob.RunTriggerSchedule)
+	a := <-alerts
+	require.False(t, a.Resolved)
+	require.Equal(t, 2, a.Failures)
+	require.Equal(t, "failed", a.Run.Error)
+
+	cj.run(modelcronjob.RunTriggerSchedule)
+	cj.run(modelcronjob.RunTriggerSchedule)
+	a = <-alerts
+	require.Equal(t, 4, a.Failures)
+
+	fail.Store(false)
+	cj.run(modelcronjob.RunTriggerSchedule)
+	a = <-alerts
+	require.True(t, a.Resolved)
+	require.Equal(t, 4, a.Failures)
+}
+
+func TestRuntime(t *testing.T) {
+	c = cron.New(cron.WithSeconds())
+	inited = true
+	defer func() {
+		mu.Lock()
+		c, inited, registered = nil, false, registered[:0]
+		mu.Unlock()
+	}()
+
+	name := t.Name()
+	RegisterContext(func(context.Context) error { return errors.New("failed") }, "@every 1h", name, Config{Overlap: OverlapSkip})
+
+	job := findJob(t, name)
+	require.False(t, job.Paused)
+	require.False(t, job.Next.IsZero())
+	require.Nil(t, job.LastRun)
+
+	require.NoError(t, Pause(name))
+	job = findJob(t, name)
+	require.True(t, job.Paused)
+	require.True(t, job.Next.IsZero())
+	require.ErrorIs(t, Pause(name), ErrJobPaused)
+
+	require.NoError(t, Resume(name))
+	job = findJob(t, name)
+	require.False(t, job.Paused)
+	require.False(t, job.Next.IsZero())
+	require.ErrorIs(t, Resume(name), ErrJobNotPaused)
+
+	events, cancel := Subscribe()
+	defer cancel()
+	require.NoError(t, Trigger(name))
+	started, finished := <-events, <-events
+	require.Equal(t, EventStarted, started.Type)
+	require.Equal(t, EventFinished, finished.Type)
+	require.Equal(t, modelcronjob.RunTriggerManual, finished.Run.Trigger)
+	require.Equal(t, modelcronjob.RunStatusFailure, finished.Run.Status)
+	require.Equal(t, "failed", finished.Run.Error)
+
+	job = findJob(t, name)
+	require.NotNil(t, job.LastRun)
+	require.Equal(t, modelcronjob.RunStatusFailure, job.LastRun.Status)
+
+	require.ErrorIs(t, Trigger("not-exists"), ErrJobNotFound)
+	require.ErrorIs(t, Pause("not-exists"), ErrJobNotFound)
+}
+
+func findJob(t *testing.T, name string) Job {
+	t.Helper()
+	for _, job := range Jobs() {
+		if job.Name == name {
+			return job
+		}
+	}
+	t.Fatalf("job %q not found", name)
+	return Job{}
+}
@@ -0,0 +1,108 @@
+package cronjob
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+// defaultHistoryRetention is the default retention of the run history.
+const defaultHistoryRetention = 7 * 24 * time.Hour
+
+// cleanupBatchSize is the number of runs deleted at a time by the history cleanup.
+const cleanupBatchSize = 1000
+
+var (
+	historyEnabled   atomic.Bool
+	historyRetention atomic.Int64
+
+	// node identifies this instance in the run history.
+	node = func() string {
+		hostname, _ := os.Hostname()
+		return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}()
+)
+
+// EnableHistory persists every run of the cronjobs as modelcronjob.Run, and every failed run,
+// after all retries, as modelcronjob.DeadLetter which can be replayed, see Replay.
+// Runs and dead letters older than retention are cleaned up hourly, default retention is 7 days.
+// Runs skipped because another instance holds the lock of a singleton cronjob are not recorded.
+func EnableHistory(retention ...time.Duration) {
+	r := defaultHistoryRetention
+	if len(retention) > 0 && retention[0] > 0 {
+		r = retention[0]
+	}
+	historyRetention.Store(int64(r))
+	if historyEnabled.Swap(true) {
+		return
+	}
+	model.Register[*modelcronjob.Run]()
+	model.Register[*modelcronjob.DeadLetter]()
+	Register(cleanupHistory, "0 30 * * * *", "cleanup cronjob run history", Config{Singleton: true, Overlap: OverlapSkip})
+}
+
+// record saves the run into the run history if enabled, and the dead letter of a failed run.
+func record(r *modelcronjob.Run) {
+	if !historyEnabled.Load() {
+		return
+	}
+	if err := database.Database[*modelcronjob.Run](nil).Create(r); err != nil {
+		log.Errorz(fmt.Sprintf("failed to save cronjob run: %s", err), zap.String("name", r.Job))
+	}
+	if r.Status != modelcronjob.RunStatusFailure && r.Status != modelcronjob.RunStatusTimeout {
+		return
+	}
+	if err := database.Database[*modelcronjob.DeadLetter](nil).Create(&modelcronjob.DeadLetter{
+		Job:      r.Job,
+		RunID:    r.ID,
+		Node:     r.Node,
+		Trigger:  r.Trigger,
+		Status:   r.Status,
+		Error:    r.Error,
+		Attempts: r.Attempts,
+		FailedAt: r.FinishedAt,
+	}); err != nil {
+		log.Errorz(fmt.Sprintf("failed to save cronjob dead letter: %s", err), zap.String("name", r.Job))
+	}
+}
+
+// HistoryEnabled reports whether the runs are saved into the run history, see EnableHistory.
+func HistoryEnabled() bool { return historyEnabled.Load() }
+
+// cleanupHistory deletes the runs and the dead letters older than the retention.
+func cleanupHistory() error {
+	end := time.Now().Add(-time.Duration(historyRetention.Load()))
+	if err := purge[*modelcronjob.Run]("started_at", end); err != nil {
+		return err
+	}
+	return purge[*modelcronjob.DeadLetter]("failed_at", end)
+}
+
+// purge deletes the records whose column is before end in batches.
+func purge[M types.Model](column string, end time.Time) error {
+	for {
+		records := make([]M, 0, cleanupBatchSize)
+		if err := database.Database[M](nil).
+			WithTimeRange(column, time.Time{}, end).
+			WithLimit(cleanupBatchSize).
+			List(&records); err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := database.Database[M](nil).WithPurge().Delete(records...); err != nil {
+			return err
+		}
+		if len(records) < cleanupBatchSize {
+			return nil
+		}
+	}
+}
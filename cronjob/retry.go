@@ -0,0 +1,73 @@
+package cronjob
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultMultiplier     = 2
+	defaultJitter         = 0.2
+)
+
+// RetryPolicy retries a failed run of a cronjob with exponential backoff.
+// The retries belong to the same run: the lock of a singleton cronjob is held
+// and the overlap policy applies until the last attempt finishes.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of calls of the cronjob function in a run,
+	// including the first call. Zero or one disables the retry.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts"`
+
+	// InitialBackoff is the delay before the first retry, default is 1s.
+	InitialBackoff time.Duration `json:"initial_backoff" yaml:"initial_backoff" toml:"initial_backoff"`
+
+	// MaxBackoff caps the delay between two attempts, default is 1m.
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+
+	// Multiplier is the growth of the delay after every retry, default is 2.
+	Multiplier float64 `json:"multiplier" yaml:"multiplier" toml:"multiplier"`
+
+	// Jitter randomizes the delay by up to ±Jitter of it, from 0 to 1, default is 0.2.
+	// A negative value disables the jitter.
+	Jitter float64 `json:"jitter" yaml:"jitter" toml:"jitter"`
+
+	// Retryable reports whether the error of an attempt is retried, nil retries all errors.
+	// The error of a timed out attempt matches context.DeadlineExceeded with errors.Is,
+	// panics are passed as the error of the panic.
+	Retryable func(error) bool `json:"-" yaml:"-" toml:"-"`
+}
+
+func (p RetryPolicy) maxAttempts() int { return max(p.MaxAttempts, 1) }
+
+func (p RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// backoff returns the delay after the attempt, attempt starts from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff, multiplier, jitter := p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+	switch {
+	case jitter == 0:
+		jitter = defaultJitter
+	case jitter < 0:
+		jitter = 0
+	case jitter > 1:
+		jitter = 1
+	}
+
+	d := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(maxBackoff))
+	d *= 1 + jitter*(2*rand.Float64()-1) // #nosec G404 -- jitter does not need a secure random
+	return min(time.Duration(d), maxBackoff)
+}
@@ -0,0 +1,312 @@
+package cronjob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrJobNotFound is returned when no cronjob is registered with the name.
+	ErrJobNotFound = errors.New("cronjob: job not found")
+	// ErrJobPaused is returned by Pause if the cronjob is already paused.
+	ErrJobPaused = errors.New("cronjob: job is already paused")
+	// ErrJobNotPaused is returned by Resume if the cronjob is not paused.
+	ErrJobNotPaused = errors.New("cronjob: job is not paused")
+	// ErrRunningElsewhere is the error of a manual run of a singleton cronjob
+	// skipped because another instance holds its lock.
+	ErrRunningElsewhere = errors.New("cronjob: job is running on another instance")
+	// ErrDeadLetterNotFound is returned by Replay if the dead letter does not exist.
+	ErrDeadLetterNotFound = errors.New("cronjob: dead letter not found")
+	// ErrHistoryDisabled is returned by Replay if the run history is not enabled, see EnableHistory.
+	ErrHistoryDisabled = errors.New("cronjob: run history is not enabled")
+)
+
+// Job is the runtime state of a cronjob on this instance.
+type Job struct {
+	Name       string
+	Spec       string
+	Singleton  bool
+	Overlap    OverlapPolicy
+	Timeout    time.Duration
+	Retry      RetryPolicy
+	AlertAfter int
+	Paused     bool
+	Running    int       // Running is the number of runs in progress on this instance.
+	Failures   int       // Failures is the number of consecutive failed runs on this instance.
+	Next       time.Time // Next is zero if the cronjob is paused.
+	Prev       time.Time // Prev is the last time the scheduler started the cronjob, zero if it has not.
+
+	// LastRun is the last run on this instance, nil if the cronjob has not run.
+	LastRun *modelcronjob.Run
+}
+
+// Jobs returns the cronjobs added to the scheduler, in registration order.
+func Jobs() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+
+	jobs := make([]Job, 0, len(registered))
+	for _, cj := range registered {
+		job := Job{
+			Name:       cj.name,
+			Spec:       cj.spec,
+			Singleton:  cj.singleton,
+			Overlap:    cj.overlap,
+			Timeout:    cj.timeout,
+			Retry:      cj.retry,
+			AlertAfter: cj.alertAfter,
+			Paused:     cj.paused,
+			Running:    int(cj.active.Load()),
+		}
+		if cj.entryID != 0 {
+			entry := c.Entry(cj.entryID)
+			job.Next, job.Prev = entry.Next, entry.Prev
+			// The scheduler computes the next time once started.
+			if job.Next.IsZero() {
+				job.Next = cj.sched.Next(time.Now())
+			}
+		}
+		cj.lastMu.RLock()
+		job.LastRun = cj.lastRun
+		job.Failures = cj.failures
+		cj.lastMu.RUnlock()
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// Trigger runs the cronjob now on this instance, in addition to its schedule.
+// It returns once the run is started, the run is reported by the events and the run history.
+// A paused cronjob can be triggered.
+func Trigger(name string) error {
+	mu.Lock()
+	cj := lookup(name)
+	mu.Unlock()
+	if cj == nil {
+		return ErrJobNotFound
+	}
+	go cj.run(modelcronjob.RunTriggerManual)
+	return nil
+}
+
+// Replay runs the cronjob of the dead letter again on this instance, like Trigger.
+// The dead letter is kept with the number and time of the replays, the replay is
+// recorded as a run whose ReplayOf is the dead letter, and a new dead letter if it fails.
+// It returns the replayed dead letter.
+func Replay(id string) (*modelcronjob.DeadLetter, error) {
+	if !historyEnabled.Load() {
+		return nil, ErrHistoryDisabled
+	}
+	dl := new(modelcronjob.DeadLetter)
+	if err := database.Database[*modelcronjob.DeadLetter](nil).Get(dl, id); err != nil {
+		if errors.Is(err, types.ErrEntryNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+	if len(dl.ID) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	mu.Lock()
+	cj := lookup(dl.Job)
+	mu.Unlock()
+	if cj == nil {
+		return nil, ErrJobNotFound
+	}
+
+	now := time.Now()
+	dl.Replays++
+	dl.ReplayedAt = &now
+	if err := database.Database[*modelcronjob.DeadLetter](nil).Update(dl); err != nil {
+		return nil, err
+	}
+	go cj.runWith(&modelcronjob.Run{Trigger: modelcronjob.RunTriggerReplay, ReplayOf: dl.ID})
+	return dl, nil
+}
+
+// Pause removes the cronjob from the scheduler, runs in progress are not canceled.
+// The pause is propagated to the other instances through redis if enabled,
+// instances started later schedule the cronjob again.
+func Pause(name string) error {
+	if err := pause(name); err != nil {
+		return err
+	}
+	broadcast(controlPause, name)
+	return nil
+}
+
+// Resume adds the paused cronjob back to the scheduler, it's propagated like Pause.
+func Resume(name string) error {
+	if err := resume(name); err != nil {
+		return err
+	}
+	broadcast(controlResume, name)
+	return nil
+}
+
+func pause(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	cj := lookup(name)
+	if cj == nil {
+		return ErrJobNotFound
+	}
+	if cj.paused {
+		return ErrJobPaused
+	}
+	if cj.entryID != 0 {
+		c.Remove(cj.entryID)
+		cj.entryID = 0
+	}
+	cj.paused = true
+	log.Infoz("paused cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec))
+	return nil
+}
+
+func resume(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	cj := lookup(name)
+	if cj == nil {
+		return ErrJobNotFound
+	}
+	if !cj.paused {
+		return ErrJobNotPaused
+	}
+	if err := cj.schedule(); err != nil {
+		return errors.Wrapf(err, "failed to resume cronjob %q", name)
+	}
+	cj.paused = false
+	log.Infoz("resumed cronjob", zap.String("name", cj.name), zap.String("spec", cj.spec))
+	return nil
+}
+
+// lookup returns the registered cronjob with the name, the caller must hold mu.
+func lookup(name string) *cronjob {
+	for _, cj := range registered {
+		if cj.name == name {
+			return cj
+		}
+	}
+	return nil
+}
+
+// EventType is the type of Event.
+type EventType string
+
+const (
+	// EventStarted is published when a run starts on this instance.
+	EventStarted EventType = "started"
+	// EventFinished is published when a run finishes or is skipped on this instance.
+	EventFinished EventType = "finished"
+)
+
+// Event is a run event of a cronjob on this instance.
+type Event struct {
+	Type EventType        `json:"type"`
+	Run  modelcronjob.Run `json:"run"`
+}
+
+// eventBuffer is the buffer of every event subscriber, events are dropped for slow subscribers.
+const eventBuffer = 64
+
+var (
+	subscribers   = make(map[chan Event]struct{})
+	subscribersMu sync.RWMutex
+)
+
+// Subscribe returns the run events of the cronjobs on this instance.
+// Events are dropped if the channel is full, cancel must be called to unsubscribe.
+func Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBuffer)
+	subscribersMu.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			delete(subscribers, ch)
+			subscribersMu.Unlock()
+		})
+	}
+}
+
+func publish(event Event) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// controlChannel is the redis channel propagating Pause and Resume to the other instances.
+const controlChannel = "gst:cronjob:control"
+
+type controlOp string
+
+const (
+	controlPause  controlOp = "pause"
+	controlResume controlOp = "resume"
+)
+
+type controlMessage struct {
+	Op   controlOp `json:"op"`
+	Name string    `json:"name"`
+	Node string    `json:"node"`
+}
+
+func broadcast(op controlOp, name string) {
+	if !config.App.Redis.Enable {
+		return
+	}
+	data, err := json.Marshal(controlMessage{Op: op, Name: name, Node: node})
+	if err != nil {
+		return
+	}
+	if err = redis.Publish(controlChannel, data); err != nil {
+		log.Errorz(fmt.Sprintf("failed to propagate cronjob %s: %s", op, err), zap.String("name", name))
+	}
+}
+
+// subscribeControl applies the Pause and Resume of the other instances.
+func subscribeControl() {
+	if !config.App.Redis.Enable {
+		return
+	}
+	if err := redis.Subscribe(context.Background(), controlChannel, func(payload []byte) {
+		var msg controlMessage
+		if err := json.Unmarshal(payload, &msg); err != nil || msg.Node == node {
+			return
+		}
+		var err error
+		switch msg.Op {
+		case controlPause:
+			err = pause(msg.Name)
+		case controlResume:
+			err = resume(msg.Name)
+		}
+		// The cronjob may not be registered on this instance, or already be in the state.
+		if err != nil && !errors.Is(err, ErrJobNotFound) && !errors.Is(err, ErrJobPaused) && !errors.Is(err, ErrJobNotPaused) {
+			log.Errorz(fmt.Sprintf("failed to apply cronjob %s: %s", msg.Op, err), zap.String("name", msg.Name))
+		}
+	}); err != nil {
+		log.Errorz(fmt.Sprintf("failed to subscribe cronjob control channel: %s", err))
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
-	"github.com/forbearing/gst/cache"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/logger"
 	"github.com/forbearing/gst/types"
@@ -74,7 +73,7 @@ type database[M types.Model] struct {
 
 	// options
 	enablePurge *bool  // delete resource permanently, not only update deleted_at field, only works on 'Delete' method.
-	enableCache bool   // using cache or not, only works 'List', 'Get', 'Count' method.
+	enableCache bool   // using cache or not, only works 'List', 'Get', 'Count', 'First', 'Last', 'Take' method.
 	tableName   string // support multiple custom table name, always used with the `WithDB` method.
 	batchSize   int    // batch size for bulk operations. affects Create, Update, Delete.
 	noHook      bool   // disable model hook.
@@ -1449,7 +1448,7 @@ func (db *database[M]) WithPagination(page, size int) types.Database[M] {
 // Example:
 //
 //	WithLimit(10)  // Return at most 10 records
-//	WithLimit(100).WithOffset(20)  // Pagination: skip 20, take 100
+//	WithLimit(100).WithOffset(20)  // Pagination: skip 20, take 100, see WithOffset
 //	WithLimit(0)   // Returns all records (unlimited)
 //
 // Note: WithLimit only affects SELECT queries (List, Get, First, Last, etc.).
@@ -1465,6 +1464,27 @@ func (db *database[M]) WithLimit(limit int) types.Database[M] {
 	return db
 }
 
+// WithOffset adds OFFSET clause to skip the first offset records.
+// Unlike WithPagination, the offset is not required to be a multiple of the page size.
+//
+// Parameters:
+//   - offset: Number of records to skip. If offset <= 0, no record is skipped.
+//
+// Example:
+//
+//	WithOrder("id asc").WithOffset(20).WithLimit(100)  // skip 20, take 100
+//
+// Note: WithOffset should be used with WithOrder, otherwise the skipped records are not deterministic.
+func (db *database[M]) WithOffset(offset int) types.Database[M] {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if offset <= 0 {
+		return db
+	}
+	db.ins = db.ins.Offset(offset)
+	return db
+}
+
 // WithExpand enables eager loading of specified associations.
 // Preloads related data to avoid N+1 query problems.
 // It uses GORM's Preload functionality to load associated data in a single query.
@@ -1641,9 +1661,13 @@ func (db *database[M]) WithPurge(enable ...bool) types.Database[M] {
 //
 // Behavior:
 //   - When enabled, query results are cached and subsequent identical queries return cached data
-//   - Cache is automatically cleared on Create, Update, Delete operations
+//   - Cache keys are derived from the normalized statement, including its values and expands
+//   - Concurrent misses of the same query share one database query
+//   - Create, Update, UpdateByID and Delete invalidate the cached queries of the table and
+//     the cached Get of the written records, whether WithCache is enabled or not
 //   - Only affects query operations (List, Get, Count, First, Last, Take)
-//   - Does not affect Create, Update, Delete operations (they clear cache instead)
+//   - Results are stored in the local cache or redis and invalidations are broadcast to the
+//     other replicas, see config.Cache.QueryBackend and config.Cache.QueryBroadcast
 //
 // Example:
 //
@@ -1775,12 +1799,10 @@ func (db *database[M]) Create(_objs ...M) (err error) {
 	if err = db.prepare(); err != nil {
 		return err
 	}
-	done, ctx, span := db.trace("Create", len(objs))
+	done, _, span := db.trace("Create", len(objs))
 	defer done(err)
 
-	if db.enableCache {
-		defer cache.Cache[[]M]().WithContext(ctx).Clear()
-	}
+	defer func() { db.invalidateCache(modelIDs(objs)...) }()
 	// if config.App.RedisConfig.Enable {
 	// 	defer func() {
 	// 		go func() {
@@ -1836,11 +1858,6 @@ func (db *database[M]) Create(_objs ...M) (err error) {
 			return err
 		}
 	}
-	if db.enableCache {
-		for i := range objs {
-			_ = cache.Cache[M]().WithContext(ctx).Delete(objs[i].GetID())
-		}
-	}
 
 	// // because db.db.Delete method just update field "delete_at" to current time,
 	// // not really delete it(soft delete).
@@ -1926,12 +1943,10 @@ func (db *database[M]) Delete(_objs ...M) (err error) {
 	if err = db.prepare(); err != nil {
 		return err
 	}
-	done, ctx, span := db.trace("Delete", len(objs))
+	done, _, span := db.trace("Delete", len(objs))
 	defer done(err)
 
-	if db.enableCache {
-		defer cache.Cache[[]M]().WithContext(ctx).Clear()
-	}
+	defer func() { db.invalidateCache(modelIDs(objs)...) }()
 	// if config.App.RedisConfig.Enable {
 	// 	defer func() {
 	// 		// TODO:only delete cache of all list statement and cache for current get statements.
@@ -1979,11 +1994,6 @@ func (db *database[M]) Delete(_objs ...M) (err error) {
 			if err = db.ins.Session(&gorm.Session{DryRun: db.dryRun}).Table(tableName).Unscoped().Delete(objs[i:end]).Error; err != nil {
 				return err
 			}
-			if db.enableCache {
-				for j := i; j < end; j++ {
-					_ = cache.Cache[M]().WithContext(ctx).Delete(objs[j].GetID())
-				}
-			}
 		}
 	} else {
 		// Delete() method just update field "delete_at" to currrent time.
@@ -2002,11 +2012,6 @@ func (db *database[M]) Delete(_objs ...M) (err error) {
 			if err = db.ins.Session(&gorm.Session{DryRun: db.dryRun}).Table(tableName).Delete(objs[i:end]).Error; err != nil {
 				return err
 			}
-			if db.enableCache {
-				for j := i; j < end; j++ {
-					_ = cache.Cache[M]().WithContext(ctx).Delete(objs[j].GetID())
-				}
-			}
 		}
 	}
 	// Invoke model hook: DeleteAfter.
@@ -2069,12 +2074,10 @@ func (db *database[M]) Update(_objs ...M) (err error) {
 	if err = db.prepare(); err != nil {
 		return err
 	}
-	done, ctx, span := db.trace("Update", len(objs))
+	done, _, span := db.trace("Update", len(objs))
 	defer done(err)
 
-	if db.enableCache {
-		defer cache.Cache[[]M]().WithContext(ctx).Clear()
-	}
+	defer func() { db.invalidateCache(modelIDs(objs)...) }()
 	// if config.App.RedisConfig.Enable {
 	// 	defer func() {
 	// 		go func() {
@@ -2129,11 +2132,6 @@ func (db *database[M]) Update(_objs ...M) (err error) {
 			zap.S().Error(err)
 			return err
 		}
-		if db.enableCache {
-			for j := i; j < end; j++ {
-				_ = cache.Cache[M]().WithContext(ctx).Delete(objs[j].GetID())
-			}
-		}
 	}
 	// Invoke model hook: UpdateAfter.
 	if !db.noHook {
@@ -2188,12 +2186,10 @@ func (db *database[M]) UpdateByID(id string, name string, value any) (err error)
 	if err = db.prepare(); err != nil {
 		return err
 	}
-	done, ctx, _ := db.trace("UpdateById")
+	done, _, _ := db.trace("UpdateById")
 	defer done(err)
 
-	if db.enableCache {
-		defer cache.Cache[[]M]().WithContext(ctx).Clear()
-	}
+	defer db.invalidateCache(id)
 	// if config.App.RedisConfig.Enable {
 	// 	defer func() {
 	// 		go func() {
@@ -2215,9 +2211,6 @@ func (db *database[M]) UpdateByID(id string, name string, value any) (err error)
 	if err = db.ins.Session(&gorm.Session{DryRun: db.dryRun}).Table(tableName).Model(*new(M)).Where("id = ?", id).Update(name, value).Error; err != nil {
 		return err
 	}
-	if db.enableCache {
-		_ = cache.Cache[M]().WithContext(ctx).Delete(id)
-	}
 	return nil
 }
 
@@ -2254,23 +2247,22 @@ func (db *database[M]) List(dest *[]M) (err error) {
 	}
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	// set selected columns.
 	if db.selectRaw != nil {
 		db.ins = db.ins.Select(db.selectRaw, db.selectRawArgs...)
 	} else if len(db.selectColumns) > 0 {
 		db.ins = db.ins.Select(db.selectColumns)
 	}
+	// apply cursor-based pagination.
+	db.applyCursorPagination()
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Find(dest).Statement, "list")
-	if _dest, e := cache.Cache[[]M]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("list", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("list", db.typ.Name()).Inc()
-		*dest = _dest
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).Find(dest).Statement, table, "list")
+	if _dest, ok := cacheGet[[]M](ctx, "list", table, key); ok {
+		*dest = slices.Clone(_dest)
 		logger.Cache.Infow("list from cache", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 		return nil
 	}
@@ -2361,46 +2353,48 @@ QUERY:
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	// apply cursor-based pagination.
-	db.applyCursorPagination()
-	if err = db.ins.Table(tableName).Find(dest).Error; err != nil {
-		return err
-	}
-	// If cursor-based pagination is enabled and this is a previous page query,
-	// reverse the list to mantain the original sort order.
-	if db.enableCursor && !db.cursorNext {
-		slices.Reverse(*dest)
-	}
+	query := func(dest *[]M) error {
+		if err := db.ins.Table(tableName).Find(dest).Error; err != nil {
+			return err
+		}
+		// If cursor-based pagination is enabled and this is a previous page query,
+		// reverse the list to mantain the original sort order.
+		if db.enableCursor && !db.cursorNext {
+			slices.Reverse(*dest)
+		}
 
-	// Invoke model hook: ListAfter()
-	if !db.noHook {
-		if err = traceModelHook[M](db.ctx, consts.PHASE_LIST_AFTER, span, func(spanCtx context.Context) error {
-			for i := range *dest {
-				if !reflect.DeepEqual(empty, (*dest)[i]) {
-					if err = (*dest)[i].ListAfter(types.NewModelContext(spanCtx, db.ctx)); err != nil {
-						return err
+		// Invoke model hook: ListAfter()
+		if !db.noHook {
+			return traceModelHook[M](db.ctx, consts.PHASE_LIST_AFTER, span, func(spanCtx context.Context) error {
+				for i := range *dest {
+					if !reflect.DeepEqual(empty, (*dest)[i]) {
+						if err := (*dest)[i].ListAfter(types.NewModelContext(spanCtx, db.ctx)); err != nil {
+							return err
+						}
 					}
 				}
-			}
-			return nil
-		}); err != nil {
-			return err
+				return nil
+			})
 		}
+		return nil
 	}
-	// Cache the result.
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("list from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.SetML[M](key, *dest); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("list from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[[]M]().WithContext(ctx).Set(key, *dest, config.App.Cache.Expiration)
+	if !db.enableCache {
+		return query(dest)
+	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	var result []M
+	if result, err = cacheLoad(key, func() ([]M, error) {
+		result := make([]M, 0)
+		if err := query(&result); err != nil {
+			return nil, err
+		}
+		cacheSet(ctx, key, result, tableTag(table))
+		return result, nil
+	}); err != nil {
+		return err
 	}
-
+	*dest = slices.Clone(result)
+	logger.Cache.Infow("list from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -2448,7 +2442,7 @@ func (db *database[M]) Get(dest M, id string) (err error) {
 	defer done(err)
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	// set selected columns.
 	if db.selectRaw != nil {
 		db.ins = db.ins.Select(db.selectRaw, db.selectRawArgs...)
@@ -2458,12 +2452,9 @@ func (db *database[M]) Get(dest M, id string) (err error) {
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Where("id = ?", id).Find(dest).Statement, "get", id)
-	if _dest, e := cache.Cache[M]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("get", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("get", db.typ.Name()).Inc()
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).Where("id = ?", id).Find(dest).Statement, table, "get")
+	if _dest, ok := cacheGet[M](ctx, "get", table, key); ok {
 		val := reflect.ValueOf(dest)
 		if val.Kind() != reflect.Pointer {
 			return ErrNotPtrStruct
@@ -2473,7 +2464,7 @@ func (db *database[M]) Get(dest M, id string) (err error) {
 		}
 		val.Elem().Set(reflect.ValueOf(_dest).Elem()) // the type of M is pointer to struct.
 		logger.Cache.Infow("get from cache", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		return nil
+		return nil // Found cache and return.
 	}
 
 	// =============================
@@ -2564,33 +2555,37 @@ QUERY:
 	// 	return err
 	// }
 	if len(tableName) == 0 {
-		_, tableName, _ = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Where("id = ?", id).Find(dest).Statement, "get", id)
+		tableName = modelTable(db.m)
 	}
-	dest.ClearID()
-	if err = db.ins.Table(tableName).Where(fmt.Sprintf("%s = ?", db.quoteTableColumn(tableName, "id")), id).Find(dest).Error; err != nil {
-		return err
-	}
-	// Invoke model hook: GetAfter.
-	if !db.noHook && !reflect.DeepEqual(empty, dest) {
-		if err = traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
-			return dest.GetAfter(types.NewModelContext(spanCtx, db.ctx))
-		}); err != nil {
+	query := func(m M) error {
+		m.ClearID()
+		if err := db.ins.Table(tableName).Where(fmt.Sprintf("%s = ?", db.quoteTableColumn(tableName, "id")), id).Find(m).Error; err != nil {
 			return err
 		}
+		// Invoke model hook: GetAfter.
+		if !db.noHook && !reflect.DeepEqual(empty, m) {
+			return traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
+				return m.GetAfter(types.NewModelContext(spanCtx, db.ctx))
+			})
+		}
+		return nil
 	}
-	// // Cache the result.
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("get from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.SetM[M](key, dest); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("get from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[M]().WithContext(ctx).Set(key, dest, config.App.Cache.Expiration)
+	if !db.enableCache {
+		return query(dest)
+	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	var result M
+	if result, err = cacheLoad(key, func() (M, error) {
+		if err := query(dest); err != nil {
+			return dest, err
+		}
+		cacheSet(ctx, key, cloneModel(dest), recordTag(table, id), recordsTag(table))
+		return dest, nil
+	}); err != nil {
+		return err
 	}
+	copyModel(dest, result)
+	logger.Cache.Infow("get from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -2630,19 +2625,16 @@ func (db *database[M]) Count(count *int64) (err error) {
 	defer done(err)
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Model(*new(M)).Count(count).Statement, "count")
-	if _cache, e := cache.Cache[int64]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("count", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("count", db.typ.Name()).Inc()
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).Model(*new(M)).Count(count).Statement, table, "count")
+	if _cache, ok := cacheGet[int64](ctx, "count", table, key); ok {
 		*count = _cache
 		logger.Cache.Infow("count from cache", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		return err
+		return nil
 	}
 
 	// =============================
@@ -2682,24 +2674,28 @@ QUERY:
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if err = db.ins.Table(tableName).Model(*new(M)).Limit(-1).Count(count).Error; err != nil {
-		logger.Cache.Error(err)
-		return err
+	query := func(count *int64) error {
+		if err := db.ins.Table(tableName).Model(*new(M)).Limit(-1).Count(count).Error; err != nil {
+			logger.Cache.Error(err)
+			return err
+		}
+		return nil
 	}
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("count from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.Set(key, *count); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-	//
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("count from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[int64]().WithContext(ctx).Set(key, *count, config.App.Cache.Expiration)
-
+	if !db.enableCache {
+		return query(count)
 	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	if *count, err = cacheLoad(key, func() (int64, error) {
+		var result int64
+		if err := query(&result); err != nil {
+			return 0, err
+		}
+		cacheSet(ctx, key, result, tableTag(table))
+		return result, nil
+	}); err != nil {
+		return err
+	}
+	logger.Cache.Infow("count from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -2740,7 +2736,7 @@ func (db *database[M]) First(dest M) (err error) {
 	defer done(err)
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	// set selected columns.
 	if db.selectRaw != nil {
 		db.ins = db.ins.Select(db.selectRaw, db.selectRawArgs...)
@@ -2750,12 +2746,9 @@ func (db *database[M]) First(dest M) (err error) {
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).First(dest).Statement, "first")
-	if _dest, e := cache.Cache[M]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("first", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("first", db.typ.Name()).Inc()
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).First(dest).Statement, table, "first")
+	if _dest, ok := cacheGet[M](ctx, "first", table, key); ok {
 		val := reflect.ValueOf(dest)
 		if val.Kind() != reflect.Pointer {
 			return ErrNotPtrStruct
@@ -2841,30 +2834,34 @@ QUERY:
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if err = db.ins.Table(tableName).First(dest).Error; err != nil {
-		return err
-	}
-	// Invoke model hook: GetAfter
-	if !db.noHook && !reflect.DeepEqual(empty, dest) {
-		if err = traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
-			return dest.GetAfter(types.NewModelContext(spanCtx, db.ctx))
-		}); err != nil {
+	query := func(m M) error {
+		if err := db.ins.Table(tableName).First(m).Error; err != nil {
 			return err
 		}
+		// Invoke model hook: GetAfter.
+		if !db.noHook && !reflect.DeepEqual(empty, m) {
+			return traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
+				return m.GetAfter(types.NewModelContext(spanCtx, db.ctx))
+			})
+		}
+		return nil
 	}
-	// // Cache the result.
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("first from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.SetM[M](key, dest); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("first from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[M]().WithContext(ctx).Set(key, dest, config.App.Cache.Expiration)
+	if !db.enableCache {
+		return query(dest)
+	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	var result M
+	if result, err = cacheLoad(key, func() (M, error) {
+		if err := query(dest); err != nil {
+			return dest, err
+		}
+		cacheSet(ctx, key, cloneModel(dest), tableTag(table))
+		return dest, nil
+	}); err != nil {
+		return err
 	}
+	copyModel(dest, result)
+	logger.Cache.Infow("first from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -2906,7 +2903,7 @@ func (db *database[M]) Last(dest M) (err error) {
 	defer done(err)
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	// set selected columns.
 	if db.selectRaw != nil {
 		db.ins = db.ins.Select(db.selectRaw, db.selectRawArgs...)
@@ -2916,12 +2913,9 @@ func (db *database[M]) Last(dest M) (err error) {
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Last(dest).Statement, "last")
-	if _dest, e := cache.Cache[M]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("last", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("last", db.typ.Name()).Inc()
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).Last(dest).Statement, table, "last")
+	if _dest, ok := cacheGet[M](ctx, "last", table, key); ok {
 		val := reflect.ValueOf(dest)
 		if val.Kind() != reflect.Pointer {
 			return ErrNotPtrStruct
@@ -3007,30 +3001,34 @@ QUERY:
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if err = db.ins.Table(tableName).Last(dest).Error; err != nil {
-		return err
-	}
-	// Invoke model hook: GetAfter
-	if !db.noHook && !reflect.DeepEqual(empty, dest) {
-		if err = traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
-			return dest.GetAfter(types.NewModelContext(spanCtx, db.ctx))
-		}); err != nil {
+	query := func(m M) error {
+		if err := db.ins.Table(tableName).Last(m).Error; err != nil {
 			return err
 		}
+		// Invoke model hook: GetAfter.
+		if !db.noHook && !reflect.DeepEqual(empty, m) {
+			return traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
+				return m.GetAfter(types.NewModelContext(spanCtx, db.ctx))
+			})
+		}
+		return nil
 	}
-	// // Cache the result.
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("last from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.SetM[M](key, dest); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("last from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[M]().WithContext(ctx).Set(key, dest, config.App.Cache.Expiration)
+	if !db.enableCache {
+		return query(dest)
+	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	var result M
+	if result, err = cacheLoad(key, func() (M, error) {
+		if err := query(dest); err != nil {
+			return dest, err
+		}
+		cacheSet(ctx, key, cloneModel(dest), tableTag(table))
+		return dest, nil
+	}); err != nil {
+		return err
 	}
+	copyModel(dest, result)
+	logger.Cache.Infow("last from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -3072,7 +3070,7 @@ func (db *database[M]) Take(dest M) (err error) {
 	defer done(err)
 
 	begin := time.Now()
-	var key string
+	var key, table string
 	// set selected columns.
 	if db.selectRaw != nil {
 		db.ins = db.ins.Select(db.selectRaw, db.selectRawArgs...)
@@ -3082,12 +3080,9 @@ func (db *database[M]) Take(dest M) (err error) {
 	if !db.enableCache {
 		goto QUERY
 	}
-	_, _, key = buildCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).First(dest).Statement, "take")
-	if _dest, e := cache.Cache[M]().WithContext(ctx).Get(key); e != nil {
-		// metrics.CacheMiss.WithLabelValues("take", db.typ.Name()).Inc()
-		goto QUERY
-	} else {
-		// metrics.CacheHit.WithLabelValues("take", db.typ.Name()).Inc()
+	table = db.cacheTable()
+	key = queryCacheKey(db.ins.Session(&gorm.Session{DryRun: true, Logger: glogger.Default.LogMode(glogger.Silent)}).Table(table).Take(dest).Statement, table, "take")
+	if _dest, ok := cacheGet[M](ctx, "take", table, key); ok {
 		val := reflect.ValueOf(dest)
 		if val.Kind() != reflect.Pointer {
 			return ErrNotPtrStruct
@@ -3173,32 +3168,34 @@ QUERY:
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
-	if err = db.ins.Table(tableName).Take(dest).Error; err != nil {
-		return err
-	}
-	// Invoke model hook: GetAfter.
-	if !db.noHook && !reflect.DeepEqual(empty, dest) {
-		if err = traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
-			return dest.GetAfter(types.NewModelContext(spanCtx, db.ctx))
-		}); err != nil {
+	query := func(m M) error {
+		if err := db.ins.Table(tableName).Take(m).Error; err != nil {
 			return err
 		}
+		// Invoke model hook: GetAfter.
+		if !db.noHook && !reflect.DeepEqual(empty, m) {
+			return traceModelHook[M](db.ctx, consts.PHASE_GET_AFTER, span, func(spanCtx context.Context) error {
+				return m.GetAfter(types.NewModelContext(spanCtx, db.ctx))
+			})
+		}
+		return nil
 	}
-	// // Cache the result.
-	// if db.enableCache && config.App.RedisConfig.Enable {
-	// 	logger.Cache.Infow("take from database", "cost", time.Since(begin).String(), "key", key)
-	// 	go func() {
-	// 		if err = redis.SetM[M](key, dest); err != nil {
-	// 			logger.Cache.Error(err)
-	// 		}
-	// 	}()
-
-	//
-	// }
-	if db.enableCache {
-		logger.Cache.Infow("take from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
-		_ = cache.Cache[M]().WithContext(ctx).Set(key, dest, config.App.Cache.Expiration)
+	if !db.enableCache {
+		return query(dest)
 	}
+	// Concurrent misses of the key share one query, only the first caller caches the result.
+	var result M
+	if result, err = cacheLoad(key, func() (M, error) {
+		if err := query(dest); err != nil {
+			return dest, err
+		}
+		cacheSet(ctx, key, cloneModel(dest), tableTag(table))
+		return dest, nil
+	}); err != nil {
+		return err
+	}
+	copyModel(dest, result)
+	logger.Cache.Infow("take from database", "cost", util.FormatDurationSmart(time.Since(begin)), "key", key)
 	return nil
 }
 
@@ -3234,6 +3231,12 @@ func (db *database[M]) Cleanup() (err error) {
 	if len(db.tableName) > 0 {
 		tableName = db.tableName
 	}
+	defer func() {
+		if err == nil {
+			table := db.cacheTable()
+			db.invalidateTags(tableTag(table), recordsTag(table))
+		}
+	}()
 	return db.ins.Session(&gorm.Session{DryRun: db.dryRun}).Table(tableName).Limit(-1).Where("deleted_at IS NOT NULL").Model(*new(M)).Unscoped().Delete(make([]M, 0)).Error
 }
 
@@ -3388,7 +3391,12 @@ func (db *database[M]) Transaction(fn func(txDB types.Database[M]) error) error
 
 	begin := time.Now()
 
-	return db.ins.Transaction(func(tx *gorm.DB) error {
+	var pool gorm.ConnPool
+	err := db.ins.Transaction(func(tx *gorm.DB) error {
+		if trackTx(tx.Statement.ConnPool) {
+			pool = tx.Statement.ConnPool
+		}
+
 		// Create a new database instance with transaction context
 		txDB := Database[M](db.ctx).WithTx(tx)
 
@@ -3417,6 +3425,11 @@ func (db *database[M]) Transaction(fn func(txDB types.Database[M]) error) error
 		)
 		return nil
 	})
+	if pool != nil {
+		// invalidate the query cache after commit
+		endTx(pool, err == nil)
+	}
+	return err
 }
 
 // TransactionFunc executes a function within a complete transaction with automatic management.
@@ -3523,7 +3536,12 @@ func (db *database[M]) TransactionFunc(fn func(tx any) error) error {
 
 	begin := time.Now()
 
-	return db.ins.Transaction(func(tx *gorm.DB) error {
+	var pool gorm.ConnPool
+	err := db.ins.Transaction(func(tx *gorm.DB) error {
+		if trackTx(tx.Statement.ConnPool) {
+			pool = tx.Statement.ConnPool
+		}
+
 		// Execute the user function with the transaction gorm.DB instance
 		if err := fn(tx); err != nil {
 			// Execute custom rollback logic if provided
@@ -3544,6 +3562,11 @@ func (db *database[M]) TransactionFunc(fn func(tx any) error) error {
 		)
 		return nil
 	})
+	if pool != nil {
+		// invalidate the query cache after commit
+		endTx(pool, err == nil)
+	}
+	return err
 }
 
 // Database creates and returns a generic database manipulator implementing types.Database interface.
@@ -4397,6 +4397,26 @@ func TestDatabaseWithLimit(t *testing.T) {
 		require.Equal(t, users1[1].ID, users2[1].ID)
 		require.Equal(t, users1[2].ID, users2[2].ID)
 	})
+
+	t.Run("WithOffset", func(t *testing.T) {
+		defer cleanupTestData()
+		testUsers, testIDs := newSeqUsers("test", 10)
+		require.NoError(t, database.Database[*TestUser](nil).Create(testUsers...))
+
+		// The offset is not a multiple of the limit.
+		users := make([]*TestUser, 0)
+		require.NoError(t, database.Database[*TestUser](nil).WithOrder("id asc").WithOffset(4).WithLimit(3).List(&users))
+		assertIDs(t, users, testIDs[4:7])
+
+		users = make([]*TestUser, 0)
+		require.NoError(t, database.Database[*TestUser](nil).WithOrder("id asc").WithOffset(8).WithLimit(5).List(&users))
+		assertIDs(t, users, testIDs[8:])
+
+		// Non-positive offset skips nothing.
+		users = make([]*TestUser, 0)
+		require.NoError(t, database.Database[*TestUser](nil).WithOrder("id asc").WithOffset(-1).WithLimit(2).List(&users))
+		assertIDs(t, users, testIDs[0:2])
+	})
 }
 
 func TestDatabaseWithExpand(t *testing.T) {
@@ -5138,6 +5158,41 @@ func TestDatabaseWithCache(t *testing.T) {
 		require.NotNil(t, uu3)
 		require.True(t, reflect.DeepEqual(uu1, uu3), "results should be identical")
 	})
+
+	t.Run("InvalidatedByWrite", func(t *testing.T) {
+		defer cleanupTestData()
+		setupTestData(t)
+
+		// Cache the list, the count and the get of u1.
+		users := make([]*TestUser, 0)
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().WithQuery(&TestUser{Name: u1.Name}).List(&users))
+		require.Len(t, users, 1)
+		count := new(int64)
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().Count(count))
+		require.Equal(t, int64(3), *count)
+		uu1 := new(TestUser)
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().Get(uu1, u1.ID))
+		require.Equal(t, u1.Age, uu1.Age)
+
+		// Different values of the same statement are cached separately.
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().WithQuery(&TestUser{Name: u2.Name}).List(&users))
+		require.Len(t, users, 1)
+		require.Equal(t, u2.ID, users[0].ID)
+
+		// Writes without cache invalidate the cached queries.
+		require.NoError(t, database.Database[*TestUser](nil).UpdateByID(u1.ID, "age", 30))
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().Get(uu1, u1.ID))
+		require.Equal(t, 30, uu1.Age)
+		require.NoError(t, database.Database[*TestUser](nil).Delete(u3))
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().Count(count))
+		require.Equal(t, int64(2), *count)
+
+		// Raw writes are invalidated explicitly.
+		require.NoError(t, database.DB.Exec("UPDATE test_users SET age = ? WHERE id = ?", 31, u1.ID).Error)
+		database.InvalidateCache[*TestUser](u1.ID)
+		require.NoError(t, database.Database[*TestUser](nil).WithCache().Get(uu1, u1.ID))
+		require.Equal(t, 31, uu1.Age)
+	})
 }
 
 func TestDatabaseWithOmit(t *testing.T) {
@@ -2,8 +2,12 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -331,44 +335,44 @@ func structFieldToMap(ctx *types.DatabaseContext, typ reflect.Type, val reflect.
 // 	return &database[*model.User]{db: DB.WithContext(c).Limit(defaultLimit)}
 // }
 
-// buildCacheKey constructs Redis cache keys for database operations.
-// Generates both prefix and full key based on GORM statement and operation type.
-// Uses consistent naming convention for cache key organization and collision avoidance.
+// queryCacheKey constructs the query cache key of a dry run GORM statement.
+// Equivalent statements share the key, whatever the values of the statement are.
 //
 // Parameters:
-//   - stmt: GORM statement containing SQL and table information
+//   - stmt: Dry run GORM statement containing SQL, variables and preloads
+//   - table: Table name of the cached query, it honors WithTable
 //   - action: Operation type ("get", "list", "count", etc.)
-//   - id: Optional ID for get operations to create simpler keys
-//
-// Returns prefix, table name and full cache key for Redis operations.
 //
 // Key Structure:
-//   - Prefix: namespace:table_name
-//   - Full Key: namespace:table_name:action:identifier
-//   - Get operations with ID: namespace:table_name:get:id_value
-//   - Other operations: namespace:table_name:action:sql_statement
+//   - namespace:query:table_name:action:sha256(statement)
 //
 // Features:
-//   - Namespace isolation for multi-tenant applications
-//   - Table-based key organization
-//   - Operation-specific key generation
-//   - SQL statement-based cache invalidation
+//   - Whitespace of the SQL statement is normalized
+//   - Statement variables and preloads (WithExpand) are part of the key
+//   - Fixed key length whatever the size of the statement
 //
 // Reference: https://gorm.io/docs/sql_builder.html
-func buildCacheKey(stmt *gorm.Statement, action string, id ...string) (prefix, table, key string) {
-	prefix = strings.Join([]string{config.App.Redis.Namespace, stmt.Table}, ":")
-	table = stmt.Table
-	switch strings.ToLower(action) {
-	case "get":
-		if len(id) > 0 {
-			key = strings.Join([]string{config.App.Redis.Namespace, stmt.Table, action, id[0]}, ":")
+func queryCacheKey(stmt *gorm.Statement, table, action string) string {
+	h := sha256.New()
+	h.Write([]byte(strings.Join(strings.Fields(stmt.SQL.String()), " ")))
+	for _, v := range stmt.Vars {
+		h.Write([]byte{0})
+		if data, err := json.Marshal(v); err == nil {
+			h.Write(data)
 		} else {
-			key = strings.Join([]string{config.App.Redis.Namespace, stmt.Table, action, stmt.SQL.String()}, ":")
+			fmt.Fprintf(h, "%v", v)
 		}
-	default:
-		key = strings.Join([]string{config.App.Redis.Namespace, stmt.Table, action, stmt.SQL.String()}, ":")
 	}
-	return prefix, table, key
+	preloads := make([]string, 0, len(stmt.Preloads))
+	for name := range stmt.Preloads {
+		preloads = append(preloads, name)
+	}
+	slices.Sort(preloads)
+	for _, name := range preloads {
+		h.Write([]byte{1})
+		h.Write([]byte(name))
+	}
+	return strings.Join([]string{config.App.Redis.Namespace, "query", table, action, hex.EncodeToString(h.Sum(nil))}, ":")
 }
 
 // boolToInt converts a boolean value to an integer.
@@ -0,0 +1,454 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cache"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/logger"
+	prommetrics "github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/provider/nats"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	gonats "github.com/nats-io/nats.go"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// Query cache
+//
+// The results of List, Get, Count, First, Last and Take with WithCache are cached
+// under a key derived from the normalized statement and its variables, and tagged with:
+//   - the table tag: every query of the table except Get
+//   - the record tag: the Get of the record id
+//
+// Create, Update, UpdateByID and Delete invalidate the table tag and the record tags
+// of the written records, whether WithCache is enabled or not, Cleanup invalidates the
+// whole table. Invalidations are applied to the local cache, the shared Redis backend
+// and broadcast to the other replicas. Inside Transaction and TransactionFunc they are
+// applied after commit and dropped on rollback.
+// Concurrent misses of the same key are coalesced into one database query.
+
+var (
+	queryFlight singleflight.Group
+	queryIndex  = newTagIndex()
+	queryNode   = util.UUID()
+
+	queryRedis  atomic.Bool // query results are stored in redis
+	queryMu     sync.Mutex
+	queryCancel context.CancelFunc
+	queryBus    func(payload []byte) error // publishes the invalidations to the other replicas
+)
+
+// queryInvalidation is the invalidation message broadcast to the other replicas.
+type queryInvalidation struct {
+	Node string   `json:"node"`
+	Tags []string `json:"tags"`
+}
+
+// tableTag is the tag of all the cached queries of the table except Get.
+func tableTag(table string) string { return "table:" + table }
+
+// recordTag is the tag of the cached Get of the record.
+func recordTag(table, id string) string { return "record:" + table + ":" + id }
+
+// recordsTag is the tag of the cached Get of all the records of the table.
+func recordsTag(table string) string { return "record:" + table }
+
+// InitQueryCache initializes the query cache backend and subscribes to the invalidations
+// of the other replicas, it must be called after the redis and nats providers are initialized.
+func InitQueryCache() error {
+	cfg := config.App.Cache
+	switch cfg.QueryBackend {
+	case "", config.QueryCacheLocal:
+		queryRedis.Store(false)
+	case config.QueryCacheRedis:
+		if !config.App.Redis.Enable {
+			return errors.New("query cache backend redis requires redis to be enabled")
+		}
+		queryRedis.Store(true)
+	default:
+		return errors.Newf("unknown query cache backend %q", cfg.QueryBackend)
+	}
+
+	queryMu.Lock()
+	defer queryMu.Unlock()
+	if queryCancel != nil {
+		queryCancel()
+		queryCancel, queryBus = nil, nil
+	}
+
+	channel := cfg.QueryChannel
+	if len(channel) == 0 {
+		channel = "gst.query_cache.invalidate"
+	}
+	switch cfg.QueryBroadcast {
+	case "", config.QueryCacheBroadcastNone:
+		return nil
+	case config.QueryCacheBroadcastRedis:
+		if !config.App.Redis.Enable {
+			return errors.New("query cache broadcast redis requires redis to be enabled")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := redis.Subscribe(ctx, channel, handleQueryInvalidation); err != nil {
+			cancel()
+			return errors.Wrap(err, "failed to subscribe query cache invalidations")
+		}
+		queryCancel = cancel
+		queryBus = func(payload []byte) error { return redis.Publish(channel, payload) }
+	case config.QueryCacheBroadcastNats:
+		conn := nats.Conn()
+		if conn == nil {
+			return errors.New("query cache broadcast nats requires nats to be enabled")
+		}
+		sub, err := conn.Subscribe(channel, func(msg *gonats.Msg) { handleQueryInvalidation(msg.Data) })
+		if err != nil {
+			return errors.Wrap(err, "failed to subscribe query cache invalidations")
+		}
+		queryCancel = func() { _ = sub.Unsubscribe() }
+		queryBus = func(payload []byte) error { return conn.Publish(channel, payload) }
+	default:
+		return errors.Newf("unknown query cache broadcast %q", cfg.QueryBroadcast)
+	}
+	logger.Cache.Infow("query cache invalidations subscribed", "broadcast", cfg.QueryBroadcast, "channel", channel, "node", queryNode)
+	return nil
+}
+
+// CloseQueryCache stops receiving the invalidations of the other replicas.
+func CloseQueryCache() {
+	queryMu.Lock()
+	defer queryMu.Unlock()
+	if queryCancel != nil {
+		queryCancel()
+	}
+	queryCancel, queryBus = nil, nil
+}
+
+// InvalidateCache removes the cached queries of the table of M on every replica, including
+// the Get of the ids, or the Get of all records if ids is empty.
+// Call it after writing the table outside of Database[M], eg: raw SQL or another service.
+func InvalidateCache[M types.Model](ids ...string) {
+	table := modelTable(reflect.New(reflect.TypeFor[M]().Elem()).Interface().(M)) //nolint:errcheck
+	tags := []string{tableTag(table)}
+	if len(ids) == 0 {
+		tags = append(tags, recordsTag(table))
+	}
+	for _, id := range ids {
+		tags = append(tags, recordTag(table, id))
+	}
+	invalidateQueryCache(tags...)
+}
+
+// modelTables caches the table names of the models.
+var modelTables sync.Map
+
+// modelTable returns the table name of the model, GetTableName or the gorm naming strategy.
+func modelTable(m types.Model) string {
+	if name := m.GetTableName(); len(name) > 0 {
+		return name
+	}
+	typ := reflect.TypeOf(m)
+	if name, ok := modelTables.Load(typ); ok {
+		return name.(string) //nolint:errcheck
+	}
+	name := typ.Elem().Name()
+	if DB != nil {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(m); err == nil {
+			name = stmt.Schema.Table
+		}
+	}
+	modelTables.Store(typ, name)
+	return name
+}
+
+// cacheTable returns the table of the cached queries, it honors WithTable.
+func (db *database[M]) cacheTable() string {
+	if len(db.tableName) > 0 {
+		return db.tableName
+	}
+	return modelTable(db.m)
+}
+
+// cacheGet returns the cached query result of the key and records the hit or miss.
+func cacheGet[T any](ctx context.Context, action, table, key string) (T, bool) {
+	var val T
+	var err error
+	if queryRedis.Load() {
+		val, err = redis.Cache[T]().WithContext(ctx).Get(key)
+	} else {
+		val, err = cache.Cache[T]().WithContext(ctx).Get(key)
+	}
+	if err != nil {
+		if prommetrics.CacheMiss != nil {
+			prommetrics.CacheMiss.WithLabelValues(action, table).Inc()
+		}
+		return val, false
+	}
+	if prommetrics.CacheHit != nil {
+		prommetrics.CacheHit.WithLabelValues(action, table).Inc()
+	}
+	return val, true
+}
+
+// cacheSet caches the query result of the key with the tags.
+func cacheSet[T any](ctx context.Context, key string, val T, tags ...string) {
+	ttl := config.App.Cache.Expiration
+	if queryRedis.Load() {
+		if err := redis.Cache[T]().WithContext(ctx).Set(key, val, ttl); err != nil {
+			logger.Cache.Warnw("failed to cache query result", "key", key, "error", err)
+			return
+		}
+		for _, tag := range tags {
+			if err := redis.SAdd(queryTagKey(tag), ttl, key); err != nil {
+				logger.Cache.Warnw("failed to tag query result", "key", key, "tag", tag, "error", err)
+			}
+		}
+		return
+	}
+	_ = cache.Cache[T]().WithContext(ctx).Set(key, val, ttl)
+	queryIndex.add(key, ttl, func() { _ = cache.Cache[T]().Delete(key) }, tags...)
+}
+
+// cloneModel returns a shallow copy of the model, so that the cached result doesn't change with dest.
+func cloneModel[M types.Model](m M) M {
+	val := reflect.New(reflect.TypeOf(m).Elem())
+	val.Elem().Set(reflect.ValueOf(m).Elem())
+	return val.Interface().(M) //nolint:errcheck
+}
+
+// copyModel copies the loaded model into dest unless they are the same.
+func copyModel[M types.Model](dest, src M) {
+	if any(dest) != any(src) {
+		reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(src).Elem())
+	}
+}
+
+// modelIDs returns the ids of the models.
+func modelIDs[M types.Model](objs []M) []string {
+	ids := make([]string, 0, len(objs))
+	for i := range objs {
+		if id := objs[i].GetID(); len(id) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// invalidateCache invalidates the cached queries of the table and the Get of the ids after a write.
+func (db *database[M]) invalidateCache(ids ...string) {
+	table := db.cacheTable()
+	tags := make([]string, 0, len(ids)+1)
+	tags = append(tags, tableTag(table))
+	for _, id := range ids {
+		tags = append(tags, recordTag(table, id))
+	}
+	db.invalidateTags(tags...)
+}
+
+// invalidateTags invalidates the tags now, or after commit if the write is inside Transaction/TransactionFunc.
+func (db *database[M]) invalidateTags(tags ...string) {
+	if db.dryRun {
+		return
+	}
+	if deferTxTags(db.ins.Statement.ConnPool, tags...) {
+		return
+	}
+	invalidateQueryCache(tags...)
+}
+
+// txTags collects the invalidation tags of the writes inside Transaction and TransactionFunc by
+// the connection of the transaction. They are applied after commit, invalidating before commit
+// would let the concurrent readers cache the uncommitted rows until the TTL.
+// The transactions begun outside of them are not tracked, their writes invalidate immediately.
+var (
+	txTagsMu sync.Mutex
+	txTags   = make(map[gorm.ConnPool][]string)
+)
+
+// trackTx starts collecting the tags of the transaction, it returns false if the transaction
+// is already tracked(nested transaction), the outermost one applies the tags.
+func trackTx(pool gorm.ConnPool) bool {
+	txTagsMu.Lock()
+	defer txTagsMu.Unlock()
+	if _, ok := txTags[pool]; ok {
+		return false
+	}
+	txTags[pool] = make([]string, 0)
+	return true
+}
+
+// deferTxTags appends the tags to the tracked transaction, it returns false if pool is not a tracked transaction.
+func deferTxTags(pool gorm.ConnPool, tags ...string) bool {
+	if pool == nil {
+		return false
+	}
+	txTagsMu.Lock()
+	defer txTagsMu.Unlock()
+	pending, ok := txTags[pool]
+	if !ok {
+		return false
+	}
+	txTags[pool] = append(pending, tags...)
+	return true
+}
+
+// endTx stops tracking the transaction and applies the collected tags if it's committed.
+func endTx(pool gorm.ConnPool, committed bool) {
+	txTagsMu.Lock()
+	tags := txTags[pool]
+	delete(txTags, pool)
+	txTagsMu.Unlock()
+	if committed && len(tags) > 0 {
+		invalidateQueryCache(tags...)
+	}
+}
+
+// cacheLoad queries the result of the key once for all the concurrent callers.
+func cacheLoad[T any](key string, load func() (T, error)) (T, error) {
+	val, err, _ := queryFlight.Do(key, func() (any, error) { return load() })
+	if err != nil {
+		return *new(T), err
+	}
+	return val.(T), nil //nolint:errcheck
+}
+
+// invalidateQueryCache removes the cached queries having any of the tags
+// from the local cache and the redis backend, and broadcasts the tags to the other replicas.
+func invalidateQueryCache(tags ...string) {
+	if len(tags) == 0 {
+		return
+	}
+	queryIndex.invalidate(tags...)
+
+	if queryRedis.Load() {
+		for _, tag := range tags {
+			keys, err := redis.SMembers(queryTagKey(tag))
+			if err != nil {
+				logger.Cache.Warnw("failed to list tagged query results", "tag", tag, "error", err)
+				continue
+			}
+			if err = redis.Unlink(append(keys, queryTagKey(tag))...); err != nil {
+				logger.Cache.Warnw("failed to remove tagged query results", "tag", tag, "error", err)
+			}
+		}
+	}
+
+	queryMu.Lock()
+	publish := queryBus
+	queryMu.Unlock()
+	if publish == nil {
+		return
+	}
+	payload, err := json.Marshal(queryInvalidation{Node: queryNode, Tags: tags})
+	if err != nil {
+		logger.Cache.Error(err)
+		return
+	}
+	if err = publish(payload); err != nil {
+		logger.Cache.Warnw("failed to broadcast query cache invalidation", "tags", tags, "error", err)
+	}
+}
+
+// handleQueryInvalidation applies the invalidation broadcast by another replica to the local cache.
+func handleQueryInvalidation(payload []byte) {
+	var msg queryInvalidation
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		logger.Cache.Warnw("invalid query cache invalidation", "error", err)
+		return
+	}
+	if msg.Node == queryNode {
+		return
+	}
+	queryIndex.invalidate(msg.Tags...)
+}
+
+// queryTagKey is the redis set of the cache keys having the tag.
+func queryTagKey(tag string) string {
+	return strings.Join([]string{config.App.Redis.Namespace, "query", "tag", tag}, ":")
+}
+
+// tagIndex indexes the local cache keys by tag.
+type tagIndex struct {
+	mu   sync.Mutex
+	tags map[string]map[string]tagEntry
+	adds int
+}
+
+type tagEntry struct {
+	expiresAt time.Time // zero never expires
+	remove    func()
+}
+
+// tagIndexPruneEvery is the number of additions between two prunes of the expired entries.
+const tagIndexPruneEvery = 1024
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{tags: make(map[string]map[string]tagEntry)}
+}
+
+// add indexes the key by the tags, remove deletes the key from the cache.
+func (ti *tagIndex) add(key string, ttl time.Duration, remove func(), tags ...string) {
+	entry := tagEntry{remove: remove}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	for _, tag := range tags {
+		keys, ok := ti.tags[tag]
+		if !ok {
+			keys = make(map[string]tagEntry)
+			ti.tags[tag] = keys
+		}
+		keys[key] = entry
+	}
+	if ti.adds++; ti.adds%tagIndexPruneEvery == 0 {
+		ti.prune(time.Now())
+	}
+}
+
+// invalidate deletes the keys having any of the tags from the cache.
+func (ti *tagIndex) invalidate(tags ...string) {
+	ti.mu.Lock()
+	removes := make([]func(), 0)
+	for _, tag := range tags {
+		for _, entry := range ti.tags[tag] {
+			removes = append(removes, entry.remove)
+		}
+		delete(ti.tags, tag)
+	}
+	ti.mu.Unlock()
+	for _, remove := range removes {
+		remove()
+	}
+}
+
+// prune drops the expired entries, ti.mu must be held.
+func (ti *tagIndex) prune(now time.Time) {
+	for tag, keys := range ti.tags {
+		for key, entry := range keys {
+			if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(ti.tags, tag)
+		}
+	}
+}
+
+// len returns the number of indexed keys of the tag.
+func (ti *tagIndex) len(tag string) int {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	return len(ti.tags[tag])
+}
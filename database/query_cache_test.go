@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestQueryCacheKey(t *testing.T) {
+	stmt := func(sql string, vars ...any) *gorm.Statement {
+		s := &gorm.Statement{Vars: vars}
+		s.SQL.WriteString(sql)
+		return s
+	}
+
+	key := queryCacheKey(stmt("SELECT * FROM `users` WHERE name = ?", "alice"), "users", "list")
+	require.Equal(t, key, queryCacheKey(stmt("SELECT *  FROM `users`\n WHERE name = ?", "alice"), "users", "list"))
+	require.NotEqual(t, key, queryCacheKey(stmt("SELECT * FROM `users` WHERE name = ?", "bob"), "users", "list"))
+	require.NotEqual(t, key, queryCacheKey(stmt("SELECT * FROM `users` WHERE name = ?", "alice"), "users", "count"))
+	require.NotEqual(t, key, queryCacheKey(stmt("SELECT * FROM `users` WHERE name = ?", "alice"), "users_2024", "list"))
+
+	expand := stmt("SELECT * FROM `users` WHERE name = ?", "alice")
+	expand.Preloads = map[string][]any{"Orders": nil}
+	require.NotEqual(t, key, queryCacheKey(expand, "users", "list"))
+}
+
+func TestTagIndex(t *testing.T) {
+	ti := newTagIndex()
+	removed := make(map[string]int)
+	remove := func(key string) func() { return func() { removed[key]++ } }
+
+	ti.add("list", time.Minute, remove("list"), tableTag("users"))
+	ti.add("get_u1", time.Minute, remove("get_u1"), recordTag("users", "u1"), recordsTag("users"))
+	ti.add("get_u2", time.Minute, remove("get_u2"), recordTag("users", "u2"), recordsTag("users"))
+
+	// Writing u1 invalidates the list and the get of u1 only.
+	ti.invalidate(tableTag("users"), recordTag("users", "u1"))
+	require.Equal(t, map[string]int{"list": 1, "get_u1": 1}, removed)
+	require.Zero(t, ti.len(tableTag("users")))
+	require.Equal(t, 1, ti.len(recordTag("users", "u2")))
+
+	// Invalidating all records of the table.
+	ti.invalidate(recordsTag("users"))
+	require.Equal(t, 1, removed["get_u2"])
+
+	// Expired entries are pruned.
+	ti.add("expired", time.Nanosecond, remove("expired"), tableTag("orders"))
+	ti.add("forever", 0, remove("forever"), tableTag("orders"))
+	ti.mu.Lock()
+	ti.prune(time.Now().Add(time.Second))
+	ti.mu.Unlock()
+	require.Equal(t, 1, ti.len(tableTag("orders")))
+}
+
+func TestQueryInvalidationBroadcast(t *testing.T) {
+	published := make([][]byte, 0)
+	queryMu.Lock()
+	queryBus = func(payload []byte) error {
+		published = append(published, payload)
+		return nil
+	}
+	queryMu.Unlock()
+	defer CloseQueryCache()
+
+	removed := 0
+	queryIndex.add("peer", time.Minute, func() { removed++ }, tableTag("peers"))
+
+	// Local invalidations are applied and broadcast.
+	invalidateQueryCache(tableTag("peers"))
+	require.Equal(t, 1, removed)
+	require.Len(t, published, 1)
+
+	// The own broadcast is ignored, the broadcast of peers are applied.
+	queryIndex.add("peer", time.Minute, func() { removed++ }, tableTag("peers"))
+	handleQueryInvalidation(published[0])
+	require.Equal(t, 1, removed)
+
+	payload, err := json.Marshal(queryInvalidation{Node: "other", Tags: []string{tableTag("peers")}})
+	require.NoError(t, err)
+	handleQueryInvalidation(payload)
+	require.Equal(t, 2, removed)
+}
+
+func TestQueryInvalidationAfterCommit(t *testing.T) {
+	removed := 0
+	remove := func() { removed++ }
+
+	committed, rolledBack := new(sql.Tx), new(sql.Tx)
+	require.True(t, trackTx(committed))
+	require.True(t, trackTx(rolledBack))
+	// The nested transaction is applied by the outermost one.
+	require.False(t, trackTx(committed))
+
+	// The writes inside the transaction are deferred until commit.
+	queryIndex.add("tx_commit", time.Minute, remove, tableTag("tx_commit"))
+	require.True(t, deferTxTags(committed, tableTag("tx_commit")))
+	require.Zero(t, removed)
+	endTx(committed, true)
+	require.Equal(t, 1, removed)
+
+	// The writes of a rolled back transaction are dropped.
+	queryIndex.add("tx_rollback", time.Minute, remove, tableTag("tx_rollback"))
+	require.True(t, deferTxTags(rolledBack, tableTag("tx_rollback")))
+	endTx(rolledBack, false)
+	require.Equal(t, 1, removed)
+
+	// Untracked connections invalidate immediately.
+	require.False(t, deferTxTags(committed, tableTag("tx_commit")))
+	require.False(t, deferTxTags(nil, tableTag("tx_commit")))
+	queryIndex.invalidate(tableTag("tx_rollback"))
+	require.Equal(t, 2, removed)
+}
@@ -0,0 +1,347 @@
+package dcache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/provider/nats"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/util"
+	gonats "github.com/nats-io/nats.go"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+)
+
+// EventBus transports the cache events between the core nodes and the state node.
+//
+// The core nodes publish opSet/opDel events to TOPIC_REDIS_SET_DEL and subscribe
+// TOPIC_REDIS_DONE, the state node(Init) does the opposite.
+// Every subscriber receives all the messages published to the topic.
+type EventBus interface {
+	// Publish sends the message to the topic.
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe calls handler with the messages published to the topic until ctx is done.
+	// The calls of one subscription are serialized, a transport that consumes messages
+	// in batches(kafka) passes the whole batch, the others pass one message per call.
+	Subscribe(ctx context.Context, topic string, handler func(msgs [][]byte)) error
+	// Close stops all subscriptions and releases the underlying connections owned by the bus.
+	Close() error
+}
+
+var (
+	_ EventBus = (*kafkaBus)(nil)
+	_ EventBus = (*redisBus)(nil)
+	_ EventBus = (*natsBus)(nil)
+	_ EventBus = (*loopbackBus)(nil)
+)
+
+var (
+	eventBus   EventBus
+	eventBusMu sync.Mutex
+)
+
+// SetEventBus replaces the event bus shared by Init and NewDistributedCache,
+// it must be called before them. The default one is built from config.App.Cache.DistributedBus.
+func SetEventBus(bus EventBus) {
+	eventBusMu.Lock()
+	defer eventBusMu.Unlock()
+	eventBus = bus
+}
+
+// defaultEventBus returns the shared event bus, creates it on the first call.
+func defaultEventBus() (EventBus, error) {
+	eventBusMu.Lock()
+	defer eventBusMu.Unlock()
+	if eventBus != nil {
+		return eventBus, nil
+	}
+
+	switch config.App.Cache.DistributedBus {
+	case config.DistributedCacheBusKafka, "":
+		eventBus = NewKafkaBus(config.App.Kafka.Brokers)
+	case config.DistributedCacheBusRedis:
+		cli, err := redis.New(config.App.Redis)
+		if err != nil {
+			return nil, err
+		}
+		eventBus = &redisBus{cli: cli, owned: true}
+	case config.DistributedCacheBusNats:
+		conn := nats.Conn()
+		if conn == nil {
+			return nil, errors.New("distributed cache bus nats requires nats to be enabled")
+		}
+		eventBus = NewNatsBus(conn)
+	case config.DistributedCacheBusLoopback:
+		eventBus = NewLoopbackBus()
+	default:
+		return nil, errors.Newf("unknown distributed cache bus: %s", config.App.Cache.DistributedBus)
+	}
+	return eventBus, nil
+}
+
+// subscriptions tracks the cancel functions of the subscriptions so that Close can stop them.
+type subscriptions struct {
+	mu      sync.Mutex
+	cancels []context.CancelFunc
+}
+
+func (s *subscriptions) add(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels = append(s.cancels, cancel)
+	s.mu.Unlock()
+	return ctx
+}
+
+func (s *subscriptions) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.cancels = nil
+}
+
+// kafkaBus is the EventBus backed by kafka, it creates one producer per topic and one consumer per subscription.
+type kafkaBus struct {
+	brokers []string
+
+	mu        sync.Mutex
+	producers map[string]*kgo.Client
+	consumers []*kgo.Client
+	subs      subscriptions
+}
+
+// NewKafkaBus creates an EventBus that publishes and consumes the events with the kafka brokers.
+func NewKafkaBus(brokers []string) EventBus {
+	return &kafkaBus{brokers: brokers, producers: make(map[string]*kgo.Client)}
+}
+
+func (b *kafkaBus) Publish(ctx context.Context, topic string, data []byte) error {
+	b.mu.Lock()
+	producer, ok := b.producers[topic]
+	if !ok {
+		var err error
+		if producer, err = newProducer(b.brokers, topic); err != nil {
+			b.mu.Unlock()
+			return err
+		}
+		b.producers[topic] = producer
+	}
+	b.mu.Unlock()
+
+	return producer.ProduceSync(ctx, &kgo.Record{Topic: topic, Value: data}).FirstErr()
+}
+
+func (b *kafkaBus) Subscribe(ctx context.Context, topic string, handler func(msgs [][]byte)) error {
+	consumer, err := newConsumer(b.brokers, topic, topic)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.consumers = append(b.consumers, consumer)
+	b.mu.Unlock()
+
+	ctx = b.subs.add(ctx)
+	util.SafeGo(func() {
+		msgs := make([][]byte, 0, 1024)
+		for {
+			fetches := consumer.PollFetches(ctx)
+			if fetches.IsClientClosed() || ctx.Err() != nil {
+				return
+			}
+			fetches.EachError(func(t string, p int32, err error) {
+				logger.Dcache.Error("failed to fetch from kafka", zap.Error(err), zap.String("topic", t), zap.Int32("partition", p))
+			})
+			fetches.EachRecord(func(r *kgo.Record) { msgs = append(msgs, r.Value) })
+			if len(msgs) > 0 {
+				handler(msgs)
+			}
+			// reset slice and keep the underlying array.
+			msgs = msgs[:0]
+		}
+	}, "EventBus.kafka."+topic)
+	return nil
+}
+
+func (b *kafkaBus) Close() error {
+	b.subs.cancel()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.consumers {
+		c.Close()
+	}
+	for _, p := range b.producers {
+		p.Close()
+	}
+	b.consumers = nil
+	b.producers = make(map[string]*kgo.Client)
+	return nil
+}
+
+// redisBus is the EventBus backed by redis pub/sub, the topic is used as the channel name.
+// Messages published while a node is disconnected are lost, the same as a fresh kafka consumer group.
+type redisBus struct {
+	cli   goredis.UniversalClient
+	owned bool
+	subs  subscriptions
+}
+
+// NewRedisBus creates an EventBus over redis pub/sub, the client is not closed by the bus.
+func NewRedisBus(cli goredis.UniversalClient) EventBus {
+	return &redisBus{cli: cli}
+}
+
+func (b *redisBus) Publish(ctx context.Context, topic string, data []byte) error {
+	return b.cli.Publish(ctx, topic, data).Err()
+}
+
+func (b *redisBus) Subscribe(ctx context.Context, topic string, handler func(msgs [][]byte)) error {
+	ctx = b.subs.add(ctx)
+	sub := b.cli.Subscribe(ctx, topic)
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return err
+	}
+	util.SafeGo(func() {
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler([][]byte{[]byte(msg.Payload)})
+			}
+		}
+	}, "EventBus.redis."+topic)
+	return nil
+}
+
+func (b *redisBus) Close() error {
+	b.subs.cancel()
+	if b.owned {
+		return b.cli.Close()
+	}
+	return nil
+}
+
+// natsBus is the EventBus backed by nats core pub/sub, the topic is used as the subject.
+type natsBus struct {
+	conn *gonats.Conn
+	subs subscriptions
+}
+
+// NewNatsBus creates an EventBus over nats, the connection is not closed by the bus.
+func NewNatsBus(conn *gonats.Conn) EventBus {
+	return &natsBus{conn: conn}
+}
+
+func (b *natsBus) Publish(_ context.Context, topic string, data []byte) error {
+	return b.conn.Publish(topic, data)
+}
+
+func (b *natsBus) Subscribe(ctx context.Context, topic string, handler func(msgs [][]byte)) error {
+	sub, err := b.conn.Subscribe(topic, func(msg *gonats.Msg) { handler([][]byte{msg.Data}) })
+	if err != nil {
+		return err
+	}
+	ctx = b.subs.add(ctx)
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+	return nil
+}
+
+func (b *natsBus) Close() error {
+	b.subs.cancel()
+	return nil
+}
+
+// loopbackBus is an in-process EventBus, it is used by single node deployments and tests.
+type loopbackBus struct {
+	mu   sync.RWMutex
+	subs map[string][]*loopbackSub
+}
+
+type loopbackSub struct {
+	ch   chan []byte
+	done chan struct{}
+}
+
+// NewLoopbackBus creates an in-memory EventBus, messages are only delivered to the subscribers of the same bus.
+func NewLoopbackBus() EventBus {
+	return &loopbackBus{subs: make(map[string][]*loopbackSub)}
+}
+
+func (b *loopbackBus) Publish(ctx context.Context, topic string, data []byte) error {
+	b.mu.RLock()
+	subs := b.subs[topic]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- data:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (b *loopbackBus) Subscribe(ctx context.Context, topic string, handler func(msgs [][]byte)) error {
+	sub := &loopbackSub{ch: make(chan []byte, 1024), done: make(chan struct{})}
+	b.mu.Lock()
+	b.subs[topic] = append(b.subs[topic], sub)
+	b.mu.Unlock()
+
+	util.SafeGo(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				b.remove(topic, sub)
+				return
+			case <-sub.done:
+				return
+			case msg := <-sub.ch:
+				handler([][]byte{msg})
+			}
+		}
+	}, "EventBus.loopback."+topic)
+	return nil
+}
+
+func (b *loopbackBus) remove(topic string, sub *loopbackSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := b.subs[topic]
+	for i := range subs {
+		if subs[i] == sub {
+			// copy on write, Publish may be ranging over the old slice.
+			b.subs[topic] = append(append([]*loopbackSub{}, subs[:i]...), subs[i+1:]...)
+			close(sub.done)
+			return
+		}
+	}
+}
+
+func (b *loopbackBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subs {
+		for _, sub := range subs {
+			close(sub.done)
+		}
+	}
+	b.subs = make(map[string][]*loopbackSub)
+	return nil
+}
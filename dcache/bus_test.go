@@ -0,0 +1,55 @@
+package dcache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/dcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopbackBus(t *testing.T) {
+	bus := dcache.NewLoopbackBus()
+	defer bus.Close()
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	subscribe := func(ctx context.Context, name, topic string) {
+		require.NoError(t, bus.Subscribe(ctx, topic, func(msgs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, msg := range msgs {
+				received[name] = append(received[name], string(msg))
+			}
+		}))
+	}
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received[name])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribe(ctx, "node1", dcache.TOPIC_REDIS_DONE)
+	subscribe(context.Background(), "node2", dcache.TOPIC_REDIS_DONE)
+	subscribe(context.Background(), "state", dcache.TOPIC_REDIS_SET_DEL)
+
+	// Every subscriber of the topic receives the messages in order.
+	for _, msg := range []string{"a", "b", "c"} {
+		require.NoError(t, bus.Publish(context.Background(), dcache.TOPIC_REDIS_DONE, []byte(msg)))
+	}
+	require.Eventually(t, func() bool { return count("node1") == 3 && count("node2") == 3 }, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	require.Equal(t, []string{"a", "b", "c"}, received["node1"])
+	mu.Unlock()
+	require.Zero(t, count("state"))
+
+	// The canceled subscription receives nothing.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, bus.Publish(context.Background(), dcache.TOPIC_REDIS_DONE, []byte("d")))
+	require.Eventually(t, func() bool { return count("node2") == 4 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, count("node1"))
+}
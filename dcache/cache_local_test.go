@@ -346,3 +346,30 @@ func TestLocalCacheEmptyKey(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "empty-key-value", val)
 }
+
+// TestLocalCacheLenPeekClear 测试 Len/Peek/Clear
+func TestLocalCacheLenPeekClear(t *testing.T) {
+	type item struct{ ID int }
+	cache, err := dcache.NewLocalCache[item]()
+	assert.NoError(t, err)
+
+	for i := range 10 {
+		assert.NoError(t, cache.Set(fmt.Sprintf("len-key-%d", i), item{ID: i}, 1*time.Hour))
+	}
+	assert.NoError(t, cache.Set("len-key-expired", item{ID: -1}, 50*time.Millisecond))
+	assert.Equal(t, 11, cache.Len())
+
+	// 过期的条目不计数
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 10, cache.Len())
+
+	val, err := cache.Peek("len-key-3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, val.ID)
+	_, err = cache.Peek("nonexistent")
+	assert.Equal(t, types.ErrEntryNotFound, err)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+	assert.False(t, cache.Exists("len-key-3"))
+}
@@ -21,7 +21,6 @@ import (
 	"github.com/google/uuid"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/panjf2000/ants/v2"
-	"github.com/twmb/franz-go/pkg/kgo"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -145,10 +144,10 @@ func NewDistributedCache[T any](opts ...DistributedCacheOption[T]) (types.Distri
 }
 
 // distributedCache implements a two-level cacheing system with local memery cache and redis backend.
-// It provides cache synchronization across multiple instances using kafka for event publishing and consuming:
+// It provides cache synchronization across multiple instances using an EventBus for event publishing and consuming:
 //   - Local memory cache for high-speed access.
 //   - Redis for distributed persistence and high availability.
-//   - Kafka for cross-instance cache invalidation.
+//   - EventBus(kafka, redis pub/sub, nats or loopback) for cross-instance cache invalidation.
 //
 // Performance metrics are tracked (hits/misses) and a controlled goroutine pool handles
 type distributedCache[T any] struct {
@@ -181,11 +180,10 @@ type distributedCache[T any] struct {
 	distributedSet    atomic.Int64
 	distributedDelete atomic.Int64
 
+	// bus publishs the event that the entry associated with the key should be updated/deleted
+	// and receives the event that the entry was updated/deleted, call "WithEventBus" to replace it.
+	bus          EventBus
 	kafkaBrokers []string
-	// pubSetDel is the kafka producer, publish the event that the entry associated with the key was updated/delete.
-	pubSetDel *kgo.Client
-	// subDone is the kafka consumer, receive the event that the entry associated with the key was updated/delete.
-	subDone *kgo.Client
 
 	// logger is the cache internal logger, call "WithLogger" to replace it.
 	logger types.Logger
@@ -277,15 +275,13 @@ func newDistributedCache[T any](opts ...DistributedCacheOption[T]) (types.Cache[
 		}
 	}
 
-	// setup kafka
-	if len(dc.kafkaBrokers) == 0 {
-		dc.kafkaBrokers = config.App.Kafka.Brokers
-	}
-	if dc.pubSetDel, err = newProducer(dc.kafkaBrokers, TOPIC_REDIS_SET_DEL); err != nil {
-		return nil, err
-	}
-	if dc.subDone, err = newConsumer(dc.kafkaBrokers, TOPIC_REDIS_DONE, GROUP_REDIS_DONE); err != nil {
-		return nil, err
+	// setup event bus
+	if dc.bus == nil {
+		if len(dc.kafkaBrokers) > 0 {
+			dc.bus = NewKafkaBus(dc.kafkaBrokers)
+		} else if dc.bus, err = defaultEventBus(); err != nil {
+			return nil, err
+		}
 	}
 
 	// setup goroutines pool.
@@ -298,7 +294,10 @@ func newDistributedCache[T any](opts ...DistributedCacheOption[T]) (types.Cache[
 	}
 	dc.gopool = pool
 
-	dc.listenEvents()
+	if err = dc.bus.Subscribe(context.Background(), TOPIC_REDIS_DONE, dc.handleEvents); err != nil {
+		pool.Release()
+		return nil, err
+	}
 	dc.startMonitor()
 
 	return dc, nil
@@ -475,112 +474,93 @@ func (dc *distributedCache[T]) DeleteWithSync(key string) (err error) {
 func (dc *distributedCache[T]) Exists(key string) bool {
 	return dc.localCache.Exists(dc.prefix + key)
 }
-func (dc *distributedCache[T]) Len() int                                   { return -1 }
-func (dc *distributedCache[T]) Peek(string) (T, error)                     { var t T; return t, nil }
-func (dc *distributedCache[T]) Clear()                                     {}
-func (dc *distributedCache[T]) WithContext(context.Context) types.Cache[T] { return dc }
 
-// listenEvents listen kafka for cache update/delete event and synchronously update the local cache.
-func (dc *distributedCache[T]) listenEvents() {
-	records := make([]*kgo.Record, 0, 1024)
+// Len is not supported, the entries are spread across redis and the local caches of
+// every node and the local cache is shared by all prefixes of T. It always returns -1,
+// the same as the redis tier.
+func (dc *distributedCache[T]) Len() int { return -1 }
 
-	util.SafeGo(func() {
-		defer func() {
-			if dc.gopool != nil {
-				dc.gopool.Release()
-			}
-		}()
+// Peek retrieves the value from the local cache without counting the local hits/misses.
+func (dc *distributedCache[T]) Peek(key string) (T, error) {
+	return dc.localCache.Peek(dc.prefix + key)
+}
+
+// Clear is not supported, clearing only the local cache would be refilled from redis by the
+// next Get and the redis tier can't be cleared by prefix. Use Delete/DeleteWithSync instead.
+func (dc *distributedCache[T]) Clear() {
+	dc.logger.Warn("Clear is not supported by the distributed cache, use Delete or DeleteWithSync instead")
+}
+
+func (dc *distributedCache[T]) WithContext(context.Context) types.Cache[T] { return dc }
 
-		for {
-			fetches := dc.subDone.PollFetches(context.Background())
-			if fetches.IsClientClosed() {
-				// TODO: reconnect
+// handleEvents consumes the events that the entries were updated/deleted and synchronously update the local cache.
+func (dc *distributedCache[T]) handleEvents(msgs [][]byte) {
+	for _, msg := range msgs {
+		evt := new(event)
+		if err := json.Unmarshal(msg, evt); err != nil {
+			dc.logger.Error(
+				"failed to unmarshal event",
+				zap.Error(err),
+				zap.String("topic", TOPIC_REDIS_DONE),
+				zap.ByteString("value", msg),
+			)
+			continue
+		}
+		switch evt.Op {
+		case opSetDone:
+			// 如果是自己发出的事件，跳过处理
+			// 先检查缓存ID, 检查完后其实不用再检查缓存类型
+			if evt.CacheID == dc.cacheID {
+				// fmt.Println("----- set 缓存ID不匹配", dc.mark, dc.cacheId, evt.CacheId)
 				continue
 			}
-			fetches.EachError(func(s string, i int32, err error) {
-				dc.logger.Error(
-					"failed to fetch from kafka",
-					zap.Error(err),
-					zap.String("topic", TOPIC_REDIS_DONE),
-					zap.String("s", s),
-					zap.Int32("i", i),
-				)
-			})
-			records = fetches.Records()
-			if len(records) == 0 {
+			// 这里会接收到任意类型的数据, 基本类型,自定义类型等, 需要判断是否是自己的类型
+			// 不用担心不同类型会有相同的key而导致错误的设置,不同类型的key总是会不同的, 例如:
+			// key1 在 string 类型的 localCache, redisCache 是这样的: string:key1
+			// key1 在 int 类型的 localCache, redisCache 是这样的: int:key1
+			if evt.Typ != dc.typ {
+				// fmt.Println("----- set 缓存类型不匹配", dc.mark, dc.typ, evt.Typ)
 				continue
 			}
-			for _, record := range records {
-				evt := new(event)
-				if err := json.Unmarshal(record.Value, evt); err != nil {
-					dc.logger.Error(
-						"failed to unmarshal event",
-						zap.Error(err),
-						zap.String("topic", TOPIC_REDIS_DONE),
-						zap.ByteString("value", record.Value),
-					)
-					continue
-				}
-				switch evt.Op {
-				case opSetDone:
-					// 如果是自己发出的事件，跳过处理
-					// 先检查缓存ID, 检查完后其实不用再检查缓存类型
-					if evt.CacheID == dc.cacheID {
-						// fmt.Println("----- set 缓存ID不匹配", dc.mark, dc.cacheId, evt.CacheId)
-						continue
-					}
-					// 这里会接收到任意类型的数据, 基本类型,自定义类型等, 需要判断是否是自己的类型
-					// 不用担心不同类型会有相同的key而导致错误的设置,不同类型的key总是会不同的, 例如:
-					// key1 在 string 类型的 localCache, redisCache 是这样的: string:key1
-					// key1 在 int 类型的 localCache, redisCache 是这样的: int:key1
-					if evt.Typ != dc.typ {
-						// fmt.Println("----- set 缓存类型不匹配", dc.mark, dc.typ, evt.Typ)
-						continue
-					}
-
-					// TODO: 生产环境需要设置成 debug
-					dc.logger.Info("consume event", zap.Object("event", evt))
-					var val T
-					// fmt.Printf("----- %s OpSet %v %v %v\n", dc.mark, event.Typ, event.Key, string(event.Val))
-					if err := json.Unmarshal(evt.Val, &val); err == nil {
-						// TODO: 如何解决这个问题
-						// 本地缓存已经删除了, 收到 opSetDone 事件后,又要再删除一次, 我觉得没必要重复删除
-
-						dc.distributedSet.Add(1)
-						// 这里不需要使用 prefix + key, 状态节点传过来的 key, 已经是 prefix+key 了.
-						if err := dc.localCache.Set(evt.Key, val, evt.TTL); err != nil {
-							dc.logger.Warn("failed to set to local cache", zap.Error(err))
-						}
-					}
-				case opDelDone:
-					// 先检查缓存ID, 其实不用再检查缓存类型
-					if evt.CacheID == dc.cacheID {
-						// fmt.Println("------ delete 缓存ID不匹配", dc.mark, dc.cacheId, evt.CacheId)
-						continue
-					}
-					if evt.Typ != dc.typ {
-						// fmt.Println("------ delete 缓存类型不匹配:", dc.mark, dc.typ, evt.Typ)
-						continue
-					}
-					dc.distributedDelete.Add(1)
-					// 这里不需要使用 prefix + key, 状态节点传过来的 key, 已经是 prefix+key 了.
-					// 但凡收到 opDelDone 事件, 都需要从本地缓存中删除, 我们无法得知这个 key 是不是属于我们当前缓存的
-					if err := dc.localCache.Delete(evt.Key); err != nil && !errors.Is(err, types.ErrEntryNotFound) {
-						dc.logger.Warn("failed to delete from local cache", zap.Error(err))
-					}
-				default:
-					dc.logger.Warn("unknown event op", zap.String("op", evt.Op.String()), zap.String("key", evt.Key), zap.Object("event", evt))
+
+			// TODO: 生产环境需要设置成 debug
+			dc.logger.Info("consume event", zap.Object("event", evt))
+			var val T
+			// fmt.Printf("----- %s OpSet %v %v %v\n", dc.mark, event.Typ, event.Key, string(event.Val))
+			if err := json.Unmarshal(evt.Val, &val); err == nil {
+				// TODO: 如何解决这个问题
+				// 本地缓存已经删除了, 收到 opSetDone 事件后,又要再删除一次, 我觉得没必要重复删除
+
+				dc.distributedSet.Add(1)
+				// 这里不需要使用 prefix + key, 状态节点传过来的 key, 已经是 prefix+key 了.
+				if err := dc.localCache.Set(evt.Key, val, evt.TTL); err != nil {
+					dc.logger.Warn("failed to set to local cache", zap.Error(err))
 				}
 			}
-
-			// reset slice and keep the underlying array.
-			records = records[:0]
+		case opDelDone:
+			// 先检查缓存ID, 其实不用再检查缓存类型
+			if evt.CacheID == dc.cacheID {
+				// fmt.Println("------ delete 缓存ID不匹配", dc.mark, dc.cacheId, evt.CacheId)
+				continue
+			}
+			if evt.Typ != dc.typ {
+				// fmt.Println("------ delete 缓存类型不匹配:", dc.mark, dc.typ, evt.Typ)
+				continue
+			}
+			dc.distributedDelete.Add(1)
+			// 这里不需要使用 prefix + key, 状态节点传过来的 key, 已经是 prefix+key 了.
+			// 但凡收到 opDelDone 事件, 都需要从本地缓存中删除, 我们无法得知这个 key 是不是属于我们当前缓存的
+			if err := dc.localCache.Delete(evt.Key); err != nil && !errors.Is(err, types.ErrEntryNotFound) {
+				dc.logger.Warn("failed to delete from local cache", zap.Error(err))
+			}
+		default:
+			dc.logger.Warn("unknown event op", zap.String("op", evt.Op.String()), zap.String("key", evt.Key), zap.Object("event", evt))
 		}
-	}, "DistributedCache.listenEvents")
+	}
 }
 
 // sendEvent asynchronously publishs cache update or delete events to
-// kafka topic using a controlled goroutines pool to prevent excessive
+// the event bus using a controlled goroutines pool to prevent excessive
 // goroutines creation and properly handle sub-groutines panic.
 func (dc *distributedCache[T]) sendEvent(evt *event) {
 	if evt == nil {
@@ -606,13 +586,9 @@ func (dc *distributedCache[T]) sendEvent(evt *event) {
 			dc.logger.Error("failed to marshal event", zap.Error(err), zap.Object("event", evt))
 			return
 		}
-		record := &kgo.Record{
-			Topic: TOPIC_REDIS_SET_DEL,
-			Value: data,
-		}
 		// TODO: 日志设置成 debug
 		dc.logger.Info("publish event", zap.Object("event", evt))
-		if err := dc.pubSetDel.ProduceSync(context.Background(), record).FirstErr(); err != nil {
+		if err := dc.bus.Publish(context.Background(), TOPIC_REDIS_SET_DEL, data); err != nil {
 			dc.logger.Error("failed to publish event", zap.Error(err), zap.Object("event", evt))
 		}
 	})
@@ -745,6 +721,18 @@ func WithTrace[T any](trace bool) DistributedCacheOption[T] {
 	}
 }
 
+// WithEventBus sets the event bus of the distributed cache, it takes precedence over WithKafkaBrokers.
+func WithEventBus[T any](bus EventBus) DistributedCacheOption[T] {
+	return func(dc *distributedCache[T]) error {
+		if bus == nil {
+			return errors.New("event bus is nil")
+		}
+		dc.bus = bus
+		return nil
+	}
+}
+
+// WithKafkaBrokers makes the distributed cache use its own kafka event bus with the brokers.
 func WithKafkaBrokers[T any](brokers []string) DistributedCacheOption[T] {
 	return func(dc *distributedCache[T]) error {
 		dc.kafkaBrokers = brokers
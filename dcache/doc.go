@@ -8,4 +8,8 @@ package dcache
 	方法: Set/Get/Delete
 3. 如果缓存状态需要跨节点同步, 并且需要将缓存同步到 redis 中, 请使用 NewDistributedCache
 	方法: SetWithSync/GetWithSync/DeleteWithSync
+
+跨节点同步的事件总线由 cache.distributed_bus 决定:
+	kafka(默认)/redis/nats/loopback, 也可以调用 SetEventBus 或 WithEventBus 替换.
+	loopback 只在当前进程内投递事件, 适用于单节点部署和测试.
 */
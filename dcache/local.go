@@ -85,9 +85,27 @@ func (c *localCache[T]) Exists(key string) bool {
 	_, exists := c.c.Get(key)
 	return exists
 }
-func (c *localCache[T]) Len() int                                   { return -1 }
-func (c *localCache[T]) Peek(string) (T, error)                     { var t T; return t, nil }
-func (c *localCache[T]) Clear()                                     {}
+
+// Len returns the number of unexpired entries, it iterates over all entries.
+func (c *localCache[T]) Len() int {
+	n := 0
+	c.c.IterValues(func(T) bool {
+		n++
+		return false
+	})
+	return n
+}
+
+// Peek is the same as Get, ristretto keeps no access order and has no read without
+// recording the access in its metrics and admission policy.
+func (c *localCache[T]) Peek(key string) (T, error) {
+	return c.Get(key)
+}
+
+// Clear removes all entries and resets the metrics.
+func (c *localCache[T]) Clear() {
+	c.c.Clear()
+}
 func (c *localCache[T]) WithContext(context.Context) types.Cache[T] { return c }
 
 func (c *localCache[T]) Metrics() *localMetrics {
@@ -0,0 +1,397 @@
+// Package election elects one leader among the instances for the components
+// that must run on exactly one instance, eg: outbox relays, session cleanup, audit consumers.
+//
+// Components started by bootstrap.RegisterGo opt into leader-only execution with LeaderOnly,
+// they run on the leader and are canceled when the leadership is lost:
+//
+//	bootstrap.RegisterGo(election.LeaderOnly(func(ctx context.Context) error {
+//		return relay.Run(ctx)
+//	}))
+//
+// The leadership is a lease of the lock "election:<name>" held with lock.Locker, the leader renews it
+// and the followers retry every RetryPeriod, the term id is the fencing token of the lease.
+// The leader resigns on bootstrap cleanup so that another instance takes over without waiting for the ttl.
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/lock"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is the default ttl of the leadership.
+	DefaultTTL = 15 * time.Second
+	// DefaultRetryPeriod is the default interval between the attempts of the followers.
+	DefaultRetryPeriod = 2 * time.Second
+
+	// resignTimeout bounds the resignation on Stop.
+	resignTimeout = 5 * time.Second
+
+	// lockPrefix is the prefix of the lock names of the elections.
+	lockPrefix = "election:"
+)
+
+// Config is the configuration of an Elector.
+type Config struct {
+	// Name is the name of the election, the electors of the same name elect one leader.
+	Name string
+
+	// Identity identifies the instance, default is "hostname-pid".
+	Identity string
+
+	// TTL is the time after which the leadership expires if the leader stops renewing it,
+	// eg: the leader crashes. Default is DefaultTTL.
+	TTL time.Duration
+
+	// RetryPeriod is the interval between the attempts of the followers, default is DefaultRetryPeriod.
+	RetryPeriod time.Duration
+
+	// Locker holds the leadership, default is lock.Default.
+	// The leader is reported by Status only if the locker implements lock.OwnerReader.
+	Locker lock.Locker
+
+	// OnStartedLeading is called in a new goroutine when the instance becomes the leader,
+	// ctx is canceled when the leadership is lost or the elector stops.
+	// The leader resigns only after it returns, so that two leaders don't overlap.
+	OnStartedLeading func(ctx context.Context)
+
+	// OnStoppedLeading is called when the instance is no longer the leader.
+	OnStoppedLeading func()
+}
+
+// Status is the state of an Elector on the instance.
+type Status struct {
+	Name     string     `json:"name"`
+	Identity string     `json:"identity"`
+	Leader   string     `json:"leader"` // Leader is the identity of the current leader, empty if there is none
+	IsLeader bool       `json:"is_leader"`
+	Term     int64      `json:"term,omitempty"`
+	Since    *time.Time `json:"since,omitempty"` // Since is the time the instance became the leader
+	Error    string     `json:"error,omitempty"`
+}
+
+// Elector campaigns in an election until stopped.
+type Elector struct {
+	cfg Config
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{} // done is closed when Run returns
+	stopped chan struct{} // stopped is closed by Stop
+	term    lock.Lease
+	since   time.Time
+	workers []*worker
+
+	// leadCtx and leadWG run the workers of the current term, leadCtx is nil if not leading.
+	leadCtx context.Context //nolint:containedctx
+	leadWG  *sync.WaitGroup
+}
+
+// worker is a leader-only component, see Elector.Go.
+type worker struct {
+	fn   func(ctx context.Context) error
+	done chan error // done receives the result of fn once it returns while leading
+}
+
+var (
+	electors   []*Elector
+	electorsMu sync.Mutex
+
+	defaultElector *Elector
+	defaultOnce    sync.Once
+)
+
+// identity identifies this instance by default.
+var identity = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}()
+
+// New creates an elector, it campaigns once Run is called.
+func New(cfg Config) *Elector {
+	if len(cfg.Name) == 0 {
+		cfg.Name = defaultName()
+	}
+	if len(cfg.Identity) == 0 {
+		cfg.Identity = identity
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.RetryPeriod <= 0 {
+		cfg.RetryPeriod = DefaultRetryPeriod
+	}
+	if cfg.Locker == nil {
+		cfg.Locker = lock.Default()
+	}
+	e := &Elector{cfg: cfg, stopped: make(chan struct{})}
+
+	electorsMu.Lock()
+	electors = append(electors, e)
+	electorsMu.Unlock()
+	return e
+}
+
+// Default returns the elector of the leader-only components, see LeaderOnly.
+// Its name is the application name, so that the instances of the application elect one leader.
+func Default() *Elector {
+	defaultOnce.Do(func() { defaultElector = New(Config{}) })
+	return defaultElector
+}
+
+// LeaderOnly wraps fn for bootstrap.RegisterGo so that it only runs on the leader of the Default elector.
+func LeaderOnly(fn func(ctx context.Context) error) func() error { return Default().Go(fn) }
+
+// Stop stops all electors, the leaders resign so that another instance takes over immediately.
+// It's called by bootstrap cleanup.
+func Stop() {
+	electorsMu.Lock()
+	list := append([]*Elector(nil), electors...)
+	electorsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range list {
+		wg.Go(e.Stop)
+	}
+	wg.Wait()
+}
+
+// Statuses returns the status of all electors of the instance.
+func Statuses(ctx context.Context) []Status {
+	electorsMu.Lock()
+	list := append([]*Elector(nil), electors...)
+	electorsMu.Unlock()
+
+	statuses := make([]Status, 0, len(list))
+	for _, e := range list {
+		statuses = append(statuses, e.Status(ctx))
+	}
+	return statuses
+}
+
+func defaultName() string {
+	if len(config.App.AppInfo.Name) > 0 {
+		return config.App.AppInfo.Name
+	}
+	return "default"
+}
+
+// Name is the name of the election.
+func (e *Elector) Name() string { return e.cfg.Name }
+
+// IsLeader reports whether the instance is the leader.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term != nil
+}
+
+// Status returns the status of the elector, the leader is read from the locker.
+func (e *Elector) Status(ctx context.Context) Status {
+	e.mu.Lock()
+	s := Status{Name: e.cfg.Name, Identity: e.cfg.Identity, IsLeader: e.term != nil}
+	if e.term != nil {
+		since := e.since
+		s.Term, s.Since = e.term.Token(), &since
+	}
+	e.mu.Unlock()
+
+	switch r, ok := e.cfg.Locker.(lock.OwnerReader); {
+	case ok:
+		leader, err := r.Owner(ctx, lockPrefix+e.cfg.Name)
+		if err != nil {
+			s.Error = err.Error()
+		}
+		s.Leader = leader
+	case s.IsLeader:
+		s.Leader = e.cfg.Identity
+	default:
+		s.Error = "the locker does not report the leader"
+	}
+	return s
+}
+
+// Go wraps fn for bootstrap.RegisterGo so that it only runs on the leader, it starts the elector if not running.
+//
+// fn is called when the instance becomes the leader and its ctx is canceled when the leadership is lost,
+// it's called again in the next term only if it returned because its ctx was canceled.
+// If fn returns while the instance is still leading, it has finished or failed: it's not called again
+// and the wrapper returns its error. The wrapper returns nil once the elector stops.
+func (e *Elector) Go(fn func(ctx context.Context) error) func() error {
+	return func() error {
+		w := &worker{fn: fn, done: make(chan error, 1)}
+		e.mu.Lock()
+		e.workers = append(e.workers, w)
+		// Join the current term, the worker is registered after the instance became the leader.
+		if ctx := e.leadCtx; ctx != nil {
+			e.leadWG.Go(func() { e.runWorker(ctx, w) })
+		}
+		e.mu.Unlock()
+		e.Start()
+
+		select {
+		case err := <-w.done:
+			return err
+		case <-e.stopped:
+			return nil
+		}
+	}
+}
+
+// runWorker runs the worker for a term, the worker is removed unless it returned because the term ended.
+func (e *Elector) runWorker(ctx context.Context, w *worker) {
+	err := w.fn(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.workers = slices.DeleteFunc(e.workers, func(o *worker) bool { return o == w })
+	e.mu.Unlock()
+	if err != nil {
+		zap.S().Errorw("leader-only component failed, it will not be restarted", "election", e.cfg.Name, "error", err.Error())
+	}
+	w.done <- err
+}
+
+// Start runs the elector in a new goroutine if not running.
+func (e *Elector) Start() {
+	e.mu.Lock()
+	running := e.running
+	e.mu.Unlock()
+	if !running {
+		go func() {
+			if err := e.Run(context.Background()); err != nil && !errors.Is(err, errAlreadyRunning) {
+				zap.S().Errorw("leader election stopped", "election", e.cfg.Name, "error", err.Error())
+			}
+		}()
+	}
+}
+
+var errAlreadyRunning = errors.New("election: elector is already running")
+
+// Run campaigns until ctx is done or Stop is called, the leader resigns before Run returns.
+func (e *Elector) Run(ctx context.Context) error {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return errAlreadyRunning
+	}
+	select {
+	case <-e.stopped:
+		e.mu.Unlock()
+		return nil
+	default:
+	}
+	e.running = true
+	e.done = make(chan struct{})
+	ctx, e.cancel = context.WithCancel(ctx)
+	done := e.done
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+		close(done)
+	}()
+
+	zap.S().Infow("leader election started", "election", e.cfg.Name, "identity", e.cfg.Identity)
+	opts := []lock.Option{lock.WithTTL(e.cfg.TTL), lock.WithRetryInterval(e.cfg.RetryPeriod), lock.WithOwner(e.cfg.Identity)}
+	for ctx.Err() == nil {
+		// Lock blocks until the instance is elected or ctx is done.
+		term, err := e.cfg.Locker.Lock(ctx, lockPrefix+e.cfg.Name, opts...)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			zap.S().Warnw("failed to campaign for leadership", "election", e.cfg.Name, "error", err.Error())
+			select {
+			case <-ctx.Done():
+			case <-time.After(e.cfg.RetryPeriod):
+			}
+			continue
+		}
+		e.lead(ctx, term)
+	}
+	return nil
+}
+
+// lead runs the leader callbacks and workers until the term ends, then resigns.
+func (e *Elector) lead(ctx context.Context, term lock.Lease) {
+	zap.S().Infow("started leading", "election", e.cfg.Name, "identity", e.cfg.Identity, "term", term.Token())
+	leadCtx, cancel := context.WithCancel(ctx)
+	wg := new(sync.WaitGroup)
+
+	e.mu.Lock()
+	e.term, e.since = term, time.Now()
+	e.leadCtx, e.leadWG = leadCtx, wg
+	if e.cfg.OnStartedLeading != nil {
+		wg.Go(func() { e.cfg.OnStartedLeading(leadCtx) })
+	}
+	for _, w := range e.workers {
+		wg.Go(func() { e.runWorker(leadCtx, w) })
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-term.Done():
+	case <-ctx.Done():
+	}
+	e.mu.Lock()
+	e.leadCtx, e.leadWG = nil, nil
+	e.mu.Unlock()
+	cancel()
+	wg.Wait()
+
+	// Resign after the leader stopped working so that the next leader doesn't overlap.
+	rctx, rcancel := context.WithTimeout(context.Background(), resignTimeout)
+	if err := term.Unlock(rctx); err != nil && !errors.Is(err, lock.ErrLockLost) {
+		zap.S().Warnw("failed to resign leadership", "election", e.cfg.Name, "error", err.Error())
+	}
+	rcancel()
+
+	e.mu.Lock()
+	e.term = nil
+	e.mu.Unlock()
+	zap.S().Infow("stopped leading", "election", e.cfg.Name, "identity", e.cfg.Identity, "term", term.Token())
+	if e.cfg.OnStoppedLeading != nil {
+		e.cfg.OnStoppedLeading()
+	}
+}
+
+// Stop stops campaigning and waits for the leader to resign, the elector can not be restarted.
+func (e *Elector) Stop() {
+	e.mu.Lock()
+	select {
+	case <-e.stopped:
+		e.mu.Unlock()
+		return
+	default:
+	}
+	close(e.stopped)
+	cancel, done := e.cancel, e.done
+	running := e.running
+	e.mu.Unlock()
+	if !running {
+		return
+	}
+	cancel()
+
+	// Wait for Run to resign and return, the leader work may not stop in time.
+	select {
+	case <-done:
+	case <-time.After(resignTimeout + e.cfg.TTL):
+		zap.S().Warnw("leader election did not stop in time", "election", e.cfg.Name)
+	}
+}
@@ -0,0 +1,154 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/lock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestElector(l lock.Locker, identity string, onStart func(ctx context.Context), onStop func()) *Elector {
+	return New(Config{
+		Name:             "test",
+		Identity:         identity,
+		TTL:              60 * time.Millisecond,
+		RetryPeriod:      10 * time.Millisecond,
+		Locker:           l,
+		OnStartedLeading: onStart,
+		OnStoppedLeading: onStop,
+	})
+}
+
+func TestElection(t *testing.T) {
+	l := lock.NewMemoryLocker()
+	var leading atomic.Int32
+	var stopped atomic.Int32
+	onStart := func(ctx context.Context) {
+		require.Equal(t, int32(1), leading.Add(1), "two leaders overlap")
+		<-ctx.Done()
+		leading.Add(-1)
+	}
+	onStop := func() { stopped.Add(1) }
+
+	a := newTestElector(l, "a", onStart, onStop)
+	c := newTestElector(l, "c", onStart, onStop)
+	a.Start()
+	require.Eventually(t, a.IsLeader, time.Second, 5*time.Millisecond)
+	c.Start()
+
+	// The leadership is renewed beyond the ttl.
+	time.Sleep(200 * time.Millisecond)
+	require.True(t, a.IsLeader())
+	require.False(t, c.IsLeader())
+
+	s := c.Status(context.Background())
+	require.Equal(t, "a", s.Leader)
+	require.False(t, s.IsLeader)
+	s = a.Status(context.Background())
+	require.True(t, s.IsLeader)
+	require.Equal(t, int64(1), s.Term)
+	require.NotNil(t, s.Since)
+
+	// The leader resigns on stop, the follower takes over.
+	a.Stop()
+	require.Equal(t, int32(1), stopped.Load())
+	require.Eventually(t, c.IsLeader, time.Second, 5*time.Millisecond)
+	require.Equal(t, int64(2), c.Status(context.Background()).Term)
+
+	c.Stop()
+	leader, err := l.(lock.OwnerReader).Owner(context.Background(), lockPrefix+"test")
+	require.NoError(t, err)
+	require.Empty(t, leader)
+	require.Equal(t, int32(0), leading.Load())
+}
+
+func TestElectorGo(t *testing.T) {
+	e := newTestElector(lock.NewMemoryLocker(), "a", nil, nil)
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Go(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})()
+	}()
+	<-started
+
+	// A worker registered while leading joins the current term.
+	joined := make(chan struct{})
+	failed := make(chan error, 1)
+	go func() {
+		failed <- e.Go(func(context.Context) error {
+			close(joined)
+			return errors.New("boom")
+		})()
+	}()
+	<-joined
+	require.EqualError(t, <-failed, "boom")
+
+	// The workers return nil once the elector stops.
+	e.Stop()
+	require.NoError(t, <-done)
+}
+
+// lostLocker grants every lock with a lease lost right away.
+type lostLocker struct {
+	lock.Locker
+	token atomic.Int64
+}
+
+type lostLease struct {
+	lock.Lease
+	token int64
+	done  chan struct{}
+}
+
+func (l *lostLease) Token() int64                                   { return l.token }
+func (l *lostLease) Done() <-chan struct{}                          { return l.done }
+func (*lostLease) Unlock(context.Context) error                     { return nil }
+func (l *lostLocker) Owner(context.Context, string) (string, error) { return "", nil }
+
+func (l *lostLocker) Lock(context.Context, string, ...lock.Option) (lock.Lease, error) {
+	lease := &lostLease{token: l.token.Add(1), done: make(chan struct{})}
+	time.AfterFunc(20*time.Millisecond, func() { close(lease.done) })
+	return lease, nil
+}
+
+func TestLeadershipLost(t *testing.T) {
+	var starts atomic.Int32
+	canceled := make(chan struct{}, 10)
+	e := newTestElector(new(lostLocker), "a", func(ctx context.Context) {
+		starts.Add(1)
+		<-ctx.Done()
+		canceled <- struct{}{}
+	}, nil)
+
+	// The worker canceled with its term runs again in the next term,
+	// the failed worker is not restarted.
+	var restarted, failed atomic.Int32
+	go e.Go(func(ctx context.Context) error {
+		restarted.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	})() //nolint:errcheck
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.Go(func(context.Context) error {
+			failed.Add(1)
+			return errors.New("boom")
+		})()
+	}()
+	defer e.Stop()
+
+	// The leader work is canceled when the term is lost, and started again on the next term.
+	<-canceled
+	require.EqualError(t, <-errc, "boom")
+	require.Eventually(t, func() bool { return starts.Load() >= 3 && restarted.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(1), failed.Load())
+}
@@ -0,0 +1,120 @@
+package featureflag
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"go.uber.org/zap"
+)
+
+const (
+	// auditBatchSize is the number of pending evaluations that triggers a flush.
+	auditBatchSize = 100
+	// auditFlushInterval is the maximum delay of a recorded evaluation.
+	auditFlushInterval = 5 * time.Second
+	// auditMaxPending bounds the pending evaluations, the evaluations are dropped
+	// rather than slowing down the requests when the database falls behind.
+	auditMaxPending = 10000
+	// cleanupBatchSize is the number of evaluations deleted at a time by the audit cleanup.
+	cleanupBatchSize = 1000
+)
+
+var (
+	auditMu   sync.Mutex
+	pending   []*modelfeatureflag.Evaluation
+	dropped   atomic.Int64
+	auditOnce sync.Once
+	flushCh   = make(chan struct{}, 1)
+)
+
+// record queues the evaluation into the audit trail, it's written asynchronously in batches.
+func record(r Result, s Subject) {
+	if !enabled.Load() {
+		return
+	}
+	auditMu.Lock()
+	if len(pending) >= auditMaxPending {
+		auditMu.Unlock()
+		dropped.Add(1)
+		return
+	}
+	pending = append(pending, &modelfeatureflag.Evaluation{
+		Flag:        r.Flag,
+		Variant:     r.Variant,
+		Reason:      r.Reason,
+		Rule:        r.Rule,
+		UserID:      s.UserID,
+		TenantID:    s.TenantID,
+		Environment: s.Environment,
+		RequestID:   s.RequestID,
+		EvaluatedAt: time.Now(),
+	})
+	full := len(pending) >= auditBatchSize
+	auditMu.Unlock()
+
+	auditOnce.Do(func() { go auditLoop() })
+	if full {
+		select {
+		case flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func auditLoop() {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-flushCh:
+		}
+		flushAudit()
+	}
+}
+
+// flushAudit writes the pending evaluations.
+func flushAudit() {
+	auditMu.Lock()
+	batch := pending
+	pending = nil
+	auditMu.Unlock()
+
+	if n := dropped.Swap(0); n > 0 {
+		zap.S().Warnw("dropped feature flag evaluations, the audit trail falls behind", "count", n)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := database.Database[*modelfeatureflag.Evaluation](nil).Create(batch...); err != nil {
+		zap.S().Errorw("failed to save feature flag evaluations", "count", len(batch), "error", err.Error())
+	}
+}
+
+// cleanupAudit deletes the evaluations older than the retention in batches.
+func cleanupAudit() error {
+	end := time.Now().Add(-cfg.AuditRetention)
+	for {
+		records := make([]*modelfeatureflag.Evaluation, 0, cleanupBatchSize)
+		if err := database.Database[*modelfeatureflag.Evaluation](nil).
+			WithTimeRange("evaluated_at", time.Time{}, end).
+			WithLimit(cleanupBatchSize).
+			List(&records); err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := database.Database[*modelfeatureflag.Evaluation](nil).WithPurge().Delete(records...); err != nil {
+			return err
+		}
+		if len(records) < cleanupBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package featureflag evaluates the feature flags managed by module/featureflag.
+//
+// A flag is evaluated for the subject of the request, its user, groups, tenant and environment:
+//
+//	func (s *OrderService) Create(ctx *types.ServiceContext, req *Order) (*Order, error) {
+//		if featureflag.Bool(ctx, "new-checkout", false) {
+//			return s.newCheckout(ctx, req)
+//		}
+//		...
+//	}
+//
+// The flags are cached on every instance, the cache is reloaded every poll interval and
+// immediately when a flag is changed through the API, the change is propagated to the
+// other instances through redis if enabled. Evaluations of the flags with Audit enabled
+// are recorded asynchronously as modelfeatureflag.Evaluation.
+//
+// The typed constants generated by "gg featureflag" reference the flags without string literals:
+//
+//	if flags.NewCheckout.Enabled(ctx) { ... }
+package featureflag
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/forbearing/gst/config"
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"github.com/forbearing/gst/types"
+)
+
+type (
+	Flag    = modelfeatureflag.Flag
+	Variant = modelfeatureflag.Variant
+	Rule    = modelfeatureflag.Rule
+	Weight  = modelfeatureflag.Weight
+	Reason  = modelfeatureflag.Reason
+	Result  = modelfeatureflag.Result
+)
+
+const (
+	ReasonNotFound = modelfeatureflag.ReasonNotFound
+	ReasonDisabled = modelfeatureflag.ReasonDisabled
+	ReasonRule     = modelfeatureflag.ReasonRule
+	ReasonDefault  = modelfeatureflag.ReasonDefault
+)
+
+// buckets is the resolution of the percentage rollouts, 0.01%.
+const buckets = 10000
+
+// Subject is what a flag is evaluated for.
+type Subject struct {
+	UserID      string
+	Groups      []string
+	TenantID    string
+	Environment string // Environment is the server mode, eg: "prod", "dev"
+	RequestID   string
+}
+
+var (
+	groupsFn func(ctx context.Context, userID string) []string
+	groupsMu sync.RWMutex
+)
+
+// SetGroupsFunc sets the function resolving the groups of a user for the group rules.
+// No user belongs to any group if it's not set.
+func SetGroupsFunc(fn func(ctx context.Context, userID string) []string) {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	groupsFn = fn
+}
+
+// SubjectOf returns the subject of the request, the environment is the server mode.
+func SubjectOf(ctx *types.ServiceContext) Subject {
+	s := Subject{
+		UserID:      ctx.UserID,
+		TenantID:    ctx.TenantID,
+		Environment: string(config.App.Mode),
+		RequestID:   ctx.RequestID,
+	}
+	groupsMu.RLock()
+	fn := groupsFn
+	groupsMu.RUnlock()
+	if fn != nil && len(s.UserID) > 0 {
+		s.Groups = fn(ctx.Context(), s.UserID)
+	}
+	return s
+}
+
+// Evaluate evaluates the flag for the subject of the request.
+func Evaluate(ctx *types.ServiceContext, key string) Result {
+	return EvaluateSubject(SubjectOf(ctx), key)
+}
+
+// EvaluateSubject evaluates the flag for the subject, it's used out of the requests, eg: in cronjobs.
+func EvaluateSubject(s Subject, key string) Result {
+	f, ok := snapshot()[key]
+	if !ok {
+		return Result{Flag: key, Reason: ReasonNotFound}
+	}
+	r := evaluate(f, s)
+	if f.Audit {
+		record(r, s)
+	}
+	return r
+}
+
+// EvaluateAll evaluates all flags for the subject of the request, ordered by key.
+func EvaluateAll(ctx *types.ServiceContext) []Result {
+	s := SubjectOf(ctx)
+	flags := snapshot()
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	results := make([]Result, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, EvaluateSubject(s, key))
+	}
+	return results
+}
+
+// Bool reports whether the boolean flag is on for the subject of the request,
+// def is returned if the flag doesn't exist or is not a boolean flag.
+func Bool(ctx *types.ServiceContext, key string, def bool) bool {
+	r := Evaluate(ctx, key)
+	if r.Variant != modelfeatureflag.VariantTrue && r.Variant != modelfeatureflag.VariantFalse {
+		return def
+	}
+	return r.Variant == modelfeatureflag.VariantTrue
+}
+
+// VariantOf returns the variant served to the subject of the request,
+// def is returned if the flag doesn't exist or is disabled without an off variant.
+func VariantOf(ctx *types.ServiceContext, key string, def string) string {
+	if r := Evaluate(ctx, key); len(r.Variant) > 0 {
+		return r.Variant
+	}
+	return def
+}
+
+// evaluate evaluates the flag for the subject, see Flag.
+func evaluate(f *Flag, s Subject) Result {
+	r := Result{Flag: f.Key}
+	switch {
+	case !f.IsEnabled():
+		r.Reason, r.Variant = ReasonDisabled, f.OffVariant
+	default:
+		r.Reason, r.Variant = ReasonDefault, f.DefaultVariant
+		for i, rule := range f.Rules {
+			if variant, ok := match(f.Key, rule, s); ok {
+				r.Reason, r.Variant, r.Rule = ReasonRule, variant, &i
+				break
+			}
+		}
+	}
+	r.Value = value(f, r.Variant)
+	return r
+}
+
+// match returns the variant served by the rule if the subject matches it.
+func match(key string, rule Rule, s Subject) (string, bool) {
+	if len(rule.Users) > 0 && !slices.Contains(rule.Users, s.UserID) {
+		return "", false
+	}
+	if len(rule.Groups) > 0 && !slices.ContainsFunc(s.Groups, func(g string) bool { return slices.Contains(rule.Groups, g) }) {
+		return "", false
+	}
+	if len(rule.Tenants) > 0 && !slices.Contains(rule.Tenants, s.TenantID) {
+		return "", false
+	}
+	if len(rule.Environments) > 0 && !slices.Contains(rule.Environments, s.Environment) {
+		return "", false
+	}
+	if rule.Percentage == nil && len(rule.Rollout) == 0 {
+		return rule.Variant, true
+	}
+
+	// The rollouts need a stable identity.
+	if len(s.UserID) == 0 {
+		return "", false
+	}
+	if rule.Percentage != nil && bucket(key, s.UserID) >= int(*rule.Percentage*buckets/100) {
+		return "", false
+	}
+	if len(rule.Rollout) == 0 {
+		return rule.Variant, true
+	}
+	// The rollout is bucketed independently of the percentage, otherwise the users
+	// of a partial rollout would all fall into the first variants.
+	b, sum := bucket(key+"/rollout", s.UserID), 0
+	for _, w := range rule.Rollout {
+		sum += w.Weight * buckets / 100
+		if b < sum {
+			return w.Variant, true
+		}
+	}
+	return rule.Rollout[len(rule.Rollout)-1].Variant, true
+}
+
+// bucket places the user in one of the buckets, stable for the same key and user.
+func bucket(key, userID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key + "/" + userID))
+	return int(h.Sum32() % buckets)
+}
+
+// value returns the value of the variant, the boolean flags serve true and false.
+func value(f *Flag, variant string) any {
+	if len(variant) == 0 {
+		return nil
+	}
+	if f.Type == modelfeatureflag.FlagTypeBoolean {
+		b, _ := strconv.ParseBool(variant)
+		return b
+	}
+	for _, v := range f.Variants {
+		if v.Key == variant && v.Value != nil {
+			return v.Value
+		}
+	}
+	return variant
+}
@@ -0,0 +1,169 @@
+package featureflag
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestEvaluate(t *testing.T) {
+	flags := []*Flag{
+		{
+			Key:     "new-checkout",
+			Enabled: ptr(true),
+			Rules: []Rule{
+				{Users: []string{"u-blocked"}, Variant: modelfeatureflag.VariantFalse},
+				{Groups: []string{"beta"}, Variant: modelfeatureflag.VariantTrue},
+				{Tenants: []string{"t1"}, Environments: []string{"prod"}, Variant: modelfeatureflag.VariantTrue},
+			},
+			DefaultVariant: modelfeatureflag.VariantFalse,
+		},
+		{
+			Key:            "theme",
+			Type:           modelfeatureflag.FlagTypeVariant,
+			Enabled:        ptr(true),
+			Variants:       []Variant{{Key: "blue", Value: "#00f"}, {Key: "red"}},
+			DefaultVariant: "blue",
+			Rules:          []Rule{{Environments: []string{"dev"}, Variant: "red"}},
+		},
+		{Key: "off"},
+		{
+			Key:            "off-variant",
+			Type:           modelfeatureflag.FlagTypeVariant,
+			Variants:       []Variant{{Key: "a"}},
+			DefaultVariant: "a",
+		},
+	}
+	require.NoError(t, Set(flags...))
+
+	cases := []struct {
+		key     string
+		subject Subject
+		variant string
+		reason  Reason
+		value   any
+	}{
+		{"new-checkout", Subject{UserID: "u1"}, "false", ReasonDefault, false},
+		{"new-checkout", Subject{UserID: "u-blocked", Groups: []string{"beta"}}, "false", ReasonRule, false},
+		{"new-checkout", Subject{UserID: "u1", Groups: []string{"alpha", "beta"}}, "true", ReasonRule, true},
+		{"new-checkout", Subject{UserID: "u1", TenantID: "t1", Environment: "prod"}, "true", ReasonRule, true},
+		{"new-checkout", Subject{UserID: "u1", TenantID: "t1", Environment: "dev"}, "false", ReasonDefault, false},
+		{"theme", Subject{}, "blue", ReasonDefault, "#00f"},
+		{"theme", Subject{Environment: "dev"}, "red", ReasonRule, "red"},
+		{"off", Subject{}, "false", ReasonDisabled, false},
+		{"off-variant", Subject{}, "", ReasonDisabled, nil},
+		{"missing", Subject{}, "", ReasonNotFound, nil},
+	}
+	for _, c := range cases {
+		r := EvaluateSubject(c.subject, c.key)
+		require.Equal(t, c.variant, r.Variant, "%s %+v", c.key, c.subject)
+		require.Equal(t, c.reason, r.Reason, "%s %+v", c.key, c.subject)
+		require.Equal(t, c.value, r.Value, "%s %+v", c.key, c.subject)
+	}
+}
+
+func TestPercentageRollout(t *testing.T) {
+	f := &Flag{
+		Key:            "rollout",
+		Enabled:        ptr(true),
+		DefaultVariant: modelfeatureflag.VariantFalse,
+		Rules:          []Rule{{Percentage: ptr(20.0), Variant: modelfeatureflag.VariantTrue}},
+	}
+	require.NoError(t, f.Normalize())
+
+	on := make(map[string]bool)
+	for i := range 10000 {
+		user := fmt.Sprintf("user-%d", i)
+		if evaluate(f, Subject{UserID: user}).Variant == modelfeatureflag.VariantTrue {
+			on[user] = true
+		}
+	}
+	require.InDelta(t, 2000, len(on), 200)
+
+	// Stable for the same user, and the users keep the flag when the rollout grows.
+	f.Rules[0].Percentage = ptr(50.0)
+	for user := range on {
+		require.Equal(t, modelfeatureflag.VariantTrue, evaluate(f, Subject{UserID: user}).Variant)
+	}
+
+	// Anonymous subjects are never rolled out.
+	require.Equal(t, ReasonDefault, evaluate(f, Subject{}).Reason)
+}
+
+func TestWeightedRollout(t *testing.T) {
+	f := &Flag{
+		Key:            "experiment",
+		Type:           modelfeatureflag.FlagTypeVariant,
+		Enabled:        ptr(true),
+		Variants:       []Variant{{Key: "a"}, {Key: "b"}, {Key: "c"}},
+		DefaultVariant: "a",
+		Rules:          []Rule{{Rollout: []Weight{{Variant: "a", Weight: 50}, {Variant: "b", Weight: 30}, {Variant: "c", Weight: 20}}}},
+	}
+	require.NoError(t, f.Normalize())
+
+	counts := make(map[string]int)
+	for i := range 10000 {
+		r := evaluate(f, Subject{UserID: fmt.Sprintf("user-%d", i)})
+		require.Equal(t, ReasonRule, r.Reason)
+		counts[r.Variant]++
+	}
+	require.InDelta(t, 5000, counts["a"], 300)
+	require.InDelta(t, 3000, counts["b"], 300)
+	require.InDelta(t, 2000, counts["c"], 300)
+}
+
+func TestNormalize(t *testing.T) {
+	invalid := []*Flag{
+		{Key: "Upper"},
+		{Key: "x", Type: "number"},
+		{Key: "x", Type: modelfeatureflag.FlagTypeVariant},
+		{Key: "x", Type: modelfeatureflag.FlagTypeVariant, Variants: []Variant{{Key: "a"}, {Key: "a"}}, DefaultVariant: "a"},
+		{Key: "x", Type: modelfeatureflag.FlagTypeVariant, Variants: []Variant{{Key: "a"}}, DefaultVariant: "b"},
+		{Key: "x", Rules: []Rule{{Variant: "maybe"}}},
+		{Key: "x", Rules: []Rule{{Percentage: ptr(120.0), Variant: "true"}}},
+		{Key: "x", Rules: []Rule{{Rollout: []Weight{{Variant: "true", Weight: 60}, {Variant: "false", Weight: 30}}}}},
+	}
+	for _, f := range invalid {
+		require.Error(t, f.Normalize(), "%+v", f)
+	}
+
+	f := &Flag{Key: "x"}
+	require.NoError(t, f.Normalize())
+	require.Equal(t, modelfeatureflag.FlagTypeBoolean, f.Type)
+	require.Equal(t, modelfeatureflag.VariantTrue, f.DefaultVariant)
+	require.Equal(t, modelfeatureflag.VariantFalse, f.OffVariant)
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate("flags",
+		&Flag{Key: "new-checkout", Description: "enables the new\ncheckout page."},
+		&Flag{
+			Key:            "checkout.theme",
+			Type:           modelfeatureflag.FlagTypeVariant,
+			Variants:       []Variant{{Key: "dark-blue", Description: "the default"}, {Key: "red"}},
+			DefaultVariant: "dark-blue",
+		},
+	)
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "flags.go", src, parser.ParseComments)
+	require.NoError(t, err)
+
+	out := string(src)
+	require.True(t, strings.HasPrefix(out, "// Code generated by \"gg featureflag\"; DO NOT EDIT."))
+	require.Contains(t, out, "// NewCheckout enables the new checkout page.\nconst NewCheckout featureflag.BoolFlag = \"new-checkout\"")
+	require.Contains(t, out, "const CheckoutTheme featureflag.VariantFlag = \"checkout.theme\"")
+	require.Contains(t, out, "CheckoutThemeDarkBlue = \"dark-blue\" // the default")
+	require.Contains(t, out, "CheckoutThemeRed      = \"red\"")
+
+	_, err = Generate("flags", &Flag{Key: "new-checkout"}, &Flag{Key: "new_checkout"})
+	require.Error(t, err)
+	_, err = Generate("flags-x")
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package featureflag
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"github.com/forbearing/gst/types"
+)
+
+// BoolFlag references a boolean flag, the constants are generated by Generate.
+type BoolFlag string
+
+// Enabled reports whether the flag is on for the subject of the request, false if the flag doesn't exist.
+func (f BoolFlag) Enabled(ctx *types.ServiceContext) bool { return Bool(ctx, string(f), false) }
+
+// Evaluate evaluates the flag for the subject of the request.
+func (f BoolFlag) Evaluate(ctx *types.ServiceContext) Result { return Evaluate(ctx, string(f)) }
+
+// VariantFlag references a variant flag, the constants are generated by Generate.
+type VariantFlag string
+
+// Variant returns the variant served to the subject of the request, def if there is none.
+func (f VariantFlag) Variant(ctx *types.ServiceContext, def string) string {
+	return VariantOf(ctx, string(f), def)
+}
+
+// Evaluate evaluates the flag for the subject of the request.
+func (f VariantFlag) Evaluate(ctx *types.ServiceContext) Result { return Evaluate(ctx, string(f)) }
+
+// Generate generates the source of the package pkg declaring a constant for every flag,
+// eg: the boolean flag "new-checkout" is declared as
+//
+//	const NewCheckout featureflag.BoolFlag = "new-checkout"
+//
+// and the variant "blue" of the variant flag "checkout-theme" as
+//
+//	const CheckoutThemeBlue = "blue"
+func Generate(pkg string, list ...*Flag) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, errors.Newf("invalid package name %q", pkg)
+	}
+	list = slices.Clone(list)
+	slices.SortFunc(list, func(a, b *Flag) int { return strings.Compare(a.Key, b.Key) })
+
+	names := make(map[string]string)
+	declare := func(name, of string) error {
+		if prev, ok := names[name]; ok {
+			return errors.Newf("%s and %s are both generated as %s", prev, of, name)
+		}
+		names[name] = of
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by \"gg featureflag\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if len(list) > 0 {
+		buf.WriteString("import \"github.com/forbearing/gst/featureflag\"\n")
+	}
+	for _, f := range list {
+		if err := f.Normalize(); err != nil {
+			return nil, errors.Wrapf(err, "flag %q", f.Key)
+		}
+		name := identifier(f.Key)
+		if err := declare(name, fmt.Sprintf("flag %q", f.Key)); err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("\n")
+		comment(&buf, name, f.Description)
+		if f.Type == modelfeatureflag.FlagTypeBoolean {
+			fmt.Fprintf(&buf, "const %s featureflag.BoolFlag = %q\n", name, f.Key)
+			continue
+		}
+		fmt.Fprintf(&buf, "const %s featureflag.VariantFlag = %q\n\n", name, f.Key)
+		fmt.Fprintf(&buf, "// The variants of %s.\nconst (\n", name)
+		for _, v := range f.Variants {
+			vname := name + identifier(v.Key)
+			if err := declare(vname, fmt.Sprintf("variant %q of flag %q", v.Key, f.Key)); err != nil {
+				return nil, err
+			}
+			if len(v.Description) > 0 {
+				fmt.Fprintf(&buf, "%s = %q // %s\n", vname, v.Key, oneLine(v.Description))
+			} else {
+				fmt.Fprintf(&buf, "%s = %q\n", vname, v.Key)
+			}
+		}
+		buf.WriteString(")\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// identifier converts the key to an exported identifier, eg: "new-checkout" to "NewCheckout".
+func identifier(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteRune('V')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	return b.String()
+}
+
+func comment(buf *bytes.Buffer, name, description string) {
+	if len(description) == 0 {
+		fmt.Fprintf(buf, "// %s is a feature flag.\n", name)
+		return
+	}
+	fmt.Fprintf(buf, "// %s %s\n", name, oneLine(description))
+}
+
+func oneLine(s string) string { return strings.Join(strings.Fields(s), " ") }
@@ -0,0 +1,175 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/database"
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/provider/redis"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPollInterval is the default interval of the reload of the flags.
+	DefaultPollInterval = 30 * time.Second
+	// DefaultAuditRetention is the default retention of the evaluation audit trail.
+	DefaultAuditRetention = 30 * 24 * time.Hour
+
+	// changedChannel propagates the changes of the flags to the other instances.
+	changedChannel = "gst:featureflag:changed"
+)
+
+// Config is the configuration of the flags stored in the database, see Enable.
+type Config struct {
+	// PollInterval is the interval of the reload of the flags, default is DefaultPollInterval.
+	// The changes made through the API are applied immediately, the interval bounds the delay
+	// of the other changes, eg: an instance missing the redis notification.
+	PollInterval time.Duration
+
+	// AuditRetention is the retention of the evaluation audit trail, default is DefaultAuditRetention.
+	AuditRetention time.Duration
+}
+
+var (
+	flags   atomic.Pointer[map[string]*Flag]
+	enabled atomic.Bool
+
+	cfg       Config
+	startOnce sync.Once
+	stopCh    = make(chan struct{})
+	stopOnce  sync.Once
+
+	// node identifies this instance in the change notifications.
+	node = func() string {
+		hostname, _ := os.Hostname()
+		return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}()
+)
+
+// Enable loads the flags from the database, it's called by module/featureflag.
+// The flags are loaded on the first evaluation and reloaded every poll interval,
+// the evaluation audit trail older than the retention is cleaned up hourly.
+func Enable(c Config) {
+	if c.PollInterval <= 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.AuditRetention <= 0 {
+		c.AuditRetention = DefaultAuditRetention
+	}
+	if enabled.Swap(true) {
+		return
+	}
+	cfg = c
+	model.Register[*modelfeatureflag.Flag]()
+	model.Register[*modelfeatureflag.Evaluation]()
+	cronjob.Register(cleanupAudit, "0 40 * * * *", "cleanup feature flag evaluations", cronjob.Config{Singleton: true, Overlap: cronjob.OverlapSkip})
+}
+
+// Set replaces the cached flags, eg: the flags defined in code when the database is not used.
+// The flags are replaced again by the next reload if Enable was called.
+func Set(list ...*Flag) error {
+	m := make(map[string]*Flag, len(list))
+	for _, f := range list {
+		if err := f.Normalize(); err != nil {
+			return err
+		}
+		m[f.Key] = f
+	}
+	flags.Store(&m)
+	return nil
+}
+
+// Invalidate reloads the flags and notifies the other instances to reload them,
+// it's called after the flags are changed.
+func Invalidate() {
+	if !enabled.Load() {
+		return
+	}
+	if err := reload(); err != nil {
+		zap.S().Errorw("failed to reload feature flags", "error", err.Error())
+	}
+	if config.App.Redis.Enable {
+		if err := redis.Publish(changedChannel, []byte(node)); err != nil {
+			zap.S().Warnw("failed to propagate feature flag change", "error", err.Error())
+		}
+	}
+}
+
+// Close stops the reload of the flags and flushes the pending evaluations, it's called by bootstrap cleanup.
+func Close() {
+	stopOnce.Do(func() { close(stopCh) })
+	flushAudit()
+}
+
+// snapshot returns the cached flags, they are loaded on the first call if enabled.
+func snapshot() map[string]*Flag {
+	if enabled.Load() {
+		startOnce.Do(start)
+	}
+	if m := flags.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// start loads the flags and keeps them up to date.
+func start() {
+	if err := reload(); err != nil {
+		zap.S().Errorw("failed to load feature flags", "error", err.Error())
+	}
+	if config.App.Redis.Enable {
+		if err := redis.Subscribe(context.Background(), changedChannel, func(payload []byte) {
+			if string(payload) == node {
+				return
+			}
+			if err := reload(); err != nil {
+				zap.S().Errorw("failed to reload feature flags", "error", err.Error())
+			}
+		}); err != nil {
+			zap.S().Warnw("failed to subscribe feature flag changes", "error", err.Error())
+		}
+	}
+	go poll()
+}
+
+func poll() {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := reload(); err != nil {
+				zap.S().Warnw("failed to reload feature flags", "error", err.Error())
+			}
+		}
+	}
+}
+
+// reload replaces the cached flags with the flags in the database,
+// the invalid flags are skipped so that one of them doesn't break the others.
+func reload() error {
+	list := make([]*Flag, 0)
+	if err := database.Database[*Flag](nil).List(&list); err != nil {
+		return err
+	}
+	m := make(map[string]*Flag, len(list))
+	for _, f := range list {
+		if err := f.Normalize(); err != nil {
+			zap.S().Warnw("skip invalid feature flag", "flag", f.Key, "error", err.Error())
+			continue
+		}
+		m[f.Key] = f
+	}
+	flags.Store(&m)
+	return nil
+}
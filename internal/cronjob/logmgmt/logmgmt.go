@@ -1,23 +1,29 @@
 package cronjoblogmgmt
 
 import (
+	"context"
+	"crypto/ed25519"
 	"time"
 
 	"github.com/forbearing/gst/database"
 	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/pkg/auditmanager"
 )
 
-// Cleanup will delete logs older than 3 months
+// Cleanup will delete logs older than 3 months.
+// Operation logs are kept when they are append-only.
 func Cleanup() error {
 	end := time.Now().Add(-3 * 30 * 24 * time.Hour)
-	oplogs := make([]*modellogmgmt.OperationLog, 0)
 
-	if err := database.Database[*modellogmgmt.OperationLog](nil).WithTimeRange("created_at", time.Time{}, end).List(&oplogs); err != nil {
-		logger.Cronjob.Error(err)
-	}
-	if err := database.Database[*modellogmgmt.OperationLog](nil).WithPurge().Delete(oplogs...); err != nil {
-		logger.Cronjob.Error(err)
+	if !modellogmgmt.AppendOnly() {
+		oplogs := make([]*modellogmgmt.OperationLog, 0)
+		if err := database.Database[*modellogmgmt.OperationLog](nil).WithTimeRange("created_at", time.Time{}, end).List(&oplogs); err != nil {
+			logger.Cronjob.Error(err)
+		}
+		if err := database.Database[*modellogmgmt.OperationLog](nil).WithPurge().Delete(oplogs...); err != nil {
+			logger.Cronjob.Error(err)
+		}
 	}
 
 	loginLogs := make([]*modellogmgmt.LoginLog, 0)
@@ -30,3 +36,15 @@ func Cleanup() error {
 
 	return nil
 }
+
+// Checkpoint returns the cronjob that signs the heads of the operation log hash chains
+// and exports the checkpoints to sink.
+func Checkpoint(key ed25519.PrivateKey, sink auditmanager.CheckpointSink) func() error {
+	return func() error {
+		if err := auditmanager.Checkpoint(context.Background(), key, sink); err != nil {
+			logger.Cronjob.Error(err)
+			return err
+		}
+		return nil
+	}
+}
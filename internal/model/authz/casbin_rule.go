@@ -7,11 +7,11 @@ import "github.com/forbearing/gst/model"
 // 或 gormadapter.NewAdapterByDB(database.DB) 来创建;
 // NOTE: ID 类型必须是整型
 //
-// -- 权限策略
-// INSERT INTO casbin_rule (ptype, v0, v1, v2, v3) VALUES
-// ('p', 'role_admin', '/api/config/*', 'GET', 'allow'),
-// ('p', 'role_admin', '/api/config/*', 'POST', 'allow'),
-// ('p', 'role_user', '/api/config/file', 'GET', 'allow');
+// -- 权限策略, v4 为条件表达式, 无条件时为 "true"
+// INSERT INTO casbin_rule (ptype, v0, v1, v2, v3, v4) VALUES
+// ('p', 'role_admin', '/api/config/*', 'GET', 'allow', 'true'),
+// ('p', 'role_admin', '/api/config/*', 'POST', 'allow', 'true'),
+// ('p', 'role_user', '/api/config/file', 'GET', 'allow', 'ipIn(r.ctx, "10.0.0.0/8")');
 //
 // -- 角色关系
 // INSERT INTO casbin_rule (ptype, v0, v1) VALUES
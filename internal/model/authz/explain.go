@@ -0,0 +1,110 @@
+package modelauthz
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// Explain explains the authorization decision of a request.
+type Explain struct {
+	model.Empty
+}
+
+// Simulate evaluates proposed role and permission changes before they are saved.
+type Simulate struct {
+	model.Empty
+}
+
+// ExplainReq describes the request to explain.
+// The subject is resolved like middleware.Authz does: "root" and "admin" use the username,
+// other users use the user id. Subject takes precedence over Username.
+type ExplainReq struct {
+	Username string `json:"username,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	// Tenant is only used when tenant RBAC is enabled, default is "default".
+	Tenant string `json:"tenant,omitempty"`
+	Path   string `json:"path"`
+	Method string `json:"method"`
+
+	// The request context conditional policies are evaluated against.
+	// Time defaults to now, Owner defaults to the owner of the resource loaded by the route of Path.
+	Time     *time.Time        `json:"time,omitempty"`
+	ClientIP string            `json:"client_ip,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+}
+
+// ExplainRsp is the authorization decision and what it was derived from.
+type ExplainRsp struct {
+	Subject string        `json:"subject"`
+	Tenant  string        `json:"tenant,omitempty"`
+	Path    string        `json:"path"`
+	Method  string        `json:"method"`
+	Allowed bool          `json:"allowed"`
+	Effect  consts.Effect `json:"effect"`
+
+	// Bypass is the role that allows the request regardless of policies,
+	// eg: "admin", or "super_admin" with tenant RBAC.
+	Bypass string `json:"bypass,omitempty"`
+	// MatchedPolicies are the casbin policy lines that allowed the request.
+	MatchedPolicies [][]string `json:"matched_policies"`
+	// UnmetPolicies are the policies of the subject's roles matching the path and method
+	// whose condition is not met, the last field of a policy line is its condition.
+	UnmetPolicies [][]string `json:"unmet_policies"`
+	// RoleChains are the role inheritance chains of the subject, eg: ["user_id", "editor", "viewer"].
+	RoleChains [][]string `json:"role_chains"`
+
+	// Permissions are the permissions matching the path and method.
+	Permissions []*Permission `json:"permissions"`
+	// Menus are the menus whose api contains one of Permissions.
+	Menus []*Menu `json:"menus"`
+	// Buttons are the buttons of Menus.
+	Buttons []*Button `json:"buttons"`
+	// Roles are the roles owning one of Menus, granting any of them allows the request.
+	Roles []*Role `json:"roles"`
+}
+
+type PolicyChangeOp string
+
+const (
+	PolicyChangeGrantPermission  PolicyChangeOp = "grant_permission"
+	PolicyChangeRevokePermission PolicyChangeOp = "revoke_permission"
+	PolicyChangeAssignRole       PolicyChangeOp = "assign_role"
+	PolicyChangeUnassignRole     PolicyChangeOp = "unassign_role"
+	PolicyChangeRemoveRole       PolicyChangeOp = "remove_role"
+	// PolicyChangeSetRoleMenus replaces the role's permissions with the permissions of MenuIDs,
+	// the same as updating the role's "menu_ids".
+	PolicyChangeSetRoleMenus PolicyChangeOp = "set_role_menus"
+)
+
+// PolicyChange is a proposed role or permission change.
+type PolicyChange struct {
+	Op PolicyChangeOp `json:"op"`
+	// Tenant is only used when tenant RBAC is enabled, empty means all tenants "*" for
+	// permission changes and the request tenant for role assignments.
+	Tenant   string   `json:"tenant,omitempty"`
+	Role     string   `json:"role,omitempty"`    // role code
+	Subject  string   `json:"subject,omitempty"` // user id, or "root"/"admin", for assign_role/unassign_role
+	Resource string   `json:"resource,omitempty"`
+	Action   string   `json:"action,omitempty"`
+	MenuIDs  []string `json:"menu_ids,omitempty"` // for set_role_menus
+	// Condition is the condition of grant_permission, see rbac.Context.
+	Condition string `json:"condition,omitempty"`
+	// Conditions are the menu conditions of set_role_menus, see Role.Conditions.
+	Conditions map[string]any `json:"conditions,omitempty"`
+}
+
+// SimulateReq explains the request against a copy of the enforcer with Changes applied.
+type SimulateReq struct {
+	ExplainReq
+	Changes []PolicyChange `json:"changes"`
+}
+
+type SimulateRsp struct {
+	Current   *ExplainRsp `json:"current"`
+	Simulated *ExplainRsp `json:"simulated"`
+	// Changed reports whether the changes flip the decision.
+	Changed bool `json:"changed"`
+}
@@ -2,6 +2,8 @@ package modelauthz
 
 import (
 	serrors "errors"
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/cockroachdb/errors"
@@ -17,10 +19,16 @@ import (
 )
 
 type Role struct {
-	Name    string `json:"name,omitempty" schema:"name" gorm:"size:191;unique"`
-	Code    string `json:"code,omitempty" schema:"code" gorm:"size:191;unique"`
+	// Name and Code are unique in the tenant, different tenants may define the same role.
+	Name    string `json:"name,omitempty" schema:"name" gorm:"size:191;uniqueIndex:idx_role_tenant_name,priority:2"`
+	Code    string `json:"code,omitempty" schema:"code" gorm:"size:191;uniqueIndex:idx_role_tenant_code,priority:2"`
 	Default *bool  `json:"default,omitempty" schema:"default"`
 
+	// TenantID is the tenant owning the role when tenant RBAC is enabled.
+	// The role's permissions only apply in that tenant and it can only be granted there.
+	// Empty means a global role, its permissions apply in every tenant it is granted in.
+	TenantID string `json:"tenant_id,omitempty" schema:"tenant_id" gorm:"size:191;index;uniqueIndex:idx_role_tenant_name,priority:1;uniqueIndex:idx_role_tenant_code,priority:1"`
+
 	// Scope holds generic constraints for regional roles.
 	// Keys and values are user-defined and framework-agnostic.
 	Scope datatypes.JSONMap `json:"scope,omitempty"`
@@ -30,6 +38,12 @@ type Role struct {
 	MenuPartialIDs datatypes.JSONSlice[string] `json:"menu_partial_ids,omitempty"`
 	ButtonIDs      datatypes.JSONSlice[string] `json:"button_ids,omitempty"`
 
+	// Conditions restricts the menus and buttons granted to the role, keyed by menu or button id,
+	// eg: {"menu_id": "timeBetween(r.ctx, '09:00', '18:00')"}, see rbac.Context for the syntax.
+	// The permissions derived from a menu are only granted when its condition is met,
+	// and the menus and buttons whose condition is not met are hidden.
+	Conditions datatypes.JSONMap `json:"conditions,omitempty"`
+
 	Menus        []*Menu `json:"menus,omitempty" gorm:"-"`
 	MenuPartials []*Menu `json:"menu_partials,omitempty" gorm:"-"`
 
@@ -45,7 +59,7 @@ func (r *Role) CreateAfter(ctx *types.ModelContext) error {
 		return err
 	}
 	e1 := r.UpdatePermission(ctx)
-	e2 := rbac.RBAC().AddRole(r.Code)
+	e2 := rbac.Domain(r.TenantID).AddRole(r.Code)
 	return serrors.Join(e1, e2)
 }
 
@@ -53,7 +67,7 @@ func (r *Role) CreateAfter(ctx *types.ModelContext) error {
 // more details see "UpdatePermission".
 func (r *Role) UpdateBefore(ctx *types.ModelContext) error {
 	e1 := r.UpdatePermission(ctx)
-	e2 := rbac.RBAC().AddRole(r.Code)
+	e2 := rbac.Domain(r.TenantID).AddRole(r.Code)
 	return serrors.Join(e1, e2)
 }
 
@@ -64,7 +78,7 @@ func (r *Role) DeleteBefore(ctx *types.ModelContext) error {
 		return err
 	}
 
-	if err := rbac.RBAC().RemoveRole(r.Code); err != nil {
+	if err := rbac.Domain(r.TenantID).RemoveRole(r.Code); err != nil {
 		return err
 	}
 
@@ -90,7 +104,7 @@ func (r *Role) DeleteBefore(ctx *types.ModelContext) error {
 
 	// revoke the role's permissions
 	for _, p := range permissions {
-		if err := rbac.RBAC().RevokePermission(r.Code, p.Resource, p.Action); err != nil {
+		if err := rbac.Domain(r.TenantID).RevokePermission(r.Code, p.Resource, p.Action); err != nil {
 			return err
 		}
 	}
@@ -106,51 +120,32 @@ func (r *Role) UpdatePermission(ctx *types.ModelContext) error {
 	// "MenuIds" is the frontend menus, "MenuPartialIds" is the frontend menus group that has no menus.
 	// A "Menu" contains one or multiple backend apis, each api binding one or multiple permissions.
 
-	var (
-		newMenus       = make([]*Menu, 0)
-		newPermissions = make([]*Permission, 0)
-	)
-
 	o := new(Role)
 	if err := database.Database[*Role](ctx.DatabaseContext()).Get(o, r.ID); err != nil {
 		zap.S().Error(err)
 		return err
 	}
 
-	// query the new role's menus
-	if err := database.Database[*Menu](ctx.DatabaseContext()).
-		WithQuery(&Menu{Base: model.Base{ID: strings.Join(r.MenuIDs, ",")}}).
-		List(&newMenus); err != nil {
+	// query the new role's permissions and their conditions.
+	newPermissions, err := MenuGrants(ctx.DatabaseContext(), r.MenuIDs, r.Conditions)
+	if err != nil {
 		zap.S().Error(err)
 		return err
 	}
 
-	// query the new role's permissions
-	for _, m := range newMenus {
-		// zap.S().Infow("menu", "label", m.Label, "api", m.API)
-		result := make([]*Permission, 0)
-		if err := database.Database[*Permission](ctx.DatabaseContext()).
-			// query the menu's permissions, multiple resources separated by ","
-			WithQuery(&Permission{Resource: strings.Join(m.API, ",")}).
-			List(&result); err != nil {
-			zap.S().Error(err)
-			return err
-		}
-		newPermissions = append(newPermissions, result...)
-	}
-
 	for _, p := range newPermissions {
-		zap.S().Infow("new permission", "role", r.Code, "resource", p.Resource, "action", p.Action, "effect", consts.EffectAllow)
+		zap.S().Infow("new permission", "role", r.Code, "resource", p.Resource, "action", p.Action, "effect", consts.EffectAllow, "condition", p.Condition)
 	}
 
-	// revoke all existing policies for this role to avoid leftovers
-	if err := rbac.RBAC().RevokePermission(r.Code, "", ""); err != nil {
+	// revoke all existing policies for this role to avoid leftovers,
+	// the old tenant is used in case the role moved to another tenant.
+	if err := rbac.Domain(o.TenantID).RevokePermission(r.Code, "", ""); err != nil {
 		zap.S().Error(err)
 		return err
 	}
 	// grant the new role's permissions
 	for _, p := range newPermissions {
-		if err := rbac.RBAC().GrantPermission(r.Code, p.Resource, p.Action); err != nil {
+		if err := rbac.Domain(r.TenantID).GrantConditionalPermission(r.Code, p.Resource, p.Action, p.Condition); err != nil {
 			zap.S().Error(err)
 			return err
 		}
@@ -172,11 +167,11 @@ func (r *Role) validate(ctx *types.ModelContext) error {
 		return errors.New("role code is required")
 	}
 
-	// Ensure uniqueness on (name, code)
+	// Ensure uniqueness on (name, code) in the tenant
 	roles := make([]*Role, 0)
 	if err := database.Database[*Role](ctx.DatabaseContext()).
 		WithLimit(1).
-		WithQuery(&Role{Name: r.Name, Code: r.Code}).
+		WithQuery(&Role{Name: r.Name, Code: r.Code}, types.QueryConfig{RawQuery: "tenant_id = ?", RawQueryArgs: []any{r.TenantID}}).
 		List(&roles); err != nil {
 		return err
 	}
@@ -184,15 +179,120 @@ func (r *Role) validate(ctx *types.ModelContext) error {
 		return errors.New("role already exists")
 	}
 
+	for id, v := range r.Conditions {
+		cond, ok := v.(string)
+		if !ok {
+			return errors.Newf("condition of %s must be a string", id)
+		}
+		if err := rbac.ValidateCondition(cond); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// FindRoleByCode returns the role of the code in the tenant, or the global role of the code
+// if the tenant doesn't define it, nil if neither exists.
+func FindRoleByCode(ctx *types.DatabaseContext, code, tenantID string) (*Role, error) {
+	roles := make([]*Role, 0, 2)
+	if err := database.Database[*Role](ctx).
+		WithQuery(&Role{Code: code}, types.QueryConfig{RawQuery: "tenant_id IN (?)", RawQueryArgs: []any{[]string{tenantID, ""}}}).
+		List(&roles); err != nil {
+		return nil, err
+	}
+	var found *Role
+	for _, role := range roles {
+		if role.TenantID == tenantID {
+			return role, nil
+		}
+		found = role
+	}
+	return found, nil
+}
+
+// Condition returns the condition of the menu or button, empty means unconditional.
+func (r *Role) Condition(id string) string {
+	cond, _ := r.Conditions[id].(string)
+	return strings.TrimSpace(cond)
+}
+
+// PermissionGrant is a permission granted through menus and the condition it is granted with.
+type PermissionGrant struct {
+	Resource  string
+	Action    string
+	Condition string
+}
+
+// MenuGrants returns the permissions of the menus with the conditions of the menus.
+// A permission owned by several menus is granted when any of their conditions is met,
+// and unconditionally when any of them is unconditional.
+func MenuGrants(ctx *types.DatabaseContext, menuIDs []string, conditions map[string]any) ([]*PermissionGrant, error) {
+	grants := make([]*PermissionGrant, 0)
+	if len(menuIDs) == 0 {
+		return grants, nil
+	}
+	menus := make([]*Menu, 0)
+	if err := database.Database[*Menu](ctx).
+		WithQuery(&Menu{Base: model.Base{ID: strings.Join(menuIDs, ",")}}).
+		List(&menus); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	// unconditional marks the grants having an unconditional menu.
+	unconditional := make(map[string]bool)
+	conds := make(map[string][]string)
+	for _, m := range menus {
+		if len(m.API) == 0 {
+			continue
+		}
+		result := make([]*Permission, 0)
+		// query the menu's permissions, multiple resources separated by ","
+		if err := database.Database[*Permission](ctx).
+			WithQuery(&Permission{Resource: strings.Join(m.API, ",")}).
+			List(&result); err != nil {
+			return nil, err
+		}
+		cond, _ := conditions[m.ID].(string)
+		cond = strings.TrimSpace(cond)
+		for _, p := range result {
+			key := p.Resource + " " + p.Action
+			if _, ok := index[key]; !ok {
+				index[key] = len(grants)
+				grants = append(grants, &PermissionGrant{Resource: p.Resource, Action: p.Action})
+			}
+			if len(cond) == 0 {
+				unconditional[key] = true
+			} else if !slices.Contains(conds[key], cond) {
+				conds[key] = append(conds[key], cond)
+			}
+		}
+	}
+	for key, i := range index {
+		if unconditional[key] || len(conds[key]) == 0 {
+			continue
+		}
+		if len(conds[key]) == 1 {
+			grants[i].Condition = conds[key][0]
+			continue
+		}
+		parts := make([]string, 0, len(conds[key]))
+		for _, c := range conds[key] {
+			parts = append(parts, fmt.Sprintf("(%s)", c))
+		}
+		grants[i].Condition = strings.Join(parts, " || ")
+	}
+	return grants, nil
+}
+
 func (r *Role) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	if r == nil {
 		return nil
 	}
 	enc.AddString("code", r.Code)
 	enc.AddString("name", r.Name)
+	enc.AddString("tenant_id", r.TenantID)
 	enc.AddString("id", r.ID)
 	return nil
 }
@@ -19,6 +19,9 @@ import (
 // RolePermission is deprecated, operations CasbinRule directly.
 type RolePermission struct {
 	Role string `json:"role" schema:"role"`
+	// TenantID is the tenant of the role, the permission is granted in it.
+	// It's resolved from the role when empty, see FindRoleByCode.
+	TenantID string `json:"tenant_id,omitempty" schema:"tenant_id" gorm:"size:191;index"`
 
 	Resource string        `json:"resource" schema:"resource"`
 	Action   string        `json:"action" schema:"action"`
@@ -28,7 +31,7 @@ type RolePermission struct {
 }
 
 func (r *RolePermission) Purge() bool { return true }
-func (r *RolePermission) CreateBefore(*types.ModelContext) error {
+func (r *RolePermission) CreateBefore(ctx *types.ModelContext) error {
 	if len(r.Role) == 0 {
 		return errors.New("role_id is required")
 	}
@@ -45,15 +48,28 @@ func (r *RolePermission) CreateBefore(*types.ModelContext) error {
 	default:
 		r.Effect = consts.EffectAllow
 	}
+	// The permission is granted in the tenant of the role, the same as Role.UpdatePermission.
+	role, err := FindRoleByCode(ctx.DatabaseContext(), r.Role, r.TenantID)
+	if err != nil {
+		return err
+	}
+	if role != nil {
+		r.TenantID = role.TenantID
+	}
+
 	// If the role already has the permission(Resource+Action), set same id to just update it.
-	r.SetID(util.HashID(r.Role, r.Resource, r.Action))
+	if len(r.TenantID) > 0 {
+		r.SetID(util.HashID(r.TenantID, r.Role, r.Resource, r.Action))
+	} else {
+		r.SetID(util.HashID(r.Role, r.Resource, r.Action))
+	}
 
 	return nil
 }
 
 func (r *RolePermission) CreateAfter(*types.ModelContext) error {
 	// grant the permission: (role, resource, action)
-	return rbac.RBAC().GrantPermission(r.Role, r.Resource, r.Action)
+	return rbac.Domain(r.TenantID).GrantPermission(r.Role, r.Resource, r.Action)
 }
 
 func (r *RolePermission) DeleteBefore(ctx *types.ModelContext) error {
@@ -61,8 +77,8 @@ func (r *RolePermission) DeleteBefore(ctx *types.ModelContext) error {
 	if err := database.Database[*RolePermission](ctx.DatabaseContext()).Get(r, r.ID); err != nil {
 		return err
 	}
-	// revoke the role's permission
-	return rbac.RBAC().RevokePermission(r.Role, r.Resource, r.Action)
+	// revoke the role's permission in the tenant it was granted in
+	return rbac.Domain(r.TenantID).RevokePermission(r.Role, r.Resource, r.Action)
 }
 
 func (r *RolePermission) MarshalLogObject(enc zapcore.ObjectEncoder) error {
@@ -70,6 +86,7 @@ func (r *RolePermission) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 		return nil
 	}
 	enc.AddString("role", r.Role)
+	enc.AddString("tenant_id", r.TenantID)
 	enc.AddString("resource", r.Resource)
 	enc.AddString("action", r.Action)
 	enc.AddString("effect", string(r.Effect))
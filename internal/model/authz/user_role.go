@@ -9,6 +9,7 @@ import (
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
 	"github.com/forbearing/gst/util"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,6 +21,12 @@ type UserRole struct {
 	RoleCode string `json:"rolecode,omitempty" schema:"rolecode"` // 角色Code, 用于 RBAC 角色控制和前端查询
 	Username string `json:"username,omitempty" schema:"username"` // 用户名, 用于 RBAC 用户控制和前端查询
 
+	// TenantID is the tenant the role is granted in when tenant RBAC is enabled.
+	// The API grants the role in the request tenant if it's empty, and only super admins
+	// can grant roles in other tenants. Created without a request, eg: in code, it defaults
+	// to the role's tenant, then the default tenant. It is always empty when tenant RBAC is disabled.
+	TenantID string `json:"tenant_id,omitempty" schema:"tenant_id" gorm:"size:191;index"`
+
 	User *modeliamuser.User `json:"user,omitempty" gorm:"-"`
 	Role *Role              `json:"role,omitempty" gorm:"-"`
 
@@ -44,8 +51,26 @@ func (r *UserRole) CreateBefore(ctx *types.ModelContext) error {
 	}
 	r.Username, r.RoleCode = user.Username, role.Code
 
+	if !rbac.TenantEnabled() {
+		r.TenantID = ""
+	} else {
+		if len(role.TenantID) > 0 {
+			if len(r.TenantID) > 0 && r.TenantID != role.TenantID {
+				return fmt.Errorf("role %q belongs to tenant %q and can not be granted in tenant %q", role.Code, role.TenantID, r.TenantID)
+			}
+			r.TenantID = role.TenantID
+		}
+		if len(r.TenantID) == 0 {
+			r.TenantID = consts.AUTHZ_TENANT_DEFAULT
+		}
+	}
+
 	// If the user already has the role, set same id to just update it.
-	r.SetID(util.HashID(r.UserID, r.RoleID))
+	if len(r.TenantID) == 0 {
+		r.SetID(util.HashID(r.UserID, r.RoleID))
+	} else {
+		r.SetID(util.HashID(r.UserID, r.RoleID, r.TenantID))
+	}
 
 	return nil
 }
@@ -55,7 +80,7 @@ func (r *UserRole) CreateAfter(ctx *types.ModelContext) error {
 		return err
 	}
 	// NOTE: must be role name not role id.
-	if err := rbac.RBAC().AssignRole(r.UserID, r.RoleCode); err != nil {
+	if err := rbac.Domain(r.TenantID).AssignRole(r.UserID, r.RoleCode); err != nil {
 		return err
 	}
 
@@ -65,7 +90,7 @@ func (r *UserRole) CreateAfter(ctx *types.ModelContext) error {
 		return err
 	}
 	casbinRules := make([]*CasbinRule, 0)
-	if err := database.Database[*CasbinRule](ctx.DatabaseContext()).WithLimit(1).WithQuery(&CasbinRule{V0: r.UserID, V1: r.RoleCode}).List(&casbinRules); err != nil {
+	if err := database.Database[*CasbinRule](ctx.DatabaseContext()).WithLimit(1).WithQuery(&CasbinRule{V0: r.UserID, V1: r.RoleCode, V2: r.TenantID}).List(&casbinRules); err != nil {
 		return err
 	}
 	if len(casbinRules) > 0 {
@@ -83,7 +108,7 @@ func (r *UserRole) DeleteBefore(ctx *types.ModelContext) error {
 		return err
 	}
 	// NOTE: must be role name not role id.
-	return rbac.RBAC().UnassignRole(r.UserID, r.RoleCode)
+	return rbac.Domain(r.TenantID).UnassignRole(r.UserID, r.RoleCode)
 }
 
 func (r *UserRole) MarshalLogObject(enc zapcore.ObjectEncoder) error {
@@ -94,6 +119,7 @@ func (r *UserRole) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddString("role_id", r.RoleID)
 	enc.AddString("user", r.Username)
 	enc.AddString("role", r.RoleCode)
+	enc.AddString("tenant_id", r.TenantID)
 	_ = enc.AddObject("base", &r.Base)
 	return nil
 }
@@ -0,0 +1,26 @@
+package modelcronjob
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/model"
+)
+
+// DeadLetter is a failed run of a cronjob, after all retries, kept until it's replayed or discarded.
+type DeadLetter struct {
+	Job      string     `json:"job" schema:"job" gorm:"size:191;index"`
+	RunID    string     `json:"run_id" schema:"run_id"`
+	Node     string     `json:"node" schema:"node"`
+	Trigger  RunTrigger `json:"trigger" schema:"trigger"`
+	Status   RunStatus  `json:"status" schema:"status"`
+	Error    string     `json:"error,omitempty"`
+	Attempts int        `json:"attempts"`
+	FailedAt time.Time  `json:"failed_at" gorm:"index"`
+
+	// Replays is the number of replays, ReplayedAt is the time of the last replay.
+	// The result of a replay is the run whose ReplayOf is the dead letter.
+	Replays    int        `json:"replays"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+
+	model.Base
+}
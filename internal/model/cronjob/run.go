@@ -0,0 +1,46 @@
+package modelcronjob
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/model"
+)
+
+// RunStatus is the result of a cronjob run.
+type RunStatus string
+
+const (
+	RunStatusSuccess RunStatus = "success"
+	RunStatusFailure RunStatus = "failure"
+	RunStatusTimeout RunStatus = "timeout"
+	// RunStatusSkipped is a run skipped because the previous run on the same instance was still running.
+	RunStatusSkipped RunStatus = "skipped"
+)
+
+// RunTrigger is what started a cronjob run.
+type RunTrigger string
+
+const (
+	RunTriggerSchedule  RunTrigger = "schedule"
+	RunTriggerImmediate RunTrigger = "immediate"
+	RunTriggerManual    RunTrigger = "manual"
+	RunTriggerReplay    RunTrigger = "replay" // RunTriggerReplay is the replay of a dead letter, see DeadLetter
+)
+
+// Run is one execution of a cronjob.
+type Run struct {
+	Job        string     `json:"job" schema:"job" gorm:"size:191;index"`
+	Node       string     `json:"node" schema:"node"` // Node is the instance that executed the run, "hostname-pid"
+	Trigger    RunTrigger `json:"trigger" schema:"trigger"`
+	Status     RunStatus  `json:"status" schema:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt time.Time  `json:"finished_at"`
+	Duration   int64      `json:"duration"` // milliseconds
+	// Attempts is the number of calls of the cronjob function, including the retries.
+	Attempts int `json:"attempts"`
+	// ReplayOf is the dead letter replayed by the run, see DeadLetter.
+	ReplayOf string `json:"replay_of,omitempty" schema:"replay_of"`
+
+	model.Base
+}
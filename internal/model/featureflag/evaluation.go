@@ -0,0 +1,53 @@
+package modelfeatureflag
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/model"
+)
+
+// Reason explains the variant served by an evaluation.
+type Reason string
+
+const (
+	ReasonNotFound Reason = "not_found" // the flag doesn't exist, the default value of the caller is served
+	ReasonDisabled Reason = "disabled"  // the flag is disabled, the OffVariant is served
+	ReasonRule     Reason = "rule"      // a rule matched
+	ReasonDefault  Reason = "default"   // no rule matched, the DefaultVariant is served
+)
+
+// Evaluation is the audit trail of an evaluation of a flag whose Audit is enabled.
+type Evaluation struct {
+	Flag        string    `json:"flag" schema:"flag" gorm:"size:191;index"`
+	Variant     string    `json:"variant" schema:"variant"`
+	Reason      Reason    `json:"reason" schema:"reason"`
+	Rule        *int      `json:"rule,omitempty"` // Rule is the index of the matching rule
+	UserID      string    `json:"user_id,omitempty" schema:"user_id" gorm:"size:191;index"`
+	TenantID    string    `json:"tenant_id,omitempty" schema:"tenant_id"`
+	Environment string    `json:"environment,omitempty" schema:"environment"`
+	RequestID   string    `json:"request_id,omitempty" schema:"request_id"`
+	EvaluatedAt time.Time `json:"evaluated_at" gorm:"index"`
+
+	model.Base
+}
+
+func (*Evaluation) Purge() bool { return true }
+
+// Evaluate evaluates all flags for the current user.
+type Evaluate struct {
+	model.Empty
+}
+
+type EvaluateRsp struct {
+	Items []Result `json:"items"`
+	Total int64    `json:"total"`
+}
+
+// Result is the result of the evaluation of a flag.
+type Result struct {
+	Flag    string `json:"flag"`
+	Variant string `json:"variant,omitempty"` // Variant is empty if the caller's default is served
+	Value   any    `json:"value,omitempty"`
+	Reason  Reason `json:"reason"`
+	Rule    *int   `json:"rule,omitempty"` // Rule is the index of the matching rule
+}
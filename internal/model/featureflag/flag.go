@@ -0,0 +1,189 @@
+package modelfeatureflag
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+	"gorm.io/datatypes"
+)
+
+// FlagType is the type of the values of a flag.
+type FlagType string
+
+const (
+	// FlagTypeBoolean flags serve the variants "true" and "false".
+	FlagTypeBoolean FlagType = "boolean"
+	// FlagTypeVariant flags serve one of their Variants, eg: the themes of an A/B test.
+	FlagTypeVariant FlagType = "variant"
+)
+
+// The variants of the boolean flags.
+const (
+	VariantTrue  = "true"
+	VariantFalse = "false"
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// Flag is a feature flag, it's evaluated for a subject (user, groups, tenant, environment) as follows:
+//  1. the OffVariant is served if the flag is disabled
+//  2. the first matching rule serves its variant
+//  3. the DefaultVariant is served if no rule matches
+type Flag struct {
+	// Key references the flag in the code, eg: "new-checkout". It's unique and can not be changed.
+	Key         string   `json:"key" schema:"key" gorm:"size:191;uniqueIndex"`
+	Description string   `json:"description,omitempty"`
+	Type        FlagType `json:"type" schema:"type"`
+	Enabled     *bool    `json:"enabled,omitempty" schema:"enabled"`
+
+	// Variants are the values served by the variant flags, the boolean flags have "true" and "false".
+	Variants datatypes.JSONSlice[Variant] `json:"variants,omitempty"`
+
+	// DefaultVariant is served if no rule matches, default is "true" for the boolean flags.
+	DefaultVariant string `json:"default_variant,omitempty"`
+
+	// OffVariant is served if the flag is disabled, default is "false" for the boolean flags.
+	// The variant flags serve the default value of the caller if it's empty.
+	OffVariant string `json:"off_variant,omitempty"`
+
+	// Rules are evaluated in order, the first matching rule serves its variant.
+	Rules datatypes.JSONSlice[Rule] `json:"rules,omitempty"`
+
+	// Audit records every evaluation of the flag as an Evaluation.
+	Audit bool `json:"audit,omitempty" schema:"audit"`
+
+	model.Base
+}
+
+// Variant is a value of a variant flag.
+type Variant struct {
+	Key         string `json:"key"`
+	Value       any    `json:"value,omitempty"` // Value is any json value, the Key is served if it's empty
+	Description string `json:"description,omitempty"`
+}
+
+// Rule targets the subjects matching all of its non-empty conditions,
+// a condition matches if the subject matches any of its values.
+type Rule struct {
+	Description string `json:"description,omitempty"`
+
+	Users        []string `json:"users,omitempty"`        // Users are the user ids
+	Groups       []string `json:"groups,omitempty"`       // Groups are the group ids of the users
+	Tenants      []string `json:"tenants,omitempty"`      // Tenants are the tenant ids
+	Environments []string `json:"environments,omitempty"` // Environments are the server modes, eg: "prod", "dev"
+
+	// Percentage rolls the rule out to a percentage (0-100) of the matching users, all of them if nil.
+	// The users are bucketed by a stable hash of the flag key and the user id, so a user keeps
+	// the same result as long as the percentage doesn't decrease. Anonymous subjects never match.
+	Percentage *float64 `json:"percentage,omitempty"`
+
+	// Variant is served if the rule matches.
+	Variant string `json:"variant,omitempty"`
+
+	// Rollout splits the matching users among the variants by weight instead of serving Variant,
+	// the users are bucketed like Percentage, eg: [{"variant": "a", "weight": 50}, {"variant": "b", "weight": 50}].
+	Rollout []Weight `json:"rollout,omitempty"`
+}
+
+// Weight is the share of a variant in a Rule rollout.
+type Weight struct {
+	Variant string `json:"variant"`
+	Weight  int    `json:"weight"`
+}
+
+func (*Flag) Purge() bool { return true }
+
+// CreateBefore derives the id from the key, so a flag is created once per key.
+func (f *Flag) CreateBefore(*types.ModelContext) error {
+	if err := f.Normalize(); err != nil {
+		return err
+	}
+	f.SetID(util.HashID(f.Key))
+	return nil
+}
+func (f *Flag) UpdateBefore(*types.ModelContext) error { return f.Normalize() }
+
+// IsEnabled reports whether the flag is enabled, a flag is disabled unless enabled explicitly.
+func (f *Flag) IsEnabled() bool { return f.Enabled != nil && *f.Enabled }
+
+// HasVariant reports whether the flag serves the variant.
+func (f *Flag) HasVariant(key string) bool {
+	if f.Type == FlagTypeBoolean {
+		return key == VariantTrue || key == VariantFalse
+	}
+	return slices.ContainsFunc(f.Variants, func(v Variant) bool { return v.Key == key })
+}
+
+// Normalize applies the defaults and validates the flag.
+func (f *Flag) Normalize() error {
+	if !keyPattern.MatchString(f.Key) {
+		return errors.Newf("invalid flag key %q, it must match %s", f.Key, keyPattern.String())
+	}
+	switch f.Type {
+	case "":
+		f.Type = FlagTypeBoolean
+	case FlagTypeBoolean, FlagTypeVariant:
+	default:
+		return errors.Newf("invalid flag type %q", f.Type)
+	}
+
+	if f.Type == FlagTypeBoolean {
+		f.Variants = nil
+		if len(f.DefaultVariant) == 0 {
+			f.DefaultVariant = VariantTrue
+		}
+		if len(f.OffVariant) == 0 {
+			f.OffVariant = VariantFalse
+		}
+	} else {
+		if len(f.Variants) == 0 {
+			return errors.New("variant flag requires variants")
+		}
+		seen := make(map[string]bool, len(f.Variants))
+		for _, v := range f.Variants {
+			if len(v.Key) == 0 || seen[v.Key] {
+				return errors.Newf("variant key %q is empty or duplicated", v.Key)
+			}
+			seen[v.Key] = true
+		}
+		if len(f.DefaultVariant) == 0 {
+			return errors.New("variant flag requires default_variant")
+		}
+	}
+	if !f.HasVariant(f.DefaultVariant) {
+		return errors.Newf("unknown default_variant %q", f.DefaultVariant)
+	}
+	if len(f.OffVariant) > 0 && !f.HasVariant(f.OffVariant) {
+		return errors.Newf("unknown off_variant %q", f.OffVariant)
+	}
+
+	for i, r := range f.Rules {
+		if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+			return errors.Newf("rule %d: percentage must be between 0 and 100", i)
+		}
+		if len(r.Rollout) == 0 {
+			if !f.HasVariant(r.Variant) {
+				return errors.Newf("rule %d: unknown variant %q", i, r.Variant)
+			}
+			continue
+		}
+		total := 0
+		for _, w := range r.Rollout {
+			if !f.HasVariant(w.Variant) {
+				return errors.Newf("rule %d: unknown rollout variant %q", i, w.Variant)
+			}
+			if w.Weight < 0 {
+				return errors.Newf("rule %d: rollout weight must not be negative", i)
+			}
+			total += w.Weight
+		}
+		if total != 100 {
+			return errors.Newf("rule %d: rollout weights must sum to 100, got %d", i, total)
+		}
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package modeliamaccount
 
 type ChangePasswordReq struct {
 	OldPassword string `json:"old_password" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required"`
 }
 
 type ChangePasswordRsp struct {
@@ -1,10 +1,23 @@
 package modeliamaccount
 
+import "github.com/forbearing/gst/pkg/webauthn"
+
 type LoginReq struct {
 	Username   string `json:"username"`
 	Password   string `json:"password"`
 	TOTPCode   string `json:"totp_code,omitempty"`   // Optional TOTP code
 	BackupCode string `json:"backup_code,omitempty"` // Optional backup code
+
+	// Optional captcha token, required once the response carries the X-Captcha-Required header.
+	CaptchaToken string `json:"captcha_token,omitempty"`
+
+	// Optional WebAuthn assertion, obtained from POST /api/2fa/webauthn/login/begin.
+	WebAuthnCeremonyID string                                `json:"webauthn_ceremony_id,omitempty"`
+	WebAuthnCredential *webauthn.CredentialAssertionResponse `json:"webauthn_credential,omitempty"`
+
+	// Optional, remembers the device after a successful second factor so that the
+	// following logins from it skip the second factor until the trusted device expires.
+	RememberDevice bool `json:"remember_device,omitempty"`
 }
 
 type LoginRsp struct {
@@ -0,0 +1,41 @@
+package modeliamaccount
+
+import "time"
+
+// LoginLockKind is the subject of a login lockout.
+type LoginLockKind string
+
+const (
+	LoginLockKindAccount LoginLockKind = "account"
+	LoginLockKindIP      LoginLockKind = "ip"
+)
+
+// LoginLock describes a temporary login lockout caused by repeated failures,
+// it is stored in redis and removed automatically when it expires.
+type LoginLock struct {
+	Kind     LoginLockKind `json:"kind"`
+	Subject  string        `json:"subject"`  // Subject is the username or the client ip
+	Failures int64         `json:"failures"` // Failures is the number of failures that triggered the lockout
+	Level    int64         `json:"level"`    // Level is the number of consecutive lockouts, every level doubles the duration
+	LockedAt time.Time     `json:"locked_at"`
+	Until    time.Time     `json:"until"`
+}
+
+// LoginLockoutListReq is the request payload for listing active login lockouts.
+type LoginLockoutListReq struct{}
+
+// LoginLockoutListRsp returns all active login lockouts for privileged administrators.
+type LoginLockoutListRsp struct {
+	Items []LoginLock `json:"items"`
+	Total int64       `json:"total"`
+}
+
+// LoginUnlockReq clears the failure counters and lockouts of an account, a client ip or both.
+type LoginUnlockReq struct {
+	Username string `json:"username,omitempty"`
+	ClientIP string `json:"client_ip,omitempty"`
+}
+
+type LoginUnlockRsp struct {
+	Msg string `json:"msg,omitempty"`
+}
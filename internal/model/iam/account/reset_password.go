@@ -2,7 +2,7 @@ package modeliamaccount
 
 type ResetPasswordReq struct {
 	UserID      string `json:"user_id" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required"`
 }
 
 type ResetPasswordRsp struct {
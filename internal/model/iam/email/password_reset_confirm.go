@@ -12,7 +12,7 @@ type PasswordResetConfirm struct {
 // It carries the reset token and the new password from the password reset flow.
 type PasswordResetConfirmReq struct {
 	Token       string `json:"token" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required"`
 }
 
 // PasswordResetConfirmRsp is the response for POST /api/iam/email/password-reset-confirm.
@@ -43,6 +43,9 @@ type Group struct {
 	TenantID *string                `json:"tenant_id" gorm:"index"`
 	Tenant   *modeliamtenant.Tenant `json:"tenant,omitempty" gorm:"-"`
 
+	// ExternalID is the id of the group in the identity provider that provisions it, eg: by SCIM.
+	ExternalID *string `json:"external_id" gorm:"type:varchar(255);index"`
+
 	model.Base
 }
 
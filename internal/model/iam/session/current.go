@@ -32,7 +32,18 @@ type SessionView struct {
 	OS          string        `json:"os"`
 	EngineName  string        `json:"engine_name"`
 	BrowserName string        `json:"browser_name"`
+	DeviceID    string        `json:"device_id,omitempty"`
 	IsCurrent   bool          `json:"is_current"`
+
+	// Impersonator is set when the session is an administrator acting as the user.
+	Impersonator *ImpersonatorView `json:"impersonator,omitempty"`
+}
+
+// ImpersonatorView describes the administrator behind an impersonation session.
+type ImpersonatorView struct {
+	Username  string    `json:"username"`
+	Reason    string    `json:"reason"`
+	StartedAt time.Time `json:"started_at"`
 }
 
 // CurrentPrincipal describes the authenticated principal bound to the current session.
@@ -0,0 +1,57 @@
+package modeliamsession
+
+import (
+	"time"
+
+	. "github.com/forbearing/gst/dsl"
+	"github.com/forbearing/gst/model"
+)
+
+// Impersonation records an administrator acting as another user.
+// The record is created when the impersonation starts and EndedAt is set when it ends,
+// it is listed to the impersonated user together with the user's sessions.
+type Impersonation struct {
+	ActorID        string     `json:"actor_id" schema:"actor_id" gorm:"type:varchar(100);index"`
+	ActorUsername  string     `json:"actor_username" schema:"actor_username"`
+	TargetID       string     `json:"target_id" schema:"target_id" gorm:"type:varchar(100);index"`
+	TargetUsername string     `json:"target_username" schema:"target_username"`
+	SessionID      string     `json:"-" gorm:"type:varchar(100);index"` // SessionID is the impersonation session.
+	Reason         string     `json:"reason"`
+	ClientIP       string     `json:"client_ip"`
+	StartedAt      time.Time  `json:"started_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+
+	model.Base
+}
+
+func (Impersonation) Design() {
+	Migrate(true)
+}
+
+// ImpersonationCreateReq is the request payload for starting to impersonate a user.
+// One of UserID and Username is required.
+type ImpersonationCreateReq struct {
+	UserID   string `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Reason   string `json:"reason"` // Reason is required and recorded in the audit trail.
+	// Duration is the impersonation duration, for example "30m", default is 1 hour.
+	// It is capped by the configured max duration and the expiration of the administrator's session.
+	Duration string `json:"duration,omitempty"`
+}
+
+// ImpersonationCreateRsp returns the impersonation session.
+type ImpersonationCreateRsp struct {
+	SessionID string         `json:"session_id"`
+	Username  string         `json:"username"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Record    *Impersonation `json:"record"`
+}
+
+// ImpersonationDeleteReq is the request payload for ending the current impersonation.
+type ImpersonationDeleteReq struct{}
+
+// ImpersonationDeleteRsp returns the restored session of the administrator.
+type ImpersonationDeleteRsp struct {
+	SessionID string `json:"session_id"`
+}
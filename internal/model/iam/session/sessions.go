@@ -7,6 +7,9 @@ type SessionsListReq struct{}
 type SessionsListRsp struct {
 	Items []SessionView `json:"items"`
 	Total int64         `json:"total"`
+
+	// Impersonations are the recent impersonation events targeting the current user.
+	Impersonations []*Impersonation `json:"impersonations,omitempty"`
 }
 
 // SessionsGetReq is the request payload for loading a specified session of the current user.
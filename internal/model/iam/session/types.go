@@ -17,6 +17,15 @@ const SessionUserNamespace = SessionNamespacePrefix + ":user"
 // SessionAllNamespace stores the global session index set by session ID.
 const SessionAllNamespace = SessionNamespacePrefix + ":all"
 
+// SessionDeviceNamespace stores the devices users have logged in from by user ID and device ID.
+const SessionDeviceNamespace = SessionNamespacePrefix + ":device"
+
+// SessionTrustedDeviceNamespace stores the trusted devices by user ID and trusted device token.
+const SessionTrustedDeviceNamespace = SessionNamespacePrefix + ":trusted"
+
+// SessionLimitLockNamespace stores the lock serializing the session limit check and the session creation by user ID.
+const SessionLimitLockNamespace = SessionNamespacePrefix + ":limit"
+
 type SessionStatus string
 
 const (
@@ -38,6 +47,7 @@ type Session struct {
 	LastName  *string `json:"last_name,omitempty"`
 	GroupID   string  `json:"group_id,omitempty"`
 	GroupName string  `json:"group_name,omitempty"`
+	TenantID  string  `json:"tenant_id,omitempty"`
 
 	MustChangePassword bool `json:"must_change_password"`
 
@@ -49,12 +59,49 @@ type Session struct {
 	EngineName  string `json:"engine_name"`
 	BrowserName string `json:"browser_name"`
 
+	// DeviceID is the fingerprint of the device derived from Platform, OS and BrowserName.
+	DeviceID string `json:"device_id,omitempty"`
+
 	State      SessionStatus `json:"state"`
 	IssuedAt   time.Time     `json:"issued_at"`
 	LastSeenAt time.Time     `json:"last_seen_at"`
 	ExpiresAt  time.Time     `json:"expires_at"`
 
 	Token Token `json:"token"`
+
+	// Impersonator is set when an administrator acts as the session user,
+	// the session identifies as UserID while Impersonator is the real actor.
+	Impersonator *Impersonator `json:"impersonator,omitempty"`
+}
+
+// Impersonator is the administrator behind an impersonation session.
+type Impersonator struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	SessionID string    `json:"session_id"` // SessionID is the own session of the administrator, restored when impersonation ends.
+	Reason    string    `json:"reason"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Device is a device the user has logged in from.
+type Device struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Platform    string    `json:"platform"`
+	OS          string    `json:"os"`
+	BrowserName string    `json:"browser_name"`
+	ClientIP    string    `json:"client_ip"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// TrustedDevice is a device the user chose to remember, logins from it skip the second factor until ExpiresAt.
+type TrustedDevice struct {
+	UserID    string    `json:"user_id"`
+	DeviceID  string    `json:"device_id"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type Token struct {
@@ -90,3 +137,23 @@ func SessionUserKey(userID string) string {
 func SessionAllKey() string {
 	return SessionAllNamespace
 }
+
+// SessionDeviceKey builds the Redis key for a device the user has logged in from.
+func SessionDeviceKey(userID, deviceID string) string {
+	return sessionRedisKey(SessionDeviceNamespace, userID+":"+deviceID)
+}
+
+// SessionLimitLockKey builds the Redis key for the session limit lock of a user.
+func SessionLimitLockKey(userID string) string {
+	return sessionRedisKey(SessionLimitLockNamespace, userID)
+}
+
+// SessionTrustedDevicePrefix builds the Redis key prefix for all trusted devices of a user.
+func SessionTrustedDevicePrefix(userID string) string {
+	return sessionRedisKey(SessionTrustedDeviceNamespace, userID) + ":"
+}
+
+// SessionTrustedDeviceKey builds the Redis key for a trusted device identified by its token.
+func SessionTrustedDeviceKey(userID, token string) string {
+	return SessionTrustedDevicePrefix(userID) + token
+}
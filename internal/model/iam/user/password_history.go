@@ -0,0 +1,20 @@
+package modeliamuser
+
+import (
+	. "github.com/forbearing/gst/dsl"
+	"github.com/forbearing/gst/model"
+)
+
+// PasswordHistory keeps previous password hashes of a user to prevent password reuse.
+type PasswordHistory struct {
+	UserID       string `json:"user_id" gorm:"type:varchar(100);index;not null"`
+	PasswordHash string `json:"-" gorm:"type:varchar(255);not null"`
+
+	model.Base
+}
+
+func (PasswordHistory) Design() {
+	Migrate(true)
+}
+
+func (PasswordHistory) Purge() bool { return true }
@@ -2,14 +2,15 @@ package modeliamuser
 
 import (
 	"encoding/json"
+	"sync/atomic"
 	"time"
 
 	. "github.com/forbearing/gst/dsl"
 	modeliamgroup "github.com/forbearing/gst/internal/model/iam/group"
 	modeliamtenant "github.com/forbearing/gst/internal/model/iam/tenant"
 	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/password"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserStatus is the account lifecycle state for IAM users.
@@ -79,12 +80,17 @@ type User struct {
 	Birthday    *time.Time `json:"birthday"`
 	Gender      *string    `json:"gender" gorm:"type:varchar(10)"`
 
+	// ExternalID is the id of the user in the identity provider that provisions it, eg: by SCIM.
+	ExternalID *string `json:"external_id" gorm:"type:varchar(255);index"`
+
 	Password           string `json:"password,omitempty" gorm:"-"`
 	PasswordHash       string `json:"-" gorm:"type:varchar(255)"`
 	Salt               string `json:"-" gorm:"type:varchar(50)"`
 	TwoFactorEnabled   *bool  `json:"two_factor_enabled" gorm:"default:false"`
 	MustChangePassword bool   `json:"must_change_password" gorm:"default:false;not null"`
 
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+
 	EmailVerified      *bool      `json:"email_verified" gorm:"default:false"`
 	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
 	PhoneVerified      *bool      `json:"phone_verified" gorm:"default:false"`
@@ -124,9 +130,13 @@ func (u User) MarshalJSON() ([]byte, error) {
 		Birthday    *time.Time `json:"birthday"`
 		Gender      *string    `json:"gender"`
 
+		ExternalID *string `json:"external_id"`
+
 		TwoFactorEnabled   *bool `json:"two_factor_enabled"`
 		MustChangePassword bool  `json:"must_change_password"`
 
+		PasswordChangedAt *time.Time `json:"password_changed_at"`
+
 		EmailVerified      *bool      `json:"email_verified"`
 		EmailVerifiedAt    *time.Time `json:"email_verified_at"`
 		PhoneVerified      *bool      `json:"phone_verified"`
@@ -162,8 +172,10 @@ func (u User) MarshalJSON() ([]byte, error) {
 		Bio:                u.Bio,
 		Birthday:           u.Birthday,
 		Gender:             u.Gender,
+		ExternalID:         u.ExternalID,
 		TwoFactorEnabled:   u.TwoFactorEnabled,
 		MustChangePassword: u.MustChangePassword,
+		PasswordChangedAt:  u.PasswordChangedAt,
 		EmailVerified:      u.EmailVerified,
 		EmailVerifiedAt:    u.EmailVerifiedAt,
 		PhoneVerified:      u.PhoneVerified,
@@ -212,13 +224,31 @@ func (User) Purge() bool { return true }
 func (u *User) CreateBefore(ctx *types.ModelContext) error { return GenerateHashedPassword(u) }
 func (u *User) UpdateBefore(ctx *types.ModelContext) error { return GenerateHashedPassword(u) }
 
+// passwordPolicy is the policy of the plain passwords hashed by GenerateHashedPassword.
+var passwordPolicy atomic.Pointer[password.Policy]
+
+// SetPasswordPolicy sets the password policy that GenerateHashedPassword validates against,
+// the zero Policy is used if it's never called.
+func SetPasswordPolicy(p password.Policy) { passwordPolicy.Store(&p) }
+
+// GenerateHashedPassword validates the plain password against the password policy and hashes it,
+// so the users created by administrators and the default users satisfy the policy too.
 func GenerateHashedPassword(u *User) error {
 	if len(u.Password) > 0 && len(u.PasswordHash) == 0 {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		var policy password.Policy
+		if p := passwordPolicy.Load(); p != nil {
+			policy = *p
+		}
+		if err := policy.Validate(u.Password, u.Username); err != nil {
+			return err
+		}
+		hashedPassword, err := password.Hash(u.Password)
 		if err != nil {
 			return err
 		}
-		u.PasswordHash = string(hashedPassword)
+		now := time.Now()
+		u.PasswordHash = hashedPassword
+		u.PasswordChangedAt = &now
 		return nil
 	}
 	return nil
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/forbearing/gst/pkg/password"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,3 +25,21 @@ func TestUserMarshalJSON(t *testing.T) {
 	require.NotContains(t, payload, "password_hash")
 	require.NotContains(t, payload, "salt")
 }
+
+func TestGenerateHashedPasswordPolicy(t *testing.T) {
+	defer passwordPolicy.Store(passwordPolicy.Load())
+
+	// The zero policy requires at least 6 characters.
+	require.Error(t, GenerateHashedPassword(&User{Username: "alice", Password: "abc"}))
+
+	SetPasswordPolicy(password.Policy{MinLength: 8, DisallowUsername: true})
+	require.Error(t, GenerateHashedPassword(&User{Username: "alice", Password: "alice-2024"}))
+
+	u := &User{Username: "alice", Password: "correct-horse"}
+	require.NoError(t, GenerateHashedPassword(u))
+	require.NotEmpty(t, u.PasswordHash)
+	require.NotNil(t, u.PasswordChangedAt)
+
+	// The hashed users are not validated again.
+	require.NoError(t, GenerateHashedPassword(&User{Username: "alice", Password: "abc", PasswordHash: u.PasswordHash}))
+}
@@ -0,0 +1,73 @@
+package modeljobs
+
+import (
+	"time"
+
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	"github.com/forbearing/gst/model"
+)
+
+// Job lists the registered cronjobs and their runtime state.
+type Job struct {
+	model.Empty
+}
+
+type JobListRsp struct {
+	Items []*JobInfo `json:"items"`
+	Total int64      `json:"total"`
+}
+
+// JobInfo is a registered cronjob.
+// Next, Prev and Running are the state of the instance serving the request,
+// the last run is the latest run of all instances if the run history is enabled.
+type JobInfo struct {
+	Name      string `json:"name"`
+	Spec      string `json:"spec"`
+	Singleton bool   `json:"singleton"`
+	Overlap   string `json:"overlap,omitempty"`
+	Timeout   string `json:"timeout,omitempty"`
+	Paused    bool   `json:"paused"`
+	Running   int    `json:"running"`
+
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	AlertAfter  int `json:"alert_after,omitempty"`
+	Failures    int `json:"failures"` // Failures is the number of consecutive failed runs
+
+	NextRun *time.Time `json:"next_run,omitempty"` // NextRun is empty if the cronjob is paused
+	PrevRun *time.Time `json:"prev_run,omitempty"` // PrevRun is empty if the scheduler has not started the cronjob
+
+	LastStatus   modelcronjob.RunStatus  `json:"last_status,omitempty"`
+	LastTrigger  modelcronjob.RunTrigger `json:"last_trigger,omitempty"`
+	LastError    string                  `json:"last_error,omitempty"`
+	LastNode     string                  `json:"last_node,omitempty"`
+	LastRunAt    *time.Time              `json:"last_run_at,omitempty"`
+	LastDuration int64                   `json:"last_duration,omitempty"` // milliseconds
+}
+
+// JobTrigger, JobPause and JobResume act on the cronjob named by the route parameter ":name".
+// The services are registered per model, so every action has its own model.
+type (
+	JobTrigger struct{ model.Empty }
+	JobPause   struct{ model.Empty }
+	JobResume  struct{ model.Empty }
+)
+
+type JobActionRsp struct {
+	Name   string `json:"name"`
+	Paused bool   `json:"paused"`
+}
+
+// JobEvent streams the run events of the cronjobs on the instance serving the request.
+type JobEvent struct {
+	model.Empty
+}
+
+// DeadLetterReplay replays the dead letter named by the route parameter ":id".
+type DeadLetterReplay struct {
+	model.Empty
+}
+
+type DeadLetterReplayRsp struct {
+	ID  string `json:"id"`
+	Job string `json:"job"`
+}
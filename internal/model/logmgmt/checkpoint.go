@@ -0,0 +1,41 @@
+package modellogmgmt
+
+import (
+	"fmt"
+
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
+)
+
+// OperationLogCheckpoint is a signed snapshot of the head of an operation log hash chain.
+// A checkpoint pins the chain: records up to Seq can not be rewritten or truncated
+// without the signing key.
+type OperationLogCheckpoint struct {
+	ChainKey     string `json:"chain_key" schema:"chain_key" gorm:"index:idx_operationlog_checkpoint,priority:1"`
+	Seq          uint64 `json:"seq" schema:"seq" gorm:"index:idx_operationlog_checkpoint,priority:2"`
+	Hash         string `json:"hash"`
+	CheckpointAt int64  `json:"checkpoint_at"` // unix nanoseconds
+	KeyID        string `json:"key_id"`        // identifies the ed25519 signing key
+	Signature    string `json:"signature"`     // base64 ed25519 signature of Payload
+
+	model.Base
+}
+
+// Payload returns the signed content of the checkpoint.
+func (cp *OperationLogCheckpoint) Payload() []byte {
+	return fmt.Appendf(nil, "%s\n%d\n%s\n%d", cp.ChainKey, cp.Seq, cp.Hash, cp.CheckpointAt)
+}
+
+func (cp *OperationLogCheckpoint) UpdateBefore(*types.ModelContext) error {
+	if appendOnly.Load() {
+		return ErrAppendOnly
+	}
+	return nil
+}
+
+func (cp *OperationLogCheckpoint) DeleteBefore(*types.ModelContext) error {
+	if appendOnly.Load() {
+		return ErrAppendOnly
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package modellogmgmt
 import (
 	. "github.com/forbearing/gst/dsl"
 	"github.com/forbearing/gst/model"
+	"gorm.io/datatypes"
 )
 
 type LoginStatus string
@@ -11,6 +12,26 @@ const (
 	LoginStatusSuccess = "success"
 	LoginStatusFailure = "failure"
 	LoginStatusLogout  = "logout"
+	LoginStatusLocked  = "locked"
+
+	// LoginStatusImpersonate and LoginStatusImpersonateEnd record an administrator starting
+	// and ending an impersonation session, Username is the impersonated user.
+	LoginStatusImpersonate    = "impersonate"
+	LoginStatusImpersonateEnd = "impersonate_end"
+)
+
+// RiskFactor is a reason the login is considered risky by login anomaly detection.
+type RiskFactor = string
+
+const (
+	// RiskFactorNewCountry is a login from a country the user has not logged in from.
+	RiskFactorNewCountry RiskFactor = "new_country"
+	// RiskFactorImpossibleTravel is a login too far away from the previous login to travel in between.
+	RiskFactorImpossibleTravel RiskFactor = "impossible_travel"
+	// RiskFactorNewASN is a login from a network(autonomous system) the user has not logged in from.
+	RiskFactorNewASN RiskFactor = "new_asn"
+	// RiskFactorFailedAttempts is a login after recent failed attempts of the account.
+	RiskFactorFailedAttempts RiskFactor = "failed_attempts"
 )
 
 type LoginLog struct {
@@ -19,6 +40,7 @@ type LoginLog struct {
 	Username string      `json:"username,omitempty" schema:"username"`
 	ClientIP string      `json:"client_ip,omitempty" schema:"client_ip"`
 	Status   LoginStatus `json:"status,omitempty" schema:"status"`
+	Reason   string      `json:"reason,omitempty" schema:"reason"` // Reason is why the login failed or was rejected
 
 	// User Agent info
 	Source   string `json:"source" schema:"source"`
@@ -26,6 +48,18 @@ type LoginLog struct {
 	Engine   string `json:"engine" schema:"engine"`
 	Browser  string `json:"browser" schema:"browser"`
 
+	// GeoIP info, resolved from ClientIP when login anomaly detection is enabled.
+	Country   string  `json:"country,omitempty" schema:"country"` // Country is the ISO 3166-1 alpha-2 country code
+	City      string  `json:"city,omitempty" schema:"city"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	ASN       uint    `json:"asn,omitempty" schema:"asn"`
+	ASOrg     string  `json:"as_org,omitempty" schema:"as_org"`
+
+	// RiskScore is the risk of the login from 0 to 100, RiskFactors are its reasons.
+	RiskScore   int                         `json:"risk_score,omitempty" schema:"risk_score"`
+	RiskFactors datatypes.JSONSlice[string] `json:"risk_factors,omitempty"`
+
 	model.Base
 }
 
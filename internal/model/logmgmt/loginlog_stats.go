@@ -0,0 +1,84 @@
+package modellogmgmt
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/model"
+)
+
+// Risk levels of the login logs, see RiskLevelOf.
+const (
+	RiskLevelNone   = "none"
+	RiskLevelLow    = "low"
+	RiskLevelMedium = "medium"
+	RiskLevelHigh   = "high"
+)
+
+// Intervals of the login stats timeline.
+const (
+	StatsIntervalHour = "hour"
+	StatsIntervalDay  = "day"
+)
+
+// RiskLevelOf returns the risk level of the risk score:
+// none is 0, low is below 40, medium is below 70 and high is 70 or above.
+func RiskLevelOf(score int) string {
+	switch {
+	case score <= 0:
+		return RiskLevelNone
+	case score < 40:
+		return RiskLevelLow
+	case score < 70:
+		return RiskLevelMedium
+	default:
+		return RiskLevelHigh
+	}
+}
+
+// LoginLogStats aggregates the login logs for dashboards.
+type LoginLogStats struct {
+	model.Empty
+}
+
+// LoginLogStatsReq is the time range of the aggregation,
+// the fields can also be passed as query parameters, eg: ?since=2026-01-02T15:04:05Z&interval=day&top=5.
+type LoginLogStatsReq struct {
+	Since    time.Time `json:"since,omitempty"`    // Since defaults to 24 hours before Until
+	Until    time.Time `json:"until,omitempty"`    // Until defaults to now
+	Interval string    `json:"interval,omitempty"` // Interval is the timeline interval "hour" or "day", default is hour for ranges up to 2 days
+	Top      int       `json:"top,omitempty"`      // Top is the size of the top lists, default is 10
+}
+
+type LoginLogStatsRsp struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Interval string    `json:"interval"`
+	Total    int64     `json:"total"`
+
+	ByStatus    []*StatsBucket `json:"by_status"`
+	ByRiskLevel []*StatsBucket `json:"by_risk_level"`
+	ByFactor    []*StatsBucket `json:"by_factor"`
+	ByCountry   []*StatsBucket `json:"by_country"` // top countries
+	ByASN       []*StatsBucket `json:"by_asn"`     // top networks, the key is "AS<number> <organization>"
+
+	TopFailedIPs   []*StatsBucket `json:"top_failed_ips"`   // client ips with the most failed and locked logins
+	TopRiskyUsers  []*StatsBucket `json:"top_risky_users"`  // usernames with the most risky logins(medium and high)
+	TopFailedUsers []*StatsBucket `json:"top_failed_users"` // usernames with the most failed and locked logins
+
+	Timeline []*StatsPoint `json:"timeline"`
+}
+
+// StatsBucket is the number of login logs having the key.
+type StatsBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// StatsPoint is the number of login logs in the interval starting at Time.
+type StatsPoint struct {
+	Time    time.Time `json:"time"`
+	Total   int64     `json:"total"`
+	Success int64     `json:"success"`
+	Failure int64     `json:"failure"` // Failure includes the failed and locked logins
+	Risky   int64     `json:"risky"`   // Risky is the logins with medium or high risk level
+}
@@ -1,16 +1,33 @@
 package modellogmgmt
 
 import (
+	"errors"
+	"sync/atomic"
+
 	. "github.com/forbearing/gst/dsl"
 	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
+	"gorm.io/datatypes"
 )
 
+// ErrAppendOnly is returned when updating or deleting an operation log in append-only mode.
+var ErrAppendOnly = errors.New("operation log is append-only")
+
+// FieldChange is the change of one field of an operation log record.
+// The values of the audit excluded fields are masked.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
 type OperationLog struct {
-	User       string    `json:"user,omitempty" schema:"user"`   // 操作者, 本地账号该字段为空,例如 root
-	IP         string    `json:"ip,omitempty" schema:"ip"`       // 操作者的 ip
-	OP         consts.OP `json:"op,omitempty" schema:"op"`       // 动作: 增删改查
-	Table      string    `json:"table,omitempty" schema:"table"` // 操作了哪张表
+	User       string    `json:"user,omitempty" schema:"user"`           // 操作者, 本地账号该字段为空,例如 root
+	RealUser   string    `json:"real_user,omitempty" schema:"real_user"` // RealUser is the administrator impersonating User, empty otherwise
+	IP         string    `json:"ip,omitempty" schema:"ip"`               // 操作者的 ip
+	OP         consts.OP `json:"op,omitempty" schema:"op"`               // 动作: 增删改查
+	Table      string    `json:"table,omitempty" schema:"table"`         // 操作了哪张表
 	Model      string    `json:"model,omitempty" schema:"model"`
 	RecordID   string    `json:"record_id,omitempty" schema:"record_id"`     // 表记录的 id
 	RecordName string    `json:"record_name,omitempty" schema:"record_name"` // 表记录的 name
@@ -19,14 +36,51 @@ type OperationLog struct {
 	Response   string    `json:"response,omitempty" schema:"response"`
 	OldRecord  string    `json:"old_record,omitempty"` // 更新前的内容
 	NewRecord  string    `json:"new_record,omitempty"` // 更新后的内容
-	Method     string    `json:"method,omitempty" schema:"method"`
-	URI        string    `json:"uri,omitempty" schema:"uri"` // request uri
-	UserAgent  string    `json:"user_agent,omitempty" schema:"user_agent"`
-	RequestID  string    `json:"request_id,omitempty" schema:"request_id"`
+	// Changes is the per-field diff between OldRecord and NewRecord.
+	Changes   datatypes.JSONSlice[FieldChange] `json:"changes,omitempty"`
+	Method    string                           `json:"method,omitempty" schema:"method"`
+	URI       string                           `json:"uri,omitempty" schema:"uri"` // request uri
+	UserAgent string                           `json:"user_agent,omitempty" schema:"user_agent"`
+	RequestID string                           `json:"request_id,omitempty" schema:"request_id"`
+
+	// The hash chain fields are only set in tamper-evident mode, see module/logmgmt.Config.
+	// Hash is the sha256 of PrevHash and the record content, so altering or deleting
+	// a record breaks the link of every following record.
+	// (ChainKey, Seq) is unique so that two writers can't fork the chain, both are NULL
+	// in the database when the log is not chained.
+	ChainKey  string `json:"chain_key,omitempty" schema:"chain_key" gorm:"default:null;uniqueIndex:idx_operationlog_chain,priority:1"`
+	Seq       uint64 `json:"seq,omitempty" schema:"seq" gorm:"default:null;uniqueIndex:idx_operationlog_chain,priority:2"`
+	PrevHash  string `json:"prev_hash,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	ChainedAt int64  `json:"chained_at,omitempty"` // unix nanoseconds, hashed instead of created_at to survive the database round trip
 
 	model.Base
 }
 
+// appendOnly rejects updating and deleting operation logs.
+var appendOnly atomic.Bool
+
+// SetAppendOnly makes OperationLog append-only, updating or deleting it returns an error.
+// It's enforced by the model hooks only, the writes without hooks(WithoutHook, raw SQL) bypass it.
+func SetAppendOnly(enable bool) { appendOnly.Store(enable) }
+
+// AppendOnly reports whether OperationLog is append-only.
+func AppendOnly() bool { return appendOnly.Load() }
+
+func (ol *OperationLog) UpdateBefore(*types.ModelContext) error {
+	if appendOnly.Load() {
+		return ErrAppendOnly
+	}
+	return nil
+}
+
+func (ol *OperationLog) DeleteBefore(*types.ModelContext) error {
+	if appendOnly.Load() {
+		return ErrAppendOnly
+	}
+	return nil
+}
+
 func (OperationLog) Design() {
 	Migrate(true)
 	List(func() {
@@ -0,0 +1,36 @@
+package modellogmgmt
+
+import "github.com/forbearing/gst/model"
+
+// OperationLogVerify verifies the operation log hash chains.
+type OperationLogVerify struct {
+	model.Empty
+}
+
+type OperationLogVerifyReq struct {
+	// ChainKey is the chain to verify, empty verifies all chains.
+	ChainKey string `json:"chain_key,omitempty"`
+}
+
+type OperationLogVerifyRsp struct {
+	Valid   bool           `json:"valid"`
+	Results []*ChainResult `json:"results"`
+}
+
+// BrokenLink is the first record of a chain that fails verification.
+type BrokenLink struct {
+	Seq      uint64 `json:"seq"`
+	RecordID string `json:"record_id,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// ChainResult is the verification result of one chain.
+type ChainResult struct {
+	ChainKey    string      `json:"chain_key"`
+	Records     uint64      `json:"records"`
+	HeadSeq     uint64      `json:"head_seq"`
+	HeadHash    string      `json:"head_hash,omitempty"`
+	Checkpoints int         `json:"checkpoints"`
+	Valid       bool        `json:"valid"`
+	Broken      *BrokenLink `json:"broken,omitempty"`
+}
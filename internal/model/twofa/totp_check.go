@@ -13,6 +13,9 @@ type TOTPCheck struct {
 type TOTPCheckReq struct {
 	Username string `json:"username" validate:"required"` // 用户名
 	Password string `json:"password" validate:"required"`
+
+	// Optional captcha token, required once the response carries the X-Captcha-Required header.
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type TOTPCheckRsp struct {
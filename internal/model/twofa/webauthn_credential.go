@@ -0,0 +1,45 @@
+package modeltwofa
+
+import (
+	"time"
+
+	. "github.com/forbearing/gst/dsl"
+	"github.com/forbearing/gst/model"
+	"gorm.io/datatypes"
+)
+
+// WebAuthnCredential represents a WebAuthn public key credential (security key or passkey) for 2FA
+type WebAuthnCredential struct {
+	UserID          string                      `json:"user_id" gorm:"type:varchar(191);not null;index" schema:"user_id"`
+	DeviceName      string                      `json:"device_name" gorm:"type:varchar(100);not null" schema:"device_name"`
+	CredentialID    string                      `json:"credential_id" gorm:"type:varchar(191);not null;uniqueIndex" schema:"credential_id"` // Base64url encoded credential id
+	PublicKey       []byte                      `json:"-" schema:"public_key"`                                                              // CBOR encoded COSE public key, not exposed in JSON
+	AttestationType string                      `json:"attestation_type" gorm:"type:varchar(32)" schema:"attestation_type"`
+	AAGUID          string                      `json:"aaguid" gorm:"type:varchar(64)" schema:"aaguid"`
+	SignCount       uint32                      `json:"sign_count" schema:"sign_count"`
+	Transports      datatypes.JSONSlice[string] `json:"transports" schema:"transports"`
+	Passwordless    bool                        `json:"passwordless" schema:"passwordless"` // Whether the credential is a discoverable passkey usable without password
+	IsActive        bool                        `json:"is_active" gorm:"default:true" schema:"is_active"`
+	LastUsedAt      *time.Time                  `json:"last_used_at" schema:"last_used_at"`
+
+	model.Base
+}
+
+func (WebAuthnCredential) Design() {
+	Migrate(true)
+
+	Route("2fa/webauthn/credentials", func() {
+		Delete(func() {
+			Enabled(true)
+		})
+		Patch(func() {
+			Enabled(true)
+		})
+		List(func() {
+			Enabled(true)
+		})
+		Get(func() {
+			Enabled(true)
+		})
+	})
+}
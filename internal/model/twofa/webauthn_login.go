@@ -0,0 +1,71 @@
+package modeltwofa
+
+import (
+	. "github.com/forbearing/gst/dsl"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/webauthn"
+)
+
+// WebAuthnLoginBegin 开始 WebAuthn 断言
+//
+// With username and password the options list the user's credentials and the
+// assertion is submitted as second factor to POST /api/login.
+// Without username a discoverable (passkey) assertion is requested and
+// submitted to POST /api/2fa/webauthn/login/finish.
+type WebAuthnLoginBegin struct {
+	model.Empty
+}
+
+type WebAuthnLoginBeginReq struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Optional captcha token, required once the response carries the X-Captcha-Required header.
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+type WebAuthnLoginBeginRsp struct {
+	CeremonyID string                             `json:"ceremony_id,omitempty"`
+	PublicKey  *webauthn.CredentialRequestOptions `json:"publicKey,omitempty"` // Pass to navigator.credentials.get()
+}
+
+func (WebAuthnLoginBegin) Design() {
+	Route("2fa/webauthn/login/begin", func() {
+		Create(func() {
+			Enabled(true)
+			Service(true)
+			Public(true) // 公开接口，用于登录前获取断言参数
+			Payload[*WebAuthnLoginBeginReq]()
+			Result[*WebAuthnLoginBeginRsp]()
+		})
+	})
+}
+
+// WebAuthnLoginFinish 使用通行密钥（passkey）免密登录
+type WebAuthnLoginFinish struct {
+	model.Empty
+}
+
+type WebAuthnLoginFinishReq struct {
+	CeremonyID string                               `json:"ceremony_id" validate:"required"`
+	Credential webauthn.CredentialAssertionResponse `json:"credential"`
+
+	// Optional captcha token, required once the response carries the X-Captcha-Required header.
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+type WebAuthnLoginFinishRsp struct {
+	SessionID string `json:"session_id,omitempty"`
+}
+
+func (WebAuthnLoginFinish) Design() {
+	Route("2fa/webauthn/login/finish", func() {
+		Create(func() {
+			Enabled(true)
+			Service(true)
+			Public(true)
+			Payload[*WebAuthnLoginFinishReq]()
+			Result[*WebAuthnLoginFinishRsp]()
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package modeltwofa
+
+import (
+	. "github.com/forbearing/gst/dsl"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/webauthn"
+)
+
+// WebAuthnRegisterBegin 开始注册 WebAuthn 凭证
+type WebAuthnRegisterBegin struct {
+	model.Empty
+}
+
+type WebAuthnRegisterBeginReq struct {
+	Passwordless bool `json:"passwordless,omitempty"` // Request a discoverable passkey usable for passwordless login
+}
+
+type WebAuthnRegisterBeginRsp struct {
+	CeremonyID string                              `json:"ceremony_id,omitempty"`
+	PublicKey  *webauthn.CredentialCreationOptions `json:"publicKey,omitempty"` // Pass to navigator.credentials.create()
+}
+
+func (WebAuthnRegisterBegin) Design() {
+	Route("2fa/webauthn/register/begin", func() {
+		Create(func() {
+			Enabled(true)
+			Service(true)
+			Payload[*WebAuthnRegisterBeginReq]()
+			Result[*WebAuthnRegisterBeginRsp]()
+		})
+	})
+}
+
+// WebAuthnRegisterFinish 完成注册 WebAuthn 凭证
+type WebAuthnRegisterFinish struct {
+	model.Empty
+}
+
+type WebAuthnRegisterFinishReq struct {
+	CeremonyID string                              `json:"ceremony_id" validate:"required"`
+	DeviceName string                              `json:"device_name" validate:"required,max=100"`
+	Credential webauthn.CredentialCreationResponse `json:"credential"`
+}
+
+type WebAuthnRegisterFinishRsp struct {
+	ID           string `json:"id,omitempty"`
+	CredentialID string `json:"credential_id,omitempty"`
+	Passwordless bool   `json:"passwordless,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+func (WebAuthnRegisterFinish) Design() {
+	Route("2fa/webauthn/register/finish", func() {
+		Create(func() {
+			Enabled(true)
+			Service(true)
+			Payload[*WebAuthnRegisterFinishReq]()
+			Result[*WebAuthnRegisterFinishRsp]()
+		})
+	})
+}
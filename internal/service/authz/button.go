@@ -1,10 +1,43 @@
 package serviceauthz
 
 import (
+	"slices"
+
 	modelauthz "github.com/forbearing/gst/internal/model/authz"
 	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
 )
 
 type ButtonService struct {
 	service.Base[*modelauthz.Button, *modelauthz.Button, *modelauthz.Button]
 }
+
+// ListAfter hides the buttons granted to the current user's roles only with conditions that are not met,
+// buttons not granted to any of the roles are kept.
+func (b *ButtonService) ListAfter(ctx *types.ServiceContext, data *[]*modelauthz.Button) error {
+	if ctx.Username == consts.AUTHZ_USER_ROOT || ctx.Username == consts.AUTHZ_USER_ADMIN {
+		return nil
+	}
+	log := b.WithServiceContext(ctx, ctx.GetPhase())
+
+	roles, err := currentRoles(ctx, log)
+	if err != nil {
+		return err
+	}
+	rctx := conditionContext(ctx)
+	*data = slices.DeleteFunc(*data, func(item *modelauthz.Button) bool {
+		granted := false
+		for _, role := range roles {
+			if !slices.Contains(role.ButtonIDs, item.ID) {
+				continue
+			}
+			granted = true
+			if conditionMet(ctx, rctx, role, item.ID, log) {
+				return false
+			}
+		}
+		return granted
+	})
+	return nil
+}
@@ -0,0 +1,436 @@
+package serviceauthz
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	casbinutil "github.com/casbin/casbin/v3/util"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	modelauthz "github.com/forbearing/gst/internal/model/authz"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// ExplainService explains why middleware.Authz allows or denies a request.
+type ExplainService struct {
+	service.Base[*modelauthz.Explain, *modelauthz.ExplainReq, *modelauthz.ExplainRsp]
+}
+
+// SimulateService evaluates proposed role and permission changes against a copy
+// of the enforcer, nothing is saved.
+type SimulateService struct {
+	service.Base[*modelauthz.Simulate, *modelauthz.SimulateReq, *modelauthz.SimulateRsp]
+}
+
+func (s *ExplainService) Create(ctx *types.ServiceContext, req *modelauthz.ExplainReq) (rsp *modelauthz.ExplainRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	e, tenantMode := currentEnforcer()
+	if e == nil {
+		return nil, types.NewServiceError(http.StatusBadRequest, "rbac is not enabled")
+	}
+	if err = normalizeExplainReq(ctx, req, tenantMode); err != nil {
+		return nil, err
+	}
+	if rsp, err = explain(ctx, e, tenantMode, req); err != nil {
+		log.Error("failed to explain authorization decision", err)
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (s *SimulateService) Create(ctx *types.ServiceContext, req *modelauthz.SimulateReq) (rsp *modelauthz.SimulateRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	e, tenantMode := currentEnforcer()
+	if e == nil {
+		return nil, types.NewServiceError(http.StatusBadRequest, "rbac is not enabled")
+	}
+	if len(req.Changes) == 0 {
+		return nil, types.NewServiceError(http.StatusBadRequest, "changes is required")
+	}
+	if err = normalizeExplainReq(ctx, &req.ExplainReq, tenantMode); err != nil {
+		return nil, err
+	}
+
+	current, err := explain(ctx, e, tenantMode, &req.ExplainReq)
+	if err != nil {
+		log.Error("failed to explain authorization decision", err)
+		return nil, err
+	}
+	c, err := rbac.Clone(e)
+	if err != nil {
+		log.Error("failed to clone enforcer", err)
+		return nil, errors.Wrap(err, "failed to clone enforcer")
+	}
+	for i := range req.Changes {
+		if err = applyPolicyChange(ctx, c, tenantMode, req.Tenant, &req.Changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	simulated, err := explain(ctx, c, tenantMode, &req.ExplainReq)
+	if err != nil {
+		log.Error("failed to explain simulated authorization decision", err)
+		return nil, err
+	}
+
+	return &modelauthz.SimulateRsp{
+		Current:   current,
+		Simulated: simulated,
+		Changed:   current.Allowed != simulated.Allowed,
+	}, nil
+}
+
+// currentEnforcer returns the enforcer used by middleware.Authz.
+func currentEnforcer() (*casbin.Enforcer, bool) {
+	if rbac.TenantEnforcer != nil {
+		return rbac.TenantEnforcer, true
+	}
+	return rbac.Enforcer, false
+}
+
+// normalizeExplainReq validates the request and resolves its subject and tenant like middleware.Authz does.
+func normalizeExplainReq(ctx *types.ServiceContext, req *modelauthz.ExplainReq, tenantMode bool) error {
+	req.Path = strings.TrimSpace(req.Path)
+	req.Method = strings.ToUpper(strings.TrimSpace(req.Method))
+	if len(req.Path) == 0 {
+		return types.NewServiceError(http.StatusBadRequest, "path is required")
+	}
+	if len(req.Method) == 0 {
+		return types.NewServiceError(http.StatusBadRequest, "method is required")
+	}
+
+	if tenantMode {
+		if len(req.Tenant) == 0 {
+			req.Tenant = consts.AUTHZ_TENANT_DEFAULT
+		}
+	} else {
+		req.Tenant = ""
+	}
+
+	if len(req.Subject) > 0 {
+		return nil
+	}
+	if len(req.Username) == 0 {
+		return types.NewServiceError(http.StatusBadRequest, "subject or username is required")
+	}
+	if req.Username == consts.AUTHZ_USER_ROOT || req.Username == consts.AUTHZ_USER_ADMIN {
+		req.Subject = req.Username
+		return nil
+	}
+	users := make([]*modeliamuser.User, 0)
+	if err := database.Database[*modeliamuser.User](ctx.DatabaseContext()).
+		WithLimit(1).
+		WithQuery(&modeliamuser.User{Username: req.Username}).
+		List(&users); err != nil {
+		return errors.Wrap(err, "failed to query user")
+	}
+	if len(users) == 0 {
+		return types.NewServiceError(http.StatusNotFound, "user not found")
+	}
+	req.Subject = users[0].ID
+	return nil
+}
+
+// explain evaluates the request against the enforcer and collects the policies,
+// roles, menus and buttons involved in the decision.
+func explain(ctx *types.ServiceContext, e *casbin.Enforcer, tenantMode bool, req *modelauthz.ExplainReq) (*modelauthz.ExplainRsp, error) {
+	rsp := &modelauthz.ExplainRsp{
+		Subject:         req.Subject,
+		Tenant:          req.Tenant,
+		Path:            req.Path,
+		Method:          req.Method,
+		Effect:          consts.EffectDeny,
+		MatchedPolicies: make([][]string, 0),
+		UnmetPolicies:   make([][]string, 0),
+		RoleChains:      make([][]string, 0),
+	}
+
+	var (
+		allowed bool
+		matched []string
+		err     error
+	)
+	rctx := requestContext(ctx, req)
+	if tenantMode {
+		allowed, matched, err = e.EnforceEx(req.Tenant, req.Subject, req.Path, req.Method, rctx)
+	} else {
+		allowed, matched, err = e.EnforceEx(req.Subject, req.Path, req.Method, rctx)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to enforce")
+	}
+	rsp.Allowed = allowed
+	if allowed {
+		rsp.Effect = consts.EffectAllow
+	}
+
+	if tenantMode {
+		for _, domain := range []string{consts.AUTHZ_TENANT_ALL, req.Tenant} {
+			chains, err := rbac.RoleChains(e, req.Subject, domain)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get role chains")
+			}
+			rsp.RoleChains = append(rsp.RoleChains, chains...)
+		}
+		if hasRole(e, req.Subject, consts.AUTHZ_ROLE_SUPER_ADMIN, consts.AUTHZ_TENANT_ALL) {
+			rsp.Bypass = consts.AUTHZ_ROLE_SUPER_ADMIN
+		} else if hasRole(e, req.Subject, consts.AUTHZ_ROLE_ADMIN, req.Tenant) {
+			rsp.Bypass = consts.AUTHZ_ROLE_ADMIN
+		}
+	} else {
+		if rsp.RoleChains, err = rbac.RoleChains(e, req.Subject); err != nil {
+			return nil, errors.Wrap(err, "failed to get role chains")
+		}
+		if hasRole(e, req.Subject, consts.AUTHZ_ROLE_ADMIN) {
+			rsp.Bypass = consts.AUTHZ_ROLE_ADMIN
+		}
+	}
+	// The bypass matches every policy line, so the matched line casbin reports is meaningless.
+	if len(rsp.Bypass) == 0 && len(matched) > 0 {
+		rsp.MatchedPolicies = append(rsp.MatchedPolicies, matched)
+	}
+	if len(rsp.Bypass) == 0 {
+		if rsp.UnmetPolicies, err = unmetPolicies(e, tenantMode, req, rctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = collectPermissions(ctx, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// requestContext returns the context conditional policies are evaluated against, see middleware.Authz.
+func requestContext(ctx *types.ServiceContext, req *modelauthz.ExplainReq) *rbac.Context {
+	now := time.Now()
+	if req.Time != nil {
+		now = *req.Time
+	}
+	header := make(http.Header)
+	for k, v := range req.Headers {
+		header.Set(k, v)
+	}
+	rctx := rbac.NewContext(ctx.Context(), now, req.ClientIP, header)
+	if len(req.Owner) > 0 {
+		rctx.SetOwner(req.Owner)
+	} else {
+		rctx.Route, rctx.ResourceID = resolveRoute(req.Path)
+	}
+	return rctx
+}
+
+// resolveRoute returns the registered route template matching the path and the value of its last parameter,
+// eg: "/api/users/:id" and "1" for "/api/users/1".
+func resolveRoute(path string) (string, string) {
+	segments := strings.Split(path, "/")
+	for route := range model.Routes {
+		if !strings.Contains(route, "/:") || !casbinutil.KeyMatch2(path, route) {
+			continue
+		}
+		parts := strings.Split(route, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		for i := len(parts) - 1; i >= 0; i-- {
+			if strings.HasPrefix(parts[i], ":") {
+				return route, segments[i]
+			}
+		}
+	}
+	return "", ""
+}
+
+// unmetPolicies returns the policies of the subject's roles matching the path and method whose condition is not met.
+func unmetPolicies(e *casbin.Enforcer, tenantMode bool, req *modelauthz.ExplainReq, rctx *rbac.Context) ([][]string, error) {
+	var domain []string
+	if tenantMode {
+		domain = []string{req.Tenant}
+	}
+	roles, err := e.GetImplicitRolesForUser(req.Subject, domain...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get roles")
+	}
+	roles = append(roles, req.Subject)
+	policies, err := e.GetPolicy()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get policies")
+	}
+
+	unmet := make([][]string, 0)
+	for _, p := range policies {
+		fields := p
+		if tenantMode {
+			if p[0] != req.Tenant && p[0] != consts.AUTHZ_TENANT_ALL {
+				continue
+			}
+			fields = p[1:]
+		}
+		// fields: sub, obj, act, eft, cond
+		if len(fields) < 5 || !slices.Contains(roles, fields[0]) || fields[2] != req.Method || !casbinutil.KeyMatch3(req.Path, fields[1]) {
+			continue
+		}
+		ok, err := rbac.EvalCondition(fields[4], req.Subject, req.Path, req.Method, rctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			unmet = append(unmet, p)
+		}
+	}
+	return unmet, nil
+}
+
+// collectPermissions fills the permissions matching the request and the menus, buttons and roles owning them.
+func collectPermissions(ctx *types.ServiceContext, rsp *modelauthz.ExplainRsp) error {
+	rsp.Permissions = make([]*modelauthz.Permission, 0)
+	rsp.Menus = make([]*modelauthz.Menu, 0)
+	rsp.Buttons = make([]*modelauthz.Button, 0)
+	rsp.Roles = make([]*modelauthz.Role, 0)
+
+	permissions := make([]*modelauthz.Permission, 0)
+	if err := database.Database[*modelauthz.Permission](ctx.DatabaseContext()).List(&permissions); err != nil {
+		return errors.Wrap(err, "failed to list permissions")
+	}
+	resources := make([]string, 0)
+	for _, p := range permissions {
+		if p.Action == rsp.Method && casbinutil.KeyMatch3(rsp.Path, p.Resource) {
+			rsp.Permissions = append(rsp.Permissions, p)
+			resources = append(resources, p.Resource)
+		}
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	menus := make([]*modelauthz.Menu, 0)
+	if err := database.Database[*modelauthz.Menu](ctx.DatabaseContext()).List(&menus); err != nil {
+		return errors.Wrap(err, "failed to list menus")
+	}
+	menuIDs := make([]string, 0)
+	for _, m := range menus {
+		if slices.ContainsFunc(m.API, func(api string) bool { return slices.Contains(resources, api) }) {
+			rsp.Menus = append(rsp.Menus, m)
+			menuIDs = append(menuIDs, m.ID)
+		}
+	}
+	if len(menuIDs) == 0 {
+		return nil
+	}
+
+	if err := database.Database[*modelauthz.Button](ctx.DatabaseContext()).
+		WithQuery(&modelauthz.Button{MenuID: strings.Join(menuIDs, ",")}).
+		List(&rsp.Buttons); err != nil {
+		return errors.Wrap(err, "failed to list buttons")
+	}
+
+	roles := make([]*modelauthz.Role, 0)
+	if err := database.Database[*modelauthz.Role](ctx.DatabaseContext()).List(&roles); err != nil {
+		return errors.Wrap(err, "failed to list roles")
+	}
+	for _, r := range roles {
+		if len(rsp.Tenant) > 0 && len(r.TenantID) > 0 && r.TenantID != rsp.Tenant {
+			continue
+		}
+		if slices.ContainsFunc(r.MenuIDs, func(id string) bool { return slices.Contains(menuIDs, id) }) {
+			rsp.Roles = append(rsp.Roles, r)
+		}
+	}
+	return nil
+}
+
+// applyPolicyChange applies the proposed change to the enforcer copy.
+func applyPolicyChange(ctx *types.ServiceContext, e *casbin.Enforcer, tenantMode bool, reqTenant string, ch *modelauthz.PolicyChange) error {
+	if len(ch.Role) == 0 {
+		return types.NewServiceError(http.StatusBadRequest, "role is required for change "+string(ch.Op))
+	}
+
+	r := rbac.New(e)
+	if tenantMode {
+		tenant := ch.Tenant
+		if len(tenant) == 0 {
+			// Permissions belong to the role's tenant, role assignments default to the request tenant.
+			roleTenant, err := roleTenant(ctx, ch.Role, reqTenant)
+			if err != nil {
+				return err
+			}
+			tenant = roleTenant
+			if len(tenant) == 0 && (ch.Op == modelauthz.PolicyChangeAssignRole || ch.Op == modelauthz.PolicyChangeUnassignRole) {
+				tenant = reqTenant
+			}
+		}
+		r = rbac.WithTenant(rbac.NewTenant(e), tenant)
+	}
+
+	var err error
+	switch ch.Op {
+	case modelauthz.PolicyChangeGrantPermission:
+		if len(ch.Resource) == 0 || len(ch.Action) == 0 {
+			return types.NewServiceError(http.StatusBadRequest, "resource and action are required for change "+string(ch.Op))
+		}
+		err = r.GrantConditionalPermission(ch.Role, ch.Resource, strings.ToUpper(ch.Action), ch.Condition)
+	case modelauthz.PolicyChangeRevokePermission:
+		err = r.RevokePermission(ch.Role, ch.Resource, strings.ToUpper(ch.Action))
+	case modelauthz.PolicyChangeAssignRole, modelauthz.PolicyChangeUnassignRole:
+		if len(ch.Subject) == 0 {
+			return types.NewServiceError(http.StatusBadRequest, "subject is required for change "+string(ch.Op))
+		}
+		if ch.Op == modelauthz.PolicyChangeAssignRole {
+			err = r.AssignRole(ch.Subject, ch.Role)
+		} else {
+			err = r.UnassignRole(ch.Subject, ch.Role)
+		}
+	case modelauthz.PolicyChangeRemoveRole:
+		err = r.RemoveRole(ch.Role)
+	case modelauthz.PolicyChangeSetRoleMenus:
+		grants, listErr := modelauthz.MenuGrants(ctx.DatabaseContext(), ch.MenuIDs, ch.Conditions)
+		if listErr != nil {
+			return errors.Wrap(listErr, "failed to list menu permissions")
+		}
+		if err = r.RevokePermission(ch.Role, "", ""); err != nil {
+			break
+		}
+		for _, g := range grants {
+			if err = r.GrantConditionalPermission(ch.Role, g.Resource, g.Action, g.Condition); err != nil {
+				break
+			}
+		}
+	default:
+		return types.NewServiceError(http.StatusBadRequest, "unknown change op: "+string(ch.Op))
+	}
+	if err != nil {
+		return types.NewServiceErrorWithCause(http.StatusBadRequest, "failed to apply change "+string(ch.Op), err)
+	}
+	return nil
+}
+
+// roleTenant returns the tenant of the role, the role of the request tenant takes precedence
+// over the global role of the same code. Empty for global or unknown roles.
+func roleTenant(ctx *types.ServiceContext, code, reqTenant string) (string, error) {
+	role, err := modelauthz.FindRoleByCode(ctx.DatabaseContext(), code, reqTenant)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to query role")
+	}
+	if role == nil {
+		return "", nil
+	}
+	return role.TenantID, nil
+}
+
+// hasRole reports whether the subject holds the role directly or through inheritance.
+func hasRole(e *casbin.Enforcer, subject, role string, domain ...string) bool {
+	roles, err := e.GetImplicitRolesForUser(subject, domain...)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(roles, role)
+}
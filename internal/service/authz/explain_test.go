@@ -0,0 +1,251 @@
+package serviceauthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v3"
+	casbinmodel "github.com/casbin/casbin/v3/model"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/cache"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/database/helper"
+	"github.com/forbearing/gst/database/sqlite"
+	modelauthz "github.com/forbearing/gst/internal/model/authz"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const basicModel = `
+[request_definition]
+r = sub, obj, act, ctx
+[policy_definition]
+p = sub, obj, act, eft, cond
+[role_definition]
+g = _, _
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = g(r.sub, "admin") || (g(r.sub, p.sub) && keyMatch3(r.obj, p.obj) && r.act == p.act && cond(p.cond, r.sub, r.obj, r.act, r.ctx))
+`
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "serviceauthz")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "true")
+	os.Setenv(config.LOGGER_DIR, dir)
+
+	model.Register[*modelauthz.Permission]()
+	model.Register[*modelauthz.Menu]()
+	model.Register[*modelauthz.Button]()
+	model.Register[*modelauthz.Role]()
+	model.Register[*modelauthz.UserRole]()
+	for _, fn := range []func() error{config.Init, pkgzap.Init, cache.Init, sqlite.Init} {
+		if err = fn(); err != nil {
+			panic(err)
+		}
+	}
+	helper.Wait()
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setup installs a fresh basic enforcer as rbac.Enforcer and creates:
+//   - permissions GET and DELETE /api/docs/{id}
+//   - menu "docs" owning /api/docs/{id} and its button "doc:delete"
+//   - role "editor" owning the menu, and alice holding it
+//   - role "auditor" allowed GET /api/docs/{id} only from 10.0.0.0/8, and bob holding it
+func setup(t *testing.T) (*casbin.Enforcer, *modelauthz.Menu, *modelauthz.Button) {
+	t.Helper()
+	m, err := casbinmodel.NewModelFromString(basicModel)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	rbac.AddConditionFunctions(e)
+	rbac.Enforcer = e
+	t.Cleanup(func() {
+		rbac.Enforcer = nil
+		_ = database.Database[*modelauthz.Role](nil).WithPurge().Delete(listAll[*modelauthz.Role](t)...)
+		_ = database.Database[*modelauthz.Button](nil).WithPurge().Delete(listAll[*modelauthz.Button](t)...)
+		_ = database.Database[*modelauthz.Menu](nil).WithPurge().Delete(listAll[*modelauthz.Menu](t)...)
+		_ = database.Database[*modelauthz.Permission](nil).WithPurge().Delete(listAll[*modelauthz.Permission](t)...)
+	})
+
+	require.NoError(t, database.Database[*modelauthz.Permission](nil).Create(
+		&modelauthz.Permission{Resource: "/api/docs/{id}", Action: http.MethodGet},
+		&modelauthz.Permission{Resource: "/api/docs/{id}", Action: http.MethodDelete},
+	))
+	menu := &modelauthz.Menu{Label: "docs", Path: "/docs", API: []string{"/api/docs/{id}"}}
+	require.NoError(t, database.Database[*modelauthz.Menu](nil).Create(menu))
+	button := &modelauthz.Button{Name: "delete", Code: "doc:delete", MenuID: menu.ID}
+	require.NoError(t, database.Database[*modelauthz.Button](nil).Create(button))
+	// Creating the role grants it the permissions of its menus.
+	require.NoError(t, database.Database[*modelauthz.Role](nil).Create(&modelauthz.Role{Name: "Editor", Code: "editor", MenuIDs: []string{menu.ID}}))
+
+	r := rbac.New(e)
+	require.NoError(t, r.AssignRole("alice", "editor"))
+	require.NoError(t, r.GrantConditionalPermission("auditor", "/api/docs/{id}", http.MethodGet, "ipIn(r.ctx, '10.0.0.0/8')"))
+	require.NoError(t, r.AssignRole("bob", "auditor"))
+	return e, menu, button
+}
+
+func listAll[M types.Model](t *testing.T) []M {
+	t.Helper()
+	items := make([]M, 0)
+	require.NoError(t, database.Database[M](nil).List(&items))
+	return items
+}
+
+func newServiceContext() *types.ServiceContext {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/authz/explain", nil)
+	return types.NewServiceContext(c)
+}
+
+func TestExplainService(t *testing.T) {
+	_, menu, button := setup(t)
+	svc := &ExplainService{Base: service.Base[*modelauthz.Explain, *modelauthz.ExplainReq, *modelauthz.ExplainRsp]{Logger: pkgzap.New("")}}
+
+	t.Run("matched", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.ExplainReq{Subject: "alice", Path: "/api/docs/1", Method: "get"})
+		require.NoError(t, err)
+		require.True(t, rsp.Allowed)
+		require.Equal(t, consts.EffectAllow, rsp.Effect)
+		require.Equal(t, http.MethodGet, rsp.Method)
+		require.Empty(t, rsp.Bypass)
+		require.Equal(t, [][]string{{"editor", "/api/docs/{id}", http.MethodGet, string(consts.EffectAllow), consts.AUTHZ_CONDITION_NONE}}, rsp.MatchedPolicies)
+		require.Empty(t, rsp.UnmetPolicies)
+		require.Equal(t, [][]string{{"alice", "editor"}}, rsp.RoleChains)
+
+		require.Len(t, rsp.Permissions, 1)
+		require.Equal(t, http.MethodGet, rsp.Permissions[0].Action)
+		require.Len(t, rsp.Menus, 1)
+		require.Equal(t, menu.ID, rsp.Menus[0].ID)
+		require.Len(t, rsp.Buttons, 1)
+		require.Equal(t, button.ID, rsp.Buttons[0].ID)
+		require.Len(t, rsp.Roles, 1)
+		require.Equal(t, "editor", rsp.Roles[0].Code)
+	})
+
+	t.Run("unmet", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.ExplainReq{Subject: "bob", Path: "/api/docs/1", Method: http.MethodGet, ClientIP: "172.16.0.1"})
+		require.NoError(t, err)
+		require.False(t, rsp.Allowed)
+		require.Equal(t, consts.EffectDeny, rsp.Effect)
+		require.Empty(t, rsp.MatchedPolicies)
+		require.Equal(t, [][]string{{"auditor", "/api/docs/{id}", http.MethodGet, string(consts.EffectAllow), "ipIn(r.ctx, '10.0.0.0/8')"}}, rsp.UnmetPolicies)
+		require.Equal(t, [][]string{{"bob", "auditor"}}, rsp.RoleChains)
+		// The menus and roles granting the request are reported for denied requests too.
+		require.Len(t, rsp.Menus, 1)
+		require.Len(t, rsp.Roles, 1)
+
+		// The condition is met from the allowed network.
+		rsp, err = svc.Create(newServiceContext(), &modelauthz.ExplainReq{Subject: "bob", Path: "/api/docs/1", Method: http.MethodGet, ClientIP: "10.1.2.3"})
+		require.NoError(t, err)
+		require.True(t, rsp.Allowed)
+		require.Empty(t, rsp.UnmetPolicies)
+	})
+
+	t.Run("no_matching_permission", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.ExplainReq{Subject: "alice", Path: "/api/users/1", Method: http.MethodGet})
+		require.NoError(t, err)
+		require.False(t, rsp.Allowed)
+		require.Empty(t, rsp.MatchedPolicies)
+		require.Empty(t, rsp.UnmetPolicies)
+		require.Empty(t, rsp.Permissions)
+		require.Empty(t, rsp.Menus)
+		require.Empty(t, rsp.Buttons)
+		require.Empty(t, rsp.Roles)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := svc.Create(newServiceContext(), &modelauthz.ExplainReq{Subject: "alice", Method: http.MethodGet})
+		require.Error(t, err)
+		_, err = svc.Create(newServiceContext(), &modelauthz.ExplainReq{Path: "/api/docs/1", Method: http.MethodGet})
+		require.Error(t, err)
+	})
+}
+
+func TestSimulateService(t *testing.T) {
+	e, _, _ := setup(t)
+	svc := &SimulateService{Base: service.Base[*modelauthz.Simulate, *modelauthz.SimulateReq, *modelauthz.SimulateRsp]{Logger: pkgzap.New("")}}
+	policies, err := e.GetPolicy()
+	require.NoError(t, err)
+	groupings, err := e.GetGroupingPolicy()
+	require.NoError(t, err)
+
+	t.Run("grant_permission", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.SimulateReq{
+			ExplainReq: modelauthz.ExplainReq{Subject: "bob", Path: "/api/docs/1", Method: http.MethodDelete},
+			Changes:    []modelauthz.PolicyChange{{Op: modelauthz.PolicyChangeGrantPermission, Role: "auditor", Resource: "/api/docs/{id}", Action: "delete"}},
+		})
+		require.NoError(t, err)
+		require.False(t, rsp.Current.Allowed)
+		require.True(t, rsp.Simulated.Allowed)
+		require.True(t, rsp.Changed)
+		require.Equal(t, [][]string{{"auditor", "/api/docs/{id}", http.MethodDelete, string(consts.EffectAllow), consts.AUTHZ_CONDITION_NONE}}, rsp.Simulated.MatchedPolicies)
+	})
+
+	t.Run("unassign_role", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.SimulateReq{
+			ExplainReq: modelauthz.ExplainReq{Subject: "alice", Path: "/api/docs/1", Method: http.MethodGet},
+			Changes:    []modelauthz.PolicyChange{{Op: modelauthz.PolicyChangeUnassignRole, Role: "editor", Subject: "alice"}},
+		})
+		require.NoError(t, err)
+		require.True(t, rsp.Current.Allowed)
+		require.False(t, rsp.Simulated.Allowed)
+		require.True(t, rsp.Changed)
+		require.Empty(t, rsp.Simulated.RoleChains)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		rsp, err := svc.Create(newServiceContext(), &modelauthz.SimulateReq{
+			ExplainReq: modelauthz.ExplainReq{Subject: "alice", Path: "/api/docs/1", Method: http.MethodGet},
+			Changes:    []modelauthz.PolicyChange{{Op: modelauthz.PolicyChangeAssignRole, Role: "auditor", Subject: "alice"}},
+		})
+		require.NoError(t, err)
+		require.True(t, rsp.Current.Allowed)
+		require.True(t, rsp.Simulated.Allowed)
+		require.False(t, rsp.Changed)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := svc.Create(newServiceContext(), &modelauthz.SimulateReq{ExplainReq: modelauthz.ExplainReq{Subject: "alice", Path: "/api/docs/1", Method: http.MethodGet}})
+		require.Error(t, err)
+		_, err = svc.Create(newServiceContext(), &modelauthz.SimulateReq{
+			ExplainReq: modelauthz.ExplainReq{Subject: "alice", Path: "/api/docs/1", Method: http.MethodGet},
+			Changes:    []modelauthz.PolicyChange{{Op: "rename_role", Role: "editor"}},
+		})
+		require.Error(t, err)
+	})
+
+	// The simulations must leave the live enforcer unchanged.
+	after, err := e.GetPolicy()
+	require.NoError(t, err)
+	require.Equal(t, policies, after)
+	afterGroupings, err := e.GetGroupingPolicy()
+	require.NoError(t, err)
+	require.Equal(t, groupings, afterGroupings)
+
+	rctx := rbac.NewContext(t.Context(), time.Now(), "", nil)
+	ok, err := e.Enforce("bob", "/api/docs/1", http.MethodDelete, rctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = e.Enforce("alice", "/api/docs/1", http.MethodGet, rctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
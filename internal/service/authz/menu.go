@@ -4,7 +4,9 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/forbearing/gst/authz/rbac"
 	"github.com/forbearing/gst/database"
 	modelauthz "github.com/forbearing/gst/internal/model/authz"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
@@ -29,49 +31,10 @@ func (m *MenuService) filterByRole(ctx *types.ServiceContext, data *[]*modelauth
 		return nil
 	}
 
-	var (
-		user      = new(modeliamuser.User)
-		userRoles = make([]*modelauthz.UserRole, 0)
-		roles     = make([]*modelauthz.Role, 0)
-	)
-
-	// query the current user
-	if err := database.Database[*modeliamuser.User](ctx.DatabaseContext()).Get(user, ctx.UserID); err != nil {
-		log.Error(err)
-		return err
-	}
-
-	// query all "UserRole" according to the current user id.
-	if err := database.Database[*modelauthz.UserRole](ctx.DatabaseContext()).
-		WithQuery(&modelauthz.UserRole{UserID: ctx.UserID}).
-		List(&userRoles); err != nil {
-		log.Error(err)
+	roles, err := currentRoles(ctx, log)
+	if err != nil {
 		return err
 	}
-
-	// query all "Role" according to the "UserRole"
-	if len(userRoles) > 0 {
-		roleIDs := make([]string, 0)
-		for _, ur := range userRoles {
-			if len(ur.RoleID) > 0 {
-				roleIDs = append(roleIDs, ur.RoleID)
-			}
-		}
-		if err := database.Database[*modelauthz.Role](ctx.DatabaseContext()).
-			WithQuery(&modelauthz.Role{Base: model.Base{ID: strings.Join(roleIDs, ",")}}).List(&roles); err != nil {
-			log.Error(err)
-			return err
-		}
-	}
-	// the user has no roles, use the default role.
-	if len(roles) == 0 {
-		if err := database.Database[*modelauthz.Role](ctx.DatabaseContext()).
-			WithQuery(&modelauthz.Role{Default: util.ValueOf(true)}).
-			List(&roles); err != nil {
-			log.Error(err)
-			return err
-		}
-	}
 	if len(roles) == 0 {
 		log.Warn("user has no roles and don't have default role")
 		// Clear the slice by dereferencing the pointer and assigning a new empty slice
@@ -83,14 +46,19 @@ func (m *MenuService) filterByRole(ctx *types.ServiceContext, data *[]*modelauth
 	}
 
 	{
+		rctx := conditionContext(ctx)
 		menuMap := make(map[string]struct{})
 		for _, role := range roles {
 			for _, id := range role.MenuIDs {
-				menuMap[id] = struct{}{}
+				if conditionMet(ctx, rctx, role, id, log) {
+					menuMap[id] = struct{}{}
+				}
 			}
 			// 这里需要把 MenuPartialIds 加进去, 父菜单下面有多个菜单, 如果只选中了部分, 则是将 id 放在 MenuPartialIds.
 			for _, id := range role.MenuPartialIDs {
-				menuMap[id] = struct{}{}
+				if conditionMet(ctx, rctx, role, id, log) {
+					menuMap[id] = struct{}{}
+				}
 			}
 		}
 		// fmt.Println("---- menuMap", len(menuMap))
@@ -119,6 +87,70 @@ func (m *MenuService) filterByRole(ctx *types.ServiceContext, data *[]*modelauth
 	}
 }
 
+// currentRoles returns the roles of the current user, or the default roles if the user has no roles.
+func currentRoles(ctx *types.ServiceContext, log types.Logger) ([]*modelauthz.Role, error) {
+	var (
+		user      = new(modeliamuser.User)
+		userRoles = make([]*modelauthz.UserRole, 0)
+		roles     = make([]*modelauthz.Role, 0)
+	)
+
+	// query the current user
+	if err := database.Database[*modeliamuser.User](ctx.DatabaseContext()).Get(user, ctx.UserID); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	// query all "UserRole" according to the current user id.
+	if err := database.Database[*modelauthz.UserRole](ctx.DatabaseContext()).
+		WithQuery(&modelauthz.UserRole{UserID: ctx.UserID}).
+		List(&userRoles); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	// query all "Role" according to the "UserRole"
+	if len(userRoles) > 0 {
+		roleIDs := make([]string, 0)
+		for _, ur := range userRoles {
+			if len(ur.RoleID) > 0 {
+				roleIDs = append(roleIDs, ur.RoleID)
+			}
+		}
+		if err := database.Database[*modelauthz.Role](ctx.DatabaseContext()).
+			WithQuery(&modelauthz.Role{Base: model.Base{ID: strings.Join(roleIDs, ",")}}).List(&roles); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	}
+	// the user has no roles, use the default role.
+	if len(roles) == 0 {
+		if err := database.Database[*modelauthz.Role](ctx.DatabaseContext()).
+			WithQuery(&modelauthz.Role{Default: util.ValueOf(true)}).
+			List(&roles); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// conditionContext returns the context the role conditions of menus and buttons are evaluated against.
+func conditionContext(ctx *types.ServiceContext) *rbac.Context {
+	return rbac.NewContext(ctx.Context(), time.Now(), ctx.ClientIP, ctx.Header)
+}
+
+// conditionMet reports whether the role's condition of the menu or button is met,
+// conditions failed to evaluate are not met.
+func conditionMet(ctx *types.ServiceContext, rctx *rbac.Context, role *modelauthz.Role, id string, log types.Logger) bool {
+	ok, err := rbac.EvalCondition(role.Condition(id), ctx.UserID, ctx.URL.Path, ctx.Method, rctx)
+	if err != nil {
+		log.Warnw("failed to evaluate role condition", "role_code", role.Code, "id", id, "error", err)
+		return false
+	}
+	return ok
+}
+
 // 递归过滤出当前角色所拥有的菜单. 作用于 menu.Children 字段.
 func filter(ctx *types.ServiceContext, menu *modelauthz.Menu, menuMap map[string]struct{}) {
 	if len(menu.Children) > 0 {
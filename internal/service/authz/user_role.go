@@ -1,6 +1,10 @@
 package serviceauthz
 
 import (
+	"fmt"
+	"net/http"
+
+	"github.com/forbearing/gst/authz/rbac"
 	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/internal/dao"
 	modelauthz "github.com/forbearing/gst/internal/model/authz"
@@ -15,14 +19,54 @@ type UserRoleService struct {
 	service.Base[*modelauthz.UserRole, *modelauthz.UserRole, *modelauthz.UserRole]
 }
 
-// DeleteAfter support filter and delete multiple user_roles by query parameter `username` and `rolecode`.
+// CreateBefore grants the role in the request tenant when tenant RBAC is enabled and no tenant is given.
+// Only super admins can grant roles in other tenants.
+func (s *UserRoleService) CreateBefore(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
+	return scopeTenant(ctx, userRole)
+}
+
+// UpdateBefore refuses to move the grants of other tenants, or into other tenants, unless the caller is a super admin.
+func (s *UserRoleService) UpdateBefore(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
+	if err := checkGrantTenant(ctx, userRole.ID); err != nil {
+		return err
+	}
+	return scopeTenant(ctx, userRole)
+}
+
+// PatchBefore works like UpdateBefore, an empty tenant is left unchanged.
+func (s *UserRoleService) PatchBefore(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
+	if err := checkGrantTenant(ctx, userRole.ID); err != nil {
+		return err
+	}
+	if len(userRole.TenantID) == 0 {
+		return nil
+	}
+	return scopeTenant(ctx, userRole)
+}
+
+// DeleteBefore refuses to revoke the grants of other tenants unless the caller is a super admin.
+func (s *UserRoleService) DeleteBefore(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
+	return checkGrantTenant(ctx, userRole.ID)
+}
+
+// DeleteAfter support filter and delete multiple user_roles by query parameter `username`, `rolecode` and `tenant_id`.
 func (s *UserRoleService) DeleteAfter(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
 	log := s.WithServiceContext(ctx, consts.PHASE_DELETE_AFTER)
 	username := ctx.URL.Query().Get("username")
 	roleCode := ctx.URL.Query().Get("rolecode")
+	tenantID := ctx.URL.Query().Get("tenant_id")
+	if len(username) == 0 && len(roleCode) == 0 {
+		return nil
+	}
+	if rbac.TenantEnabled() && !isSuperAdmin(ctx) {
+		if len(tenantID) > 0 && tenantID != requestTenant(ctx) {
+			return types.NewServiceError(http.StatusForbidden, fmt.Sprintf("can not revoke roles in tenant %q", tenantID))
+		}
+		tenantID = requestTenant(ctx)
+	}
 
 	userRoles := make([]*modelauthz.UserRole, 0)
-	if err := database.Database[*modelauthz.UserRole](ctx.DatabaseContext()).WithQuery(&modelauthz.UserRole{Username: username, RoleCode: roleCode}).List(&userRoles); err != nil {
+	if err := database.Database[*modelauthz.UserRole](ctx.DatabaseContext()).WithQuery(&modelauthz.UserRole{Username: username, RoleCode: roleCode, TenantID: tenantID}).List(&userRoles); err != nil {
 		log.Error(err)
 		return err
 	}
@@ -57,3 +101,52 @@ func (s *UserRoleService) ListAfter(ctx *types.ServiceContext, data *[]*modelaut
 
 	return nil
 }
+
+// isSuperAdmin reports whether the caller acts in every tenant, the subject is derived like middleware.Authz.
+func isSuperAdmin(ctx *types.ServiceContext) bool {
+	sub := ctx.UserID
+	if ctx.Username == consts.AUTHZ_USER_ROOT || ctx.Username == consts.AUTHZ_USER_ADMIN {
+		sub = ctx.Username
+	}
+	return rbac.IsSuperAdmin(sub)
+}
+
+// requestTenant returns the tenant resolved by middleware.Authz, the default tenant if there is none.
+func requestTenant(ctx *types.ServiceContext) string {
+	if len(ctx.TenantID) > 0 {
+		return ctx.TenantID
+	}
+	return consts.AUTHZ_TENANT_DEFAULT
+}
+
+// scopeTenant confines the grant to the request tenant unless the caller is a super admin,
+// so that a tenant admin can not grant roles in other tenants or in all tenants.
+func scopeTenant(ctx *types.ServiceContext, userRole *modelauthz.UserRole) error {
+	if !rbac.TenantEnabled() {
+		return nil
+	}
+	if len(userRole.TenantID) == 0 {
+		userRole.TenantID = requestTenant(ctx)
+		return nil
+	}
+	if userRole.TenantID != requestTenant(ctx) && !isSuperAdmin(ctx) {
+		return types.NewServiceError(http.StatusForbidden, fmt.Sprintf("can not grant roles in tenant %q", userRole.TenantID))
+	}
+	return nil
+}
+
+// checkGrantTenant refuses to change the existing grant unless it belongs to the request tenant
+// or the caller is a super admin.
+func checkGrantTenant(ctx *types.ServiceContext, id string) error {
+	if !rbac.TenantEnabled() || len(id) == 0 || isSuperAdmin(ctx) {
+		return nil
+	}
+	existing := new(modelauthz.UserRole)
+	if err := database.Database[*modelauthz.UserRole](ctx.DatabaseContext()).Get(existing, id); err != nil {
+		return err
+	}
+	if len(existing.ID) > 0 && existing.TenantID != requestTenant(ctx) {
+		return types.NewServiceError(http.StatusForbidden, fmt.Sprintf("can not change roles in tenant %q", existing.TenantID))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package serviceauthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/casbin/v3"
+	casbinmodel "github.com/casbin/casbin/v3/model"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	modelauthz "github.com/forbearing/gst/internal/model/authz"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const tenantModel = `
+[request_definition]
+r = tenant, sub, obj, act, ctx
+[policy_definition]
+p = tenant, sub, obj, act, eft, cond
+[role_definition]
+g = _, _, _
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = g(r.sub, "super_admin", "*") || g(r.sub, "admin", r.tenant)
+`
+
+// setupTenant installs a tenant enforcer where root is a super admin and alice is the admin of tenant t1.
+func setupTenant(t *testing.T) {
+	t.Helper()
+	m, err := casbinmodel.NewModelFromString(tenantModel)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain(consts.AUTHZ_USER_ROOT, consts.AUTHZ_ROLE_SUPER_ADMIN, consts.AUTHZ_TENANT_ALL)
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("alice", "admin", "t1")
+	require.NoError(t, err)
+	rbac.TenantEnforcer = e
+	t.Cleanup(func() {
+		rbac.TenantEnforcer = nil
+		_ = database.Database[*modelauthz.UserRole](nil).WithPurge().Delete(listAll[*modelauthz.UserRole](t)...)
+	})
+}
+
+func tenantContext(username, userID, tenant, query string) *types.ServiceContext {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/authz/user-roles"+query, nil)
+	ctx := types.NewServiceContext(c)
+	ctx.Username, ctx.UserID, ctx.TenantID = username, userID, tenant
+	return ctx
+}
+
+func requireStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	var se *types.ServiceError
+	require.True(t, errors.As(err, &se), "expected a service error, got %v", err)
+	require.Equal(t, code, se.StatusCode)
+}
+
+func TestUserRoleTenantScope(t *testing.T) {
+	setupTenant(t)
+	svc := &UserRoleService{Base: service.Base[*modelauthz.UserRole, *modelauthz.UserRole, *modelauthz.UserRole]{Logger: pkgzap.New("")}}
+	alice := tenantContext("alice", "alice", "t1", "")
+	root := tenantContext(consts.AUTHZ_USER_ROOT, "root-id", "t1", "")
+
+	// Grants default to the request tenant.
+	ur := &modelauthz.UserRole{UserID: "bob", RoleID: "r1"}
+	require.NoError(t, svc.CreateBefore(alice, ur))
+	require.Equal(t, "t1", ur.TenantID)
+
+	// Tenant admins can not grant roles in other tenants or in all tenants.
+	requireStatus(t, svc.CreateBefore(alice, &modelauthz.UserRole{UserID: "bob", RoleID: "r1", TenantID: "t2"}), http.StatusForbidden)
+	requireStatus(t, svc.CreateBefore(alice, &modelauthz.UserRole{UserID: "bob", RoleID: "r1", TenantID: consts.AUTHZ_TENANT_ALL}), http.StatusForbidden)
+	// Super admins can.
+	require.NoError(t, svc.CreateBefore(root, &modelauthz.UserRole{UserID: "bob", RoleID: "r1", TenantID: "t2"}))
+
+	// The grants of other tenants can not be changed or revoked.
+	foreign := &modelauthz.UserRole{UserID: "bob", Username: "bob", RoleID: "r1", TenantID: "t2"}
+	foreign.SetID("foreign")
+	own := &modelauthz.UserRole{UserID: "bob", Username: "bob", RoleID: "r1", TenantID: "t1"}
+	own.SetID("own")
+	require.NoError(t, database.DB.Create(foreign).Error)
+	require.NoError(t, database.DB.Create(own).Error)
+
+	requireStatus(t, svc.UpdateBefore(alice, &modelauthz.UserRole{Base: foreign.Base, TenantID: "t1"}), http.StatusForbidden)
+	requireStatus(t, svc.PatchBefore(alice, &modelauthz.UserRole{Base: foreign.Base}), http.StatusForbidden)
+	requireStatus(t, svc.DeleteBefore(alice, &modelauthz.UserRole{Base: foreign.Base}), http.StatusForbidden)
+	requireStatus(t, svc.UpdateBefore(alice, &modelauthz.UserRole{Base: own.Base, TenantID: "t2"}), http.StatusForbidden)
+	require.NoError(t, svc.UpdateBefore(alice, &modelauthz.UserRole{Base: own.Base, TenantID: "t1"}))
+	require.NoError(t, svc.DeleteBefore(alice, &modelauthz.UserRole{Base: own.Base}))
+	require.NoError(t, svc.DeleteBefore(root, &modelauthz.UserRole{Base: foreign.Base}))
+
+	// The bulk revoke is confined to the request tenant.
+	requireStatus(t, svc.DeleteAfter(tenantContext("alice", "alice", "t1", "?username=bob&tenant_id=t2"), new(modelauthz.UserRole)), http.StatusForbidden)
+	require.NoError(t, svc.DeleteAfter(tenantContext("alice", "alice", "t1", "?username=bob"), new(modelauthz.UserRole)))
+	remaining := listAll[*modelauthz.UserRole](t)
+	require.Len(t, remaining, 1)
+	require.Equal(t, "t2", remaining[0].TenantID)
+}
@@ -0,0 +1,81 @@
+package servicefeatureflag
+
+import (
+	"net/http"
+
+	"github.com/forbearing/gst/featureflag"
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+)
+
+// FlagService manages the flags, every change reloads the flags of all instances.
+type FlagService struct {
+	service.Base[*modelfeatureflag.Flag, *modelfeatureflag.Flag, *modelfeatureflag.Flag]
+}
+
+func (FlagService) CreateBefore(_ *types.ServiceContext, f *modelfeatureflag.Flag) error {
+	return validate(f)
+}
+
+func (FlagService) UpdateBefore(_ *types.ServiceContext, f *modelfeatureflag.Flag) error {
+	return validate(f)
+}
+
+func (FlagService) CreateAfter(*types.ServiceContext, *modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) DeleteAfter(*types.ServiceContext, *modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) UpdateAfter(*types.ServiceContext, *modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) PatchAfter(*types.ServiceContext, *modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) CreateManyAfter(*types.ServiceContext, ...*modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) DeleteManyAfter(*types.ServiceContext, ...*modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) UpdateManyAfter(*types.ServiceContext, ...*modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+func (FlagService) PatchManyAfter(*types.ServiceContext, ...*modelfeatureflag.Flag) error {
+	featureflag.Invalidate()
+	return nil
+}
+
+// validate reports the invalid flags as bad requests before they reach the model hooks.
+func validate(f *modelfeatureflag.Flag) error {
+	if err := f.Normalize(); err != nil {
+		return types.NewServiceError(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// EvaluateService evaluates all flags for the current user.
+type EvaluateService struct {
+	service.Base[*modelfeatureflag.Evaluate, *modelfeatureflag.Evaluate, *modelfeatureflag.EvaluateRsp]
+}
+
+func (s *EvaluateService) List(ctx *types.ServiceContext, req *modelfeatureflag.Evaluate) (*modelfeatureflag.EvaluateRsp, error) {
+	items := featureflag.EvaluateAll(ctx)
+	return &modelfeatureflag.EvaluateRsp{Items: items, Total: int64(len(items))}, nil
+}
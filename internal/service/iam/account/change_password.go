@@ -7,11 +7,11 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type ChangePasswordService struct {
@@ -42,30 +42,41 @@ func (s *ChangePasswordService) Create(ctx *types.ServiceContext, req *modeliama
 	user := users[0]
 
 	// Verify old password
-	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.OldPassword)); err != nil {
+	if ok, _ := serviceiampassword.Verify(ctx, user, req.OldPassword); !ok {
 		log.Error("old password verification failed", "username", user.Username)
 		return nil, fmt.Errorf("old password is incorrect")
 	}
 
+	// Check password policy and password history
+	if err = serviceiampassword.Validate(ctx, user, req.NewPassword); err != nil {
+		return nil, err
+	}
+
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	previousHash, err := serviceiampassword.Set(user, req.NewPassword)
 	if err != nil {
 		log.Error("failed to hash new password", err)
 		return nil, fmt.Errorf("failed to process new password")
 	}
 
 	// Update password in database
-	user.PasswordHash = string(hashedPassword)
 	user.MustChangePassword = false
 	if err := database.Database[*modeliamuser.User](ctx.DatabaseContext()).Update(user); err != nil {
 		log.Error("failed to update password", err)
 		return nil, fmt.Errorf("failed to update password")
 	}
+	if err = serviceiampassword.Record(ctx, user.ID, previousHash); err != nil {
+		log.Error("failed to record password history", err)
+	}
 
 	if syncErr := serviceiamsession.UpdateSessionMustChangePassword(sessionID, false); syncErr != nil {
 		log.Error("failed to sync session after password change", syncErr)
 		return nil, errors.Wrap(syncErr, "failed to refresh session")
 	}
+	// Devices trusted with the old password must pass the second factor again.
+	if err = serviceiamsession.RevokeTrustedDevices(user.ID); err != nil {
+		log.Error("failed to revoke trusted devices", err)
+	}
 
 	log.Info("password changed successfully", "username", user.Username)
 	return &modeliamaccount.ChangePasswordRsp{Msg: "password changed successfully"}, nil
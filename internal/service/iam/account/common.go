@@ -32,9 +32,24 @@ func mayManageProtectedUser(actorUsername string, actor, target *modeliamuser.Us
 
 // loadPrivilegedActorAndTarget resolves the current actor from session context and loads the requested target user.
 func loadPrivilegedActorAndTarget(ctx *types.ServiceContext, targetUserID string) (string, *modeliamuser.User, *modeliamuser.User, error) {
+	actorUsername, actor, err := loadActor(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	target := new(modeliamuser.User)
+	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).Get(target, targetUserID); err != nil {
+		return "", nil, nil, errors.Wrap(err, "user not found")
+	}
+
+	return actorUsername, actor, target, nil
+}
+
+// loadActor resolves the current actor from session context.
+func loadActor(ctx *types.ServiceContext) (string, *modeliamuser.User, error) {
 	_, session, err := serviceiamsession.GetCurrentSession(ctx)
 	if err != nil {
-		return "", nil, nil, errors.Wrap(err, "invalid session")
+		return "", nil, errors.Wrap(err, "invalid session")
 	}
 
 	actorUsername := session.Username
@@ -42,7 +57,7 @@ func loadPrivilegedActorAndTarget(ctx *types.ServiceContext, targetUserID string
 		actorUsername = ctx.Username
 	}
 	if actorUsername == "" {
-		return "", nil, nil, errors.New("actor username not found")
+		return "", nil, errors.New("actor username not found")
 	}
 
 	actors := make([]*modeliamuser.User, 0)
@@ -50,18 +65,13 @@ func loadPrivilegedActorAndTarget(ctx *types.ServiceContext, targetUserID string
 		WithLimit(1).
 		WithQuery(&modeliamuser.User{Username: actorUsername}).
 		List(&actors); err != nil {
-		return "", nil, nil, errors.Wrap(err, "database error")
+		return "", nil, errors.Wrap(err, "database error")
 	}
 	if len(actors) == 0 {
-		return "", nil, nil, errors.New("actor user not found")
-	}
-
-	target := new(modeliamuser.User)
-	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).Get(target, targetUserID); err != nil {
-		return "", nil, nil, errors.Wrap(err, "user not found")
+		return "", nil, errors.New("actor user not found")
 	}
 
-	return actorUsername, actors[0], target, nil
+	return actorUsername, actors[0], nil
 }
 
 // shouldInvalidateUserSessions returns whether a user status transition must revoke all active sessions.
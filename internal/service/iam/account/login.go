@@ -1,6 +1,8 @@
 package serviceiamaccount
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -12,10 +14,13 @@ import (
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
 	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	serviceiamemail "github.com/forbearing/gst/internal/service/iam/email"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
 	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
 	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/auditmanager"
 	"github.com/forbearing/gst/provider/redis"
 	"github.com/forbearing/gst/response"
 	"github.com/forbearing/gst/service"
@@ -24,9 +29,11 @@ import (
 	"github.com/mssola/useragent"
 	"github.com/pquerna/otp/totp"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// trustedDeviceCookie is the cookie carrying the trusted device token, see serviceiamsession.TrustDevice.
+const trustedDeviceCookie = "trusted_device"
+
 type LoginService struct {
 	service.Base[*model.Empty, *modeliamaccount.LoginReq, *modeliamaccount.LoginRsp]
 }
@@ -47,27 +54,60 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 	}
 
 	var success bool
+	// countFailure marks the failures caused by wrong credentials, they are counted by the login guard.
+	var countFailure bool
+	status := modellogmgmt.LoginStatusFailure
+	reason := ""
 	ua := useragent.New(ctx.UserAgent)
 	engineName, engineVersion := ua.Engine()
 	browserName, browserVersion := ua.Browser()
+	guard := newLoginGuard(ctx, log, req.Username)
+	// risk is the login anomaly assessment, it is nil until the attempt passed the login guard.
+	var risk *servicelogmgmt.LoginRisk
 
 	defer func() {
+		if success {
+			guard.succeed()
+			return
+		}
+		if countFailure {
+			guard.fail()
+		}
 		// write login log.
-		if !success && servicelogmgmt.Enabled {
-			if logErr := database.Database[*modellogmgmt.LoginLog](ctx.DatabaseContext()).Create(&modellogmgmt.LoginLog{
+		if servicelogmgmt.Enabled {
+			loginLog := &modellogmgmt.LoginLog{
 				Username: req.Username,
 				ClientIP: ctx.ClientIP,
-				Status:   modellogmgmt.LoginStatusFailure,
+				Status:   modellogmgmt.LoginStatus(status),
+				Reason:   reason,
 				Source:   ctx.Request.UserAgent(),
 				Platform: fmt.Sprintf("%s %s", ua.Platform(), ua.OS()),
 				Engine:   fmt.Sprintf("%s %s", engineName, engineVersion),
 				Browser:  fmt.Sprintf("%s %s", browserName, browserVersion),
-			}); logErr != nil {
+			}
+			risk.Apply(loginLog)
+			if logErr := auditmanager.RecordLogin(ctx.DatabaseContext(), loginLog); logErr != nil {
 				log.Warnz("failed to write login log", zap.Error(logErr))
 			}
 		}
 	}()
 
+	// Reject locked accounts and client ips before touching the password.
+	if err = guard.check(req.CaptchaToken); err != nil {
+		switch {
+		case errors.Is(err, errLoginLocked):
+			status, reason = modellogmgmt.LoginStatusLocked, "too many failed login attempts"
+			log.Warnz("login rejected by lockout", zap.String("username", req.Username), zap.String("client_ip", ctx.ClientIP))
+		case errors.Is(err, errCaptchaRequired):
+			reason = "captcha required"
+			log.Infoz("login rejected without valid captcha", zap.String("username", req.Username))
+		}
+		return nil, err
+	}
+
+	// Score the attempt by its location and the recent logins of the account.
+	risk = servicelogmgmt.AssessLogin(ctx.DatabaseContext(), req.Username, ctx.ClientIP)
+
 	// Find user by username
 	users := make([]*modeliamuser.User, 0)
 	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).WithLimit(1).WithQuery(&modeliamuser.User{Username: req.Username}).List(&users); err != nil {
@@ -76,21 +116,25 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 	}
 	if len(users) == 0 {
 		log.Warnz("user not found", zap.String("username", req.Username))
+		countFailure, reason = true, "invalid username or password"
 		return nil, fmt.Errorf("invalid username or password")
 	}
 	user := users[0]
 
 	// Check if user is enabled
 	if user.Status == modeliamuser.UserStatusInactive {
+		reason = "account inactive"
 		return nil, types.NewServiceError(http.StatusForbidden, "", response.CodeAccountInactive)
 	}
 	if user.Status == modeliamuser.UserStatusLocked {
+		status, reason = modellogmgmt.LoginStatusLocked, "account locked"
 		return nil, types.NewServiceError(http.StatusForbidden, "", response.CodeAccountLocked)
 	}
 
 	// Verify password
-	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		log.Warnz("invalid password", zap.String("username", req.Username))
+	if ok, verifyErr := serviceiampassword.Verify(ctx, user, req.Password); !ok {
+		log.Warnz("invalid password", zap.String("username", req.Username), zap.Error(verifyErr))
+		countFailure, reason = true, "invalid username or password"
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
@@ -101,18 +145,50 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 		return nil, fmt.Errorf("internal server error")
 	}
 
-	// If user has 2FA enabled, validate the 2FA code
+	// A trusted device presenting its token skips the second factor.
+	deviceID := serviceiamsession.DeviceFingerprint(user.ID, ua.Platform(), ua.OS(), browserName)
+	trusted := false
 	if has2FA {
-		// Check if either TOTP code or backup code is provided
-		if req.TOTPCode == "" && req.BackupCode == "" {
+		if token, cookieErr := ctx.Cookie(trustedDeviceCookie); cookieErr == nil {
+			trusted = serviceiamsession.IsTrustedDevice(user.ID, deviceID, token)
+		}
+		if trusted && risk != nil && risk.Require2FA {
+			trusted = false
+			log.Infoz("2FA required on trusted device by risky login", zap.String("username", req.Username), zap.Int("risk_score", risk.Score))
+		}
+		if trusted {
+			log.Infoz("2FA skipped on trusted device", zap.String("username", req.Username), zap.String("device_id", deviceID))
+		}
+	}
+	// Risky logins of users without 2FA can't be challenged, reject them if configured.
+	if risk.Blocked(has2FA) {
+		log.Warnz("risky login rejected without 2FA", zap.String("username", req.Username), zap.Int("risk_score", risk.Score), zap.Strings("risk_factors", risk.Factors))
+		reason = "risky login requires 2FA"
+		return nil, types.NewServiceError(http.StatusForbidden, "risky login requires 2FA, please enable 2FA or contact the administrator")
+	}
+
+	// If user has 2FA enabled, validate the 2FA code
+	if has2FA && !trusted {
+		// Check if either TOTP code, backup code or WebAuthn assertion is provided
+		if req.TOTPCode == "" && req.BackupCode == "" && req.WebAuthnCredential == nil {
 			log.Infoz("2FA required but no code provided", zap.String("username", req.Username))
+			reason = "2FA required"
 			return nil, fmt.Errorf("2FA verification required")
 		}
 
-		// Validate TOTP code if provided
-		if req.TOTPCode != "" {
+		if req.WebAuthnCredential != nil {
+			// Validate WebAuthn assertion if provided
+			if err = servicetwofa.ValidateWebAuthnAssertion(ctx, user.ID, req.WebAuthnCeremonyID, req.WebAuthnCredential); err != nil {
+				log.Warnz("invalid webauthn assertion", zap.String("username", req.Username), zap.Error(err))
+				countFailure, reason = true, "invalid 2FA credential"
+				return nil, fmt.Errorf("invalid 2FA credential")
+			}
+			log.Infoz("webauthn assertion validated successfully", zap.String("username", req.Username))
+		} else if req.TOTPCode != "" {
+			// Validate TOTP code if provided
 			if err = validateTOTPCode(ctx, user.ID, req.TOTPCode); err != nil {
 				log.Warnz("invalid TOTP code", zap.String("username", req.Username), zap.Error(err))
+				countFailure, reason = true, "invalid 2FA code"
 				return nil, fmt.Errorf("invalid 2FA code")
 			}
 			log.Infoz("TOTP code validated successfully", zap.String("username", req.Username))
@@ -120,15 +196,67 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 			// Validate backup code if provided
 			if err = validateBackupCode(ctx, user.ID, req.BackupCode); err != nil {
 				log.Warnz("invalid backup code", zap.String("username", req.Username), zap.Error(err))
+				countFailure, reason = true, "invalid backup code"
 				return nil, fmt.Errorf("invalid backup code")
 			}
 			log.Infoz("backup code validated successfully", zap.String("username", req.Username))
 		}
 	}
 
-	// Update last login time
+	sessionID, err := createSession(ctx, log, user, ua, risk)
+	if err != nil {
+		if errors.Is(err, serviceiamsession.ErrSessionLimitExceeded) {
+			reason = "maximum concurrent sessions reached"
+		}
+		return nil, err
+	}
+	success = true
+
+	// Remember the device after a successful second factor.
+	if has2FA && !trusted && req.RememberDevice {
+		rememberDevice(ctx, log, user.ID, deviceID)
+	}
+
+	log.Infoz("user logged in successfully", zap.String("username", req.Username), zap.String("user_id", user.ID))
+
+	return &modeliamaccount.LoginRsp{
+		SessionID: sessionID,
+	}, nil
+}
+
+// createSession updates the last login time of the user, stores a new session in redis,
+// sets the session cookie and writes the success login log.
+// The concurrent session limit is enforced before the session is created,
+// and the user is notified when the session is created on a new device.
+// The login anomaly assessment risk is recorded in the login log, it may be nil.
+func createSession(ctx *types.ServiceContext, log types.Logger, user *modeliamuser.User, ua *useragent.UserAgent, risk *servicelogmgmt.LoginRisk) (string, error) {
+	var err error
+	engineName, engineVersion := ua.Engine()
+	browserName, browserVersion := ua.Browser()
+
+	// Make room for the new session or reject it once the user reached the maximum concurrent sessions.
+	release, err := serviceiamsession.EnforceSessionLimit(user.ID, user.GroupID)
+	if err != nil {
+		if errors.Is(err, serviceiamsession.ErrSessionLimitExceeded) {
+			log.Infoz("login rejected by session limit", zap.String("username", user.Username))
+			return "", types.NewServiceErrorWithCause(http.StatusConflict, "", err, response.CodeTooManySessions)
+		}
+		log.Errorz("failed to enforce session limit", zap.Error(err))
+		return "", fmt.Errorf("failed to enforce session limit")
+	}
+	// The concurrent logins of the user wait until the new session is indexed.
+	defer release()
+
+	// The first login of the user is not reported as a new-device login.
+	firstLogin := user.LastLoginAt == nil
+
+	// Update last login time, and force a password change once the password expired.
 	now := time.Now()
 	user.LastLoginAt = &now
+	if !user.MustChangePassword && serviceiampassword.Expired(user) {
+		log.Infoz("password expired, password change required", zap.String("username", user.Username))
+		user.MustChangePassword = true
+	}
 	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).Update(user); err != nil {
 		log.Errorz("failed to update last login time", zap.Error(err))
 		// Don't fail the login for this
@@ -157,6 +285,7 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 		LastName:           user.LastName,
 		GroupID:            user.GroupID,
 		GroupName:          group.Name,
+		TenantID:           util.Deref(user.TenantID),
 		MustChangePassword: user.MustChangePassword,
 		ClientIP:           ctx.ClientIP,
 		UserAgent:          ctx.Request.UserAgent(),
@@ -164,6 +293,7 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 		Platform:           ua.Platform(),
 		EngineName:         engineName,
 		BrowserName:        browserName,
+		DeviceID:           serviceiamsession.DeviceFingerprint(user.ID, ua.Platform(), ua.OS(), browserName),
 		State:              modeliamsession.SessionStatusActive,
 		IssuedAt:           now,
 		LastSeenAt:         now,
@@ -172,13 +302,15 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 	// Store session in Redis
 	if err = redis.Cache[modeliamsession.Session]().Set(prefixedSessionID, sessionData, expire); err != nil {
 		log.Errorz("failed to set session in redis", zap.Error(err))
-		return nil, fmt.Errorf("failed to set session in redis")
+		return "", fmt.Errorf("failed to set session in redis")
 	}
 	if err = serviceiamsession.TrackUserSession(sessionData); err != nil {
 		log.Errorz("failed to track user session in redis", zap.Error(err))
-		return nil, fmt.Errorf("failed to track user session in redis")
+		return "", fmt.Errorf("failed to track user session in redis")
 	}
 
+	recordDevice(ctx, log, sessionData, firstLogin)
+
 	// Set cookie
 	//nolint:gosec // Secure is intentionally false so local HTTP development keeps session cookies.
 	http.SetCookie(ctx.Writer, &http.Cookie{
@@ -191,12 +323,9 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 		SameSite: http.SameSiteLaxMode,  // Lax mode
 	})
 
-	log.Infoz("user logged in successfully", zap.String("username", req.Username), zap.String("user_id", user.ID))
-
 	// write login log
-	success = true
 	if servicelogmgmt.Enabled {
-		if err = database.Database[*modellogmgmt.LoginLog](ctx.DatabaseContext()).Create(&modellogmgmt.LoginLog{
+		loginLog := &modellogmgmt.LoginLog{
 			UserID:   user.ID,
 			Username: user.Username,
 			ClientIP: ctx.ClientIP,
@@ -206,17 +335,79 @@ func localLogin(ctx *types.ServiceContext, log types.Logger, req *modeliamaccoun
 			Platform: fmt.Sprintf("%s %s", ua.Platform(), ua.OS()),
 			Engine:   fmt.Sprintf("%s %s", engineName, engineVersion),
 			Browser:  fmt.Sprintf("%s %s", browserName, browserVersion),
-		}); err != nil {
+		}
+		risk.Apply(loginLog)
+		if err = auditmanager.RecordLogin(ctx.DatabaseContext(), loginLog); err != nil {
 			log.Warnz("failed to write login log", zap.Error(err))
 		}
 	}
 
-	return &modeliamaccount.LoginRsp{
-		SessionID: sessionID,
-	}, nil
+	return sessionID, nil
 }
 
-// checkUserHas2FA checks if the user has active TOTP devices
+// rememberDevice trusts the device of the user and sets the trusted device cookie,
+// failures are logged and don't fail the login.
+func rememberDevice(ctx *types.ServiceContext, log types.Logger, userID, deviceID string) {
+	ttl := serviceiamsession.GetSessionPolicy().TrustedDeviceTTL
+	if ttl <= 0 {
+		return
+	}
+	token, _, err := serviceiamsession.TrustDevice(userID, deviceID, ctx.ClientIP)
+	if err != nil {
+		log.Warnz("failed to trust device", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	//nolint:gosec // Secure is intentionally false so local HTTP development keeps the cookie, same as session_id.
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     trustedDeviceCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// recordDevice records the device of the session and sends the new-device login
+// notification asynchronously when enabled, failures are logged and don't fail the login.
+func recordDevice(ctx *types.ServiceContext, log types.Logger, session modeliamsession.Session, firstLogin bool) {
+	isNew, err := serviceiamsession.RecordDevice(modeliamsession.Device{
+		ID:          session.DeviceID,
+		UserID:      session.UserID,
+		Platform:    session.Platform,
+		OS:          session.OS,
+		BrowserName: session.BrowserName,
+		ClientIP:    session.ClientIP,
+		LastSeenAt:  session.IssuedAt,
+	})
+	if err != nil {
+		log.Warnz("failed to record device", zap.String("user_id", session.UserID), zap.Error(err))
+		return
+	}
+	if !isNew || firstLogin || !serviceiamsession.GetSessionPolicy().NotifyNewDevice {
+		return
+	}
+	log.Infoz("login from new device", zap.String("username", session.Username), zap.String("device_id", session.DeviceID))
+	notifyCtx := context.WithoutCancel(ctx.Context())
+	go func() {
+		if err := serviceiamemail.NotifyNewDevice(notifyCtx, serviceiamemail.NewDeviceLogin{
+			UserID:      session.UserID,
+			Username:    session.Username,
+			Email:       session.Email,
+			DeviceID:    session.DeviceID,
+			ClientIP:    session.ClientIP,
+			Platform:    session.Platform,
+			OS:          session.OS,
+			BrowserName: session.BrowserName,
+			LoginAt:     session.IssuedAt,
+		}); err != nil {
+			log.Warnz("failed to send new device notification", zap.String("user_id", session.UserID), zap.Error(err))
+		}
+	}()
+}
+
+// checkUserHas2FA checks if the user has active TOTP devices or WebAuthn credentials
 func checkUserHas2FA(ctx *types.ServiceContext, userID string) (bool, error) {
 	if !servicetwofa.Enabled {
 		return false, nil
@@ -231,8 +422,11 @@ func checkUserHas2FA(ctx *types.ServiceContext, userID string) (bool, error) {
 	}).List(&devices); err != nil {
 		return false, fmt.Errorf("failed to query TOTP devices: %w", err)
 	}
+	if len(devices) > 0 {
+		return true, nil
+	}
 
-	return len(devices) > 0, nil
+	return servicetwofa.HasWebAuthnCredentials(ctx, userID)
 }
 
 // validateTOTPCode validates the provided TOTP code for the user
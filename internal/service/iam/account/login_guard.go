@@ -0,0 +1,378 @@
+package serviceiamaccount
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
+	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// loginGuardNamespace is the redis key prefix of all brute-force protection state.
+	loginGuardNamespace = "iam:login"
+
+	// HeaderCaptchaRequired is set to "true" on login responses once a captcha must be solved.
+	HeaderCaptchaRequired = "X-Captcha-Required"
+)
+
+// LoginAlertKind is the kind of a login alert.
+type LoginAlertKind string
+
+const (
+	LoginAlertAccountLocked LoginAlertKind = "account_locked"
+	LoginAlertIPLocked      LoginAlertKind = "ip_locked"
+)
+
+// LoginAlert is passed to LoginProtectionConfig.AlertHook when an account or a client ip is locked.
+type LoginAlert struct {
+	Kind     LoginAlertKind
+	Username string
+	ClientIP string
+	Lock     modeliamaccount.LoginLock
+}
+
+// LoginProtectionConfig is the brute-force protection configuration for login.
+type LoginProtectionConfig struct {
+	Enable             bool          // Enable enables brute-force protection, default is false
+	MaxAccountFailures int           // MaxAccountFailures is the failures per account before lockout, default is 5
+	MaxIPFailures      int           // MaxIPFailures is the failures per client ip before lockout, default is 20
+	FailureWindow      time.Duration // FailureWindow is the window in which failures are counted, default is 15 minutes
+	LockoutDuration    time.Duration // LockoutDuration is the first lockout duration, doubled on every consecutive lockout, default is 15 minutes
+	MaxLockoutDuration time.Duration // MaxLockoutDuration caps the lockout duration and is how long consecutive lockouts are remembered, default is 24 hours
+	DelayBase          time.Duration // DelayBase is the delay after the first failure, doubled on every further failure, default is 500ms
+	MaxDelay           time.Duration // MaxDelay caps the progressive delay, default is 5 seconds
+	CaptchaThreshold   int           // CaptchaThreshold is the failures after which a captcha is required, 0 disables captcha
+
+	// CaptchaVerifier verifies the captcha token of the login request.
+	// If nil, the captcha requirement is only signalled by the X-Captcha-Required response header.
+	CaptchaVerifier func(ctx *types.ServiceContext, token string) bool
+	// AlertHook is called asynchronously every time an account or a client ip is locked.
+	AlertHook func(alert LoginAlert)
+}
+
+var (
+	loginProtection   LoginProtectionConfig
+	loginProtectionMu sync.RWMutex
+)
+
+// SetLoginProtection sets the brute-force protection configuration for login.
+// This function should be called during module registration.
+func SetLoginProtection(cfg LoginProtectionConfig) {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = 5
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = 20
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = 15 * time.Minute
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 15 * time.Minute
+	}
+	if cfg.MaxLockoutDuration <= 0 {
+		cfg.MaxLockoutDuration = 24 * time.Hour
+	}
+	if cfg.DelayBase <= 0 {
+		cfg.DelayBase = 500 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 5 * time.Second
+	}
+
+	loginProtectionMu.Lock()
+	loginProtection = cfg
+	loginProtectionMu.Unlock()
+
+	// The twofa endpoints verifying the password share the login guard.
+	servicetwofa.SetCredentialGuard(func(ctx *types.ServiceContext, log types.Logger, username string) servicetwofa.CredentialGuard {
+		return credentialGuard{newLoginGuard(ctx, log, username)}
+	})
+}
+
+func getLoginProtection() LoginProtectionConfig {
+	loginProtectionMu.RLock()
+	defer loginProtectionMu.RUnlock()
+	return loginProtection
+}
+
+var (
+	errLoginLocked     = errors.New("login locked")
+	errCaptchaRequired = errors.New("captcha required")
+)
+
+func loginFailuresKey(kind modeliamaccount.LoginLockKind, subject string) string {
+	return loginGuardNamespace + ":failures:" + string(kind) + ":" + subject
+}
+
+func loginLockKey(kind modeliamaccount.LoginLockKind, subject string) string {
+	return loginGuardNamespace + ":lock:" + string(kind) + ":" + subject
+}
+
+func loginLockLevelKey(kind modeliamaccount.LoginLockKind, subject string) string {
+	return loginGuardNamespace + ":level:" + string(kind) + ":" + subject
+}
+
+// loginLocksKey is the sorted set indexing all lockouts, scored by the unlock time.
+func loginLocksKey() string {
+	return loginGuardNamespace + ":locks"
+}
+
+func loginLockMember(kind modeliamaccount.LoginLockKind, subject string) string {
+	return string(kind) + ":" + subject
+}
+
+// loginGuard tracks the brute-force protection state of one login request.
+// A nil loginGuard means protection is disabled and all methods are no-ops.
+// An empty username means the account is not known yet, only the client ip is guarded.
+type loginGuard struct {
+	cfg      LoginProtectionConfig
+	ctx      *types.ServiceContext
+	log      types.Logger
+	username string
+	clientIP string
+
+	accountFailures int64
+	ipFailures      int64
+}
+
+func newLoginGuard(ctx *types.ServiceContext, log types.Logger, username string) *loginGuard {
+	cfg := getLoginProtection()
+	if !cfg.Enable {
+		return nil
+	}
+	return &loginGuard{
+		cfg:      cfg,
+		ctx:      ctx,
+		log:      log,
+		username: username,
+		clientIP: ctx.ClientIP,
+	}
+}
+
+// check rejects the attempt if the account or the client ip is locked or a required captcha
+// was not solved, and then applies the progressive delay.
+// The returned error wraps errLoginLocked or errCaptchaRequired.
+func (g *loginGuard) check(captchaToken string) error {
+	if g == nil {
+		return nil
+	}
+	if err := g.checkLock(); err != nil {
+		return err
+	}
+
+	if len(g.username) > 0 {
+		g.accountFailures = getLoginFailures(modeliamaccount.LoginLockKindAccount, g.username)
+	}
+	g.ipFailures = getLoginFailures(modeliamaccount.LoginLockKindIP, g.clientIP)
+
+	if g.captchaRequired() {
+		g.ctx.Writer.Header().Set(HeaderCaptchaRequired, "true")
+		if g.cfg.CaptchaVerifier != nil && (len(captchaToken) == 0 || !g.cfg.CaptchaVerifier(g.ctx, captchaToken)) {
+			return types.NewServiceErrorWithCause(http.StatusBadRequest, "", errCaptchaRequired, response.CodeCaptchaRequired)
+		}
+	}
+
+	g.delay()
+	return nil
+}
+
+// checkLock rejects the attempt if the account or the client ip is locked.
+// The returned error wraps errLoginLocked.
+func (g *loginGuard) checkLock() error {
+	if g == nil {
+		return nil
+	}
+	for _, subject := range g.subjects() {
+		lock, err := getLoginLock(subject.kind, subject.value)
+		if err != nil {
+			if !errors.Is(err, types.ErrEntryNotFound) {
+				g.log.Warnz("failed to get login lock", zap.String("kind", string(subject.kind)), zap.Error(err))
+			}
+			continue
+		}
+		retryAfter := int(time.Until(lock.Until).Seconds()) + 1
+		g.ctx.Writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return types.NewServiceErrorWithCause(http.StatusTooManyRequests, "", errLoginLocked, response.CodeTooManyLoginAttempts)
+	}
+	return nil
+}
+
+// checkAccount sets the account resolved after the credential was verified,
+// and rejects the attempt if the account is locked.
+func (g *loginGuard) checkAccount(username string) error {
+	if g == nil {
+		return nil
+	}
+	g.username = username
+	return g.checkLock()
+}
+
+// fail records a failed attempt and locks the account or the client ip when its limit is reached.
+func (g *loginGuard) fail() {
+	if g == nil {
+		return
+	}
+	var err error
+	if len(g.username) > 0 {
+		if g.accountFailures, err = redis.IncrWithExpire(loginFailuresKey(modeliamaccount.LoginLockKindAccount, g.username), g.cfg.FailureWindow); err != nil {
+			g.log.Warnz("failed to count account login failure", zap.Error(err))
+		}
+	}
+	if g.ipFailures, err = redis.IncrWithExpire(loginFailuresKey(modeliamaccount.LoginLockKindIP, g.clientIP), g.cfg.FailureWindow); err != nil {
+		g.log.Warnz("failed to count ip login failure", zap.Error(err))
+	}
+
+	if len(g.username) > 0 && g.accountFailures >= int64(g.cfg.MaxAccountFailures) {
+		g.lock(modeliamaccount.LoginLockKindAccount, g.username, g.accountFailures)
+	}
+	if g.ipFailures >= int64(g.cfg.MaxIPFailures) {
+		g.lock(modeliamaccount.LoginLockKindIP, g.clientIP, g.ipFailures)
+	}
+	if g.captchaRequired() {
+		g.ctx.Writer.Header().Set(HeaderCaptchaRequired, "true")
+	}
+}
+
+// succeed resets the failure counter of the account, the client ip counter is kept
+// so one valid account cannot be used to keep guessing the others.
+func (g *loginGuard) succeed() {
+	if g == nil || len(g.username) == 0 {
+		return
+	}
+	if err := redis.Del(loginFailuresKey(modeliamaccount.LoginLockKindAccount, g.username)); err != nil {
+		g.log.Warnz("failed to reset account login failures", zap.Error(err))
+	}
+}
+
+func (g *loginGuard) lock(kind modeliamaccount.LoginLockKind, subject string, failures int64) {
+	level, err := redis.IncrWithExpire(loginLockLevelKey(kind, subject), g.cfg.MaxLockoutDuration)
+	if err != nil || level < 1 {
+		level = 1
+	}
+	duration := g.cfg.LockoutDuration
+	for i := int64(1); i < level && duration < g.cfg.MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, g.cfg.MaxLockoutDuration)
+
+	now := time.Now()
+	lock := modeliamaccount.LoginLock{
+		Kind:     kind,
+		Subject:  subject,
+		Failures: failures,
+		Level:    level,
+		LockedAt: now,
+		Until:    now.Add(duration),
+	}
+	if err = redis.Cache[modeliamaccount.LoginLock]().Set(loginLockKey(kind, subject), lock, duration); err != nil {
+		g.log.Errorz("failed to lock login", zap.String("kind", string(kind)), zap.String("subject", subject), zap.Error(err))
+		return
+	}
+	if err = redis.ZAdd(loginLocksKey(), float64(lock.Until.Unix()), loginLockMember(kind, subject)); err != nil {
+		g.log.Warnz("failed to index login lock", zap.Error(err))
+	}
+	// Start counting from zero again once the lockout expires.
+	_ = redis.Del(loginFailuresKey(kind, subject))
+
+	g.log.Warnz("login locked after repeated failures",
+		zap.String("kind", string(kind)),
+		zap.String("subject", subject),
+		zap.Int64("failures", failures),
+		zap.Int64("level", level),
+		zap.Duration("duration", duration),
+	)
+
+	if g.cfg.AlertHook != nil {
+		alert := LoginAlert{Kind: LoginAlertAccountLocked, Username: g.username, ClientIP: g.clientIP, Lock: lock}
+		if kind == modeliamaccount.LoginLockKindIP {
+			alert.Kind = LoginAlertIPLocked
+		}
+		hook := g.cfg.AlertHook
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					zap.S().Errorw("login alert hook panic", "panic", r)
+				}
+			}()
+			hook(alert)
+		}()
+	}
+}
+
+func (g *loginGuard) captchaRequired() bool {
+	if g.cfg.CaptchaThreshold <= 0 {
+		return false
+	}
+	threshold := int64(g.cfg.CaptchaThreshold)
+	return g.accountFailures >= threshold || g.ipFailures >= threshold
+}
+
+// delay sleeps DelayBase * 2^(failures-1), bounded by MaxDelay, or until the request is canceled.
+func (g *loginGuard) delay() {
+	failures := max(g.accountFailures, g.ipFailures)
+	if failures <= 0 {
+		return
+	}
+	d := g.cfg.DelayBase
+	for i := int64(1); i < failures && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, g.cfg.MaxDelay)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-g.ctx.Context().Done():
+	}
+}
+
+type loginSubject struct {
+	kind  modeliamaccount.LoginLockKind
+	value string
+}
+
+func (g *loginGuard) subjects() []loginSubject {
+	subjects := make([]loginSubject, 0, 2)
+	if len(g.username) > 0 {
+		subjects = append(subjects, loginSubject{kind: modeliamaccount.LoginLockKindAccount, value: g.username})
+	}
+	return append(subjects, loginSubject{kind: modeliamaccount.LoginLockKindIP, value: g.clientIP})
+}
+
+// credentialGuard adapts loginGuard to servicetwofa.CredentialGuard.
+type credentialGuard struct{ g *loginGuard }
+
+func (c credentialGuard) Check(captchaToken string) error { return c.g.check(captchaToken) }
+func (c credentialGuard) Fail()                           { c.g.fail() }
+
+func getLoginFailures(kind modeliamaccount.LoginLockKind, subject string) int64 {
+	n, err := redis.GetInt(loginFailuresKey(kind, subject))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func getLoginLock(kind modeliamaccount.LoginLockKind, subject string) (modeliamaccount.LoginLock, error) {
+	return redis.Cache[modeliamaccount.LoginLock]().Get(loginLockKey(kind, subject))
+}
+
+// clearLoginLock removes the lockout, the failure counter and the lockout level of subject.
+func clearLoginLock(kind modeliamaccount.LoginLockKind, subject string) error {
+	for _, key := range []string{loginLockKey(kind, subject), loginFailuresKey(kind, subject), loginLockLevelKey(kind, subject)} {
+		if err := redis.Del(key); err != nil {
+			return err
+		}
+	}
+	return redis.ZRem(loginLocksKey(), loginLockMember(kind, subject))
+}
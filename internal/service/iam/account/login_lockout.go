@@ -0,0 +1,102 @@
+package serviceiamaccount
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+)
+
+// LoginLockoutListService lists the active login lockouts for privileged administrators.
+type LoginLockoutListService struct {
+	service.Base[*model.Empty, *modeliamaccount.LoginLockoutListReq, *modeliamaccount.LoginLockoutListRsp]
+}
+
+// LoginUnlockService clears login lockouts and failure counters for privileged administrators.
+type LoginUnlockService struct {
+	service.Base[*model.Empty, *modeliamaccount.LoginUnlockReq, *modeliamaccount.LoginUnlockRsp]
+}
+
+func (s *LoginLockoutListService) List(ctx *types.ServiceContext, req *modeliamaccount.LoginLockoutListReq) (rsp *modeliamaccount.LoginLockoutListRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if err = ensurePrivilegedActor(ctx); err != nil {
+		log.Error("login lockout list denied", err)
+		return nil, err
+	}
+
+	members, err := redis.ZRange(loginLocksKey(), 0, -1)
+	if err != nil {
+		log.Error("failed to list login locks", err)
+		return nil, err
+	}
+
+	items := make([]modeliamaccount.LoginLock, 0, len(members))
+	for _, member := range members {
+		kind, subject, ok := strings.Cut(member, ":")
+		if !ok {
+			_ = redis.ZRem(loginLocksKey(), member)
+			continue
+		}
+		lock, getErr := getLoginLock(modeliamaccount.LoginLockKind(kind), subject)
+		if getErr != nil {
+			if errors.Is(getErr, types.ErrEntryNotFound) {
+				// The lockout expired, drop it from the index.
+				_ = redis.ZRem(loginLocksKey(), member)
+				continue
+			}
+			log.Error("failed to load login lock", getErr)
+			return nil, getErr
+		}
+		items = append(items, lock)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].LockedAt.After(items[j].LockedAt) })
+
+	return &modeliamaccount.LoginLockoutListRsp{Items: items, Total: int64(len(items))}, nil
+}
+
+func (s *LoginUnlockService) Create(ctx *types.ServiceContext, req *modeliamaccount.LoginUnlockReq) (rsp *modeliamaccount.LoginUnlockRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if req.Username == "" && req.ClientIP == "" {
+		return nil, errors.New("username or client_ip is required")
+	}
+	if err = ensurePrivilegedActor(ctx); err != nil {
+		log.Error("login unlock denied", err)
+		return nil, err
+	}
+
+	if req.Username != "" {
+		if err = clearLoginLock(modeliamaccount.LoginLockKindAccount, req.Username); err != nil {
+			log.Error("failed to clear account login lock", err)
+			return nil, errors.Wrap(err, "failed to unlock account")
+		}
+	}
+	if req.ClientIP != "" {
+		if err = clearLoginLock(modeliamaccount.LoginLockKindIP, req.ClientIP); err != nil {
+			log.Error("failed to clear ip login lock", err)
+			return nil, errors.Wrap(err, "failed to unlock client ip")
+		}
+	}
+
+	log.Info("login unlocked", "username", req.Username, "client_ip", req.ClientIP, "actor", ctx.Username)
+	return &modeliamaccount.LoginUnlockRsp{Msg: "login unlocked successfully"}, nil
+}
+
+// ensurePrivilegedActor rejects the request unless the current actor may manage privileged account operations.
+func ensurePrivilegedActor(ctx *types.ServiceContext) error {
+	actorUsername, actor, err := loadActor(ctx)
+	if err != nil {
+		return types.NewServiceErrorWithCause(http.StatusUnauthorized, "session invalid", err)
+	}
+	if !privilegedActor(actor, actorUsername) {
+		return types.NewServiceError(http.StatusForbidden, "forbidden: superuser privileges required")
+	}
+	return nil
+}
@@ -3,11 +3,11 @@ package serviceiamaccount
 import (
 	"fmt"
 
-	"github.com/forbearing/gst/database"
 	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
 	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/auditmanager"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
 	"github.com/mssola/useragent"
@@ -50,7 +50,7 @@ func localLogout(ctx *types.ServiceContext, log types.Logger, req *model.Empty)
 		username = session.Username
 	}
 
-	if logErr := database.Database[*modellogmgmt.LoginLog](ctx.DatabaseContext()).Create(&modellogmgmt.LoginLog{
+	if logErr := auditmanager.RecordLogin(ctx.DatabaseContext(), &modellogmgmt.LoginLog{
 		UserID:   userID,
 		Username: username,
 		ClientIP: ctx.ClientIP,
@@ -0,0 +1,72 @@
+package serviceiamaccount
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/forbearing/gst/database"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
+	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
+	"github.com/forbearing/gst/response"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/mssola/useragent"
+	"go.uber.org/zap"
+)
+
+// PasskeyLoginService completes a passwordless login with a discoverable WebAuthn credential.
+// The passkey replaces both the password and the second factor, so no further 2FA check is performed.
+type PasskeyLoginService struct {
+	service.Base[*modeltwofa.WebAuthnLoginFinish, *modeltwofa.WebAuthnLoginFinishReq, *modeltwofa.WebAuthnLoginFinishRsp]
+}
+
+func (s *PasskeyLoginService) Create(ctx *types.ServiceContext, req *modeltwofa.WebAuthnLoginFinishReq) (rsp *modeltwofa.WebAuthnLoginFinishRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	// The account is only known after the assertion is verified, so the client ip is guarded first.
+	guard := newLoginGuard(ctx, log, "")
+	if err = guard.check(req.CaptchaToken); err != nil {
+		log.Warnz("passkey login rejected by login guard", zap.String("client_ip", ctx.ClientIP), zap.Error(err))
+		return nil, err
+	}
+
+	userID, err := servicetwofa.FinishPasskeyLogin(ctx, req.CeremonyID, &req.Credential)
+	if err != nil {
+		log.Warnz("passkey login failed", zap.String("client_ip", ctx.ClientIP), zap.Error(err))
+		guard.fail()
+		return nil, err
+	}
+
+	user := new(modeliamuser.User)
+	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).Get(user, userID); err != nil || len(user.ID) == 0 {
+		log.Warnz("user not found", zap.String("user_id", userID))
+		guard.fail()
+		return nil, fmt.Errorf("invalid passkey")
+	}
+	if err = guard.checkAccount(user.Username); err != nil {
+		log.Warnz("passkey login rejected by lockout", zap.String("username", user.Username), zap.String("client_ip", ctx.ClientIP))
+		return nil, err
+	}
+	if user.Status == modeliamuser.UserStatusInactive {
+		return nil, types.NewServiceError(http.StatusForbidden, "", response.CodeAccountInactive)
+	}
+	if user.Status == modeliamuser.UserStatusLocked {
+		return nil, types.NewServiceError(http.StatusForbidden, "", response.CodeAccountLocked)
+	}
+
+	// A passkey is phishing resistant, the risk is only recorded.
+	risk := servicelogmgmt.AssessLogin(ctx.DatabaseContext(), user.Username, ctx.ClientIP)
+	sessionID, err := createSession(ctx, log, user, useragent.New(ctx.UserAgent), risk)
+	if err != nil {
+		return nil, err
+	}
+	guard.succeed()
+
+	log.Infoz("user logged in with passkey successfully", zap.String("username", user.Username), zap.String("user_id", user.ID))
+
+	return &modeltwofa.WebAuthnLoginFinishRsp{
+		SessionID: sessionID,
+	}, nil
+}
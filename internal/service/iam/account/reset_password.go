@@ -5,11 +5,11 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type ResetPasswordService struct {
@@ -31,21 +31,26 @@ func (s *ResetPasswordService) Create(ctx *types.ServiceContext, req *modeliamac
 		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err = serviceiampassword.Validate(ctx, target, req.NewPassword); err != nil {
+		return nil, err
+	}
+	previousHash, err := serviceiampassword.Set(target, req.NewPassword)
 	if err != nil {
 		log.Error("failed to hash new password", err)
 		return nil, errors.Wrap(err, "failed to process new password")
 	}
 
-	target.PasswordHash = string(hashedPassword)
 	target.MustChangePassword = true
 	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).
 		WithoutHook().
-		WithSelect("username", "password_hash", "must_change_password").
+		WithSelect("username", "password_hash", "password_changed_at", "must_change_password").
 		Update(target); err != nil {
 		log.Error("failed to update user password fields", err)
 		return nil, errors.Wrap(err, "failed to update password")
 	}
+	if err = serviceiampassword.Record(ctx, target.ID, previousHash); err != nil {
+		log.Error("failed to record password history", err)
+	}
 
 	serviceiamsession.InvalidateUserSessions(req.UserID)
 
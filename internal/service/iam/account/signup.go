@@ -6,11 +6,11 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamaccount "github.com/forbearing/gst/internal/model/iam/account"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type SignupService struct {
@@ -30,9 +30,6 @@ func (s *SignupService) Create(ctx *types.ServiceContext, req *modeliamaccount.S
 	if req.Password != req.RePassword {
 		return nil, fmt.Errorf("passwords do not match")
 	}
-	if len(req.Password) < 6 {
-		return nil, fmt.Errorf("password must be at least 6 characters long")
-	}
 
 	// Check if username already exists
 	existingUsers := make([]*modeliamuser.User, 0)
@@ -44,17 +41,18 @@ func (s *SignupService) Create(ctx *types.ServiceContext, req *modeliamaccount.S
 		return nil, fmt.Errorf("username already exists")
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Error("failed to hash password", zap.Error(err))
-		return nil, fmt.Errorf("failed to create user")
-	}
-
 	// Create new user
 	newUser := &modeliamuser.User{
-		Username:     req.Username,
-		PasswordHash: string(hashedPassword),
+		Username: req.Username,
+	}
+
+	// Check password policy and hash password
+	if err = serviceiampassword.Validate(ctx, newUser, req.Password); err != nil {
+		return nil, err
+	}
+	if _, err = serviceiampassword.Set(newUser, req.Password); err != nil {
+		log.Error("failed to hash password", zap.Error(err))
+		return nil, fmt.Errorf("failed to create user")
 	}
 
 	// Set optional fields
@@ -7,9 +7,9 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamemail "github.com/forbearing/gst/internal/model/iam/email"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	"github.com/forbearing/gst/pkg/password"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // ChangeRequestService handles authenticated requests that start the email
@@ -123,11 +123,11 @@ func validateEmailChangeTarget(ctx *types.ServiceContext, user *modeliamuser.Use
 
 // verifyEmailChangePassword re-authenticates the current user before issuing
 // email change tokens.
-func verifyEmailChangePassword(user *modeliamuser.User, password string) error {
+func verifyEmailChangePassword(user *modeliamuser.User, currentPassword string) error {
 	if user == nil {
 		return errors.New("current user is required")
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	if ok, _, _ := password.Verify(user.PasswordHash, currentPassword); !ok {
 		return errors.New("current password is incorrect")
 	}
 	return nil
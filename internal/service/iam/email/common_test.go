@@ -186,6 +186,40 @@ func TestDispatchEmail(t *testing.T) {
 	require.EqualError(t, err, "email recipient is required")
 }
 
+func TestNotifyNewDevice(t *testing.T) {
+	flowCache := newTestCache[iamEmailFlowState]()
+	throttleCache := newTestCache[emailThrottleRecord]()
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	restore := stubEmailGlobals(flowCache, throttleCache, now, bytes.NewReader(bytes.Repeat([]byte{5}, 64)))
+	t.Cleanup(restore)
+
+	sender := new(testEmailSender)
+	setEmailSender(sender)
+
+	err := NotifyNewDevice(context.Background(), NewDeviceLogin{
+		UserID:      "user-1",
+		Username:    "alice",
+		Email:       " Alice@Example.COM ",
+		DeviceID:    "device-1",
+		ClientIP:    "10.0.0.1",
+		Platform:    "Macintosh",
+		OS:          "Mac OS X 10_15_7",
+		BrowserName: "Chrome",
+		LoginAt:     now,
+	})
+	require.NoError(t, err)
+	require.Len(t, sender.deliveries, 1)
+	require.Equal(t, "alice@example.com", sender.last.To)
+	require.Equal(t, "iam/email/new-device", sender.last.Template)
+	require.Equal(t, "device-1", sender.last.Data["device_id"])
+	require.Equal(t, "10.0.0.1", sender.last.Data["client_ip"])
+	require.Equal(t, now, sender.last.Data["login_at"])
+
+	// users without email are skipped
+	require.NoError(t, NotifyNewDevice(context.Background(), NewDeviceLogin{UserID: "user-2"}))
+	require.Len(t, sender.deliveries, 1)
+}
+
 func TestPublicAcceptedMessage(t *testing.T) {
 	require.Equal(t, "If the email is eligible, a verification message will be sent shortly.", publicAcceptedMessage(iamEmailFlowKindVerification))
 	require.Equal(t, "If the email is eligible, a password reset message will be sent shortly.", publicAcceptedMessage(iamEmailFlowKindPasswordReset))
@@ -0,0 +1,49 @@
+package serviceiamemail
+
+import (
+	"context"
+	"time"
+)
+
+// NewDeviceLogin describes a login from a device the user has not logged in from recently.
+type NewDeviceLogin struct {
+	UserID      string
+	Username    string
+	Email       string
+	DeviceID    string
+	ClientIP    string
+	Platform    string
+	OS          string
+	BrowserName string
+	LoginAt     time.Time
+}
+
+// NotifyNewDevice sends the new-device login notification through the IAM email sender.
+// Users without an email are skipped.
+func NotifyNewDevice(ctx context.Context, login NewDeviceLogin) error {
+	if normalizeEmailScope(login.Email) == "" {
+		return nil
+	}
+	return dispatchEmail(ctx, newDeviceDelivery(login))
+}
+
+// newDeviceDelivery builds the delivery payload of the new-device login notification,
+// the template data carries the device so the user can recognize or report the login.
+func newDeviceDelivery(login NewDeviceLogin) emailDelivery {
+	return emailDelivery{
+		To:       login.Email,
+		Subject:  "New device login",
+		Template: "iam/email/new-device",
+		Data: map[string]any{
+			"user_id":      login.UserID,
+			"username":     login.Username,
+			"email":        normalizeEmailScope(login.Email),
+			"device_id":    login.DeviceID,
+			"client_ip":    login.ClientIP,
+			"platform":     login.Platform,
+			"os":           login.OS,
+			"browser_name": login.BrowserName,
+			"login_at":     login.LoginAt,
+		},
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamemail "github.com/forbearing/gst/internal/model/iam/email"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // PasswordResetConfirmService handles the token confirmation step that finalizes
@@ -33,7 +33,7 @@ var (
 	passwordResetUpdateUser = func(ctx *types.ServiceContext, user *modeliamuser.User) error {
 		return database.Database[*modeliamuser.User](ctx.DatabaseContext()).
 			WithoutHook().
-			WithSelect("username", "password_hash", "must_change_password").
+			WithSelect("username", "password_hash", "password_changed_at", "must_change_password").
 			Update(user)
 	}
 	// passwordResetInvalidateSessions clears the cached user-session index so a
@@ -73,7 +73,11 @@ func (s *PasswordResetConfirmService) Create(ctx *types.ServiceContext, req *mod
 		}, nil
 	}
 
-	if err = applyPasswordReset(user, req.NewPassword); err != nil {
+	if err = serviceiampassword.Validate(ctx, user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	previousHash, err := applyPasswordReset(user, req.NewPassword)
+	if err != nil {
 		log.Error("failed to apply password reset", err)
 		return nil, err
 	}
@@ -81,6 +85,9 @@ func (s *PasswordResetConfirmService) Create(ctx *types.ServiceContext, req *mod
 		log.Error("failed to update password reset user", err)
 		return nil, errors.Wrap(err, "failed to update password")
 	}
+	if err = serviceiampassword.Record(ctx, user.ID, previousHash); err != nil {
+		log.Error("failed to record password history", err)
+	}
 
 	passwordResetInvalidateSessions(user.ID)
 	return &modeliamemail.PasswordResetConfirmRsp{
@@ -90,16 +97,15 @@ func (s *PasswordResetConfirmService) Create(ctx *types.ServiceContext, req *mod
 }
 
 // applyPasswordReset hashes the supplied password and updates the in-memory user
-// model before persistence.
-func applyPasswordReset(user *modeliamuser.User, newPassword string) error {
+// model before persistence, it returns the previous password hash.
+func applyPasswordReset(user *modeliamuser.User, newPassword string) (string, error) {
 	if user == nil {
-		return errors.New("password reset user is required")
+		return "", errors.New("password reset user is required")
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	previousHash, err := serviceiampassword.Set(user, newPassword)
 	if err != nil {
-		return errors.Wrap(err, "failed to process new password")
+		return "", errors.Wrap(err, "failed to process new password")
 	}
-	user.PasswordHash = string(hashedPassword)
 	user.MustChangePassword = false
-	return nil
+	return previousHash, nil
 }
@@ -0,0 +1,192 @@
+package serviceiampassword
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	"github.com/forbearing/gst/pkg/password"
+	"github.com/forbearing/gst/types"
+)
+
+// Config is the password policy configuration for iam module.
+type Config struct {
+	password.Policy
+
+	// BlocklistFile is a local breached password list with one password per line,
+	// it is merged into Policy.Blocklist and enables Policy.RejectCommon.
+	BlocklistFile string
+	// HistorySize rejects reusing any of the last HistorySize passwords, 0 disables the check.
+	HistorySize int
+	// MaxAge forces a password change once the password is older than MaxAge, 0 disables expiry.
+	MaxAge time.Duration
+	// Hasher hashes new passwords, default is bcrypt with bcrypt.DefaultCost.
+	Hasher password.Hasher
+	// LegacyHashers verify existing hashes, matching passwords are rehashed with Hasher on login.
+	// bcrypt and argon2id with default parameters are always recognized.
+	LegacyHashers []password.Hasher
+}
+
+var (
+	cfg   Config
+	cfgMu sync.RWMutex
+)
+
+// SetConfig sets the password policy configuration and the default password hasher.
+// This function should be called during module registration.
+func SetConfig(c Config) error {
+	if len(c.BlocklistFile) > 0 {
+		list, err := password.LoadBlocklist(c.BlocklistFile)
+		if err != nil {
+			return err
+		}
+		for s := range c.Blocklist {
+			list[s] = struct{}{}
+		}
+		c.Blocklist = list
+		c.RejectCommon = true
+	}
+	if c.Hasher == nil {
+		c.Hasher = password.NewBcrypt(0)
+	}
+	legacy := append([]password.Hasher{}, c.LegacyHashers...)
+	legacy = append(legacy, password.NewBcrypt(0), password.NewArgon2id())
+	password.SetDefault(password.NewManager(c.Hasher, legacy...))
+	modeliamuser.SetPasswordPolicy(c.Policy)
+
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	cfg = c
+	return nil
+}
+
+func getConfig() Config {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg
+}
+
+// Validate checks the new password of user against the password policy and the password history.
+// The user may be unsaved, then only the policy is checked.
+func Validate(ctx *types.ServiceContext, user *modeliamuser.User, newPassword string) error {
+	c := getConfig()
+	if err := c.Validate(newPassword, user.Username); err != nil {
+		return types.NewServiceErrorWithCause(http.StatusBadRequest, err.Error(), err)
+	}
+	if c.HistorySize <= 0 || len(user.ID) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, c.HistorySize+1)
+	if len(user.PasswordHash) > 0 {
+		hashes = append(hashes, user.PasswordHash)
+	}
+	histories, err := listHistory(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(histories) && i < c.HistorySize; i++ {
+		hashes = append(hashes, histories[i].PasswordHash)
+	}
+	for _, hash := range hashes {
+		if ok, _, _ := password.Verify(hash, newPassword); ok {
+			return types.NewServiceError(http.StatusBadRequest, "password must not match any of the last "+strconv.Itoa(c.HistorySize)+" passwords")
+		}
+	}
+	return nil
+}
+
+// Set hashes newPassword into user.PasswordHash and resets user.PasswordChangedAt.
+// It must be called after Validate and before the user is saved, the previous hash
+// is recorded into the password history by Record after saving.
+func Set(user *modeliamuser.User, newPassword string) (previousHash string, err error) {
+	hash, err := password.Hash(newPassword)
+	if err != nil {
+		return "", err
+	}
+	previousHash = user.PasswordHash
+	now := time.Now()
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+	return previousHash, nil
+}
+
+// Record stores previousHash into the password history of user and prunes entries
+// beyond the configured history size.
+func Record(ctx *types.ServiceContext, userID, previousHash string) error {
+	c := getConfig()
+	if c.HistorySize <= 0 || len(userID) == 0 || len(previousHash) == 0 {
+		return nil
+	}
+	if err := database.Database[*modeliamuser.PasswordHistory](ctx.DatabaseContext()).Create(&modeliamuser.PasswordHistory{
+		UserID:       userID,
+		PasswordHash: previousHash,
+	}); err != nil {
+		return errors.Wrap(err, "failed to record password history")
+	}
+
+	histories, err := listHistory(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(histories) > c.HistorySize {
+		if err = database.Database[*modeliamuser.PasswordHistory](ctx.DatabaseContext()).Delete(histories[c.HistorySize:]...); err != nil {
+			return errors.Wrap(err, "failed to prune password history")
+		}
+	}
+	return nil
+}
+
+// Verify reports whether plain matches the password of user. When the stored hash was produced
+// by a legacy hasher or with outdated parameters it is transparently replaced by a fresh hash.
+func Verify(ctx *types.ServiceContext, user *modeliamuser.User, plain string) (bool, error) {
+	ok, rehash, err := password.Verify(user.PasswordHash, plain)
+	if err != nil || !ok {
+		return false, err
+	}
+	if !rehash {
+		return true, nil
+	}
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return true, nil //nolint:nilerr // keep the old hash, the password is still valid.
+	}
+	user.PasswordHash = hash
+	if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).
+		WithoutHook().
+		WithSelect("username", "password_hash").
+		Update(user); err != nil {
+		return true, errors.Wrap(err, "failed to rehash password")
+	}
+	return true, nil
+}
+
+// Expired reports whether the password of user is older than the configured maximum age.
+// Users created before password ages were tracked count from their creation time.
+func Expired(user *modeliamuser.User) bool {
+	c := getConfig()
+	if c.MaxAge <= 0 {
+		return false
+	}
+	changedAt := user.PasswordChangedAt
+	if changedAt == nil {
+		changedAt = user.CreatedAt
+	}
+	return changedAt != nil && time.Since(*changedAt) > c.MaxAge
+}
+
+// listHistory returns the password history of user, newest first.
+func listHistory(ctx *types.ServiceContext, userID string) ([]*modeliamuser.PasswordHistory, error) {
+	histories := make([]*modeliamuser.PasswordHistory, 0)
+	if err := database.Database[*modeliamuser.PasswordHistory](ctx.DatabaseContext()).
+		WithQuery(&modeliamuser.PasswordHistory{UserID: userID}).
+		WithOrder("created_at desc").
+		List(&histories); err != nil {
+		return nil, errors.Wrap(err, "failed to query password history")
+	}
+	return histories, nil
+}
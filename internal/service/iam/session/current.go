@@ -110,6 +110,15 @@ func buildCurrentSessionView(session modeliamsession.Session, currentSessionID s
 		state = modeliamsession.SessionStatusActive
 	}
 
+	var impersonator *modeliamsession.ImpersonatorView
+	if session.Impersonator != nil {
+		impersonator = &modeliamsession.ImpersonatorView{
+			Username:  session.Impersonator.Username,
+			Reason:    session.Impersonator.Reason,
+			StartedAt: session.Impersonator.StartedAt,
+		}
+	}
+
 	return modeliamsession.SessionView{
 		ID:          sessionID,
 		State:       state,
@@ -122,6 +131,9 @@ func buildCurrentSessionView(session modeliamsession.Session, currentSessionID s
 		OS:          session.OS,
 		EngineName:  session.EngineName,
 		BrowserName: session.BrowserName,
+		DeviceID:    session.DeviceID,
 		IsCurrent:   sessionID == currentSessionID,
+
+		Impersonator: impersonator,
 	}
 }
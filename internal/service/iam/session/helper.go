@@ -187,7 +187,7 @@ func DeleteAllSessions(userID string) error {
 	return redis.Del(modeliamsession.SessionUserKey(userID))
 }
 
-// InvalidateUserSessions removes all indexed sessions for a user.
+// InvalidateUserSessions removes all indexed sessions and trusted devices for a user.
 // It is best-effort: failures to talk to Redis do not block password updates.
 func InvalidateUserSessions(userID string) {
 	if userID == "" {
@@ -202,6 +202,7 @@ func InvalidateUserSessions(userID string) {
 		}
 	}
 	_ = redis.Del(modeliamsession.SessionUserKey(userID))
+	_ = RevokeTrustedDevices(userID)
 }
 
 // GetSessionExpiration returns the configured session expiration time.
@@ -0,0 +1,364 @@
+package serviceiamsession
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/database"
+	modeliamgroup "github.com/forbearing/gst/internal/model/iam/group"
+	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+	"go.uber.org/zap"
+)
+
+const (
+	// ImpersonationResource is the RBAC resource of the impersonation permission,
+	// subjects granted "POST" on it may impersonate other users.
+	ImpersonationResource = "/api/iam/impersonation"
+
+	defaultImpersonationDuration = time.Hour
+	recentImpersonationLimit     = 20
+)
+
+// ImpersonationConfig is the configuration of admin impersonation.
+type ImpersonationConfig struct {
+	Enable      bool          // Enable enables the impersonation routes, default is false
+	MaxDuration time.Duration // MaxDuration caps the impersonation duration, default is 1 hour
+
+	// Authorize decides whether actor may impersonate target, it replaces the default check:
+	// "root", "admin", superusers and subjects granted ImpersonationResource by RBAC are allowed.
+	// The target rules (not self, not nested, administrators and other tenants only by root) always apply.
+	Authorize func(ctx *types.ServiceContext, actor, target *modeliamuser.User) error
+}
+
+var (
+	impersonation   ImpersonationConfig
+	impersonationMu sync.RWMutex
+)
+
+// SetImpersonation sets the impersonation configuration.
+// This function should be called during module registration.
+func SetImpersonation(cfg ImpersonationConfig) {
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = defaultImpersonationDuration
+	}
+	impersonationMu.Lock()
+	defer impersonationMu.Unlock()
+	impersonation = cfg
+}
+
+func getImpersonation() ImpersonationConfig {
+	impersonationMu.RLock()
+	defer impersonationMu.RUnlock()
+	return impersonation
+}
+
+// ImpersonationCreateService starts an impersonation session.
+type ImpersonationCreateService struct {
+	service.Base[*model.Empty, *modeliamsession.ImpersonationCreateReq, *modeliamsession.ImpersonationCreateRsp]
+}
+
+// ImpersonationDeleteService ends the current impersonation session.
+type ImpersonationDeleteService struct {
+	service.Base[*model.Empty, *modeliamsession.ImpersonationDeleteReq, *modeliamsession.ImpersonationDeleteRsp]
+}
+
+// Create starts acting as the target user. A new session of the target user is created
+// and the session cookie is replaced, the administrator's own session is kept and
+// restored when the impersonation ends or expires.
+func (s *ImpersonationCreateService) Create(ctx *types.ServiceContext, req *modeliamsession.ImpersonationCreateReq) (rsp *modeliamsession.ImpersonationCreateRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+	cfg := getImpersonation()
+	if !cfg.Enable {
+		return nil, types.NewServiceError(http.StatusNotFound, "impersonation is not enabled")
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) == 0 {
+		return nil, types.NewServiceError(http.StatusBadRequest, "reason is required")
+	}
+	duration := cfg.MaxDuration
+	if len(req.Duration) > 0 {
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			return nil, types.NewServiceError(http.StatusBadRequest, "invalid duration")
+		}
+		duration = min(duration, cfg.MaxDuration)
+	}
+
+	actorSessionID, actorSession, err := GetCurrentSession(ctx)
+	if err != nil {
+		log.Errorz("failed to get current session", zap.Error(err))
+		return nil, err
+	}
+	if actorSession.Impersonator != nil {
+		return nil, types.NewServiceError(http.StatusForbidden, "already impersonating, end the current impersonation first")
+	}
+	if !actorSession.ExpiresAt.IsZero() {
+		duration = min(duration, time.Until(actorSession.ExpiresAt))
+	}
+	if duration <= 0 {
+		return nil, types.NewServiceError(http.StatusUnauthorized, "session expired")
+	}
+
+	db := database.Database[*modeliamuser.User](ctx.DatabaseContext())
+	actor := new(modeliamuser.User)
+	if err = db.Get(actor, actorSession.UserID); err != nil || actor.GetID() == "" {
+		return nil, types.NewServiceError(http.StatusUnauthorized, "session invalid")
+	}
+	target := new(modeliamuser.User)
+	switch {
+	case len(req.UserID) > 0:
+		err = db.Get(target, req.UserID)
+	case len(req.Username) > 0:
+		users := make([]*modeliamuser.User, 0)
+		if err = db.WithLimit(1).WithQuery(&modeliamuser.User{Username: req.Username}).List(&users); err == nil && len(users) > 0 {
+			target = users[0]
+		}
+	default:
+		return nil, types.NewServiceError(http.StatusBadRequest, "user_id or username is required")
+	}
+	if err != nil {
+		log.Errorz("failed to get target user", zap.Error(err))
+		return nil, err
+	}
+	if target.GetID() == "" {
+		return nil, types.NewServiceError(http.StatusNotFound, "user not found")
+	}
+	if err = authorizeImpersonation(ctx, cfg, actor, target); err != nil {
+		log.Warnz("impersonation denied", zap.String("actor", actor.Username), zap.String("target", target.Username), zap.Error(err))
+		return nil, err
+	}
+
+	group := new(modeliamgroup.Group)
+	_ = database.Database[*modeliamgroup.Group](ctx.DatabaseContext()).Get(group, target.GroupID)
+
+	now := time.Now()
+	sessionID := util.UUID()
+	session := modeliamsession.Session{
+		ID:        sessionID,
+		UserID:    target.ID,
+		Username:  target.Username,
+		Email:     util.Deref(target.Email),
+		Status:    string(target.Status),
+		FirstName: target.FirstName,
+		LastName:  target.LastName,
+		GroupID:   target.GroupID,
+		GroupName: group.Name,
+		TenantID:  util.Deref(target.TenantID),
+		// The administrator can not change the password of the target user,
+		// so the flag would lock the impersonation session out.
+		MustChangePassword: false,
+		ClientIP:           ctx.ClientIP,
+		UserAgent:          actorSession.UserAgent,
+		Platform:           actorSession.Platform,
+		OS:                 actorSession.OS,
+		EngineName:         actorSession.EngineName,
+		BrowserName:        actorSession.BrowserName,
+		State:              modeliamsession.SessionStatusActive,
+		IssuedAt:           now,
+		LastSeenAt:         now,
+		ExpiresAt:          now.Add(duration),
+		Impersonator: &modeliamsession.Impersonator{
+			UserID:    actor.ID,
+			Username:  actor.Username,
+			SessionID: actorSessionID,
+			Reason:    req.Reason,
+			StartedAt: now,
+		},
+	}
+	if err = redis.Cache[modeliamsession.Session]().Set(modeliamsession.SessionIDKey(sessionID), session, duration); err != nil {
+		log.Errorz("failed to set session in redis", zap.Error(err))
+		return nil, err
+	}
+	if err = TrackUserSession(session); err != nil {
+		log.Errorz("failed to track user session in redis", zap.Error(err))
+		return nil, err
+	}
+
+	record := &modeliamsession.Impersonation{
+		ActorID:        actor.ID,
+		ActorUsername:  actor.Username,
+		TargetID:       target.ID,
+		TargetUsername: target.Username,
+		SessionID:      sessionID,
+		Reason:         req.Reason,
+		ClientIP:       ctx.ClientIP,
+		StartedAt:      now,
+		ExpiresAt:      session.ExpiresAt,
+	}
+	if err = database.Database[*modeliamsession.Impersonation](ctx.DatabaseContext()).Create(record); err != nil {
+		// Without the audit record the impersonation must not start.
+		log.Errorz("failed to create impersonation record", zap.Error(err))
+		_, _ = DeleteSession(sessionID)
+		return nil, err
+	}
+	recordImpersonationLog(ctx, log, &session, modellogmgmt.LoginStatusImpersonate)
+
+	setSessionCookie(ctx, sessionID, duration)
+	log.Infoz("impersonation started", zap.String("actor", actor.Username), zap.String("target", target.Username), zap.Duration("duration", duration))
+
+	return &modeliamsession.ImpersonationCreateRsp{
+		SessionID: sessionID,
+		Username:  target.Username,
+		ExpiresAt: session.ExpiresAt,
+		Record:    record,
+	}, nil
+}
+
+// Delete ends the current impersonation and restores the administrator's own session.
+func (s *ImpersonationDeleteService) Delete(ctx *types.ServiceContext, req *modeliamsession.ImpersonationDeleteReq) (rsp *modeliamsession.ImpersonationDeleteRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	sessionID, session, err := GetCurrentSession(ctx)
+	if err != nil {
+		log.Errorz("failed to get current session", zap.Error(err))
+		return nil, err
+	}
+	if session.Impersonator == nil {
+		return nil, types.NewServiceError(http.StatusBadRequest, "not impersonating")
+	}
+	if _, err = DeleteSession(sessionID); err != nil {
+		log.Errorz("failed to delete impersonation session", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]*modeliamsession.Impersonation, 0)
+	if err = database.Database[*modeliamsession.Impersonation](ctx.DatabaseContext()).
+		WithQuery(&modeliamsession.Impersonation{SessionID: sessionID}).
+		List(&records); err != nil {
+		log.Warnz("failed to list impersonation records", zap.Error(err))
+	}
+	now := time.Now()
+	for _, record := range records {
+		record.EndedAt = &now
+		if err = database.Database[*modeliamsession.Impersonation](ctx.DatabaseContext()).Update(record); err != nil {
+			log.Warnz("failed to update impersonation record", zap.Error(err))
+		}
+	}
+	recordImpersonationLog(ctx, log, &session, modellogmgmt.LoginStatusImpersonateEnd)
+	log.Infoz("impersonation ended", zap.String("actor", session.Impersonator.Username), zap.String("target", session.Username))
+
+	// Restore the administrator's session if it is still alive, otherwise the administrator logs in again.
+	actorSession, err := redis.Cache[modeliamsession.Session]().Get(modeliamsession.SessionIDKey(session.Impersonator.SessionID))
+	if err != nil || actorSession.UserID != session.Impersonator.UserID || time.Until(actorSession.ExpiresAt) <= 0 {
+		ctx.SetCookie("session_id", "", -1, "/", "", false, true)
+		return &modeliamsession.ImpersonationDeleteRsp{}, nil
+	}
+	setSessionCookie(ctx, actorSession.ID, time.Until(actorSession.ExpiresAt))
+
+	return &modeliamsession.ImpersonationDeleteRsp{SessionID: actorSession.ID}, nil
+}
+
+// authorizeImpersonation checks the impersonation permission of actor and the target rules.
+func authorizeImpersonation(ctx *types.ServiceContext, cfg ImpersonationConfig, actor, target *modeliamuser.User) error {
+	if actor.ID == target.ID {
+		return types.NewServiceError(http.StatusBadRequest, "can not impersonate yourself")
+	}
+	if target.Status != modeliamuser.UserStatusActive {
+		return types.NewServiceError(http.StatusBadRequest, "user is not active")
+	}
+	// Acting as another administrator would hand over its privileges, only root may do it.
+	if isAdministrator(target) && actor.Username != consts.AUTHZ_USER_ROOT {
+		return types.NewServiceError(http.StatusForbidden, "only root can impersonate administrators")
+	}
+	// Tenants are isolated, only root may act across them.
+	if util.Deref(target.TenantID) != util.Deref(actor.TenantID) && actor.Username != consts.AUTHZ_USER_ROOT {
+		return types.NewServiceError(http.StatusForbidden, "can not impersonate users of another tenant")
+	}
+
+	if cfg.Authorize != nil {
+		return cfg.Authorize(ctx, actor, target)
+	}
+	if isAdministrator(actor) {
+		return nil
+	}
+	var allow bool
+	var err error
+	rctx := rbac.NewContext(ctx.Context(), time.Now(), ctx.ClientIP, ctx.Header)
+	switch {
+	case rbac.TenantEnforcer != nil:
+		// The permission must be granted in the tenant of the target, not the tenant of the request.
+		tenant := util.Deref(target.TenantID)
+		if len(tenant) == 0 {
+			tenant = consts.AUTHZ_TENANT_DEFAULT
+		}
+		allow, err = rbac.TenantEnforcer.Enforce(tenant, actor.ID, ImpersonationResource, http.MethodPost, rctx)
+	case rbac.Enforcer != nil:
+		allow, err = rbac.Enforcer.Enforce(actor.ID, ImpersonationResource, http.MethodPost, rctx)
+	}
+	if err != nil {
+		return err
+	}
+	if !allow {
+		return types.NewServiceError(http.StatusForbidden, "forbidden")
+	}
+	return nil
+}
+
+// isAdministrator reports whether the user is "root", "admin" or a superuser.
+func isAdministrator(user *modeliamuser.User) bool {
+	if user.Username == consts.AUTHZ_USER_ROOT || user.Username == consts.AUTHZ_USER_ADMIN {
+		return true
+	}
+	return user.IsSuperuser != nil && *user.IsSuperuser
+}
+
+// recordImpersonationLog writes the login log of the impersonated user, it is best-effort.
+func recordImpersonationLog(ctx *types.ServiceContext, log types.Logger, session *modeliamsession.Session, status modellogmgmt.LoginStatus) {
+	if !servicelogmgmt.Enabled {
+		return
+	}
+	if err := auditmanager.RecordLogin(ctx.DatabaseContext(), &modellogmgmt.LoginLog{
+		UserID:   session.UserID,
+		Username: session.Username,
+		ClientIP: ctx.ClientIP,
+		Status:   status,
+		Reason:   fmt.Sprintf("impersonated by %s: %s", session.Impersonator.Username, session.Impersonator.Reason),
+		Source:   ctx.Request.UserAgent(),
+		Platform: fmt.Sprintf("%s %s", session.Platform, session.OS),
+		Engine:   session.EngineName,
+		Browser:  session.BrowserName,
+	}); err != nil {
+		log.Warnz("failed to write login log", zap.Error(err))
+	}
+}
+
+// listRecentImpersonations returns the recent impersonation records targeting the user.
+func listRecentImpersonations(ctx *types.ServiceContext, userID string) ([]*modeliamsession.Impersonation, error) {
+	if !getImpersonation().Enable {
+		return nil, nil
+	}
+	records := make([]*modeliamsession.Impersonation, 0)
+	err := database.Database[*modeliamsession.Impersonation](ctx.DatabaseContext()).
+		WithQuery(&modeliamsession.Impersonation{TargetID: userID}).
+		WithOrder("created_at desc").
+		WithLimit(recentImpersonationLimit).
+		List(&records)
+	return records, err
+}
+
+func setSessionCookie(ctx *types.ServiceContext, sessionID string, expire time.Duration) {
+	//nolint:gosec // Secure is intentionally false so local HTTP development keeps session cookies.
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(expire.Seconds()),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
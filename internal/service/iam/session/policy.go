@@ -0,0 +1,267 @@
+package serviceiamsession
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/util"
+)
+
+// SessionLimitStrategy decides what happens when a user reaches the maximum concurrent sessions.
+type SessionLimitStrategy string
+
+const (
+	// SessionLimitEvictOldest revokes the oldest sessions to make room for the new one.
+	SessionLimitEvictOldest SessionLimitStrategy = "evict_oldest"
+	// SessionLimitReject rejects the new login.
+	SessionLimitReject SessionLimitStrategy = "reject"
+)
+
+const defaultKnownDeviceTTL = 90 * 24 * time.Hour
+
+const (
+	// sessionLimitLockTTL bounds how long a crashed login holds the session limit lock of the user.
+	sessionLimitLockTTL = 10 * time.Second
+	// sessionLimitLockWait is how long a login waits for the concurrent logins of the same user.
+	sessionLimitLockWait  = 5 * time.Second
+	sessionLimitLockRetry = 20 * time.Millisecond
+)
+
+// ErrSessionLimitExceeded is returned by EnforceSessionLimit when the user reached
+// the maximum concurrent sessions and the strategy is SessionLimitReject.
+var ErrSessionLimitExceeded = errors.New("maximum concurrent sessions reached")
+
+// SessionPolicyConfig is the configuration of concurrent sessions and trusted devices.
+type SessionPolicyConfig struct {
+	MaxSessions      int                  // MaxSessions is the maximum concurrent sessions per user, 0 means unlimited
+	GroupMaxSessions map[string]int       // GroupMaxSessions overrides MaxSessions for the users of the group, keyed by group id, 0 means unlimited
+	UserMaxSessions  map[string]int       // UserMaxSessions overrides MaxSessions and GroupMaxSessions for the user, keyed by user id, 0 means unlimited
+	Strategy         SessionLimitStrategy // Strategy is applied when the limit is reached, default is SessionLimitEvictOldest
+
+	// TrustedDeviceTTL is how long a device remembered by "remember_device" skips the second factor,
+	// 0 disables trusted devices.
+	TrustedDeviceTTL time.Duration
+	// KnownDeviceTTL is how long a device is remembered after the last login from it, default is 90 days.
+	// A login from a device not seen within KnownDeviceTTL is a new-device login.
+	KnownDeviceTTL time.Duration
+	// NotifyNewDevice sends an email to the user on a new-device login.
+	NotifyNewDevice bool
+}
+
+var (
+	sessionPolicy   SessionPolicyConfig
+	sessionPolicyMu sync.RWMutex
+)
+
+// SetSessionPolicy sets the concurrent session and trusted device configuration.
+// This function should be called during module registration.
+func SetSessionPolicy(cfg SessionPolicyConfig) {
+	if cfg.Strategy != SessionLimitReject {
+		cfg.Strategy = SessionLimitEvictOldest
+	}
+	if cfg.TrustedDeviceTTL < 0 {
+		cfg.TrustedDeviceTTL = 0
+	}
+	if cfg.KnownDeviceTTL <= 0 {
+		cfg.KnownDeviceTTL = defaultKnownDeviceTTL
+	}
+	sessionPolicyMu.Lock()
+	defer sessionPolicyMu.Unlock()
+	sessionPolicy = cfg
+}
+
+// GetSessionPolicy returns the concurrent session and trusted device configuration.
+func GetSessionPolicy() SessionPolicyConfig {
+	sessionPolicyMu.RLock()
+	defer sessionPolicyMu.RUnlock()
+	cfg := sessionPolicy
+	if len(cfg.Strategy) == 0 {
+		cfg.Strategy = SessionLimitEvictOldest
+	}
+	if cfg.KnownDeviceTTL <= 0 {
+		cfg.KnownDeviceTTL = defaultKnownDeviceTTL
+	}
+	return cfg
+}
+
+// maxSessions returns the maximum concurrent sessions of the user, 0 means unlimited.
+func (cfg SessionPolicyConfig) maxSessions(userID, groupID string) int {
+	if n, ok := cfg.UserMaxSessions[userID]; ok {
+		return n
+	}
+	if n, ok := cfg.GroupMaxSessions[groupID]; ok && len(groupID) > 0 {
+		return n
+	}
+	return cfg.MaxSessions
+}
+
+// EnforceSessionLimit makes room for a new session of the user.
+// The user's sessions are counted from the indexed session set, stale index entries are removed.
+// Once the limit is reached, the oldest sessions are revoked or ErrSessionLimitExceeded is returned
+// according to the configured strategy.
+//
+// Concurrent logins of the user would all see room for one more session, so the count and the
+// creation of the new session are serialized per user across instances: on success the session
+// limit lock of the user is held until release is called, the caller must call release once the
+// new session is indexed. release is never nil.
+func EnforceSessionLimit(userID, groupID string) (release func(), err error) {
+	release = func() {}
+	cfg := GetSessionPolicy()
+	limit := cfg.maxSessions(userID, groupID)
+	if limit <= 0 || userID == "" {
+		return release, nil
+	}
+
+	unlock, err := lockSessionLimit(userID)
+	if err != nil {
+		return release, err
+	}
+	defer func() {
+		if err != nil {
+			unlock()
+		}
+	}()
+
+	// The indexed session set is ordered by issued time, oldest first.
+	sessionIDs, err := listUserSessionIDs(userID)
+	if err != nil {
+		return release, err
+	}
+	active := make([]string, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		if _, err = redis.Cache[modeliamsession.Session]().Get(modeliamsession.SessionIDKey(sessionID)); err != nil {
+			if errors.Is(err, types.ErrEntryNotFound) {
+				_ = redis.ZRem(modeliamsession.SessionUserKey(userID), sessionID)
+				_ = redis.ZRem(modeliamsession.SessionAllKey(), sessionID)
+				continue
+			}
+			return release, err
+		}
+		active = append(active, sessionID)
+	}
+	if len(active) < limit {
+		return unlock, nil
+	}
+	if cfg.Strategy == SessionLimitReject {
+		return release, ErrSessionLimitExceeded
+	}
+
+	for _, sessionID := range active[:len(active)-limit+1] {
+		if _, err = DeleteSession(sessionID); err != nil && !errors.Is(err, types.ErrEntryNotFound) {
+			return release, err
+		}
+	}
+	return unlock, nil
+}
+
+// lockSessionLimit acquires the session limit lock of the user, waiting for at most sessionLimitLockWait.
+// The lock expires after sessionLimitLockTTL, unlock only releases the lock if it is still owned.
+func lockSessionLimit(userID string) (unlock func(), err error) {
+	key := modeliamsession.SessionLimitLockKey(userID)
+	token := util.UUID()
+	deadline := time.Now().Add(sessionLimitLockWait)
+	for {
+		ok, err := redis.SetNX(key, token, sessionLimitLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() { _, _ = redis.DelIfEqual(key, token) }, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Newf("timed out waiting for the session limit lock of user %s", userID)
+		}
+		time.Sleep(sessionLimitLockRetry)
+	}
+}
+
+// DeviceFingerprint returns the id of the user's device derived from the parsed user agent fields.
+func DeviceFingerprint(userID, platform, os, browserName string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{userID, platform, os, browserName}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RecordDevice records a login of the user from the device and reports whether
+// the device has not been seen within the configured KnownDeviceTTL.
+func RecordDevice(device modeliamsession.Device) (isNew bool, err error) {
+	if device.UserID == "" || device.ID == "" {
+		return false, nil
+	}
+	key := modeliamsession.SessionDeviceKey(device.UserID, device.ID)
+	known, err := redis.Cache[modeliamsession.Device]().Get(key)
+	switch {
+	case err == nil:
+		device.FirstSeenAt = known.FirstSeenAt
+	case errors.Is(err, types.ErrEntryNotFound):
+		isNew = true
+		device.FirstSeenAt = device.LastSeenAt
+	default:
+		return false, err
+	}
+	return isNew, redis.Cache[modeliamsession.Device]().Set(key, device, GetSessionPolicy().KnownDeviceTTL)
+}
+
+// TrustDevice remembers the device of the user and returns the token identifying it,
+// the token must be presented by the following logins to skip the second factor.
+func TrustDevice(userID, deviceID, clientIP string) (string, modeliamsession.TrustedDevice, error) {
+	ttl := GetSessionPolicy().TrustedDeviceTTL
+	if ttl <= 0 {
+		return "", modeliamsession.TrustedDevice{}, errors.New("trusted devices are disabled")
+	}
+	token, err := newSessionToken()
+	if err != nil {
+		return "", modeliamsession.TrustedDevice{}, err
+	}
+	now := time.Now()
+	device := modeliamsession.TrustedDevice{
+		UserID:    userID,
+		DeviceID:  deviceID,
+		ClientIP:  clientIP,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err = redis.Cache[modeliamsession.TrustedDevice]().Set(modeliamsession.SessionTrustedDeviceKey(userID, token), device, ttl); err != nil {
+		return "", modeliamsession.TrustedDevice{}, err
+	}
+	return token, device, nil
+}
+
+// IsTrustedDevice reports whether the token identifies a trusted device of the user
+// and the device is the one the token was issued to.
+func IsTrustedDevice(userID, deviceID, token string) bool {
+	if GetSessionPolicy().TrustedDeviceTTL <= 0 || userID == "" || token == "" {
+		return false
+	}
+	device, err := redis.Cache[modeliamsession.TrustedDevice]().Get(modeliamsession.SessionTrustedDeviceKey(userID, token))
+	if err != nil {
+		return false
+	}
+	return device.UserID == userID && device.DeviceID == deviceID && time.Now().Before(device.ExpiresAt)
+}
+
+// RevokeTrustedDevices forgets all trusted devices of the user,
+// the following logins require the second factor again.
+func RevokeTrustedDevices(userID string) error {
+	if userID == "" {
+		return nil
+	}
+	return redis.RemovePrefix(modeliamsession.SessionTrustedDevicePrefix(userID))
+}
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -80,9 +80,16 @@ func (s *SessionsListService) List(ctx *types.ServiceContext, req *modeliamsessi
 		return left.After(right)
 	})
 
+	impersonations, err := listRecentImpersonations(ctx, currentSession.UserID)
+	if err != nil {
+		log.Error("failed to list impersonations", err)
+		return nil, err
+	}
+
 	return &modeliamsession.SessionsListRsp{
-		Items: items,
-		Total: int64(len(items)),
+		Items:          items,
+		Total:          int64(len(items)),
+		Impersonations: impersonations,
 	}, nil
 }
 
@@ -0,0 +1,37 @@
+package servicejobs
+
+import (
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cronjob"
+	modeljobs "github.com/forbearing/gst/internal/model/jobs"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+)
+
+// DeadLetterReplayService runs the cronjob of the dead letter again on the instance serving the request.
+type DeadLetterReplayService struct {
+	service.Base[*modeljobs.DeadLetterReplay, *modeljobs.DeadLetterReplay, *modeljobs.DeadLetterReplayRsp]
+}
+
+func (s *DeadLetterReplayService) Create(ctx *types.ServiceContext, req *modeljobs.DeadLetterReplay) (rsp *modeljobs.DeadLetterReplayRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	id := ctx.Params["id"]
+	if len(id) == 0 {
+		return nil, types.NewServiceError(http.StatusBadRequest, "dead letter id is required")
+	}
+	dl, err := cronjob.Replay(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, cronjob.ErrDeadLetterNotFound):
+			return nil, types.NewServiceError(http.StatusNotFound, "dead letter not found")
+		case errors.Is(err, cronjob.ErrJobNotFound):
+			return nil, types.NewServiceError(http.StatusNotFound, "job not found")
+		}
+		log.Error(err)
+		return nil, err
+	}
+	return &modeljobs.DeadLetterReplayRsp{ID: dl.ID, Job: dl.Job}, nil
+}
@@ -0,0 +1,172 @@
+package servicejobs
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/database"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	modeljobs "github.com/forbearing/gst/internal/model/jobs"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+// heartbeatInterval is the interval of the comments keeping the event stream alive through proxies.
+const heartbeatInterval = 30 * time.Second
+
+// JobListService lists the registered cronjobs.
+type JobListService struct {
+	service.Base[*modeljobs.Job, *modeljobs.Job, *modeljobs.JobListRsp]
+}
+
+func (s *JobListService) List(ctx *types.ServiceContext, req *modeljobs.Job) (rsp *modeljobs.JobListRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	jobs := cronjob.Jobs()
+	rsp = &modeljobs.JobListRsp{Items: make([]*modeljobs.JobInfo, 0, len(jobs)), Total: int64(len(jobs))}
+	for _, job := range jobs {
+		info := &modeljobs.JobInfo{
+			Name:       job.Name,
+			Spec:       job.Spec,
+			Singleton:  job.Singleton,
+			Overlap:    string(job.Overlap),
+			Paused:     job.Paused,
+			Running:    job.Running,
+			Failures:   job.Failures,
+			AlertAfter: job.AlertAfter,
+			NextRun:    timePtr(job.Next),
+			PrevRun:    timePtr(job.Prev),
+		}
+		if job.Timeout > 0 {
+			info.Timeout = job.Timeout.String()
+		}
+		if job.Retry.MaxAttempts > 1 {
+			info.MaxAttempts = job.Retry.MaxAttempts
+		}
+
+		last := job.LastRun
+		if cronjob.HistoryEnabled() {
+			// The latest run may be executed by another instance.
+			runs := make([]*modelcronjob.Run, 0, 1)
+			if err = database.Database[*modelcronjob.Run](ctx.DatabaseContext()).
+				WithQuery(&modelcronjob.Run{Job: job.Name}).
+				WithOrder("started_at desc").
+				WithLimit(1).
+				List(&runs); err != nil {
+				log.Warnz("failed to get the last cronjob run", zap.String("name", job.Name), zap.Error(err))
+			} else if len(runs) > 0 && (last == nil || runs[0].StartedAt.After(last.StartedAt)) {
+				last = runs[0]
+			}
+		}
+		if last != nil {
+			info.LastStatus = last.Status
+			info.LastTrigger = last.Trigger
+			info.LastError = last.Error
+			info.LastNode = last.Node
+			info.LastRunAt = timePtr(last.StartedAt)
+			info.LastDuration = last.Duration
+		}
+		rsp.Items = append(rsp.Items, info)
+	}
+	return rsp, nil
+}
+
+// JobTriggerService runs the cronjob now on the instance serving the request.
+type JobTriggerService struct {
+	service.Base[*modeljobs.JobTrigger, *modeljobs.JobTrigger, *modeljobs.JobActionRsp]
+}
+
+func (s *JobTriggerService) Create(ctx *types.ServiceContext, req *modeljobs.JobTrigger) (rsp *modeljobs.JobActionRsp, err error) {
+	return doAction(ctx, s.WithServiceContext(ctx, ctx.GetPhase()), cronjob.Trigger)
+}
+
+// JobPauseService removes the cronjob from the scheduler of all instances.
+type JobPauseService struct {
+	service.Base[*modeljobs.JobPause, *modeljobs.JobPause, *modeljobs.JobActionRsp]
+}
+
+func (s *JobPauseService) Create(ctx *types.ServiceContext, req *modeljobs.JobPause) (rsp *modeljobs.JobActionRsp, err error) {
+	return doAction(ctx, s.WithServiceContext(ctx, ctx.GetPhase()), cronjob.Pause)
+}
+
+// JobResumeService adds the paused cronjob back to the scheduler of all instances.
+type JobResumeService struct {
+	service.Base[*modeljobs.JobResume, *modeljobs.JobResume, *modeljobs.JobActionRsp]
+}
+
+func (s *JobResumeService) Create(ctx *types.ServiceContext, req *modeljobs.JobResume) (rsp *modeljobs.JobActionRsp, err error) {
+	return doAction(ctx, s.WithServiceContext(ctx, ctx.GetPhase()), cronjob.Resume)
+}
+
+func doAction(ctx *types.ServiceContext, log types.Logger, action func(name string) error) (*modeljobs.JobActionRsp, error) {
+	name := ctx.Params["name"]
+	if len(name) == 0 {
+		return nil, types.NewServiceError(http.StatusBadRequest, "job name is required")
+	}
+	if err := action(name); err != nil {
+		switch {
+		case errors.Is(err, cronjob.ErrJobNotFound):
+			return nil, types.NewServiceError(http.StatusNotFound, "job not found")
+		case errors.Is(err, cronjob.ErrJobPaused):
+			return nil, types.NewServiceError(http.StatusConflict, "job is already paused")
+		case errors.Is(err, cronjob.ErrJobNotPaused):
+			return nil, types.NewServiceError(http.StatusConflict, "job is not paused")
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	rsp := &modeljobs.JobActionRsp{Name: name}
+	for _, job := range cronjob.Jobs() {
+		if job.Name == name {
+			rsp.Paused = job.Paused
+			break
+		}
+	}
+	return rsp, nil
+}
+
+// JobEventService streams the run events of the cronjobs on the instance serving the request,
+// the event type is "started" or "finished" and the data is the run.
+type JobEventService struct {
+	service.Base[*modeljobs.JobEvent, *modeljobs.JobEvent, *modeljobs.JobEvent]
+}
+
+func (s *JobEventService) List(ctx *types.ServiceContext, req *modeljobs.JobEvent) (rsp *modeljobs.JobEvent, err error) {
+	events, cancel := cronjob.Subscribe()
+	defer cancel()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	// The first comment flushes the headers, the client is subscribed once it receives them.
+	connected := false
+	ctx.SSE().Stream(func(w io.Writer) bool {
+		if !connected {
+			connected = true
+			_, err := io.WriteString(w, ": connected\n\n")
+			return err == nil
+		}
+		select {
+		case <-ctx.Context().Done():
+			return false
+		case <-ticker.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case ev := <-events:
+			return ctx.Encode(w, types.Event{Event: string(ev.Type), Data: ev.Run}) == nil
+		}
+	})
+	return nil, nil
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
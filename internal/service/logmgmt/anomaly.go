@@ -0,0 +1,212 @@
+package servicelogmgmt
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/pkg/geoip"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+// Scores added by every risk factor, the risk score is capped at 100.
+const (
+	newCountryScore       = 40
+	impossibleTravelScore = 60
+	newASNScore           = 15
+	failedAttemptsScore   = 20
+
+	// minTravelDistance ignores the travels shorter than the accuracy of geolocation.
+	minTravelDistance = 500.0 // km
+	// minFailedAttempts is the failed attempts within failedAttemptsWindow to add RiskFactorFailedAttempts.
+	minFailedAttempts    = 3
+	failedAttemptsWindow = time.Hour
+)
+
+// AnomalyConfig is the configuration of login anomaly detection.
+//
+// Every login is enriched with the GeoIP location of the client ip and scored
+// against the recent successful logins of the account:
+//   - new_country: the country differs from all recent logins, +40
+//   - impossible_travel: the distance to the previous login can't be traveled at MaxTravelSpeed, +60
+//   - new_asn: the network differs from all recent logins, +15
+//   - failed_attempts: 3 or more failed attempts of the account within the last hour, +20
+type AnomalyConfig struct {
+	Enable bool // Enable enables login anomaly detection, default is false
+
+	GeoIPCityDB string // GeoIPCityDB is the path of a MaxMind-format city or country database, eg: GeoLite2-City.mmdb
+	GeoIPASNDB  string // GeoIPASNDB is the path of a MaxMind-format ASN database, eg: GeoLite2-ASN.mmdb
+
+	HistorySize    int     // HistorySize is the recent successful logins compared with, default is 50
+	MaxTravelSpeed float64 // MaxTravelSpeed in km/h, faster travels are impossible, default is 1000
+
+	// Require2FAScore is the risk score from which the second factor is required,
+	// even on trusted devices, 0 defaults to 50.
+	Require2FAScore int
+	// BlockWithout2FA rejects the logins requiring the second factor of users without 2FA,
+	// they are only recorded by default.
+	BlockWithout2FA bool
+}
+
+// LoginRisk is the result of login anomaly detection.
+type LoginRisk struct {
+	Location *geoip.Location
+	Score    int
+	Factors  []string
+
+	// Require2FA is true once the score reaches AnomalyConfig.Require2FAScore.
+	Require2FA bool
+
+	block bool
+}
+
+// Blocked reports whether the login must be rejected because it requires the second factor
+// but the user has no 2FA and AnomalyConfig.BlockWithout2FA is enabled.
+func (r *LoginRisk) Blocked(has2FA bool) bool {
+	return r != nil && r.Require2FA && r.block && !has2FA
+}
+
+// Apply records the location and risk in the login log.
+func (r *LoginRisk) Apply(l *modellogmgmt.LoginLog) {
+	if r == nil || l == nil {
+		return
+	}
+	if r.Location != nil {
+		l.Country = r.Location.Country
+		l.City = r.Location.City
+		l.Latitude = r.Location.Latitude
+		l.Longitude = r.Location.Longitude
+		l.ASN = r.Location.ASN
+		l.ASOrg = r.Location.ASOrg
+	}
+	l.RiskScore = r.Score
+	l.RiskFactors = r.Factors
+}
+
+var (
+	anomaly      AnomalyConfig
+	anomalyGeoIP *geoip.Reader
+	anomalyMu    sync.RWMutex
+)
+
+// SetAnomaly sets the login anomaly detection configuration and opens the GeoIP databases.
+// This function should be called during module registration.
+func SetAnomaly(cfg AnomalyConfig) error {
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 50
+	}
+	if cfg.MaxTravelSpeed <= 0 {
+		cfg.MaxTravelSpeed = 1000
+	}
+	if cfg.Require2FAScore <= 0 {
+		cfg.Require2FAScore = 50
+	}
+	var reader *geoip.Reader
+	if cfg.Enable {
+		var err error
+		if reader, err = geoip.Open(cfg.GeoIPCityDB, cfg.GeoIPASNDB); err != nil {
+			return err
+		}
+	}
+
+	anomalyMu.Lock()
+	defer anomalyMu.Unlock()
+	_ = anomalyGeoIP.Close()
+	anomaly, anomalyGeoIP = cfg, reader
+	return nil
+}
+
+func getAnomaly() (AnomalyConfig, *geoip.Reader) {
+	anomalyMu.RLock()
+	defer anomalyMu.RUnlock()
+	return anomaly, anomalyGeoIP
+}
+
+// AssessLogin locates the client ip and scores the login attempt of the username against
+// its recent successful logins. It returns nil if anomaly detection or logmgmt is disabled.
+// Failures are logged and result in a partial assessment, they never fail the login.
+func AssessLogin(ctx *types.DatabaseContext, username, clientIP string) *LoginRisk {
+	cfg, reader := getAnomaly()
+	if !Enabled || !cfg.Enable {
+		return nil
+	}
+
+	now := time.Now()
+	loc, err := reader.Lookup(clientIP)
+	if err != nil {
+		zap.S().Warnw("failed to lookup client ip", "client_ip", clientIP, "error", err)
+		loc = new(geoip.Location)
+	}
+
+	history := make([]*modellogmgmt.LoginLog, 0)
+	if len(username) > 0 {
+		if err = database.Database[*modellogmgmt.LoginLog](ctx).
+			WithQuery(&modellogmgmt.LoginLog{Username: username, Status: modellogmgmt.LoginStatusSuccess}).
+			WithSelect("country", "latitude", "longitude", "asn", "created_at").
+			WithOrder("created_at DESC").
+			WithLimit(cfg.HistorySize).
+			List(&history); err != nil {
+			zap.S().Warnw("failed to list login history", "username", username, "error", err)
+		}
+	}
+
+	var failures int64
+	if len(username) > 0 {
+		if err = database.Database[*modellogmgmt.LoginLog](ctx).
+			WithQuery(&modellogmgmt.LoginLog{Username: username, Status: modellogmgmt.LoginStatusFailure}).
+			WithTimeRange("created_at", now.Add(-failedAttemptsWindow), now).
+			Count(&failures); err != nil {
+			zap.S().Warnw("failed to count login failures", "username", username, "error", err)
+		}
+	}
+
+	return assessRisk(cfg, loc, history, failures, now)
+}
+
+// assessRisk scores the login from loc at now against the recent successful logins, newest first.
+func assessRisk(cfg AnomalyConfig, loc *geoip.Location, history []*modellogmgmt.LoginLog, failures int64, now time.Time) *LoginRisk {
+	risk := &LoginRisk{Location: loc, Factors: make([]string, 0), block: cfg.BlockWithout2FA}
+	add := func(factor string, score int) {
+		risk.Factors = append(risk.Factors, factor)
+		risk.Score += score
+	}
+
+	countries := make([]string, 0)
+	asns := make([]uint, 0)
+	var previous *modellogmgmt.LoginLog
+	for _, l := range history {
+		if len(l.Country) > 0 {
+			countries = append(countries, l.Country)
+		}
+		if l.ASN > 0 {
+			asns = append(asns, l.ASN)
+		}
+		if previous == nil && (l.Latitude != 0 || l.Longitude != 0) {
+			previous = l
+		}
+	}
+
+	if len(loc.Country) > 0 && len(countries) > 0 && !slices.Contains(countries, loc.Country) {
+		add(modellogmgmt.RiskFactorNewCountry, newCountryScore)
+	}
+	if previous != nil && loc.HasCoordinates() {
+		distance := geoip.Distance(loc, &geoip.Location{Latitude: previous.Latitude, Longitude: previous.Longitude})
+		hours := now.Sub(previous.GetCreatedAt()).Hours()
+		if distance > minTravelDistance && (hours <= 0 || distance/hours > cfg.MaxTravelSpeed) {
+			add(modellogmgmt.RiskFactorImpossibleTravel, impossibleTravelScore)
+		}
+	}
+	if loc.ASN > 0 && len(asns) > 0 && !slices.Contains(asns, loc.ASN) {
+		add(modellogmgmt.RiskFactorNewASN, newASNScore)
+	}
+	if failures >= minFailedAttempts {
+		add(modellogmgmt.RiskFactorFailedAttempts, failedAttemptsScore)
+	}
+
+	risk.Score = min(risk.Score, 100)
+	risk.Require2FA = risk.Score >= cfg.Require2FAScore
+	return risk
+}
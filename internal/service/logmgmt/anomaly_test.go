@@ -0,0 +1,122 @@
+package servicelogmgmt
+
+import (
+	"testing"
+	"time"
+
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/pkg/geoip"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssessRisk(t *testing.T) {
+	cfg := AnomalyConfig{MaxTravelSpeed: 1000, Require2FAScore: 50}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	history := func(ago time.Duration, country string, lat, lon float64, asn uint) *modellogmgmt.LoginLog {
+		createdAt := now.Add(-ago)
+		return &modellogmgmt.LoginLog{Country: country, Latitude: lat, Longitude: lon, ASN: asn, Base: model.Base{CreatedAt: &createdAt}}
+	}
+	london := &geoip.Location{Country: "GB", Latitude: 51.5074, Longitude: -0.1278, ASN: 100}
+	newYork := &geoip.Location{Country: "US", Latitude: 40.7128, Longitude: -74.0060, ASN: 200}
+
+	t.Run("no_history", func(t *testing.T) {
+		risk := assessRisk(cfg, newYork, nil, 0, now)
+		require.Zero(t, risk.Score)
+		require.Empty(t, risk.Factors)
+		require.False(t, risk.Require2FA)
+	})
+
+	t.Run("known_location", func(t *testing.T) {
+		risk := assessRisk(cfg, london, []*modellogmgmt.LoginLog{history(time.Hour, "GB", 51.5, -0.12, 100)}, 0, now)
+		require.Zero(t, risk.Score)
+	})
+
+	t.Run("impossible_travel", func(t *testing.T) {
+		risk := assessRisk(cfg, newYork, []*modellogmgmt.LoginLog{history(time.Hour, "GB", 51.5074, -0.1278, 100)}, 0, now)
+		require.Equal(t, []string{modellogmgmt.RiskFactorNewCountry, modellogmgmt.RiskFactorImpossibleTravel, modellogmgmt.RiskFactorNewASN}, risk.Factors)
+		require.Equal(t, 100, risk.Score)
+		require.True(t, risk.Require2FA)
+		require.False(t, risk.Blocked(false))
+	})
+
+	t.Run("possible_travel", func(t *testing.T) {
+		risk := assessRisk(cfg, newYork, []*modellogmgmt.LoginLog{history(24*time.Hour, "GB", 51.5074, -0.1278, 200)}, 0, now)
+		require.Equal(t, []string{modellogmgmt.RiskFactorNewCountry}, risk.Factors)
+		require.Equal(t, 40, risk.Score)
+		require.False(t, risk.Require2FA)
+	})
+
+	t.Run("failed_attempts", func(t *testing.T) {
+		risk := assessRisk(cfg, london, nil, 3, now)
+		require.Equal(t, []string{modellogmgmt.RiskFactorFailedAttempts}, risk.Factors)
+		require.Equal(t, 20, risk.Score)
+	})
+
+	t.Run("blocked_without_2fa", func(t *testing.T) {
+		blockCfg := cfg
+		blockCfg.BlockWithout2FA = true
+		risk := assessRisk(blockCfg, newYork, []*modellogmgmt.LoginLog{history(time.Hour, "GB", 51.5074, -0.1278, 100)}, 0, now)
+		require.True(t, risk.Blocked(false))
+		require.False(t, risk.Blocked(true))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		risk := assessRisk(cfg, newYork, []*modellogmgmt.LoginLog{history(time.Hour, "GB", 51.5074, -0.1278, 100)}, 0, now)
+		l := new(modellogmgmt.LoginLog)
+		risk.Apply(l)
+		require.Equal(t, "US", l.Country)
+		require.Equal(t, uint(200), l.ASN)
+		require.Equal(t, 100, l.RiskScore)
+		require.Len(t, l.RiskFactors, 3)
+
+		var nilRisk *LoginRisk
+		nilRisk.Apply(l)
+		require.False(t, nilRisk.Blocked(false))
+	})
+}
+
+func TestAggregateLoginLogs(t *testing.T) {
+	since := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(at time.Duration, username, ip, status, country string, score int, factors ...string) *modellogmgmt.LoginLog {
+		createdAt := since.Add(at)
+		return &modellogmgmt.LoginLog{
+			Username: username, ClientIP: ip, Status: modellogmgmt.LoginStatus(status), Country: country,
+			RiskScore: score, RiskFactors: factors, Base: model.Base{CreatedAt: &createdAt},
+		}
+	}
+	logs := []*modellogmgmt.LoginLog{
+		entry(10*time.Minute, "alice", "1.1.1.1", modellogmgmt.LoginStatusSuccess, "GB", 0),
+		entry(20*time.Minute, "alice", "2.2.2.2", modellogmgmt.LoginStatusSuccess, "US", 100, modellogmgmt.RiskFactorNewCountry, modellogmgmt.RiskFactorImpossibleTravel),
+		entry(70*time.Minute, "bob", "3.3.3.3", modellogmgmt.LoginStatusFailure, "", 0),
+		entry(80*time.Minute, "bob", "3.3.3.3", modellogmgmt.LoginStatusLocked, "", 20, modellogmgmt.RiskFactorFailedAttempts),
+	}
+	rsp := aggregateLoginLogs(logs, &modellogmgmt.LoginLogStatsReq{
+		Since:    since,
+		Until:    since.Add(3 * time.Hour),
+		Interval: modellogmgmt.StatsIntervalHour,
+		Top:      1,
+	})
+
+	require.Equal(t, int64(4), rsp.Total)
+	require.Equal(t, []*modellogmgmt.StatsBucket{
+		{Key: modellogmgmt.LoginStatusSuccess, Count: 2},
+		{Key: modellogmgmt.LoginStatusFailure, Count: 1},
+		{Key: modellogmgmt.LoginStatusLocked, Count: 1},
+	}, rsp.ByStatus)
+	require.Equal(t, []*modellogmgmt.StatsBucket{
+		{Key: modellogmgmt.RiskLevelNone, Count: 2},
+		{Key: modellogmgmt.RiskLevelHigh, Count: 1},
+		{Key: modellogmgmt.RiskLevelLow, Count: 1},
+	}, rsp.ByRiskLevel)
+	require.Len(t, rsp.ByFactor, 3)
+	require.Equal(t, []*modellogmgmt.StatsBucket{{Key: "GB", Count: 1}}, rsp.ByCountry)
+	require.Equal(t, []*modellogmgmt.StatsBucket{{Key: "3.3.3.3", Count: 2}}, rsp.TopFailedIPs)
+	require.Equal(t, []*modellogmgmt.StatsBucket{{Key: "alice", Count: 1}}, rsp.TopRiskyUsers)
+	require.Equal(t, []*modellogmgmt.StatsBucket{{Key: "bob", Count: 2}}, rsp.TopFailedUsers)
+
+	require.Len(t, rsp.Timeline, 4)
+	require.Equal(t, &modellogmgmt.StatsPoint{Time: since, Total: 2, Success: 2, Risky: 1}, rsp.Timeline[0])
+	require.Equal(t, &modellogmgmt.StatsPoint{Time: since.Add(time.Hour), Total: 2, Failure: 2}, rsp.Timeline[1])
+	require.Zero(t, rsp.Timeline[2].Total)
+}
@@ -0,0 +1,209 @@
+package servicelogmgmt
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStatsRange = 24 * time.Hour
+	maxStatsRange     = 90 * 24 * time.Hour
+	defaultStatsTop   = 10
+	// maxStatsRows caps the login logs aggregated by one request.
+	maxStatsRows = 200000
+)
+
+// LoginLogStatsService aggregates the login logs by status, risk, location and time for dashboards.
+type LoginLogStatsService struct {
+	service.Base[*modellogmgmt.LoginLogStats, *modellogmgmt.LoginLogStatsReq, *modellogmgmt.LoginLogStatsRsp]
+}
+
+func (s *LoginLogStatsService) List(ctx *types.ServiceContext, req *modellogmgmt.LoginLogStatsReq) (rsp *modellogmgmt.LoginLogStatsRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if err = parseStatsQuery(ctx, req); err != nil {
+		return nil, types.NewServiceError(http.StatusBadRequest, err.Error())
+	}
+	if req.Until.IsZero() {
+		req.Until = time.Now()
+	}
+	if req.Since.IsZero() {
+		req.Since = req.Until.Add(-defaultStatsRange)
+	}
+	if !req.Since.Before(req.Until) {
+		return nil, types.NewServiceError(http.StatusBadRequest, "since must be before until")
+	}
+	if req.Until.Sub(req.Since) > maxStatsRange {
+		return nil, types.NewServiceError(http.StatusBadRequest, "time range must not exceed 90 days")
+	}
+	switch req.Interval {
+	case "":
+		req.Interval = modellogmgmt.StatsIntervalHour
+		if req.Until.Sub(req.Since) > 48*time.Hour {
+			req.Interval = modellogmgmt.StatsIntervalDay
+		}
+	case modellogmgmt.StatsIntervalHour, modellogmgmt.StatsIntervalDay:
+	default:
+		return nil, types.NewServiceError(http.StatusBadRequest, "interval must be hour or day")
+	}
+	if req.Top <= 0 {
+		req.Top = defaultStatsTop
+	}
+
+	logs := make([]*modellogmgmt.LoginLog, 0)
+	if err = database.Database[*modellogmgmt.LoginLog](ctx.DatabaseContext()).
+		WithSelect("username", "client_ip", "status", "country", "asn", "as_org", "risk_score", "risk_factors", "created_at").
+		WithTimeRange("created_at", req.Since, req.Until).
+		WithLimit(maxStatsRows).
+		List(&logs); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	if len(logs) == maxStatsRows {
+		log.Warnz("login log stats truncated", zap.Int("rows", maxStatsRows))
+	}
+
+	return aggregateLoginLogs(logs, req), nil
+}
+
+// parseStatsQuery overrides the request with the query parameters.
+func parseStatsQuery(ctx *types.ServiceContext, req *modellogmgmt.LoginLogStatsReq) error {
+	var err error
+	if v := ctx.Query.Get("since"); len(v) > 0 {
+		if req.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("invalid since %q, it must be RFC3339", v)
+		}
+	}
+	if v := ctx.Query.Get("until"); len(v) > 0 {
+		if req.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("invalid until %q, it must be RFC3339", v)
+		}
+	}
+	if v := ctx.Query.Get("interval"); len(v) > 0 {
+		req.Interval = v
+	}
+	if v := ctx.Query.Get("top"); len(v) > 0 {
+		if req.Top, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid top %q", v)
+		}
+	}
+	return nil
+}
+
+// aggregateLoginLogs aggregates the login logs in the time range of the request.
+func aggregateLoginLogs(logs []*modellogmgmt.LoginLog, req *modellogmgmt.LoginLogStatsReq) *modellogmgmt.LoginLogStatsRsp {
+	byStatus := make(map[string]int64)
+	byRisk := make(map[string]int64)
+	byFactor := make(map[string]int64)
+	byCountry := make(map[string]int64)
+	byASN := make(map[string]int64)
+	failedIPs := make(map[string]int64)
+	riskyUsers := make(map[string]int64)
+	failedUsers := make(map[string]int64)
+
+	truncate := func(t time.Time) time.Time {
+		t = t.In(req.Since.Location())
+		if req.Interval == modellogmgmt.StatsIntervalDay {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		return t.Truncate(time.Hour)
+	}
+	timeline := make([]*modellogmgmt.StatsPoint, 0)
+	points := make(map[time.Time]*modellogmgmt.StatsPoint)
+	for t := truncate(req.Since); !t.After(req.Until); {
+		p := &modellogmgmt.StatsPoint{Time: t}
+		timeline = append(timeline, p)
+		points[t] = p
+		if req.Interval == modellogmgmt.StatsIntervalDay {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Hour)
+		}
+	}
+
+	for _, l := range logs {
+		status := string(l.Status)
+		failed := status == modellogmgmt.LoginStatusFailure || status == modellogmgmt.LoginStatusLocked
+		level := modellogmgmt.RiskLevelOf(l.RiskScore)
+		risky := level == modellogmgmt.RiskLevelMedium || level == modellogmgmt.RiskLevelHigh
+
+		byStatus[status]++
+		byRisk[level]++
+		for _, f := range l.RiskFactors {
+			byFactor[f]++
+		}
+		if len(l.Country) > 0 {
+			byCountry[l.Country]++
+		}
+		if l.ASN > 0 {
+			byASN[fmt.Sprintf("AS%d %s", l.ASN, l.ASOrg)]++
+		}
+		if failed {
+			if len(l.ClientIP) > 0 {
+				failedIPs[l.ClientIP]++
+			}
+			if len(l.Username) > 0 {
+				failedUsers[l.Username]++
+			}
+		}
+		if risky && len(l.Username) > 0 {
+			riskyUsers[l.Username]++
+		}
+
+		if p, ok := points[truncate(l.GetCreatedAt())]; ok {
+			p.Total++
+			switch {
+			case status == modellogmgmt.LoginStatusSuccess:
+				p.Success++
+			case failed:
+				p.Failure++
+			}
+			if risky {
+				p.Risky++
+			}
+		}
+	}
+
+	return &modellogmgmt.LoginLogStatsRsp{
+		Since:          req.Since,
+		Until:          req.Until,
+		Interval:       req.Interval,
+		Total:          int64(len(logs)),
+		ByStatus:       statsBuckets(byStatus, 0),
+		ByRiskLevel:    statsBuckets(byRisk, 0),
+		ByFactor:       statsBuckets(byFactor, 0),
+		ByCountry:      statsBuckets(byCountry, req.Top),
+		ByASN:          statsBuckets(byASN, req.Top),
+		TopFailedIPs:   statsBuckets(failedIPs, req.Top),
+		TopRiskyUsers:  statsBuckets(riskyUsers, req.Top),
+		TopFailedUsers: statsBuckets(failedUsers, req.Top),
+		Timeline:       timeline,
+	}
+}
+
+// statsBuckets returns the buckets ordered by count descending and key, top <= 0 returns all.
+func statsBuckets(counts map[string]int64, top int) []*modellogmgmt.StatsBucket {
+	buckets := make([]*modellogmgmt.StatsBucket, 0, len(counts))
+	for k, v := range counts {
+		buckets = append(buckets, &modellogmgmt.StatsBucket{Key: k, Count: v})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	if top > 0 && len(buckets) > top {
+		buckets = buckets[:top]
+	}
+	return buckets
+}
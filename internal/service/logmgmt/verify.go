@@ -0,0 +1,51 @@
+package servicelogmgmt
+
+import (
+	"crypto/ed25519"
+	"net/http"
+
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+// CheckpointPublicKey verifies the checkpoint signatures, nil skips the signature check.
+var CheckpointPublicKey ed25519.PublicKey
+
+// OperationLogVerifyService verifies the operation log hash chains.
+type OperationLogVerifyService struct {
+	service.Base[*modellogmgmt.OperationLogVerify, *modellogmgmt.OperationLogVerifyReq, *modellogmgmt.OperationLogVerifyRsp]
+}
+
+func (s *OperationLogVerifyService) Create(ctx *types.ServiceContext, req *modellogmgmt.OperationLogVerifyReq) (rsp *modellogmgmt.OperationLogVerifyRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if !auditmanager.ChainEnabled() {
+		return nil, types.NewServiceError(http.StatusBadRequest, "tamper-evident operation log is not enabled")
+	}
+
+	var results []*modellogmgmt.ChainResult
+	if len(req.ChainKey) > 0 {
+		var res *modellogmgmt.ChainResult
+		if res, err = auditmanager.Verify(req.ChainKey, CheckpointPublicKey); err == nil {
+			results = []*modellogmgmt.ChainResult{res}
+		}
+	} else {
+		results, err = auditmanager.VerifyAll(CheckpointPublicKey)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	rsp = &modellogmgmt.OperationLogVerifyRsp{Valid: true, Results: results}
+	for _, res := range results {
+		if !res.Valid {
+			rsp.Valid = false
+			log.Warnz("operation log chain is broken", zap.String("chain", res.ChainKey), zap.Uint64("seq", res.Broken.Seq), zap.String("reason", res.Broken.Reason))
+		}
+	}
+	return rsp, nil
+}
@@ -0,0 +1,52 @@
+package servicetwofa
+
+import (
+	"sync"
+
+	"github.com/forbearing/gst/types"
+)
+
+// CredentialGuard is the brute-force protection of the endpoints verifying user credentials.
+// It is implemented by the login guard of the iam module, so every path verifying
+// a password or a passkey shares the failure counters and lockouts of the login.
+type CredentialGuard interface {
+	// Check rejects the attempt if the account or the client ip is locked
+	// or a required captcha was not solved.
+	Check(captchaToken string) error
+	// Fail records a failed attempt.
+	// There is no success counterpart: only a completed login resets the failure counters,
+	// otherwise a known password could be used to reset the counter of the second factor.
+	Fail()
+}
+
+// CredentialGuardFunc creates the CredentialGuard of one request for the username.
+type CredentialGuardFunc func(ctx *types.ServiceContext, log types.Logger, username string) CredentialGuard
+
+var (
+	credentialGuardFn CredentialGuardFunc
+	credentialGuardMu sync.RWMutex
+)
+
+// SetCredentialGuard sets the brute-force protection of the twofa endpoints verifying credentials.
+// This function is called by the iam module when the login protection is configured.
+func SetCredentialGuard(fn CredentialGuardFunc) {
+	credentialGuardMu.Lock()
+	defer credentialGuardMu.Unlock()
+	credentialGuardFn = fn
+}
+
+// newCredentialGuard returns a no-op guard if no guard is set.
+func newCredentialGuard(ctx *types.ServiceContext, log types.Logger, username string) CredentialGuard {
+	credentialGuardMu.RLock()
+	fn := credentialGuardFn
+	credentialGuardMu.RUnlock()
+	if fn == nil {
+		return nopGuard{}
+	}
+	return fn(ctx, log, username)
+}
+
+type nopGuard struct{}
+
+func (nopGuard) Check(string) error { return nil }
+func (nopGuard) Fail()              {}
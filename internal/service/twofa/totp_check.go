@@ -6,9 +6,9 @@ import (
 	"github.com/forbearing/gst/database"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
 	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	"github.com/forbearing/gst/pkg/password"
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type TOTPCheckService struct {
@@ -28,6 +28,13 @@ func (c *TOTPCheckService) Create(ctx *types.ServiceContext, req *modeltwofa.TOT
 		return nil, fmt.Errorf("password is required")
 	}
 
+	// 验证密码前检查账号和客户端 IP 是否被锁定, 失败次数与登录共享
+	guard := newCredentialGuard(ctx, log, req.Username)
+	if err = guard.Check(req.CaptchaToken); err != nil {
+		log.Warnw("totp check rejected by login guard", "username", req.Username, "client_ip", ctx.ClientIP, "error", err)
+		return nil, err
+	}
+
 	// 查找用户
 	db := database.Database[*modeliamuser.User](ctx.DatabaseContext())
 	users := make([]*modeliamuser.User, 0)
@@ -37,13 +44,15 @@ func (c *TOTPCheckService) Create(ctx *types.ServiceContext, req *modeltwofa.TOT
 	}
 	if len(users) == 0 {
 		log.Warnw("user not found", "username", req.Username, "client_ip", ctx.ClientIP)
+		guard.Fail()
 		return nil, fmt.Errorf("authentication failed")
 	}
 	user := users[0]
 
 	// 验证密码
-	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	if ok, _, _ := password.Verify(user.PasswordHash, req.Password); !ok {
 		log.Warnw("invalid password", "username", req.Username, "client_ip", ctx.ClientIP)
+		guard.Fail()
 		return nil, fmt.Errorf("authentication failed")
 	}
 
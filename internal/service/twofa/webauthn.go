@@ -0,0 +1,251 @@
+package servicetwofa
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	"github.com/forbearing/gst/pkg/webauthn"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/forbearing/gst/util"
+)
+
+// webAuthnCeremonyNamespace stores pending WebAuthn ceremonies by ceremony id.
+const webAuthnCeremonyNamespace = "twofa:webauthn:ceremony"
+
+const (
+	ceremonyRegister = "register"
+	ceremonyLogin    = "login"
+)
+
+// WebAuthnConfig is the WebAuthn relying party configuration.
+type WebAuthnConfig struct {
+	RPID               string   // RPID is the relying party id, default is "localhost"
+	RPDisplayName      string   // RPDisplayName is shown by authenticators, default is the framework name
+	RPOrigins          []string // RPOrigins are the allowed origins, default is "http://localhost:<server port>"
+	EnablePasswordless bool     // EnablePasswordless allows passkey login without password, default is false
+}
+
+var (
+	webAuthnConfig   WebAuthnConfig
+	webAuthnConfigMu sync.RWMutex
+)
+
+// SetWebAuthnConfig sets the WebAuthn configuration for twofa module.
+// This function should be called during module registration.
+func SetWebAuthnConfig(cfg WebAuthnConfig) {
+	webAuthnConfigMu.Lock()
+	defer webAuthnConfigMu.Unlock()
+	webAuthnConfig = cfg
+}
+
+// PasswordlessEnabled reports whether passkey login without password is enabled.
+func PasswordlessEnabled() bool {
+	webAuthnConfigMu.RLock()
+	defer webAuthnConfigMu.RUnlock()
+	return webAuthnConfig.EnablePasswordless
+}
+
+// newWebAuthn creates the relying party from the current configuration.
+// Defaults are resolved lazily because the server port is only known after bootstrap.
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	webAuthnConfigMu.RLock()
+	cfg := webAuthnConfig
+	webAuthnConfigMu.RUnlock()
+
+	if len(cfg.RPID) == 0 {
+		cfg.RPID = "localhost"
+	}
+	if len(cfg.RPDisplayName) == 0 {
+		cfg.RPDisplayName = consts.FrameworkName
+	}
+	if len(cfg.RPOrigins) == 0 {
+		cfg.RPOrigins = []string{fmt.Sprintf("http://localhost:%d", config.App.Server.Port)}
+	}
+	return webauthn.New(webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+}
+
+// webAuthnCeremony is the pending ceremony state kept in redis between begin and finish.
+type webAuthnCeremony struct {
+	Kind         string               `json:"kind"`
+	UserID       string               `json:"user_id,omitempty"`
+	Passwordless bool                 `json:"passwordless,omitempty"`
+	Session      webauthn.SessionData `json:"session"`
+}
+
+func webAuthnCeremonyKey(id string) string {
+	return webAuthnCeremonyNamespace + ":" + id
+}
+
+func saveCeremony(ceremony webAuthnCeremony) (string, error) {
+	id := util.UUID()
+	ttl := time.Until(ceremony.Session.Expires)
+	if err := redis.Cache[webAuthnCeremony]().Set(webAuthnCeremonyKey(id), ceremony, ttl); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// takeCeremony loads and deletes the ceremony, every challenge can be answered only once.
+func takeCeremony(id, kind string) (webAuthnCeremony, error) {
+	if len(id) == 0 {
+		return webAuthnCeremony{}, errors.New("ceremony_id is required")
+	}
+	// Get and delete atomically, so concurrent finishes can't both answer the same challenge.
+	data, err := redis.GetDel(webAuthnCeremonyKey(id))
+	if err != nil {
+		return webAuthnCeremony{}, errors.Wrap(err, "webauthn ceremony not found or expired")
+	}
+	var ceremony webAuthnCeremony
+	if err = json.Unmarshal(data, &ceremony); err != nil {
+		return webAuthnCeremony{}, errors.Wrap(err, "invalid webauthn ceremony")
+	}
+	if ceremony.Kind != kind {
+		return webAuthnCeremony{}, errors.New("webauthn ceremony kind mismatch")
+	}
+	return ceremony, nil
+}
+
+// listWebAuthnCredentials returns the active WebAuthn credentials of the user.
+func listWebAuthnCredentials(ctx *types.ServiceContext, userID string) ([]*modeltwofa.WebAuthnCredential, error) {
+	creds := make([]*modeltwofa.WebAuthnCredential, 0)
+	if err := database.Database[*modeltwofa.WebAuthnCredential](ctx.DatabaseContext()).WithQuery(&modeltwofa.WebAuthnCredential{
+		UserID:   userID,
+		IsActive: true,
+	}).List(&creds); err != nil {
+		return nil, fmt.Errorf("failed to query webauthn credentials: %w", err)
+	}
+	return creds, nil
+}
+
+func toWebAuthnCredentials(creds []*modeltwofa.WebAuthnCredential) []webauthn.Credential {
+	result := make([]webauthn.Credential, 0, len(creds))
+	for _, c := range creds {
+		id, err := webauthn.DecodeBase64(c.CredentialID)
+		if err != nil {
+			continue
+		}
+		aaguid, _ := hex.DecodeString(c.AAGUID)
+		result = append(result, webauthn.Credential{
+			ID:              id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			AAGUID:          aaguid,
+			SignCount:       c.SignCount,
+			Transports:      c.Transports,
+		})
+	}
+	return result
+}
+
+// HasWebAuthnCredentials reports whether the user has active WebAuthn credentials.
+func HasWebAuthnCredentials(ctx *types.ServiceContext, userID string) (bool, error) {
+	if !Enabled {
+		return false, nil
+	}
+	creds, err := listWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(creds) > 0, nil
+}
+
+// ValidateWebAuthnAssertion verifies a second factor assertion for the user,
+// the ceremony must have been started by POST /api/2fa/webauthn/login/begin for the same user.
+func ValidateWebAuthnAssertion(ctx *types.ServiceContext, userID, ceremonyID string, resp *webauthn.CredentialAssertionResponse) error {
+	if resp == nil {
+		return errors.New("webauthn credential is required")
+	}
+	ceremony, err := takeCeremony(ceremonyID, ceremonyLogin)
+	if err != nil {
+		return err
+	}
+	if ceremony.UserID != userID {
+		return errors.New("webauthn ceremony does not belong to the user")
+	}
+	_, err = finishAssertion(ctx, userID, ceremony, resp, false)
+	return err
+}
+
+// FinishPasskeyLogin verifies a discoverable assertion and returns the user id it authenticates.
+func FinishPasskeyLogin(ctx *types.ServiceContext, ceremonyID string, resp *webauthn.CredentialAssertionResponse) (string, error) {
+	if !PasswordlessEnabled() {
+		return "", types.NewServiceError(http.StatusForbidden, "passwordless login is disabled")
+	}
+	if resp == nil {
+		return "", errors.New("webauthn credential is required")
+	}
+	ceremony, err := takeCeremony(ceremonyID, ceremonyLogin)
+	if err != nil {
+		return "", err
+	}
+	if len(ceremony.UserID) > 0 {
+		return "", errors.New("webauthn ceremony is not a passkey login")
+	}
+	if len(resp.Response.UserHandle) == 0 {
+		return "", errors.New("webauthn user handle is required")
+	}
+	userID := string(resp.Response.UserHandle)
+	if _, err = finishAssertion(ctx, userID, ceremony, resp, true); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// finishAssertion verifies resp against the user's credentials and records the new signature counter.
+func finishAssertion(ctx *types.ServiceContext, userID string, ceremony webAuthnCeremony, resp *webauthn.CredentialAssertionResponse, passwordless bool) (*modeltwofa.WebAuthnCredential, error) {
+	wa, err := newWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := listWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if passwordless {
+		filtered := creds[:0]
+		for _, c := range creds {
+			if c.Passwordless {
+				filtered = append(filtered, c)
+			}
+		}
+		creds = filtered
+	}
+	if len(creds) == 0 {
+		return nil, errors.New("no active webauthn credentials found")
+	}
+
+	verified, err := wa.FinishLogin(&ceremony.Session, toWebAuthnCredentials(creds), resp)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(verified.ID)
+	for _, c := range creds {
+		if c.CredentialID != credentialID {
+			continue
+		}
+		now := time.Now()
+		c.SignCount = verified.SignCount
+		c.LastUsedAt = &now
+		if err = database.Database[*modeltwofa.WebAuthnCredential](ctx.DatabaseContext()).Update(c); err != nil {
+			return nil, fmt.Errorf("failed to update webauthn credential: %w", err)
+		}
+		return c, nil
+	}
+	return nil, webauthn.ErrCredentialNotFound
+}
@@ -0,0 +1,10 @@
+package servicetwofa
+
+import (
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	"github.com/forbearing/gst/service"
+)
+
+type WebAuthnCredentialService struct {
+	service.Base[*modeltwofa.WebAuthnCredential, *modeltwofa.WebAuthnCredential, *modeltwofa.WebAuthnCredential]
+}
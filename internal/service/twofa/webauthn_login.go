@@ -0,0 +1,96 @@
+package servicetwofa
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/forbearing/gst/database"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	"github.com/forbearing/gst/pkg/password"
+	"github.com/forbearing/gst/pkg/webauthn"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+type WebAuthnLoginBeginService struct {
+	service.Base[*modeltwofa.WebAuthnLoginBegin, *modeltwofa.WebAuthnLoginBeginReq, *modeltwofa.WebAuthnLoginBeginRsp]
+}
+
+func (s *WebAuthnLoginBeginService) Create(ctx *types.ServiceContext, req *modeltwofa.WebAuthnLoginBeginReq) (rsp *modeltwofa.WebAuthnLoginBeginRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	wa, err := newWebAuthn()
+	if err != nil {
+		log.Errorz("failed to create webauthn relying party", zap.Error(err))
+		return nil, fmt.Errorf("webauthn is not configured")
+	}
+
+	var userID string
+	var credentials []webauthn.Credential
+
+	if len(req.Username) == 0 {
+		// Discoverable passkey login, the authenticator picks the credential.
+		if !PasswordlessEnabled() {
+			return nil, types.NewServiceError(http.StatusForbidden, "passwordless login is disabled")
+		}
+	} else {
+		// Second factor, verify the password first so the endpoint cannot be used to enumerate credentials.
+		if len(req.Password) == 0 {
+			return nil, fmt.Errorf("password is required")
+		}
+		// The password check shares the failure counters and lockouts of the login.
+		guard := newCredentialGuard(ctx, log, req.Username)
+		if err = guard.Check(req.CaptchaToken); err != nil {
+			log.Warnz("webauthn login rejected by login guard", zap.String("username", req.Username), zap.String("client_ip", ctx.ClientIP), zap.Error(err))
+			return nil, err
+		}
+		users := make([]*modeliamuser.User, 0)
+		if err = database.Database[*modeliamuser.User](ctx.DatabaseContext()).WithLimit(1).WithQuery(&modeliamuser.User{Username: req.Username}).List(&users); err != nil {
+			log.Errorz("failed to query user", zap.String("username", req.Username), zap.Error(err))
+			return nil, fmt.Errorf("authentication failed")
+		}
+		if len(users) == 0 {
+			log.Warnz("user not found", zap.String("username", req.Username), zap.String("client_ip", ctx.ClientIP))
+			guard.Fail()
+			return nil, fmt.Errorf("authentication failed")
+		}
+		if ok, _, _ := password.Verify(users[0].PasswordHash, req.Password); !ok {
+			log.Warnz("invalid password", zap.String("username", req.Username), zap.String("client_ip", ctx.ClientIP))
+			guard.Fail()
+			return nil, fmt.Errorf("authentication failed")
+		}
+		userID = users[0].ID
+
+		creds, err := listWebAuthnCredentials(ctx, userID)
+		if err != nil {
+			log.Errorz("failed to list webauthn credentials", zap.Error(err))
+			return nil, err
+		}
+		if len(creds) == 0 {
+			return nil, fmt.Errorf("no active webauthn credentials found")
+		}
+		credentials = toWebAuthnCredentials(creds)
+	}
+
+	options, session, err := wa.BeginLogin([]byte(userID), credentials)
+	if err != nil {
+		log.Errorz("failed to begin webauthn login", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin webauthn login")
+	}
+	ceremonyID, err := saveCeremony(webAuthnCeremony{
+		Kind:    ceremonyLogin,
+		UserID:  userID,
+		Session: *session,
+	})
+	if err != nil {
+		log.Errorz("failed to save webauthn ceremony", zap.Error(err))
+		return nil, fmt.Errorf("failed to save webauthn ceremony")
+	}
+
+	return &modeltwofa.WebAuthnLoginBeginRsp{
+		CeremonyID: ceremonyID,
+		PublicKey:  options,
+	}, nil
+}
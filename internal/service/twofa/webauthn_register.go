@@ -0,0 +1,153 @@
+package servicetwofa
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	"github.com/forbearing/gst/pkg/webauthn"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+	"go.uber.org/zap"
+)
+
+type WebAuthnRegisterBeginService struct {
+	service.Base[*modeltwofa.WebAuthnRegisterBegin, *modeltwofa.WebAuthnRegisterBeginReq, *modeltwofa.WebAuthnRegisterBeginRsp]
+}
+
+func (s *WebAuthnRegisterBeginService) Create(ctx *types.ServiceContext, req *modeltwofa.WebAuthnRegisterBeginReq) (rsp *modeltwofa.WebAuthnRegisterBeginRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if len(ctx.UserID) == 0 || len(ctx.Username) == 0 {
+		log.Errorz("user not found in context")
+		return nil, types.NewServiceError(http.StatusUnauthorized, "authentication required")
+	}
+	if req.Passwordless && !PasswordlessEnabled() {
+		return nil, types.NewServiceError(http.StatusForbidden, "passwordless login is disabled")
+	}
+
+	wa, err := newWebAuthn()
+	if err != nil {
+		log.Errorz("failed to create webauthn relying party", zap.Error(err))
+		return nil, fmt.Errorf("webauthn is not configured")
+	}
+	creds, err := listWebAuthnCredentials(ctx, ctx.UserID)
+	if err != nil {
+		log.Errorz("failed to list webauthn credentials", zap.Error(err))
+		return nil, err
+	}
+
+	opts := make([]webauthn.RegistrationOption, 0)
+	if req.Passwordless {
+		opts = append(opts, webauthn.WithResidentKey(webauthn.ResidentKeyRequired))
+	}
+	options, session, err := wa.BeginRegistration(webauthn.User{
+		ID:          []byte(ctx.UserID),
+		Name:        ctx.Username,
+		Credentials: toWebAuthnCredentials(creds),
+	}, opts...)
+	if err != nil {
+		log.Errorz("failed to begin webauthn registration", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin webauthn registration")
+	}
+
+	ceremonyID, err := saveCeremony(webAuthnCeremony{
+		Kind:         ceremonyRegister,
+		UserID:       ctx.UserID,
+		Passwordless: req.Passwordless,
+		Session:      *session,
+	})
+	if err != nil {
+		log.Errorz("failed to save webauthn ceremony", zap.Error(err))
+		return nil, fmt.Errorf("failed to save webauthn ceremony")
+	}
+
+	log.Infoz("webauthn registration started", zap.String("user_id", ctx.UserID), zap.Bool("passwordless", req.Passwordless))
+
+	return &modeltwofa.WebAuthnRegisterBeginRsp{
+		CeremonyID: ceremonyID,
+		PublicKey:  options,
+	}, nil
+}
+
+type WebAuthnRegisterFinishService struct {
+	service.Base[*modeltwofa.WebAuthnRegisterFinish, *modeltwofa.WebAuthnRegisterFinishReq, *modeltwofa.WebAuthnRegisterFinishRsp]
+}
+
+func (s *WebAuthnRegisterFinishService) Create(ctx *types.ServiceContext, req *modeltwofa.WebAuthnRegisterFinishReq) (rsp *modeltwofa.WebAuthnRegisterFinishRsp, err error) {
+	log := s.WithServiceContext(ctx, ctx.GetPhase())
+
+	if len(ctx.UserID) == 0 {
+		log.Errorz("user_id not found in context")
+		return nil, types.NewServiceError(http.StatusUnauthorized, "authentication required")
+	}
+
+	ceremony, err := takeCeremony(req.CeremonyID, ceremonyRegister)
+	if err != nil {
+		log.Warnz("invalid webauthn ceremony", zap.String("user_id", ctx.UserID), zap.Error(err))
+		return nil, err
+	}
+	if ceremony.UserID != ctx.UserID {
+		log.Warnz("webauthn ceremony user mismatch", zap.String("user_id", ctx.UserID))
+		return nil, types.NewServiceError(http.StatusForbidden, "webauthn ceremony does not belong to the user")
+	}
+
+	wa, err := newWebAuthn()
+	if err != nil {
+		log.Errorz("failed to create webauthn relying party", zap.Error(err))
+		return nil, fmt.Errorf("webauthn is not configured")
+	}
+	cred, err := wa.FinishRegistration(&ceremony.Session, &req.Credential)
+	if err != nil {
+		log.Warnz("webauthn registration verification failed", zap.String("user_id", ctx.UserID), zap.Error(err))
+		return nil, fmt.Errorf("webauthn registration failed: %w", err)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(cred.ID)
+	existing := make([]*modeltwofa.WebAuthnCredential, 0)
+	if err = database.Database[*modeltwofa.WebAuthnCredential](ctx.DatabaseContext()).WithQuery(&modeltwofa.WebAuthnCredential{
+		CredentialID: credentialID,
+	}).WithLimit(1).List(&existing); err != nil {
+		log.Errorz("failed to list webauthn credentials", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webauthn credentials: %w", err)
+	}
+	if len(existing) > 0 {
+		log.Warnz("webauthn credential already exists", zap.String("user_id", ctx.UserID))
+		return nil, fmt.Errorf("credential already registered")
+	}
+
+	now := time.Now()
+	credential := &modeltwofa.WebAuthnCredential{
+		UserID:          ctx.UserID,
+		DeviceName:      req.DeviceName,
+		CredentialID:    credentialID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		AAGUID:          hex.EncodeToString(cred.AAGUID),
+		SignCount:       cred.SignCount,
+		Transports:      cred.Transports,
+		Passwordless:    ceremony.Passwordless,
+		IsActive:        true,
+		LastUsedAt:      &now,
+	}
+	if err = database.Database[*modeltwofa.WebAuthnCredential](ctx.DatabaseContext()).Create(credential); err != nil {
+		log.Errorz("failed to create webauthn credential", zap.Error(err))
+		return nil, fmt.Errorf("failed to save credential: %w", err)
+	}
+
+	log.Infoz("webauthn credential registered successfully",
+		zap.String("user_id", ctx.UserID),
+		zap.String("credential_id", credential.ID),
+		zap.Bool("passwordless", credential.Passwordless))
+
+	return &modeltwofa.WebAuthnRegisterFinishRsp{
+		ID:           credential.ID,
+		CredentialID: credentialID,
+		Passwordless: credential.Passwordless,
+		Message:      "WebAuthn credential registered successfully",
+	}, nil
+}
@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	dialectPostgres = "postgres"
+	dialectMySQL    = "mysql"
+)
+
+// isAdvisoryDialect reports whether the database supports advisory locks.
+func isAdvisoryDialect(name string) bool {
+	return name == dialectPostgres || name == dialectMySQL
+}
+
+// NewDatabaseLocker creates a locker backed by the advisory locks of postgres (pg_try_advisory_lock)
+// or mysql (GET_LOCK), eg: database.DB or postgres.Default.
+//
+// Advisory locks belong to a database session, so every lease holds a dedicated connection of the pool
+// until unlocked and the lock is lost when the connection breaks. The renewal pings the connection,
+// the ttl only sets the interval of the pings.
+// The fencing token comes from the database, txid_current() for postgres and UUID_SHORT() for mysql,
+// it increases across all locks rather than per lock.
+func NewDatabaseLocker(db *gorm.DB) Locker { return &databaseLocker{db: db} }
+
+type databaseLocker struct {
+	db *gorm.DB
+}
+
+func (d *databaseLocker) Lock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	o := newOptions(opts)
+	return acquire(ctx, o, func() (Lease, error) { return d.tryLock(ctx, name, o) })
+}
+
+func (d *databaseLocker) TryLock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return d.tryLock(ctx, name, newOptions(opts))
+}
+
+func (d *databaseLocker) tryLock(ctx context.Context, name string, o options) (Lease, error) {
+	if d.db == nil {
+		return nil, errors.New("lock: database is not initialized")
+	}
+	dialect := d.db.Dialector.Name()
+	if !isAdvisoryDialect(dialect) {
+		return nil, errors.Newf("lock: advisory locks are not supported by %s", dialect)
+	}
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &databaseLock{conn: conn, dialect: dialect, key: advisoryKey(dialect, name)}
+	var acquired bool
+	var fence int64
+	switch dialect {
+	case dialectPostgres:
+		if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err == nil && acquired {
+			err = conn.QueryRowContext(ctx, "SELECT txid_current()").Scan(&fence)
+		}
+	default:
+		var res sql.NullInt64
+		if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.key).Scan(&res); err == nil && res.Int64 == 1 {
+			acquired = true
+			err = conn.QueryRowContext(ctx, "SELECT UUID_SHORT()").Scan(&fence)
+		}
+	}
+	if err != nil || !acquired {
+		if acquired {
+			_ = l.release(context.WithoutCancel(ctx))
+		} else {
+			_ = conn.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotAcquired
+	}
+	return newLease(name, fence, l, o, nil), nil
+}
+
+// advisoryKey maps the lock name to the key of the advisory lock,
+// a bigint for postgres and a name of at most 64 characters for mysql.
+func advisoryKey(dialect, name string) any {
+	sum := sha1.Sum([]byte(name)) //nolint:gosec
+	if dialect == dialectPostgres {
+		return int64(binary.BigEndian.Uint64(sum[:8])) //nolint:gosec
+	}
+	if key := keyPrefix + name; len(key) <= 64 {
+		return key
+	}
+	return keyPrefix + hex.EncodeToString(sum[:])
+}
+
+type databaseLock struct {
+	conn    *sql.Conn
+	dialect string
+	key     any
+}
+
+// refresh pings the connection holding the lock, the lock is lost with the connection.
+func (l *databaseLock) refresh(ctx context.Context, _ time.Duration) error {
+	if err := l.conn.PingContext(ctx); err != nil {
+		if errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) {
+			return ErrLockLost
+		}
+		return err
+	}
+	return nil
+}
+
+// release releases the advisory lock and returns the connection to the pool.
+func (l *databaseLock) release(ctx context.Context) error {
+	var err error
+	if l.dialect == dialectPostgres {
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	} else {
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.key)
+	}
+	if err != nil {
+		// Close the underlying connection so that the session, and the lock, ends.
+		_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	return errors.Join(err, l.conn.Close())
+}
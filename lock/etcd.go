@@ -0,0 +1,105 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// NewEtcdLocker creates a locker backed by etcd concurrency sessions and mutexes.
+// Every lease has its own session whose etcd lease is kept alive by the client,
+// the lock is lost when the session expires. Lock waits in the queue of the mutex
+// instead of polling, the waiters acquire the lock in order.
+// The fencing token is the etcd revision at which the lock was acquired,
+// the owner is stored in the key of the holder.
+func NewEtcdLocker(cli *clientv3.Client) Locker { return &etcdLocker{cli: cli} }
+
+type etcdLocker struct {
+	cli *clientv3.Client
+}
+
+func (e *etcdLocker) Lock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return e.lock(ctx, name, newOptions(opts), (*concurrency.Mutex).Lock)
+}
+
+func (e *etcdLocker) TryLock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return e.lock(ctx, name, newOptions(opts), (*concurrency.Mutex).TryLock)
+}
+
+func (e *etcdLocker) lock(ctx context.Context, name string, o options, fn func(*concurrency.Mutex, context.Context) error) (Lease, error) {
+	if e.cli == nil {
+		return nil, errors.New("lock: etcd client is nil")
+	}
+	// The session outlives ctx, it's closed by Unlock.
+	sctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	session, err := concurrency.NewSession(e.cli, concurrency.WithTTL(ttlSeconds(o.ttl)), concurrency.WithContext(sctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	m := concurrency.NewMutex(session, keyPrefix+name)
+	if err = fn(m, ctx); err != nil {
+		_ = session.Close()
+		cancel()
+		if errors.Is(err, concurrency.ErrLocked) {
+			return nil, ErrNotAcquired
+		}
+		return nil, err
+	}
+	l := &etcdLock{session: session, mutex: m, cancel: cancel}
+	if len(o.owner) > 0 {
+		if _, err = e.cli.Put(ctx, m.Key(), o.owner, clientv3.WithLease(session.Lease())); err != nil {
+			_ = l.release(context.WithoutCancel(ctx))
+			return nil, err
+		}
+	}
+	return newLease(name, m.Header().Revision, l, options{ttl: o.ttl}, session.Done()), nil
+}
+
+// Owner returns the value of the oldest key of the mutex, it's the key of the holder.
+func (e *etcdLocker) Owner(ctx context.Context, name string) (string, error) {
+	if e.cli == nil {
+		return "", errors.New("lock: etcd client is nil")
+	}
+	resp, err := e.cli.Get(ctx, keyPrefix+name+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// ttlSeconds rounds the ttl up to the seconds granularity of etcd leases.
+func ttlSeconds(ttl time.Duration) int {
+	return max(int((ttl+time.Second-1)/time.Second), 1)
+}
+
+type etcdLock struct {
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+	cancel  context.CancelFunc
+}
+
+// refresh renews the etcd lease once, its ttl can not be changed.
+func (l *etcdLock) refresh(ctx context.Context, _ time.Duration) error {
+	if _, err := l.session.Client().KeepAliveOnce(ctx, l.session.Lease()); err != nil {
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return ErrLockLost
+		}
+		return err
+	}
+	return nil
+}
+
+// release deletes the key of the mutex and revokes the lease of the session.
+func (l *etcdLock) release(ctx context.Context) error {
+	defer l.cancel()
+	err := l.mutex.Unlock(ctx)
+	return errors.Join(err, l.session.Close())
+}
@@ -0,0 +1,320 @@
+// Package lock provides distributed locks guarding a resource across instances.
+//
+// A Locker acquires a Lease of a named lock, the lease is renewed in the background
+// until unlocked and carries a fencing token:
+//
+//	lease, err := lock.Lock(ctx, "payment:"+id)
+//	if err != nil {
+//		return err
+//	}
+//	defer lease.Unlock(context.Background())
+//
+//	// Reject the writes of a previous holder whose lease expired, eg: after a long GC pause.
+//	db.Where("id = ? AND fence < ?", id, lease.Token()).Updates(...)
+//
+// A lease may be lost before Unlock, eg: the process is partitioned from the backend,
+// callers doing long work should stop once Lease.Done is closed.
+package lock
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/provider/etcd"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotAcquired is returned by TryLock if the lock is held by another holder.
+	ErrNotAcquired = errors.New("lock: not acquired")
+	// ErrLockLost is returned if the lease expired and the lock may be held by another holder.
+	ErrLockLost = errors.New("lock: lost")
+)
+
+const (
+	// DefaultTTL is the default ttl of a lease.
+	DefaultTTL = 30 * time.Second
+	// DefaultRetryInterval is the default interval between the attempts of Lock.
+	DefaultRetryInterval = 100 * time.Millisecond
+)
+
+// keyPrefix is the prefix of the lock keys in redis and etcd.
+const keyPrefix = "gst:lock:"
+
+// Locker acquires named locks.
+type Locker interface {
+	// Lock acquires the lock, it waits until the lock is released by the holder or ctx is done.
+	Lock(ctx context.Context, name string, opts ...Option) (Lease, error)
+
+	// TryLock acquires the lock without waiting, it returns ErrNotAcquired if the lock is held.
+	TryLock(ctx context.Context, name string, opts ...Option) (Lease, error)
+}
+
+// OwnerReader is implemented by the lockers that record the owner of the locks, see WithOwner.
+// The database locker doesn't record the owner.
+type OwnerReader interface {
+	// Owner returns the owner of the lock, empty if the lock is not held or has no owner.
+	Owner(ctx context.Context, name string) (string, error)
+}
+
+// Lease is a lock held by the caller.
+type Lease interface {
+	// Name is the name of the lock.
+	Name() string
+
+	// Token is the fencing token of the lease, it increases every time the lock is acquired.
+	// Resources guarded by the lock should reject the operations carrying a token lower than
+	// the last one they have seen.
+	Token() int64
+
+	// Done is closed when the lease is unlocked or lost.
+	Done() <-chan struct{}
+
+	// Err returns ErrLockLost if the lease was lost, nil otherwise.
+	Err() error
+
+	// Refresh extends the lease to expire ttl from now, it returns ErrLockLost if the lease was lost.
+	// It's only needed if the lease is not renewed automatically, see WithoutRenew.
+	Refresh(ctx context.Context, ttl time.Duration) error
+
+	// Unlock releases the lock and stops the renewal.
+	Unlock(ctx context.Context) error
+}
+
+// Option configures the acquisition of a lock.
+type Option func(*options)
+
+type options struct {
+	ttl           time.Duration
+	retryInterval time.Duration
+	renew         bool
+	owner         string
+}
+
+func newOptions(opts []Option) options {
+	o := options{ttl: DefaultTTL, retryInterval: DefaultRetryInterval, renew: true}
+	for _, fn := range opts {
+		if fn != nil {
+			fn(&o)
+		}
+	}
+	return o
+}
+
+// WithTTL sets the ttl of the lease, default is DefaultTTL.
+// The lease is renewed every ttl/3 and expires ttl after the holder stops renewing it, eg: the holder crashes.
+// The ttl of the database locker is ignored, the lock lives as long as the database connection.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithRetryInterval sets the interval between the attempts of Lock, default is DefaultRetryInterval.
+// A jitter of 20% is added to spread the attempts of the waiters.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.retryInterval = interval
+		}
+	}
+}
+
+// WithoutRenew disables the automatic renewal of the lease, it expires after ttl unless refreshed.
+// The etcd locker always renews the lease while its session is alive.
+func WithoutRenew() Option {
+	return func(o *options) { o.renew = false }
+}
+
+// WithOwner records the owner of the lock, eg: the identity of the instance, it's read by OwnerReader.
+func WithOwner(owner string) Option {
+	return func(o *options) { o.owner = owner }
+}
+
+var (
+	defaultLocker Locker
+	lockerMu      sync.RWMutex
+)
+
+// SetLocker sets the locker used by Lock and TryLock.
+// If not set, it uses redis if enabled, then etcd if enabled, then the database if it's postgres or mysql,
+// otherwise the in-memory locker which only guards the current instance.
+func SetLocker(l Locker) {
+	lockerMu.Lock()
+	defer lockerMu.Unlock()
+	defaultLocker = l
+}
+
+// Default returns the locker used by Lock and TryLock, see SetLocker.
+func Default() Locker {
+	lockerMu.RLock()
+	l := defaultLocker
+	lockerMu.RUnlock()
+	if l != nil {
+		return l
+	}
+
+	lockerMu.Lock()
+	defer lockerMu.Unlock()
+	if defaultLocker == nil {
+		defaultLocker = chooseLocker()
+	}
+	return defaultLocker
+}
+
+// chooseLocker chooses the locker from the enabled providers.
+func chooseLocker() Locker {
+	switch {
+	case config.App.Redis.Enable:
+		return NewRedisLocker()
+	case config.App.Etcd.Enable && etcd.Client() != nil:
+		return NewEtcdLocker(etcd.Client())
+	case database.DB != nil && isAdvisoryDialect(database.DB.Dialector.Name()):
+		return NewDatabaseLocker(database.DB)
+	default:
+		zap.S().Warn("no distributed lock backend is enabled, locks only guard the current instance")
+		return NewMemoryLocker()
+	}
+}
+
+// Lock acquires the lock with the default locker, see Locker.Lock.
+func Lock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return Default().Lock(ctx, name, opts...)
+}
+
+// TryLock acquires the lock with the default locker without waiting, see Locker.TryLock.
+func TryLock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return Default().TryLock(ctx, name, opts...)
+}
+
+// acquire calls try until the lock is acquired or ctx is done.
+func acquire(ctx context.Context, o options, try func() (Lease, error)) (Lease, error) {
+	for {
+		lease, err := try()
+		if !errors.Is(err, ErrNotAcquired) {
+			return lease, err
+		}
+		jitter := time.Duration(rand.Int64N(int64(o.retryInterval)/5 + 1))
+		timer := time.NewTimer(o.retryInterval + jitter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// backend is the lock held in a backend.
+type backend interface {
+	// refresh extends the lock to expire ttl from now, it returns ErrLockLost if the lock was lost.
+	refresh(ctx context.Context, ttl time.Duration) error
+	// release releases the lock.
+	release(ctx context.Context) error
+}
+
+// lease implements Lease over a backend, it renews the backend every ttl/3 if enabled.
+type lease struct {
+	name  string
+	token int64
+	b     backend
+
+	mu     sync.Mutex
+	err    error
+	done   chan struct{}
+	closed bool
+}
+
+// newLease creates the lease, lost is closed by the backend when the lock is lost, it may be nil.
+func newLease(name string, token int64, b backend, o options, lost <-chan struct{}) *lease {
+	l := &lease{name: name, token: token, b: b, done: make(chan struct{})}
+	if o.renew || lost != nil {
+		go l.keep(o, lost)
+	}
+	return l
+}
+
+func (l *lease) Name() string          { return l.name }
+func (l *lease) Token() int64          { return l.token }
+func (l *lease) Done() <-chan struct{} { return l.done }
+
+func (l *lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *lease) Refresh(ctx context.Context, ttl time.Duration) error {
+	if err := l.Err(); err != nil {
+		return err
+	}
+	err := l.b.refresh(ctx, ttl)
+	if errors.Is(err, ErrLockLost) {
+		l.close(ErrLockLost)
+	}
+	return err
+}
+
+func (l *lease) Unlock(ctx context.Context) error {
+	if !l.close(nil) {
+		return l.Err()
+	}
+	return l.b.release(ctx)
+}
+
+// close closes the lease with err, it reports false if the lease was already closed.
+func (l *lease) close(err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.closed = true
+	l.err = err
+	close(l.done)
+	return true
+}
+
+// keep renews the lease until it's closed, the lease is lost if a renewal fails before it expires.
+func (l *lease) keep(o options, lost <-chan struct{}) {
+	var tick <-chan time.Time
+	if o.renew {
+		ticker := time.NewTicker(max(o.ttl/3, time.Millisecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	deadline := time.Now().Add(o.ttl)
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-lost:
+			if l.close(ErrLockLost) {
+				zap.S().Warnw("lock lost", "name", l.name, "token", l.token)
+			}
+			return
+		case <-tick:
+			ctx, cancel := context.WithTimeout(context.Background(), o.ttl/3)
+			err := l.b.refresh(ctx, o.ttl)
+			cancel()
+			switch {
+			case err == nil:
+				deadline = time.Now().Add(o.ttl)
+			case errors.Is(err, ErrLockLost) || time.Now().After(deadline):
+				if l.close(ErrLockLost) {
+					zap.S().Warnw("lock lost", "name", l.name, "token", l.token, "error", err.Error())
+				}
+				return
+			default:
+				zap.S().Warnw("failed to renew lock", "name", l.name, "error", err.Error())
+			}
+		}
+	}
+}
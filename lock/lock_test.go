@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	a, err := l.TryLock(ctx, "payment")
+	require.NoError(t, err)
+	require.Equal(t, "payment", a.Name())
+	require.Equal(t, int64(1), a.Token())
+
+	_, err = l.TryLock(ctx, "payment")
+	require.ErrorIs(t, err, ErrNotAcquired)
+
+	// The locks are independent.
+	other, err := l.TryLock(ctx, "email")
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock(ctx))
+
+	require.NoError(t, a.Unlock(ctx))
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("done is not closed after unlock")
+	}
+	require.NoError(t, a.Err())
+
+	// The fencing token increases every time the lock is acquired.
+	b, err := l.TryLock(ctx, "payment", WithOwner("node-1"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), b.Token())
+	owner, err := l.(OwnerReader).Owner(ctx, "payment")
+	require.NoError(t, err)
+	require.Equal(t, "node-1", owner)
+	require.NoError(t, b.Unlock(ctx))
+	owner, err = l.(OwnerReader).Owner(ctx, "payment")
+	require.NoError(t, err)
+	require.Empty(t, owner)
+}
+
+func TestLockWaits(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	a, err := l.Lock(ctx, "job")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var b Lease
+	wg.Go(func() {
+		var err error
+		b, err = l.Lock(ctx, "job", WithRetryInterval(10*time.Millisecond))
+		require.NoError(t, err)
+	})
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, a.Unlock(ctx))
+	wg.Wait()
+	require.Greater(t, b.Token(), a.Token())
+	require.NoError(t, b.Unlock(ctx))
+
+	// Lock gives up once ctx is done.
+	c, err := l.Lock(ctx, "job")
+	require.NoError(t, err)
+	defer c.Unlock(ctx) //nolint:errcheck
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(tctx, "job", WithRetryInterval(10*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLeaseRenew(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	// The lease is renewed beyond its ttl.
+	a, err := l.TryLock(ctx, "renewed", WithTTL(60*time.Millisecond))
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	_, err = l.TryLock(ctx, "renewed")
+	require.ErrorIs(t, err, ErrNotAcquired)
+	require.NoError(t, a.Err())
+	require.NoError(t, a.Unlock(ctx))
+
+	// The lease expires without renewal, the next holder takes over the lock.
+	b, err := l.TryLock(ctx, "expired", WithTTL(30*time.Millisecond), WithoutRenew())
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	c, err := l.TryLock(ctx, "expired")
+	require.NoError(t, err)
+	require.Greater(t, c.Token(), b.Token())
+	require.ErrorIs(t, b.Refresh(ctx, time.Second), ErrLockLost)
+	require.ErrorIs(t, b.Err(), ErrLockLost)
+	<-b.Done()
+	require.ErrorIs(t, b.Unlock(ctx), ErrLockLost)
+
+	// The unlock of the previous holder doesn't release the lock of the next one.
+	_, err = l.TryLock(ctx, "expired")
+	require.ErrorIs(t, err, ErrNotAcquired)
+	require.NoError(t, c.Unlock(ctx))
+}
+
+// lostBackend fails every refresh as if the lock was taken over.
+type lostBackend struct{}
+
+func (lostBackend) refresh(context.Context, time.Duration) error { return ErrLockLost }
+func (lostBackend) release(context.Context) error                { return nil }
+
+// flakyBackend fails every refresh with a transient error.
+type flakyBackend struct{}
+
+func (flakyBackend) refresh(context.Context, time.Duration) error { return errors.New("timeout") }
+func (flakyBackend) release(context.Context) error                { return nil }
+
+func TestLeaseLost(t *testing.T) {
+	o := newOptions([]Option{WithTTL(30 * time.Millisecond)})
+
+	a := newLease("a", 1, lostBackend{}, o, nil)
+	select {
+	case <-a.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease is not lost")
+	}
+	require.ErrorIs(t, a.Err(), ErrLockLost)
+
+	// Transient errors are retried until the lease expires.
+	b := newLease("b", 1, flakyBackend{}, o, nil)
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease is not lost")
+	}
+	require.ErrorIs(t, b.Err(), ErrLockLost)
+
+	// The backend reports the loss, eg: the etcd session expired.
+	lost := make(chan struct{})
+	c := newLease("c", 1, flakyBackend{}, options{ttl: time.Hour}, lost)
+	close(lost)
+	<-c.Done()
+	require.ErrorIs(t, c.Err(), ErrLockLost)
+}
+
+func TestAdvisoryKey(t *testing.T) {
+	require.Equal(t, advisoryKey(dialectPostgres, "a"), advisoryKey(dialectPostgres, "a"))
+	require.NotEqual(t, advisoryKey(dialectPostgres, "a"), advisoryKey(dialectPostgres, "b"))
+	require.Equal(t, keyPrefix+"a", advisoryKey(dialectMySQL, "a"))
+
+	long := advisoryKey(dialectMySQL, string(make([]byte, 100)))
+	require.LessOrEqual(t, len(long.(string)), 64) //nolint:errcheck
+}
+
+func TestRedisLockerDisabled(t *testing.T) {
+	_, err := NewRedisLocker().TryLock(context.Background(), "x")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotAcquired)
+}
@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/forbearing/gst/util"
+)
+
+// NewMemoryLocker creates a locker keeping the locks in process,
+// it only guards the current instance and suits tests and single instance deployments.
+func NewMemoryLocker() Locker {
+	return &memoryLocker{locks: make(map[string]*memoryEntry)}
+}
+
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	token     string // token is the random token of the holder, empty if released
+	owner     string // owner is set by WithOwner
+	expiresAt time.Time
+	fence     int64
+}
+
+func (m *memoryLocker) Lock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	o := newOptions(opts)
+	return acquire(ctx, o, func() (Lease, error) { return m.tryLock(name, o) })
+}
+
+func (m *memoryLocker) TryLock(_ context.Context, name string, opts ...Option) (Lease, error) {
+	return m.tryLock(name, newOptions(opts))
+}
+
+func (m *memoryLocker) tryLock(name string, o options) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e, ok := m.locks[name]
+	if !ok {
+		e = new(memoryEntry)
+		m.locks[name] = e
+	}
+	if e.held(now) {
+		return nil, ErrNotAcquired
+	}
+	e.token, e.owner = util.UUID(), o.owner
+	e.expiresAt = now.Add(o.ttl)
+	e.fence++
+	return newLease(name, e.fence, &memoryLock{m: m, name: name, token: e.token}, o, nil), nil
+}
+
+func (m *memoryLocker) Owner(_ context.Context, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.locks[name]; ok && e.held(time.Now()) {
+		return e.owner, nil
+	}
+	return "", nil
+}
+
+func (e *memoryEntry) held(now time.Time) bool { return len(e.token) > 0 && now.Before(e.expiresAt) }
+
+type memoryLock struct {
+	m     *memoryLocker
+	name  string
+	token string
+}
+
+func (l *memoryLock) refresh(_ context.Context, ttl time.Duration) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	e := l.m.locks[l.name]
+	if e.token != l.token || time.Now().After(e.expiresAt) {
+		return ErrLockLost
+	}
+	e.expiresAt = time.Now().Add(ttl)
+	return nil
+}
+
+// release keeps the entry so that the fencing token keeps increasing.
+func (l *memoryLock) release(context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if e := l.m.locks[l.name]; e.token == l.token {
+		e.token = ""
+	}
+	return nil
+}
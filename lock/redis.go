@@ -0,0 +1,105 @@
+package lock
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/util"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// acquireScript sets the lock key holding the token of the holder,
+// and increments the fencing counter if the key is set.
+// The keys share the hash tag of the name so that they are in the same slot of a cluster.
+var acquireScript = goredis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// refreshScript extends the lock key only if it still holds the token.
+var refreshScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock key only if it still holds the token.
+var releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// getScript reads the lock key through RunScript, which reports redis.ErrRedisIsDisabled.
+var getScript = goredis.NewScript(`return redis.call("GET", KEYS[1])`)
+
+// NewRedisLocker creates a locker backed by redis keys set with SET NX PX, see provider/redis.
+// The key holds a random token and the owner so that only the holder refreshes or deletes it,
+// the fencing token is a counter per lock name that never expires.
+// It returns redis.ErrRedisIsDisabled if redis is disabled.
+func NewRedisLocker() Locker { return redisLocker{} }
+
+type redisLocker struct{}
+
+func (r redisLocker) Lock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	o := newOptions(opts)
+	return acquire(ctx, o, func() (Lease, error) { return r.tryLock(ctx, name, o) })
+}
+
+func (r redisLocker) TryLock(ctx context.Context, name string, opts ...Option) (Lease, error) {
+	return r.tryLock(ctx, name, newOptions(opts))
+}
+
+func (redisLocker) tryLock(ctx context.Context, name string, o options) (Lease, error) {
+	// The value is "<token> <owner>", the token tells the leases of the same owner apart.
+	l := &redisLock{key: redisKey(name), token: util.UUID() + " " + o.owner}
+	fence, err := redis.RunScript(ctx, acquireScript, []string{l.key, l.key + ":fence"}, l.token, o.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotAcquired
+	}
+	return newLease(name, fence, l, o, nil), nil
+}
+
+func (redisLocker) Owner(ctx context.Context, name string) (string, error) {
+	value, err := redis.RunScript(ctx, getScript, []string{redisKey(name)}).Text()
+	if errors.Is(err, goredis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	_, owner, _ := strings.Cut(value, " ")
+	return owner, nil
+}
+
+func redisKey(name string) string { return keyPrefix + "{" + name + "}" }
+
+type redisLock struct {
+	key   string
+	token string
+}
+
+func (l *redisLock) refresh(ctx context.Context, ttl time.Duration) error {
+	n, err := redis.RunScript(ctx, refreshScript, []string{l.key}, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *redisLock) release(ctx context.Context) error {
+	return redis.RunScript(ctx, releaseScript, []string{l.key}, l.token).Err()
+}
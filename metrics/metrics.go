@@ -31,7 +31,17 @@ var (
 	DBConnectionsOpen     prometheus.Gauge
 	CacheHit              *prometheus.CounterVec
 	CacheMiss             *prometheus.CounterVec
+	CacheHitRatio         *prometheus.GaugeVec
 	QueueSize             prometheus.Gauge
+
+	AuditSinkEvents        *prometheus.CounterVec
+	AuditSinkQueueSize     *prometheus.GaugeVec
+	AuditSinkWriteDuration *prometheus.HistogramVec
+
+	CircuitBreakerState *prometheus.GaugeVec
+	ConcurrencyLimit    prometheus.Gauge
+	ConcurrencyInflight prometheus.Gauge
+	LoadShedTotal       *prometheus.CounterVec
 )
 
 func Init() error {
@@ -143,6 +153,12 @@ func Init() error {
 		Name:      "cache_misses_total",
 		Help:      "Total number of cache misses",
 	}, []string{"phase", "table"})
+	CacheHitRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "cache_hit_ratio",
+		Help:      "Cache hit ratio in percent by cache and tier",
+	}, []string{"cache", "tier"})
 	QueueSize = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace: NAMESPACE,
 		Subsystem: SUBSYSTEM,
@@ -150,7 +166,52 @@ func Init() error {
 		Help:      "Current size of the task queue",
 	})
 
-	errs := make([]error, 0, 18)
+	AuditSinkEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "audit_sink_events_total",
+		Help:      "Total number of audit events by sink and result (delivered, failed, dropped)",
+	}, []string{"sink", "result"})
+	AuditSinkQueueSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "audit_sink_queue_size",
+		Help:      "Current number of buffered audit events by sink",
+	}, []string{"sink"})
+	AuditSinkWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "audit_sink_write_duration_seconds",
+		Help:      "Audit sink write latencies in seconds",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"sink"})
+
+	CircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state by route (0 closed, 1 half-open, 2 open)",
+	}, []string{"route"})
+	ConcurrencyLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "concurrency_limit",
+		Help:      "Current adaptive concurrency limit of the load shedding",
+	})
+	ConcurrencyInflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "concurrency_inflight",
+		Help:      "Current number of requests admitted by the load shedding",
+	})
+	LoadShedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: SUBSYSTEM,
+		Name:      "load_shed_total",
+		Help:      "Total number of requests shed by priority",
+	}, []string{"priority"})
+
+	errs := make([]error, 0, 26)
 	errs = append(errs, prometheus.Register(State))
 	errs = append(errs, prometheus.Register(Uptime))
 	errs = append(errs, prometheus.Register(HTTPRequestsTotal))
@@ -166,7 +227,15 @@ func Init() error {
 	errs = append(errs, prometheus.Register(DBConnectionsOpen))
 	errs = append(errs, prometheus.Register(CacheHit))
 	errs = append(errs, prometheus.Register(CacheMiss))
+	errs = append(errs, prometheus.Register(CacheHitRatio))
 	errs = append(errs, prometheus.Register(QueueSize))
+	errs = append(errs, prometheus.Register(AuditSinkEvents))
+	errs = append(errs, prometheus.Register(AuditSinkQueueSize))
+	errs = append(errs, prometheus.Register(AuditSinkWriteDuration))
+	errs = append(errs, prometheus.Register(CircuitBreakerState))
+	errs = append(errs, prometheus.Register(ConcurrencyLimit))
+	errs = append(errs, prometheus.Register(ConcurrencyInflight))
+	errs = append(errs, prometheus.Register(LoadShedTotal))
 
 	errs = append(errs, prometheus.Register(collectors.NewBuildInfoCollector()))
 	errs = append(errs, prometheus.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: NAMESPACE})))
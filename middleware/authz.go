@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"time"
+
 	"github.com/forbearing/gst/authz/rbac"
 	"github.com/forbearing/gst/logger"
 	. "github.com/forbearing/gst/response"
@@ -11,6 +13,12 @@ import (
 
 // Authz authorizes requests using RBAC.
 // It derives subject from context or headers, falling back to system user.
+//
+// When tenant RBAC is enabled, the request is enforced within its tenant,
+// see resolveTenant, and the tenant is stored in context key "tenant_id".
+//
+// Conditional policies are evaluated against the request time, client ip,
+// headers and the owner of the requested resource, see rbac.Context.
 func Authz() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var allow bool
@@ -37,12 +45,31 @@ func Authz() gin.HandlerFunc {
 		if len(sub) == 0 {
 			sub = consts.AUTHZ_USER_BLOCKED
 		}
-		// When RBAC is disabled, Enforcer is nil; skip enforcement and allow the request.
-		if rbac.Enforcer == nil {
-			c.Next()
-			return
+		var tenant string
+		if rbac.TenantEnforcer != nil {
+			var ok bool
+			if tenant, ok = resolveTenant(c, sub); !ok {
+				JSON(c, CodeForbidden)
+				c.Abort()
+				logger.Authz.Infoz(
+					"tenant not allowed",
+					zap.String("tenant", tenant),
+					zap.String("sub", sub),
+					zap.String("eft", string(consts.EffectDeny)),
+				)
+				return
+			}
+			c.Set(consts.CTX_TENANT_ID, tenant)
+			allow, err = rbac.TenantEnforcer.Enforce(tenant, sub, obj, act, authzContext(c))
+		} else {
+			// When RBAC is disabled, Enforcer is nil; skip enforcement and allow the request.
+			if rbac.Enforcer == nil {
+				c.Next()
+				return
+			}
+			allow, err = rbac.Enforcer.Enforce(sub, obj, act, authzContext(c))
 		}
-		if allow, err = rbac.Enforcer.Enforce(sub, obj, act); err != nil {
+		if err != nil {
 			zap.S().Error(err)
 			JSON(c, CodeFailure)
 			c.Abort()
@@ -52,6 +79,7 @@ func Authz() gin.HandlerFunc {
 			c.Next()
 			logger.Authz.Infoz(
 				"",
+				zap.String("tenant", tenant),
 				zap.String("sub", sub),
 				zap.String("obj", obj),
 				zap.String("act", act),
@@ -64,6 +92,7 @@ func Authz() gin.HandlerFunc {
 			c.Abort()
 			logger.Authz.Infoz(
 				"",
+				zap.String("tenant", tenant),
 				zap.String("sub", sub),
 				zap.String("obj", obj),
 				zap.String("act", act),
@@ -75,3 +104,29 @@ func Authz() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// authzContext returns the context conditional policies are evaluated against.
+// The resource id is the last route parameter, eg: ":id" of "/api/users/:id".
+func authzContext(c *gin.Context) *rbac.Context {
+	ctx := rbac.NewContext(c.Request.Context(), time.Now(), c.ClientIP(), c.Request.Header)
+	ctx.Route = c.FullPath()
+	if len(c.Params) > 0 {
+		ctx.ResourceID = c.Params[len(c.Params)-1].Value
+	}
+	return ctx
+}
+
+// resolveTenant returns the tenant the request acts in. The "X-Tenant-ID" header
+// selects one of the tenants the subject has grants in, otherwise the tenant of
+// the login session is used, falling back to the default tenant.
+// The header can not widen access: it's refused unless the subject holds a role in the tenant
+// or is a super admin, and the wildcard tenant "*" is always refused.
+func resolveTenant(c *gin.Context, sub string) (string, bool) {
+	if h := c.GetHeader(consts.HEADER_TENANT_ID); len(h) > 0 {
+		return h, rbac.InTenant(sub, h)
+	}
+	if t := c.GetString(consts.CTX_TENANT_ID); len(t) > 0 {
+		return t, t != consts.AUTHZ_TENANT_ALL
+	}
+	return consts.AUTHZ_TENANT_DEFAULT, true
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/casbin/v3"
+	"github.com/casbin/casbin/v3/model"
+	"github.com/forbearing/gst/authz/rbac"
+	"github.com/forbearing/gst/logger"
+	pkgzap "github.com/forbearing/gst/logger/zap"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantModel is the tenant model of authz/rbac/tenant without the conditions.
+const tenantModel = `
+[request_definition]
+r = tenant, sub, obj, act, ctx
+
+[policy_definition]
+p = tenant, sub, obj, act, eft, cond
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, "super_admin", "*") || \
+    g(r.sub, "admin", r.tenant) || \
+    (g(r.sub, p.sub, r.tenant) && (p.tenant == r.tenant || p.tenant == "*") && keyMatch3(r.obj, p.obj) && r.act == p.act)
+`
+
+func TestAuthzTenantHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, err := model.NewModelFromString(tenantModel)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m)
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("root", consts.AUTHZ_ROLE_SUPER_ADMIN, consts.AUTHZ_TENANT_ALL)
+	require.NoError(t, err)
+	_, err = e.AddRoleForUserInDomain("alice", "admin", "t1")
+	require.NoError(t, err)
+
+	prev, prevLogger := rbac.TenantEnforcer, logger.Authz
+	rbac.TenantEnforcer, logger.Authz = e, pkgzap.New("/dev/null")
+	t.Cleanup(func() { rbac.TenantEnforcer, logger.Authz = prev, prevLogger })
+
+	var tenant string
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(consts.CTX_USERNAME, c.GetHeader("X-Test-User"))
+		c.Set(consts.CTX_USER_ID, c.GetHeader("X-Test-User"))
+		c.Set(consts.CTX_TENANT_ID, "t1")
+	}, Authz())
+	router.GET("/api/docs", func(c *gin.Context) {
+		tenant = c.GetString(consts.CTX_TENANT_ID)
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		user, header string
+		code         int
+		tenant       string
+	}{
+		{"alice", "", http.StatusOK, "t1"},                           // the session tenant
+		{"alice", "t1", http.StatusOK, "t1"},                         // a tenant the user has grants in
+		{"alice", "t2", http.StatusForbidden, ""},                    // a foreign tenant
+		{"alice", consts.AUTHZ_TENANT_ALL, http.StatusForbidden, ""}, // the wildcard tenant
+		{"root", "t2", http.StatusOK, "t2"},                          // super admins act in every tenant
+		{"root", consts.AUTHZ_TENANT_ALL, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		tenant = ""
+		req := httptest.NewRequest(http.MethodGet, "/api/docs", nil)
+		req.Header.Set("X-Test-User", tt.user)
+		if len(tt.header) > 0 {
+			req.Header.Set(consts.HEADER_TENANT_ID, tt.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, tt.code, w.Code, "user %s header %q", tt.user, tt.header)
+		require.Equal(t, tt.tenant, tenant, "user %s header %q", tt.user, tt.header)
+	}
+}
@@ -3,17 +3,34 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	prommetrics "github.com/forbearing/gst/metrics"
 	"github.com/gin-gonic/gin"
+	"github.com/sony/gobreaker"
 	"go.uber.org/zap"
 )
 
+// CircuitBreaker trips the breaker of a route when its failure rate exceeds the threshold,
+// every route template, eg: "GET /api/iam/users/:id", has its own breaker so that
+// one failing endpoint doesn't reject the requests of the others.
+// The settings come from config.Server.CircuitBreaker, Routes overrides them per route.
+// Requests not matching any route are not limited.
 func CircuitBreaker() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get request info for better logging
 		path := c.Request.URL.Path
 		method := c.Request.Method
 
+		cb := breakers.get(method, c.FullPath())
+		if cb == nil {
+			c.Next()
+			return
+		}
+
 		if _, err := cb.Execute(func() (any, error) {
 			c.Next()
 
@@ -40,6 +57,7 @@ func CircuitBreaker() gin.HandlerFunc {
 			zap.S().Errorw(
 				"circuit breaker error",
 				"error", err.Error(),
+				"breaker", cb.Name(),
 				"path", path,
 				"method", method,
 			)
@@ -51,3 +69,163 @@ func CircuitBreaker() gin.HandlerFunc {
 		}
 	}
 }
+
+var breakers = new(breakerRegistry)
+
+// breakerRegistry creates the circuit breakers of the routes on their first request.
+type breakerRegistry struct {
+	mu       sync.RWMutex
+	cfg      config.CircuitBreaker
+	enabled  bool
+	breakers map[string]*gobreaker.CircuitBreaker // nil value means the breaker of the route is disabled
+}
+
+// init validates the configuration and resets the breakers.
+func (r *breakerRegistry) init(cfg config.CircuitBreaker) error {
+	if err := validateBreaker(cfg.Name, cfg.MaxRequests, cfg.MinRequests, cfg.FailureRate); err != nil {
+		return err
+	}
+	for _, route := range cfg.Routes {
+		if len(strings.TrimSpace(route.Route)) == 0 {
+			return errors.New("circuit breaker route cannot be empty")
+		}
+		if route.Disable {
+			continue
+		}
+		// Zero values inherit the global settings.
+		if err := validateBreaker(route.Route,
+			fallback(route.MaxRequests, cfg.MaxRequests),
+			fallback(route.MinRequests, cfg.MinRequests),
+			fallback(route.FailureRate, cfg.FailureRate),
+		); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	r.enabled = cfg.Enable
+	r.breakers = make(map[string]*gobreaker.CircuitBreaker)
+	return nil
+}
+
+func validateBreaker(name string, maxRequests, minRequests uint32, failureRate float64) error {
+	if maxRequests == 0 {
+		return errors.Newf("circuit breaker %q max_requests cannot be 0", name)
+	}
+	if minRequests == 0 {
+		return errors.Newf("circuit breaker %q min_requests cannot be 0", name)
+	}
+	if failureRate <= 0 || failureRate > 1 {
+		return errors.Newf("circuit breaker %q failure_rate must be between 0 and 1", name)
+	}
+	return nil
+}
+
+// get returns the breaker of the route, nil if the breakers are disabled
+// or the request doesn't match any route.
+func (r *breakerRegistry) get(method, route string) *gobreaker.CircuitBreaker {
+	if len(route) == 0 {
+		return nil
+	}
+	key := method + " " + route
+
+	r.mu.RLock()
+	if !r.enabled {
+		r.mu.RUnlock()
+		return nil
+	}
+	cb, ok := r.breakers[key]
+	r.mu.RUnlock()
+	if ok {
+		return cb
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cb, ok = r.breakers[key]; ok {
+		return cb
+	}
+	settings, enabled := r.settings(method, route)
+	if enabled {
+		cb = gobreaker.NewCircuitBreaker(settings)
+		if prommetrics.CircuitBreakerState != nil {
+			prommetrics.CircuitBreakerState.WithLabelValues(key).Set(float64(gobreaker.StateClosed))
+		}
+	}
+	r.breakers[key] = cb
+	return cb
+}
+
+// settings merges the global settings with the override of the route,
+// the override with method takes precedence over the one without.
+func (r *breakerRegistry) settings(method, route string) (gobreaker.Settings, bool) {
+	cfg := r.cfg
+	var override *config.CircuitBreakerRoute
+	for i := range cfg.Routes {
+		m, p := splitRoute(cfg.Routes[i].Route)
+		if p != route || (len(m) > 0 && m != method) {
+			continue
+		}
+		if override == nil || len(m) > 0 {
+			override = &cfg.Routes[i]
+		}
+	}
+
+	maxRequests, minRequests, failureRate := cfg.MaxRequests, cfg.MinRequests, cfg.FailureRate
+	interval, timeout := cfg.Interval, cfg.Timeout
+	if override != nil {
+		if override.Disable {
+			return gobreaker.Settings{}, false
+		}
+		maxRequests = fallback(override.MaxRequests, maxRequests)
+		minRequests = fallback(override.MinRequests, minRequests)
+		failureRate = fallback(override.FailureRate, failureRate)
+		interval = fallback(override.Interval, interval)
+		timeout = fallback(override.Timeout, timeout)
+	}
+
+	return gobreaker.Settings{
+		Name:        method + " " + route,
+		MaxRequests: maxRequests,
+		Interval:    interval,
+		Timeout:     timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if counts.Requests < minRequests {
+				return false
+			}
+			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
+			return failureRatio >= failureRate
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			zap.S().Infow(
+				"circuit breaker state changed",
+				"name", name,
+				"from", from.String(),
+				"to", to.String(),
+			)
+			if prommetrics.CircuitBreakerState != nil {
+				prommetrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+			}
+		},
+	}, true
+}
+
+// splitRoute splits "GET /api/users" into the method and the route template,
+// the method is empty if the route has no method prefix.
+func splitRoute(s string) (method, route string) {
+	s = strings.TrimSpace(s)
+	if m, r, ok := strings.Cut(s, " "); ok {
+		return strings.ToUpper(m), strings.TrimSpace(r)
+	}
+	return "", s
+}
+
+func fallback[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerPerRoute(t *testing.T) {
+	require.NoError(t, breakers.init(config.CircuitBreaker{
+		Enable:      true,
+		MaxRequests: 1,
+		Interval:    time.Minute,
+		Timeout:     time.Minute,
+		FailureRate: 0.5,
+		MinRequests: 2,
+		Routes: []config.CircuitBreakerRoute{
+			{Route: "/flaky", MinRequests: 4},
+			{Route: "GET /ignored", Disable: true},
+		},
+	}))
+	t.Cleanup(func() { _ = breakers.init(config.CircuitBreaker{MaxRequests: 1, MinRequests: 1, FailureRate: 1}) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CircuitBreaker())
+	fail := func(c *gin.Context) { c.String(http.StatusInternalServerError, "failed") }
+	r.GET("/broken", fail)
+	r.GET("/flaky", fail)
+	r.GET("/ignored", fail)
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// The breaker of /broken trips after min_requests failures.
+	require.Equal(t, http.StatusInternalServerError, do("/broken"))
+	require.Equal(t, http.StatusInternalServerError, do("/broken"))
+	require.Equal(t, http.StatusServiceUnavailable, do("/broken"))
+
+	// The other routes have their own breakers.
+	require.Equal(t, http.StatusOK, do("/ok"))
+
+	// The override of /flaky trips after 4 failures.
+	for range 4 {
+		require.Equal(t, http.StatusInternalServerError, do("/flaky"))
+	}
+	require.Equal(t, http.StatusServiceUnavailable, do("/flaky"))
+
+	// The breaker of /ignored is disabled.
+	for range 5 {
+		require.Equal(t, http.StatusInternalServerError, do("/ignored"))
+	}
+
+	// Unknown routes are not limited.
+	require.Equal(t, http.StatusNotFound, do("/unknown"))
+}
+
+func TestCircuitBreakerInit(t *testing.T) {
+	cfg := config.CircuitBreaker{MaxRequests: 1, MinRequests: 1, FailureRate: 0.5}
+	require.NoError(t, new(breakerRegistry).init(cfg))
+
+	cfg.Routes = []config.CircuitBreakerRoute{{Route: "/api/x", FailureRate: 2}}
+	require.Error(t, new(breakerRegistry).init(cfg))
+
+	cfg.Routes = []config.CircuitBreakerRoute{{Route: " "}}
+	require.Error(t, new(breakerRegistry).init(cfg))
+}
+
+func TestBreakerSettings(t *testing.T) {
+	r := &breakerRegistry{cfg: config.CircuitBreaker{
+		MaxRequests: 10, MinRequests: 10, FailureRate: 0.5, Timeout: time.Second,
+		Routes: []config.CircuitBreakerRoute{
+			{Route: "/api/users", Timeout: time.Minute},
+			{Route: "post /api/users", Timeout: time.Hour},
+		},
+	}}
+
+	s, ok := r.settings(http.MethodPost, "/api/users")
+	require.True(t, ok)
+	require.Equal(t, time.Hour, s.Timeout)
+	require.Equal(t, uint32(10), s.MaxRequests)
+	require.Equal(t, "POST /api/users", s.Name)
+
+	s, _ = r.settings(http.MethodGet, "/api/users")
+	require.Equal(t, time.Minute, s.Timeout)
+
+	s, _ = r.settings(http.MethodGet, "/api/groups")
+	require.Equal(t, time.Second, s.Timeout)
+}
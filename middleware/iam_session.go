@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
 	"github.com/forbearing/gst/provider/redis"
@@ -33,6 +34,25 @@ func mustChangePasswordExempt(method, path string) bool {
 	}
 }
 
+// impersonationBlocked reports whether the route is forbidden while an administrator impersonates a user:
+// credential and 2FA management, email change and nested impersonation.
+func impersonationBlocked(method, path string) bool {
+	switch {
+	case method == http.MethodPost && path == "/api/iam/change-password":
+		return true
+	case method == http.MethodPost && path == "/api/iam/reset-password":
+		return true
+	case method == http.MethodPost && strings.HasPrefix(path, "/api/iam/email/change-"):
+		return true
+	case method != http.MethodGet && strings.HasPrefix(path, "/api/2fa/"):
+		return true
+	case method == http.MethodPost && path == "/api/iam/impersonation":
+		return true
+	default:
+		return false
+	}
+}
+
 func IAMSession() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// fmt.Println("----- identifySession middleware", c.Request.RequestURI)
@@ -75,8 +95,25 @@ func IAMSession() gin.HandlerFunc {
 			return
 		}
 
+		if session.Impersonator != nil && impersonationBlocked(c.Request.Method, c.Request.URL.Path) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "not allowed while impersonating",
+			})
+			return
+		}
+
 		c.Set(consts.CTX_USER_ID, session.UserID)
 		c.Set(consts.CTX_USERNAME, session.Username)
+		if session.Impersonator != nil {
+			c.Set(consts.CTX_REAL_USER_ID, session.Impersonator.UserID)
+			c.Set(consts.CTX_REAL_USERNAME, session.Impersonator.Username)
+		} else {
+			c.Set(consts.CTX_REAL_USER_ID, session.UserID)
+			c.Set(consts.CTX_REAL_USERNAME, session.Username)
+		}
+		if len(session.TenantID) > 0 {
+			c.Set(consts.CTX_TENANT_ID, session.TenantID)
+		}
 		c.Next()
 	}
 }
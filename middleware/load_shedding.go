@@ -0,0 +1,302 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/config"
+	prommetrics "github.com/forbearing/gst/metrics"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	LoadSheddingGradient = "gradient"
+	LoadSheddingAIMD     = "aimd"
+)
+
+// Priority is the priority class of a request under load shedding.
+type Priority int
+
+const (
+	// PriorityLow requests are shed first, once the inflight requests exceed
+	// LowPriorityRatio of the limit.
+	PriorityLow Priority = iota
+	// PriorityNormal requests are shed once the inflight requests reach the limit.
+	PriorityNormal
+	// PriorityCritical requests are never shed, eg: health checks and authentication.
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// LoadShedding limits the concurrent requests with a limit adapted to the latency,
+// the requests above the limit are rejected with 503 before the latency explodes.
+// The settings come from config.Server.LoadShedding, the middleware does nothing if disabled.
+//
+// The limit grows while the latency is stable and shrinks when it increases,
+// "gradient" compares the latency of the recent requests with the long-term latency,
+// "aimd" decreases the limit when a request is slower than Latency or fails.
+func LoadShedding() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := shedder.get()
+		if l == nil {
+			c.Next()
+			return
+		}
+
+		priority := l.priority(c.Request.Method, c.FullPath())
+		if !l.acquire(priority) {
+			if prommetrics.LoadShedTotal != nil {
+				prommetrics.LoadShedTotal.WithLabelValues(priority.String()).Inc()
+			}
+			zap.S().Warnw("request shed",
+				"path", c.Request.URL.Path,
+				"method", c.Request.Method,
+				"priority", priority.String(),
+				"limit", l.currentLimit(),
+			)
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":  http.StatusText(http.StatusServiceUnavailable),
+				"detail": "server overloaded, limit " + strconv.Itoa(l.currentLimit()),
+			})
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			l.release(time.Since(start), c.Writer.Status() >= 500)
+		}()
+		c.Next()
+	}
+}
+
+var shedder = new(shedderHolder)
+
+// shedderHolder holds the limiter built from the configuration by Init.
+type shedderHolder struct {
+	mu sync.RWMutex
+	l  *limiter
+}
+
+func (h *shedderHolder) init(cfg config.LoadShedding) error {
+	var l *limiter
+	if cfg.Enable {
+		var err error
+		if l, err = newLimiter(cfg); err != nil {
+			return err
+		}
+		zap.S().Infow("load shedding initialized",
+			"algorithm", l.algorithm,
+			"initial_limit", cfg.InitialLimit,
+			"min_limit", l.minLimit,
+			"max_limit", l.maxLimit,
+		)
+	}
+	h.mu.Lock()
+	h.l = l
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *shedderHolder) get() *limiter {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.l
+}
+
+const (
+	// gradientSmoothing is the weight of the new limit of gradient.
+	gradientSmoothing = 0.2
+	// longRTTWindow is the number of the requests averaged by the long-term latency of gradient.
+	longRTTWindow = 600
+	// aimdBackoff is the ratio of the limit kept by aimd on a slow or failed request.
+	aimdBackoff = 0.9
+)
+
+// limiter is an adaptive concurrency limiter.
+type limiter struct {
+	mu sync.Mutex
+
+	algorithm string
+	limit     float64
+	minLimit  float64
+	maxLimit  float64
+	lowRatio  float64
+	latency   time.Duration
+	longRTT   float64 // nanoseconds
+	inflight  int
+
+	critical []string
+	low      []string
+}
+
+func newLimiter(cfg config.LoadShedding) (*limiter, error) {
+	l := &limiter{
+		algorithm: strings.ToLower(cfg.Algorithm),
+		limit:     float64(cfg.InitialLimit),
+		minLimit:  float64(cfg.MinLimit),
+		maxLimit:  float64(cfg.MaxLimit),
+		lowRatio:  cfg.LowPriorityRatio,
+		latency:   cfg.Latency,
+		critical:  cfg.CriticalRoutes,
+		low:       cfg.LowPriorityRoutes,
+	}
+	if len(l.algorithm) == 0 {
+		l.algorithm = LoadSheddingGradient
+	}
+	if l.algorithm != LoadSheddingGradient && l.algorithm != LoadSheddingAIMD {
+		return nil, errors.Newf("load shedding algorithm must be %q or %q, got %q", LoadSheddingGradient, LoadSheddingAIMD, cfg.Algorithm)
+	}
+	if l.minLimit < 1 {
+		l.minLimit = 1
+	}
+	if l.maxLimit < l.minLimit {
+		return nil, errors.New("load shedding max_limit must not be less than min_limit")
+	}
+	if l.limit <= 0 {
+		l.limit = l.minLimit
+	}
+	l.limit = min(max(l.limit, l.minLimit), l.maxLimit)
+	if l.lowRatio <= 0 || l.lowRatio > 1 {
+		return nil, errors.New("load shedding low_priority_ratio must be between 0 and 1")
+	}
+	if l.algorithm == LoadSheddingAIMD && l.latency <= 0 {
+		return nil, errors.New("load shedding latency must be positive for aimd")
+	}
+	if prommetrics.ConcurrencyLimit != nil {
+		prommetrics.ConcurrencyLimit.Set(l.limit)
+	}
+	return l, nil
+}
+
+// priority returns the priority class of the route, the critical routes take precedence.
+func (l *limiter) priority(method, route string) Priority {
+	if matchRoutes(l.critical, method, route) {
+		return PriorityCritical
+	}
+	if matchRoutes(l.low, method, route) {
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// matchRoutes reports whether the route matches any of the patterns,
+// the patterns are route templates with optional method prefix and trailing "*".
+func matchRoutes(patterns []string, method, route string) bool {
+	for _, pattern := range patterns {
+		m, p := splitRoute(pattern)
+		if len(m) > 0 && m != method {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if p == route {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire admits the request if the inflight requests are below the limit of its priority.
+func (l *limiter) acquire(p Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch p {
+	case PriorityNormal:
+		if float64(l.inflight) >= l.limit {
+			return false
+		}
+	case PriorityLow:
+		if float64(l.inflight) >= l.limit*l.lowRatio {
+			return false
+		}
+	}
+	l.inflight++
+	if prommetrics.ConcurrencyInflight != nil {
+		prommetrics.ConcurrencyInflight.Set(float64(l.inflight))
+	}
+	return true
+}
+
+// release finishes an admitted request and adapts the limit to its latency.
+func (l *limiter) release(rtt time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inflight := l.inflight
+	l.inflight--
+	if prommetrics.ConcurrencyInflight != nil {
+		prommetrics.ConcurrencyInflight.Set(float64(l.inflight))
+	}
+
+	switch l.algorithm {
+	case LoadSheddingAIMD:
+		l.aimd(rtt, failed, inflight)
+	default:
+		l.gradient(rtt, inflight)
+	}
+	if prommetrics.ConcurrencyLimit != nil {
+		prommetrics.ConcurrencyLimit.Set(l.limit)
+	}
+}
+
+// gradient adapts the limit by the ratio of the long-term latency to the latency of the request,
+// the limit grows by a queue of sqrt(limit) while the latency is stable.
+func (l *limiter) gradient(rtt time.Duration, inflight int) {
+	short := float64(max(rtt, time.Microsecond))
+	if l.longRTT == 0 {
+		l.longRTT = short
+	} else {
+		l.longRTT += (short - l.longRTT) / longRTTWindow
+	}
+	// Recover faster from a long-term latency inflated by a previous overload.
+	if l.longRTT/short > 2 {
+		l.longRTT *= 0.95
+	}
+	// The limit is not reached, the latency says nothing about it.
+	if float64(inflight) < l.limit/2 {
+		return
+	}
+
+	grad := min(max(l.longRTT/short, 0.5), 1)
+	newLimit := l.limit*grad + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-gradientSmoothing) + newLimit*gradientSmoothing
+	l.limit = min(max(newLimit, l.minLimit), l.maxLimit)
+}
+
+// aimd decreases the limit multiplicatively on a slow or failed request,
+// and increases it by one while the limit is used.
+func (l *limiter) aimd(rtt time.Duration, failed bool, inflight int) {
+	if failed || rtt > l.latency {
+		l.limit = max(l.limit*aimdBackoff, l.minLimit)
+		return
+	}
+	if float64(inflight)*2 >= l.limit {
+		l.limit = min(l.limit+1, l.maxLimit)
+	}
+}
+
+func (l *limiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterPriority(t *testing.T) {
+	l, err := newLimiter(config.LoadShedding{
+		InitialLimit:      10,
+		MinLimit:          1,
+		MaxLimit:          100,
+		LowPriorityRatio:  0.5,
+		CriticalRoutes:    []string{"/-/healthz", "/api/2fa/*"},
+		LowPriorityRoutes: []string{"GET /api/reports/*"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, PriorityCritical, l.priority(http.MethodGet, "/-/healthz"))
+	require.Equal(t, PriorityCritical, l.priority(http.MethodPost, "/api/2fa/totp/check"))
+	require.Equal(t, PriorityLow, l.priority(http.MethodGet, "/api/reports/:id"))
+	require.Equal(t, PriorityNormal, l.priority(http.MethodPost, "/api/reports/:id"))
+	require.Equal(t, PriorityNormal, l.priority(http.MethodGet, "/api/users"))
+
+	for range 5 {
+		require.True(t, l.acquire(PriorityLow))
+	}
+	// Low priority requests are shed above half of the limit.
+	require.False(t, l.acquire(PriorityLow))
+	for range 5 {
+		require.True(t, l.acquire(PriorityNormal))
+	}
+	require.False(t, l.acquire(PriorityNormal))
+	// Critical requests are never shed.
+	require.True(t, l.acquire(PriorityCritical))
+}
+
+func TestLimiterGradient(t *testing.T) {
+	l, err := newLimiter(config.LoadShedding{InitialLimit: 20, MinLimit: 5, MaxLimit: 40, LowPriorityRatio: 1})
+	require.NoError(t, err)
+
+	// The limit grows while the latency is stable and the limit is used.
+	for range 50 {
+		l.inflight = int(l.limit)
+		l.release(10*time.Millisecond, false)
+	}
+	require.InDelta(t, 40, l.limit, 0.001)
+
+	// The limit shrinks when the latency increases.
+	for range 50 {
+		l.inflight = int(l.limit)
+		l.release(100*time.Millisecond, false)
+	}
+	require.Less(t, l.limit, float64(20))
+	require.GreaterOrEqual(t, l.limit, float64(5))
+
+	// The limit is kept while it's not used.
+	before := l.limit
+	l.inflight = 1
+	l.release(time.Second, false)
+	require.InDelta(t, before, l.limit, 0.001)
+}
+
+func TestLimiterAIMD(t *testing.T) {
+	l, err := newLimiter(config.LoadShedding{Algorithm: "aimd", InitialLimit: 10, MinLimit: 5, MaxLimit: 12, LowPriorityRatio: 1, Latency: 100 * time.Millisecond})
+	require.NoError(t, err)
+
+	l.inflight = 10
+	l.release(10*time.Millisecond, false)
+	require.InDelta(t, 11, l.limit, 0.001)
+
+	l.inflight = 10
+	l.release(time.Second, false)
+	require.InDelta(t, 9.9, l.limit, 0.001)
+
+	l.inflight = 10
+	l.release(10*time.Millisecond, true)
+	require.InDelta(t, 8.91, l.limit, 0.001)
+
+	for range 20 {
+		l.inflight = 1
+		l.release(time.Second, true)
+	}
+	require.InDelta(t, 5, l.limit, 0.001)
+
+	_, err = newLimiter(config.LoadShedding{Algorithm: "aimd", LowPriorityRatio: 1})
+	require.Error(t, err)
+	_, err = newLimiter(config.LoadShedding{Algorithm: "vegas", LowPriorityRatio: 1})
+	require.Error(t, err)
+}
+
+func TestLoadShedding(t *testing.T) {
+	require.NoError(t, shedder.init(config.LoadShedding{
+		Enable:           true,
+		Algorithm:        "aimd",
+		InitialLimit:     1,
+		MinLimit:         1,
+		MaxLimit:         1,
+		Latency:          time.Minute,
+		LowPriorityRatio: 1,
+		CriticalRoutes:   []string{"/-/healthz"},
+	}))
+	t.Cleanup(func() { _ = shedder.init(config.LoadShedding{}) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(LoadShedding())
+	started, done := make(chan struct{}), make(chan struct{})
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-done
+		c.Status(http.StatusOK)
+	})
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/-/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() { require.Equal(t, http.StatusOK, do("/slow").Code) })
+	<-started
+
+	w := do("/ok")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, do("/-/healthz").Code)
+
+	close(done)
+	wg.Wait()
+	require.Equal(t, http.StatusOK, do("/ok").Code)
+}
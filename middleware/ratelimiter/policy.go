@@ -0,0 +1,98 @@
+package ratelimiter
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+)
+
+// Algorithm is the rate limiting algorithm of a Policy.
+type Algorithm string
+
+const (
+	// GCRA is the generic cell rate algorithm, a token bucket that stores one timestamp per key.
+	// Requests are spread evenly over the period after a burst of Policy.Burst requests.
+	GCRA Algorithm = "gcra"
+
+	// SlidingWindow counts the requests of the current and the previous window of Policy.Period,
+	// weighting the previous window by its overlap with the sliding window.
+	// It suits quotas such as 10000 requests per day, bursts are only limited by Policy.Limit.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// Common quota periods of Policy.Period.
+const (
+	PerSecond = time.Second
+	PerMinute = time.Minute
+	PerHour   = time.Hour
+	PerDay    = 24 * time.Hour
+)
+
+// Policy is a named rate limit, a request is allowed only if all policies of the middleware allow it.
+// Every policy limits the subjects returned by its KeyFunc separately.
+type Policy struct {
+	// Name identifies the policy in the store keys, it must be unique among the policies sharing a store.
+	Name string
+
+	// Limit is the number of requests allowed per Period.
+	Limit int
+
+	// Period is the period of Limit, default is one second, eg: PerMinute, PerDay.
+	Period time.Duration
+
+	// Burst is the number of requests allowed at once by GCRA, default is Limit.
+	Burst int
+
+	// Algorithm defaults to GCRA.
+	Algorithm Algorithm
+
+	// KeyFunc returns the subject of the request, eg: KeyByIP, KeyByUser, KeyByAPIKey, KeyByTenant.
+	// The policy is skipped if it returns an empty key, eg: KeyByUser for anonymous requests.
+	// Defaults to KeyByIP.
+	KeyFunc func(*gin.Context) string
+}
+
+// normalize returns the policy with the defaults applied.
+func (p Policy) normalize() Policy {
+	if p.Period <= 0 {
+		p.Period = time.Second
+	}
+	if p.Limit <= 0 {
+		p.Limit = 1
+	}
+	if p.Burst <= 0 {
+		p.Burst = p.Limit
+	}
+	if len(p.Algorithm) == 0 {
+		p.Algorithm = GCRA
+	}
+	if p.KeyFunc == nil {
+		p.KeyFunc = KeyByIP
+	}
+	return p
+}
+
+// emission is the interval between two requests of GCRA, at least one nanosecond
+// so that a Limit above the nanoseconds of Period doesn't divide by zero.
+func (p Policy) emission() time.Duration {
+	return max(p.Period/time.Duration(p.Limit), time.Nanosecond)
+}
+
+// KeyByIP limits every client ip.
+func KeyByIP(c *gin.Context) string { return c.ClientIP() }
+
+// KeyByUser limits every authenticated user, the middleware must run after the authentication.
+func KeyByUser(c *gin.Context) string { return c.GetString(consts.CTX_USER_ID) }
+
+// KeyByTenant limits every tenant, the middleware must run after the authentication.
+func KeyByTenant(c *gin.Context) string { return c.GetString(consts.CTX_TENANT_ID) }
+
+// KeyByAPIKey limits every api key passed in the header, default header is "X-API-Key".
+func KeyByAPIKey(header ...string) func(*gin.Context) string {
+	h := "X-API-Key"
+	if len(header) > 0 && len(header[0]) > 0 {
+		h = header[0]
+	}
+	return func(c *gin.Context) string { return c.GetHeader(h) }
+}
@@ -1,46 +1,45 @@
 package ratelimiter
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
 	"time"
 
-	"github.com/cockroachdb/errors"
-	"github.com/forbearing/gst/cache/ristretto"
 	. "github.com/forbearing/gst/response"
-	"github.com/forbearing/gst/types"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
 
 const (
 	defaultRate  = rate.Limit(10) // 默认每秒允许 10 个请求
 	defaultBurst = 50             // 默认令牌桶容量
-	defaultTTL   = 24 * time.Hour // 默认限流器过期时间
-)
 
-func init() {
-	if err := ristretto.Init(); err != nil {
-		panic(err)
-	}
-}
-
-var ratelimiterMap = ristretto.Cache[*rate.Limiter]()
+	keyPrefix = "gst:ratelimit:"
+)
 
 // Config holds the configuration for the RateLimiter middleware.
 type Config struct {
-	// Rate is the number of requests allowed per second.
-	// Defaults to 10 req/s if not set or non-positive.
+	// Rate is the number of requests allowed per second of the default policy.
+	// Defaults to 10 req/s if not set or non-positive, rate.Inf disables the default policy.
+	// It's ignored if Policies is set.
 	Rate rate.Limit
 
-	// Burst is the maximum number of requests allowed to burst above the rate.
+	// Burst is the maximum number of requests allowed to burst above the rate of the default policy.
 	// Defaults to 50 if not set or non-positive.
+	// It's ignored if Policies is set.
 	Burst int
 
 	// TTL is the duration after which an idle rate limiter is evicted from the cache.
-	// Defaults to 24h if not set or non-positive.
+	//
+	// Deprecated: the state of a key expires once its limit is fully restored, TTL is ignored.
 	TTL time.Duration
 
-	// KeyFunc extracts a unique key from the request to identify the rate limit subject.
-	// Defaults to client IP if not set.
+	// KeyFunc extracts a unique key from the request to identify the rate limit subject of the default policy.
+	// Defaults to client IP if not set. It's ignored if Policies is set.
 	//
 	// Common examples:
 	//   c.ClientIP()                              per client IP (default)
@@ -50,6 +49,17 @@ type Config struct {
 	//   c.FullPath() + ":" + c.GetString("user_id")  per user per route
 	KeyFunc func(*gin.Context) string
 
+	// Policies are the named rate limits of the middleware, a request is allowed only if all policies allow it.
+	// A denied request consumes no quota of the other policies if the Store implements Refunder.
+	// Defaults to one GCRA policy named "default" built from Rate, Burst and KeyFunc.
+	// Middlewares of different route groups using the same policy name share the limit.
+	Policies []Policy
+
+	// Store keeps the state of the rate limits.
+	// Defaults to NewRedisStore, which limits the requests of all instances together
+	// and falls back to the local store if redis is disabled or unavailable.
+	Store Store
+
 	// OnLimitReached is called when the rate limit is exceeded.
 	// If set, it is responsible for writing the response; the default 429 response is skipped.
 	// Defaults to responding with CodeTooManyRequests if not set.
@@ -63,6 +73,9 @@ type Config struct {
 // RateLimiter returns a gin middleware that limits request rates per configurable key.
 // Use functional options (WithRate, WithBurst, WithKeyFunc, etc.) to customize behavior.
 //
+// Every response carries the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers of the most restrictive policy, rejected requests also carry Retry-After.
+//
 // Example:
 //
 //	r.Use(ratelimiter.RateLimiter(
@@ -71,6 +84,15 @@ type Config struct {
 //	    ratelimiter.WithKeyFunc(func(c *gin.Context) string { return c.ClientIP() }),
 //	    ratelimiter.WithSkipFunc(func(c *gin.Context) bool { return c.FullPath() == "/health" }),
 //	))
+//
+// Multiple policies, eg: 20 req/s per ip and a daily quota per user on an authenticated route group:
+//
+//	group.Use(ratelimiter.RateLimiter(
+//	    ratelimiter.WithPolicies(
+//	        ratelimiter.Policy{Name: "api-ip", Limit: 20, Period: ratelimiter.PerSecond, KeyFunc: ratelimiter.KeyByIP},
+//	        ratelimiter.Policy{Name: "api-user-daily", Limit: 10000, Period: ratelimiter.PerDay, Algorithm: ratelimiter.SlidingWindow, KeyFunc: ratelimiter.KeyByUser},
+//	    ),
+//	))
 func RateLimiter(opts ...Option) gin.HandlerFunc {
 	conf := new(Config)
 	for _, op := range opts {
@@ -90,8 +112,21 @@ func RateLimiter(opts ...Option) gin.HandlerFunc {
 			return c.ClientIP()
 		}
 	}
-	if conf.TTL <= 0 {
-		conf.TTL = defaultTTL
+	if len(conf.Policies) == 0 && conf.Rate != rate.Inf {
+		// Burst requests at once, refilled at Rate.
+		conf.Policies = []Policy{{
+			Name:    "default",
+			Limit:   conf.Burst,
+			Period:  max(time.Duration(float64(time.Second)*float64(conf.Burst)/float64(conf.Rate)), time.Nanosecond),
+			KeyFunc: conf.KeyFunc,
+		}}
+	}
+	policies := make([]Policy, 0, len(conf.Policies))
+	for _, p := range conf.Policies {
+		policies = append(policies, p.normalize())
+	}
+	if conf.Store == nil {
+		conf.Store = NewRedisStore()
 	}
 
 	return func(c *gin.Context) {
@@ -100,17 +135,42 @@ func RateLimiter(opts ...Option) gin.HandlerFunc {
 			return
 		}
 
-		key := conf.KeyFunc(c)
-		limiter, err := ratelimiterMap.Get(key)
-		if errors.Is(err, types.ErrEntryNotFound) {
-			limiter = rate.NewLimiter(conf.Rate, conf.Burst)
-			_ = ratelimiterMap.Set(key, limiter, conf.TTL)
-		} else if err != nil {
-			JSON(c, CodeFailure)
-			c.Abort()
+		var (
+			decisive *Result
+			policy   Policy
+			charges  = make([]charge, 0, len(policies))
+		)
+		for _, p := range policies {
+			subject := p.KeyFunc(c)
+			if len(subject) == 0 {
+				continue
+			}
+			key := storeKey(p.Name, subject)
+			res, err := conf.Store.Take(c.Request.Context(), key, p)
+			if err != nil {
+				refund(c.Request.Context(), conf.Store, charges)
+				JSON(c, CodeFailure)
+				c.Abort()
+				return
+			}
+			if decisive == nil || !res.Allowed || res.Remaining < decisive.Remaining {
+				decisive, policy = &res, p
+			}
+			if !res.Allowed {
+				// The request consumes no quota of the policies allowing it.
+				refund(c.Request.Context(), conf.Store, charges)
+				break
+			}
+			charges = append(charges, charge{key: key, policy: p})
+		}
+		if decisive == nil {
+			c.Next()
 			return
 		}
-		if !limiter.Allow() {
+
+		setHeaders(c, decisive, policy)
+		if !decisive.Allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(decisive.RetryAfter), 10))
 			if conf.OnLimitReached != nil {
 				conf.OnLimitReached(c)
 				c.Abort()
@@ -123,3 +183,39 @@ func RateLimiter(opts ...Option) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// charge is a request counted by a policy.
+type charge struct {
+	key    string
+	policy Policy
+}
+
+// refund returns the requests counted by the charges if the store supports it.
+func refund(ctx context.Context, store Store, charges []charge) {
+	r, ok := store.(Refunder)
+	if !ok {
+		return
+	}
+	for _, ch := range charges {
+		if err := r.Refund(ctx, ch.key, ch.policy); err != nil {
+			zap.S().Warnw("failed to refund the rate limit", "policy", ch.policy.Name, "error", err.Error())
+		}
+	}
+}
+
+func setHeaders(c *gin.Context, res *Result, p Policy) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(max(res.Remaining, 0)))
+	c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.Reset), 10))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Limit, ceilSeconds(p.Period)))
+}
+
+// storeKey hashes the subject, which may be a secret such as an api key.
+func storeKey(policy, subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return keyPrefix + policy + ":" + hex.EncodeToString(sum[:16])
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
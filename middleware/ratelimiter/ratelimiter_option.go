@@ -10,7 +10,7 @@ import (
 // Option is a functional option for configuring RateLimiterConfig.
 type Option func(*Config)
 
-// WithRate sets the number of requests allowed per second.
+// WithRate sets the number of requests allowed per second, rate.Inf disables the rate limit.
 // Non-positive values are ignored; the default (10 req/s) is used instead.
 func WithRate(r rate.Limit) Option {
 	return func(conf *Config) {
@@ -33,7 +33,8 @@ func WithBurst(burst int) Option {
 }
 
 // WithTTL sets the duration after which an idle rate limiter is evicted from cache.
-// Non-positive values are ignored; the default (24h) is used instead.
+//
+// Deprecated: the state of a key expires once its limit is fully restored, TTL is ignored.
 func WithTTL(ttl time.Duration) Option {
 	return func(conf *Config) {
 		if conf == nil || ttl <= 0 {
@@ -77,3 +78,25 @@ func WithOnLimitReached(onLimitReached gin.HandlerFunc) Option {
 		conf.OnLimitReached = onLimitReached
 	}
 }
+
+// WithPolicies sets the named rate limits, a request is allowed only if all policies allow it.
+// Rate, Burst and KeyFunc are ignored if policies are set.
+func WithPolicies(policies ...Policy) Option {
+	return func(conf *Config) {
+		if conf == nil || len(policies) == 0 {
+			return
+		}
+		conf.Policies = append(conf.Policies, policies...)
+	}
+}
+
+// WithStore sets the store of the rate limits, eg: NewRedisStore, NewLocalStore.
+// A nil store is ignored; the default NewRedisStore is used instead.
+func WithStore(store Store) Option {
+	return func(conf *Config) {
+		if conf == nil || store == nil {
+			return
+		}
+		conf.Store = store
+	}
+}
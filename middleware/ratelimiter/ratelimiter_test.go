@@ -0,0 +1,208 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLocalStoreGCRA(t *testing.T) {
+	store := NewLocalStore()
+	p := Policy{Limit: 10, Period: time.Second, Burst: 3}.normalize()
+
+	for i := range 3 {
+		res, err := store.Take(context.Background(), "k", p)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 2-i, res.Remaining)
+	}
+	res, err := store.Take(context.Background(), "k", p)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Positive(t, res.RetryAfter)
+	require.LessOrEqual(t, res.RetryAfter, 100*time.Millisecond)
+
+	// The keys are limited separately.
+	res, _ = store.Take(context.Background(), "other", p)
+	require.True(t, res.Allowed)
+
+	time.Sleep(res.RetryAfter + 110*time.Millisecond)
+	res, _ = store.Take(context.Background(), "k", p)
+	require.True(t, res.Allowed)
+}
+
+func TestLocalStoreGCRAHighRate(t *testing.T) {
+	// The emission of Period/Limit truncates to zero, the requests are still counted.
+	store := NewLocalStore()
+	p := Policy{Limit: 1000, Period: time.Microsecond, Burst: 2}.normalize()
+	require.Equal(t, time.Nanosecond, p.emission())
+	for range 2 {
+		res, err := store.Take(context.Background(), "k", p)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	period := int64(time.Minute)
+
+	res, ok := slidingWindow(10, period, period/2, 5, 10)
+	require.False(t, ok) // 10*0.5 + 5 + 1 > 10
+	require.Equal(t, time.Duration(period/2), res.Reset)
+	// The previous window weighs 0.5 now, the request fits once it weighs 0.4.
+	require.InDelta(t, float64(period)/10, float64(res.RetryAfter), float64(time.Millisecond))
+
+	res, ok = slidingWindow(10, period, period/2, 4, 2)
+	require.True(t, ok)
+	require.Equal(t, 4, res.Remaining) // 10 - (2*0.5 + 4) - 1
+
+	res, ok = slidingWindow(10, period, period/2, 10, 0)
+	require.False(t, ok)
+	require.Equal(t, res.Reset, res.RetryAfter)
+}
+
+func TestLocalStoreSlidingWindow(t *testing.T) {
+	store := NewLocalStore()
+	p := Policy{Limit: 5, Period: time.Hour, Algorithm: SlidingWindow}.normalize()
+	for range 5 {
+		res, err := store.Take(context.Background(), "k", p)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+	res, err := store.Take(context.Background(), "k", p)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+}
+
+func TestRedisStoreFallback(t *testing.T) {
+	// redis is disabled, the requests are limited by the local store.
+	store := NewRedisStore()
+	p := Policy{Limit: 1, Period: time.Hour}.normalize()
+	res, err := store.Take(context.Background(), "k", p)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	res, err = store.Take(context.Background(), "k", p)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+}
+
+func TestRateLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); len(user) > 0 {
+			c.Set(consts.CTX_USER_ID, user)
+		}
+	})
+	r.Use(RateLimiter(
+		WithStore(NewLocalStore()),
+		WithPolicies(
+			Policy{Name: "ip", Limit: 100, Period: PerMinute, KeyFunc: KeyByIP},
+			Policy{Name: "user", Limit: 2, Period: PerDay, Algorithm: SlidingWindow, KeyFunc: KeyByUser},
+		),
+	))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if len(user) > 0 {
+			req.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The user policy is skipped for anonymous requests.
+	w := do("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "100", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "99", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "100;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = do("alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "2;w=86400", w.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, do("alice").Code)
+	w = do("alice")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other users are not limited by alice's quota.
+	require.Equal(t, http.StatusOK, do("bob").Code)
+}
+
+func TestRateLimiterRefund(t *testing.T) {
+	for name, store := range map[string]Store{"local": NewLocalStore(), "redis": NewRedisStore()} {
+		t.Run(name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(func(c *gin.Context) { c.Set(consts.CTX_USER_ID, c.GetHeader("X-User")) })
+			r.Use(RateLimiter(
+				WithStore(store),
+				WithPolicies(
+					Policy{Name: "ip", Limit: 3, Period: PerHour, KeyFunc: KeyByIP},
+					Policy{Name: "user", Limit: 1, Period: PerDay, Algorithm: SlidingWindow, KeyFunc: KeyByUser},
+				),
+			))
+			r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			do := func(user string) int {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-User", user)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			require.Equal(t, http.StatusOK, do("alice"))
+			// The requests denied by the user policy don't consume the quota of the ip.
+			for range 5 {
+				require.Equal(t, http.StatusTooManyRequests, do("alice"))
+			}
+			require.Equal(t, http.StatusOK, do("bob"))
+			require.Equal(t, http.StatusOK, do("carol"))
+			require.Equal(t, http.StatusTooManyRequests, do("dave"))
+		})
+	}
+}
+
+func TestRateLimiterDefaultPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimiter(WithStore(NewLocalStore()), WithRate(1), WithBurst(2)))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	codes := make([]int, 0, 3)
+	for range 3 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes = append(codes, w.Code)
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestRateLimiterInf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimiter(WithStore(NewLocalStore()), WithRate(rate.Inf), WithBurst(1)))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for range 100 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
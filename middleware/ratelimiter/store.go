@@ -0,0 +1,307 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/provider/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Result is the decision of a Store for one request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if the request is allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of the rate limits.
+type Store interface {
+	// Take counts a request of the key under the policy and reports whether it's allowed.
+	// Denied requests are not counted.
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// Refunder is implemented by the stores able to return a request counted by Take.
+// The middleware refunds the requests counted by the policies allowing a request
+// that is denied by a later policy, so the denied request consumes no quota.
+// Without it, the earlier policies keep the request counted.
+type Refunder interface {
+	// Refund uncounts a request of the key allowed by Take under the policy.
+	Refund(ctx context.Context, key string, policy Policy) error
+}
+
+var (
+	_ Refunder = (*localStore)(nil)
+	_ Refunder = (*redisStore)(nil)
+)
+
+// sweepInterval is the interval of removing the expired keys of the local store.
+const sweepInterval = time.Minute
+
+// NewLocalStore creates a store keeping the rate limits in process.
+// Every instance limits the requests separately, and the limits reset on restart.
+func NewLocalStore() Store {
+	return &localStore{entries: make(map[string]*localEntry), nextSweep: time.Now().Add(sweepInterval)}
+}
+
+type localStore struct {
+	mu        sync.Mutex
+	entries   map[string]*localEntry
+	nextSweep time.Time
+}
+
+type localEntry struct {
+	tat       time.Time // tat is the theoretical arrival time of GCRA
+	window    int64     // window is the index of the current window of SlidingWindow
+	count     int       // count is the number of requests in the current window
+	prevCount int       // prevCount is the number of requests in the previous window
+	expiresAt time.Time
+}
+
+func (s *localStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.nextSweep) {
+		for k, e := range s.entries {
+			if now.After(e.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(sweepInterval)
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = new(localEntry)
+		s.entries[key] = e
+	}
+	if policy.Algorithm == SlidingWindow {
+		return e.slidingWindow(now, policy), nil
+	}
+	return e.gcra(now, policy), nil
+}
+
+func (s *localStore) Refund(_ context.Context, key string, policy Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if policy.Algorithm == SlidingWindow {
+		if e.count > 0 {
+			e.count--
+		}
+		return nil
+	}
+	e.tat = e.tat.Add(-policy.emission())
+	e.expiresAt = e.tat
+	return nil
+}
+
+func (e *localEntry) gcra(now time.Time, p Policy) Result {
+	emission := p.emission()
+	tolerance := emission * time.Duration(p.Burst)
+
+	tat := e.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(emission)
+	allowAt := newTat.Add(-tolerance)
+	if now.Before(allowAt) {
+		return Result{Limit: p.Limit, Reset: tat.Sub(now), RetryAfter: allowAt.Sub(now)}
+	}
+	e.tat = newTat
+	e.expiresAt = newTat
+	return Result{Allowed: true, Limit: p.Limit, Remaining: int(now.Sub(allowAt) / emission), Reset: newTat.Sub(now)}
+}
+
+func (e *localEntry) slidingWindow(now time.Time, p Policy) Result {
+	period := int64(p.Period)
+	window := now.UnixNano() / period
+	elapsed := now.UnixNano() - window*period
+	switch window {
+	case e.window:
+	case e.window + 1:
+		e.window, e.prevCount, e.count = window, e.count, 0
+	default:
+		e.window, e.prevCount, e.count = window, 0, 0
+	}
+
+	res, allowed := slidingWindow(p.Limit, period, elapsed, e.count, e.prevCount)
+	if allowed {
+		e.count++
+		e.expiresAt = now.Add(2 * p.Period)
+	}
+	return res
+}
+
+// slidingWindow decides the request by the counts of the current and the previous window.
+func slidingWindow(limit int, period, elapsed int64, count, prevCount int) (Result, bool) {
+	weight := float64(period-elapsed) / float64(period)
+	estimated := float64(prevCount)*weight + float64(count)
+	res := Result{Limit: limit, Reset: time.Duration(period - elapsed)}
+	if estimated+1 > float64(limit) {
+		res.RetryAfter = res.Reset
+		if count+1 <= limit && prevCount > 0 {
+			// The weight of the previous window decreases until the request fits.
+			res.RetryAfter = time.Duration(float64(period-elapsed) - float64(limit-count-1)*float64(period)/float64(prevCount))
+		}
+		return res, false
+	}
+	res.Allowed = true
+	res.Remaining = int(float64(limit) - estimated - 1)
+	return res, true
+}
+
+// gcraScript is GCRA in microseconds, using the redis clock so that all instances share the same time.
+var gcraScript = goredis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local emission = math.max(tonumber(ARGV[1]), 1)
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / emission), new_tat - now, 0}
+`)
+
+// slidingWindowScript keeps the counts of the windows in a hash, the field is the window index.
+var slidingWindowScript = goredis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local period = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local cur, prev = string.format("%d", window), string.format("%d", window - 1)
+local count = tonumber(redis.call("HGET", KEYS[1], cur) or 0)
+local prev_count = tonumber(redis.call("HGET", KEYS[1], prev) or 0)
+local reset = period - elapsed
+local estimated = prev_count * (period - elapsed) / period + count
+if estimated + 1 > limit then
+	local retry = reset
+	if count + 1 <= limit and prev_count > 0 then
+		retry = math.ceil(period - elapsed - (limit - count - 1) * period / prev_count)
+	end
+	return {0, 0, reset, retry}
+end
+redis.call("HINCRBY", KEYS[1], cur, 1)
+for _, field in ipairs(redis.call("HKEYS", KEYS[1])) do
+	if field ~= cur and field ~= prev then
+		redis.call("HDEL", KEYS[1], field)
+	end
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(2 * period / 1000))
+return {1, math.floor(limit - estimated - 1), reset, 0}
+`)
+
+// gcraRefundScript moves the theoretical arrival time back by one emission.
+var gcraRefundScript = goredis.NewScript(`
+redis.replicate_commands()
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat then
+	return 0
+end
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+tat = tat - math.max(tonumber(ARGV[1]), 1)
+if tat <= now then
+	return redis.call("DEL", KEYS[1])
+end
+redis.call("SET", KEYS[1], string.format("%.0f", tat), "PX", math.ceil((tat - now) / 1000))
+return 1
+`)
+
+// slidingWindowRefundScript decrements the count of the current window.
+var slidingWindowRefundScript = goredis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local cur = string.format("%d", math.floor(now / tonumber(ARGV[1])))
+if tonumber(redis.call("HGET", KEYS[1], cur) or 0) > 0 then
+	return redis.call("HINCRBY", KEYS[1], cur, -1)
+end
+return 0
+`)
+
+// fallbackLogInterval limits the logs of the redis store falling back to the local store.
+const fallbackLogInterval = time.Minute
+
+// NewRedisStore creates a store keeping the rate limits in redis, shared by all instances.
+// The requests are limited by the local store while redis is disabled or unavailable,
+// so the limits apply per instance until redis recovers.
+func NewRedisStore() Store {
+	return &redisStore{fallback: NewLocalStore()}
+}
+
+type redisStore struct {
+	fallback Store
+	lastLog  atomic.Int64
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	var cmd *goredis.Cmd
+	if policy.Algorithm == SlidingWindow {
+		cmd = redis.RunScript(ctx, slidingWindowScript, []string{key}, policy.Period.Microseconds(), policy.Limit)
+	} else {
+		// The script works in microseconds, the tolerance is derived from the rounded emission
+		// so that a sub-microsecond emission doesn't leave a zero tolerance denying every request.
+		emission := max(policy.emission().Microseconds(), 1)
+		cmd = redis.RunScript(ctx, gcraScript, []string{key}, emission, emission*int64(policy.Burst))
+	}
+	vals, err := cmd.Int64Slice()
+	if err == nil && len(vals) != 4 {
+		err = errors.Newf("unexpected rate limit script result %v", vals)
+	}
+	if err != nil {
+		if now := time.Now().UnixNano(); !errors.Is(err, redis.ErrRedisIsDisabled) && now-s.lastLog.Load() > int64(fallbackLogInterval) {
+			s.lastLog.Store(now)
+			zap.S().Warnw("rate limiter falls back to the local store", "error", err.Error())
+		}
+		return s.fallback.Take(ctx, key, policy)
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(vals[1]),
+		Reset:      time.Duration(vals[2]) * time.Microsecond,
+		RetryAfter: time.Duration(vals[3]) * time.Microsecond,
+	}, nil
+}
+
+func (s *redisStore) Refund(ctx context.Context, key string, policy Policy) error {
+	var err error
+	if policy.Algorithm == SlidingWindow {
+		err = redis.RunScript(ctx, slidingWindowRefundScript, []string{key}, policy.Period.Microseconds()).Err()
+	} else {
+		err = redis.RunScript(ctx, gcraRefundScript, []string{key}, max(policy.emission().Microseconds(), 1)).Err()
+	}
+	if err != nil {
+		// The request was counted by the fallback store if redis is unavailable.
+		return s.fallback.(Refunder).Refund(ctx, key, policy) //nolint:errcheck
+	}
+	return nil
+}
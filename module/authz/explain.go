@@ -0,0 +1,49 @@
+package authz
+
+import (
+	modelauthz "github.com/forbearing/gst/internal/model/authz"
+	serviceauthz "github.com/forbearing/gst/internal/service/authz"
+	"github.com/forbearing/gst/types"
+)
+
+var (
+	_ types.Module[*Explain, *ExplainReq, *ExplainRsp]    = (*ExplainModule)(nil)
+	_ types.Module[*Simulate, *SimulateReq, *SimulateRsp] = (*SimulateModule)(nil)
+)
+
+type (
+	Explain        = modelauthz.Explain
+	ExplainReq     = modelauthz.ExplainReq
+	ExplainRsp     = modelauthz.ExplainRsp
+	Simulate       = modelauthz.Simulate
+	SimulateReq    = modelauthz.SimulateReq
+	SimulateRsp    = modelauthz.SimulateRsp
+	PolicyChange   = modelauthz.PolicyChange
+	PolicyChangeOp = modelauthz.PolicyChangeOp
+
+	ExplainModule  struct{}
+	SimulateModule struct{}
+)
+
+const (
+	PolicyChangeGrantPermission  = modelauthz.PolicyChangeGrantPermission
+	PolicyChangeRevokePermission = modelauthz.PolicyChangeRevokePermission
+	PolicyChangeAssignRole       = modelauthz.PolicyChangeAssignRole
+	PolicyChangeUnassignRole     = modelauthz.PolicyChangeUnassignRole
+	PolicyChangeRemoveRole       = modelauthz.PolicyChangeRemoveRole
+	PolicyChangeSetRoleMenus     = modelauthz.PolicyChangeSetRoleMenus
+)
+
+func (*ExplainModule) Service() types.Service[*Explain, *ExplainReq, *ExplainRsp] {
+	return &serviceauthz.ExplainService{}
+}
+func (*ExplainModule) Route() string { return "authz/explain" }
+func (*ExplainModule) Pub() bool     { return false }
+func (*ExplainModule) Param() string { return "id" }
+
+func (*SimulateModule) Service() types.Service[*Simulate, *SimulateReq, *SimulateRsp] {
+	return &serviceauthz.SimulateService{}
+}
+func (*SimulateModule) Route() string { return "authz/simulate" }
+func (*SimulateModule) Pub() bool     { return false }
+func (*SimulateModule) Param() string { return "id" }
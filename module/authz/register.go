@@ -17,6 +17,21 @@ import (
 	"go.uber.org/zap"
 )
 
+// Config is the configuration of authz module.
+type Config struct {
+	// EnableTenant enables the tenant aware RBAC model, default is false.
+	//
+	// Requests are enforced within their tenant, resolved from the "X-Tenant-ID" header,
+	// then the tenant of the login session, then the "default" tenant.
+	// Roles with "tenant_id" only apply in that tenant, roles without it are global,
+	// and user roles are granted per tenant.
+	// The users "root" and "admin" are super admins and can access all tenants.
+	//
+	// NOTE: the casbin_rule policies are not compatible between the two models,
+	// switching an existing deployment requires re-creating the roles and user roles.
+	EnableTenant bool
+}
+
 // Register register modules: Permission, Role, UserRole.
 //
 // Modules:
@@ -41,6 +56,8 @@ import (
 //   - PATCH  /api/authz/user-roles/:id
 //   - GET    /api/authz/user-roles
 //   - GET    /api/authz/user-roles/:id
+//   - POST   /api/authz/explain
+//   - POST   /api/authz/simulate
 //   - POST   /api/menus
 //   - DELETE /api/menus/:id
 //   - PUT    /api/menus/:id
@@ -59,9 +76,17 @@ import (
 //   - Authz
 //
 // Panic if creates table records failed.
-func Register() {
+func Register(cfgs ...Config) {
+	var cfg Config
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
+
 	// Enable RBAC
 	os.Setenv(config.AUTH_RBAC_ENABLE, "true")
+	if cfg.EnableTenant {
+		os.Setenv(config.AUTH_RBAC_TENANT_ENABLE, "true")
+	}
 
 	// creates table "casbin_rule".
 	model.Register[*CasbinRule]()
@@ -111,6 +136,22 @@ func Register() {
 		consts.PHASE_GET,
 	)
 
+	// Explain why a request is allowed or denied, and evaluate proposed changes before saving them.
+	module.Use[
+		*Explain,
+		*ExplainReq,
+		*ExplainRsp](
+		&ExplainModule{},
+		consts.PHASE_CREATE,
+	)
+	module.Use[
+		*Simulate,
+		*SimulateReq,
+		*SimulateRsp](
+		&SimulateModule{},
+		consts.PHASE_CREATE,
+	)
+
 	module.Use[
 		*Menu,
 		*Menu,
@@ -0,0 +1,63 @@
+package featureflag
+
+import (
+	modelfeatureflag "github.com/forbearing/gst/internal/model/featureflag"
+	servicefeatureflag "github.com/forbearing/gst/internal/service/featureflag"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+)
+
+var (
+	_ types.Module[*Flag, *Flag, *Flag]                   = (*FlagModule)(nil)
+	_ types.Module[*Evaluation, *Evaluation, *Evaluation] = (*EvaluationModule)(nil)
+	_ types.Module[*Evaluate, *Evaluate, *EvaluateRsp]    = (*EvaluateModule)(nil)
+)
+
+type (
+	Flag       = modelfeatureflag.Flag
+	FlagType   = modelfeatureflag.FlagType
+	Variant    = modelfeatureflag.Variant
+	Rule       = modelfeatureflag.Rule
+	Weight     = modelfeatureflag.Weight
+	FlagModule struct{}
+
+	Evaluation       = modelfeatureflag.Evaluation
+	Reason           = modelfeatureflag.Reason
+	EvaluationModule struct{}
+
+	Evaluate       = modelfeatureflag.Evaluate
+	EvaluateRsp    = modelfeatureflag.EvaluateRsp
+	Result         = modelfeatureflag.Result
+	EvaluateModule struct{}
+)
+
+const (
+	FlagTypeBoolean = modelfeatureflag.FlagTypeBoolean
+	FlagTypeVariant = modelfeatureflag.FlagTypeVariant
+
+	ReasonNotFound = modelfeatureflag.ReasonNotFound
+	ReasonDisabled = modelfeatureflag.ReasonDisabled
+	ReasonRule     = modelfeatureflag.ReasonRule
+	ReasonDefault  = modelfeatureflag.ReasonDefault
+)
+
+func (*FlagModule) Service() types.Service[*Flag, *Flag, *Flag] {
+	return &servicefeatureflag.FlagService{}
+}
+func (*FlagModule) Route() string { return "/featureflags" }
+func (*FlagModule) Pub() bool     { return false }
+func (*FlagModule) Param() string { return "id" }
+
+func (*EvaluationModule) Service() types.Service[*Evaluation, *Evaluation, *Evaluation] {
+	return &service.Base[*Evaluation, *Evaluation, *Evaluation]{}
+}
+func (*EvaluationModule) Route() string { return "/featureflags/evaluations" }
+func (*EvaluationModule) Pub() bool     { return false }
+func (*EvaluationModule) Param() string { return "id" }
+
+func (*EvaluateModule) Service() types.Service[*Evaluate, *Evaluate, *EvaluateRsp] {
+	return &servicefeatureflag.EvaluateService{}
+}
+func (*EvaluateModule) Route() string { return "/featureflags/evaluate" }
+func (*EvaluateModule) Pub() bool     { return false }
+func (*EvaluateModule) Param() string { return "id" }
@@ -0,0 +1,80 @@
+package featureflag
+
+import (
+	"context"
+	"time"
+
+	"github.com/forbearing/gst/featureflag"
+	"github.com/forbearing/gst/module"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// Config is the configuration of featureflag module.
+type Config struct {
+	// PollInterval is the interval of the reload of the flags on every instance, default is 30 seconds.
+	PollInterval time.Duration
+
+	// AuditRetention is the retention of the evaluation audit trail, default is 30 days.
+	AuditRetention time.Duration
+
+	// Groups resolves the groups of a user for the group rules, see featureflag.SetGroupsFunc.
+	Groups func(ctx context.Context, userID string) []string
+}
+
+// Register registers the management API of the feature flags and loads the flags
+// evaluated by the featureflag package from the database.
+//
+// Models:
+//   - Flag
+//   - Evaluation
+//
+// Routes:
+//   - POST   /api/featureflags
+//   - DELETE /api/featureflags/:id
+//   - PUT    /api/featureflags/:id
+//   - PATCH  /api/featureflags/:id
+//   - GET    /api/featureflags
+//   - GET    /api/featureflags/:id
+//   - GET    /api/featureflags/evaluate
+//   - GET    /api/featureflags/evaluations
+//   - GET    /api/featureflags/evaluations/:id
+//
+// Cronjob:
+//   - cleanup the evaluation audit trail hourly.
+//
+// Every change of the flags reloads them on the instance serving the request and,
+// through redis if enabled, on the other instances. Evaluate evaluates all flags
+// for the current user, eg: for the frontend. The evaluations of the flags
+// with Audit enabled are listed by the evaluations routes.
+func Register(cfgs ...Config) {
+	var cfg Config
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
+	if cfg.Groups != nil {
+		featureflag.SetGroupsFunc(cfg.Groups)
+	}
+	featureflag.Enable(featureflag.Config{PollInterval: cfg.PollInterval, AuditRetention: cfg.AuditRetention})
+
+	module.Use[*Flag,
+		*Flag,
+		*Flag](
+		&FlagModule{},
+		consts.PHASE_CREATE,
+		consts.PHASE_DELETE,
+		consts.PHASE_UPDATE,
+		consts.PHASE_PATCH,
+		consts.PHASE_LIST,
+		consts.PHASE_GET,
+	)
+
+	module.Use(&EvaluateModule{}, consts.PHASE_LIST)
+
+	module.Use[*Evaluation,
+		*Evaluation,
+		*Evaluation](
+		&EvaluationModule{},
+		consts.PHASE_LIST,
+		consts.PHASE_GET,
+	)
+}
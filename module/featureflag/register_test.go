@@ -0,0 +1,215 @@
+package featureflag_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/client"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/internal/helper"
+	"github.com/forbearing/gst/module/featureflag"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	token = "-"
+	port  = 8000
+
+	flagAPI        = fmt.Sprintf("http://localhost:%d/api/featureflags", port)
+	evaluateAPI    = fmt.Sprintf("http://localhost:%d/api/featureflags/evaluate", port)
+	evaluationsAPI = fmt.Sprintf("http://localhost:%d/api/featureflags/evaluations", port)
+
+	// pollInterval bounds the delay of the changes not made through the API.
+	pollInterval = 200 * time.Millisecond
+)
+
+func init() {
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "true")
+	os.Setenv(config.SERVER_PORT, fmt.Sprintf("%d", port))
+	os.Setenv(config.LOGGER_DIR, "./logs")
+	os.Setenv(config.AUTH_NONE_EXPIRE_TOKEN, token)
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+
+	go func() {
+		featureflag.Register(featureflag.Config{PollInterval: pollInterval})
+
+		if err := bootstrap.Run(); err != nil {
+			panic(err)
+		}
+	}()
+
+	for {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			l.Close()
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		if errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+		panic(err)
+
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// evaluate evaluates all flags and returns the result of the flag with the key,
+// the Flag of the result is empty if the flag isn't evaluated.
+func evaluate(key string) (featureflag.Result, error) {
+	cli, err := client.New(evaluateAPI)
+	if err != nil {
+		return featureflag.Result{}, err
+	}
+	items := make([]featureflag.Result, 0)
+	var total int64
+	if _, err = cli.List(&items, &total); err != nil {
+		return featureflag.Result{}, err
+	}
+	for _, r := range items {
+		if r.Flag == key {
+			return r, nil
+		}
+	}
+	return featureflag.Result{}, nil
+}
+
+func TestFeatureFlag(t *testing.T) {
+	var id string
+	key := "new-checkout"
+
+	t.Run("create", func(t *testing.T) {
+		cli, err := client.New(flagAPI)
+		require.NoError(t, err)
+
+		resp, err := cli.Create(featureflag.Flag{Key: key, Description: "the new checkout page", Enabled: ptr(true)})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp featureflag.Flag) {
+			require.NotEmpty(t, rsp.ID)
+			require.Equal(t, key, rsp.Key)
+			require.Equal(t, featureflag.FlagTypeBoolean, rsp.Type)
+			require.Equal(t, "true", rsp.DefaultVariant)
+			require.Equal(t, "false", rsp.OffVariant)
+			id = rsp.ID
+		})
+
+		// The invalid flags are rejected.
+		for _, f := range []featureflag.Flag{
+			{Key: "Upper"},
+			{Key: "theme", Type: featureflag.FlagTypeVariant},
+			{Key: "rollout", Rules: []featureflag.Rule{{Percentage: ptr(120.0), Variant: "true"}}},
+		} {
+			_, err = cli.Create(f)
+			require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusBadRequest), f.Key)
+		}
+	})
+
+	t.Run("get and list", func(t *testing.T) {
+		cli, err := client.New(flagAPI)
+		require.NoError(t, err)
+
+		flag := new(featureflag.Flag)
+		_, err = cli.Get(id, flag)
+		require.NoError(t, err)
+		require.Equal(t, key, flag.Key)
+		require.True(t, flag.IsEnabled())
+
+		items := make([]*featureflag.Flag, 0)
+		var total int64
+		_, err = cli.List(&items, &total)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, key, items[0].Key)
+	})
+
+	t.Run("evaluate", func(t *testing.T) {
+		r, err := evaluate(key)
+		require.NoError(t, err)
+		require.Equal(t, key, r.Flag)
+		require.Equal(t, "true", r.Variant)
+		require.Equal(t, true, r.Value)
+		require.Equal(t, featureflag.ReasonDefault, r.Reason)
+	})
+
+	// The changes made through the API are applied to the cached flags immediately.
+	t.Run("update", func(t *testing.T) {
+		cli, err := client.New(flagAPI)
+		require.NoError(t, err)
+
+		_, err = cli.Update(id, featureflag.Flag{Key: key, Enabled: ptr(false)})
+		require.NoError(t, err)
+		r, err := evaluate(key)
+		require.NoError(t, err)
+		require.Equal(t, "false", r.Variant)
+		require.Equal(t, featureflag.ReasonDisabled, r.Reason)
+
+		_, err = cli.Patch(id, map[string]any{"enabled": true})
+		require.NoError(t, err)
+		r, err = evaluate(key)
+		require.NoError(t, err)
+		require.Equal(t, "true", r.Variant)
+		require.Equal(t, featureflag.ReasonDefault, r.Reason)
+
+		_, err = cli.Update(id, featureflag.Flag{Key: key, Type: featureflag.FlagTypeVariant})
+		require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusBadRequest))
+	})
+
+	// The evaluations of the audited flags are recorded asynchronously.
+	t.Run("audit", func(t *testing.T) {
+		cli, err := client.New(flagAPI)
+		require.NoError(t, err)
+		_, err = cli.Patch(id, map[string]any{"audit": true})
+		require.NoError(t, err)
+		_, err = evaluate(key)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			cli, err := client.New(evaluationsAPI, client.WithQuery("flag", key))
+			if err != nil {
+				return false
+			}
+			items := make([]*featureflag.Evaluation, 0)
+			var total int64
+			_, err = cli.List(&items, &total)
+			return err == nil && len(items) > 0 && items[0].Variant == "true" && items[0].Reason == featureflag.ReasonDefault
+		}, 10*time.Second, 200*time.Millisecond)
+	})
+
+	// The changes not made through the API, eg: by another instance missing the notification,
+	// are applied by the next reload.
+	t.Run("reload", func(t *testing.T) {
+		flag := new(featureflag.Flag)
+		require.NoError(t, database.Database[*featureflag.Flag](nil).Get(flag, id))
+		flag.Enabled = ptr(false)
+		require.NoError(t, database.Database[*featureflag.Flag](nil).Update(flag))
+
+		require.Eventually(t, func() bool {
+			r, err := evaluate(key)
+			return err == nil && r.Reason == featureflag.ReasonDisabled
+		}, 10*pollInterval, pollInterval/4)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		cli, err := client.New(flagAPI)
+		require.NoError(t, err)
+
+		_, err = cli.Delete(id)
+		require.NoError(t, err)
+		r, err := evaluate(key)
+		require.NoError(t, err)
+		require.Empty(t, r.Flag)
+	})
+}
@@ -659,3 +659,122 @@ func TestAccountStatus(t *testing.T) {
 		accountSetSuperuser(t, actor.Username, false)
 	})
 }
+
+func TestAccountLoginLockout(t *testing.T) {
+	actor := accountSignupUser(t, "acct_lockout_actor", "12345678")
+	actor.SessionID = accountLoginUser(t, &actor, actor.Password)
+	victim := accountSignupUser(t, "acct_lockout_victim", "acctpass11")
+
+	login := func(password string) error {
+		cli, err := client.New(loginAPI)
+		require.NoError(t, err)
+		_, err = cli.Create(iam.LoginReq{Username: victim.Username, Password: password})
+		return err
+	}
+
+	t.Run("lock_after_failures", func(t *testing.T) {
+		for range 2 {
+			err := login("wrong-password")
+			require.Error(t, err)
+			require.NotContains(t, err.Error(), fmt.Sprintf(`"code":%d`, response.CodeTooManyLoginAttempts.Code()))
+		}
+		require.Error(t, login("wrong-password"))
+
+		select {
+		case alert := <-loginAlerts:
+			require.Equal(t, iam.LoginAlertAccountLocked, alert.Kind)
+			require.Equal(t, victim.Username, alert.Lock.Subject)
+			require.Equal(t, int64(3), alert.Lock.Failures)
+		case <-time.After(5 * time.Second):
+			t.Fatal("login alert not received")
+		}
+	})
+
+	t.Run("correct_password_rejected_while_locked", func(t *testing.T) {
+		err := login(victim.Password)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), fmt.Sprintf(`"code":%d`, response.CodeTooManyLoginAttempts.Code()))
+	})
+
+	t.Run("list_forbidden_when_not_superuser", func(t *testing.T) {
+		cli, err := client.New(loginLockoutsAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: actor.SessionID}))
+		require.NoError(t, err)
+		items := make([]iam.LoginLock, 0)
+		_, err = cli.List(&items, new(int64))
+		require.Error(t, err)
+	})
+
+	t.Run("promote_actor_superuser", func(t *testing.T) {
+		accountSetSuperuser(t, actor.Username, true)
+	})
+
+	t.Run("list_lockouts", func(t *testing.T) {
+		cli, err := client.New(loginLockoutsAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: actor.SessionID}))
+		require.NoError(t, err)
+		items := make([]iam.LoginLock, 0)
+		_, err = cli.List(&items, new(int64))
+		require.NoError(t, err)
+
+		found := false
+		for _, item := range items {
+			if item.Kind == iam.LoginLockKindAccount && item.Subject == victim.Username {
+				found = true
+				require.True(t, item.Until.After(time.Now()))
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		cli, err := client.New(loginUnlockAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: actor.SessionID}))
+		require.NoError(t, err)
+		_, err = cli.Create(iam.LoginUnlockReq{Username: victim.Username})
+		require.NoError(t, err)
+	})
+
+	t.Run("login_after_unlock", func(t *testing.T) {
+		accountLoginUser(t, &victim, victim.Password)
+	})
+
+	t.Run("demote_actor_superuser", func(t *testing.T) {
+		accountSetSuperuser(t, actor.Username, false)
+	})
+}
+
+func TestAccountPasswordPolicy(t *testing.T) {
+	user := accountSignupUser(t, "acct_pwdpolicy", "acctpass11")
+	user.SessionID = accountLoginUser(t, &user, user.Password)
+
+	changePassword := func(oldPassword, newPassword string) error {
+		cli, err := client.New(changepasswordAPI, client.WithCookie(&http.Cookie{
+			Name:  "session_id",
+			Value: user.SessionID,
+		}))
+		require.NoError(t, err)
+		_, err = cli.Create(iam.ChangePasswordReq{OldPassword: oldPassword, NewPassword: newPassword})
+		return err
+	}
+
+	t.Run("too_short", func(t *testing.T) {
+		err := changePassword(user.Password, "abc")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "at least 6 characters")
+	})
+
+	t.Run("reuse_current_password", func(t *testing.T) {
+		err := changePassword(user.Password, user.Password)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "last 3 passwords")
+	})
+
+	t.Run("change_password", func(t *testing.T) {
+		require.NoError(t, changePassword(user.Password, "acctpass22"))
+		user.SessionID = accountLoginUser(t, &user, "acctpass22")
+	})
+
+	t.Run("reuse_previous_password", func(t *testing.T) {
+		err := changePassword("acctpass22", user.Password)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "last 3 passwords")
+	})
+}
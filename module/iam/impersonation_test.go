@@ -0,0 +1,126 @@
+package iam_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/client"
+	"github.com/forbearing/gst/internal/helper"
+	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
+	"github.com/forbearing/gst/module/iam"
+	"github.com/forbearing/gst/provider/redis"
+	"github.com/stretchr/testify/require"
+)
+
+var impersonationAPI = fmt.Sprintf("http://localhost:%d/api/iam/impersonation", port)
+
+func TestImpersonation(t *testing.T) {
+	setupSessionRedisCleanup(t)
+
+	admin := newSessionTestAccount(t)
+	sessionSetSuperuser(t, admin.Username, true)
+	adminSessionID := loginSession(t, admin.Username, admin.Password)
+	target := newSessionTestAccount(t)
+
+	var sessionID string
+	t.Run("start", func(t *testing.T) {
+		cli, err := client.New(impersonationAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: adminSessionID}))
+		require.NoError(t, err)
+
+		resp, err := cli.Create(iam.ImpersonationCreateReq{Username: target.Username, Reason: "reproduce ticket", Duration: "2h"})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp iam.ImpersonationCreateRsp) {
+			require.NotEmpty(t, rsp.SessionID)
+			require.Equal(t, target.Username, rsp.Username)
+			// The duration is capped by MaxDuration.
+			require.WithinDuration(t, time.Now().Add(30*time.Minute), rsp.ExpiresAt, time.Minute)
+			sessionID = rsp.SessionID
+		})
+
+		session, err := redis.Cache[modeliamsession.Session]().Get(modeliamsession.SessionIDKey(sessionID))
+		require.NoError(t, err)
+		require.Equal(t, target.UserID, session.UserID)
+		require.NotNil(t, session.Impersonator)
+		require.Equal(t, admin.Username, session.Impersonator.Username)
+		require.Equal(t, adminSessionID, session.Impersonator.SessionID)
+		requireUserSessionContains(t, target.UserID, sessionID)
+	})
+
+	t.Run("reason_required", func(t *testing.T) {
+		cli, err := client.New(impersonationAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: adminSessionID}))
+		require.NoError(t, err)
+
+		_, err = cli.Create(iam.ImpersonationCreateReq{Username: target.Username})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "400")
+	})
+
+	t.Run("forbidden_for_regular_user", func(t *testing.T) {
+		account := newSessionTestAccount(t)
+		cli, err := client.New(impersonationAPI, client.WithCookie(&http.Cookie{
+			Name:  "session_id",
+			Value: loginSession(t, account.Username, account.Password),
+		}))
+		require.NoError(t, err)
+
+		_, err = cli.Create(iam.ImpersonationCreateReq{Username: target.Username, Reason: "test"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "403")
+	})
+
+	t.Run("sensitive_routes_blocked", func(t *testing.T) {
+		cli, err := client.New(changepasswordAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: sessionID}))
+		require.NoError(t, err)
+
+		_, err = cli.Create(iam.ChangePasswordReq{OldPassword: target.Password, NewPassword: "123456789"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "403")
+
+		cli, err = client.New(impersonationAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: sessionID}))
+		require.NoError(t, err)
+		_, err = cli.Create(iam.ImpersonationCreateReq{Username: admin.Username, Reason: "nested"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "403")
+	})
+
+	t.Run("visible_to_target", func(t *testing.T) {
+		cli, err := client.New(sessionsAPI, client.WithCookie(&http.Cookie{
+			Name:  "session_id",
+			Value: loginSession(t, target.Username, target.Password),
+		}))
+		require.NoError(t, err)
+
+		resp, err := cli.List(new([]iam.SessionView), new(int64))
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp iam.SessionsListRsp) {
+			require.Len(t, rsp.Impersonations, 1)
+			require.Equal(t, admin.Username, rsp.Impersonations[0].ActorUsername)
+			require.Equal(t, "reproduce ticket", rsp.Impersonations[0].Reason)
+
+			var found bool
+			for _, item := range rsp.Items {
+				if item.ID == sessionID {
+					found = true
+					require.NotNil(t, item.Impersonator)
+					require.Equal(t, admin.Username, item.Impersonator.Username)
+				}
+			}
+			require.True(t, found)
+		})
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		cli, err := client.New(impersonationAPI, client.WithCookie(&http.Cookie{Name: "session_id", Value: sessionID}))
+		require.NoError(t, err)
+
+		resp, err := cli.Request(http.MethodDelete, new(struct{}))
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp iam.ImpersonationDeleteRsp) {
+			require.Equal(t, adminSessionID, rsp.SessionID)
+		})
+		requireSessionNotFound(t, sessionID)
+		requireUserSessionNotContains(t, target.UserID, sessionID)
+	})
+}
@@ -5,10 +5,12 @@ import (
 
 	"github.com/forbearing/gst/cronjob"
 	cronjobiam "github.com/forbearing/gst/internal/cronjob/iam"
+	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
 	serviceiamaccount "github.com/forbearing/gst/internal/service/iam/account"
 	serviceiamemail "github.com/forbearing/gst/internal/service/iam/email"
 	serviceiamgroup "github.com/forbearing/gst/internal/service/iam/group"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
 	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	serviceiamtenant "github.com/forbearing/gst/internal/service/iam/tenant"
 	serviceiamuser "github.com/forbearing/gst/internal/service/iam/user"
@@ -27,6 +29,29 @@ type Config struct {
 	EnableTenant      bool          // EnableTenant enables tenant module, default is false
 	DefaultUsers      []*User       // DefaultUsers are default users to create on registration
 	SessionExpiration time.Duration // SessionExpiration is the session expiration time, default is 8 hours
+
+	// LoginProtection configures brute-force protection for POST /api/login:
+	// per-account and per-ip failure counters, progressive delays, temporary
+	// lockouts, captcha signalling and alert hooks. It is disabled by default.
+	// The passkey login and the twofa endpoints verifying the password share the counters and lockouts.
+	LoginProtection LoginProtectionConfig
+
+	// PasswordPolicy configures password rules for signup, change password and reset password:
+	// length and character classes, common/breached password lists, reuse of the last N
+	// passwords, maximum password age and the password hasher. The zero value keeps the
+	// previous behavior: 6 to 72 characters hashed with bcrypt.
+	PasswordPolicy PasswordPolicyConfig
+
+	// SessionPolicy limits the concurrent sessions per user or group, evicting the oldest sessions
+	// or rejecting new logins, and configures trusted devices: logins with "remember_device" skip
+	// the second factor on that device for TrustedDeviceTTL, and logins from new devices can be
+	// notified by email. The zero value keeps the previous behavior: unlimited sessions, no trusted devices.
+	SessionPolicy SessionPolicyConfig
+
+	// Impersonation enables administrators to act as another user for a limited time.
+	// The session carries both identities, operation logs record the administrator as real_user,
+	// and password, email and 2FA management are blocked while impersonating. It is disabled by default.
+	Impersonation ImpersonationConfig
 }
 
 // Register registers IAM models, API routes, middleware, and scheduled jobs.
@@ -43,6 +68,8 @@ type Config struct {
 //   - DELETE /api/iam/sessions
 //   - DELETE /api/iam/sessions/:id
 //   - GET    /api/online-users
+//   - POST   /api/iam/impersonation
+//   - DELETE /api/iam/impersonation
 //
 // Account management routes:
 //   - POST   /api/login
@@ -51,6 +78,8 @@ type Config struct {
 //   - POST   /api/iam/change-password
 //   - POST   /api/iam/reset-password
 //   - POST   /api/iam/account-status
+//   - GET    /api/iam/admin/login-lockouts
+//   - POST   /api/iam/admin/login-unlock
 //
 // IAM resource routes:
 //   - POST   /api/iam/users
@@ -91,6 +120,17 @@ type Config struct {
 // Configuration:
 //   - Tenant routes are registered only when EnableTenant is true
 //   - SessionExpiration defaults to 8 hours when not configured
+//   - LoginProtection is disabled unless LoginProtection.Enable is true, lockouts are recorded
+//     in login logs with status "locked" when logmgmt module is registered
+//   - PasswordPolicy.MaxAge sets MustChangePassword on login once the password is older than MaxAge
+//   - Passwords hashed by a legacy hasher are rehashed with PasswordPolicy.Hasher on successful login
+//   - SessionPolicy.MaxSessions, GroupMaxSessions and UserMaxSessions are unlimited when 0, SessionPolicy.Strategy
+//     defaults to evict_oldest, rejected logins respond 409 with code CodeTooManySessions
+//   - Trusted devices are disabled unless SessionPolicy.TrustedDeviceTTL is set, they are bound to the "trusted_device"
+//     cookie and the device fingerprint, and revoked when the password changes or is reset
+//   - New-device login emails are sent with template "iam/email/new-device" when SessionPolicy.NotifyNewDevice is true
+//   - Impersonation routes are registered only when Impersonation.Enable is true, root, admin, superusers
+//     and subjects granted POST on /api/iam/impersonation by RBAC may impersonate unless Impersonation.Authorize is set
 //
 // NOTE: Register IAM modules before authz modules because authz middleware depends on IAMSession.
 func Register(config ...Config) {
@@ -110,16 +150,30 @@ func Register(config ...Config) {
 
 	// Set session expiration in service layer
 	serviceiamsession.SetSessionExpiration(cfg.SessionExpiration)
+	// Set login brute-force protection in service layer
+	serviceiamaccount.SetLoginProtection(cfg.LoginProtection)
+	// Set concurrent session limits and trusted devices in service layer
+	serviceiamsession.SetSessionPolicy(cfg.SessionPolicy)
+	// Set admin impersonation in service layer
+	serviceiamsession.SetImpersonation(cfg.Impersonation)
+	// Set password policy and password hasher in service layer
+	if err := serviceiampassword.SetConfig(cfg.PasswordPolicy); err != nil {
+		panic(err)
+	}
 
 	// Register auth middleware before protected routes so auth handlers are attached deterministically.
 	middleware.RegisterAuth(middleware.IAMSession())
 
+	model.Register[*modeliamuser.PasswordHistory]()
+
 	module.Use(module.NewWrapper("/login", "id", true, &serviceiamaccount.LoginService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/logout", "id", false, &serviceiamaccount.LogoutService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/signup", "id", true, &serviceiamaccount.SignupService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/iam/change-password", "id", false, &serviceiamaccount.ChangePasswordService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/iam/reset-password", "id", false, &serviceiamaccount.ResetPasswordService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/iam/account-status", "id", false, &serviceiamaccount.AccountStatusService{}), consts.PHASE_CREATE)
+	module.Use(module.NewWrapper("/iam/admin/login-lockouts", "id", false, &serviceiamaccount.LoginLockoutListService{}), consts.PHASE_LIST)
+	module.Use(module.NewWrapper("/iam/admin/login-unlock", "id", false, &serviceiamaccount.LoginUnlockService{}), consts.PHASE_CREATE)
 	module.Use(
 		module.NewWrapper("/iam/users", "id", false, &serviceiamuser.UserService{}),
 		consts.PHASE_CREATE,
@@ -172,6 +226,11 @@ func Register(config ...Config) {
 	module.UseCustom(module.NewWrapper("/iam/sessions", "id", false, &serviceiamsession.SessionsDeleteAllService{}), consts.PHASE_DELETE)
 	module.Use(module.NewWrapper("/iam/sessions", "id", false, &serviceiamsession.SessionsDeleteService{}), consts.PHASE_DELETE)
 	module.Use(module.NewWrapper("/online-users", "id", false, &service.Base[*OnlineUser, *OnlineUser, *OnlineUser]{}), consts.PHASE_LIST)
+	if cfg.Impersonation.Enable {
+		model.Register[*modeliamsession.Impersonation]()
+		module.Use(module.NewWrapper("/iam/impersonation", "id", false, &serviceiamsession.ImpersonationCreateService{}), consts.PHASE_CREATE)
+		module.UseCustom(module.NewWrapper("/iam/impersonation", "id", false, &serviceiamsession.ImpersonationDeleteService{}), consts.PHASE_DELETE)
+	}
 
 	module.Use(module.NewWrapper("/iam/email/verification-request", "id", true, &serviceiamemail.VerificationRequestService{}), consts.PHASE_CREATE)
 	module.Use(module.NewWrapper("/iam/email/verification-resend", "id", true, &serviceiamemail.VerificationResendService{}), consts.PHASE_CREATE)
@@ -194,7 +253,7 @@ func Register(config ...Config) {
 	}
 
 	// cleanup the oneline user that not active every 30 seconds, will run immediately after application bootstrap.
-	cronjob.Register(cronjobiam.CleanupOnlineUser, "*/30 * * * * *", "cleanup online user", cronjob.Config{RunImmediately: true})
+	cronjob.Register(cronjobiam.CleanupOnlineUser, "*/30 * * * * *", "cleanup online user", cronjob.Config{RunImmediately: true, Singleton: true, Overlap: cronjob.OverlapSkip})
 }
 
 // GetSessionExpiration returns the configured session expiration time.
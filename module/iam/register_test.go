@@ -28,6 +28,10 @@ var (
 	groupAPI          = fmt.Sprintf("http://localhost:%d/api/iam/groups", port)
 	tenantAPI         = fmt.Sprintf("http://localhost:%d/api/iam/tenants", port)
 	currentAPI        = fmt.Sprintf("http://localhost:%d/api/iam/session/current", port)
+	loginLockoutsAPI  = fmt.Sprintf("http://localhost:%d/api/iam/admin/login-lockouts", port)
+	loginUnlockAPI    = fmt.Sprintf("http://localhost:%d/api/iam/admin/login-unlock", port)
+
+	loginAlerts = make(chan iam.LoginAlert, 16)
 )
 
 type ListResponse[T any] struct {
@@ -45,7 +49,19 @@ func init() {
 	os.Setenv(config.LOGGER_DIR, "./logs")
 	os.Setenv(config.AUTH_NONE_EXPIRE_TOKEN, token)
 
-	iam.Register(iam.Config{EnableTenant: true})
+	iam.Register(iam.Config{
+		EnableTenant: true,
+		LoginProtection: iam.LoginProtectionConfig{
+			Enable:             true,
+			MaxAccountFailures: 3,
+			MaxIPFailures:      1000,
+			DelayBase:          time.Millisecond,
+			MaxDelay:           10 * time.Millisecond,
+			AlertHook:          func(alert iam.LoginAlert) { loginAlerts <- alert },
+		},
+		PasswordPolicy: iam.PasswordPolicyConfig{HistorySize: 3},
+		Impersonation:  iam.ImpersonationConfig{Enable: true, MaxDuration: 30 * time.Minute},
+	})
 	if err := bootstrap.Bootstrap(); err != nil {
 		panic(err)
 	}
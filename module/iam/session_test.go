@@ -11,8 +11,10 @@ import (
 	"github.com/forbearing/gst/internal/helper"
 	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 	"github.com/forbearing/gst/module/iam"
 	"github.com/forbearing/gst/provider/redis"
+	"github.com/forbearing/gst/response"
 	"github.com/forbearing/gst/types"
 	"github.com/stretchr/testify/require"
 )
@@ -1015,6 +1017,57 @@ func TestSessionCurrentDelete(t *testing.T) {
 	})
 }
 
+func TestSessionLimit(t *testing.T) {
+	setupSessionRedisCleanup(t)
+
+	account := newSessionTestAccount(t)
+	t.Cleanup(func() { serviceiamsession.SetSessionPolicy(iam.SessionPolicyConfig{}) })
+
+	t.Run("evict_oldest", func(t *testing.T) {
+		serviceiamsession.SetSessionPolicy(iam.SessionPolicyConfig{
+			UserMaxSessions: map[string]int{account.UserID: 2},
+			Strategy:        iam.SessionLimitEvictOldest,
+		})
+
+		first := loginSession(t, account.Username, account.Password)
+		second := loginSession(t, account.Username, account.Password)
+		third := loginSession(t, account.Username, account.Password)
+
+		requireSessionNotFound(t, first)
+		requireUserSessionNotContains(t, account.UserID, first)
+		requireUserSessionContains(t, account.UserID, second)
+		requireUserSessionContains(t, account.UserID, third)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		serviceiamsession.SetSessionPolicy(iam.SessionPolicyConfig{
+			UserMaxSessions: map[string]int{account.UserID: 2},
+			Strategy:        iam.SessionLimitReject,
+		})
+
+		cli, err := client.New(loginAPI)
+		require.NoError(t, err)
+		_, err = cli.Create(iam.LoginReq{Username: account.Username, Password: account.Password})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), fmt.Sprintf(`"code":%d`, response.CodeTooManySessions.Code()))
+	})
+
+	t.Run("device_recorded", func(t *testing.T) {
+		serviceiamsession.SetSessionPolicy(iam.SessionPolicyConfig{})
+
+		sessionID := loginSession(t, account.Username, account.Password)
+		session, err := redis.Cache[modeliamsession.Session]().Get(modeliamsession.SessionIDKey(sessionID))
+		require.NoError(t, err)
+		require.NotEmpty(t, session.DeviceID)
+		require.Equal(t, serviceiamsession.DeviceFingerprint(account.UserID, session.Platform, session.OS, session.BrowserName), session.DeviceID)
+
+		device, err := redis.Cache[iam.Device]().Get(modeliamsession.SessionDeviceKey(account.UserID, session.DeviceID))
+		require.NoError(t, err)
+		require.Equal(t, account.UserID, device.UserID)
+		require.False(t, device.FirstSeenAt.IsZero())
+	})
+}
+
 func setupSessionRedisCleanup(t *testing.T) {
 	t.Helper()
 
@@ -7,6 +7,9 @@ import (
 	modeliamsession "github.com/forbearing/gst/internal/model/iam/session"
 	modeliamtenant "github.com/forbearing/gst/internal/model/iam/tenant"
 	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiamaccount "github.com/forbearing/gst/internal/service/iam/account"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
+	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
 )
 
 // account
@@ -26,6 +29,19 @@ type (
 	AccountStatusReq = modeliamaccount.AccountStatusReq
 	AccountStatusRsp = modeliamaccount.AccountStatusRsp
 
+	LoginLock           = modeliamaccount.LoginLock
+	LoginLockKind       = modeliamaccount.LoginLockKind
+	LoginLockoutListReq = modeliamaccount.LoginLockoutListReq
+	LoginLockoutListRsp = modeliamaccount.LoginLockoutListRsp
+	LoginUnlockReq      = modeliamaccount.LoginUnlockReq
+	LoginUnlockRsp      = modeliamaccount.LoginUnlockRsp
+
+	LoginProtectionConfig = serviceiamaccount.LoginProtectionConfig
+	PasswordPolicyConfig  = serviceiampassword.Config
+	PasswordHistory       = modeliamuser.PasswordHistory
+	LoginAlert            = serviceiamaccount.LoginAlert
+	LoginAlertKind        = serviceiamaccount.LoginAlertKind
+
 	User   = modeliamuser.User
 	Group  = modeliamgroup.Group
 	Tenant = modeliamtenant.Tenant
@@ -58,6 +74,20 @@ type (
 	AdminSessionsDeleteReq = modeliamsession.AdminSessionsDeleteReq
 	AdminSessionsDeleteRsp = modeliamsession.AdminSessionsDeleteRsp
 
+	SessionPolicyConfig  = serviceiamsession.SessionPolicyConfig
+	SessionLimitStrategy = serviceiamsession.SessionLimitStrategy
+	Device               = modeliamsession.Device
+	TrustedDevice        = modeliamsession.TrustedDevice
+
+	ImpersonationConfig    = serviceiamsession.ImpersonationConfig
+	Impersonator           = modeliamsession.Impersonator
+	ImpersonatorView       = modeliamsession.ImpersonatorView
+	Impersonation          = modeliamsession.Impersonation
+	ImpersonationCreateReq = modeliamsession.ImpersonationCreateReq
+	ImpersonationCreateRsp = modeliamsession.ImpersonationCreateRsp
+	ImpersonationDeleteReq = modeliamsession.ImpersonationDeleteReq
+	ImpersonationDeleteRsp = modeliamsession.ImpersonationDeleteRsp
+
 	AdminUserSessionsListReq   = modeliamsession.AdminUserSessionsListReq
 	AdminUserSessionsListRsp   = modeliamsession.AdminUserSessionsListRsp
 	AdminUserSessionsDeleteReq = modeliamsession.AdminUserSessionsDeleteReq
@@ -84,3 +114,23 @@ type (
 	EmailVerificationRequestReq = modeliamemail.VerificationRequestReq
 	EmailVerificationRequestRsp = modeliamemail.VerificationRequestRsp
 )
+
+// login protection
+const (
+	LoginLockKindAccount = modeliamaccount.LoginLockKindAccount
+	LoginLockKindIP      = modeliamaccount.LoginLockKindIP
+
+	LoginAlertAccountLocked = serviceiamaccount.LoginAlertAccountLocked
+	LoginAlertIPLocked      = serviceiamaccount.LoginAlertIPLocked
+
+	HeaderCaptchaRequired = serviceiamaccount.HeaderCaptchaRequired
+)
+
+// session policy
+const (
+	SessionLimitEvictOldest = serviceiamsession.SessionLimitEvictOldest
+	SessionLimitReject      = serviceiamsession.SessionLimitReject
+)
+
+// impersonation
+const ImpersonationResource = serviceiamsession.ImpersonationResource
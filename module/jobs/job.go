@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"github.com/forbearing/gst/cronjob"
+	modelcronjob "github.com/forbearing/gst/internal/model/cronjob"
+	modeljobs "github.com/forbearing/gst/internal/model/jobs"
+	servicejobs "github.com/forbearing/gst/internal/service/jobs"
+	"github.com/forbearing/gst/service"
+	"github.com/forbearing/gst/types"
+)
+
+var (
+	_ types.Module[*Job, *Job, *JobListRsp]                                    = (*JobModule)(nil)
+	_ types.Module[*JobTrigger, *JobTrigger, *JobActionRsp]                    = (*JobTriggerModule)(nil)
+	_ types.Module[*JobPause, *JobPause, *JobActionRsp]                        = (*JobPauseModule)(nil)
+	_ types.Module[*JobResume, *JobResume, *JobActionRsp]                      = (*JobResumeModule)(nil)
+	_ types.Module[*JobEvent, *JobEvent, *JobEvent]                            = (*JobEventModule)(nil)
+	_ types.Module[*Run, *Run, *Run]                                           = (*RunModule)(nil)
+	_ types.Module[*DeadLetter, *DeadLetter, *DeadLetter]                      = (*DeadLetterModule)(nil)
+	_ types.Module[*DeadLetterReplay, *DeadLetterReplay, *DeadLetterReplayRsp] = (*DeadLetterReplayModule)(nil)
+)
+
+type (
+	Job        = modeljobs.Job
+	JobListRsp = modeljobs.JobListRsp
+	JobInfo    = modeljobs.JobInfo
+	JobModule  struct{}
+
+	JobTrigger       = modeljobs.JobTrigger
+	JobPause         = modeljobs.JobPause
+	JobResume        = modeljobs.JobResume
+	JobActionRsp     = modeljobs.JobActionRsp
+	JobTriggerModule struct{}
+	JobPauseModule   struct{}
+	JobResumeModule  struct{}
+
+	JobEvent       = modeljobs.JobEvent
+	JobEventModule struct{}
+
+	Run        = modelcronjob.Run
+	RunStatus  = modelcronjob.RunStatus
+	RunTrigger = modelcronjob.RunTrigger
+	RunModule  struct{}
+
+	RetryPolicy = cronjob.RetryPolicy
+	Alert       = cronjob.Alert
+	Alerter     = cronjob.Alerter
+
+	DeadLetter             = modelcronjob.DeadLetter
+	DeadLetterModule       struct{}
+	DeadLetterReplay       = modeljobs.DeadLetterReplay
+	DeadLetterReplayRsp    = modeljobs.DeadLetterReplayRsp
+	DeadLetterReplayModule struct{}
+)
+
+const (
+	RunStatusSuccess = modelcronjob.RunStatusSuccess
+	RunStatusFailure = modelcronjob.RunStatusFailure
+	RunStatusTimeout = modelcronjob.RunStatusTimeout
+	RunStatusSkipped = modelcronjob.RunStatusSkipped
+
+	RunTriggerSchedule  = modelcronjob.RunTriggerSchedule
+	RunTriggerImmediate = modelcronjob.RunTriggerImmediate
+	RunTriggerManual    = modelcronjob.RunTriggerManual
+	RunTriggerReplay    = modelcronjob.RunTriggerReplay
+)
+
+func (*JobModule) Service() types.Service[*Job, *Job, *JobListRsp] {
+	return &servicejobs.JobListService{}
+}
+func (*JobModule) Route() string { return "/jobs" }
+func (*JobModule) Pub() bool     { return false }
+func (*JobModule) Param() string { return "name" }
+
+func (*JobTriggerModule) Service() types.Service[*JobTrigger, *JobTrigger, *JobActionRsp] {
+	return &servicejobs.JobTriggerService{}
+}
+func (*JobTriggerModule) Route() string { return "/jobs/:name/trigger" }
+func (*JobTriggerModule) Pub() bool     { return false }
+func (*JobTriggerModule) Param() string { return "name" }
+
+func (*JobPauseModule) Service() types.Service[*JobPause, *JobPause, *JobActionRsp] {
+	return &servicejobs.JobPauseService{}
+}
+func (*JobPauseModule) Route() string { return "/jobs/:name/pause" }
+func (*JobPauseModule) Pub() bool     { return false }
+func (*JobPauseModule) Param() string { return "name" }
+
+func (*JobResumeModule) Service() types.Service[*JobResume, *JobResume, *JobActionRsp] {
+	return &servicejobs.JobResumeService{}
+}
+func (*JobResumeModule) Route() string { return "/jobs/:name/resume" }
+func (*JobResumeModule) Pub() bool     { return false }
+func (*JobResumeModule) Param() string { return "name" }
+
+func (*JobEventModule) Service() types.Service[*JobEvent, *JobEvent, *JobEvent] {
+	return &servicejobs.JobEventService{}
+}
+func (*JobEventModule) Route() string { return "/jobs/events" }
+func (*JobEventModule) Pub() bool     { return false }
+func (*JobEventModule) Param() string { return "id" }
+
+func (*RunModule) Service() types.Service[*Run, *Run, *Run] {
+	return &service.Base[*Run, *Run, *Run]{}
+}
+func (*RunModule) Route() string { return "/jobs/runs" }
+func (*RunModule) Pub() bool     { return false }
+func (*RunModule) Param() string { return "id" }
+
+func (*DeadLetterModule) Service() types.Service[*DeadLetter, *DeadLetter, *DeadLetter] {
+	return &service.Base[*DeadLetter, *DeadLetter, *DeadLetter]{}
+}
+func (*DeadLetterModule) Route() string { return "/jobs/deadletters" }
+func (*DeadLetterModule) Pub() bool     { return false }
+func (*DeadLetterModule) Param() string { return "id" }
+
+func (*DeadLetterReplayModule) Service() types.Service[*DeadLetterReplay, *DeadLetterReplay, *DeadLetterReplayRsp] {
+	return &servicejobs.DeadLetterReplayService{}
+}
+func (*DeadLetterReplayModule) Route() string { return "/jobs/deadletters/:id/replay" }
+func (*DeadLetterReplayModule) Pub() bool     { return false }
+func (*DeadLetterReplayModule) Param() string { return "id" }
@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/module"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// Config is the configuration of jobs module.
+type Config struct {
+	// HistoryRetention is the retention of the cronjob run history, default is 7 days.
+	HistoryRetention time.Duration
+
+	// Alerters receive the alerts of the cronjobs failing Config.AlertAfter times in a row,
+	// eg: cronjob.NewFeishuAlerter, cronjob.NewWebhookAlerter.
+	Alerters []cronjob.Alerter
+}
+
+// Register registers the runtime management API of the cronjobs,
+// it enables the cronjob run history, see cronjob.EnableHistory.
+//
+// Models:
+//   - Run
+//   - DeadLetter
+//
+// Routes:
+//   - GET    /api/jobs
+//   - POST   /api/jobs/:name/trigger
+//   - POST   /api/jobs/:name/pause
+//   - POST   /api/jobs/:name/resume
+//   - GET    /api/jobs/events (SSE)
+//   - GET    /api/jobs/runs
+//   - GET    /api/jobs/runs/:id
+//   - GET    /api/jobs/deadletters
+//   - GET    /api/jobs/deadletters/:id
+//   - DELETE /api/jobs/deadletters/:id
+//   - POST   /api/jobs/deadletters/:id/replay
+//
+// Cronjob:
+//   - cleanup cronjob run history and dead letters hourly.
+//
+// Trigger runs the cronjob on the instance serving the request, pause and resume
+// are propagated to the other instances through redis if enabled.
+// The list and the events reflect the instance serving the request,
+// the run history covers all instances. Failed runs, after all retries, are kept as dead letters
+// until deleted or expired, replay runs the cronjob again on the instance serving the request.
+func Register(cfgs ...Config) {
+	var cfg Config
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
+	cronjob.EnableHistory(cfg.HistoryRetention)
+	for _, a := range cfg.Alerters {
+		cronjob.AddAlerter(a)
+	}
+
+	module.Use(&JobModule{}, consts.PHASE_LIST)
+	module.UseCustom(&JobTriggerModule{}, consts.PHASE_CREATE)
+	module.UseCustom(&JobPauseModule{}, consts.PHASE_CREATE)
+	module.UseCustom(&JobResumeModule{}, consts.PHASE_CREATE)
+	module.Use(&JobEventModule{}, consts.PHASE_LIST)
+
+	module.Use[*Run,
+		*Run,
+		*Run](
+		&RunModule{},
+		consts.PHASE_LIST,
+		consts.PHASE_GET,
+	)
+
+	module.Use[*DeadLetter,
+		*DeadLetter,
+		*DeadLetter](
+		&DeadLetterModule{},
+		consts.PHASE_LIST,
+		consts.PHASE_GET,
+		consts.PHASE_DELETE,
+	)
+	module.UseCustom(&DeadLetterReplayModule{}, consts.PHASE_CREATE)
+}
@@ -0,0 +1,235 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/client"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/cronjob"
+	"github.com/forbearing/gst/internal/helper"
+	"github.com/forbearing/gst/module/jobs"
+	"github.com/forbearing/gst/task"
+	"github.com/forbearing/gst/types"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	token = "-"
+	port  = 8000
+
+	jobsAPI        = fmt.Sprintf("http://localhost:%d/api/jobs", port)
+	eventsAPI      = fmt.Sprintf("http://localhost:%d/api/jobs/events", port)
+	runsAPI        = fmt.Sprintf("http://localhost:%d/api/jobs/runs", port)
+	deadlettersAPI = fmt.Sprintf("http://localhost:%d/api/jobs/deadletters", port)
+)
+
+const (
+	failingJob = "jobs test failing"
+	taskJob    = "jobs test task"
+)
+
+func init() {
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "true")
+	os.Setenv(config.SERVER_PORT, fmt.Sprintf("%d", port))
+	os.Setenv(config.LOGGER_DIR, "./logs")
+	os.Setenv(config.AUTH_NONE_EXPIRE_TOKEN, token)
+
+	cronjob.Register(func() error { return errors.New("failed") }, "0 0 0 1 1 *", failingJob)
+	task.Register(func() error { return nil }, time.Hour, taskJob) //nolint:staticcheck
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+
+	go func() {
+		jobs.Register()
+
+		if err := bootstrap.Run(); err != nil {
+			panic(err)
+		}
+	}()
+
+	for {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			l.Close()
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		if errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+		panic(err)
+
+	}
+}
+
+// action posts to /api/jobs/:name/<action>.
+func action(name, action string) (*client.Resp, error) {
+	cli, err := client.New(fmt.Sprintf("%s/%s/%s", jobsAPI, name, action))
+	if err != nil {
+		return nil, err
+	}
+	return cli.Create(struct{}{})
+}
+
+func TestJobs(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		cli, err := client.New(jobsAPI)
+		require.NoError(t, err)
+
+		items := make([]*jobs.JobInfo, 0)
+		var total int64
+		_, err = cli.List(&items, &total)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(items)), total)
+
+		found := make(map[string]*jobs.JobInfo)
+		for _, item := range items {
+			found[item.Name] = item
+		}
+		require.Contains(t, found, failingJob)
+		require.Equal(t, "0 0 0 1 1 *", found[failingJob].Spec)
+		require.NotNil(t, found[failingJob].NextRun)
+		require.False(t, found[failingJob].Paused)
+
+		// The tasks are listed with the cronjobs.
+		require.Contains(t, found, taskJob)
+		require.Equal(t, "@every 1h0m0s", found[taskJob].Spec)
+		require.Contains(t, found, "runtime stats")
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		resp, err := action(failingJob, "pause")
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp jobs.JobActionRsp) {
+			require.Equal(t, failingJob, rsp.Name)
+			require.True(t, rsp.Paused)
+		})
+
+		_, err = action(failingJob, "pause")
+		require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusConflict))
+
+		resp, err = action(failingJob, "resume")
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp jobs.JobActionRsp) {
+			require.Equal(t, failingJob, rsp.Name)
+			require.False(t, rsp.Paused)
+		})
+
+		_, err = action(failingJob, "resume")
+		require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusConflict))
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		for _, a := range []string{"trigger", "pause", "resume"} {
+			_, err := action("not-exists", a)
+			require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusNotFound), a)
+		}
+	})
+
+	t.Run("trigger and events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cli, err := client.New(eventsAPI, client.WithContext(ctx))
+		require.NoError(t, err)
+
+		// The stream may not be subscribed when the first trigger runs, trigger until the events arrive.
+		go func() {
+			ticker := time.NewTicker(200 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, _ = action(failingJob, "trigger")
+				}
+			}
+		}()
+
+		var started, finished bool
+		errDone := errors.New("done")
+		err = cli.StreamURL(http.MethodGet, "", nil, func(event types.Event) error {
+			data, _ := event.Data.(string)
+			run := new(jobs.Run)
+			if err := json.Unmarshal([]byte(data), run); err != nil || run.Job != failingJob {
+				return nil
+			}
+			switch cronjob.EventType(event.Event) {
+			case cronjob.EventStarted:
+				started = true
+			case cronjob.EventFinished:
+				require.Equal(t, jobs.RunTriggerManual, run.Trigger)
+				require.Equal(t, jobs.RunStatusFailure, run.Status)
+				require.Equal(t, "failed", run.Error)
+				finished = true
+			}
+			if started && finished {
+				return errDone
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, errDone)
+	})
+
+	var deadLetterID string
+	t.Run("runs", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			cli, err := client.New(runsAPI, client.WithQuery("job", failingJob))
+			require.NoError(t, err)
+			items := make([]*jobs.Run, 0)
+			var total int64
+			_, err = cli.List(&items, &total)
+			return err == nil && len(items) > 0 && items[0].Status == jobs.RunStatusFailure
+		}, 5*time.Second, 100*time.Millisecond)
+
+		cli, err := client.New(deadlettersAPI, client.WithQuery("job", failingJob))
+		require.NoError(t, err)
+		items := make([]*jobs.DeadLetter, 0)
+		var total int64
+		_, err = cli.List(&items, &total)
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		require.Equal(t, jobs.RunStatusFailure, items[0].Status)
+		deadLetterID = items[0].ID
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		require.NotEmpty(t, deadLetterID)
+		cli, err := client.New(fmt.Sprintf("%s/%s/replay", deadlettersAPI, deadLetterID))
+		require.NoError(t, err)
+		resp, err := cli.Create(struct{}{})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp jobs.DeadLetterReplayRsp) {
+			require.Equal(t, deadLetterID, rsp.ID)
+			require.Equal(t, failingJob, rsp.Job)
+		})
+
+		// The replay is recorded as a run of the dead letter.
+		require.Eventually(t, func() bool {
+			cli, err := client.New(runsAPI, client.WithQuery("replay_of", deadLetterID))
+			require.NoError(t, err)
+			items := make([]*jobs.Run, 0)
+			var total int64
+			_, err = cli.List(&items, &total)
+			return err == nil && len(items) == 1 && items[0].Trigger == jobs.RunTriggerReplay
+		}, 5*time.Second, 100*time.Millisecond)
+
+		cli, err = client.New(fmt.Sprintf("%s/not-exists/replay", deadlettersAPI))
+		require.NoError(t, err)
+		_, err = cli.Create(struct{}{})
+		require.ErrorContains(t, err, fmt.Sprintf("response status code: %d", http.StatusNotFound))
+	})
+}
@@ -6,19 +6,42 @@ import (
 	"github.com/forbearing/gst/types"
 )
 
-var _ types.Module[*LoginLog, *LoginLog, *LoginLog] = (*LoginLogModule)(nil)
+var (
+	_ types.Module[*LoginLog, *LoginLog, *LoginLog]                      = (*LoginLogModule)(nil)
+	_ types.Module[*LoginLogStats, *LoginLogStatsReq, *LoginLogStatsRsp] = (*LoginLogStatsModule)(nil)
+)
 
 type (
 	LoginStatus = modellogmgmt.LoginStatus
 
 	LoginLog       = modellogmgmt.LoginLog
 	LoginLogModule struct{}
+
+	LoginLogStats       = modellogmgmt.LoginLogStats
+	LoginLogStatsReq    = modellogmgmt.LoginLogStatsReq
+	LoginLogStatsRsp    = modellogmgmt.LoginLogStatsRsp
+	StatsBucket         = modellogmgmt.StatsBucket
+	StatsPoint          = modellogmgmt.StatsPoint
+	LoginLogStatsModule struct{}
+
+	AnomalyConfig = servicelogmgmt.AnomalyConfig
 )
 
 const (
 	LoginStatusSuccess = modellogmgmt.LoginStatusSuccess
 	LoginStatusFailure = modellogmgmt.LoginStatusFailure
 	LoginStatusLogout  = modellogmgmt.LoginStatusLogout
+	LoginStatusLocked  = modellogmgmt.LoginStatusLocked
+
+	RiskFactorNewCountry       = modellogmgmt.RiskFactorNewCountry
+	RiskFactorImpossibleTravel = modellogmgmt.RiskFactorImpossibleTravel
+	RiskFactorNewASN           = modellogmgmt.RiskFactorNewASN
+	RiskFactorFailedAttempts   = modellogmgmt.RiskFactorFailedAttempts
+
+	RiskLevelNone   = modellogmgmt.RiskLevelNone
+	RiskLevelLow    = modellogmgmt.RiskLevelLow
+	RiskLevelMedium = modellogmgmt.RiskLevelMedium
+	RiskLevelHigh   = modellogmgmt.RiskLevelHigh
 )
 
 func (*LoginLogModule) Service() types.Service[*LoginLog, *LoginLog, *LoginLog] {
@@ -27,3 +50,10 @@ func (*LoginLogModule) Service() types.Service[*LoginLog, *LoginLog, *LoginLog]
 func (*LoginLogModule) Route() string { return "/log/loginlog" }
 func (*LoginLogModule) Pub() bool     { return false }
 func (*LoginLogModule) Param() string { return "id" }
+
+func (*LoginLogStatsModule) Service() types.Service[*LoginLogStats, *LoginLogStatsReq, *LoginLogStatsRsp] {
+	return &servicelogmgmt.LoginLogStatsService{}
+}
+func (*LoginLogStatsModule) Route() string { return "/log/loginlog/stats" }
+func (*LoginLogStatsModule) Pub() bool     { return false }
+func (*LoginLogStatsModule) Param() string { return "id" }
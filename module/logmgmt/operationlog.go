@@ -3,6 +3,7 @@ package logmgmt
 import (
 	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
+	"github.com/forbearing/gst/pkg/auditmanager"
 	"github.com/forbearing/gst/types"
 )
 
@@ -10,7 +11,23 @@ var _ types.Module[*OperationLog, *OperationLog, *OperationLog] = (*OperationLog
 
 type (
 	OperationLog       = modellogmgmt.OperationLog
+	FieldChange        = modellogmgmt.FieldChange
 	OperationLogModule struct{}
+
+	AuditEvent     = auditmanager.Event
+	AuditEventType = auditmanager.EventType
+	Sink           = auditmanager.Sink
+	SinkConfig     = auditmanager.SinkConfig
+	OverflowPolicy = auditmanager.OverflowPolicy
+)
+
+const (
+	AuditEventOperation = auditmanager.EventOperation
+	AuditEventLogin     = auditmanager.EventLogin
+
+	OverflowDropNewest = auditmanager.OverflowDropNewest
+	OverflowDropOldest = auditmanager.OverflowDropOldest
+	OverflowBlock      = auditmanager.OverflowBlock
 )
 
 func (*OperationLogModule) Service() types.Service[*OperationLog, *OperationLog, *OperationLog] {
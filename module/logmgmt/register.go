@@ -1,33 +1,89 @@
 package logmgmt
 
 import (
+	"crypto/ed25519"
 	"os"
 
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/cronjob"
 	cronjoblogmgmt "github.com/forbearing/gst/internal/cronjob/logmgmt"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
 	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
+	"github.com/forbearing/gst/model"
 	"github.com/forbearing/gst/module"
+	"github.com/forbearing/gst/pkg/auditmanager"
 	"github.com/forbearing/gst/types/consts"
 )
 
+// Config is the configuration of logmgmt module.
+type Config struct {
+	// TamperEvident makes the operation log append-only and hash chained, default is false.
+	//
+	// Every OperationLog stores the hash of its content chained over the previous record,
+	// so altering, deleting or reordering records is detected by the verification.
+	// Updating and deleting operation logs through the framework is rejected,
+	// and the hourly cleanup keeps them.
+	//
+	// Multiple instances may write the operation logs: (chain_key, seq) is unique, an instance
+	// whose cached chain head is stale reloads it from the database and retries.
+	//
+	// NOTE: append-only is enforced by the model hooks, writes that skip the hooks(WithoutHook,
+	// raw SQL) are not rejected. Revoke UPDATE and DELETE on the operation log table from
+	// the database user to enforce it, the verification still detects such writes.
+	TamperEvident bool
+
+	// ChainScope decides whether all operation logs share one chain ("global")
+	// or every table has its own chain ("table"), default is "global".
+	ChainScope ChainScope
+
+	// SigningKey signs the periodic checkpoints of the chain heads.
+	// Checkpoints are disabled if it is nil.
+	SigningKey ed25519.PrivateKey
+
+	// CheckpointSpec is the cron spec of the checkpoints, default is hourly "0 0 * * * *".
+	CheckpointSpec string
+
+	// CheckpointSink exports the checkpoints to an external write-once location,
+	// eg: MinioCheckpointSink. Checkpoints are only saved to database if it is nil.
+	CheckpointSink CheckpointSink
+
+	// Sinks forward the operation logs and login logs to external systems such as a SIEM,
+	// in addition to the database. Every sink has its own buffer, filter and overflow policy,
+	// see package pkg/auditmanager/sink for the syslog, file, kafka and elasticsearch sinks.
+	Sinks []SinkConfig
+
+	// Anomaly enriches the login logs with the GeoIP location and network of the client ip
+	// from local MaxMind-format databases, and scores every login by new country, impossible
+	// travel, new network and recent failures. Risky logins require the second factor of IAM
+	// login, even on trusted devices. It is disabled by default.
+	Anomaly AnomalyConfig
+}
+
 // Register registers two modules: LoginLog and OperationLog.
 //
 // Models:
 //   - LoginLog
 //   - OperationLog
+//   - OperationLogCheckpoint (TamperEvident only)
 //
 // Routes:
-//   - GET /api/log/loginlog
-//   - GET /api/log/loginlog/:id
-//   - GET /api/log/operationlog
-//   - GET /api/log/operationlog/:id
+//   - GET  /api/log/loginlog
+//   - GET  /api/log/loginlog/:id
+//   - GET  /api/log/loginlog/stats
+//   - GET  /api/log/operationlog
+//   - GET  /api/log/operationlog/:id
+//   - POST /api/log/operationlog/verify (TamperEvident only)
 //
 // Cronjob:
 //   - cleanup operationlog and loginlog hourly.
+//   - checkpoint the operationlog chains (TamperEvident with SigningKey only).
 //
 // Enable Audit to records all operation logs.
-func Register() {
+func Register(cfgs ...Config) {
+	var cfg Config
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
 	servicelogmgmt.Enabled = true
 
 	// enable audit function to records the operation logs.
@@ -50,5 +106,39 @@ func Register() {
 		consts.PHASE_GET,
 	)
 
-	cronjob.Register(cronjoblogmgmt.Cleanup, "0 0 * * * *", "cleanup operationlog and loginlog hourly")
+	module.Use(&LoginLogStatsModule{}, consts.PHASE_LIST)
+
+	if err := servicelogmgmt.SetAnomaly(cfg.Anomaly); err != nil {
+		panic(err)
+	}
+
+	cronjob.Register(cronjoblogmgmt.Cleanup, "0 0 * * * *", "cleanup operationlog and loginlog hourly", cronjob.Config{Singleton: true, Overlap: cronjob.OverlapSkip})
+
+	for _, sc := range cfg.Sinks {
+		if err := auditmanager.RegisterSink(sc); err != nil {
+			panic(err)
+		}
+	}
+
+	if cfg.TamperEvident {
+		registerTamperEvident(cfg)
+	}
+}
+
+func registerTamperEvident(cfg Config) {
+	modellogmgmt.SetAppendOnly(true)
+	auditmanager.EnableChain(cfg.ChainScope)
+
+	model.Register[*OperationLogCheckpoint]()
+	module.Use(&OperationLogVerifyModule{}, consts.PHASE_CREATE)
+
+	if cfg.SigningKey == nil {
+		return
+	}
+	servicelogmgmt.CheckpointPublicKey = cfg.SigningKey.Public().(ed25519.PublicKey)
+	spec := cfg.CheckpointSpec
+	if len(spec) == 0 {
+		spec = "0 0 * * * *"
+	}
+	cronjob.Register(cronjoblogmgmt.Checkpoint(cfg.SigningKey, cfg.CheckpointSink), spec, "checkpoint operationlog hash chains", cronjob.Config{Singleton: true, Overlap: cronjob.OverlapSkip})
 }
@@ -0,0 +1,51 @@
+package logmgmt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/provider/minio"
+)
+
+var _ CheckpointSink = (*MinioCheckpointSink)(nil)
+
+// MinioCheckpointSink exports checkpoints as JSON objects "<Prefix>/<chain_key>/<seq>.json"
+// to minio, existing objects are never overwritten.
+//
+// The bucket should have object lock (WORM) enabled with a retention period,
+// otherwise the objects can still be removed by anyone with write access to the bucket.
+// The minio provider must be enabled.
+type MinioCheckpointSink struct {
+	Bucket string // Bucket overrides the bucket of the minio config.
+	Prefix string // Prefix defaults to "operationlog-checkpoints".
+}
+
+func (s *MinioCheckpointSink) Export(ctx context.Context, cp *OperationLogCheckpoint) error {
+	prefix := s.Prefix
+	if len(prefix) == 0 {
+		prefix = "operationlog-checkpoints"
+	}
+	key := path.Join(prefix, cp.ChainKey, fmt.Sprintf("%020d.json", cp.Seq))
+
+	exists, err := minio.Exists(ctx, key, &minio.ExistsOptions{Bucket: s.Bucket})
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Newf("checkpoint object %q already exists", key)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	_, err = minio.Put(ctx, key, bytes.NewReader(data), &minio.PutOptions{
+		ContentType: "application/json",
+		Bucket:      s.Bucket,
+		Size:        int64(len(data)),
+	})
+	return err
+}
@@ -0,0 +1,36 @@
+package logmgmt
+
+import (
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	servicelogmgmt "github.com/forbearing/gst/internal/service/logmgmt"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/types"
+)
+
+var _ types.Module[*OperationLogVerify, *OperationLogVerifyReq, *OperationLogVerifyRsp] = (*OperationLogVerifyModule)(nil)
+
+type (
+	OperationLogCheckpoint = modellogmgmt.OperationLogCheckpoint
+	OperationLogVerify     = modellogmgmt.OperationLogVerify
+	OperationLogVerifyReq  = modellogmgmt.OperationLogVerifyReq
+	OperationLogVerifyRsp  = modellogmgmt.OperationLogVerifyRsp
+	ChainResult            = modellogmgmt.ChainResult
+	BrokenLink             = modellogmgmt.BrokenLink
+
+	ChainScope     = auditmanager.ChainScope
+	CheckpointSink = auditmanager.CheckpointSink
+
+	OperationLogVerifyModule struct{}
+)
+
+const (
+	ChainScopeGlobal = auditmanager.ChainScopeGlobal
+	ChainScopeTable  = auditmanager.ChainScopeTable
+)
+
+func (*OperationLogVerifyModule) Service() types.Service[*OperationLogVerify, *OperationLogVerifyReq, *OperationLogVerifyRsp] {
+	return &servicelogmgmt.OperationLogVerifyService{}
+}
+func (*OperationLogVerifyModule) Route() string { return "/log/operationlog/verify" }
+func (*OperationLogVerifyModule) Pub() bool     { return false }
+func (*OperationLogVerifyModule) Param() string { return "id" }
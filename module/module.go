@@ -35,6 +35,7 @@ import (
 	"github.com/forbearing/gst/service"
 	"github.com/forbearing/gst/types"
 	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
 )
 
 var notify = make(chan struct{})
@@ -135,6 +136,17 @@ func UseCustom[M types.Model, REQ types.Request, RSP types.Response](mod types.M
 	}()
 }
 
+// UseHandler registers raw gin handlers at the path, which is not prefixed with "/api".
+// It is intended for protocol endpoints with their own request and response formats, eg: SCIM,
+// the handlers are responsible for authentication.
+func UseHandler(method, path string, handlers ...gin.HandlerFunc) {
+	go func() {
+		<-notify
+
+		router.Handle(method, path, handlers...)
+	}()
+}
+
 // registerRouter registers an HTTP route with the appropriate router based on mod.Pub().
 // If mod.Pub() returns true, registers with public router; otherwise with authenticated router.
 func registerRouter[M types.Model, REQ types.Request, RSP types.Response](mod types.Module[M, REQ, RSP], route string, cfg *types.ControllerConfig[M], verb consts.HTTPVerb) {
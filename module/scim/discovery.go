@@ -0,0 +1,209 @@
+package scim
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/gin-gonic/gin"
+)
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type serviceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 supported              `json:"patch"`
+	Bulk                  bulkSupported          `json:"bulk"`
+	Filter                filterSupported        `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	ETag                  supported              `json:"etag"`
+	AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+	Meta                  *scim.Meta             `json:"meta"`
+}
+
+type resourceType struct {
+	Schemas          []string   `json:"schemas"`
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Endpoint         string     `json:"endpoint"`
+	Description      string     `json:"description"`
+	Schema           string     `json:"schema"`
+	SchemaExtensions []any      `json:"schemaExtensions"`
+	Meta             *scim.Meta `json:"meta"`
+}
+
+type schemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []schemaAttribute `json:"subAttributes,omitempty"`
+}
+
+type schema struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []schemaAttribute `json:"attributes"`
+	Meta        *scim.Meta        `json:"meta"`
+}
+
+// attr creates a single-valued, optional, case insensitive, read-write string attribute.
+func attr(name string, opts ...func(*schemaAttribute)) schemaAttribute {
+	a := schemaAttribute{Name: name, Type: "string", Mutability: "readWrite", Returned: "default", Uniqueness: "none"}
+	for _, fn := range opts {
+		fn(&a)
+	}
+	return a
+}
+
+func typ(t string) func(*schemaAttribute) { return func(a *schemaAttribute) { a.Type = t } }
+func multiValued(a *schemaAttribute)      { a.MultiValued = true }
+func required(a *schemaAttribute)         { a.Required = true }
+func caseExact(a *schemaAttribute)        { a.CaseExact = true }
+func unique(a *schemaAttribute)           { a.Uniqueness = "server" }
+func readOnly(a *schemaAttribute)         { a.Mutability = "readOnly" }
+func writeOnly(a *schemaAttribute)        { a.Mutability, a.Returned = "writeOnly", "never" }
+func sub(attrs ...schemaAttribute) func(*schemaAttribute) {
+	return func(a *schemaAttribute) { a.Type, a.SubAttributes = "complex", attrs }
+}
+
+func multiValue(name string, opts ...func(*schemaAttribute)) schemaAttribute {
+	return attr(name, append([]func(*schemaAttribute){multiValued, sub(
+		attr("value"),
+		attr("display"),
+		attr("type"),
+		attr("primary", typ("boolean")),
+	)}, opts...)...)
+}
+
+var schemas = []schema{
+	{
+		ID:          scim.SchemaUser,
+		Name:        "User",
+		Description: "User Account",
+		Attributes: []schemaAttribute{
+			attr("userName", required, unique),
+			attr("name", sub(attr("formatted", readOnly), attr("familyName"), attr("givenName"))),
+			attr("displayName"),
+			attr("active", typ("boolean")),
+			attr("password", writeOnly),
+			multiValue("emails", unique),
+			multiValue("phoneNumbers"),
+			multiValue("groups", readOnly),
+		},
+	},
+	{
+		ID:          scim.SchemaGroup,
+		Name:        "Group",
+		Description: "Group",
+		Attributes: []schemaAttribute{
+			attr("displayName", required, unique),
+			attr("members", multiValued, sub(
+				attr("value", caseExact),
+				attr("display", readOnly),
+				attr("type"),
+				attr("$ref", typ("reference"), readOnly),
+			)),
+		},
+	},
+}
+
+var resourceTypes = []resourceType{
+	{ID: "User", Name: "User", Endpoint: "/Users", Description: "User Account", Schema: scim.SchemaUser},
+	{ID: "Group", Name: "Group", Endpoint: "/Groups", Description: "Group", Schema: scim.SchemaGroup},
+}
+
+func (s *server) serviceProviderConfig(c *gin.Context) {
+	write(c, http.StatusOK, &serviceProviderConfig{
+		Schemas:        []string{scim.SchemaServiceProviderConfig},
+		Patch:          supported{Supported: true},
+		Bulk:           bulkSupported{},
+		Filter:         filterSupported{Supported: true, MaxResults: s.cfg.MaxResults},
+		ChangePassword: supported{Supported: true},
+		Sort:           supported{Supported: true},
+		ETag:           supported{Supported: true},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication scheme using the OAuth Bearer Token Standard",
+			Primary:     true,
+		}},
+		Meta: &scim.Meta{ResourceType: "ServiceProviderConfig", Location: s.baseURL(c) + "/ServiceProviderConfig"},
+	})
+}
+
+func (s *server) listSchemas(c *gin.Context) {
+	resources := make([]any, 0, len(schemas))
+	for i := range schemas {
+		resources = append(resources, s.schema(c, schemas[i]))
+	}
+	write(c, http.StatusOK, scim.NewListResponse(int64(len(resources)), 1, resources))
+}
+
+func (s *server) getSchema(c *gin.Context) {
+	for i := range schemas {
+		if strings.EqualFold(schemas[i].ID, c.Param("id")) {
+			write(c, http.StatusOK, s.schema(c, schemas[i]))
+			return
+		}
+	}
+	writeError(c, scim.NewError(http.StatusNotFound, "", "schema "+c.Param("id")+" not found"))
+}
+
+func (s *server) listResourceTypes(c *gin.Context) {
+	resources := make([]any, 0, len(resourceTypes))
+	for i := range resourceTypes {
+		resources = append(resources, s.resourceType(c, resourceTypes[i]))
+	}
+	write(c, http.StatusOK, scim.NewListResponse(int64(len(resources)), 1, resources))
+}
+
+func (s *server) getResourceType(c *gin.Context) {
+	for i := range resourceTypes {
+		if strings.EqualFold(resourceTypes[i].ID, c.Param("id")) {
+			write(c, http.StatusOK, s.resourceType(c, resourceTypes[i]))
+			return
+		}
+	}
+	writeError(c, scim.NewError(http.StatusNotFound, "", "resource type "+c.Param("id")+" not found"))
+}
+
+func (s *server) schema(c *gin.Context, sc schema) *schema {
+	sc.Schemas = []string{scim.SchemaSchema}
+	sc.Meta = &scim.Meta{ResourceType: "Schema", Location: s.baseURL(c) + "/Schemas/" + sc.ID}
+	return &sc
+}
+
+func (s *server) resourceType(c *gin.Context, rt resourceType) *resourceType {
+	rt.Schemas = []string{scim.SchemaResourceType}
+	rt.SchemaExtensions = make([]any, 0)
+	rt.Meta = &scim.Meta{ResourceType: "ResourceType", Location: s.baseURL(c) + "/ResourceTypes/" + rt.ID}
+	return &rt
+}
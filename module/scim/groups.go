@@ -0,0 +1,370 @@
+package scim
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/forbearing/gst/database"
+	modeliamgroup "github.com/forbearing/gst/internal/model/iam/group"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/forbearing/gst/types"
+	"github.com/gin-gonic/gin"
+)
+
+// groupColumns returns the filterable group attributes, members are resolved
+// through the group of the member user.
+func groupColumns(c *gin.Context) scim.Columns {
+	members := scim.Column{Cond: func(op scim.Operator, value any) (string, []any, error) {
+		id, ok := value.(string)
+		if !ok || (op != scim.OpEqual && op != scim.OpNotEqual) {
+			return "", nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "members only supports eq and ne with a user id")
+		}
+		u := new(modeliamuser.User)
+		if err := database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).Get(u, id); err != nil {
+			return "", nil, err
+		}
+		switch {
+		case op == scim.OpEqual && len(u.GroupID) > 0:
+			return "id = ?", []any{u.GroupID}, nil
+		case op == scim.OpEqual:
+			return "1 = 0", nil, nil
+		case len(u.GroupID) > 0:
+			return "id <> ?", []any{u.GroupID}, nil
+		}
+		return "1 = 1", nil, nil
+	}}
+	return scim.Columns{
+		"id":                {Name: "id", CaseExact: true},
+		"externalId":        {Name: "external_id", CaseExact: true},
+		"displayName":       {Name: "name"},
+		"members":           members,
+		"members.value":     members,
+		"meta.created":      {Name: "created_at", Type: scim.TypeDateTime},
+		"meta.lastModified": {Name: "updated_at", Type: scim.TypeDateTime},
+	}
+}
+
+func (s *server) listGroups(c *gin.Context) {
+	q, err := s.parseListQuery(c, groupColumns(c), defaultOrder)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	groups, total, err := list[*modeliamgroup.Group](c, q)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	members, err := membersOf(c, groups...)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resources := make([]any, 0, len(groups))
+	for _, g := range groups {
+		obj, err := toMap(s.toGroup(c, g, members[g.ID]))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		resources = append(resources, scim.Project(obj, c.Query("attributes"), c.Query("excludedAttributes")))
+	}
+	write(c, http.StatusOK, scim.NewListResponse(total, q.startIndex, resources))
+}
+
+func (s *server) getGroup(c *gin.Context) {
+	g, err := getGroup(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	s.writeGroup(c, http.StatusOK, g)
+}
+
+func (s *server) createGroup(c *gin.Context) {
+	req := new(scim.Group)
+	if err := readJSON(c, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	g := &modeliamgroup.Group{
+		Type:   modeliamgroup.GroupTypeRegular,
+		Status: modeliamgroup.GroupStatusActive,
+	}
+	if err := applyGroup(c, g, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).Create(g); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := setMembers(c, g.ID, nil, memberIDs(req.Members)); err != nil {
+		writeError(c, err)
+		return
+	}
+	g, err := getGroup(c, g.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Location", s.baseURL(c)+"/Groups/"+g.ID)
+	s.writeGroup(c, http.StatusCreated, g)
+}
+
+func (s *server) replaceGroup(c *gin.Context) {
+	g, err := getGroup(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(g.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	req := new(scim.Group)
+	if err = readJSON(c, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	s.updateGroup(c, g, req)
+}
+
+func (s *server) patchGroup(c *gin.Context) {
+	g, err := getGroup(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(g.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	req := new(scim.PatchRequest)
+	if err = readJSON(c, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	members, err := membersOf(c, g)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	obj, err := toMap(s.toGroup(c, g, members[g.ID]))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = scim.ApplyPatch(obj, req.Operations); err != nil {
+		writeError(c, err)
+		return
+	}
+	patched := new(scim.Group)
+	if err = fromMap(obj, patched); err != nil {
+		writeError(c, err)
+		return
+	}
+	s.updateGroup(c, g, patched)
+}
+
+func (s *server) deleteGroup(c *gin.Context) {
+	g, err := getGroup(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(g.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	members, err := membersOf(c, g)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = setMembers(c, g.ID, members[g.ID], nil); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).Delete(g); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// updateGroup applies the replacement of the group and saves it, the group is
+// saved even if only the members changed so that its version changes.
+func (s *server) updateGroup(c *gin.Context, g *modeliamgroup.Group, req *scim.Group) {
+	members, err := membersOf(c, g)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = applyGroup(c, g, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = setMembers(c, g.ID, members[g.ID], memberIDs(req.Members)); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).Update(g); err != nil {
+		writeError(c, err)
+		return
+	}
+	if g, err = getGroup(c, g.ID); err != nil {
+		writeError(c, err)
+		return
+	}
+	s.writeGroup(c, http.StatusOK, g)
+}
+
+func (s *server) writeGroup(c *gin.Context, code int, g *modeliamgroup.Group) {
+	etag := version(g.UpdatedAt)
+	c.Header("ETag", etag)
+	if code == http.StatusOK && c.Request.Method == http.MethodGet && notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	members, err := membersOf(c, g)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeResource(c, code, s.toGroup(c, g, members[g.ID]))
+}
+
+func (s *server) toGroup(c *gin.Context, g *modeliamgroup.Group, members []*modeliamuser.User) *scim.Group {
+	sg := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          g.ID,
+		ExternalID:  deref(g.ExternalID),
+		DisplayName: g.Name,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      g.CreatedAt,
+			LastModified: g.UpdatedAt,
+			Location:     s.baseURL(c) + "/Groups/" + g.ID,
+			Version:      version(g.UpdatedAt),
+		},
+	}
+	for _, u := range members {
+		sg.Members = append(sg.Members, scim.MultiValue{
+			Value:   u.ID,
+			Display: u.Username,
+			Type:    "User",
+			Ref:     s.baseURL(c) + "/Users/" + u.ID,
+		})
+	}
+	return sg
+}
+
+// applyGroup sets the attributes of the SCIM group to the IAM group, the members are set by setMembers.
+func applyGroup(c *gin.Context, g *modeliamgroup.Group, req *scim.Group) error {
+	name := strings.TrimSpace(req.DisplayName)
+	if len(name) == 0 {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+	cond, args := "LOWER(name) = ?", []any{strings.ToLower(name)}
+	if len(g.ID) > 0 {
+		cond, args = cond+" AND id <> ?", append(args, g.ID)
+	}
+	var count int64
+	if err := database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).
+		WithQuery(nil, types.QueryConfig{RawQuery: cond, RawQueryArgs: args}).
+		Count(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "displayName is already in use")
+	}
+	for _, m := range req.Members {
+		if len(m.Type) > 0 && !strings.EqualFold(m.Type, "User") {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "nested groups are not supported")
+		}
+	}
+
+	g.Name = name
+	g.ExternalID = ptr(req.ExternalID)
+	return nil
+}
+
+// setMembers moves the users added to the group into it and the removed users out of it.
+func setMembers(c *gin.Context, groupID string, current []*modeliamuser.User, ids []string) error {
+	db := func() types.Database[*modeliamuser.User] {
+		return database.Database[*modeliamuser.User](types.NewDatabaseContext(c))
+	}
+	for _, u := range current {
+		if !slices.Contains(ids, u.ID) {
+			u.GroupID = ""
+			if err := db().Update(u); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range ids {
+		if slices.ContainsFunc(current, func(u *modeliamuser.User) bool { return u.ID == id }) {
+			continue
+		}
+		u := new(modeliamuser.User)
+		if err := db().Get(u, id); err != nil {
+			return err
+		}
+		if len(u.ID) == 0 {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "member "+id+" not found")
+		}
+		u.GroupID = groupID
+		if err := db().Update(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// membersOf loads the members of the groups, keyed by the group id.
+func membersOf(c *gin.Context, groups ...*modeliamgroup.Group) (map[string][]*modeliamuser.User, error) {
+	res := make(map[string][]*modeliamuser.User)
+	if len(groups) == 0 {
+		return res, nil
+	}
+	ids := make([]string, 0, len(groups))
+	for _, g := range groups {
+		ids = append(ids, g.ID)
+	}
+	users := make([]*modeliamuser.User, 0)
+	if err := database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).
+		WithQuery(nil, types.QueryConfig{RawQuery: "group_id IN ?", RawQueryArgs: []any{ids}}).
+		WithOrder(defaultOrder).
+		List(&users); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		res[u.GroupID] = append(res[u.GroupID], u)
+	}
+	return res, nil
+}
+
+// memberIDs returns the distinct user ids of the members.
+func memberIDs(members []scim.MultiValue) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if id := strings.TrimSpace(m.Value); len(id) > 0 && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func getGroup(c *gin.Context, id string) (*modeliamgroup.Group, error) {
+	g := new(modeliamgroup.Group)
+	if err := database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).Get(g, id); err != nil {
+		return nil, err
+	}
+	if len(g.ID) == 0 {
+		return nil, scim.NewError(http.StatusNotFound, "", "group "+id+" not found")
+	}
+	return g, nil
+}
@@ -0,0 +1,119 @@
+// Package scim provides SCIM 2.0 (RFC 7643, RFC 7644) provisioning endpoints
+// backed by the IAM User and Group models, so that identity providers like
+// Okta, Microsoft Entra ID and OneLogin can create, update and deactivate accounts.
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	modeliamgroup "github.com/forbearing/gst/internal/model/iam/group"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	"github.com/forbearing/gst/model"
+	"github.com/forbearing/gst/module"
+	"github.com/forbearing/gst/pkg/scim"
+)
+
+// BasePath is the path prefix of the SCIM endpoints.
+const BasePath = "/scim/v2"
+
+const defaultMaxResults = 200
+
+// Config is the configuration of the scim module.
+type Config struct {
+	// Tokens are the accepted bearer tokens of the identity providers, at least one is required.
+	Tokens []string
+	// BaseURL is the external url of the SCIM endpoints used in meta.location,
+	// eg: "https://example.com/scim/v2", default is derived from the request.
+	BaseURL string
+	// MaxResults is the max number of resources returned by a query, default is 200.
+	MaxResults int
+}
+
+type (
+	User           = scim.User
+	Group          = scim.Group
+	Meta           = scim.Meta
+	Name           = scim.Name
+	MultiValue     = scim.MultiValue
+	ListResponse   = scim.ListResponse
+	PatchRequest   = scim.PatchRequest
+	PatchOperation = scim.PatchOperation
+	Error          = scim.Error
+)
+
+// Register registers the SCIM endpoints, the users and groups are the IAM users and groups.
+// The IAM module is not required, but register it to let the provisioned users log in.
+//
+// API Routes, authenticated by "Authorization: Bearer <token>":
+//   - GET    /scim/v2/ServiceProviderConfig
+//   - GET    /scim/v2/Schemas
+//   - GET    /scim/v2/Schemas/:id
+//   - GET    /scim/v2/ResourceTypes
+//   - GET    /scim/v2/ResourceTypes/:id
+//   - GET    /scim/v2/Users
+//   - POST   /scim/v2/Users
+//   - GET    /scim/v2/Users/:id
+//   - PUT    /scim/v2/Users/:id
+//   - PATCH  /scim/v2/Users/:id
+//   - DELETE /scim/v2/Users/:id
+//   - GET    /scim/v2/Groups
+//   - POST   /scim/v2/Groups
+//   - GET    /scim/v2/Groups/:id
+//   - PUT    /scim/v2/Groups/:id
+//   - PATCH  /scim/v2/Groups/:id
+//   - DELETE /scim/v2/Groups/:id
+//
+// Supported features:
+//   - filter with all operators, "and", "or", "not" and value paths, eg: emails[value co "@example.com"]
+//   - PATCH "add", "replace" and "remove", with value filters in paths
+//   - pagination with startIndex and count, sorting with sortBy and sortOrder
+//   - attributes and excludedAttributes
+//   - ETags: meta.version, If-Match and If-None-Match
+//
+// Users belong to at most one group because IAM users have a single group,
+// adding a user to a group moves the user out of its previous group.
+// Deactivating or deleting a user revokes its sessions.
+func Register(cfg Config) {
+	tokens := make([]string, 0, len(cfg.Tokens))
+	for _, t := range cfg.Tokens {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(tokens) == 0 {
+		panic(errors.New("scim: at least one bearer token is required"))
+	}
+	cfg.Tokens = tokens
+	if cfg.MaxResults <= 0 {
+		cfg.MaxResults = defaultMaxResults
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	model.Register[*modeliamuser.User]()
+	model.Register[*modeliamgroup.Group]()
+
+	s := &server{cfg: cfg}
+	auth := s.authenticate()
+
+	module.UseHandler(http.MethodGet, BasePath+"/ServiceProviderConfig", auth, s.serviceProviderConfig)
+	module.UseHandler(http.MethodGet, BasePath+"/Schemas", auth, s.listSchemas)
+	module.UseHandler(http.MethodGet, BasePath+"/Schemas/:id", auth, s.getSchema)
+	module.UseHandler(http.MethodGet, BasePath+"/ResourceTypes", auth, s.listResourceTypes)
+	module.UseHandler(http.MethodGet, BasePath+"/ResourceTypes/:id", auth, s.getResourceType)
+
+	module.UseHandler(http.MethodGet, BasePath+"/Users", auth, s.listUsers)
+	module.UseHandler(http.MethodPost, BasePath+"/Users", auth, s.createUser)
+	module.UseHandler(http.MethodGet, BasePath+"/Users/:id", auth, s.getUser)
+	module.UseHandler(http.MethodPut, BasePath+"/Users/:id", auth, s.replaceUser)
+	module.UseHandler(http.MethodPatch, BasePath+"/Users/:id", auth, s.patchUser)
+	module.UseHandler(http.MethodDelete, BasePath+"/Users/:id", auth, s.deleteUser)
+
+	module.UseHandler(http.MethodGet, BasePath+"/Groups", auth, s.listGroups)
+	module.UseHandler(http.MethodPost, BasePath+"/Groups", auth, s.createGroup)
+	module.UseHandler(http.MethodGet, BasePath+"/Groups/:id", auth, s.getGroup)
+	module.UseHandler(http.MethodPut, BasePath+"/Groups/:id", auth, s.replaceGroup)
+	module.UseHandler(http.MethodPatch, BasePath+"/Groups/:id", auth, s.patchGroup)
+	module.UseHandler(http.MethodDelete, BasePath+"/Groups/:id", auth, s.deleteGroup)
+}
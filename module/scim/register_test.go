@@ -0,0 +1,231 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/bootstrap"
+	"github.com/forbearing/gst/config"
+	"github.com/forbearing/gst/module/scim"
+	pkgscim "github.com/forbearing/gst/pkg/scim"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	token = "scim-test-token"
+	port  = 8000
+
+	baseURL = fmt.Sprintf("http://localhost:%d%s", port, scim.BasePath)
+)
+
+func init() {
+	os.Setenv(config.DATABASE_TYPE, string(config.DBSqlite))
+	os.Setenv(config.SQLITE_IS_MEMORY, "true")
+	os.Setenv(config.SERVER_PORT, fmt.Sprintf("%d", port))
+	os.Setenv(config.LOGGER_DIR, "./logs")
+
+	if err := bootstrap.Bootstrap(); err != nil {
+		panic(err)
+	}
+
+	go func() {
+		scim.Register(scim.Config{Tokens: []string{"other-token", token}})
+
+		if err := bootstrap.Run(); err != nil {
+			panic(err)
+		}
+	}()
+
+	for {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			l.Close()
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		if errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+		panic(err)
+	}
+}
+
+// step is a recorded SCIM request and the expected response.
+//
+// The path, headers and body may reference the variables captured by previous
+// steps of the same file as "${name}". The expected response is matched as a
+// subset of the actual response, arrays must have the same length, "*" matches
+// any value and null matches a missing attribute.
+type step struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	RawBody string            `json:"raw_body"`
+
+	Status          int               `json:"status"`
+	Response        json.RawMessage   `json:"response"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	Capture         map[string]string `json:"capture"`
+}
+
+// TestConformance replays the recorded requests of identity providers in testdata.
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+			var steps []step
+			require.NoError(t, json.Unmarshal(data, &steps))
+
+			vars := make(map[string]string)
+			for _, s := range steps {
+				if !t.Run(s.Name, func(t *testing.T) { replay(t, s, vars) }) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func replay(t *testing.T, s step, vars map[string]string) {
+	expand := func(str string) string {
+		for k, v := range vars {
+			str = strings.ReplaceAll(str, "${"+k+"}", v)
+		}
+		return str
+	}
+	// expandJSON escapes the variables substituted into JSON strings, eg: the quotes of ETags.
+	expandJSON := func(str string) string {
+		for k, v := range vars {
+			quoted, _ := json.Marshal(v)
+			str = strings.ReplaceAll(str, "${"+k+"}", string(quoted[1:len(quoted)-1]))
+		}
+		return str
+	}
+
+	path, rawQuery, _ := strings.Cut(expand(s.Path), "?")
+	query, err := url.ParseQuery(rawQuery)
+	require.NoError(t, err)
+	target := baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	body := s.RawBody
+	if len(s.Body) > 0 {
+		body = string(s.Body)
+	}
+
+	req, err := http.NewRequest(s.Method, target, strings.NewReader(expandJSON(body)))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", pkgscim.ContentType)
+	for k, v := range s.Headers {
+		if v = expand(v); len(v) == 0 {
+			req.Header.Del(k)
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, s.Status, resp.StatusCode, string(data))
+
+	for k, v := range s.ResponseHeaders {
+		if v == "*" {
+			require.NotEmpty(t, resp.Header.Get(k), k)
+		} else {
+			require.Equal(t, expand(v), resp.Header.Get(k), k)
+		}
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		require.Empty(t, data)
+		return
+	}
+	require.Equal(t, pkgscim.ContentType, resp.Header.Get("Content-Type"))
+
+	var actual any
+	require.NoError(t, json.Unmarshal(data, &actual), string(data))
+	if len(s.Response) > 0 {
+		var expected any
+		require.NoError(t, json.Unmarshal([]byte(expandJSON(string(s.Response))), &expected))
+		matchSubset(t, "$", expected, actual)
+	}
+	for name, path := range s.Capture {
+		v := capture(actual, path)
+		require.NotNil(t, v, "capture %s from %s", name, path)
+		vars[name] = fmt.Sprint(v)
+	}
+}
+
+func matchSubset(t *testing.T, path string, expected, actual any) {
+	switch e := expected.(type) {
+	case nil:
+		require.Nil(t, actual, path)
+	case string:
+		if e == "*" {
+			require.NotNil(t, actual, path)
+			return
+		}
+		require.Equal(t, e, actual, path)
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		require.True(t, ok, "%s: expected object, got %v", path, actual)
+		for k, v := range e {
+			matchSubset(t, path+"."+k, v, a[k])
+		}
+	case []any:
+		a, ok := actual.([]any)
+		require.True(t, ok, "%s: expected array, got %v", path, actual)
+		require.Len(t, a, len(e), path)
+		for i := range e {
+			matchSubset(t, fmt.Sprintf("%s[%d]", path, i), e[i], a[i])
+		}
+	default:
+		require.Equal(t, e, actual, path)
+	}
+}
+
+// capture returns the value at the dotted path, eg: "Resources.0.id".
+func capture(v any, path string) any {
+	for _, key := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]any:
+			v = x[key]
+		case []any:
+			var i int
+			if _, err := fmt.Sscan(key, &i); err != nil || i >= len(x) {
+				return nil
+			}
+			v = x[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func TestRegisterInvalidConfig(t *testing.T) {
+	require.Panics(t, func() { scim.Register(scim.Config{}) })
+	require.Panics(t, func() { scim.Register(scim.Config{Tokens: []string{" "}}) })
+}
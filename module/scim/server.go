@@ -0,0 +1,248 @@
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/logger"
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxBodySize is the max size of request bodies.
+const maxBodySize = 1 << 20
+
+type server struct {
+	cfg Config
+}
+
+// authenticate checks the bearer token in constant time against every configured token.
+func (s *server) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		valid := 0
+		if strings.EqualFold(scheme, "Bearer") && len(token) > 0 {
+			for _, t := range s.cfg.Tokens {
+				valid |= subtle.ConstantTimeCompare([]byte(token), []byte(t))
+			}
+		}
+		if valid != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			writeError(c, scim.NewError(http.StatusUnauthorized, "", "invalid bearer token"))
+			c.Abort()
+			return
+		}
+		// The changes made by the identity provider are audited as user "scim".
+		c.Set(consts.CTX_USERNAME, "scim")
+		c.Set(consts.CTX_REAL_USERNAME, "scim")
+		c.Next()
+	}
+}
+
+// baseURL returns the url prefix of resource locations.
+func (s *server) baseURL(c *gin.Context) string {
+	if len(s.cfg.BaseURL) > 0 {
+		return s.cfg.BaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + BasePath
+}
+
+func write(c *gin.Context, code int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Data(code, scim.ContentType, data)
+}
+
+// writeResource writes the resource with the attributes projection of the request.
+func writeResource(c *gin.Context, code int, v any) {
+	obj, err := toMap(v)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	write(c, code, scim.Project(obj, c.Query("attributes"), c.Query("excludedAttributes")))
+}
+
+// writeError writes err as a SCIM error, errors other than *scim.Error and
+// *types.ServiceError are logged and hidden behind a generic message.
+func writeError(c *gin.Context, err error) {
+	var serr *scim.Error
+	var svcErr *types.ServiceError
+	switch {
+	case errors.As(err, &serr):
+	case errors.As(err, &svcErr):
+		serr = scim.NewError(svcErr.StatusCode, "", svcErr.Message)
+	default:
+		logger.Controller.WithControllerContext(types.NewControllerContext(c), consts.Phase("SCIM")).Errorz("scim request failed",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Error(err),
+		)
+		serr = scim.NewError(http.StatusInternalServerError, "", "internal server error")
+	}
+	write(c, serr.Code(), serr)
+}
+
+// readJSON decodes the request body, "application/json" is accepted besides "application/scim+json".
+func readJSON(c *gin.Context, v any) error {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1))
+	if err != nil {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "failed to read request body")
+	}
+	if len(data) > maxBodySize {
+		return scim.NewError(http.StatusRequestEntityTooLarge, scim.ErrTooMany, "request body is too large")
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "invalid JSON: "+err.Error())
+	}
+	return nil
+}
+
+func toMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any)
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// fromMap converts the JSON decoded resource back to v, it reports invalid values as "invalidValue".
+func fromMap(m map[string]any, v any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, err.Error())
+	}
+	return nil
+}
+
+// version returns the weak ETag of a resource, it changes whenever the resource is updated.
+func version(updatedAt *time.Time) string {
+	if updatedAt == nil {
+		return `W/"0"`
+	}
+	return `W/"` + strconv.FormatInt(updatedAt.UnixNano(), 36) + `"`
+}
+
+// checkPrecondition checks the If-Match header of PUT, PATCH and DELETE.
+func checkPrecondition(c *gin.Context, current string) error {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if len(ifMatch) == 0 || ifMatch == "*" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if weakEqual(strings.TrimSpace(tag), current) {
+			return nil
+		}
+	}
+	return scim.NewError(http.StatusPreconditionFailed, "", "resource version mismatch, current version is "+current)
+}
+
+// notModified reports whether the If-None-Match header of GET matches the current version.
+func notModified(c *gin.Context, current string) bool {
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || weakEqual(tag, current) {
+			return true
+		}
+	}
+	return false
+}
+
+func weakEqual(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// listQuery is the parsed query of list requests.
+type listQuery struct {
+	cond       string
+	args       []any
+	order      string
+	startIndex int
+	count      int
+}
+
+// parseListQuery parses filter, startIndex, count, sortBy and sortOrder.
+func (s *server) parseListQuery(c *gin.Context, cols scim.Columns, defaultOrder string) (*listQuery, error) {
+	q := &listQuery{startIndex: 1, count: s.cfg.MaxResults, order: defaultOrder}
+	if filter := strings.TrimSpace(c.Query("filter")); len(filter) > 0 {
+		expr, err := scim.ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if q.cond, q.args, err = scim.ToSQL(expr, cols); err != nil {
+			return nil, err
+		}
+	}
+	// Invalid or out of range startIndex and count are adjusted as required by RFC 7644.
+	if v, err := strconv.Atoi(c.Query("startIndex")); err == nil && v > 1 {
+		q.startIndex = v
+	}
+	if v, err := strconv.Atoi(c.Query("count")); err == nil {
+		q.count = min(max(v, 0), s.cfg.MaxResults)
+	}
+	if sortBy := strings.TrimSpace(c.Query("sortBy")); len(sortBy) > 0 {
+		col, ok := cols.Lookup(sortBy)
+		if !ok || col.Cond != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, fmt.Sprintf("attribute %q is not sortable", sortBy))
+		}
+		dir := "asc"
+		if strings.EqualFold(c.Query("sortOrder"), "descending") {
+			dir = "desc"
+		}
+		q.order = col.Name + " " + dir + ", id asc"
+	}
+	return q, nil
+}
+
+// list queries a page of resources and the total count.
+func list[M types.Model](c *gin.Context, q *listQuery) ([]M, int64, error) {
+	newDB := func() types.Database[M] {
+		db := database.Database[M](types.NewDatabaseContext(c))
+		if len(q.cond) > 0 {
+			db = db.WithQuery(*new(M), types.QueryConfig{RawQuery: q.cond, RawQueryArgs: q.args})
+		}
+		return db
+	}
+
+	var total int64
+	if err := newDB().Count(&total); err != nil {
+		return nil, 0, err
+	}
+	resources := make([]M, 0)
+	if q.count == 0 || int64(q.startIndex) > total {
+		return resources, total, nil
+	}
+
+	if err := newDB().WithOrder(q.order).WithOffset(q.startIndex - 1).WithLimit(q.count).List(&resources); err != nil {
+		return nil, 0, err
+	}
+	return resources, total, nil
+}
@@ -0,0 +1,425 @@
+package scim
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/forbearing/gst/database"
+	modeliamgroup "github.com/forbearing/gst/internal/model/iam/group"
+	modeliamuser "github.com/forbearing/gst/internal/model/iam/user"
+	serviceiampassword "github.com/forbearing/gst/internal/service/iam/password"
+	serviceiamsession "github.com/forbearing/gst/internal/service/iam/session"
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/forbearing/gst/types"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultOrder = "created_at asc, id asc"
+
+var userColumns = scim.Columns{
+	"id":                 {Name: "id", CaseExact: true},
+	"externalId":         {Name: "external_id", CaseExact: true},
+	"userName":           {Name: "username"},
+	"name.givenName":     {Name: "first_name"},
+	"name.familyName":    {Name: "last_name"},
+	"displayName":        {Name: "display_name"},
+	"emails":             {Name: "email"},
+	"emails.value":       {Name: "email"},
+	"phoneNumbers":       {Name: "phone"},
+	"phoneNumbers.value": {Name: "phone"},
+	"groups":             {Name: "group_id", CaseExact: true},
+	"groups.value":       {Name: "group_id", CaseExact: true},
+	"meta.created":       {Name: "created_at", Type: scim.TypeDateTime},
+	"meta.lastModified":  {Name: "updated_at", Type: scim.TypeDateTime},
+	"active": {Cond: func(op scim.Operator, value any) (string, []any, error) {
+		active, ok := value.(bool)
+		switch {
+		case op == scim.OpPresent:
+			return "1 = 1", nil, nil
+		case !ok || (op != scim.OpEqual && op != scim.OpNotEqual):
+			return "", nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "active only supports eq and ne with a boolean")
+		}
+		if op == scim.OpNotEqual {
+			active = !active
+		}
+		if active {
+			return "(status IS NULL OR status <> ?)", []any{modeliamuser.UserStatusInactive}, nil
+		}
+		return "status = ?", []any{modeliamuser.UserStatusInactive}, nil
+	}},
+}
+
+func (s *server) listUsers(c *gin.Context) {
+	q, err := s.parseListQuery(c, userColumns, defaultOrder)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	users, total, err := list[*modeliamuser.User](c, q)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	groups, err := groupsOf(c, users...)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resources := make([]any, 0, len(users))
+	for _, u := range users {
+		obj, err := toMap(s.toUser(c, u, groups))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		resources = append(resources, scim.Project(obj, c.Query("attributes"), c.Query("excludedAttributes")))
+	}
+	write(c, http.StatusOK, scim.NewListResponse(total, q.startIndex, resources))
+}
+
+func (s *server) getUser(c *gin.Context) {
+	u, err := getUser(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	s.writeUser(c, http.StatusOK, u)
+}
+
+func (s *server) createUser(c *gin.Context) {
+	req, err := readUser(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	u := &modeliamuser.User{
+		Status: modeliamuser.UserStatusActive,
+		Type:   modeliamuser.UserTypeRegular,
+	}
+	if err = applyUser(c, u, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).Create(u); err != nil {
+		writeError(c, err)
+		return
+	}
+	if u, err = getUser(c, u.ID); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Location", s.baseURL(c)+"/Users/"+u.ID)
+	s.writeUser(c, http.StatusCreated, u)
+}
+
+func (s *server) replaceUser(c *gin.Context) {
+	u, err := getUser(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(u.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	req, err := readUser(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	s.updateUser(c, u, req)
+}
+
+func (s *server) patchUser(c *gin.Context) {
+	u, err := getUser(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(u.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	req := new(scim.PatchRequest)
+	if err = readJSON(c, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	groups, err := groupsOf(c, u)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	obj, err := toMap(s.toUser(c, u, groups))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = scim.ApplyPatch(obj, req.Operations); err != nil {
+		writeError(c, err)
+		return
+	}
+	patched := new(scim.User)
+	coerceBool(obj, "active")
+	if err = fromMap(obj, patched); err != nil {
+		writeError(c, err)
+		return
+	}
+	s.updateUser(c, u, patched)
+}
+
+func (s *server) deleteUser(c *gin.Context) {
+	u, err := getUser(c, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = checkPrecondition(c, version(u.UpdatedAt)); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err = database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).Delete(u); err != nil {
+		writeError(c, err)
+		return
+	}
+	serviceiamsession.InvalidateUserSessions(u.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// updateUser applies the replacement of the user and saves it.
+func (s *server) updateUser(c *gin.Context, u *modeliamuser.User, req *scim.User) {
+	wasActive := u.Status != modeliamuser.UserStatusInactive
+	previousHash := u.PasswordHash
+	if err := applyUser(c, u, req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).Update(u); err != nil {
+		writeError(c, err)
+		return
+	}
+	if len(req.Password) > 0 {
+		if err := serviceiampassword.Record(types.NewServiceContext(c), u.ID, previousHash); err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+	// Deactivated users and users whose password is reset by the identity provider are signed out.
+	if (wasActive && u.Status == modeliamuser.UserStatusInactive) || len(req.Password) > 0 {
+		serviceiamsession.InvalidateUserSessions(u.ID)
+	}
+	u, err := getUser(c, u.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	s.writeUser(c, http.StatusOK, u)
+}
+
+func (s *server) writeUser(c *gin.Context, code int, u *modeliamuser.User) {
+	etag := version(u.UpdatedAt)
+	c.Header("ETag", etag)
+	if code == http.StatusOK && c.Request.Method == http.MethodGet && notModified(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	groups, err := groupsOf(c, u)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeResource(c, code, s.toUser(c, u, groups))
+}
+
+// toUser converts the IAM user to the SCIM user, groups maps group ids to the groups.
+func (s *server) toUser(c *gin.Context, u *modeliamuser.User, groups map[string]*modeliamgroup.Group) *scim.User {
+	active := u.Status != modeliamuser.UserStatusInactive
+	su := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          u.ID,
+		ExternalID:  deref(u.ExternalID),
+		UserName:    u.Username,
+		DisplayName: deref(u.DisplayName),
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     s.baseURL(c) + "/Users/" + u.ID,
+			Version:      version(u.UpdatedAt),
+		},
+	}
+	if u.FirstName != nil || u.LastName != nil {
+		su.Name = &scim.Name{GivenName: deref(u.FirstName), FamilyName: deref(u.LastName)}
+		su.Name.Formatted = strings.TrimSpace(su.Name.GivenName + " " + su.Name.FamilyName)
+	}
+	if email := deref(u.Email); len(email) > 0 {
+		su.Emails = []scim.MultiValue{{Value: email, Type: "work", Primary: true}}
+	}
+	if phone := deref(u.Phone); len(phone) > 0 {
+		su.PhoneNumbers = []scim.MultiValue{{Value: phone, Type: "work", Primary: true}}
+	}
+	if g, ok := groups[u.GroupID]; ok {
+		su.Groups = []scim.MultiValue{{Value: g.ID, Display: g.Name, Ref: s.baseURL(c) + "/Groups/" + g.ID}}
+	}
+	return su
+}
+
+// applyUser sets the attributes of the SCIM user to the IAM user, omitted attributes are cleared.
+// The groups are read-only, they are changed through the members of groups.
+func applyUser(c *gin.Context, u *modeliamuser.User, req *scim.User) error {
+	username := strings.TrimSpace(req.UserName)
+	if len(username) == 0 {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName is required")
+	}
+	email := primaryValue(req.Emails)
+	if err := checkUserUniqueness(c, u.ID, username, email); err != nil {
+		return err
+	}
+
+	u.Username = username
+	u.ExternalID = ptr(req.ExternalID)
+	u.DisplayName = ptr(req.DisplayName)
+	u.FirstName, u.LastName = nil, nil
+	if req.Name != nil {
+		u.FirstName = ptr(req.Name.GivenName)
+		u.LastName = ptr(req.Name.FamilyName)
+	}
+	if !strings.EqualFold(deref(u.Email), email) {
+		verified := false
+		u.EmailVerified = &verified
+	}
+	u.Email = ptr(email)
+	u.Phone = ptr(primaryValue(req.PhoneNumbers))
+	if req.Active != nil {
+		switch {
+		case !*req.Active:
+			u.Status = modeliamuser.UserStatusInactive
+		case u.Status == modeliamuser.UserStatusInactive:
+			u.Status = modeliamuser.UserStatusActive
+		}
+	}
+	if len(req.Password) > 0 {
+		if err := serviceiampassword.Validate(types.NewServiceContext(c), u, req.Password); err != nil {
+			return err
+		}
+		if _, err := serviceiampassword.Set(u, req.Password); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUserUniqueness checks that no other user has the userName or the email, both case insensitive.
+func checkUserUniqueness(c *gin.Context, id, username, email string) error {
+	cond, args := "LOWER(username) = ?", []any{strings.ToLower(username)}
+	if len(email) > 0 {
+		cond, args = "(LOWER(username) = ? OR LOWER(email) = ?)", append(args, strings.ToLower(email))
+	}
+	if len(id) > 0 {
+		cond, args = cond+" AND id <> ?", append(args, id)
+	}
+	var count int64
+	if err := database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).
+		WithQuery(nil, types.QueryConfig{RawQuery: cond, RawQueryArgs: args}).
+		Count(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "userName or email is already in use")
+	}
+	return nil
+}
+
+// readUser reads the SCIM user of POST and PUT.
+func readUser(c *gin.Context) (*scim.User, error) {
+	obj := make(map[string]any)
+	if err := readJSON(c, &obj); err != nil {
+		return nil, err
+	}
+	coerceBool(obj, "active")
+	req := new(scim.User)
+	if err := fromMap(obj, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func getUser(c *gin.Context, id string) (*modeliamuser.User, error) {
+	u := new(modeliamuser.User)
+	if err := database.Database[*modeliamuser.User](types.NewDatabaseContext(c)).Get(u, id); err != nil {
+		return nil, err
+	}
+	if len(u.ID) == 0 {
+		return nil, scim.NewError(http.StatusNotFound, "", "user "+id+" not found")
+	}
+	return u, nil
+}
+
+// groupsOf loads the groups of the users.
+func groupsOf(c *gin.Context, users ...*modeliamuser.User) (map[string]*modeliamgroup.Group, error) {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		if len(u.GroupID) > 0 {
+			ids = append(ids, u.GroupID)
+		}
+	}
+	res := make(map[string]*modeliamgroup.Group)
+	if len(ids) == 0 {
+		return res, nil
+	}
+	groups := make([]*modeliamgroup.Group, 0)
+	if err := database.Database[*modeliamgroup.Group](types.NewDatabaseContext(c)).
+		WithQuery(nil, types.QueryConfig{RawQuery: "id IN ?", RawQueryArgs: []any{ids}}).
+		List(&groups); err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		res[g.ID] = g
+	}
+	return res, nil
+}
+
+// coerceBool converts the string boolean sent by some identity providers, eg: "active": "False".
+func coerceBool(obj map[string]any, name string) {
+	for k, v := range obj {
+		if !strings.EqualFold(k, name) {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "true":
+				obj[k] = true
+			case "false":
+				obj[k] = false
+			}
+		}
+	}
+}
+
+// primaryValue returns the value of the primary item, or the first item if none is primary.
+func primaryValue(values []scim.MultiValue) string {
+	for _, v := range values {
+		if v.Primary {
+			return strings.TrimSpace(v.Value)
+		}
+	}
+	if len(values) > 0 {
+		return strings.TrimSpace(values[0].Value)
+	}
+	return ""
+}
+
+func ptr(s string) *string {
+	if s = strings.TrimSpace(s); len(s) == 0 {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -69,6 +69,22 @@ E. TOTP 检查服务
 F. TOTP 状态服务
 - 查询用户的 2FA 状态
 - 返回设备列表信息
+
+
+
+WebAuthn 流程
+
+注册流程：
+- POST /api/2fa/webauthn/register/begin - 生成注册参数（passwordless=true 时创建通行密钥）
+- POST /api/2fa/webauthn/register/finish - 校验证明并保存凭证
+
+二次验证登录：
+1. POST /api/2fa/webauthn/login/begin → 使用用户名和密码获取断言参数
+2. POST /api/login → 携带 webauthn_ceremony_id 和 webauthn_credential
+
+免密登录（需开启 EnablePasswordless）：
+1. POST /api/2fa/webauthn/login/begin → 不携带用户名，获取可发现凭证的断言参数
+2. POST /api/2fa/webauthn/login/finish → 校验断言并创建会话
 */
 
 package twofa
@@ -79,7 +95,16 @@ import (
 	"github.com/forbearing/gst/types/consts"
 )
 
-// Register registers the models: TOTPBind, TOTPCheck, TOTPConfirm, TOTPDevice, TOTPStatus, TOTPUnbind and TOTPVerify.
+// Config is the configuration for twofa module.
+type Config struct {
+	RPID               string   // RPID is the WebAuthn relying party id, default is "localhost"
+	RPDisplayName      string   // RPDisplayName is the relying party name shown by authenticators, default is the framework name
+	RPOrigins          []string // RPOrigins are the allowed WebAuthn origins, default is "http://localhost:<server port>"
+	EnablePasswordless bool     // EnablePasswordless allows passkey login without password, default is false
+}
+
+// Register registers the models: TOTPBind, TOTPCheck, TOTPConfirm, TOTPDevice, TOTPStatus, TOTPUnbind, TOTPVerify,
+// WebAuthnCredential, WebAuthnRegisterBegin, WebAuthnRegisterFinish, WebAuthnLoginBegin and WebAuthnLoginFinish.
 //
 // Modules, Payload and Result:
 //   - TOTPBind, TOTPBindRsp
@@ -89,6 +114,11 @@ import (
 //   - TOTPStatus, TOTPStatusRsp
 //   - TOTPUnbind, TOTPUnbindReq, TOTPUnbindRsp
 //   - TOTPVerify, TOTPVerifyReq, TOTPVerifyRsp
+//   - WebAuthnCredential
+//   - WebAuthnRegisterBegin, WebAuthnRegisterBeginReq, WebAuthnRegisterBeginRsp
+//   - WebAuthnRegisterFinish, WebAuthnRegisterFinishReq, WebAuthnRegisterFinishRsp
+//   - WebAuthnLoginBegin, WebAuthnLoginBeginReq, WebAuthnLoginBeginRsp
+//   - WebAuthnLoginFinish, WebAuthnLoginFinishReq, WebAuthnLoginFinishRsp
 //
 // Routes
 //   - POST     /api/2fa/totp/bind
@@ -103,8 +133,27 @@ import (
 //   - PATCH    /api/2fa/totp/devices/:id
 //   - GET      /api/2fa/totp/devices
 //   - GET      /api/2fa/totp/devices/:id
-func Register() {
+//   - POST     /api/2fa/webauthn/register/begin
+//   - POST     /api/2fa/webauthn/register/finish
+//   - POST     /api/2fa/webauthn/login/begin
+//   - POST     /api/2fa/webauthn/login/finish
+//   - DELETE   /api/2fa/webauthn/credentials/:id
+//   - PATCH    /api/2fa/webauthn/credentials/:id
+//   - GET      /api/2fa/webauthn/credentials
+//   - GET      /api/2fa/webauthn/credentials/:id
+func Register(config ...Config) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
 	servicetwofa.Enabled = true
+	servicetwofa.SetWebAuthnConfig(servicetwofa.WebAuthnConfig{
+		RPID:               cfg.RPID,
+		RPDisplayName:      cfg.RPDisplayName,
+		RPOrigins:          cfg.RPOrigins,
+		EnablePasswordless: cfg.EnablePasswordless,
+	})
 
 	module.Use[
 		*TOTPBind,
@@ -166,4 +215,47 @@ func Register() {
 		&TOTPVerifyModule{},
 		consts.PHASE_CREATE,
 	)
+
+	module.Use[
+		*WebAuthnCredential,
+		*WebAuthnCredential,
+		*WebAuthnCredential](
+		&WebAuthnCredentialModule{},
+		consts.PHASE_DELETE,
+		consts.PHASE_PATCH,
+		consts.PHASE_LIST,
+		consts.PHASE_GET,
+	)
+
+	module.Use[
+		*WebAuthnRegisterBegin,
+		*WebAuthnRegisterBeginReq,
+		*WebAuthnRegisterBeginRsp](
+		&WebAuthnRegisterBeginModule{},
+		consts.PHASE_CREATE,
+	)
+
+	module.Use[
+		*WebAuthnRegisterFinish,
+		*WebAuthnRegisterFinishReq,
+		*WebAuthnRegisterFinishRsp](
+		&WebAuthnRegisterFinishModule{},
+		consts.PHASE_CREATE,
+	)
+
+	module.Use[
+		*WebAuthnLoginBegin,
+		*WebAuthnLoginBeginReq,
+		*WebAuthnLoginBeginRsp](
+		&WebAuthnLoginBeginModule{},
+		consts.PHASE_CREATE,
+	)
+
+	module.Use[
+		*WebAuthnLoginFinish,
+		*WebAuthnLoginFinishReq,
+		*WebAuthnLoginFinishRsp](
+		&WebAuthnLoginFinishModule{},
+		consts.PHASE_CREATE,
+	)
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/forbearing/gst/internal/helper"
 	"github.com/forbearing/gst/module/iam"
 	"github.com/forbearing/gst/module/twofa"
+	"github.com/forbearing/gst/pkg/webauthn/webauthntest"
 	"github.com/forbearing/gst/types/consts"
 	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/require"
@@ -34,6 +35,11 @@ var (
 	unbindAPI  = fmt.Sprintf("http://localhost:%d/api/2fa/totp/unbind", port)
 	statusAPI  = fmt.Sprintf("http://localhost:%d/api/2fa/totp/status", port)
 	deviceAPI  = fmt.Sprintf("http://localhost:%d/api/2fa/totp/devices", port)
+
+	webauthnRegisterBeginAPI  = fmt.Sprintf("http://localhost:%d/api/2fa/webauthn/register/begin", port)
+	webauthnRegisterFinishAPI = fmt.Sprintf("http://localhost:%d/api/2fa/webauthn/register/finish", port)
+	webauthnLoginBeginAPI     = fmt.Sprintf("http://localhost:%d/api/2fa/webauthn/login/begin", port)
+	webauthnLoginFinishAPI    = fmt.Sprintf("http://localhost:%d/api/2fa/webauthn/login/finish", port)
 )
 
 type ListResponse[T any] struct {
@@ -58,7 +64,7 @@ func init() {
 
 	go func() {
 		iam.Register()
-		twofa.Register()
+		twofa.Register(twofa.Config{EnablePasswordless: true})
 
 		if err := bootstrap.Run(); err != nil {
 			panic(err)
@@ -487,3 +493,158 @@ func Test2fa(t *testing.T) {
 		})
 	})
 }
+
+func TestWebAuthn(t *testing.T) {
+	username := "user02"
+	password := "12345678"
+	sessionID := ""
+	authenticator := webauthntest.New(fmt.Sprintf("http://localhost:%d", port))
+
+	t.Run("signup", func(t *testing.T) {
+		cli, err := client.New(signupAPI)
+		require.NoError(t, err)
+
+		resp, err := cli.Create(iam.SignupReq{
+			Username:   username,
+			Password:   password,
+			RePassword: password,
+		})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp iam.SignupRsp) {
+			require.Equal(t, username, rsp.Username)
+		})
+	})
+
+	t.Run("login", func(t *testing.T) {
+		cli, err := client.New(loginAPI)
+		require.NoError(t, err)
+
+		resp, err := cli.Create(iam.LoginReq{
+			Username: username,
+			Password: password,
+		})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *iam.LoginRsp) {
+			require.NotEmpty(t, rsp.SessionID)
+			sessionID = rsp.SessionID
+		})
+	})
+
+	t.Run("register", func(t *testing.T) {
+		cookie := client.WithCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		cli, err := client.New(webauthnRegisterBeginAPI, cookie)
+		require.NoError(t, err)
+
+		var begin *twofa.WebAuthnRegisterBeginRsp
+		resp, err := cli.Create(twofa.WebAuthnRegisterBeginReq{Passwordless: true})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *twofa.WebAuthnRegisterBeginRsp) {
+			require.NotEmpty(t, rsp.CeremonyID)
+			require.NotNil(t, rsp.PublicKey)
+			require.Equal(t, "localhost", rsp.PublicKey.RelyingParty.ID)
+			begin = rsp
+		})
+
+		credential, err := authenticator.Register(begin.PublicKey)
+		require.NoError(t, err)
+
+		cli, err = client.New(webauthnRegisterFinishAPI, cookie)
+		require.NoError(t, err)
+		resp, err = cli.Create(twofa.WebAuthnRegisterFinishReq{
+			CeremonyID: begin.CeremonyID,
+			DeviceName: "software-key",
+			Credential: *credential,
+		})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *twofa.WebAuthnRegisterFinishRsp) {
+			require.NotEmpty(t, rsp.ID)
+			require.NotEmpty(t, rsp.CredentialID)
+			require.True(t, rsp.Passwordless)
+		})
+
+		// The ceremony is single use.
+		_, err = cli.Create(twofa.WebAuthnRegisterFinishReq{
+			CeremonyID: begin.CeremonyID,
+			DeviceName: "software-key",
+			Credential: *credential,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("login_requires_2fa", func(t *testing.T) {
+		cli, err := client.New(loginAPI)
+		require.NoError(t, err)
+
+		_, err = cli.Create(iam.LoginReq{
+			Username: username,
+			Password: password,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("login_with_webauthn", func(t *testing.T) {
+		cli, err := client.New(webauthnLoginBeginAPI)
+		require.NoError(t, err)
+
+		var begin *twofa.WebAuthnLoginBeginRsp
+		resp, err := cli.Create(twofa.WebAuthnLoginBeginReq{Username: username, Password: password})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *twofa.WebAuthnLoginBeginRsp) {
+			require.NotEmpty(t, rsp.CeremonyID)
+			require.Len(t, rsp.PublicKey.AllowCredentials, 1)
+			begin = rsp
+		})
+
+		assertion, err := authenticator.Login(begin.PublicKey)
+		require.NoError(t, err)
+
+		cli, err = client.New(loginAPI)
+		require.NoError(t, err)
+		resp, err = cli.Create(iam.LoginReq{
+			Username:           username,
+			Password:           password,
+			WebAuthnCeremonyID: begin.CeremonyID,
+			WebAuthnCredential: assertion,
+		})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *iam.LoginRsp) {
+			require.NotEmpty(t, rsp.SessionID)
+		})
+	})
+
+	t.Run("login_begin_wrong_password", func(t *testing.T) {
+		cli, err := client.New(webauthnLoginBeginAPI)
+		require.NoError(t, err)
+
+		_, err = cli.Create(twofa.WebAuthnLoginBeginReq{Username: username, Password: "wrong-password"})
+		require.Error(t, err)
+	})
+
+	t.Run("passkey_login", func(t *testing.T) {
+		cli, err := client.New(webauthnLoginBeginAPI)
+		require.NoError(t, err)
+
+		var begin *twofa.WebAuthnLoginBeginRsp
+		resp, err := cli.Create(twofa.WebAuthnLoginBeginReq{})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *twofa.WebAuthnLoginBeginRsp) {
+			require.NotEmpty(t, rsp.CeremonyID)
+			require.Empty(t, rsp.PublicKey.AllowCredentials)
+			begin = rsp
+		})
+
+		assertion, err := authenticator.Login(begin.PublicKey)
+		require.NoError(t, err)
+
+		cli, err = client.New(webauthnLoginFinishAPI)
+		require.NoError(t, err)
+		resp, err = cli.Create(twofa.WebAuthnLoginFinishReq{
+			CeremonyID: begin.CeremonyID,
+			Credential: *assertion,
+		})
+		require.NoError(t, err)
+		helper.TestResp(t, resp, func(t *testing.T, rsp *twofa.WebAuthnLoginFinishRsp) {
+			require.NotEmpty(t, rsp.SessionID)
+		})
+	})
+}
@@ -0,0 +1,21 @@
+package twofa
+
+import (
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
+	"github.com/forbearing/gst/types"
+)
+
+var _ types.Module[*WebAuthnCredential, *WebAuthnCredential, *WebAuthnCredential] = (*WebAuthnCredentialModule)(nil)
+
+type (
+	WebAuthnCredential       = modeltwofa.WebAuthnCredential
+	WebAuthnCredentialModule struct{}
+)
+
+func (*WebAuthnCredentialModule) Service() types.Service[*WebAuthnCredential, *WebAuthnCredential, *WebAuthnCredential] {
+	return &servicetwofa.WebAuthnCredentialService{}
+}
+func (*WebAuthnCredentialModule) Route() string { return "2fa/webauthn/credentials" }
+func (*WebAuthnCredentialModule) Pub() bool     { return false }
+func (*WebAuthnCredentialModule) Param() string { return "id" }
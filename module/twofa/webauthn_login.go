@@ -0,0 +1,39 @@
+package twofa
+
+import (
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	serviceiamaccount "github.com/forbearing/gst/internal/service/iam/account"
+	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
+	"github.com/forbearing/gst/types"
+)
+
+var (
+	_ types.Module[*WebAuthnLoginBegin, *WebAuthnLoginBeginReq, *WebAuthnLoginBeginRsp]    = (*WebAuthnLoginBeginModule)(nil)
+	_ types.Module[*WebAuthnLoginFinish, *WebAuthnLoginFinishReq, *WebAuthnLoginFinishRsp] = (*WebAuthnLoginFinishModule)(nil)
+)
+
+type (
+	WebAuthnLoginBegin       = modeltwofa.WebAuthnLoginBegin
+	WebAuthnLoginBeginReq    = modeltwofa.WebAuthnLoginBeginReq
+	WebAuthnLoginBeginRsp    = modeltwofa.WebAuthnLoginBeginRsp
+	WebAuthnLoginBeginModule struct{}
+
+	WebAuthnLoginFinish       = modeltwofa.WebAuthnLoginFinish
+	WebAuthnLoginFinishReq    = modeltwofa.WebAuthnLoginFinishReq
+	WebAuthnLoginFinishRsp    = modeltwofa.WebAuthnLoginFinishRsp
+	WebAuthnLoginFinishModule struct{}
+)
+
+func (*WebAuthnLoginBeginModule) Service() types.Service[*WebAuthnLoginBegin, *WebAuthnLoginBeginReq, *WebAuthnLoginBeginRsp] {
+	return &servicetwofa.WebAuthnLoginBeginService{}
+}
+func (*WebAuthnLoginBeginModule) Route() string { return "2fa/webauthn/login/begin" }
+func (*WebAuthnLoginBeginModule) Pub() bool     { return true }
+func (*WebAuthnLoginBeginModule) Param() string { return "id" }
+
+func (*WebAuthnLoginFinishModule) Service() types.Service[*WebAuthnLoginFinish, *WebAuthnLoginFinishReq, *WebAuthnLoginFinishRsp] {
+	return &serviceiamaccount.PasskeyLoginService{}
+}
+func (*WebAuthnLoginFinishModule) Route() string { return "2fa/webauthn/login/finish" }
+func (*WebAuthnLoginFinishModule) Pub() bool     { return true }
+func (*WebAuthnLoginFinishModule) Param() string { return "id" }
@@ -0,0 +1,38 @@
+package twofa
+
+import (
+	modeltwofa "github.com/forbearing/gst/internal/model/twofa"
+	servicetwofa "github.com/forbearing/gst/internal/service/twofa"
+	"github.com/forbearing/gst/types"
+)
+
+var (
+	_ types.Module[*WebAuthnRegisterBegin, *WebAuthnRegisterBeginReq, *WebAuthnRegisterBeginRsp]    = (*WebAuthnRegisterBeginModule)(nil)
+	_ types.Module[*WebAuthnRegisterFinish, *WebAuthnRegisterFinishReq, *WebAuthnRegisterFinishRsp] = (*WebAuthnRegisterFinishModule)(nil)
+)
+
+type (
+	WebAuthnRegisterBegin       = modeltwofa.WebAuthnRegisterBegin
+	WebAuthnRegisterBeginReq    = modeltwofa.WebAuthnRegisterBeginReq
+	WebAuthnRegisterBeginRsp    = modeltwofa.WebAuthnRegisterBeginRsp
+	WebAuthnRegisterBeginModule struct{}
+
+	WebAuthnRegisterFinish       = modeltwofa.WebAuthnRegisterFinish
+	WebAuthnRegisterFinishReq    = modeltwofa.WebAuthnRegisterFinishReq
+	WebAuthnRegisterFinishRsp    = modeltwofa.WebAuthnRegisterFinishRsp
+	WebAuthnRegisterFinishModule struct{}
+)
+
+func (*WebAuthnRegisterBeginModule) Service() types.Service[*WebAuthnRegisterBegin, *WebAuthnRegisterBeginReq, *WebAuthnRegisterBeginRsp] {
+	return &servicetwofa.WebAuthnRegisterBeginService{}
+}
+func (*WebAuthnRegisterBeginModule) Route() string { return "2fa/webauthn/register/begin" }
+func (*WebAuthnRegisterBeginModule) Pub() bool     { return false }
+func (*WebAuthnRegisterBeginModule) Param() string { return "id" }
+
+func (*WebAuthnRegisterFinishModule) Service() types.Service[*WebAuthnRegisterFinish, *WebAuthnRegisterFinishReq, *WebAuthnRegisterFinishRsp] {
+	return &servicetwofa.WebAuthnRegisterFinishService{}
+}
+func (*WebAuthnRegisterFinishModule) Route() string { return "2fa/webauthn/register/finish" }
+func (*WebAuthnRegisterFinishModule) Pub() bool     { return false }
+func (*WebAuthnRegisterFinishModule) Param() string { return "id" }
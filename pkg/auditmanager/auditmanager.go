@@ -14,10 +14,19 @@ import (
 	"github.com/forbearing/gst/types"
 	"github.com/gertd/go-pluralize"
 	"go.uber.org/zap"
+	"gorm.io/datatypes"
 )
 
 var pluralizeCli = pluralize.NewClient()
 
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = 5 * time.Second
+	// insertBatchSize is the number of rows per INSERT, it's independent of the flush
+	// threshold(BatchSize) to stay within the placeholder limit of the databases.
+	insertBatchSize = 1000
+)
+
 // AuditManager manages audit logging based on configuration.
 // It provides a centralized way to handle operation logging across all Factory functions,
 // replacing the previous direct enqueuing of OperationLog records.
@@ -25,50 +34,47 @@ var pluralizeCli = pluralize.NewClient()
 type AuditManager struct {
 	config *config.Audit
 	cb     *circularbuffer.CircularBuffer[*modellogmgmt.OperationLog]
+
+	// flushCh wakes up Consume once the buffered logs reach the batch size.
+	flushCh chan struct{}
 }
 
 // New creates a new audit manager instance.
 // This replaces the previous direct usage of circular buffer for operation logging.
 func New(auditConfig *config.Audit, cb *circularbuffer.CircularBuffer[*modellogmgmt.OperationLog]) *AuditManager {
 	return &AuditManager{
-		config: auditConfig,
-		cb:     cb,
+		config:  auditConfig,
+		cb:      cb,
+		flushCh: make(chan struct{}, 1),
 	}
 }
 
 // RecordOperation records a single operation audit log.
 // This method is now used by all Factory functions instead of directly enqueuing OperationLog records.
 // It provides centralized audit logging with configurable filtering and supports both sync and async writing.
+//
+// The log is filtered by the audit policy before writing:
+//   - operations in ExcludeOperations and tables in ExcludeTables are skipped
+//   - the field changes are computed from OldRecord and NewRecord
+//   - JSON payloads only keep IncludeFields (if any), mask ExcludeFields recursively
+//     and truncate string values longer than MaxFieldLength
+//   - the Record* toggles drop the corresponding parts
+//
+// If the hash chain is enabled by EnableChain, the log is sealed into its chain when written.
+// Written logs are forwarded to the sinks registered by RegisterSink.
 func (am *AuditManager) RecordOperation(ctx *types.DatabaseContext, m types.Model, operationLog *modellogmgmt.OperationLog) error {
-	// Skip if audit is disabled
-	if !am.config.Enable {
-		return nil
-	}
-
-	// Skip if the operation is excluded.
-	if slices.Contains(am.config.ExcludeOperations, operationLog.OP) {
+	if !am.prepare(m, operationLog) {
 		return nil
 	}
-
-	// Record the table name
-	tableName := m.GetTableName()
-	if len(tableName) == 0 {
-		typ := reflect.TypeOf(m).Elem()
-		items := strings.Split(typ.Name(), ".")
-		if len(items) > 0 {
-			tableName = pluralizeCli.Plural(strings.ToLower(items[len(items)-1]))
-		}
-	}
-	operationLog.Table = tableName
+	setRealUser(ctx, operationLog)
 
 	if am.config.AsyncWrite {
-		// Use existing circular buffer for async writing
-		am.cb.Enqueue(operationLog)
+		am.enqueue(ctx, operationLog)
 		return nil
 	}
 
 	// Synchronous writing
-	if err := database.Database[*modellogmgmt.OperationLog](ctx).Create(operationLog); err != nil {
+	if err := am.create(ctx, operationLog); err != nil {
 		return errors.Wrap(err, "failed to write audit log")
 	}
 	return nil
@@ -76,57 +82,184 @@ func (am *AuditManager) RecordOperation(ctx *types.DatabaseContext, m types.Mode
 
 // RecordBatchOperations records multiple operations audit logs
 func (am *AuditManager) RecordBatchOperations(ctx *types.DatabaseContext, m types.Model, operationLogs []*modellogmgmt.OperationLog) error {
-	if !am.config.Enable {
-		return nil
-	}
-
-	if len(operationLogs) == 0 {
-		return nil
-	}
-
-	// Record the table name
-	tableName := m.GetTableName()
-	if len(tableName) == 0 {
-		typ := reflect.TypeOf(m).Elem()
-		items := strings.Split(typ.Name(), ".")
-		if len(items) > 0 {
-			tableName = pluralizeCli.Plural(strings.ToLower(items[len(items)-1]))
+	logs := make([]*modellogmgmt.OperationLog, 0, len(operationLogs))
+	for _, operationLog := range operationLogs {
+		if am.prepare(m, operationLog) {
+			setRealUser(ctx, operationLog)
+			logs = append(logs, operationLog)
 		}
 	}
-	for _, operationLog := range operationLogs {
-		operationLog.Table = tableName
+	if len(logs) == 0 {
+		return nil
 	}
 
 	if am.config.AsyncWrite {
-		// Enqueue all logs to circular buffer
-		for _, log := range operationLogs {
-			am.cb.Enqueue(log)
+		for _, log := range logs {
+			am.enqueue(ctx, log)
 		}
 		return nil
 	}
 
 	// Synchronous batch writing
-	if err := database.Database[*modellogmgmt.OperationLog](ctx).Create(operationLogs...); err != nil {
+	if err := am.create(ctx, logs...); err != nil {
 		return errors.Wrap(err, "failed to write batch audit logs")
 	}
 	return nil
 }
 
-// Consume operation log.
+// Consume writes the buffered operation logs every FlushInterval,
+// or earlier once BatchSize logs are buffered.
 func (am *AuditManager) Consume() {
-	operationLogs := make([]*modellogmgmt.OperationLog, 0, config.App.Server.CircularBuffer.SizeOperationLog)
-	ticker := time.NewTicker(5 * time.Second)
-
-	for range ticker.C {
-		operationLogs = operationLogs[:0]
-		for !am.cb.IsEmpty() {
-			ol, _ := am.cb.Dequeue()
-			operationLogs = append(operationLogs, ol)
+	ticker := time.NewTicker(am.flushInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-am.flushCh:
+		}
+		am.Flush()
+	}
+}
+
+// Flush writes all buffered operation logs to database.
+func (am *AuditManager) Flush() {
+	batchSize := am.batchSize()
+	operationLogs := make([]*modellogmgmt.OperationLog, 0, batchSize)
+	for !am.cb.IsEmpty() {
+		ol, ok := am.cb.Dequeue()
+		if !ok {
+			break
+		}
+		operationLogs = append(operationLogs, ol)
+		if len(operationLogs) >= batchSize {
+			am.write(operationLogs)
+			operationLogs = operationLogs[:0]
+		}
+	}
+	am.write(operationLogs)
+}
+
+func (am *AuditManager) write(operationLogs []*modellogmgmt.OperationLog) {
+	if len(operationLogs) == 0 {
+		return
+	}
+	if err := am.create(nil, operationLogs...); err != nil {
+		zap.S().Error(err)
+	}
+}
+
+// create writes the logs, sealing them into the hash chain if it is enabled,
+// and forwards them to the sinks.
+func (am *AuditManager) create(ctx *types.DatabaseContext, operationLogs ...*modellogmgmt.OperationLog) (err error) {
+	defer publishOperations(operationLogs)
+
+	db := database.Database[*modellogmgmt.OperationLog](ctx).WithLimit(-1).WithBatchSize(insertBatchSize)
+	if c := currentChain(); c != nil {
+		return c.write(db, operationLogs...)
+	}
+	return db.Create(operationLogs...)
+}
+
+// enqueue buffers the log for Consume. When the buffer is full the log is written synchronously
+// instead of being dropped.
+func (am *AuditManager) enqueue(ctx *types.DatabaseContext, operationLog *modellogmgmt.OperationLog) {
+	if !am.cb.Enqueue(operationLog) {
+		if err := am.create(ctx, operationLog); err != nil {
+			zap.S().Error(errors.Wrap(err, "failed to write audit log"))
 		}
-		if len(operationLogs) > 0 {
-			if err := database.Database[*modellogmgmt.OperationLog](nil).WithBatchSize(1000).Create(operationLogs...); err != nil {
-				zap.S().Error(err)
-			}
+	}
+	if am.cb.Len() >= am.batchSize() {
+		select {
+		case am.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// prepare applies the audit policy to the log, it returns false if the log should be skipped.
+func (am *AuditManager) prepare(m types.Model, operationLog *modellogmgmt.OperationLog) bool {
+	// Skip if audit is disabled
+	if !am.config.Enable {
+		return false
+	}
+	// Skip if the operation is excluded.
+	if slices.Contains(am.config.ExcludeOperations, operationLog.OP) {
+		return false
+	}
+
+	// Record the table name
+	operationLog.Table = tableName(m)
+	if slices.Contains(am.config.ExcludeTables, operationLog.Table) {
+		return false
+	}
+
+	p := newPolicy(am.config)
+	if len(operationLog.OldRecord) > 0 && len(operationLog.NewRecord) > 0 {
+		operationLog.Changes = datatypes.JSONSlice[modellogmgmt.FieldChange](p.diff(operationLog.OldRecord, operationLog.NewRecord))
+	}
+	operationLog.Record = p.sanitize(operationLog.Record)
+	operationLog.Request = p.sanitize(operationLog.Request)
+	operationLog.Response = p.sanitize(operationLog.Response)
+	operationLog.OldRecord = p.sanitize(operationLog.OldRecord)
+	operationLog.NewRecord = p.sanitize(operationLog.NewRecord)
+
+	if !am.config.RecordRequestBody {
+		operationLog.Request = ""
+	}
+	if !am.config.RecordResponseBody {
+		operationLog.Response = ""
+	}
+	if !am.config.RecordOldValues {
+		operationLog.OldRecord = ""
+		for i := range operationLog.Changes {
+			operationLog.Changes[i].Old = nil
+		}
+	}
+	if !am.config.RecordNewValues {
+		operationLog.NewRecord = ""
+		for i := range operationLog.Changes {
+			operationLog.Changes[i].New = nil
+		}
+	}
+	if !am.config.RecordQueryParams {
+		operationLog.URI, _, _ = strings.Cut(operationLog.URI, "?")
+	}
+	if !am.config.RecordUserAgent {
+		operationLog.UserAgent = ""
+	}
+	return true
+}
+
+func (am *AuditManager) batchSize() int {
+	if am.config.BatchSize > 0 {
+		return am.config.BatchSize
+	}
+	return defaultBatchSize
+}
+
+func (am *AuditManager) flushInterval() time.Duration {
+	if d, err := time.ParseDuration(am.config.FlushInterval); err == nil && d > 0 {
+		return d
+	}
+	return defaultFlushInterval
+}
+
+// setRealUser records the administrator impersonating the operation log user.
+func setRealUser(ctx *types.DatabaseContext, operationLog *modellogmgmt.OperationLog) {
+	if ctx.Impersonating() && len(operationLog.RealUser) == 0 {
+		operationLog.RealUser = ctx.RealUsername
+	}
+}
+
+func tableName(m types.Model) string {
+	name := m.GetTableName()
+	if len(name) == 0 {
+		typ := reflect.TypeOf(m).Elem()
+		items := strings.Split(typ.Name(), ".")
+		if len(items) > 0 {
+			name = pluralizeCli.Plural(strings.ToLower(items[len(items)-1]))
 		}
 	}
+	return name
 }
@@ -0,0 +1,281 @@
+package auditmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+)
+
+// ChainScope decides which operation logs share a hash chain.
+type ChainScope string
+
+const (
+	// ChainScopeGlobal chains all operation logs together.
+	ChainScopeGlobal ChainScope = "global"
+	// ChainScopeTable chains the operation logs of each table separately.
+	ChainScopeTable ChainScope = "table"
+)
+
+// ChainKeyGlobal is the chain key of ChainScopeGlobal.
+const ChainKeyGlobal = "global"
+
+// chainWriteRetries is the max number of writes of a batch whose chain heads were advanced by other instances.
+const chainWriteRetries = 5
+
+// chain seals operation logs into hash chains.
+// The head of every chain is cached and advanced while holding mu across the database write.
+// With multiple writer instances the cached head may be stale, the unique (chain_key, seq)
+// rejects the write, and the batch is sealed again over the heads reloaded from database.
+type chain struct {
+	mu    sync.Mutex
+	scope ChainScope
+	heads map[string]head
+}
+
+type head struct {
+	seq  uint64
+	hash string
+}
+
+var (
+	chainMu     sync.RWMutex
+	activeChain *chain
+)
+
+// EnableChain seals every operation log written by the audit manager into a hash chain.
+// An empty scope defaults to ChainScopeGlobal.
+func EnableChain(scope ChainScope) {
+	if scope != ChainScopeTable {
+		scope = ChainScopeGlobal
+	}
+	chainMu.Lock()
+	defer chainMu.Unlock()
+	activeChain = &chain{scope: scope, heads: make(map[string]head)}
+}
+
+// ChainEnabled reports whether operation logs are hash chained.
+func ChainEnabled() bool {
+	return currentChain() != nil
+}
+
+func currentChain() *chain {
+	chainMu.RLock()
+	defer chainMu.RUnlock()
+	return activeChain
+}
+
+// write seals the logs and writes them in one transaction, the chain heads only advance if the write succeeds.
+func (c *chain) write(db types.Database[*modellogmgmt.OperationLog], operationLogs ...*modellogmgmt.OperationLog) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for range chainWriteRetries {
+		var prev, heads map[string]head
+		if prev, heads, err = c.seal(operationLogs); err != nil {
+			return err
+		}
+		if err = db.Transaction(func(tx types.Database[*modellogmgmt.OperationLog]) error {
+			return tx.Create(operationLogs...)
+		}); err == nil {
+			maps.Copy(c.heads, heads)
+			return nil
+		}
+
+		// Reload the heads from database, retry only if another instance has appended to the chains.
+		moved := false
+		for key, h := range prev {
+			delete(c.heads, key)
+			last, e := lastRecord(key)
+			if e != nil {
+				return errors.CombineErrors(err, e)
+			}
+			if last != nil && last.Seq != h.seq {
+				moved = true
+			}
+		}
+		if !moved {
+			return err
+		}
+	}
+	return errors.Wrapf(err, "operation log chain is contended, gave up after %d writes", chainWriteRetries)
+}
+
+// seal seals the logs over the current chain heads,
+// it returns the heads before and after the logs of every chain.
+func (c *chain) seal(operationLogs []*modellogmgmt.OperationLog) (prev, heads map[string]head, err error) {
+	prev = make(map[string]head)
+	heads = make(map[string]head)
+	for _, ol := range operationLogs {
+		key := c.key(ol)
+		h, ok := heads[key]
+		if !ok {
+			if h, err = c.head(key); err != nil {
+				return nil, nil, err
+			}
+			prev[key] = h
+		}
+		if err = seal(ol, key, h); err != nil {
+			return nil, nil, err
+		}
+		heads[key] = head{seq: ol.Seq, hash: ol.Hash}
+	}
+	return prev, heads, nil
+}
+
+func (c *chain) key(ol *modellogmgmt.OperationLog) string {
+	if c.scope == ChainScopeTable && len(ol.Table) > 0 {
+		return ol.Table
+	}
+	return ChainKeyGlobal
+}
+
+// head returns the cached chain head, or loads it from database.
+func (c *chain) head(key string) (head, error) {
+	if h, ok := c.heads[key]; ok {
+		return h, nil
+	}
+	last, err := lastRecord(key)
+	if err != nil {
+		return head{}, err
+	}
+	if last == nil {
+		return head{}, nil
+	}
+	return head{seq: last.Seq, hash: last.Hash}, nil
+}
+
+// lastRecord returns the record with the largest seq of the chain, or nil if the chain is empty.
+func lastRecord(key string) (*modellogmgmt.OperationLog, error) {
+	logs := make([]*modellogmgmt.OperationLog, 0, 1)
+	if err := database.Database[*modellogmgmt.OperationLog](nil).
+		WithQuery(&modellogmgmt.OperationLog{ChainKey: key}).
+		WithOrder("seq desc").
+		WithLimit(1).
+		List(&logs); err != nil {
+		return nil, errors.Wrapf(err, "failed to load head of operation log chain %q", key)
+	}
+	if len(logs) == 0 {
+		return nil, nil
+	}
+	return logs[0], nil
+}
+
+// seal links the log to the previous chain head.
+// The id is assigned here because it is part of the hash.
+func seal(ol *modellogmgmt.OperationLog, key string, prev head) error {
+	ol.SetID()
+	ol.ChainKey = key
+	ol.Seq = prev.seq + 1
+	ol.PrevHash = prev.hash
+	ol.ChainedAt = time.Now().UnixNano()
+	hash, err := Hash(ol)
+	if err != nil {
+		return err
+	}
+	ol.Hash = hash
+	return nil
+}
+
+// hashContent is the hashed content of an operation log.
+// Fields are listed explicitly so that adding fields to OperationLog does not invalidate existing chains.
+type hashContent struct {
+	ID         string          `json:"id"`
+	ChainKey   string          `json:"chain_key"`
+	Seq        uint64          `json:"seq"`
+	ChainedAt  int64           `json:"chained_at"`
+	User       string          `json:"user"`
+	RealUser   string          `json:"real_user,omitempty"` // omitted when empty to keep the hashes of older records
+	IP         string          `json:"ip"`
+	OP         consts.OP       `json:"op"`
+	Table      string          `json:"table"`
+	Model      string          `json:"model"`
+	RecordID   string          `json:"record_id"`
+	RecordName string          `json:"record_name"`
+	Record     string          `json:"record"`
+	Request    string          `json:"request"`
+	Response   string          `json:"response"`
+	OldRecord  string          `json:"old_record"`
+	NewRecord  string          `json:"new_record"`
+	Changes    json.RawMessage `json:"changes"`
+	Method     string          `json:"method"`
+	URI        string          `json:"uri"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+}
+
+// Hash computes the chain hash of the operation log: sha256(PrevHash + "\n" + content).
+func Hash(ol *modellogmgmt.OperationLog) (string, error) {
+	changes, err := canonicalChanges(ol.Changes)
+	if err != nil {
+		return "", err
+	}
+	content, err := json.Marshal(hashContent{
+		ID:         ol.ID,
+		ChainKey:   ol.ChainKey,
+		Seq:        ol.Seq,
+		ChainedAt:  ol.ChainedAt,
+		User:       ol.User,
+		RealUser:   ol.RealUser,
+		IP:         ol.IP,
+		OP:         ol.OP,
+		Table:      ol.Table,
+		Model:      ol.Model,
+		RecordID:   ol.RecordID,
+		RecordName: ol.RecordName,
+		Record:     ol.Record,
+		Request:    ol.Request,
+		Response:   ol.Response,
+		OldRecord:  ol.OldRecord,
+		NewRecord:  ol.NewRecord,
+		Changes:    changes,
+		Method:     ol.Method,
+		URI:        ol.URI,
+		UserAgent:  ol.UserAgent,
+		RequestID:  ol.RequestID,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal operation log")
+	}
+	sum := sha256.New()
+	sum.Write([]byte(ol.PrevHash))
+	sum.Write([]byte("\n"))
+	sum.Write(content)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// canonicalChanges encodes the field changes the way they read back from database:
+// values are decoded without json.Number, so large numbers lose precision the same way
+// on both sides of the comparison.
+func canonicalChanges(changes []modellogmgmt.FieldChange) (json.RawMessage, error) {
+	if len(changes) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal operation log changes")
+	}
+	decoded := make([]modellogmgmt.FieldChange, 0, len(changes))
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal operation log changes")
+	}
+	if data, err = json.Marshal(decoded); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal operation log changes")
+	}
+	return data, nil
+}
+
+// formatSeq is used in error messages and checkpoint object names.
+func formatSeq(seq uint64) string {
+	return strconv.FormatUint(seq, 10)
+}
@@ -0,0 +1,111 @@
+package auditmanager
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func newChain(t *testing.T, n int) []*modellogmgmt.OperationLog {
+	t.Helper()
+	logs := make([]*modellogmgmt.OperationLog, 0, n)
+	var h head
+	for range n {
+		ol := &modellogmgmt.OperationLog{
+			OP:      consts.OP_UPDATE,
+			Table:   "users",
+			Changes: []modellogmgmt.FieldChange{{Field: "n", Old: 1, New: uint64(12345678901234567890)}},
+		}
+		require.NoError(t, seal(ol, ChainKeyGlobal, h))
+		h = head{seq: ol.Seq, hash: ol.Hash}
+		logs = append(logs, ol)
+	}
+	return logs
+}
+
+func verify(logs []*modellogmgmt.OperationLog, cps []*modellogmgmt.OperationLogCheckpoint, pub ed25519.PublicKey) *modellogmgmt.ChainResult {
+	v := newVerifier(ChainKeyGlobal, cps, pub)
+	for _, ol := range logs {
+		if v.add(ol); v.done() {
+			break
+		}
+	}
+	return v.result()
+}
+
+func signCheckpoint(key ed25519.PrivateKey, ol *modellogmgmt.OperationLog) *modellogmgmt.OperationLogCheckpoint {
+	cp := &modellogmgmt.OperationLogCheckpoint{ChainKey: ol.ChainKey, Seq: ol.Seq, Hash: ol.Hash, CheckpointAt: 1}
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, cp.Payload()))
+	return cp
+}
+
+func TestChainVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	logs := newChain(t, 5)
+	require.Equal(t, uint64(5), logs[4].Seq)
+	require.Equal(t, logs[3].Hash, logs[4].PrevHash)
+
+	cps := []*modellogmgmt.OperationLogCheckpoint{signCheckpoint(key, logs[2]), signCheckpoint(key, logs[4])}
+	res := verify(logs, cps, pub)
+	require.True(t, res.Valid)
+	require.Equal(t, uint64(5), res.Records)
+	require.Equal(t, 2, res.Checkpoints)
+
+	// altered content
+	logs = newChain(t, 5)
+	logs[1].User = "mallory"
+	res = verify(logs, nil, nil)
+	require.False(t, res.Valid)
+	require.Equal(t, uint64(2), res.Broken.Seq)
+	require.Equal(t, logs[1].ID, res.Broken.RecordID)
+
+	// deleted record
+	logs = newChain(t, 5)
+	res = verify(append(logs[:2:2], logs[3:]...), nil, nil)
+	require.False(t, res.Valid)
+	require.Equal(t, uint64(3), res.Broken.Seq)
+
+	// rewritten chain, detected by the checkpoint
+	logs = newChain(t, 5)
+	cps = []*modellogmgmt.OperationLogCheckpoint{signCheckpoint(key, logs[2])}
+	rewritten := newChain(t, 5)
+	res = verify(rewritten, cps, pub)
+	require.False(t, res.Valid)
+	require.Equal(t, uint64(3), res.Broken.Seq)
+
+	// truncated tail
+	logs = newChain(t, 5)
+	cps = []*modellogmgmt.OperationLogCheckpoint{signCheckpoint(key, logs[4])}
+	res = verify(logs[:3], cps, pub)
+	require.False(t, res.Valid)
+	require.Equal(t, uint64(4), res.Broken.Seq)
+
+	// forged checkpoint
+	logs = newChain(t, 5)
+	cps = []*modellogmgmt.OperationLogCheckpoint{signCheckpoint(key, logs[4])}
+	cps[0].Seq = 4
+	res = verify(logs, cps, pub)
+	require.False(t, res.Valid)
+	require.Equal(t, "checkpoint signature is invalid", res.Broken.Reason)
+}
+
+func TestHashChangesRoundTrip(t *testing.T) {
+	ol := newChain(t, 1)[0]
+
+	// Changes read back from database are decoded without json.Number.
+	data, err := ol.Changes.Value()
+	require.NoError(t, err)
+	read := *ol
+	read.Changes = nil
+	require.NoError(t, read.Changes.Scan(data))
+
+	hash, err := Hash(&read)
+	require.NoError(t, err)
+	require.Equal(t, ol.Hash, hash)
+}
@@ -0,0 +1,113 @@
+package auditmanager
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types"
+)
+
+// CheckpointSink exports checkpoints to a location outside the database,
+// it should be write-once so that the checkpoints can not be rewritten together with the chain.
+type CheckpointSink interface {
+	Export(ctx context.Context, cp *modellogmgmt.OperationLogCheckpoint) error
+}
+
+// KeyID identifies the ed25519 key that signs the checkpoints.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Checkpoint signs the head of every chain that advanced since its last checkpoint,
+// saves the checkpoint and exports it to the sink if not nil.
+func Checkpoint(ctx context.Context, key ed25519.PrivateKey, sink CheckpointSink) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return errors.New("invalid checkpoint signing key")
+	}
+	keys, err := ChainKeys()
+	if err != nil {
+		return err
+	}
+	for _, chainKey := range keys {
+		last, err := lastRecord(chainKey)
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			continue
+		}
+		cp, err := lastCheckpoint(chainKey)
+		if err != nil {
+			return err
+		}
+		if cp != nil && cp.Seq >= last.Seq {
+			continue
+		}
+
+		cp = &modellogmgmt.OperationLogCheckpoint{
+			ChainKey:     chainKey,
+			Seq:          last.Seq,
+			Hash:         last.Hash,
+			CheckpointAt: time.Now().UnixNano(),
+			KeyID:        KeyID(key.Public().(ed25519.PublicKey)),
+		}
+		cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, cp.Payload()))
+		if err = database.Database[*modellogmgmt.OperationLogCheckpoint](nil).Create(cp); err != nil {
+			return errors.Wrapf(err, "failed to save checkpoint of operation log chain %q", chainKey)
+		}
+		if sink != nil {
+			if err = sink.Export(ctx, cp); err != nil {
+				return errors.Wrapf(err, "failed to export checkpoint of operation log chain %q at seq %s", chainKey, formatSeq(cp.Seq))
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyCheckpoint verifies the signature of the checkpoint.
+func VerifyCheckpoint(cp *modellogmgmt.OperationLogCheckpoint, pub ed25519.PublicKey) bool {
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil {
+		return false
+	}
+	return len(pub) == ed25519.PublicKeySize && ed25519.Verify(pub, cp.Payload(), sig)
+}
+
+// ChainKeys returns the keys of all operation log chains.
+func ChainKeys() ([]string, error) {
+	logs := make([]*modellogmgmt.OperationLog, 0)
+	if err := database.Database[*modellogmgmt.OperationLog](nil).
+		WithQuery(nil, types.QueryConfig{RawQuery: "seq > ?", RawQueryArgs: []any{0}}).
+		WithSelectRaw("DISTINCT chain_key").
+		List(&logs); err != nil {
+		return nil, errors.Wrap(err, "failed to list operation log chains")
+	}
+	keys := make([]string, 0, len(logs))
+	for _, ol := range logs {
+		keys = append(keys, ol.ChainKey)
+	}
+	return keys, nil
+}
+
+func lastCheckpoint(chainKey string) (*modellogmgmt.OperationLogCheckpoint, error) {
+	cps := make([]*modellogmgmt.OperationLogCheckpoint, 0, 1)
+	if err := database.Database[*modellogmgmt.OperationLogCheckpoint](nil).
+		WithQuery(&modellogmgmt.OperationLogCheckpoint{ChainKey: chainKey}).
+		WithOrder("seq desc").
+		WithLimit(1).
+		List(&cps); err != nil {
+		return nil, errors.Wrapf(err, "failed to load checkpoint of operation log chain %q", chainKey)
+	}
+	if len(cps) == 0 {
+		return nil, nil
+	}
+	return cps[0], nil
+}
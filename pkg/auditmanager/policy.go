@@ -0,0 +1,216 @@
+package auditmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/forbearing/gst/config"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+)
+
+// maskedValue replaces the values of excluded fields.
+const maskedValue = "******"
+
+// diffIgnoreFields always change on update and carry no information for the field diff.
+var diffIgnoreFields = []string{"updated_at"}
+
+// policy applies the field rules of the audit config to JSON payloads.
+type policy struct {
+	exclude   []string
+	include   []string
+	maxLength int
+}
+
+func newPolicy(cfg *config.Audit) *policy {
+	p := &policy{maxLength: cfg.MaxFieldLength}
+	for _, f := range cfg.ExcludeFields {
+		if f = normalizeField(f); len(f) > 0 {
+			p.exclude = append(p.exclude, f)
+		}
+	}
+	for _, f := range cfg.IncludeFields {
+		if f = normalizeField(f); len(f) > 0 {
+			p.include = append(p.include, f)
+		}
+	}
+	return p
+}
+
+// sanitize applies the field rules to the payload.
+// A JSON object, or an array of JSON objects, only keeps the included top-level fields,
+// has the excluded fields masked at any depth and its long string values truncated.
+// Other payloads are truncated as a whole.
+func (p *policy) sanitize(payload string) string {
+	if len(payload) == 0 {
+		return payload
+	}
+	v, ok := decode(payload)
+	if !ok {
+		return p.truncate(payload)
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		v = p.filter(val)
+	case []any:
+		for i := range val {
+			if obj, isObj := val[i].(map[string]any); isObj {
+				val[i] = p.filter(obj)
+			}
+		}
+	}
+	data, err := json.Marshal(p.mask(v))
+	if err != nil {
+		return p.truncate(payload)
+	}
+	return string(data)
+}
+
+// diff returns the changed top-level fields between the old and new JSON object,
+// sorted by field name. The rules of sanitize apply to the fields and their values.
+func (p *policy) diff(oldPayload, newPayload string) []modellogmgmt.FieldChange {
+	oldVal, ok1 := decode(oldPayload)
+	newVal, ok2 := decode(newPayload)
+	oldObj, ok3 := oldVal.(map[string]any)
+	newObj, ok4 := newVal.(map[string]any)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(oldObj)+len(newObj))
+	for k := range oldObj {
+		fields = append(fields, k)
+	}
+	for k := range newObj {
+		if _, exists := oldObj[k]; !exists {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]modellogmgmt.FieldChange, 0)
+	for _, field := range fields {
+		if slices.Contains(diffIgnoreFields, field) || !p.included(field) {
+			continue
+		}
+		o, n := oldObj[field], newObj[field]
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		if p.excluded(field) {
+			// Report that the secret changed without revealing it.
+			changes = append(changes, modellogmgmt.FieldChange{Field: field, Old: maskedValue, New: maskedValue})
+			continue
+		}
+		changes = append(changes, modellogmgmt.FieldChange{Field: field, Old: p.mask(o), New: p.mask(n)})
+	}
+	return changes
+}
+
+// filter keeps the included fields of the top-level object.
+func (p *policy) filter(obj map[string]any) map[string]any {
+	if len(p.include) == 0 {
+		return obj
+	}
+	for k := range obj {
+		if !p.included(k) {
+			delete(obj, k)
+		}
+	}
+	return obj
+}
+
+// mask masks the excluded fields recursively and truncates long strings.
+func (p *policy) mask(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if p.excluded(k) {
+				val[k] = maskedValue
+			} else {
+				val[k] = p.mask(item)
+			}
+		}
+		return val
+	case []any:
+		for i := range val {
+			val[i] = p.mask(val[i])
+		}
+		return val
+	case string:
+		return p.truncate(val)
+	default:
+		return v
+	}
+}
+
+func (p *policy) truncate(s string) string {
+	if p.maxLength <= 0 || utf8.RuneCountInString(s) <= p.maxLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:p.maxLength]) + "...(truncated)"
+}
+
+func (p *policy) included(field string) bool {
+	if len(p.include) == 0 {
+		return true
+	}
+	field = normalizeField(field)
+	return field == "id" || slices.Contains(p.include, field)
+}
+
+// excluded reports whether the field matches one of the excluded fields.
+// The excluded field matches the whole field name or one of its "_" separated parts,
+// eg: "token" matches "token", "access_token", "accessToken" and "token_hash", but not "tokenizer".
+func (p *policy) excluded(field string) bool {
+	field = normalizeField(field)
+	for _, f := range p.exclude {
+		if field == f ||
+			strings.HasPrefix(field, f+"_") ||
+			strings.HasSuffix(field, "_"+f) ||
+			strings.Contains(field, "_"+f+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeField converts the field name into lower snake case,
+// eg: "accessToken", "Access-Token" and "ACCESS_TOKEN" are all normalized to "access_token",
+// the acronyms are kept as one part: "APIKey" to "api_key", "userID" to "user_id".
+func normalizeField(field string) string {
+	runes := []rune(strings.TrimSpace(field))
+	var b strings.Builder
+	b.Grow(len(runes) + 4)
+	for i, r := range runes {
+		if r == '-' {
+			r = '_'
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// decode decodes the JSON payload, numbers are kept as json.Number to avoid precision loss.
+func decode(payload string) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	return v, true
+}
@@ -0,0 +1,100 @@
+package auditmanager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/forbearing/gst/config"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicySanitize(t *testing.T) {
+	p := newPolicy(&config.Audit{
+		ExcludeFields:  []string{"password", "token", "key"},
+		MaxFieldLength: 5,
+	})
+
+	got := p.sanitize(`{"name":"alice","password":"p","profile":{"access_token":"t","keyword":"abcdefgh"},"keys":[{"api_key":"k"}],"n":12345678901234567890}`)
+	require.JSONEq(t, `{
+		"name":"alice",
+		"password":"******",
+		"profile":{"access_token":"******","keyword":"abcde...(truncated)"},
+		"keys":[{"api_key":"******"}],
+		"n":12345678901234567890
+	}`, got)
+
+	require.Equal(t, "plain...(truncated)", p.sanitize("plain text"))
+	require.Empty(t, p.sanitize(""))
+
+	p = newPolicy(&config.Audit{IncludeFields: []string{"name"}})
+	require.JSONEq(t, `[{"id":"1","name":"a"},{"id":"2","name":"b"}]`,
+		p.sanitize(`[{"id":"1","name":"a","age":1},{"id":"2","name":"b","age":2}]`))
+}
+
+func TestPolicyExcludedCamelCase(t *testing.T) {
+	p := newPolicy(&config.Audit{ExcludeFields: []string{"token", "secret", "api_key"}})
+
+	for _, field := range []string{"accessToken", "refreshToken", "clientSecret", "AccessToken", "APIKey", "x-api-key", "ACCESS_TOKEN"} {
+		require.True(t, p.excluded(field), field)
+	}
+	for _, field := range []string{"tokenizer", "secretary", "name", "userID"} {
+		require.False(t, p.excluded(field), field)
+	}
+
+	require.JSONEq(t, `{"accessToken":"******","clientSecret":"******","userName":"alice"}`,
+		p.sanitize(`{"accessToken":"t","clientSecret":"s","userName":"alice"}`))
+}
+
+func TestPolicyDiff(t *testing.T) {
+	p := newPolicy(&config.Audit{ExcludeFields: []string{"password"}})
+
+	changes := p.diff(
+		`{"id":"1","name":"a","age":1,"password_hash":"x","tags":["a"],"updated_at":"t1"}`,
+		`{"id":"1","name":"b","age":1,"password_hash":"y","tags":["a","b"],"updated_at":"t2","email":"e"}`,
+	)
+	data, err := json.Marshal(changes)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"field":"email","old":null,"new":"e"},
+		{"field":"name","old":"a","new":"b"},
+		{"field":"password_hash","old":"******","new":"******"},
+		{"field":"tags","old":["a"],"new":["a","b"]}
+	]`, string(data))
+
+	require.Nil(t, p.diff(`[]`, `{}`))
+}
+
+func TestAuditManagerPrepare(t *testing.T) {
+	am := New(&config.Audit{
+		Enable:            true,
+		ExcludeOperations: []consts.OP{consts.OP_LIST},
+		ExcludeTables:     []string{"secrets"},
+		ExcludeFields:     []string{"password"},
+		RecordNewValues:   true,
+	}, nil)
+
+	ol := &modellogmgmt.OperationLog{
+		OP:        consts.OP_UPDATE,
+		Request:   `{"password":"p"}`,
+		Response:  `{"name":"b"}`,
+		OldRecord: `{"name":"a"}`,
+		NewRecord: `{"name":"b"}`,
+		URI:       "/api/users/1?token=x",
+		UserAgent: "curl",
+	}
+	require.True(t, am.prepare(&modellogmgmt.OperationLog{}, ol))
+	require.Equal(t, "operationlogs", ol.Table)
+	require.Empty(t, ol.Request)
+	require.Empty(t, ol.Response)
+	require.Empty(t, ol.OldRecord)
+	require.JSONEq(t, `{"name":"b"}`, ol.NewRecord)
+	require.Equal(t, "/api/users/1", ol.URI)
+	require.Empty(t, ol.UserAgent)
+	require.Len(t, ol.Changes, 1)
+	require.Nil(t, ol.Changes[0].Old)
+	require.Equal(t, "b", ol.Changes[0].New)
+
+	require.False(t, am.prepare(&modellogmgmt.OperationLog{}, &modellogmgmt.OperationLog{OP: consts.OP_LIST}))
+}
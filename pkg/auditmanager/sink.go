@@ -0,0 +1,380 @@
+package auditmanager
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	"github.com/forbearing/gst/ds/queue/circularbuffer"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	prommetrics "github.com/forbearing/gst/metrics"
+	"github.com/forbearing/gst/types"
+	"github.com/forbearing/gst/types/consts"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSinkBufferSize    = 10000
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second
+	defaultSinkRetries       = 3
+	defaultSinkBlockTimeout  = time.Second
+)
+
+// EventType is the type of audit event.
+type EventType string
+
+const (
+	EventOperation EventType = "operation"
+	EventLogin     EventType = "login"
+)
+
+// Event is an audit event forwarded to the sinks, only one of Operation and Login is set.
+type Event struct {
+	Type      EventType                  `json:"type"`
+	Time      time.Time                  `json:"time"`
+	Operation *modellogmgmt.OperationLog `json:"operation,omitempty"`
+	Login     *modellogmgmt.LoginLog     `json:"login,omitempty"`
+}
+
+// ID returns the id of the operation log or login log.
+func (e *Event) ID() string {
+	switch {
+	case e.Operation != nil:
+		return e.Operation.ID
+	case e.Login != nil:
+		return e.Login.ID
+	}
+	return ""
+}
+
+// User returns the user of the event.
+func (e *Event) User() string {
+	switch {
+	case e.Operation != nil:
+		return e.Operation.User
+	case e.Login != nil:
+		return e.Login.Username
+	}
+	return ""
+}
+
+// Sink forwards audit events to an external system, eg: a SIEM.
+// Write is called from a single goroutine per sink with up to BatchSize events.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+// OverflowPolicy decides what happens to new events when the sink buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowDropNewest drops the new event, this is the default policy.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest drops the oldest buffered event to make room for the new event.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowBlock blocks the writer up to BlockTimeout, then drops the new event.
+	// It slows down the requests writing audit logs instead of losing events.
+	OverflowBlock OverflowPolicy = "block"
+)
+
+// SinkConfig is the configuration of a sink.
+type SinkConfig struct {
+	Sink Sink
+
+	// Filter only forwards the events it returns true for, nil forwards all events.
+	// See FilterTypes, FilterTables, FilterOperations and FilterAll.
+	Filter func(*Event) bool
+
+	BufferSize    int            // BufferSize is the number of buffered events, default is 10000.
+	BatchSize     int            // BatchSize is the max number of events per Write, default is 100.
+	FlushInterval time.Duration  // FlushInterval is the max delay of buffered events, default is 1s.
+	Retries       int            // Retries is the number of retries of a failed Write, default is 3, negative disables retries.
+	Overflow      OverflowPolicy // Overflow decides what to do when the buffer is full, default is OverflowDropNewest.
+	BlockTimeout  time.Duration  // BlockTimeout is the max wait of OverflowBlock, default is 1s.
+}
+
+// FilterTypes forwards the events of the given types.
+func FilterTypes(typs ...EventType) func(*Event) bool {
+	return func(e *Event) bool { return slices.Contains(typs, e.Type) }
+}
+
+// FilterTables forwards the operation logs of the given tables and all login logs.
+func FilterTables(tables ...string) func(*Event) bool {
+	return func(e *Event) bool { return e.Operation == nil || slices.Contains(tables, e.Operation.Table) }
+}
+
+// FilterOperations forwards the operation logs of the given operations and all login logs.
+func FilterOperations(ops ...consts.OP) func(*Event) bool {
+	return func(e *Event) bool { return e.Operation == nil || slices.Contains(ops, e.Operation.OP) }
+}
+
+// FilterAll forwards the events matching all filters.
+func FilterAll(filters ...func(*Event) bool) func(*Event) bool {
+	return func(e *Event) bool {
+		for _, f := range filters {
+			if f != nil && !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+var (
+	sinkMu sync.RWMutex
+	sinks  []*sinkWorker
+)
+
+// RegisterSink starts forwarding audit events to the sink.
+// Events are buffered per sink and written in batches by a background goroutine,
+// so a slow or unavailable sink never blocks the database writer unless OverflowBlock is used.
+func RegisterSink(cfg SinkConfig) error {
+	if cfg.Sink == nil {
+		return errors.New("audit sink is nil")
+	}
+	w, err := newSinkWorker(cfg)
+	if err != nil {
+		return err
+	}
+	sinkMu.Lock()
+	sinks = append(sinks, w)
+	sinkMu.Unlock()
+	go w.run()
+	return nil
+}
+
+// CloseSinks flushes the buffered events and closes all sinks.
+func CloseSinks() {
+	sinkMu.Lock()
+	ws := sinks
+	sinks = nil
+	sinkMu.Unlock()
+	for _, w := range ws {
+		w.close()
+	}
+}
+
+// RecordLogin writes the login log and forwards it to the sinks.
+func RecordLogin(ctx *types.DatabaseContext, loginLog *modellogmgmt.LoginLog) error {
+	err := database.Database[*modellogmgmt.LoginLog](ctx).Create(loginLog)
+	publish(&Event{Type: EventLogin, Time: time.Now(), Login: loginLog})
+	if err != nil {
+		return errors.Wrap(err, "failed to write login log")
+	}
+	return nil
+}
+
+func publishOperations(operationLogs []*modellogmgmt.OperationLog) {
+	now := time.Now()
+	for _, ol := range operationLogs {
+		publish(&Event{Type: EventOperation, Time: now, Operation: ol})
+	}
+}
+
+func publish(e *Event) {
+	sinkMu.RLock()
+	ws := sinks
+	sinkMu.RUnlock()
+	for _, w := range ws {
+		w.enqueue(e)
+	}
+}
+
+type sinkWorker struct {
+	cfg  SinkConfig
+	name string
+	cb   *circularbuffer.CircularBuffer[*Event]
+
+	flushCh chan struct{} // flushCh wakes up run once BatchSize events are buffered.
+	spaceMu sync.Mutex
+	spaceCh chan struct{} // spaceCh is closed to wake up the blocked writers after a batch is dequeued.
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func newSinkWorker(cfg SinkConfig) (*sinkWorker, error) {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultSinkBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultSinkBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultSinkFlushInterval
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	} else if cfg.Retries == 0 {
+		cfg.Retries = defaultSinkRetries
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = defaultSinkBlockTimeout
+	}
+	if len(cfg.Overflow) == 0 {
+		cfg.Overflow = OverflowDropNewest
+	}
+	cb, err := circularbuffer.New(cfg.BufferSize, circularbuffer.WithSafe[*Event](), circularbuffer.WithDrop[*Event]())
+	if err != nil {
+		return nil, err
+	}
+	return &sinkWorker{
+		cfg:     cfg,
+		name:    cfg.Sink.Name(),
+		cb:      cb,
+		flushCh: make(chan struct{}, 1),
+		spaceCh: make(chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}, nil
+}
+
+func (w *sinkWorker) enqueue(e *Event) {
+	if w.cfg.Filter != nil && !w.cfg.Filter(e) {
+		return
+	}
+	if !w.cb.Enqueue(e) {
+		switch w.cfg.Overflow {
+		case OverflowDropOldest:
+			w.cb.Dequeue()
+			w.dropped(1)
+			w.cb.Enqueue(e)
+		case OverflowBlock:
+			if !w.block(e) {
+				w.dropped(1)
+			}
+		default:
+			w.dropped(1)
+		}
+	}
+	w.observeQueue()
+	if w.cb.Len() >= w.cfg.BatchSize {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// block waits for free space until BlockTimeout, it reports whether the event was buffered.
+func (w *sinkWorker) block(e *Event) bool {
+	timer := time.NewTimer(w.cfg.BlockTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+		w.spaceMu.Lock()
+		space := w.spaceCh
+		w.spaceMu.Unlock()
+		select {
+		case <-space:
+			if w.cb.Enqueue(e) {
+				return true
+			}
+		case <-timer.C:
+			return w.cb.Enqueue(e)
+		case <-w.done:
+			return false
+		}
+	}
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushCh:
+		case <-w.done:
+			w.flush()
+			return
+		}
+		w.flush()
+	}
+}
+
+func (w *sinkWorker) flush() {
+	events := make([]*Event, 0, w.cfg.BatchSize)
+	for {
+		events = events[:0]
+		for len(events) < w.cfg.BatchSize {
+			e, ok := w.cb.Dequeue()
+			if !ok {
+				break
+			}
+			events = append(events, e)
+		}
+		if len(events) == 0 {
+			return
+		}
+		w.notifySpace()
+		w.observeQueue()
+		w.write(events)
+	}
+}
+
+// notifySpace wakes up all writers blocked by OverflowBlock.
+func (w *sinkWorker) notifySpace() {
+	w.spaceMu.Lock()
+	close(w.spaceCh)
+	w.spaceCh = make(chan struct{})
+	w.spaceMu.Unlock()
+}
+
+func (w *sinkWorker) write(events []*Event) {
+	var err error
+	for attempt := 0; attempt <= w.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			case <-w.done:
+			}
+		}
+		start := time.Now()
+		err = w.cfg.Sink.Write(context.Background(), events)
+		if prommetrics.AuditSinkWriteDuration != nil {
+			prommetrics.AuditSinkWriteDuration.WithLabelValues(w.name).Observe(time.Since(start).Seconds())
+		}
+		if err == nil {
+			w.count("delivered", len(events))
+			return
+		}
+	}
+	w.count("failed", len(events))
+	zap.S().Errorw("failed to write audit events to sink", "sink", w.name, "events", len(events), "error", err)
+}
+
+func (w *sinkWorker) close() {
+	w.once.Do(func() {
+		close(w.done)
+		<-w.stopped
+		if err := w.cfg.Sink.Close(); err != nil {
+			zap.S().Errorw("failed to close audit sink", "sink", w.name, "error", err)
+		}
+	})
+}
+
+func (w *sinkWorker) dropped(n int) { w.count("dropped", n) }
+
+func (w *sinkWorker) count(result string, n int) {
+	if prommetrics.AuditSinkEvents != nil {
+		prommetrics.AuditSinkEvents.WithLabelValues(w.name, result).Add(float64(n))
+	}
+}
+
+func (w *sinkWorker) observeQueue() {
+	if prommetrics.AuditSinkQueueSize != nil {
+		prommetrics.AuditSinkQueueSize.WithLabelValues(w.name).Set(float64(w.cb.Len()))
+	}
+}
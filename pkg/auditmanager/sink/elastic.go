@@ -0,0 +1,82 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/provider/elastic"
+	"github.com/forbearing/gst/types"
+)
+
+var _ auditmanager.Sink = (*Elastic)(nil)
+
+// ElasticConfig is the configuration of the elasticsearch sink.
+type ElasticConfig struct {
+	Index string // Index defaults to "gst-audit".
+	// DailyIndex appends the event date to the index, eg: "gst-audit-2006.01.02".
+	DailyIndex bool
+}
+
+// Elastic indexes the events into elasticsearch with provider/elastic,
+// the document id is the id of the operation log or login log.
+type Elastic struct {
+	cfg ElasticConfig
+}
+
+func NewElastic(cfg ElasticConfig) (*Elastic, error) {
+	if elastic.Client() == nil {
+		return nil, errors.New("elasticsearch is not enabled")
+	}
+	if len(cfg.Index) == 0 {
+		cfg.Index = "gst-audit"
+	}
+	return &Elastic{cfg: cfg}, nil
+}
+
+func (es *Elastic) Name() string { return "elastic" }
+
+func (es *Elastic) Write(ctx context.Context, events []*auditmanager.Event) error {
+	docs := make(map[string][]types.ESDocumenter)
+	for _, e := range events {
+		index := es.cfg.Index
+		if es.cfg.DailyIndex {
+			index += "-" + e.Time.Format("2006.01.02")
+		}
+		doc, err := newESDocument(e)
+		if err != nil {
+			return err
+		}
+		docs[index] = append(docs[index], doc)
+	}
+	for index, items := range docs {
+		if err := elastic.Document.BulkIndex(ctx, index, items...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (es *Elastic) Close() error { return nil }
+
+type esDocument struct {
+	id  string
+	doc map[string]any
+}
+
+func newESDocument(e *auditmanager.Event) (*esDocument, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal audit event")
+	}
+	doc := make(map[string]any)
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal audit event")
+	}
+	doc["@timestamp"] = e.Time
+	return &esDocument{id: e.ID(), doc: doc}, nil
+}
+
+func (d *esDocument) Document() map[string]any { return d.doc }
+func (d *esDocument) GetID() string            { return d.id }
@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+var _ auditmanager.Sink = (*File)(nil)
+
+// FileConfig is the configuration of the file sink.
+type FileConfig struct {
+	Filename   string // Filename is the file to write, eg: "/var/log/gst/audit.json".
+	MaxSize    int    // MaxSize is the max size in megabytes before the file is rotated, default is 100.
+	MaxBackups int    // MaxBackups is the max number of rotated files to keep, default is to keep all.
+	MaxAge     int    // MaxAge is the max days to keep rotated files, default is to keep all.
+	Compress   bool   // Compress compresses the rotated files with gzip.
+}
+
+// File writes the events as newline-delimited JSON with size based rotation.
+type File struct {
+	mu sync.Mutex
+	w  *lumberjack.Logger
+}
+
+func NewFile(cfg FileConfig) (*File, error) {
+	if len(cfg.Filename) == 0 {
+		return nil, errors.New("audit file name is empty")
+	}
+	return &File{w: &lumberjack.Logger{
+		Filename:   cfg.Filename,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}}, nil
+}
+
+func (f *File) Name() string { return "file" }
+
+func (f *File) Write(_ context.Context, events []*auditmanager.Event) error {
+	buf := make([]byte, 0, 512*len(events))
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal audit event")
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.w.Write(buf)
+	return err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.w.Close()
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/provider/kafka"
+)
+
+var _ auditmanager.Sink = (*Kafka)(nil)
+
+// KafkaConfig is the configuration of the kafka sink.
+type KafkaConfig struct {
+	Topic string // Topic defaults to "gst-audit".
+	// Client defaults to the client of provider/kafka, the sink does not close it.
+	Client sarama.Client
+}
+
+// Kafka sends every event as a JSON message keyed by the event id.
+type Kafka struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func NewKafka(cfg KafkaConfig) (*Kafka, error) {
+	if len(cfg.Topic) == 0 {
+		cfg.Topic = "gst-audit"
+	}
+	if cfg.Client == nil {
+		if cfg.Client = kafka.Client(); cfg.Client == nil {
+			return nil, errors.New("kafka is not enabled")
+		}
+	}
+	producer, err := kafka.NewSyncProducer(cfg.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kafka producer")
+	}
+	return &Kafka{topic: cfg.Topic, producer: producer}, nil
+}
+
+func (k *Kafka) Name() string { return "kafka" }
+
+func (k *Kafka) Write(_ context.Context, events []*auditmanager.Event) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal audit event")
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic:     k.topic,
+			Key:       sarama.StringEncoder(e.ID()),
+			Value:     sarama.ByteEncoder(data),
+			Timestamp: e.Time,
+		})
+	}
+	return k.producer.SendMessages(msgs)
+}
+
+func (k *Kafka) Close() error { return k.producer.Close() }
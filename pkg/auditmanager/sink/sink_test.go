@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/pkg/auditmanager"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func events() []*auditmanager.Event {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ol := &modellogmgmt.OperationLog{User: "alice", IP: "10.0.0.1", OP: consts.OP_UPDATE, Table: "users", RecordID: `a"b]`}
+	ol.ID = "op1"
+	ll := &modellogmgmt.LoginLog{Username: "bob", ClientIP: "10.0.0.2", Status: modellogmgmt.LoginStatusFailure}
+	ll.ID = "login1"
+	return []*auditmanager.Event{
+		{Type: auditmanager.EventOperation, Time: ts, Operation: ol},
+		{Type: auditmanager.EventLogin, Time: ts, Login: ll},
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// octet-counting framing: "LEN SP MSG"
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			var n int
+			if _, err = fmt.Sscan(strings.TrimSpace(size), &n); err != nil {
+				return
+			}
+			buf := make([]byte, n)
+			if _, err = io.ReadFull(r, buf); err != nil {
+				return
+			}
+			lines <- string(buf)
+		}
+	}()
+
+	s, err := NewSyslog(SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), Hostname: "host", AppName: "app"})
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Write(context.Background(), events()))
+
+	op := <-lines
+	require.Regexp(t, regexp.MustCompile(`^<110>1 2026-01-02T03:04:05Z host app \d+ operation \[audit@32473 id="op1" user="alice" ip="10.0.0.1" op="update" table="users" record_id="a\\"b\\]"\] \{`), op)
+	login := <-lines
+	require.Regexp(t, regexp.MustCompile(`^<108>1 2026-01-02T03:04:05Z host app \d+ login \[audit@32473 id="login1" user="bob" ip="10.0.0.2" status="failure"\] \{`), login)
+}
+
+func TestFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.json")
+	f, err := NewFile(FileConfig{Filename: filename})
+	require.NoError(t, err)
+	require.NoError(t, f.Write(context.Background(), events()))
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var e auditmanager.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	require.Equal(t, auditmanager.EventLogin, e.Type)
+	require.Equal(t, "bob", e.Login.Username)
+}
@@ -0,0 +1,201 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/pkg/auditmanager"
+)
+
+const (
+	// facilityLogAudit is the RFC 5424 "log audit" facility.
+	facilityLogAudit = 13
+
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+var _ auditmanager.Sink = (*Syslog)(nil)
+
+// SyslogConfig is the configuration of the syslog sink.
+type SyslogConfig struct {
+	Network   string      // Network is "udp", "tcp" or "tls", default is "udp".
+	Addr      string      // Addr is the syslog server address, eg: "127.0.0.1:514".
+	TLSConfig *tls.Config // TLSConfig is used by the "tls" network.
+	Timeout   time.Duration
+
+	Facility int    // Facility defaults to 13 (log audit).
+	Hostname string // Hostname defaults to os.Hostname.
+	AppName  string // AppName defaults to "gst".
+	// SDID is the structured data id of the event fields, default is "audit@32473".
+	// Replace 32473 with your private enterprise number.
+	SDID string
+}
+
+// Syslog sends the events as RFC 5424 messages, one message per event.
+// The message body is the JSON encoded event, the main fields are also sent as structured data.
+// TCP and TLS use octet-counting framing (RFC 6587).
+type Syslog struct {
+	cfg  SyslogConfig
+	pid  string
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog creates the syslog sink, the connection is established on first write.
+func NewSyslog(cfg SyslogConfig) (*Syslog, error) {
+	if len(cfg.Addr) == 0 {
+		return nil, errors.New("syslog address is empty")
+	}
+	switch cfg.Network {
+	case "":
+		cfg.Network = "udp"
+	case "udp", "tcp", "tls":
+	default:
+		return nil, errors.Newf("unsupported syslog network %q", cfg.Network)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Facility <= 0 {
+		cfg.Facility = facilityLogAudit
+	}
+	if len(cfg.Hostname) == 0 {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if len(cfg.AppName) == 0 {
+		cfg.AppName = "gst"
+	}
+	if len(cfg.SDID) == 0 {
+		cfg.SDID = "audit@32473"
+	}
+	return &Syslog{cfg: cfg, pid: strconv.Itoa(os.Getpid())}, nil
+}
+
+func (s *Syslog) Name() string { return "syslog" }
+
+func (s *Syslog) Write(ctx context.Context, events []*auditmanager.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range events {
+		msg, err := s.format(e)
+		if err != nil {
+			return err
+		}
+		if s.cfg.Network != "udp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if err = s.send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send writes the message, reconnecting once if the connection is broken.
+func (s *Syslog) send(ctx context.Context, msg []byte) error {
+	var err error
+	for range 2 {
+		if s.conn == nil {
+			if s.conn, err = s.dial(ctx); err != nil {
+				return errors.Wrap(err, "failed to connect to syslog server")
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return errors.Wrap(err, "failed to write syslog message")
+}
+
+func (s *Syslog) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	if s.cfg.Network == "tls" {
+		td := &tls.Dialer{NetDialer: dialer, Config: s.cfg.TLSConfig}
+		return td.DialContext(ctx, "tcp", s.cfg.Addr)
+	}
+	return dialer.DialContext(ctx, s.cfg.Network, s.cfg.Addr)
+}
+
+// format formats the event as RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID k="v"...] MSG
+func (s *Syslog) format(e *auditmanager.Event) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal audit event")
+	}
+
+	severity := severityInfo
+	params := [][2]string{{"id", e.ID()}, {"user", e.User()}}
+	switch {
+	case e.Operation != nil:
+		ol := e.Operation
+		params = append(params, [2]string{"ip", ol.IP}, [2]string{"op", string(ol.OP)}, [2]string{"table", ol.Table},
+			[2]string{"record_id", ol.RecordID}, [2]string{"request_id", ol.RequestID}, [2]string{"real_user", ol.RealUser})
+	case e.Login != nil:
+		ll := e.Login
+		params = append(params, [2]string{"ip", ll.ClientIP}, [2]string{"status", string(ll.Status)})
+		switch ll.Status {
+		case modellogmgmt.LoginStatusFailure, modellogmgmt.LoginStatusLocked, modellogmgmt.LoginStatusImpersonate:
+			severity = severityWarning
+		}
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + s.cfg.SDID)
+	for _, p := range params {
+		if len(p[1]) > 0 {
+			fmt.Fprintf(&sd, " %s=\"%s\"", p[0], escapeSDValue(p[1]))
+		}
+	}
+	sd.WriteString("]")
+
+	return fmt.Appendf(nil, "<%d>1 %s %s %s %s %s %s %s",
+		s.cfg.Facility*8+severity,
+		e.Time.UTC().Format(time.RFC3339Nano),
+		headerField(s.cfg.Hostname),
+		headerField(s.cfg.AppName),
+		s.pid,
+		headerField(string(e.Type)),
+		sd.String(),
+		body,
+	), nil
+}
+
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// escapeSDValue escapes '"', '\' and ']' in structured data values.
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// headerField returns the NILVALUE "-" for empty header fields and removes spaces.
+func headerField(v string) string {
+	if len(v) == 0 {
+		return "-"
+	}
+	return strings.ReplaceAll(v, " ", "_")
+}
@@ -0,0 +1,111 @@
+package auditmanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types/consts"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []*Event
+	fail   int
+}
+
+func (s *memorySink) Name() string { return "memory" }
+
+func (s *memorySink) Write(_ context.Context, events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("unavailable")
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func opEvent(table string, op consts.OP) *Event {
+	return &Event{Type: EventOperation, Time: time.Now(), Operation: &modellogmgmt.OperationLog{Table: table, OP: op}}
+}
+
+func TestSinkWorker(t *testing.T) {
+	s := &memorySink{fail: 1}
+	w, err := newSinkWorker(SinkConfig{
+		Sink:          s,
+		Filter:        FilterAll(FilterTables("users"), FilterOperations(consts.OP_CREATE)),
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	go w.run()
+
+	w.enqueue(opEvent("users", consts.OP_CREATE))
+	w.enqueue(opEvent("groups", consts.OP_CREATE))
+	w.enqueue(opEvent("users", consts.OP_DELETE))
+	w.enqueue(&Event{Type: EventLogin, Login: &modellogmgmt.LoginLog{Username: "root"}})
+
+	// The batch size is reached, the first write fails and is retried.
+	require.Eventually(t, func() bool { return s.len() == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, "users", s.events[0].Operation.Table)
+	require.Equal(t, "root", s.events[1].User())
+
+	w.enqueue(opEvent("users", consts.OP_CREATE))
+	w.close()
+	require.Equal(t, 3, s.len())
+}
+
+func TestSinkWorkerOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		tables []string
+	}{
+		{OverflowDropNewest, []string{"a", "b"}},
+		{OverflowDropOldest, []string{"b", "c"}},
+		{OverflowBlock, []string{"a", "b", "c"}},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			s := &memorySink{}
+			w, err := newSinkWorker(SinkConfig{
+				Sink:          s,
+				BufferSize:    2,
+				BatchSize:     10,
+				FlushInterval: time.Hour,
+				Overflow:      tc.policy,
+				BlockTimeout:  time.Second,
+			})
+			require.NoError(t, err)
+			if tc.policy == OverflowBlock {
+				go w.run()
+			}
+
+			w.enqueue(opEvent("a", consts.OP_CREATE))
+			w.enqueue(opEvent("b", consts.OP_CREATE))
+			w.enqueue(opEvent("c", consts.OP_CREATE))
+			if tc.policy != OverflowBlock {
+				go w.run()
+			}
+			w.close()
+
+			tables := make([]string, 0, len(s.events))
+			for _, e := range s.events {
+				tables = append(tables, e.Operation.Table)
+			}
+			require.Equal(t, tc.tables, tables)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package auditmanager
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/database"
+	modellogmgmt "github.com/forbearing/gst/internal/model/logmgmt"
+	"github.com/forbearing/gst/types"
+)
+
+const verifyPageSize = 1000
+
+// Verify walks the chain in seq order and reports the first broken link:
+// a missing or reordered record, a record not linked to its predecessor, a record whose
+// content does not match its hash, or a checkpoint with an invalid signature, an unknown
+// hash or beyond the chain head.
+// Checkpoint signatures are not verified if pub is nil.
+func Verify(chainKey string, pub ed25519.PublicKey) (*modellogmgmt.ChainResult, error) {
+	cps := make([]*modellogmgmt.OperationLogCheckpoint, 0)
+	if err := database.Database[*modellogmgmt.OperationLogCheckpoint](nil).
+		WithQuery(&modellogmgmt.OperationLogCheckpoint{ChainKey: chainKey}).
+		WithOrder("seq asc").
+		List(&cps); err != nil {
+		return nil, errors.Wrapf(err, "failed to list checkpoints of operation log chain %q", chainKey)
+	}
+
+	v := newVerifier(chainKey, cps, pub)
+	var last uint64
+	for !v.done() {
+		logs := make([]*modellogmgmt.OperationLog, 0, verifyPageSize)
+		if err := database.Database[*modellogmgmt.OperationLog](nil).
+			WithQuery(&modellogmgmt.OperationLog{ChainKey: chainKey}, types.QueryConfig{RawQuery: "seq > ?", RawQueryArgs: []any{last}}).
+			WithOrder("seq asc").
+			WithLimit(verifyPageSize).
+			List(&logs); err != nil {
+			return nil, errors.Wrapf(err, "failed to list operation log chain %q", chainKey)
+		}
+		for _, ol := range logs {
+			if v.add(ol); v.done() {
+				break
+			}
+		}
+		if len(logs) < verifyPageSize {
+			break
+		}
+		last = logs[len(logs)-1].Seq
+	}
+	return v.result(), nil
+}
+
+// VerifyAll verifies all chains.
+func VerifyAll(pub ed25519.PublicKey) ([]*modellogmgmt.ChainResult, error) {
+	keys, err := ChainKeys()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*modellogmgmt.ChainResult, 0, len(keys))
+	for _, key := range keys {
+		res, err := Verify(key, pub)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// verifier checks the records of one chain fed in seq order.
+type verifier struct {
+	res    *modellogmgmt.ChainResult
+	pub    ed25519.PublicKey
+	cps    []*modellogmgmt.OperationLogCheckpoint
+	hashes map[uint64]string // hashes of the records pinned by checkpoints
+	broken *modellogmgmt.BrokenLink
+}
+
+func newVerifier(chainKey string, cps []*modellogmgmt.OperationLogCheckpoint, pub ed25519.PublicKey) *verifier {
+	v := &verifier{
+		res:    &modellogmgmt.ChainResult{ChainKey: chainKey, Checkpoints: len(cps)},
+		pub:    pub,
+		cps:    cps,
+		hashes: make(map[uint64]string, len(cps)),
+	}
+	for _, cp := range cps {
+		v.hashes[cp.Seq] = ""
+	}
+	return v
+}
+
+func (v *verifier) done() bool { return v.broken != nil }
+
+func (v *verifier) add(ol *modellogmgmt.OperationLog) {
+	expected := v.res.HeadSeq + 1
+	switch {
+	case ol.Seq != expected:
+		v.fail(expected, "", fmt.Sprintf("record with seq %s is missing, got seq %s", formatSeq(expected), formatSeq(ol.Seq)))
+		return
+	case ol.PrevHash != v.res.HeadHash:
+		v.fail(ol.Seq, ol.ID, "prev_hash does not match the hash of the previous record")
+		return
+	}
+	hash, err := Hash(ol)
+	if err != nil {
+		v.fail(ol.Seq, ol.ID, err.Error())
+		return
+	}
+	if hash != ol.Hash {
+		v.fail(ol.Seq, ol.ID, "record content does not match its hash")
+		return
+	}
+
+	v.res.Records++
+	v.res.HeadSeq = ol.Seq
+	v.res.HeadHash = ol.Hash
+	if _, ok := v.hashes[ol.Seq]; ok {
+		v.hashes[ol.Seq] = ol.Hash
+	}
+}
+
+func (v *verifier) fail(seq uint64, id, reason string) {
+	if v.broken == nil || seq < v.broken.Seq {
+		v.broken = &modellogmgmt.BrokenLink{Seq: seq, RecordID: id, Reason: reason}
+	}
+}
+
+// result checks the checkpoints against the verified records.
+func (v *verifier) result() *modellogmgmt.ChainResult {
+	for _, cp := range v.cps {
+		if v.pub != nil && !VerifyCheckpoint(cp, v.pub) {
+			v.fail(cp.Seq, "", "checkpoint signature is invalid")
+			break
+		}
+		if v.broken != nil && cp.Seq >= v.broken.Seq {
+			break
+		}
+		if cp.Seq > v.res.HeadSeq {
+			v.fail(v.res.HeadSeq+1, "", fmt.Sprintf("records after seq %s are missing, checkpoint is at seq %s", formatSeq(v.res.HeadSeq), formatSeq(cp.Seq)))
+			break
+		}
+		if v.hashes[cp.Seq] != cp.Hash {
+			v.fail(cp.Seq, "", "checkpoint hash does not match the record hash")
+			break
+		}
+	}
+	v.res.Broken = v.broken
+	v.res.Valid = v.broken == nil
+	return v.res
+}
@@ -0,0 +1,150 @@
+// Package geoip resolves the location and network of ip addresses from local
+// MaxMind-format databases, eg: GeoLite2-City.mmdb, GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb.
+// No network access is required, the databases are memory mapped.
+package geoip
+
+import (
+	"math"
+	"net/netip"
+
+	"github.com/cockroachdb/errors"
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const earthRadius = 6371.0 // km
+
+// Location is the location and network of an ip address,
+// the fields are empty if the databases have no record of the address.
+type Location struct {
+	Country     string  `json:"country,omitempty"`      // Country is the ISO 3166-1 alpha-2 country code, eg: US
+	CountryName string  `json:"country_name,omitempty"` // CountryName is the English country name
+	City        string  `json:"city,omitempty"`         // City is the English city name, only available in city databases
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+	ASN         uint    `json:"asn,omitempty"`    // ASN is the autonomous system number
+	ASOrg       string  `json:"as_org,omitempty"` // ASOrg is the autonomous system organization
+}
+
+// HasCoordinates reports whether the location has the latitude and longitude.
+func (l *Location) HasCoordinates() bool {
+	return l != nil && (l.Latitude != 0 || l.Longitude != 0)
+}
+
+// Reader looks up ip addresses in a city or country database and an ASN database.
+type Reader struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader
+}
+
+type cityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Open opens the city or country database and the ASN database, either path may be empty.
+func Open(cityPath, asnPath string) (*Reader, error) {
+	r := new(Reader)
+	var err error
+	if len(cityPath) > 0 {
+		if r.city, err = maxminddb.Open(cityPath); err != nil {
+			return nil, errors.Wrapf(err, "geoip: failed to open %s", cityPath)
+		}
+	}
+	if len(asnPath) > 0 {
+		if r.asn, err = maxminddb.Open(asnPath); err != nil {
+			_ = r.Close()
+			return nil, errors.Wrapf(err, "geoip: failed to open %s", asnPath)
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the location and network of the ip address.
+// Private, loopback and other non-public addresses have an empty location.
+func (r *Reader) Lookup(ip string) (*Location, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, errors.Wrapf(err, "geoip: invalid ip %q", ip)
+	}
+	addr = addr.Unmap()
+	loc := new(Location)
+	if r == nil || !Public(addr) {
+		return loc, nil
+	}
+
+	if r.city != nil {
+		var rec cityRecord
+		if err = r.city.Lookup(addr).Decode(&rec); err != nil {
+			return nil, errors.Wrapf(err, "geoip: failed to lookup %s", ip)
+		}
+		loc.Country = rec.Country.ISOCode
+		loc.CountryName = rec.Country.Names["en"]
+		loc.City = rec.City.Names["en"]
+		loc.Latitude = rec.Location.Latitude
+		loc.Longitude = rec.Location.Longitude
+	}
+	if r.asn != nil {
+		var rec asnRecord
+		if err = r.asn.Lookup(addr).Decode(&rec); err != nil {
+			return nil, errors.Wrapf(err, "geoip: failed to lookup asn of %s", ip)
+		}
+		loc.ASN = rec.Number
+		loc.ASOrg = rec.Organization
+	}
+	return loc, nil
+}
+
+// Close closes the databases.
+func (r *Reader) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	if r.city != nil {
+		errs = append(errs, r.city.Close())
+	}
+	if r.asn != nil {
+		errs = append(errs, r.asn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Public reports whether the address is routable on the internet and can be located.
+func Public(addr netip.Addr) bool {
+	return addr.IsValid() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsMulticast()
+}
+
+// Distance returns the great-circle distance in kilometers between two locations,
+// it is 0 if either location has no coordinates.
+func Distance(a, b *Location) float64 {
+	if !a.HasCoordinates() || !b.HasCoordinates() {
+		return 0
+	}
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dlat := lat2 - lat1
+	dlon := radians(b.Longitude - a.Longitude)
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
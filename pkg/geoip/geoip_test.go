@@ -0,0 +1,57 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/forbearing/gst/pkg/geoip"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistance(t *testing.T) {
+	london := &geoip.Location{Latitude: 51.5074, Longitude: -0.1278}
+	newYork := &geoip.Location{Latitude: 40.7128, Longitude: -74.0060}
+
+	require.InDelta(t, 5570, geoip.Distance(london, newYork), 10)
+	require.InDelta(t, geoip.Distance(london, newYork), geoip.Distance(newYork, london), 1e-9)
+	require.Zero(t, geoip.Distance(london, london))
+	require.Zero(t, geoip.Distance(london, &geoip.Location{Country: "US"}))
+	require.Zero(t, geoip.Distance(nil, london))
+}
+
+func TestPublic(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"8.8.8.8":     true,
+		"2001:4860::": true,
+		"10.0.0.1":    false,
+		"192.168.1.1": false,
+		"127.0.0.1":   false,
+		"::1":         false,
+		"fe80::1":     false,
+		"0.0.0.0":     false,
+		"fc00::1":     false,
+	} {
+		require.Equal(t, public, geoip.Public(netip.MustParseAddr(ip)), ip)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	// Without databases every address has an empty location.
+	r, err := geoip.Open("", "")
+	require.NoError(t, err)
+	defer r.Close()
+
+	loc, err := r.Lookup("8.8.8.8")
+	require.NoError(t, err)
+	require.Equal(t, &geoip.Location{}, loc)
+
+	loc, err = r.Lookup("::ffff:10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, &geoip.Location{}, loc)
+
+	_, err = r.Lookup("invalid")
+	require.Error(t, err)
+
+	_, err = geoip.Open("not-exists.mmdb", "")
+	require.Error(t, err)
+}
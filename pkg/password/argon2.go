@@ -0,0 +1,103 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2id hashes passwords with argon2id and encodes them in the PHC string format.
+type Argon2id struct {
+	Time    uint32 // Time is the number of passes over the memory
+	Memory  uint32 // Memory is the memory size in KiB
+	Threads uint8  // Threads is the degree of parallelism
+	KeyLen  uint32 // KeyLen is the length of the derived key in bytes
+	SaltLen uint32 // SaltLen is the length of the random salt in bytes
+}
+
+var _ Hasher = (*Argon2id)(nil)
+
+// NewArgon2id creates an argon2id hasher with the parameters recommended by RFC 9106
+// for memory constrained environments: t=3, m=64MiB, p=4.
+func NewArgon2id() *Argon2id {
+	return &Argon2id{
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Wrap(err, "password: argon2id salt")
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key))) //nolint:gosec
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a *Argon2id) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Time != a.Time ||
+		params.Memory != a.Memory ||
+		params.Threads != a.Threads ||
+		uint32(len(key)) != a.KeyLen || //nolint:gosec
+		uint32(len(salt)) != a.SaltLen //nolint:gosec
+}
+
+// decodeArgon2id parses "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>".
+func decodeArgon2id(encoded string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("password: invalid argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, errors.Wrap(err, "password: invalid argon2id version")
+	}
+	if version != argon2.Version {
+		return params, nil, nil, errors.Newf("password: unsupported argon2id version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, errors.Wrap(err, "password: invalid argon2id parameters")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errors.Wrap(err, "password: invalid argon2id salt")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, errors.Wrap(err, "password: invalid argon2id key")
+	}
+	if len(key) == 0 || params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errors.New("password: invalid argon2id hash")
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,55 @@
+package password
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxBytes is the max password length of bcrypt in bytes.
+const bcryptMaxBytes = 72
+
+// Bcrypt hashes passwords with bcrypt.
+// bcrypt only uses the first 72 bytes of a password, longer passwords are rejected.
+type Bcrypt struct {
+	Cost int
+}
+
+var _ Hasher = (*Bcrypt)(nil)
+
+// NewBcrypt creates a bcrypt hasher, cost out of range falls back to bcrypt.DefaultCost.
+func NewBcrypt(cost int) *Bcrypt {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &Bcrypt{Cost: cost}
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", errors.Wrap(err, "password: bcrypt")
+	}
+	return string(hash), nil
+}
+
+func (b *Bcrypt) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "password: bcrypt")
+	}
+	return true, nil
+}
+
+func (b *Bcrypt) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
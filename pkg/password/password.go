@@ -0,0 +1,107 @@
+// Package password provides pluggable password hashers and password policy validation.
+//
+// Hashes are self-describing: bcrypt hashes use the "$2a$" family of prefixes and
+// argon2id hashes use the PHC string format ("$argon2id$v=19$m=...,t=...,p=...$salt$key").
+// A Manager hashes new passwords with its preferred Hasher and verifies hashes produced by
+// any registered Hasher, reporting when a stored hash should be upgraded.
+package password
+
+import (
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownHash is returned when no registered hasher recognizes an encoded hash.
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// Hasher hashes and verifies passwords with one algorithm.
+type Hasher interface {
+	// Hash returns the encoded hash of password.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded, password string) (bool, error)
+	// Identify reports whether encoded was produced by this hasher's algorithm.
+	Identify(encoded string) bool
+	// NeedsRehash reports whether encoded was produced with parameters different from the current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashes passwords with a preferred hasher and verifies hashes of any registered hasher.
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+// NewManager creates a Manager hashing with preferred, legacy hashers are only used for verification.
+func NewManager(preferred Hasher, legacy ...Hasher) *Manager {
+	if preferred == nil {
+		preferred = NewBcrypt(bcrypt.DefaultCost)
+	}
+	hashers := make([]Hasher, 0, len(legacy)+1)
+	hashers = append(hashers, preferred)
+	for _, h := range legacy {
+		if h != nil {
+			hashers = append(hashers, h)
+		}
+	}
+	return &Manager{preferred: preferred, hashers: hashers}
+}
+
+// Hash hashes password with the preferred hasher.
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+// MaxBytes returns the max length in bytes of the passwords the preferred hasher accepts, 0 means unlimited.
+func (m *Manager) MaxBytes() int {
+	if _, ok := m.preferred.(*Bcrypt); ok {
+		return bcryptMaxBytes
+	}
+	return 0
+}
+
+// Verify reports whether password matches encoded.
+// rehash is true when the password matched but encoded was produced by a legacy hasher
+// or with outdated parameters, callers should then store a fresh hash from Hash.
+func (m *Manager) Verify(encoded, password string) (ok, rehash bool, err error) {
+	for _, h := range m.hashers {
+		if !h.Identify(encoded) {
+			continue
+		}
+		if ok, err = h.Verify(encoded, password); err != nil || !ok {
+			return false, false, err
+		}
+		if h != m.preferred {
+			return true, true, nil
+		}
+		return true, h.NeedsRehash(encoded), nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+var defaultManager atomic.Pointer[Manager]
+
+func init() {
+	defaultManager.Store(NewManager(NewBcrypt(bcrypt.DefaultCost), NewArgon2id()))
+}
+
+// SetDefault replaces the Manager used by the package level Hash and Verify.
+func SetDefault(m *Manager) {
+	if m != nil {
+		defaultManager.Store(m)
+	}
+}
+
+// Default returns the Manager used by the package level Hash and Verify.
+// It hashes with bcrypt and also verifies argon2id hashes unless replaced by SetDefault.
+func Default() *Manager { return defaultManager.Load() }
+
+// Hash hashes password with the default Manager.
+func Hash(password string) (string, error) { return Default().Hash(password) }
+
+// Verify verifies password against encoded with the default Manager.
+func Verify(encoded, password string) (ok, rehash bool, err error) {
+	return Default().Verify(encoded, password)
+}
@@ -0,0 +1,110 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/forbearing/gst/pkg/password"
+	"github.com/stretchr/testify/require"
+)
+
+func fastArgon2id() *password.Argon2id {
+	return &password.Argon2id{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+}
+
+func TestHashers(t *testing.T) {
+	for name, h := range map[string]password.Hasher{
+		"bcrypt":   password.NewBcrypt(4),
+		"argon2id": fastArgon2id(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			hash, err := h.Hash("s3cret-Password")
+			require.NoError(t, err)
+			require.True(t, h.Identify(hash))
+			require.False(t, h.NeedsRehash(hash))
+
+			ok, err := h.Verify(hash, "s3cret-Password")
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			ok, err = h.Verify(hash, "wrong-password")
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestManagerRehash(t *testing.T) {
+	bcrypt := password.NewBcrypt(4)
+	argon := fastArgon2id()
+
+	legacy, err := bcrypt.Hash("s3cret-Password")
+	require.NoError(t, err)
+
+	m := password.NewManager(argon, bcrypt)
+	ok, rehash, err := m.Verify(legacy, "s3cret-Password")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, rehash, "hash of a legacy hasher must be upgraded")
+
+	ok, rehash, err = m.Verify(legacy, "wrong-password")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.False(t, rehash)
+
+	current, err := m.Hash("s3cret-Password")
+	require.NoError(t, err)
+	ok, rehash, err = m.Verify(current, "s3cret-Password")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.False(t, rehash)
+
+	stronger := fastArgon2id()
+	stronger.Time = 2
+	ok, rehash, err = password.NewManager(stronger).Verify(current, "s3cret-Password")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, rehash, "hash with outdated parameters must be upgraded")
+
+	_, _, err = m.Verify("plaintext", "plaintext")
+	require.ErrorIs(t, err, password.ErrUnknownHash)
+}
+
+func TestPolicy(t *testing.T) {
+	require.NoError(t, password.Policy{}.Validate("abcdef", "alice"))
+	require.Error(t, password.Policy{}.Validate("abc", "alice"))
+
+	p := password.Policy{
+		MinLength:        10,
+		RequireUpper:     true,
+		RequireDigit:     true,
+		MinCharClasses:   3,
+		DisallowUsername: true,
+		RejectCommon:     true,
+		Blocklist:        password.NewBlocklist("Correct-Horse-1"),
+	}
+	require.NoError(t, p.Validate("Tr0ub4dour&3", "alice"))
+
+	err := p.Validate("alice", "alice")
+	var perr *password.PolicyError
+	require.ErrorAs(t, err, &perr)
+	require.Len(t, perr.Violations, 5)
+
+	require.Error(t, p.Validate("Password123", "bob"), "common passwords are rejected case-insensitively")
+	require.Error(t, p.Validate("correct-horse-1", "bob"))
+	require.Error(t, p.Validate("Xalice-2024-Y", "alice"))
+}
+
+func TestPolicyBcryptBytes(t *testing.T) {
+	// 30 characters but 90 bytes, bcrypt would reject it.
+	multiByte := strings.Repeat("密", 30)
+	err := password.Policy{}.Validate(multiByte, "alice")
+	var perr *password.PolicyError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, []string{"must be at most 72 bytes long"}, perr.Violations)
+
+	// argon2id has no byte limit.
+	defer password.SetDefault(password.Default())
+	password.SetDefault(password.NewManager(fastArgon2id()))
+	require.NoError(t, password.Policy{}.Validate(multiByte, "alice"))
+}
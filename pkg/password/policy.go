@@ -0,0 +1,173 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cockroachdb/errors"
+)
+
+//go:embed common.txt
+var commonPasswords string
+
+// commonList is the built-in list of the most common passwords.
+var commonList = func() Blocklist {
+	b, _ := readBlocklist(strings.NewReader(commonPasswords))
+	return b
+}()
+
+// Policy describes the rules a new password must satisfy.
+// The zero value only requires passwords to be between 6 and 72 characters long.
+type Policy struct {
+	MinLength      int  // MinLength is the minimum number of characters, default is 6
+	MaxLength      int  // MaxLength is the maximum number of characters, default is 72 (the bcrypt limit), bcrypt also limits the bytes to 72
+	RequireUpper   bool // RequireUpper requires at least one upper case letter
+	RequireLower   bool // RequireLower requires at least one lower case letter
+	RequireDigit   bool // RequireDigit requires at least one digit
+	RequireSymbol  bool // RequireSymbol requires at least one character that is not a letter or digit
+	MinCharClasses int  // MinCharClasses is the minimum number of distinct character classes (upper, lower, digit, symbol)
+
+	// DisallowUsername rejects passwords containing the username.
+	DisallowUsername bool
+	// RejectCommon rejects passwords found in the built-in common password list and Blocklist.
+	RejectCommon bool
+	// Blocklist are additional rejected passwords, use LoadBlocklist to read a local breached password list.
+	Blocklist Blocklist
+}
+
+// PolicyError lists every rule a password violates.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate checks password against the policy, username is used by DisallowUsername.
+// It returns a *PolicyError if one or more rules are violated.
+func (p Policy) Validate(password, username string) error {
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = 6
+	}
+	maxLength := p.MaxLength
+	if maxLength <= 0 {
+		maxLength = 72
+	}
+
+	violations := make([]string, 0)
+	length := len([]rune(password))
+	if length < minLength {
+		violations = append(violations, "must be at least "+strconv.Itoa(minLength)+" characters long")
+	}
+	if length > maxLength {
+		violations = append(violations, "must be at most "+strconv.Itoa(maxLength)+" characters long")
+	} else if maxBytes := Default().MaxBytes(); maxBytes > 0 && len(password) > maxBytes {
+		// multi-byte characters count more than once against the bcrypt limit.
+		violations = append(violations, "must be at most "+strconv.Itoa(maxBytes)+" bytes long")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an upper case letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lower case letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+	if p.MinCharClasses > 0 {
+		classes := 0
+		for _, ok := range []bool{upper, lower, digit, symbol} {
+			if ok {
+				classes++
+			}
+		}
+		if classes < p.MinCharClasses {
+			violations = append(violations, "must contain at least "+strconv.Itoa(p.MinCharClasses)+" of upper case letters, lower case letters, digits and symbols")
+		}
+	}
+
+	if p.DisallowUsername && len(username) > 0 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if p.RejectCommon && p.isCommon(password) {
+		violations = append(violations, "is too common")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func (p Policy) isCommon(password string) bool {
+	return commonList.Contains(password) || p.Blocklist.Contains(password)
+}
+
+// Blocklist is a set of rejected passwords, compared case-insensitively.
+type Blocklist map[string]struct{}
+
+// NewBlocklist creates a Blocklist from passwords.
+func NewBlocklist(passwords ...string) Blocklist {
+	b := make(Blocklist, len(passwords))
+	for _, s := range passwords {
+		b[strings.ToLower(s)] = struct{}{}
+	}
+	return b
+}
+
+// Contains reports whether password is in the blocklist.
+func (b Blocklist) Contains(password string) bool {
+	_, ok := b[strings.ToLower(password)]
+	return ok
+}
+
+// LoadBlocklist reads a password list with one password per line,
+// blank lines and lines starting with "#" are ignored.
+func LoadBlocklist(path string) (Blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "password: open blocklist")
+	}
+	defer f.Close()
+	return readBlocklist(f)
+}
+
+func readBlocklist(r io.Reader) (Blocklist, error) {
+	b := make(Blocklist)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "password: read blocklist")
+	}
+	return b, nil
+}
@@ -0,0 +1,91 @@
+package scim
+
+import "strings"
+
+// alwaysReturned are the attributes returned regardless of the attributes parameters.
+var alwaysReturned = []string{"schemas", "id"}
+
+// Project applies the "attributes" and "excludedAttributes" query parameters to the JSON decoded resource.
+// The parameters are comma separated attribute paths, eg: "userName,name.givenName".
+// When attributes is set, excludedAttributes is ignored. "schemas" and "id" are always returned.
+func Project(resource map[string]any, attributes, excludedAttributes string) map[string]any {
+	if include := splitAttributes(attributes); len(include) > 0 {
+		res := make(map[string]any, len(include)+len(alwaysReturned))
+		for _, name := range alwaysReturned {
+			if v := lookup(resource, name); v != nil {
+				res[name] = v
+			}
+		}
+		for _, path := range include {
+			attr, sub, _ := strings.Cut(path, ".")
+			attr, v := lookupKey(resource, attr)
+			if v == nil {
+				continue
+			}
+			if len(sub) == 0 {
+				set(res, attr, v)
+				continue
+			}
+			switch v := v.(type) {
+			case map[string]any:
+				if sub, sv := lookupKey(v, sub); sv != nil {
+					parent, _ := lookup(res, attr).(map[string]any)
+					if parent == nil {
+						parent = make(map[string]any)
+						set(res, attr, parent)
+					}
+					set(parent, sub, sv)
+				}
+			case []any:
+				// The sub attribute of multi-valued attributes is projected on every item.
+				list := make([]any, 0, len(v))
+				for _, item := range items(v) {
+					if sub, sv := lookupKey(item, sub); sv != nil {
+						list = append(list, map[string]any{sub: sv})
+					}
+				}
+				set(res, attr, list)
+			}
+		}
+		return res
+	}
+
+	for _, path := range splitAttributes(excludedAttributes) {
+		attr, sub, _ := strings.Cut(path, ".")
+		if isAlwaysReturned(attr) {
+			continue
+		}
+		if len(sub) == 0 {
+			del(resource, attr)
+			continue
+		}
+		switch v := lookup(resource, attr).(type) {
+		case map[string]any:
+			del(v, sub)
+		case []any:
+			for _, item := range items(v) {
+				del(item, sub)
+			}
+		}
+	}
+	return resource
+}
+
+func splitAttributes(s string) []string {
+	var res []string
+	for _, path := range strings.Split(s, ",") {
+		if path = normalizePath(strings.TrimSpace(path)); len(path) > 0 {
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
+func isAlwaysReturned(attr string) bool {
+	for _, name := range alwaysReturned {
+		if strings.EqualFold(name, attr) {
+			return true
+		}
+	}
+	return false
+}
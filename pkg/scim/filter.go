@@ -0,0 +1,357 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Operator is the attribute operator of filters.
+type Operator string
+
+const (
+	OpEqual          Operator = "eq"
+	OpNotEqual       Operator = "ne"
+	OpContains       Operator = "co"
+	OpStartsWith     Operator = "sw"
+	OpEndsWith       Operator = "ew"
+	OpGreaterThan    Operator = "gt"
+	OpGreaterOrEqual Operator = "ge"
+	OpLessThan       Operator = "lt"
+	OpLessOrEqual    Operator = "le"
+	OpPresent        Operator = "pr"
+)
+
+var operators = map[string]Operator{
+	"eq": OpEqual, "ne": OpNotEqual, "co": OpContains, "sw": OpStartsWith, "ew": OpEndsWith,
+	"gt": OpGreaterThan, "ge": OpGreaterOrEqual, "lt": OpLessThan, "le": OpLessOrEqual, "pr": OpPresent,
+}
+
+// Expr is a parsed filter expression, one of *AttrExpr, *LogicalExpr, *NotExpr and *ValuePathExpr.
+type Expr interface {
+	String() string
+}
+
+// AttrExpr compares an attribute with a value, eg: userName eq "bjensen".
+// Path is the attribute path without the schema URN, eg: "name.givenName".
+// Value is a string, float64, bool or nil, it is unset for the "pr" operator.
+type AttrExpr struct {
+	Path  string
+	Op    Operator
+	Value any
+}
+
+// LogicalExpr joins two expressions with "and" or "or".
+type LogicalExpr struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// NotExpr negates an expression.
+type NotExpr struct {
+	Expr Expr
+}
+
+// ValuePathExpr filters the items of a multi-valued attribute, eg: emails[type eq "work"].
+// The attribute paths of Filter are relative to Attr.
+type ValuePathExpr struct {
+	Attr   string
+	Filter Expr
+}
+
+func (e *AttrExpr) String() string {
+	if e.Op == OpPresent {
+		return e.Path + " pr"
+	}
+	value, _ := json.Marshal(e.Value)
+	return fmt.Sprintf("%s %s %s", e.Path, e.Op, value)
+}
+
+func (e *LogicalExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+func (e *NotExpr) String() string { return fmt.Sprintf("not (%s)", e.Expr) }
+
+func (e *ValuePathExpr) String() string { return fmt.Sprintf("%s[%s]", e.Attr, e.Filter) }
+
+// Path is a PATCH operation path: attr[filter].sub, Filter and Sub are optional.
+type Path struct {
+	Attr   string
+	Filter Expr
+	Sub    string
+}
+
+// ParseFilter parses the filter of queries, the errors are *Error with scimType "invalidFilter".
+func ParseFilter(filter string) (Expr, error) {
+	p, err := newParser(filter, ErrInvalidFilter)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+	return expr, nil
+}
+
+// ParsePath parses the path of PATCH operations, the errors are *Error with scimType "invalidPath".
+func ParsePath(path string) (*Path, error) {
+	p, err := newParser(path, ErrInvalidPath)
+	if err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if tok.kind != tokWord {
+		return nil, p.errorf("attribute path is required")
+	}
+	attr, sub, _ := strings.Cut(normalizePath(tok.text), ".")
+	res := &Path{Attr: attr, Sub: sub}
+	if p.peek().kind == tokLBracket {
+		if len(sub) > 0 {
+			return nil, p.errorf("filter must follow the attribute %q", attr)
+		}
+		p.next()
+		if res.Filter, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRBracket {
+			return nil, p.errorf("missing \"]\"")
+		}
+		// The sub attribute after the filter is lexed as a word starting with ".".
+		if tok = p.peek(); tok.kind == tokWord && strings.HasPrefix(tok.text, ".") {
+			p.next()
+			res.Sub = strings.TrimPrefix(tok.text, ".")
+		}
+	}
+	if tok = p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+	if len(res.Attr) == 0 || strings.Contains(res.Sub, ".") {
+		return nil, p.errorf("invalid path %q", path)
+	}
+	return res, nil
+}
+
+// normalizePath removes the schema URN prefix of the attribute path,
+// eg: "urn:ietf:params:scim:schemas:core:2.0:User:name.givenName" is "name.givenName".
+func normalizePath(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	typ    ErrorType
+}
+
+func newParser(input string, typ ErrorType) (*parser, error) {
+	p := &parser{typ: typ}
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{kind: tokLParen, text: "("})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{kind: tokRParen, text: ")"})
+			i++
+		case c == '[':
+			p.tokens = append(p.tokens, token{kind: tokLBracket, text: "["})
+			i++
+		case c == ']':
+			p.tokens = append(p.tokens, token{kind: tokRBracket, text: "]"})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(input) && input[j] != '"'; j++ {
+				if input[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(input) {
+				return nil, p.errorf("unterminated string")
+			}
+			var s string
+			if err := json.Unmarshal([]byte(input[i:j+1]), &s); err != nil {
+				return nil, p.errorf("invalid string %s", input[i:j+1])
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: s})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(input) && !strings.ContainsRune(" \t\n\r()[]\"", rune(input[j])); j++ {
+			}
+			p.tokens = append(p.tokens, token{kind: tokWord, text: input[i:j]})
+			i = j
+		}
+	}
+	return p, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == tokWord && strings.EqualFold(tok.text, kw)
+}
+
+func (p *parser) errorf(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, p.typ, fmt.Sprintf(format, args...))
+}
+
+// parseOr parses: and ("or" and)*, "and" has higher precedence than "or".
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.keyword("not") {
+		p.next()
+		if p.peek().kind != tokLParen {
+			return nil, p.errorf("\"not\" must be followed by \"(\"")
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr}, nil
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, p.errorf("missing \")\"")
+		}
+		return expr, nil
+	case tokWord:
+	case tokEOF:
+		return nil, p.errorf("unexpected end of filter")
+	default:
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+
+	path := normalizePath(tok.text)
+	if p.peek().kind == tokLBracket {
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRBracket {
+			return nil, p.errorf("missing \"]\"")
+		}
+		return &ValuePathExpr{Attr: path, Filter: filter}, nil
+	}
+
+	opTok := p.next()
+	op, ok := operators[strings.ToLower(opTok.text)]
+	if opTok.kind != tokWord || !ok {
+		return nil, p.errorf("invalid operator %q after %q", opTok.text, tok.text)
+	}
+	if op == OpPresent {
+		return &AttrExpr{Path: path, Op: op}, nil
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &AttrExpr{Path: path, Op: op, Value: value}, nil
+}
+
+func (p *parser) parseValue() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return tok.text, nil
+	case tokWord:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, p.errorf("invalid value %q", tok.text)
+}
@@ -0,0 +1,187 @@
+package scim_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`userName eq "bjensen"`, `userName eq "bjensen"`},
+		{`userName Eq "bjensen"`, `userName eq "bjensen"`},
+		{`name.familyName co "O'Malley"`, `name.familyName co "O'Malley"`},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`, `userName sw "J"`},
+		{`title pr`, `title pr`},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`, `meta.lastModified gt "2011-05-13T04:42:34Z"`},
+		{`active eq true`, `active eq true`},
+		{`count ge 10`, `count ge 10`},
+		{`manager eq null`, `manager eq null`},
+		{`title pr and userType eq "Employee"`, `(title pr and userType eq "Employee")`},
+		{`title pr or userType eq "Intern"`, `(title pr or userType eq "Intern")`},
+		{
+			`a eq "1" or b eq "2" and c eq "3"`,
+			`(a eq "1" or (b eq "2" and c eq "3"))`,
+		},
+		{
+			`userType eq "Employee" and (emails co "example.com" or emails.value co "example.org")`,
+			`(userType eq "Employee" and (emails co "example.com" or emails.value co "example.org"))`,
+		},
+		{
+			`userType ne "Employee" and not (emails co "example.com" or emails.value co "example.org")`,
+			`(userType ne "Employee" and not ((emails co "example.com" or emails.value co "example.org")))`,
+		},
+		{
+			`emails[type eq "work" and value co "@example.com"]`,
+			`emails[(type eq "work" and value co "@example.com")]`,
+		},
+		{`displayName eq "a \"quoted\" name"`, `displayName eq "a \"quoted\" name"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := scim.ParseFilter(tt.filter)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, expr.String())
+		})
+	}
+}
+
+func TestParseFilterError(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName xx "a"`,
+		`userName eq "unterminated`,
+		`userName eq bjensen`,
+		`(userName eq "a"`,
+		`emails[type eq "work"`,
+		`not userName eq "a"`,
+		`userName eq "a" and`,
+		`userName eq "a" "b"`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := scim.ParseFilter(filter)
+			require.Error(t, err)
+			var serr *scim.Error
+			require.True(t, errors.As(err, &serr))
+			require.Equal(t, scim.ErrInvalidFilter, serr.ScimType)
+			require.Equal(t, 400, serr.Code())
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		attr   string
+		filter string
+		sub    string
+	}{
+		{`userName`, "userName", "", ""},
+		{`name.givenName`, "name", "", "givenName"},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:name.familyName`, "name", "", "familyName"},
+		{`members[value eq "2819c223"]`, "members", `value eq "2819c223"`, ""},
+		{`emails[type eq "work"].value`, "emails", `type eq "work"`, "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := scim.ParsePath(tt.path)
+			require.NoError(t, err)
+			require.Equal(t, tt.attr, p.Attr)
+			require.Equal(t, tt.sub, p.Sub)
+			if len(tt.filter) == 0 {
+				require.Nil(t, p.Filter)
+			} else {
+				require.Equal(t, tt.filter, p.Filter.String())
+			}
+		})
+	}
+
+	for _, path := range []string{``, `name.givenName[value eq "a"]`, `emails[type eq "work"].value.x`, `a b`} {
+		_, err := scim.ParsePath(path)
+		var serr *scim.Error
+		require.True(t, errors.As(err, &serr), path)
+		require.Equal(t, scim.ErrInvalidPath, serr.ScimType, path)
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	cols := scim.Columns{
+		"userName":          {Name: "username"},
+		"externalId":        {Name: "external_id", CaseExact: true},
+		"emails":            {Name: "email"},
+		"emails.value":      {Name: "email"},
+		"active":            {Name: "active", Type: scim.TypeBoolean},
+		"loginCount":        {Name: "login_count", Type: scim.TypeNumber},
+		"meta.lastModified": {Name: "updated_at", Type: scim.TypeDateTime},
+		"groups": {Cond: func(op scim.Operator, value any) (string, []any, error) {
+			return "group_id = ?", []any{value}, nil
+		}},
+	}
+	tests := []struct {
+		filter string
+		cond   string
+		args   []any
+	}{
+		{`userName eq "BJensen"`, "LOWER(username) = ?", []any{"bjensen"}},
+		{`UserName Eq "BJensen"`, "LOWER(username) = ?", []any{"bjensen"}},
+		{`externalId eq "AbC"`, "external_id = ?", []any{"AbC"}},
+		{`userName ne "a"`, "(username IS NULL OR LOWER(username) <> ?)", []any{"a"}},
+		{`userName co "50%_!"`, "LOWER(username) LIKE ? ESCAPE '!'", []any{"%50!%!_!!%"}},
+		{`userName sw "j"`, "LOWER(username) LIKE ? ESCAPE '!'", []any{"j%"}},
+		{`userName ew "n"`, "LOWER(username) LIKE ? ESCAPE '!'", []any{"%n"}},
+		{`userName pr`, "(username IS NOT NULL AND username <> '')", nil},
+		{`active pr`, "active IS NOT NULL", nil},
+		{`active eq false`, "active = ?", []any{false}},
+		{`loginCount ge 3`, "login_count >= ?", []any{float64(3)}},
+		{`externalId eq null`, "external_id IS NULL", nil},
+		{`emails[value eq "A@example.com"]`, "LOWER(email) = ?", []any{"a@example.com"}},
+		{`emails.value eq "A@example.com"`, "LOWER(email) = ?", []any{"a@example.com"}},
+		{`groups eq "g1"`, "group_id = ?", []any{"g1"}},
+		{
+			`userName eq "a" or not (emails co "x" and active eq true)`,
+			"(LOWER(username) = ? OR NOT ((LOWER(email) LIKE ? ESCAPE '!' AND active = ?)))",
+			[]any{"a", "%x%", true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := scim.ParseFilter(tt.filter)
+			require.NoError(t, err)
+			cond, args, err := scim.ToSQL(expr, cols)
+			require.NoError(t, err)
+			require.Equal(t, tt.cond, cond)
+			require.Equal(t, tt.args, args)
+		})
+	}
+
+	expr, err := scim.ParseFilter(`meta.lastModified gt "2011-05-13T04:42:34Z"`)
+	require.NoError(t, err)
+	cond, args, err := scim.ToSQL(expr, cols)
+	require.NoError(t, err)
+	require.Equal(t, "updated_at > ?", cond)
+	require.Len(t, args, 1)
+
+	for _, filter := range []string{
+		`title eq "a"`,
+		`active eq "yes"`,
+		`active gt true`,
+		`loginCount eq "3"`,
+		`meta.lastModified gt "yesterday"`,
+		`userName gt 3`,
+		`userName co null`,
+	} {
+		expr, err := scim.ParseFilter(filter)
+		require.NoError(t, err, filter)
+		_, _, err = scim.ToSQL(expr, cols)
+		var serr *scim.Error
+		require.True(t, errors.As(err, &serr), filter)
+		require.Equal(t, scim.ErrInvalidFilter, serr.ScimType, filter)
+	}
+}
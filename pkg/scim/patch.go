@@ -0,0 +1,372 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Match reports whether the object, a JSON decoded resource or multi-valued item, matches the filter.
+// Strings are compared case insensitively.
+func Match(expr Expr, obj map[string]any) bool {
+	switch e := expr.(type) {
+	case *LogicalExpr:
+		if e.Op == "and" {
+			return Match(e.Left, obj) && Match(e.Right, obj)
+		}
+		return Match(e.Left, obj) || Match(e.Right, obj)
+	case *NotExpr:
+		return !Match(e.Expr, obj)
+	case *ValuePathExpr:
+		for _, item := range items(lookup(obj, e.Attr)) {
+			if Match(e.Filter, item) {
+				return true
+			}
+		}
+		return false
+	case *AttrExpr:
+		attr, sub, _ := strings.Cut(e.Path, ".")
+		v := lookup(obj, attr)
+		if len(sub) > 0 {
+			if m, ok := v.(map[string]any); ok {
+				v = lookup(m, sub)
+			} else {
+				// A sub attribute of a multi-valued attribute matches any item.
+				for _, item := range items(v) {
+					if matchValue(lookup(item, sub), e.Op, e.Value) {
+						return true
+					}
+				}
+				return false
+			}
+		}
+		return matchValue(v, e.Op, e.Value)
+	}
+	return false
+}
+
+func matchValue(v any, op Operator, value any) bool {
+	if op == OpPresent {
+		return v != nil && v != ""
+	}
+	switch a := v.(type) {
+	case string:
+		b, ok := value.(string)
+		if !ok {
+			return false
+		}
+		a, b = strings.ToLower(a), strings.ToLower(b)
+		switch op {
+		case OpEqual:
+			return a == b
+		case OpNotEqual:
+			return a != b
+		case OpContains:
+			return strings.Contains(a, b)
+		case OpStartsWith:
+			return strings.HasPrefix(a, b)
+		case OpEndsWith:
+			return strings.HasSuffix(a, b)
+		case OpGreaterThan:
+			return a > b
+		case OpGreaterOrEqual:
+			return a >= b
+		case OpLessThan:
+			return a < b
+		case OpLessOrEqual:
+			return a <= b
+		}
+	case float64:
+		b, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case OpEqual:
+			return a == b
+		case OpNotEqual:
+			return a != b
+		case OpGreaterThan:
+			return a > b
+		case OpGreaterOrEqual:
+			return a >= b
+		case OpLessThan:
+			return a < b
+		case OpLessOrEqual:
+			return a <= b
+		}
+	case nil:
+		return (op == OpEqual && value == nil) || (op == OpNotEqual && value != nil)
+	default:
+		switch op {
+		case OpEqual:
+			return v == value
+		case OpNotEqual:
+			return v != value
+		}
+	}
+	return false
+}
+
+// ApplyPatch applies the PATCH operations to the JSON decoded resource.
+// Attribute names are case insensitive. Besides RFC 7644 it accepts the variations
+// sent by common identity providers: capitalized operation names, dotted attribute
+// paths in the value of operations without path, and "remove" of multi-valued items
+// listed in the value instead of a filter.
+func ApplyPatch(resource map[string]any, ops []PatchOperation) error {
+	for _, op := range ops {
+		if err := applyOperation(resource, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(resource map[string]any, op PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	switch kind {
+	case "add", "replace", "remove":
+	default:
+		return NewError(http.StatusBadRequest, ErrInvalidSyntax, fmt.Sprintf("invalid operation %q", op.Op))
+	}
+
+	if len(op.Path) == 0 {
+		if kind == "remove" {
+			return NewError(http.StatusBadRequest, ErrNoTarget, "path is required for remove")
+		}
+		values, ok := op.Value.(map[string]any)
+		if !ok {
+			return NewError(http.StatusBadRequest, ErrInvalidValue, "value must be an object when path is empty")
+		}
+		for k, v := range values {
+			if _, ok := v.(map[string]any); ok && strings.HasPrefix(strings.ToLower(k), "urn:") {
+				// Schema extensions are not supported, their attributes are ignored.
+				continue
+			}
+			if err := applyOperation(resource, PatchOperation{Op: kind, Path: k, Value: v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if kind != "remove" && op.Value == nil {
+		return NewError(http.StatusBadRequest, ErrInvalidValue, fmt.Sprintf("value is required for %s", kind))
+	}
+
+	if path.Filter == nil {
+		target := resource
+		attr := path.Attr
+		if len(path.Sub) > 0 {
+			parent, ok := lookup(resource, path.Attr).(map[string]any)
+			if !ok {
+				if kind == "remove" {
+					return nil
+				}
+				parent = make(map[string]any)
+				set(resource, path.Attr, parent)
+			}
+			target, attr = parent, path.Sub
+		}
+		return applyValue(target, attr, kind, op.Value)
+	}
+
+	// attr[filter] or attr[filter].sub
+	list := items(lookup(resource, path.Attr))
+	matched := false
+	result := make([]any, 0, len(list))
+	for _, item := range list {
+		if !Match(path.Filter, item) {
+			result = append(result, item)
+			continue
+		}
+		matched = true
+		switch {
+		case kind == "remove" && len(path.Sub) == 0:
+			continue
+		case len(path.Sub) > 0:
+			if err = applyValue(item, path.Sub, kind, op.Value); err != nil {
+				return err
+			}
+		default:
+			values, ok := op.Value.(map[string]any)
+			if !ok {
+				return NewError(http.StatusBadRequest, ErrInvalidValue, "value must be an object")
+			}
+			for k, v := range values {
+				set(item, k, v)
+			}
+		}
+		result = append(result, item)
+	}
+	if !matched {
+		if kind == "remove" {
+			return nil
+		}
+		// emails[type eq "work"].value creates the item when it does not exist.
+		item, ok := newItem(path.Filter)
+		if !ok {
+			return NewError(http.StatusBadRequest, ErrNoTarget, fmt.Sprintf("no item matches %q", op.Path))
+		}
+		if len(path.Sub) > 0 {
+			set(item, path.Sub, op.Value)
+		} else if values, ok := op.Value.(map[string]any); ok {
+			for k, v := range values {
+				set(item, k, v)
+			}
+		}
+		result = append(result, item)
+	}
+	set(resource, path.Attr, result)
+	return nil
+}
+
+// applyValue applies the operation to the attribute of the object.
+func applyValue(obj map[string]any, attr, kind string, value any) error {
+	existing := lookup(obj, attr)
+	switch kind {
+	case "remove":
+		// Remove the multi-valued items listed in value, eg: {"op":"remove","path":"members","value":[{"value":"id"}]}.
+		if list, ok := existing.([]any); ok && value != nil {
+			set(obj, attr, toAnySlice(removeItems(items(list), items(value))))
+			return nil
+		}
+		del(obj, attr)
+	case "add":
+		if list, ok := existing.([]any); ok {
+			set(obj, attr, toAnySlice(mergeItems(items(list), items(value))))
+			return nil
+		}
+		fallthrough
+	case "replace":
+		if m, ok := existing.(map[string]any); ok {
+			if values, ok := value.(map[string]any); ok {
+				for k, v := range values {
+					set(m, k, v)
+				}
+				return nil
+			}
+		}
+		set(obj, attr, value)
+	}
+	return nil
+}
+
+// newItem creates the multi-valued item matching a filter made of "eq" comparisons joined by "and".
+func newItem(expr Expr) (map[string]any, bool) {
+	item := make(map[string]any)
+	var build func(Expr) bool
+	build = func(e Expr) bool {
+		switch e := e.(type) {
+		case *AttrExpr:
+			if e.Op != OpEqual || strings.Contains(e.Path, ".") {
+				return false
+			}
+			item[e.Path] = e.Value
+			return true
+		case *LogicalExpr:
+			return e.Op == "and" && build(e.Left) && build(e.Right)
+		}
+		return false
+	}
+	return item, build(expr)
+}
+
+// mergeItems appends the items not in list, items are identified by "value" if present.
+func mergeItems(list, add []map[string]any) []map[string]any {
+	for _, item := range add {
+		if v := lookup(item, "value"); v != nil && containsValue(list, v) {
+			continue
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+func removeItems(list, remove []map[string]any) []map[string]any {
+	result := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if !containsValue(remove, lookup(item, "value")) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func containsValue(list []map[string]any, v any) bool {
+	for _, item := range list {
+		if matchValue(lookup(item, "value"), OpEqual, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// items returns the objects of a multi-valued attribute, a single object is a list of one item.
+func items(v any) []map[string]any {
+	switch v := v.(type) {
+	case []any:
+		res := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				res = append(res, m)
+			}
+		}
+		return res
+	case []map[string]any:
+		return v
+	case map[string]any:
+		return []map[string]any{v}
+	}
+	return nil
+}
+
+func toAnySlice(list []map[string]any) []any {
+	res := make([]any, len(list))
+	for i := range list {
+		res[i] = list[i]
+	}
+	return res
+}
+
+// lookup returns the attribute of the object, the name is case insensitive.
+func lookup(obj map[string]any, name string) any {
+	_, v := lookupKey(obj, name)
+	return v
+}
+
+// lookupKey returns the key spelled as in the object and the attribute.
+func lookupKey(obj map[string]any, name string) (string, any) {
+	if v, ok := obj[name]; ok {
+		return name, v
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, name) {
+			return k, v
+		}
+	}
+	return name, nil
+}
+
+// set sets the attribute of the object, keeping the spelling of the existing key.
+func set(obj map[string]any, name string, value any) {
+	for k := range obj {
+		if strings.EqualFold(k, name) {
+			obj[k] = value
+			return
+		}
+	}
+	obj[name] = value
+}
+
+func del(obj map[string]any, name string) {
+	for k := range obj {
+		if strings.EqualFold(k, name) {
+			delete(obj, k)
+		}
+	}
+}
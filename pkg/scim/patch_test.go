@@ -0,0 +1,194 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/forbearing/gst/pkg/scim"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	m := make(map[string]any)
+	require.NoError(t, json.Unmarshal([]byte(s), &m))
+	return m
+}
+
+func operations(t *testing.T, s string) []scim.PatchOperation {
+	t.Helper()
+	req := new(scim.PatchRequest)
+	require.NoError(t, json.Unmarshal([]byte(s), req))
+	return req.Operations
+}
+
+func TestMatch(t *testing.T) {
+	user := decode(t, `{
+		"userName": "bjensen",
+		"name": {"givenName": "Barbara"},
+		"active": true,
+		"emails": [{"value": "bjensen@example.com", "type": "work"}, {"value": "babs@jensen.org", "type": "home"}]
+	}`)
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "BJensen"`, true},
+		{`userName ne "bjensen"`, false},
+		{`name.givenName sw "bar"`, true},
+		{`active eq true`, true},
+		{`emails.value ew "jensen.org"`, true},
+		{`emails[type eq "work" and value co "example"]`, true},
+		{`emails[type eq "other"]`, false},
+		{`title pr`, false},
+		{`not (title pr)`, true},
+		{`userName eq "x" or active eq true`, true},
+	}
+	for _, tt := range tests {
+		expr, err := scim.ParseFilter(tt.filter)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, scim.Match(expr, user), tt.filter)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		ops      string
+		want     string
+	}{
+		{
+			name:     "replace_attribute",
+			resource: `{"userName":"a","active":true}`,
+			ops:      `{"Operations":[{"op":"replace","path":"active","value":false}]}`,
+			want:     `{"userName":"a","active":false}`,
+		},
+		{
+			name:     "capitalized_op_and_no_path",
+			resource: `{"userName":"a","name":{"givenName":"A","familyName":"B"}}`,
+			ops:      `{"Operations":[{"op":"Replace","value":{"userName":"b","name.givenName":"C","displayName":"C B"}}]}`,
+			want:     `{"userName":"b","name":{"givenName":"C","familyName":"B"},"displayName":"C B"}`,
+		},
+		{
+			name:     "replace_complex_merges",
+			resource: `{"name":{"givenName":"A","familyName":"B"}}`,
+			ops:      `{"Operations":[{"op":"replace","path":"name","value":{"givenName":"C"}}]}`,
+			want:     `{"name":{"givenName":"C","familyName":"B"}}`,
+		},
+		{
+			name:     "replace_sub_attribute_case_insensitive",
+			resource: `{"name":{"givenName":"A"}}`,
+			ops:      `{"Operations":[{"op":"replace","path":"NAME.GIVENNAME","value":"C"}]}`,
+			want:     `{"name":{"givenName":"C"}}`,
+		},
+		{
+			name:     "replace_filtered_sub_attribute",
+			resource: `{"emails":[{"value":"a@x.com","type":"work"},{"value":"a@y.com","type":"home"}]}`,
+			ops:      `{"Operations":[{"op":"replace","path":"emails[type eq \"work\"].value","value":"b@x.com"}]}`,
+			want:     `{"emails":[{"value":"b@x.com","type":"work"},{"value":"a@y.com","type":"home"}]}`,
+		},
+		{
+			name:     "replace_filtered_creates_missing_item",
+			resource: `{"userName":"a"}`,
+			ops:      `{"Operations":[{"op":"replace","path":"emails[type eq \"work\"].value","value":"a@x.com"}]}`,
+			want:     `{"userName":"a","emails":[{"value":"a@x.com","type":"work"}]}`,
+		},
+		{
+			name:     "add_members_dedup",
+			resource: `{"members":[{"value":"u1"}]}`,
+			ops:      `{"Operations":[{"op":"add","path":"members","value":[{"value":"u1"},{"value":"u2"}]}]}`,
+			want:     `{"members":[{"value":"u1"},{"value":"u2"}]}`,
+		},
+		{
+			name:     "add_to_missing_attribute",
+			resource: `{}`,
+			ops:      `{"Operations":[{"op":"add","path":"members","value":[{"value":"u1"}]}]}`,
+			want:     `{"members":[{"value":"u1"}]}`,
+		},
+		{
+			name:     "remove_member_by_filter",
+			resource: `{"members":[{"value":"u1"},{"value":"u2"}]}`,
+			ops:      `{"Operations":[{"op":"remove","path":"members[value eq \"u1\"]"}]}`,
+			want:     `{"members":[{"value":"u2"}]}`,
+		},
+		{
+			name:     "remove_member_by_value",
+			resource: `{"members":[{"value":"u1"},{"value":"u2"}]}`,
+			ops:      `{"Operations":[{"op":"Remove","path":"members","value":[{"value":"u2"}]}]}`,
+			want:     `{"members":[{"value":"u1"}]}`,
+		},
+		{
+			name:     "remove_all_members",
+			resource: `{"displayName":"g","members":[{"value":"u1"}]}`,
+			ops:      `{"Operations":[{"op":"remove","path":"members"}]}`,
+			want:     `{"displayName":"g"}`,
+		},
+		{
+			name:     "remove_missing_is_noop",
+			resource: `{"members":[{"value":"u1"}]}`,
+			ops:      `{"Operations":[{"op":"remove","path":"members[value eq \"u9\"]"},{"op":"remove","path":"name.givenName"}]}`,
+			want:     `{"members":[{"value":"u1"}]}`,
+		},
+		{
+			name:     "urn_prefixed_path",
+			resource: `{"displayName":"a"}`,
+			ops:      `{"Operations":[{"op":"replace","path":"urn:ietf:params:scim:schemas:core:2.0:User:displayName","value":"b"}]}`,
+			want:     `{"displayName":"b"}`,
+		},
+		{
+			name:     "extension_ignored",
+			resource: `{"displayName":"a"}`,
+			ops:      `{"Operations":[{"op":"add","value":{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User":{"department":"x"}}}]}`,
+			want:     `{"displayName":"a"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := decode(t, tt.resource)
+			require.NoError(t, scim.ApplyPatch(resource, operations(t, tt.ops)))
+			require.Equal(t, decode(t, tt.want), resource)
+		})
+	}
+}
+
+func TestApplyPatchError(t *testing.T) {
+	tests := []struct {
+		ops string
+		typ scim.ErrorType
+	}{
+		{`{"Operations":[{"op":"move","path":"a","value":1}]}`, scim.ErrInvalidSyntax},
+		{`{"Operations":[{"op":"remove"}]}`, scim.ErrNoTarget},
+		{`{"Operations":[{"op":"replace","value":"a"}]}`, scim.ErrInvalidValue},
+		{`{"Operations":[{"op":"replace","path":"a"}]}`, scim.ErrInvalidValue},
+		{`{"Operations":[{"op":"replace","path":"a[b eq"}]}`, scim.ErrInvalidPath},
+		{`{"Operations":[{"op":"replace","path":"members[value co \"u\"].display","value":"x"}]}`, scim.ErrNoTarget},
+	}
+	for _, tt := range tests {
+		err := scim.ApplyPatch(decode(t, `{"userName":"a"}`), operations(t, tt.ops))
+		var serr *scim.Error
+		require.True(t, errors.As(err, &serr), tt.ops)
+		require.Equal(t, tt.typ, serr.ScimType, tt.ops)
+	}
+}
+
+func TestProject(t *testing.T) {
+	user := `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"id": "1",
+		"userName": "a",
+		"name": {"givenName": "A", "familyName": "B"},
+		"emails": [{"value": "a@x.com", "type": "work"}],
+		"meta": {"resourceType": "User"}
+	}`
+	require.Equal(t,
+		decode(t, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"1","userName":"a","name":{"familyName":"B"},"emails":[{"value":"a@x.com"}]}`),
+		scim.Project(decode(t, user), "username, name.familyName,emails.value", "userName"),
+	)
+	require.Equal(t,
+		decode(t, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"1","userName":"a","name":{"givenName":"A"},"emails":[{"value":"a@x.com"}]}`),
+		scim.Project(decode(t, user), "", "meta,name.familyName,emails.type,id"),
+	)
+	require.Equal(t, decode(t, user), scim.Project(decode(t, user), "", ""))
+}
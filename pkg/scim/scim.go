@@ -0,0 +1,157 @@
+// Package scim implements the protocol parts of SCIM 2.0 (RFC 7643, RFC 7644):
+// resource and message types, the filter syntax and PATCH operations.
+// It is storage agnostic, see module/scim for the endpoints backed by the IAM models.
+package scim
+
+import (
+	"strconv"
+	"time"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Schema URNs.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Meta is the resource metadata.
+type Meta struct {
+	ResourceType string     `json:"resourceType,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+// Name is the name of a user.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue is an item of a multi-valued attribute, eg: emails, groups and members.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User is the core user resource.
+type User struct {
+	Schemas      []string     `json:"schemas"`
+	ID           string       `json:"id,omitempty"`
+	ExternalID   string       `json:"externalId,omitempty"`
+	UserName     string       `json:"userName"`
+	Name         *Name        `json:"name,omitempty"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	Active       *bool        `json:"active,omitempty"`
+	Password     string       `json:"password,omitempty"` // Password is write-only.
+	Emails       []MultiValue `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []MultiValue `json:"groups,omitempty"` // Groups is read-only, it is changed through the members of groups.
+	Meta         *Meta        `json:"meta,omitempty"`
+}
+
+// Group is the core group resource.
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// ListResponse is the response of queries.
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// NewListResponse creates the list response of resources.
+func NewListResponse(total int64, startIndex int, resources []any) *ListResponse {
+	if resources == nil {
+		resources = make([]any, 0)
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// PatchRequest is the request of PATCH.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is an operation of PATCH, Op is "add", "remove" or "replace" in any case.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// ErrorType is the scimType of errors.
+type ErrorType string
+
+const (
+	ErrInvalidFilter ErrorType = "invalidFilter"
+	ErrTooMany       ErrorType = "tooMany"
+	ErrUniqueness    ErrorType = "uniqueness"
+	ErrMutability    ErrorType = "mutability"
+	ErrInvalidSyntax ErrorType = "invalidSyntax"
+	ErrInvalidPath   ErrorType = "invalidPath"
+	ErrNoTarget      ErrorType = "noTarget"
+	ErrInvalidValue  ErrorType = "invalidValue"
+	ErrInvalidVers   ErrorType = "invalidVers"
+)
+
+// Error is the SCIM error response, it implements error.
+type Error struct {
+	Schemas  []string  `json:"schemas"`
+	Status   string    `json:"status"` // Status is the http status code as a string, eg: "404".
+	ScimType ErrorType `json:"scimType,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+
+	code int
+}
+
+// NewError creates an error with the http status code.
+func NewError(code int, typ ErrorType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(code),
+		ScimType: typ,
+		Detail:   detail,
+		code:     code,
+	}
+}
+
+func (e *Error) Error() string {
+	if len(e.ScimType) > 0 {
+		return string(e.ScimType) + ": " + e.Detail
+	}
+	return e.Detail
+}
+
+// Code returns the http status code of the error.
+func (e *Error) Code() int { return e.code }
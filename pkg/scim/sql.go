@@ -0,0 +1,175 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AttrType is the type of an attribute mapped to a column.
+type AttrType int
+
+const (
+	TypeString AttrType = iota
+	TypeBoolean
+	TypeDateTime
+	TypeNumber
+)
+
+// Column maps an attribute to a database column.
+type Column struct {
+	Name      string   // Name is the column name.
+	Type      AttrType // Type defaults to TypeString.
+	CaseExact bool     // CaseExact compares strings case sensitively, default is case insensitive.
+
+	// Cond builds the condition of attributes not stored in a single column, Name and Type are ignored.
+	// The value is a string, float64, bool or nil, it is unset for the "pr" operator.
+	Cond func(op Operator, value any) (string, []any, error)
+}
+
+// Columns maps the attribute paths to columns, the keys are case insensitive,
+// eg: "userName", "name.givenName" and "emails.value". A multi-valued attribute
+// stored in a single column may map both "emails" and "emails.value".
+type Columns map[string]Column
+
+// Lookup returns the column of the attribute path, the path is case insensitive.
+func (cols Columns) Lookup(path string) (Column, bool) {
+	for k, col := range cols {
+		if strings.EqualFold(k, path) {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// ToSQL translates the filter to a SQL condition and its arguments.
+// Attributes missing in cols are rejected with scimType "invalidFilter".
+func ToSQL(expr Expr, cols Columns) (string, []any, error) {
+	return toSQL(expr, cols, "")
+}
+
+func toSQL(expr Expr, cols Columns, prefix string) (string, []any, error) {
+	switch e := expr.(type) {
+	case *LogicalExpr:
+		left, largs, err := toSQL(e.Left, cols, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rargs, err := toSQL(e.Right, cols, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(e.Op), right), append(largs, rargs...), nil
+	case *NotExpr:
+		cond, args, err := toSQL(e.Expr, cols, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", cond), args, nil
+	case *ValuePathExpr:
+		if len(prefix) > 0 {
+			return "", nil, NewError(http.StatusBadRequest, ErrInvalidFilter, "nested value path is not allowed")
+		}
+		return toSQL(e.Filter, cols, e.Attr+".")
+	case *AttrExpr:
+		path := prefix + e.Path
+		col, ok := cols.Lookup(path)
+		if !ok && len(prefix) > 0 && strings.EqualFold(e.Path, "value") {
+			// emails[value eq "x"] is the same as emails eq "x".
+			col, ok = cols.Lookup(strings.TrimSuffix(prefix, "."))
+		}
+		if !ok {
+			return "", nil, NewError(http.StatusBadRequest, ErrInvalidFilter, fmt.Sprintf("attribute %q is not filterable", path))
+		}
+		if col.Cond != nil {
+			return col.Cond(e.Op, e.Value)
+		}
+		return compare(col, e.Op, e.Value)
+	}
+	return "", nil, NewError(http.StatusBadRequest, ErrInvalidFilter, "invalid filter")
+}
+
+func compare(col Column, op Operator, value any) (string, []any, error) {
+	if op == OpPresent {
+		if col.Type == TypeString {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col.Name, col.Name), nil, nil
+		}
+		return fmt.Sprintf("%s IS NOT NULL", col.Name), nil, nil
+	}
+	invalid := func() (string, []any, error) {
+		return "", nil, NewError(http.StatusBadRequest, ErrInvalidFilter, fmt.Sprintf("invalid value %v for operator %q", value, op))
+	}
+	if value == nil {
+		switch op {
+		case OpEqual:
+			return fmt.Sprintf("%s IS NULL", col.Name), nil, nil
+		case OpNotEqual:
+			return fmt.Sprintf("%s IS NOT NULL", col.Name), nil, nil
+		}
+		return invalid()
+	}
+
+	name := col.Name
+	arg := value
+	switch col.Type {
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok || (op != OpEqual && op != OpNotEqual) {
+			return invalid()
+		}
+	case TypeNumber:
+		if _, ok := value.(float64); !ok {
+			return invalid()
+		}
+	case TypeDateTime:
+		s, ok := value.(string)
+		if !ok {
+			return invalid()
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return invalid()
+		}
+		arg = t
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return invalid()
+		}
+		if !col.CaseExact {
+			name = "LOWER(" + name + ")"
+			s = strings.ToLower(s)
+		}
+		arg = s
+		switch op {
+		case OpContains:
+			return name + " LIKE ? ESCAPE '!'", []any{"%" + escapeLike(s) + "%"}, nil
+		case OpStartsWith:
+			return name + " LIKE ? ESCAPE '!'", []any{escapeLike(s) + "%"}, nil
+		case OpEndsWith:
+			return name + " LIKE ? ESCAPE '!'", []any{"%" + escapeLike(s)}, nil
+		}
+	}
+
+	switch op {
+	case OpEqual:
+		return name + " = ?", []any{arg}, nil
+	case OpNotEqual:
+		return fmt.Sprintf("(%s IS NULL OR %s <> ?)", col.Name, name), []any{arg}, nil
+	case OpGreaterThan:
+		return name + " > ?", []any{arg}, nil
+	case OpGreaterOrEqual:
+		return name + " >= ?", []any{arg}, nil
+	case OpLessThan:
+		return name + " < ?", []any{arg}, nil
+	case OpLessOrEqual:
+		return name + " <= ?", []any{arg}, nil
+	}
+	return invalid()
+}
+
+// escapeLike escapes the LIKE wildcards with "!", which has no special meaning
+// in the string literals of all supported databases unlike "\".
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -0,0 +1,172 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// URLEncodedBase64 is a byte slice that is marshaled to JSON as unpadded base64url,
+// which is the encoding browsers use for ArrayBuffer fields in the WebAuthn JSON API.
+type URLEncodedBase64 []byte
+
+func (u URLEncodedBase64) String() string { return base64.RawURLEncoding.EncodeToString(u) }
+
+func (u URLEncodedBase64) MarshalJSON() ([]byte, error) {
+	if u == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(u.String())
+}
+
+func (u *URLEncodedBase64) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := DecodeBase64(s)
+	if err != nil {
+		return err
+	}
+	*u = b
+	return nil
+}
+
+// DecodeBase64 decodes base64url with or without padding, falling back to standard base64.
+func DecodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// User verification requirements.
+const (
+	VerificationRequired    = "required"
+	VerificationPreferred   = "preferred"
+	VerificationDiscouraged = "discouraged"
+)
+
+// Resident key requirements.
+const (
+	ResidentKeyRequired    = "required"
+	ResidentKeyPreferred   = "preferred"
+	ResidentKeyDiscouraged = "discouraged"
+)
+
+const credentialTypePublicKey = "public-key"
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          URLEncodedBase64 `json:"id"`
+	Name        string           `json:"name"`
+	DisplayName string           `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type      string        `json:"type"`
+	Algorithm COSEAlgorithm `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string           `json:"type"`
+	ID         URLEncodedBase64 `json:"id"`
+	Transports []string         `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	AuthenticatorAttachment string `json:"authenticatorAttachment,omitempty"`
+	ResidentKey             string `json:"residentKey,omitempty"`
+	RequireResidentKey      bool   `json:"requireResidentKey"`
+	UserVerification        string `json:"userVerification,omitempty"`
+}
+
+// CredentialCreationOptions is the PublicKeyCredentialCreationOptions passed to navigator.credentials.create().
+type CredentialCreationOptions struct {
+	RelyingParty           RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              URLEncodedBase64       `json:"challenge"`
+	Parameters             []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"` // milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation,omitempty"`
+}
+
+// CredentialRequestOptions is the PublicKeyCredentialRequestOptions passed to navigator.credentials.get().
+type CredentialRequestOptions struct {
+	Challenge        URLEncodedBase64       `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"` // milliseconds
+	RelyingPartyID   string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification,omitempty"`
+}
+
+// CredentialCreationResponse is the JSON serialization of the PublicKeyCredential
+// returned by navigator.credentials.create().
+type CredentialCreationResponse struct {
+	ID       string                           `json:"id"`
+	RawID    URLEncodedBase64                 `json:"rawId"`
+	Type     string                           `json:"type"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON"`
+	AttestationObject URLEncodedBase64 `json:"attestationObject"`
+	Transports        []string         `json:"transports,omitempty"`
+}
+
+// CredentialAssertionResponse is the JSON serialization of the PublicKeyCredential
+// returned by navigator.credentials.get().
+type CredentialAssertionResponse struct {
+	ID       string                         `json:"id"`
+	RawID    URLEncodedBase64               `json:"rawId"`
+	Type     string                         `json:"type"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON"`
+	AuthenticatorData URLEncodedBase64 `json:"authenticatorData"`
+	Signature         URLEncodedBase64 `json:"signature"`
+	UserHandle        URLEncodedBase64 `json:"userHandle,omitempty"`
+}
+
+// CollectedClientData is the decoded clientDataJSON.
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// Authenticator data flags.
+const (
+	FlagUserPresent            byte = 0x01
+	FlagUserVerified           byte = 0x04
+	FlagBackupEligible         byte = 0x08
+	FlagBackupState            byte = 0x10
+	FlagAttestedCredentialData byte = 0x40
+	FlagExtensionData          byte = 0x80
+)
+
+// COSEAlgorithm is a COSE algorithm identifier registered by IANA.
+type COSEAlgorithm int
+
+const (
+	AlgES256 = COSEAlgorithm(webauthncose.AlgES256) // ECDSA w/ SHA-256 on P-256
+	AlgEdDSA = COSEAlgorithm(webauthncose.AlgEdDSA) // EdDSA on Ed25519
+	AlgRS256 = COSEAlgorithm(webauthncose.AlgRS256) // RSASSA-PKCS1-v1_5 w/ SHA-256
+)
@@ -0,0 +1,413 @@
+// Package webauthn implements the relying party side of the W3C WebAuthn
+// registration and authentication ceremonies.
+//
+// The package is a small layer over github.com/go-webauthn/webauthn/protocol,
+// which parses the responses and verifies the attestation statements and
+// assertion signatures. This package adds the session handling, the credential
+// allow list and the signature counter check. ES256, EdDSA and RS256 credentials
+// are requested, which covers platform authenticators, security keys and synced
+// passkeys. Attestation certificates are not chained to a trust root.
+//
+// Usage:
+//
+//	wa, _ := webauthn.New(webauthn.Config{RPID: "example.com", RPOrigins: []string{"https://example.com"}})
+//
+//	// registration
+//	options, session, _ := wa.BeginRegistration(user)
+//	cred, _ := wa.FinishRegistration(session, response)
+//
+//	// authentication
+//	options, session, _ = wa.BeginLogin(user.ID, user.Credentials)
+//	cred, _ = wa.FinishLogin(session, user.Credentials, response)
+//
+// See package webauthntest for a software authenticator usable in tests.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+var (
+	ErrInvalidChallenge   = errors.New("webauthn: challenge mismatch")
+	ErrInvalidOrigin      = errors.New("webauthn: origin not allowed")
+	ErrInvalidRPID        = errors.New("webauthn: rp id hash mismatch")
+	ErrInvalidSignature   = errors.New("webauthn: invalid signature")
+	ErrUserNotPresent     = errors.New("webauthn: user presence flag not set")
+	ErrUserNotVerified    = errors.New("webauthn: user verification required")
+	ErrSessionExpired     = errors.New("webauthn: ceremony session expired")
+	ErrCredentialNotFound = errors.New("webauthn: credential not allowed")
+	ErrCloned             = errors.New("webauthn: signature counter did not increase, authenticator may be cloned")
+	ErrUnsupportedFormat  = errors.New("webauthn: unsupported attestation format")
+)
+
+// defaultTimeout is the ceremony timeout passed to the browser and used for session expiry.
+const defaultTimeout = 5 * time.Minute
+
+// Config configures the relying party.
+type Config struct {
+	RPID          string        // RPID is the effective domain, e.g. "example.com".
+	RPDisplayName string        // RPDisplayName is shown by authenticators, default is RPID.
+	RPOrigins     []string      // RPOrigins are the allowed origins, e.g. "https://example.com".
+	Timeout       time.Duration // Timeout is the ceremony timeout, default is 5 minutes.
+
+	// UserVerification is the user verification requirement for both ceremonies, default is "preferred".
+	UserVerification string
+}
+
+// WebAuthn is a relying party.
+type WebAuthn struct {
+	cfg Config
+}
+
+// New creates a relying party from the config.
+func New(cfg Config) (*WebAuthn, error) {
+	if len(cfg.RPID) == 0 {
+		return nil, errors.New("webauthn: rp id is required")
+	}
+	if len(cfg.RPOrigins) == 0 {
+		return nil, errors.New("webauthn: at least one rp origin is required")
+	}
+	if len(cfg.RPDisplayName) == 0 {
+		cfg.RPDisplayName = cfg.RPID
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if len(cfg.UserVerification) == 0 {
+		cfg.UserVerification = VerificationPreferred
+	}
+	return &WebAuthn{cfg: cfg}, nil
+}
+
+// User is the account a credential is registered for.
+type User struct {
+	ID          []byte // ID is the opaque user handle, at most 64 bytes.
+	Name        string
+	DisplayName string
+	Credentials []Credential // Credentials are excluded from re-registration.
+}
+
+// Credential is a registered public key credential.
+type Credential struct {
+	ID              []byte   `json:"id"`
+	PublicKey       []byte   `json:"public_key"` // CBOR encoded COSE_Key
+	AttestationType string   `json:"attestation_type"`
+	AAGUID          []byte   `json:"aaguid"`
+	SignCount       uint32   `json:"sign_count"`
+	Transports      []string `json:"transports,omitempty"`
+	UserVerified    bool     `json:"user_verified"`
+	BackupEligible  bool     `json:"backup_eligible"`
+	BackupState     bool     `json:"backup_state"`
+}
+
+// SessionData is the server side state of a ceremony, it must be kept between Begin* and Finish*.
+type SessionData struct {
+	Challenge            string    `json:"challenge"` // base64url encoded
+	UserID               []byte    `json:"user_id,omitempty"`
+	AllowedCredentialIDs [][]byte  `json:"allowed_credential_ids,omitempty"`
+	UserVerification     string    `json:"user_verification"`
+	Expires              time.Time `json:"expires"`
+}
+
+// RegistrationOption customizes the creation options.
+type RegistrationOption func(*CredentialCreationOptions)
+
+// WithResidentKey requests a discoverable credential (passkey).
+func WithResidentKey(requirement string) RegistrationOption {
+	return func(o *CredentialCreationOptions) {
+		o.AuthenticatorSelection.ResidentKey = requirement
+		o.AuthenticatorSelection.RequireResidentKey = requirement == ResidentKeyRequired
+	}
+}
+
+// WithAuthenticatorAttachment restricts the authenticator to "platform" or "cross-platform".
+func WithAuthenticatorAttachment(attachment string) RegistrationOption {
+	return func(o *CredentialCreationOptions) {
+		o.AuthenticatorSelection.AuthenticatorAttachment = attachment
+	}
+}
+
+// BeginRegistration starts a registration ceremony for user.
+func (w *WebAuthn) BeginRegistration(user User, opts ...RegistrationOption) (*CredentialCreationOptions, *SessionData, error) {
+	if len(user.ID) == 0 || len(user.ID) > 64 {
+		return nil, nil, errors.New("webauthn: user id must be between 1 and 64 bytes")
+	}
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+	displayName := user.DisplayName
+	if len(displayName) == 0 {
+		displayName = user.Name
+	}
+
+	options := &CredentialCreationOptions{
+		RelyingParty: RelyingPartyEntity{ID: w.cfg.RPID, Name: w.cfg.RPDisplayName},
+		User:         UserEntity{ID: user.ID, Name: user.Name, DisplayName: displayName},
+		Challenge:    challenge,
+		Parameters: []CredentialParameter{
+			{Type: credentialTypePublicKey, Algorithm: AlgES256},
+			{Type: credentialTypePublicKey, Algorithm: AlgEdDSA},
+			{Type: credentialTypePublicKey, Algorithm: AlgRS256},
+		},
+		Timeout: w.cfg.Timeout.Milliseconds(),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      ResidentKeyDiscouraged,
+			UserVerification: w.cfg.UserVerification,
+		},
+		Attestation: "none",
+	}
+	for _, cred := range user.Credentials {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, CredentialDescriptor{
+			Type:       credentialTypePublicKey,
+			ID:         cred.ID,
+			Transports: cred.Transports,
+		})
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	session := &SessionData{
+		Challenge:        challenge.String(),
+		UserID:           user.ID,
+		UserVerification: options.AuthenticatorSelection.UserVerification,
+		Expires:          time.Now().Add(w.cfg.Timeout),
+	}
+	return options, session, nil
+}
+
+// FinishRegistration verifies the attestation response and returns the new credential.
+func (w *WebAuthn) FinishRegistration(session *SessionData, resp *CredentialCreationResponse) (*Credential, error) {
+	if session == nil || resp == nil {
+		return nil, errors.New("webauthn: session and response are required")
+	}
+	if err := checkExpiry(session); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "webauthn: failed to marshal response")
+	}
+	pcc, err := protocol.ParseCredentialCreationResponseBytes(data)
+	if err != nil {
+		return nil, wrapProtocolError(err, "webauthn: invalid attestation response")
+	}
+	authData := pcc.Response.AttestationObject.AuthData
+	if err = w.checkClientData(pcc.Response.CollectedClientData, protocol.CreateCeremony, session); err != nil {
+		return nil, err
+	}
+	if err = w.checkAuthenticatorData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(attestationFormats, protocol.AttestationFormat(pcc.Response.AttestationObject.Format)) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if _, err = pcc.Verify(session.Challenge, session.UserVerification == VerificationRequired, true,
+		w.cfg.RPID, w.cfg.RPOrigins, nil, protocol.TopOriginIgnoreVerificationMode, nil, credentialParameters()); err != nil {
+		return nil, wrapProtocolError(err, "webauthn: attestation verification failed")
+	}
+
+	return &Credential{
+		ID:              authData.AttData.CredentialID,
+		PublicKey:       authData.AttData.CredentialPublicKey,
+		AttestationType: pcc.Response.AttestationObject.Format,
+		AAGUID:          authData.AttData.AAGUID,
+		SignCount:       authData.Counter,
+		Transports:      resp.Response.Transports,
+		UserVerified:    authData.Flags.HasUserVerified(),
+		BackupEligible:  authData.Flags.HasBackupEligible(),
+		BackupState:     authData.Flags.HasBackupState(),
+	}, nil
+}
+
+// BeginLogin starts an authentication ceremony.
+// An empty credentials list starts a discoverable (passwordless) login where
+// the authenticator chooses the credential and returns the user handle.
+func (w *WebAuthn) BeginLogin(userID []byte, credentials []Credential) (*CredentialRequestOptions, *SessionData, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+	options := &CredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          w.cfg.Timeout.Milliseconds(),
+		RelyingPartyID:   w.cfg.RPID,
+		UserVerification: w.cfg.UserVerification,
+	}
+	session := &SessionData{
+		Challenge:        challenge.String(),
+		UserID:           userID,
+		UserVerification: w.cfg.UserVerification,
+		Expires:          time.Now().Add(w.cfg.Timeout),
+	}
+	for _, cred := range credentials {
+		options.AllowCredentials = append(options.AllowCredentials, CredentialDescriptor{
+			Type:       credentialTypePublicKey,
+			ID:         cred.ID,
+			Transports: cred.Transports,
+		})
+		session.AllowedCredentialIDs = append(session.AllowedCredentialIDs, cred.ID)
+	}
+	return options, session, nil
+}
+
+// FinishLogin verifies the assertion against one of credentials and returns the matched
+// credential with its signature counter updated. The caller must persist the new counter.
+func (w *WebAuthn) FinishLogin(session *SessionData, credentials []Credential, resp *CredentialAssertionResponse) (*Credential, error) {
+	if session == nil || resp == nil {
+		return nil, errors.New("webauthn: session and response are required")
+	}
+	if resp.Type != credentialTypePublicKey {
+		return nil, errors.Newf("webauthn: unexpected credential type %q", resp.Type)
+	}
+	credID := []byte(resp.RawID)
+	if len(credID) == 0 {
+		var err error
+		if credID, err = DecodeBase64(resp.ID); err != nil {
+			return nil, errors.Wrap(err, "webauthn: invalid credential id")
+		}
+	}
+	if len(session.AllowedCredentialIDs) > 0 && !slices.ContainsFunc(session.AllowedCredentialIDs, func(id []byte) bool {
+		return bytes.Equal(id, credID)
+	}) {
+		return nil, ErrCredentialNotFound
+	}
+	if len(session.UserID) > 0 && len(resp.Response.UserHandle) > 0 && !bytes.Equal(session.UserID, resp.Response.UserHandle) {
+		return nil, errors.New("webauthn: user handle mismatch")
+	}
+
+	idx := slices.IndexFunc(credentials, func(c Credential) bool { return bytes.Equal(c.ID, credID) })
+	if idx < 0 {
+		return nil, ErrCredentialNotFound
+	}
+	cred := credentials[idx]
+
+	if err := checkExpiry(session); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "webauthn: failed to marshal response")
+	}
+	par, err := protocol.ParseCredentialRequestResponseBytes(data)
+	if err != nil {
+		return nil, wrapProtocolError(err, "webauthn: invalid assertion response")
+	}
+	authData := par.Response.AuthenticatorData
+	if err = w.checkClientData(par.Response.CollectedClientData, protocol.AssertCeremony, session); err != nil {
+		return nil, err
+	}
+	if err = w.checkAuthenticatorData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+
+	if err = par.Verify(session.Challenge, w.cfg.RPID, w.cfg.RPOrigins, nil, protocol.TopOriginIgnoreVerificationMode,
+		"", session.UserVerification == VerificationRequired, true, cred.PublicKey); err != nil {
+		var perr *protocol.Error
+		if errors.As(err, &perr) && perr.Type == protocol.ErrAssertionSignature.Type {
+			return nil, errors.Wrap(ErrInvalidSignature, perr.Details)
+		}
+		return nil, wrapProtocolError(err, "webauthn: assertion verification failed")
+	}
+
+	// Authenticators that do not implement a counter always report zero.
+	if authData.Counter != 0 || cred.SignCount != 0 {
+		if authData.Counter <= cred.SignCount {
+			return nil, ErrCloned
+		}
+	}
+	cred.SignCount = authData.Counter
+	cred.UserVerified = authData.Flags.HasUserVerified()
+	cred.BackupState = authData.Flags.HasBackupState()
+	return &cred, nil
+}
+
+func checkExpiry(session *SessionData) error {
+	if !session.Expires.IsZero() && time.Now().After(session.Expires) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// checkClientData checks the client data before the full verification,
+// so the common failures are reported as the errors of this package.
+func (w *WebAuthn) checkClientData(cd protocol.CollectedClientData, ceremony protocol.CeremonyType, session *SessionData) error {
+	if cd.Type != ceremony {
+		return errors.Newf("webauthn: unexpected client data type %q", cd.Type)
+	}
+	got, err := DecodeBase64(cd.Challenge)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	want, err := base64.RawURLEncoding.DecodeString(session.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrInvalidChallenge
+	}
+	if !slices.Contains(w.cfg.RPOrigins, cd.Origin) {
+		return ErrInvalidOrigin
+	}
+	return nil
+}
+
+func (w *WebAuthn) checkAuthenticatorData(ad protocol.AuthenticatorData, userVerification string) error {
+	rpIDHash := sha256.Sum256([]byte(w.cfg.RPID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return ErrInvalidRPID
+	}
+	if !ad.Flags.HasUserPresent() {
+		return ErrUserNotPresent
+	}
+	if userVerification == VerificationRequired && !ad.Flags.HasUserVerified() {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// wrapProtocolError keeps the details of the protocol error, its Error() only returns the short description.
+func wrapProtocolError(err error, msg string) error {
+	var perr *protocol.Error
+	if errors.As(err, &perr) && len(perr.DevInfo) > 0 {
+		return errors.Wrapf(err, "%s: %s", msg, perr.DevInfo)
+	}
+	return errors.Wrap(err, msg)
+}
+
+// attestationFormats are the attestation statement formats verified by the protocol package.
+var attestationFormats = []protocol.AttestationFormat{
+	protocol.AttestationFormatNone,
+	protocol.AttestationFormatPacked,
+	protocol.AttestationFormatTPM,
+	protocol.AttestationFormatAndroidKey,
+	protocol.AttestationFormatAndroidSafetyNet,
+	protocol.AttestationFormatFIDOUniversalSecondFactor,
+	protocol.AttestationFormatApple,
+}
+
+func credentialParameters() []protocol.CredentialParameter {
+	return []protocol.CredentialParameter{
+		{Type: protocol.PublicKeyCredentialType, Algorithm: webauthncose.AlgES256},
+		{Type: protocol.PublicKeyCredentialType, Algorithm: webauthncose.AlgEdDSA},
+		{Type: protocol.PublicKeyCredentialType, Algorithm: webauthncose.AlgRS256},
+	}
+}
+
+func newChallenge() (URLEncodedBase64, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, errors.Wrap(err, "webauthn: failed to generate challenge")
+	}
+	return challenge, nil
+}
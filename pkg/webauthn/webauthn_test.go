@@ -0,0 +1,162 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/forbearing/gst/pkg/webauthn"
+	"github.com/forbearing/gst/pkg/webauthn/webauthntest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rpID   = "localhost"
+	origin = "http://localhost:8000"
+)
+
+func newRP(t *testing.T) *webauthn.WebAuthn {
+	t.Helper()
+	wa, err := webauthn.New(webauthn.Config{RPID: rpID, RPOrigins: []string{origin}})
+	require.NoError(t, err)
+	return wa
+}
+
+func register(t *testing.T, wa *webauthn.WebAuthn, auth *webauthntest.Authenticator, user webauthn.User) *webauthn.Credential {
+	t.Helper()
+	options, session, err := wa.BeginRegistration(user, webauthn.WithResidentKey(webauthn.ResidentKeyRequired))
+	require.NoError(t, err)
+	resp, err := auth.Register(options)
+	require.NoError(t, err)
+
+	// Round trip through JSON like a browser client would.
+	data, err := json.Marshal(resp)
+	require.NoError(t, err)
+	resp = new(webauthn.CredentialCreationResponse)
+	require.NoError(t, json.Unmarshal(data, resp))
+
+	cred, err := wa.FinishRegistration(session, resp)
+	require.NoError(t, err)
+	return cred
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	wa := newRP(t)
+	auth := webauthntest.New(origin)
+	user := webauthn.User{ID: []byte("user-01"), Name: "user01"}
+
+	cred := register(t, wa, auth, user)
+	require.NotEmpty(t, cred.ID)
+	require.Equal(t, "none", cred.AttestationType)
+	require.Equal(t, uint32(1), cred.SignCount)
+	require.True(t, cred.UserVerified)
+
+	t.Run("second factor", func(t *testing.T) {
+		options, session, err := wa.BeginLogin(user.ID, []webauthn.Credential{*cred})
+		require.NoError(t, err)
+		require.Len(t, options.AllowCredentials, 1)
+
+		resp, err := auth.Login(options)
+		require.NoError(t, err)
+		updated, err := wa.FinishLogin(session, []webauthn.Credential{*cred}, resp)
+		require.NoError(t, err)
+		require.Equal(t, uint32(2), updated.SignCount)
+		cred = updated
+	})
+
+	t.Run("discoverable", func(t *testing.T) {
+		options, session, err := wa.BeginLogin(nil, nil)
+		require.NoError(t, err)
+		require.Empty(t, options.AllowCredentials)
+
+		resp, err := auth.Login(options)
+		require.NoError(t, err)
+		require.Equal(t, user.ID, []byte(resp.Response.UserHandle))
+		_, err = wa.FinishLogin(session, []webauthn.Credential{*cred}, resp)
+		require.NoError(t, err)
+	})
+
+	t.Run("replayed assertion", func(t *testing.T) {
+		options, session, err := wa.BeginLogin(user.ID, []webauthn.Credential{*cred})
+		require.NoError(t, err)
+		resp, err := auth.Login(options)
+		require.NoError(t, err)
+
+		_, err = wa.FinishLogin(session, []webauthn.Credential{*cred}, resp)
+		require.NoError(t, err)
+
+		// The stored counter was not advanced by the previous call.
+		stale := *cred
+		stale.SignCount = 100
+		_, err = wa.FinishLogin(session, []webauthn.Credential{stale}, resp)
+		require.ErrorIs(t, err, webauthn.ErrCloned)
+	})
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		options, _, err := wa.BeginLogin(user.ID, []webauthn.Credential{*cred})
+		require.NoError(t, err)
+		_, other, err := wa.BeginLogin(user.ID, []webauthn.Credential{*cred})
+		require.NoError(t, err)
+		resp, err := auth.Login(options)
+		require.NoError(t, err)
+		_, err = wa.FinishLogin(other, []webauthn.Credential{*cred}, resp)
+		require.ErrorIs(t, err, webauthn.ErrInvalidChallenge)
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		options, session, err := wa.BeginLogin(user.ID, []webauthn.Credential{*cred})
+		require.NoError(t, err)
+		resp, err := auth.Login(options)
+		require.NoError(t, err)
+		resp.Response.AuthenticatorData[len(resp.Response.AuthenticatorData)-1]++
+		_, err = wa.FinishLogin(session, []webauthn.Credential{*cred}, resp)
+		require.ErrorIs(t, err, webauthn.ErrInvalidSignature)
+	})
+}
+
+func TestFinishRegistrationRejects(t *testing.T) {
+	wa := newRP(t)
+	user := webauthn.User{ID: []byte("user-02"), Name: "user02"}
+
+	t.Run("origin", func(t *testing.T) {
+		options, session, err := wa.BeginRegistration(user)
+		require.NoError(t, err)
+		resp, err := webauthntest.New("https://evil.example").Register(options)
+		require.NoError(t, err)
+		_, err = wa.FinishRegistration(session, resp)
+		require.ErrorIs(t, err, webauthn.ErrInvalidOrigin)
+	})
+
+	t.Run("rp id", func(t *testing.T) {
+		options, session, err := wa.BeginRegistration(user)
+		require.NoError(t, err)
+		options.RelyingParty.ID = "example.com"
+		resp, err := webauthntest.New(origin).Register(options)
+		require.NoError(t, err)
+		_, err = wa.FinishRegistration(session, resp)
+		require.ErrorIs(t, err, webauthn.ErrInvalidRPID)
+	})
+
+	t.Run("user verification", func(t *testing.T) {
+		strict, err := webauthn.New(webauthn.Config{RPID: rpID, RPOrigins: []string{origin}, UserVerification: webauthn.VerificationRequired})
+		require.NoError(t, err)
+		options, session, err := strict.BeginRegistration(user)
+		require.NoError(t, err)
+		auth := webauthntest.New(origin)
+		auth.UserVerified = false
+		resp, err := auth.Register(options)
+		require.NoError(t, err)
+		_, err = strict.FinishRegistration(session, resp)
+		require.ErrorIs(t, err, webauthn.ErrUserNotVerified)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		options, session, err := wa.BeginRegistration(user)
+		require.NoError(t, err)
+		session.Expires = time.Now().Add(-time.Second)
+		resp, err := webauthntest.New(origin).Register(options)
+		require.NoError(t, err)
+		_, err = wa.FinishRegistration(session, resp)
+		require.ErrorIs(t, err, webauthn.ErrSessionExpired)
+	})
+}
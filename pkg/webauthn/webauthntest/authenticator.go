@@ -0,0 +1,219 @@
+// Package webauthntest provides a software WebAuthn authenticator for tests.
+//
+// The authenticator generates ES256 credentials, produces "none" attestation
+// objects and signs assertions exactly like a browser would, so relying party
+// code can be exercised end to end without a real security key.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/forbearing/gst/pkg/webauthn"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// Authenticator is an in-memory authenticator holding any number of credentials.
+type Authenticator struct {
+	// Origin is reported in clientDataJSON, e.g. "http://localhost:8000".
+	Origin string
+	// UserVerified sets the UV flag on every response.
+	UserVerified bool
+	// SkipCounter keeps the signature counter at zero like many platform passkeys.
+	SkipCounter bool
+
+	mu          sync.Mutex
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// New creates an authenticator reporting the given origin.
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, UserVerified: true}
+}
+
+// Register performs navigator.credentials.create() for options.
+func (a *Authenticator) Register(options *webauthn.CredentialCreationOptions) (*webauthn.CredentialCreationResponse, error) {
+	if options == nil {
+		return nil, errors.New("webauthntest: options is nil")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, excluded := range options.ExcludeCredentials {
+		for _, c := range a.credentials {
+			if bytes.Equal(c.id, excluded.ID) {
+				return nil, errors.New("webauthntest: credential already registered")
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{
+		id:         id,
+		rpID:       options.RelyingParty.ID,
+		userHandle: options.User.ID,
+		key:        key,
+	}
+	if !a.SkipCounter {
+		cred.signCount = 1
+	}
+
+	coseKey, err := marshalPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	attested := make([]byte, 0, 18+len(id)+len(coseKey))
+	attested = append(attested, make([]byte, 16)...) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, coseKey...)
+
+	authData := a.authenticatorData(cred, webauthn.FlagAttestedCredentialData, attested)
+	attObj, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, cred)
+	return &webauthn.CredentialCreationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(id),
+		RawID: id,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientData,
+			AttestationObject: attObj,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Login performs navigator.credentials.get() for options.
+// When options.AllowCredentials is empty a discoverable credential for the relying party is used.
+func (a *Authenticator) Login(options *webauthn.CredentialRequestOptions) (*webauthn.CredentialAssertionResponse, error) {
+	if options == nil {
+		return nil, errors.New("webauthntest: options is nil")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var cred *credential
+	for _, c := range a.credentials {
+		if c.rpID != options.RelyingPartyID {
+			continue
+		}
+		if len(options.AllowCredentials) == 0 {
+			cred = c
+			break
+		}
+		for _, allowed := range options.AllowCredentials {
+			if bytes.Equal(allowed.ID, c.id) {
+				cred = c
+				break
+			}
+		}
+		if cred != nil {
+			break
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+
+	if !a.SkipCounter {
+		cred.signCount++
+	}
+	authData := a.authenticatorData(cred, 0, nil)
+	clientData, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.CredentialAssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(cred.id),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientData,
+			AuthenticatorData: authData,
+			Signature:         sig,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+func (a *Authenticator) authenticatorData(cred *credential, flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(cred.rpID))
+	flags |= webauthn.FlagUserPresent
+	if a.UserVerified {
+		flags |= webauthn.FlagUserVerified
+	}
+	data := make([]byte, 0, 37+len(attested))
+	data = append(data, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, cred.signCount)
+	return append(data, attested...)
+}
+
+// marshalPublicKey encodes the P-256 public key as an ES256 COSE_Key.
+func marshalPublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	ecdhKey, err := pub.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	point := ecdhKey.Bytes() // 0x04 || x || y
+	return webauthncbor.Marshal(&webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+}
+
+func (a *Authenticator) clientData(typ string, challenge []byte) ([]byte, error) {
+	return json.Marshal(webauthn.CollectedClientData{
+		Type:      typ,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.Origin,
+	})
+}
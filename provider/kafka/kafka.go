@@ -165,6 +165,13 @@ func configureKafka(config *sarama.Config, cfg config.Kafka) error {
 	return nil
 }
 
+// Client returns the global kafka client, it is nil if kafka is not enabled.
+func Client() sarama.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
+
 // NewAsyncProducer creates a new AsyncProducer using the given client
 func NewAsyncProducer(client sarama.Client) (sarama.AsyncProducer, error) {
 	return sarama.NewAsyncProducerFromClient(client)
@@ -369,6 +369,119 @@ func Expire(key string, expiration time.Duration) error {
 	return client.Expire(ctx, key, expiration).Err()
 }
 
+// SetNX sets key to value with the expiration only if key does not exist,
+// it reports whether the key was set.
+func SetNX(key string, value any, expiration time.Duration) (bool, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return true, nil
+	}
+	return cli.SetNX(ctx, key, value, expiration).Result()
+}
+
+// delIfEqualScript deletes the key only if it still holds the value.
+var delIfEqualScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DelIfEqual deletes key only if its value equals value, it reports whether the key was deleted.
+// It is used to release a key set by SetNX without deleting the key of another owner once it expired.
+func DelIfEqual(key, value string) (bool, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return true, nil
+	}
+	n, err := delIfEqualScript.Run(ctx, cli, []string{key}, value).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// getDelScript gets and deletes the key atomically,
+// it works on every redis version unlike GETDEL which requires redis 6.2.
+var getDelScript = goredis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if v then
+	redis.call("DEL", KEYS[1])
+end
+return v
+`)
+
+// GetDel gets the raw value of key and deletes the key atomically,
+// so only one of the concurrent callers gets the value.
+// The error is ErrKeyNotExists if the key doesn't exist, or ErrRedisIsDisabled if redis is disabled.
+func GetDel(key string) ([]byte, error) {
+	if !config.App.Redis.Enable || cli == nil {
+		return nil, ErrRedisIsDisabled
+	}
+	data, err := getDelScript.Run(ctx, cli, []string{key}).Text()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrKeyNotExists
+		}
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+// pexpireIfEqualScript sets the expiration of the key only if it still holds the value.
+var pexpireIfEqualScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// ExpireIfEqual sets the expiration of key only if its value equals value, it reports whether the expiration was set.
+// It is used to extend a key set by SetNX only while it is still owned by the caller.
+func ExpireIfEqual(key, value string, expiration time.Duration) (bool, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return true, nil
+	}
+	n, err := pexpireIfEqualScript.Run(ctx, cli, []string{key}, value, expiration.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// incrWithExpireScript sets the expiration when the counter is created,
+// it works on every redis version unlike INCR + EXPIRE NX which requires redis 7.
+var incrWithExpireScript = goredis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// IncrWithExpire increments the integer value of key by one and returns the new value.
+// The expiration is only applied when the key is created, so the counter behaves as a fixed window.
+func IncrWithExpire(key string, expiration time.Duration) (int64, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return 0, nil
+	}
+	return incrWithExpireScript.Run(ctx, cli, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+// RunScript runs the lua script with EVALSHA, it loads the script on the first run.
+// The error of the returned command is ErrRedisIsDisabled if redis is disabled,
+// callers that must work without redis fall back on it.
+func RunScript(c context.Context, script *goredis.Script, keys []string, args ...any) *goredis.Cmd {
+	if !config.App.Redis.Enable || cli == nil {
+		cmd := goredis.NewCmd(c)
+		cmd.SetErr(ErrRedisIsDisabled)
+		return cmd
+	}
+	return script.Run(c, cli, keys, args...)
+}
+
 // ZAdd adds one or multiple string members with the same score into a sorted set.
 func ZAdd(key string, score float64, members ...string) error {
 	if !config.App.Redis.Enable {
@@ -419,6 +532,95 @@ func ZRem(key string, members ...string) error {
 	return client.ZRem(ctx, key, memberArgs...).Err()
 }
 
+// SAdd adds one or multiple members into a set and resets the ttl of the set.
+func SAdd(key string, expiration time.Duration, members ...string) error {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return nil
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	memberArgs := make([]any, 0, len(members))
+	for i := range members {
+		memberArgs = append(memberArgs, members[i])
+	}
+	_, err := cli.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.SAdd(ctx, key, memberArgs...)
+		if expiration > 0 {
+			pipe.Expire(ctx, key, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// SMembers returns all the members of a set.
+func SMembers(key string) ([]string, error) {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return make([]string, 0), nil
+	}
+	return cli.SMembers(ctx, key).Result()
+}
+
+// Unlink removes the keys in background.
+// Every key is removed by its own command in a pipeline, so the keys may belong to different cluster slots.
+func Unlink(keys ...string) error {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return nil
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := cli.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i := range keys {
+			pipe.Unlink(ctx, keys[i])
+		}
+		return nil
+	})
+	return err
+}
+
+// Publish posts the message to the channel.
+func Publish(channel string, message []byte) error {
+	if !config.App.Redis.Enable {
+		zap.S().Warn(ErrRedisIsDisabled.Error())
+		return nil
+	}
+	return cli.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe calls handler with the payload of every message published to the channel
+// until the context is canceled. It returns once the subscription is confirmed.
+func Subscribe(c context.Context, channel string, handler func(payload []byte)) error {
+	if !config.App.Redis.Enable {
+		return ErrRedisIsDisabled
+	}
+	sub := cli.Subscribe(c, channel)
+	if _, err := sub.Receive(c); err != nil {
+		_ = sub.Close()
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-c.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler([]byte(msg.Payload))
+			}
+		}
+	}()
+	return nil
+}
+
 // RemovePrefix will scan and delete all redis key that matchs the `prefix`.
 // for example: myprefix*
 func RemovePrefix(prefix string) (err error) {
@@ -56,6 +56,10 @@ const (
 
 	CodeAccountInactive
 	CodeAccountLocked
+
+	CodeTooManyLoginAttempts
+	CodeCaptchaRequired
+	CodeTooManySessions
 )
 
 type codeValue struct {
@@ -95,6 +99,10 @@ var defaultCodeValueMap = map[Code]codeValue{
 	CodeTooLargeFile:        {http.StatusBadRequest, "too large file"},
 	CodeAccountInactive:     {http.StatusForbidden, "user account is disabled"},
 	CodeAccountLocked:       {http.StatusForbidden, "user account is locked"},
+
+	CodeTooManyLoginAttempts: {http.StatusTooManyRequests, "too many failed login attempts, please try again later"},
+	CodeCaptchaRequired:      {http.StatusBadRequest, "captcha verification required"},
+	CodeTooManySessions:      {http.StatusConflict, "maximum concurrent sessions reached"},
 }
 
 // customCodeValueMap holds app-defined overrides from Code to HTTP status and message.
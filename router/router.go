@@ -5,14 +5,17 @@ import (
 	"net"
 	"net/http"
 	gopath "path"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/forbearing/gst/authz/rbac"
 	"github.com/forbearing/gst/config"
 	"github.com/forbearing/gst/controller"
+	"github.com/forbearing/gst/database"
 	"github.com/forbearing/gst/internal/openapigen"
 	"github.com/forbearing/gst/middleware"
 	"github.com/forbearing/gst/model"
@@ -51,11 +54,13 @@ func Init() error {
 		middleware.Recovery("recovery.log"),
 		middleware.Cors(),
 		middleware.RouteParams(),
+		middleware.LoadShedding(),
 		// middleware.Gzip(),
 	)
 	root.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	root.GET("/-/healthz", controller.Probe.Healthz)
 	root.GET("/-/readyz", controller.Probe.Readyz)
+	root.GET("/-/leader", controller.Probe.Leader)
 	root.GET("/openapi.json", middleware.BaseAuth(), gin.WrapH(openapigen.DocumentHandler()))
 	root.GET("/docs/*any", middleware.BaseAuth(), ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi.json")))
 	root.GET("/redoc", middleware.BaseAuth(), controller.Redoc)
@@ -117,6 +122,14 @@ func Run() error {
 func Auth() *gin.RouterGroup { return auth }
 func Pub() *gin.RouterGroup  { return pub }
 
+// Handle registers raw handlers on the root router, the path is not prefixed with "/api"
+// and the auth middlewares are not applied.
+func Handle(method, path string, handlers ...gin.HandlerFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	root.Handle(method, path, handlers...)
+}
+
 func Stop() {
 	if server == nil {
 		return
@@ -208,6 +221,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.POST(path, controller.CreateFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPost)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Create)
 	}
 	if verbMap[consts.Delete] {
@@ -215,6 +229,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.DELETE(path, controller.DeleteFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodDelete)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Delete)
 	}
 	if verbMap[consts.Update] {
@@ -222,6 +237,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.PUT(path, controller.UpdateFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPut)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Update)
 	}
 	if verbMap[consts.Patch] {
@@ -229,6 +245,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.PATCH(path, controller.PatchFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPatch)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Patch)
 	}
 	if verbMap[consts.List] {
@@ -236,6 +253,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.GET(path, controller.ListFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodGet)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.List)
 	}
 
@@ -244,6 +262,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.GET(path, controller.GetFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodGet)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Get)
 	}
 
@@ -252,6 +271,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.POST(path, controller.CreateManyFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPost)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.CreateMany)
 	}
 	if verbMap[consts.DeleteMany] {
@@ -259,6 +279,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.DELETE(path, controller.DeleteManyFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodDelete)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.DeleteMany)
 	}
 	if verbMap[consts.UpdateMany] {
@@ -266,6 +287,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.PUT(path, controller.UpdateManyFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPut)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.UpdateMany)
 	}
 	if verbMap[consts.PatchMany] {
@@ -273,6 +295,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.PATCH(path, controller.PatchManyFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPatch)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.PatchMany)
 	}
 
@@ -281,6 +304,7 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.POST(path, controller.ImportFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodPost)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Import)
 	}
 	if verbMap[consts.Export] {
@@ -288,10 +312,26 @@ func register[M types.Model, REQ types.Request, RSP types.Response](router gin.I
 		router.GET(path, controller.ExportFactory[M, REQ, RSP](cfg...))
 		model.Routes[endpoint] = append(model.Routes[endpoint], http.MethodGet)
 		middleware.RouteManager.Add(endpoint)
+		registerOwner[M](endpoint)
 		go openapigen.Set[M, REQ, RSP](endpoint, consts.Export)
 	}
 }
 
+// registerOwner registers the owner loader of the route with path parameters,
+// so conditional policies can reference the owner of the requested resource, see rbac.Context.
+func registerOwner[M types.Model](endpoint string) {
+	if !strings.Contains(endpoint, "/:") {
+		return
+	}
+	rbac.RegisterOwner(endpoint, func(_ context.Context, id string) (string, error) {
+		m := reflect.New(reflect.TypeOf(*new(M)).Elem()).Interface().(M) //nolint:errcheck
+		if err := database.Database[M](nil).WithoutHook().Get(m, id); err != nil {
+			return "", err
+		}
+		return m.GetCreatedBy(), nil
+	})
+}
+
 // buildPath normalizes the API path.
 func buildPath(path string) string {
 	path = strings.TrimPrefix(path, `/api/`) // remove path prefix: '/api/'
@@ -5,14 +5,13 @@
 package task
 
 import (
-	"context"
 	"fmt"
 	"runtime"
 	"sync"
 	"time"
 
+	"github.com/forbearing/gst/cronjob"
 	"github.com/forbearing/gst/logger"
-	"github.com/forbearing/gst/util"
 )
 
 var (
@@ -26,8 +25,19 @@ type task struct {
 	name     string
 	interval time.Duration
 	fn       func() error
-	ctx      context.Context
-	cancel   context.CancelFunc
+	cfg      Config
+}
+
+// RetryPolicy retries the failed runs of a task, see cronjob.RetryPolicy.
+type RetryPolicy = cronjob.RetryPolicy
+
+// Config is the optional configuration of a task.
+type Config struct {
+	// Retry retries the failed attempts of a run, a run is failed only if its last attempt failed.
+	Retry RetryPolicy
+
+	// AlertAfter sends an alert after the number of consecutive failed runs, see cronjob.AddAlerter.
+	AlertAfter int
 }
 
 // Init initializes the task scheduler and starts all registered tasks.
@@ -36,9 +46,12 @@ type task struct {
 func Init() error {
 	Register(runtimestats, 60*time.Second, "runtime stats")
 
+	mu.Lock()
+	defer mu.Unlock()
 	for _, t := range tasks {
 		register(t)
 	}
+	tasks = nil
 
 	inited = true
 	return nil
@@ -47,27 +60,34 @@ func Init() error {
 // Register registers a task with the given function, interval, and name.
 // The task can be registered at any point before or after Init().
 //
+// The task runs as a cronjob scheduled "@every <interval>" which runs immediately and skips
+// the ticks while the previous run is still running, so it's listed and managed by module/jobs,
+// its runs are retried by Config.Retry and the failed runs are kept as dead letters
+// if cronjob.EnableHistory is called.
+//
 // Deprecated: Use cronjob.Register() instead for more flexible cron-based scheduling.
 // Example migration:
 //
 //	// Old: task.Register(fn, 5*time.Minute, "my-task")
 //	// New: cronjob.Register(fn, "0 */5 * * * *", "my-task")
-func Register(fn func() error, interval time.Duration, name string) {
+func Register(fn func() error, interval time.Duration, name string, config ...Config) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	// #nosec G118 -- cancel is stored on task and called in task goroutine via defer t.cancel() when it exits
-	ctx, cancel := context.WithCancel(context.Background())
+	t := &task{name: name, fn: fn, interval: interval}
+	if len(config) > 0 {
+		t.cfg = config[0]
+	}
 	if inited {
-		register(&task{name: name, fn: fn, interval: interval, ctx: ctx, cancel: cancel})
+		register(t)
 	} else {
-		tasks = append(tasks, &task{name: name, fn: fn, interval: interval, ctx: ctx, cancel: cancel})
+		tasks = append(tasks, t)
 	}
 }
 
 func register(t *task) {
 	if t == nil {
-		logger.Task.Warnw("task is nil, skip", "name", t.name, "interval", t.interval.String())
+		logger.Task.Warnw("task is nil, skip")
 		return
 	}
 	if t.interval < time.Second {
@@ -78,40 +98,12 @@ func register(t *task) {
 		logger.Task.Warnw("task function is nil, skip", "name", t.name, "interval", t.interval.String())
 		return
 	}
-	go func() {
-		defer t.cancel()
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Task.Errorw(fmt.Sprintf("task panic: %s", err), "name", t.name, "interval", t.interval.String())
-			}
-		}()
-		begin := time.Now()
-		logger.Task.Infow("starting task", "name", t.name, "interval", t.interval.String())
-		if err := t.fn(); err != nil {
-			logger.Task.Errorw(fmt.Sprintf("finished task with error: %s", err), "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-		} else {
-			logger.Task.Infow("finished task", "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-		}
-
-		ticker := time.NewTicker(t.interval)
-
-		for {
-			select {
-			case <-t.ctx.Done():
-				return
-			case <-ticker.C:
-				begin = time.Now()
-				logger.Task.Infow("starting task", "name", t.name, "interval", t.interval.String())
-				if err := t.fn(); err != nil {
-					logger.Task.Errorw(fmt.Sprintf("finished task with error: %s", err), "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-					// return
-				} else {
-					logger.Task.Infow("finished task", "name", t.name, "interval", t.interval.String(), "cost", util.FormatDurationSmart(time.Since(begin)))
-					// return
-				}
-			}
-		}
-	}()
+	cronjob.Register(t.fn, "@every "+t.interval.String(), t.name, cronjob.Config{
+		RunImmediately: true,
+		Overlap:        cronjob.OverlapSkip,
+		Retry:          t.cfg.Retry,
+		AlertAfter:     t.cfg.AlertAfter,
+	})
 }
 
 func runtimestats() error {
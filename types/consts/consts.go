@@ -26,8 +26,14 @@ const (
 	CTX_USERNAME      = "username"
 	CTX_USER_ID       = "user_id"
 	CTX_SESSION_ID    = "session_id"
+	CTX_TENANT_ID     = "tenant_id"
 	CTX_REQUIRES_AUTH = "requires_auth"
 
+	// CTX_REAL_USERNAME and CTX_REAL_USER_ID are the user who authenticated the session,
+	// they differ from CTX_USERNAME and CTX_USER_ID while an administrator impersonates a user.
+	CTX_REAL_USERNAME = "real_username"
+	CTX_REAL_USER_ID  = "real_user_id"
+
 	DATE_TIME_LAYOUT = "2006-01-02 15:04:05"
 	DATE_ID_LAYOUT   = "20060102"
 
@@ -77,6 +83,7 @@ const (
 	HEADER_TRACE_ID   = "X-Trace-ID"
 	HEADER_SPAN_ID    = "X-Span-ID"
 	HEADER_PSPAN_ID   = "X-Pspan-ID"
+	HEADER_TENANT_ID  = "X-Tenant-ID"
 
 	FIELD_ID = "ID"
 
@@ -450,8 +457,17 @@ const (
 	AUTHZ_USER_ADMIN   = "admin"
 	AUTHZ_USER_BLOCKED = "blocked"
 
-	AUTHZ_ROLE_ADMIN   = "admin"
-	AUTHZ_ROLE_BLOCKED = "blocked"
+	AUTHZ_ROLE_ADMIN       = "admin"
+	AUTHZ_ROLE_BLOCKED     = "blocked"
+	AUTHZ_ROLE_SUPER_ADMIN = "super_admin"
+
+	// AUTHZ_TENANT_ALL is the wildcard tenant, policies and super admin grants in it apply to all tenants.
+	AUTHZ_TENANT_ALL = "*"
+	// AUTHZ_TENANT_DEFAULT is the tenant of requests that carry no tenant.
+	AUTHZ_TENANT_DEFAULT = "default"
+
+	// AUTHZ_CONDITION_NONE is the condition of unconditional policies.
+	AUTHZ_CONDITION_NONE = "true"
 )
 
 type Effect string
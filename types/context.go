@@ -15,9 +15,13 @@ import (
 type ControllerContext struct {
 	Username string // currrent login user.
 	UserID   string // currrent login user id
-	Route    string
-	Params   map[string]string
-	Query    url.Values
+	// RealUsername and RealUserID are the user who authenticated the session,
+	// they differ from Username and UserID while impersonating.
+	RealUsername string
+	RealUserID   string
+	Route        string
+	Params       map[string]string
+	Query        url.Values
 
 	RequestID string
 	TraceID   string
@@ -38,22 +42,33 @@ func NewControllerContext(c *gin.Context) *ControllerContext {
 	}
 
 	return &ControllerContext{
-		Route:     c.GetString(consts.CTX_ROUTE),
-		Username:  c.GetString(consts.CTX_USERNAME),
-		UserID:    c.GetString(consts.CTX_USER_ID),
-		RequestID: c.GetString(consts.REQUEST_ID),
-		TraceID:   c.GetString(consts.TRACE_ID),
-		Params:    params,
-		Query:     c.Request.URL.Query(),
+		Route:        c.GetString(consts.CTX_ROUTE),
+		Username:     c.GetString(consts.CTX_USERNAME),
+		UserID:       c.GetString(consts.CTX_USER_ID),
+		RealUsername: c.GetString(consts.CTX_REAL_USERNAME),
+		RealUserID:   c.GetString(consts.CTX_REAL_USER_ID),
+		RequestID:    c.GetString(consts.REQUEST_ID),
+		TraceID:      c.GetString(consts.TRACE_ID),
+		Params:       params,
+		Query:        c.Request.URL.Query(),
 	}
 }
 
+// Impersonating reports whether the request is made by an administrator acting as UserID.
+func (cc *ControllerContext) Impersonating() bool {
+	return cc != nil && len(cc.RealUserID) > 0 && cc.RealUserID != cc.UserID
+}
+
 type DatabaseContext struct {
 	Username string // currrent login user.
 	UserID   string // currrent login user id
-	Route    string
-	Params   map[string]string
-	Query    url.Values
+	// RealUsername and RealUserID are the user who authenticated the session,
+	// they differ from Username and UserID while impersonating.
+	RealUsername string
+	RealUserID   string
+	Route        string
+	Params       map[string]string
+	Query        url.Values
 
 	context   context.Context
 	RequestID string
@@ -83,17 +98,24 @@ func NewDatabaseContext(c *gin.Context, ctxs ...context.Context) *DatabaseContex
 	}
 
 	return &DatabaseContext{
-		context:   ctx,
-		Route:     c.GetString(consts.CTX_ROUTE),
-		Username:  c.GetString(consts.CTX_USERNAME),
-		UserID:    c.GetString(consts.CTX_USER_ID),
-		RequestID: c.GetString(consts.REQUEST_ID),
-		TraceID:   c.GetString(consts.TRACE_ID),
-		Params:    params,
-		Query:     c.Request.URL.Query(),
+		context:      ctx,
+		Route:        c.GetString(consts.CTX_ROUTE),
+		Username:     c.GetString(consts.CTX_USERNAME),
+		UserID:       c.GetString(consts.CTX_USER_ID),
+		RealUsername: c.GetString(consts.CTX_REAL_USERNAME),
+		RealUserID:   c.GetString(consts.CTX_REAL_USER_ID),
+		RequestID:    c.GetString(consts.REQUEST_ID),
+		TraceID:      c.GetString(consts.TRACE_ID),
+		Params:       params,
+		Query:        c.Request.URL.Query(),
 	}
 }
 
+// Impersonating reports whether the request is made by an administrator acting as UserID.
+func (dc *DatabaseContext) Impersonating() bool {
+	return dc != nil && len(dc.RealUserID) > 0 && dc.RealUserID != dc.UserID
+}
+
 // Context converts *DatabaseContext to context.Context.
 // It starts from the underlying ctx.context and conditionally injects extra metadata.
 func (dc *DatabaseContext) Context() context.Context {
@@ -144,8 +166,14 @@ type ServiceContext struct {
 	SessionID string // session id
 	Username  string // currrent login user.
 	UserID    string // currrent login user id
+	TenantID  string // current tenant, resolved by the Authz middleware when tenant RBAC is enabled
 	Route     string
 
+	// RealUsername and RealUserID are the user who authenticated the session,
+	// they differ from Username and UserID while impersonating.
+	RealUsername string
+	RealUserID   string
+
 	RequestID string
 	TraceID   string
 	PSpanID   string
@@ -193,6 +221,10 @@ func NewServiceContext(c *gin.Context, ctxs ...context.Context) *ServiceContext
 		Username:  c.GetString(consts.CTX_USERNAME),
 		UserID:    c.GetString(consts.CTX_USER_ID),
 		SessionID: c.GetString(consts.CTX_SESSION_ID),
+		TenantID:  c.GetString(consts.CTX_TENANT_ID),
+
+		RealUsername: c.GetString(consts.CTX_REAL_USERNAME),
+		RealUserID:   c.GetString(consts.CTX_REAL_USER_ID),
 
 		RequestID: c.GetString(consts.REQUEST_ID),
 		TraceID:   c.GetString(consts.TRACE_ID),
@@ -231,6 +263,11 @@ func (sc *ServiceContext) Context() context.Context {
 	return c
 }
 
+// Impersonating reports whether the request is made by an administrator acting as UserID.
+func (sc *ServiceContext) Impersonating() bool {
+	return sc != nil && len(sc.RealUserID) > 0 && sc.RealUserID != sc.UserID
+}
+
 func (sc *ServiceContext) DatabaseContext() *DatabaseContext {
 	return NewDatabaseContext(sc.ginCtx, sc.context)
 }
@@ -160,6 +160,8 @@ type DatabaseOption[M Model] interface {
 	WithPagination(page, size int) Database[M]
 	// WithLimit restricts the number of returned records.
 	WithLimit(limit int) Database[M]
+	// WithOffset skips the first offset records.
+	WithOffset(offset int) Database[M]
 	// WithExclude excludes records matching specified conditions.
 	WithExclude(map[string][]any) Database[M]
 	// WithOrder adds ORDER BY clause to sort query results.
@@ -326,8 +328,10 @@ type Cache[T any] interface {
 // Features:
 //   - Automatic cache synchronization across multiple application instances
 //   - Configurable TTL for both local and distributed cache layers
-//   - Event-driven cache invalidation using Kafka messaging
+//   - Event-driven cache invalidation using Kafka, Redis pub/sub, NATS or an in-memory event bus
 //   - Thread-safe concurrent operations
+//
+// Len and Clear are not supported, Len always returns -1 and Clear is a no-op.
 type DistributedCache[T any] interface {
 	Cache[T]
 
@@ -354,17 +358,44 @@ type RBAC interface {
 	RemoveRole(name string) error
 
 	GrantPermission(role string, resource string, action string) error
+	// GrantConditionalPermission grants the permission only when the condition evaluated
+	// against the request context is met, eg: only during business hours or from office CIDR.
+	// Empty condition is the same as GrantPermission.
+	// It replaces the condition of an existing grant of the same role, resource and action.
+	GrantConditionalPermission(role string, resource string, action string, condition string) error
 	// RevokePermission removes policies for the given role with flexible behaviors:
 	// - resource=="" && action=="" : remove all policies for the role
 	// - resource=="" && action!="" : remove policies matching the role and action
 	// - resource!="" && action=="" : remove policies matching the role and resource
-	// - resource!="" && action!="" : remove the exact (role, resource, action, "allow") policy
+	// - resource!="" && action!="" : remove the (role, resource, action) policies regardless of their condition
 	RevokePermission(role string, resource string, action string) error
 
 	AssignRole(subject string, role string) error
 	UnassignRole(subject string, role string) error
 }
 
+// TenantRBAC provides tenant-aware role-based access control operations.
+// It is the RBAC variant for the domain model, every permission and role grant belongs to a tenant.
+//
+// The tenant "*" is the wildcard tenant:
+//   - permissions granted in "*" apply to the role in all tenants
+//   - subjects granted the "super_admin" role in "*" can access all resources of all tenants
+type TenantRBAC interface {
+	AddRole(tenant string, name string) error
+	// RemoveRole removes the role's permissions and grants in the tenant,
+	// the tenant "*" removes them from all tenants.
+	RemoveRole(tenant string, name string) error
+
+	GrantPermission(tenant string, role string, resource string, action string) error
+	// GrantConditionalPermission is the tenant aware RBAC.GrantConditionalPermission.
+	GrantConditionalPermission(tenant string, role string, resource string, action string, condition string) error
+	// RevokePermission removes the role's policies in the tenant, empty resource or action matches all.
+	RevokePermission(tenant string, role string, resource string, action string) error
+
+	AssignRole(tenant string, subject string, role string) error
+	UnassignRole(tenant string, subject string, role string) error
+}
+
 // Module defines a module system for creating modular API endpoints
 // with automatic CRUD operations, routing, and service layer integration.
 //